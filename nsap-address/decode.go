@@ -0,0 +1,417 @@
+package nsap_address
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"unicode/utf8"
+)
+
+var ErrEmptyNetworkAddress = errors.New("empty network address")
+var ErrUnrecognizedAFI = errors.New("unrecognized authority and format identifier (AFI)")
+var ErrTruncatedIDI = errors.New("truncated initial domain identifier (IDI)")
+var ErrMalformedIDI = errors.New("malformed initial domain identifier (IDI)")
+var ErrMalformedDSP = errors.New("malformed domain specific part (DSP)")
+var ErrDSPTooLong = errors.New("domain specific part (DSP) too long")
+
+// IDIs used with the IANA ICP AFI. The DSP of the former is an IPv6 address
+// and the DSP of the latter is an IPv4 address.
+var ianaIcpIdiIPv6 = []byte("0000")
+var ianaIcpIdiIPv4 = []byte("0001")
+
+var individualToGroupAFI = map[byte]byte{
+	AFI_X121_DEC_LEADING_NON_ZERO: GROUP_AFI_X121_DEC_LEADING_NON_ZERO,
+	AFI_X121_DEC_LEADING_ZERO:     GROUP_AFI_X121_DEC_LEADING_ZERO,
+	AFI_X121_BIN_LEADING_NON_ZERO: GROUP_AFI_X121_BIN_LEADING_NON_ZERO,
+	AFI_X121_BIN_LEADING_ZERO:     GROUP_AFI_X121_BIN_LEADING_ZERO,
+	AFI_ISO_DCC_DEC:               GROUP_AFI_ISO_DCC_DEC,
+	AFI_ISO_DCC_BIN:               GROUP_AFI_ISO_DCC_BIN,
+	AFI_F69_DEC_LEADING_NON_ZERO:  GROUP_AFI_F69_DEC_LEADING_NON_ZERO,
+	AFI_F69_DEC_LEADING_ZERO:      GROUP_AFI_F69_DEC_LEADING_ZERO,
+	AFI_F69_BIN_LEADING_NON_ZERO:  GROUP_AFI_F69_BIN_LEADING_NON_ZERO,
+	AFI_F69_BIN_LEADING_ZERO:      GROUP_AFI_F69_BIN_LEADING_ZERO,
+	AFI_E163_DEC_LEADING_NON_ZERO: GROUP_AFI_E163_DEC_LEADING_NON_ZERO,
+	AFI_E163_DEC_LEADING_ZERO:     GROUP_AFI_E163_DEC_LEADING_ZERO,
+	AFI_E163_BIN_LEADING_NON_ZERO: GROUP_AFI_E163_BIN_LEADING_NON_ZERO,
+	AFI_E163_BIN_LEADING_ZERO:     GROUP_AFI_E163_BIN_LEADING_ZERO,
+	AFI_E164_DEC_LEADING_NON_ZERO: GROUP_AFI_E164_DEC_LEADING_NON_ZERO,
+	AFI_E164_DEC_LEADING_ZERO:     GROUP_AFI_E164_DEC_LEADING_ZERO,
+	AFI_E164_BIN_LEADING_NON_ZERO: GROUP_AFI_E164_BIN_LEADING_NON_ZERO,
+	AFI_E164_BIN_LEADING_ZERO:     GROUP_AFI_E164_BIN_LEADING_ZERO,
+	AFI_ISO_6523_ICD_DEC:          GROUP_AFI_ISO_6523_ICD_DEC,
+	AFI_ISO_6523_ICD_BIN:          GROUP_AFI_ISO_6523_ICD_BIN,
+	AFI_IANA_ICP_DEC:              GROUP_AFI_IANA_ICP_DEC,
+	AFI_IANA_ICP_BIN:              GROUP_AFI_IANA_ICP_BIN,
+	AFI_ITU_T_IND_DEC:             GROUP_AFI_ITU_T_IND_DEC,
+	AFI_ITU_T_IND_BIN:             GROUP_AFI_ITU_T_IND_BIN,
+	AFI_LOCAL_DEC:                 GROUP_AFI_LOCAL_DEC,
+	AFI_LOCAL_BIN:                 GROUP_AFI_LOCAL_BIN,
+	AFI_LOCAL_ISO_IEC_646:         GROUP_AFI_LOCAL_ISO_IEC_646,
+	AFI_LOCAL_NATIONAL:            GROUP_AFI_LOCAL_NATIONAL,
+}
+
+var groupToIndividualAFI = func() map[byte]byte {
+	ret := make(map[byte]byte, len(individualToGroupAFI))
+	for ind, group := range individualToGroupAFI {
+		ret[group] = ind
+	}
+	return ret
+}()
+
+// Convert a group AFI to its individual equivalent. The second return value is
+// false if the AFI is not a recognized group AFI.
+func GroupAFIToIndividualAFI(afi byte) (byte, bool) {
+	ind_afi, is_group := groupToIndividualAFI[afi]
+	if !is_group {
+		return afi, false
+	}
+	return ind_afi, true
+}
+
+// Convert an individual AFI to its group equivalent. The second return value
+// is false if the AFI is not a recognized individual AFI.
+func IndividualAFIToGroupAFI(afi byte) (byte, bool) {
+	group_afi, is_ind := individualToGroupAFI[afi]
+	if !is_ind {
+		return afi, false
+	}
+	return group_afi, true
+}
+
+// Return the network type identified by an individual or group AFI.
+func NetworkTypeFromAFI(afi byte) (X213NetworkAddressType, bool) {
+	if afi == AFI_URL {
+		return URL, true
+	}
+	ind_afi, _ := GroupAFIToIndividualAFI(afi)
+	switch ind_afi {
+	case AFI_X121_DEC_LEADING_NON_ZERO, AFI_X121_DEC_LEADING_ZERO,
+		AFI_X121_BIN_LEADING_NON_ZERO, AFI_X121_BIN_LEADING_ZERO:
+		return X121, true
+	case AFI_ISO_DCC_DEC, AFI_ISO_DCC_BIN:
+		return ISO_DCC, true
+	case AFI_F69_DEC_LEADING_NON_ZERO, AFI_F69_DEC_LEADING_ZERO,
+		AFI_F69_BIN_LEADING_NON_ZERO, AFI_F69_BIN_LEADING_ZERO:
+		return F69, true
+	case AFI_E163_DEC_LEADING_NON_ZERO, AFI_E163_DEC_LEADING_ZERO,
+		AFI_E163_BIN_LEADING_NON_ZERO, AFI_E163_BIN_LEADING_ZERO:
+		return E163, true
+	case AFI_E164_DEC_LEADING_NON_ZERO, AFI_E164_DEC_LEADING_ZERO,
+		AFI_E164_BIN_LEADING_NON_ZERO, AFI_E164_BIN_LEADING_ZERO:
+		return E164, true
+	case AFI_ISO_6523_ICD_DEC, AFI_ISO_6523_ICD_BIN:
+		return ISO_6523_ICD, true
+	case AFI_IANA_ICP_DEC, AFI_IANA_ICP_BIN:
+		return IANA_ICP, true
+	case AFI_ITU_T_IND_DEC, AFI_ITU_T_IND_BIN:
+		return ITU_T_IND, true
+	case AFI_LOCAL_DEC, AFI_LOCAL_BIN, AFI_LOCAL_ISO_IEC_646, AFI_LOCAL_NATIONAL:
+		return LOCAL, true
+	}
+	return X121, false
+}
+
+// Return the maximum length of the IDI in digits for a given network type.
+func MaxIDILength(nt X213NetworkAddressType) int {
+	switch nt {
+	case X121:
+		return MAX_IDI_LEN_X121
+	case ISO_DCC:
+		return MAX_IDI_LEN_ISO_DCC
+	case F69:
+		return MAX_IDI_LEN_F69
+	case E163:
+		return MAX_IDI_LEN_E163
+	case E164:
+		return MAX_IDI_LEN_E164
+	case ISO_6523_ICD:
+		return MAX_IDI_LEN_ISO_6523_ICD
+	case IANA_ICP:
+		return MAX_IDI_LEN_IANA_ICP
+	case ITU_T_IND:
+		return MAX_IDI_LEN_ITU_T_IND
+	case URL:
+		return MAX_IDI_LEN_URL
+	}
+	return MAX_IDI_LEN_LOCAL
+}
+
+// Returns true if the IDI of this network type is of variable length, meaning
+// that it is padded to its maximum length in the binary encoding.
+func idiIsVariableLength(nt X213NetworkAddressType) bool {
+	switch nt {
+	case X121, F69, E163, E164:
+		return true
+	}
+	return false
+}
+
+func idiHasLeadingZero(ind_afi byte) bool {
+	switch ind_afi {
+	case AFI_X121_DEC_LEADING_ZERO, AFI_X121_BIN_LEADING_ZERO,
+		AFI_F69_DEC_LEADING_ZERO, AFI_F69_BIN_LEADING_ZERO,
+		AFI_E163_DEC_LEADING_ZERO, AFI_E163_BIN_LEADING_ZERO,
+		AFI_E164_DEC_LEADING_ZERO, AFI_E164_BIN_LEADING_ZERO:
+		return true
+	}
+	return false
+}
+
+func dspTypeFromAFI(ind_afi byte) DomainSpecificPartType {
+	switch ind_afi {
+	case AFI_X121_DEC_LEADING_NON_ZERO, AFI_X121_DEC_LEADING_ZERO,
+		AFI_ISO_DCC_DEC,
+		AFI_F69_DEC_LEADING_NON_ZERO, AFI_F69_DEC_LEADING_ZERO,
+		AFI_E163_DEC_LEADING_NON_ZERO, AFI_E163_DEC_LEADING_ZERO,
+		AFI_E164_DEC_LEADING_NON_ZERO, AFI_E164_DEC_LEADING_ZERO,
+		AFI_ISO_6523_ICD_DEC, AFI_IANA_ICP_DEC, AFI_ITU_T_IND_DEC,
+		AFI_LOCAL_DEC:
+		return DSP_DECIMAL
+	case AFI_LOCAL_ISO_IEC_646:
+		return DSP_ISO_IEC_646
+	case AFI_LOCAL_NATIONAL:
+		return DSP_NATIONAL
+	}
+	return DSP_BINARY
+}
+
+func maxDecimalDSPLength(nt X213NetworkAddressType) int {
+	switch nt {
+	case X121:
+		return MAX_DEC_DSP_LEN_X121
+	case ISO_DCC:
+		return MAX_DEC_DSP_LEN_ISO_DCC
+	case F69:
+		return MAX_DEC_DSP_LEN_F69
+	case E163:
+		return MAX_DEC_DSP_LEN_E163
+	case E164:
+		return MAX_DEC_DSP_LEN_E164
+	case ISO_6523_ICD:
+		return MAX_DEC_DSP_LEN_ISO_6523_ICD
+	case IANA_ICP:
+		return MAX_DEC_DSP_LEN_IANA_ICP
+	case ITU_T_IND:
+		return MAX_DEC_DSP_LEN_ITU_T_IND
+	}
+	return MAX_DEC_DSP_LEN_LOCAL
+}
+
+// Decode semi-octets into digits, stopping at the first 0xF padding nibble.
+// Returns false if a non-decimal, non-padding nibble is encountered, or if
+// padding is followed by anything other than more padding.
+func decodeSemiOctets(b []byte) ([]byte, bool) {
+	digits := make([]byte, 0, len(b)*2)
+	padded := false
+	for _, octet := range b {
+		for _, nybble := range [2]byte{octet >> 4, octet & 0x0F} {
+			if nybble == 0x0F {
+				padded = true
+				continue
+			}
+			if padded || nybble > 9 {
+				return nil, false
+			}
+			digits = append(digits, nybble)
+		}
+	}
+	return digits, true
+}
+
+// Decode an X.213 Network Service Access Point (NSAP) address from the
+// "preferred binary encoding" described in Annex A, Section A.5.3 of ITU-T
+// Recommendation X.213 (2001), including the URL and IANA ICP forms.
+func FromBytes(b []byte) (X213NetworkAddress, error) {
+	if len(b) == 0 {
+		return X213NetworkAddress{}, ErrEmptyNetworkAddress
+	}
+	afi := b[0]
+	nt, recognized := NetworkTypeFromAFI(afi)
+	if !recognized {
+		return X213NetworkAddress{}, ErrUnrecognizedAFI
+	}
+	ind_afi, group := GroupAFIToIndividualAFI(afi)
+	max_idi_len := MaxIDILength(nt)
+	idi_octets := (max_idi_len + 1) / 2
+	if len(b) < 1+idi_octets {
+		return X213NetworkAddress{}, ErrTruncatedIDI
+	}
+	idi_digits, ok := decodeSemiOctets(b[1 : 1+idi_octets])
+	if !ok {
+		return X213NetworkAddress{}, ErrMalformedIDI
+	}
+	// Variable-length IDIs are left-padded to their maximum length with the
+	// digit 1 if leading zeroes are significant, and 0 otherwise.
+	if idiIsVariableLength(nt) {
+		padding_digit := byte(0)
+		if idiHasLeadingZero(ind_afi) {
+			padding_digit = 1
+		}
+		for len(idi_digits) > 0 && idi_digits[0] == padding_digit {
+			idi_digits = idi_digits[1:]
+		}
+	}
+	idi := make([]byte, len(idi_digits))
+	for i, d := range idi_digits {
+		idi[i] = d + 0x30
+	}
+	dsp := b[1+idi_octets:]
+	addr := X213NetworkAddress{
+		Network_type: nt,
+		Group:        group,
+		Idi:          idi,
+	}
+	if nt == URL {
+		if !utf8.Valid(dsp) {
+			return X213NetworkAddress{}, ErrMalformedDSP
+		}
+		addr.DspType = DSP_URL
+		addr.Dsp = dsp
+		return addr, nil
+	}
+	if nt == IANA_ICP && ind_afi == AFI_IANA_ICP_BIN {
+		if string(idi) == string(ianaIcpIdiIPv6) && len(dsp) >= net.IPv6len {
+			addr.DspType = DSP_IP_ADDRESS
+			addr.Dsp = dsp[:net.IPv6len]
+			return addr, nil
+		}
+		if string(idi) == string(ianaIcpIdiIPv4) && len(dsp) >= net.IPv4len {
+			addr.DspType = DSP_IP_ADDRESS
+			addr.Dsp = dsp[:net.IPv4len]
+			return addr, nil
+		}
+	}
+	addr.DspType = dspTypeFromAFI(ind_afi)
+	switch addr.DspType {
+	case DSP_DECIMAL:
+		digits, ok := decodeSemiOctets(dsp)
+		if !ok {
+			return X213NetworkAddress{}, ErrMalformedDSP
+		}
+		if len(digits) > maxDecimalDSPLength(nt) {
+			return X213NetworkAddress{}, ErrDSPTooLong
+		}
+		addr.Dsp = digits
+	case DSP_ISO_IEC_646:
+		if len(dsp) > MAX_ISO_IEC_646_LEN_LOCAL {
+			return X213NetworkAddress{}, ErrDSPTooLong
+		}
+		for _, c := range dsp {
+			if c > 0x7F {
+				return X213NetworkAddress{}, ErrMalformedDSP
+			}
+		}
+		addr.Dsp = dsp
+	case DSP_NATIONAL:
+		if len(dsp) > MAX_NATIONAL_CHAR_LEN_LOCAL*2 {
+			return X213NetworkAddress{}, ErrDSPTooLong
+		}
+		addr.Dsp = dsp
+	default:
+		addr.Dsp = dsp
+	}
+	return addr, nil
+}
+
+// Returns true if this NSAP uses the IETF RFC 1277 telex number to identify
+// an address on the Internet, as used by IETF RFC 1006 and ITU-T Rec. X.519.
+func (naddr *X213NetworkAddress) IsInternet() bool {
+	return naddr.Network_type == F69 &&
+		string(naddr.Idi) == IETF_RFC_1277_TELEX_NUMBER_STR &&
+		naddr.DspType == DSP_DECIMAL &&
+		len(naddr.Dsp) >= 2
+}
+
+// Return the two-digit DSP prefix used by IETF RFC 1277 and ITU-T Rec. X.519
+// to identify the network or protocol of an Internet NSAP. The second return
+// value is false if this is not an Internet NSAP.
+func (naddr *X213NetworkAddress) InternetDSPPrefix() (byte, bool) {
+	if !naddr.IsInternet() {
+		return 0, false
+	}
+	// The prefix is compared against constants written in hexadecimal, such
+	// as 0x03 or 0x11, so each decimal digit becomes a semi-octet.
+	return (naddr.Dsp[0] << 4) | naddr.Dsp[1], true
+}
+
+func decimalDigitsToInt(digits []byte) int {
+	ret := 0
+	for _, d := range digits {
+		ret = (ret * 10) + int(d)
+	}
+	return ret
+}
+
+// Return the IP address and port identified by this NSAP. The port is zero if
+// none is encoded. The last return value is false if this NSAP does not
+// identify an IP address.
+//
+// Internet NSAPs are decoded as described in IETF RFC 1277, Section 4.3: after
+// the two-digit prefix, the IPv4 address is encoded as twelve digits, followed
+// optionally by a five-digit port and a five-digit transport set.
+func (naddr *X213NetworkAddress) SocketAddr() (ip net.IP, port int, ok bool) {
+	if naddr.DspType == DSP_IP_ADDRESS {
+		if len(naddr.Dsp) != net.IPv4len && len(naddr.Dsp) != net.IPv6len {
+			return nil, 0, false
+		}
+		return net.IP(naddr.Dsp), 0, true
+	}
+	if !naddr.IsInternet() || len(naddr.Dsp) < 14 {
+		return nil, 0, false
+	}
+	octets := make([]byte, 4)
+	for i := range octets {
+		octet := decimalDigitsToInt(naddr.Dsp[2+(i*3) : 5+(i*3)])
+		if octet > 255 {
+			return nil, 0, false
+		}
+		octets[i] = byte(octet)
+	}
+	ip = net.IPv4(octets[0], octets[1], octets[2], octets[3])
+	if len(naddr.Dsp) >= 19 {
+		port = decimalDigitsToInt(naddr.Dsp[14:19])
+		if port > 65535 {
+			return nil, 0, false
+		}
+	}
+	return ip, port, true
+}
+
+// Return the URL identified by this NSAP, if it is a URL NSAP or an Internet
+// NSAP using one of the DSP prefixes defined in ITU-T Rec. X.519 for IDM, ITOT,
+// or LDAP. IDM and LDAP NSAPs must encode a port to be converted to a URL.
+func (naddr *X213NetworkAddress) ToURL() (string, error) {
+	if naddr.Network_type == URL {
+		if naddr.DspType != DSP_URL {
+			return "", ErrMalformedDSP
+		}
+		return string(naddr.Dsp), nil
+	}
+	prefix, is_internet := naddr.InternetDSPPrefix()
+	if !is_internet {
+		return "", errors.New("network address does not identify a URL")
+	}
+	ip, port, ok := naddr.SocketAddr()
+	if !ok {
+		return "", ErrMalformedDSP
+	}
+	var scheme string
+	switch prefix {
+	case ITU_X519_DSP_PREFIX_ITOT_OVER_IPV4:
+		scheme = "itot"
+		if port == 0 {
+			port = ITOT_OVER_IPV4_DEFAULT_PORT
+		}
+	case ITU_X519_DSP_PREFIX_IDM_OVER_IPV4:
+		scheme = "idm"
+	case ITU_X519_DSP_PREFIX_LDAP:
+		scheme = "ldap"
+	default:
+		return "", fmt.Errorf("unrecognized internet dsp prefix %02x", prefix)
+	}
+	if port == 0 {
+		return "", fmt.Errorf("%s network address does not encode a port", scheme)
+	}
+	return scheme + "://" + net.JoinHostPort(ip.String(), strconv.Itoa(port)), nil
+}
@@ -0,0 +1,263 @@
+package nsap_address
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+)
+
+// TELEX+00728722+RFC-1006+03+10.0.0.6, from IETF RFC 1277.
+var rfc1277Internet = []byte{
+	AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x22,
+	0x03, 0x01, 0x00, 0x00, 0x00, 0x00, 0x06,
+}
+
+func TestFromBytes(t *testing.T) {
+	cases := []struct {
+		name    string
+		b       []byte
+		nt      X213NetworkAddressType
+		group   bool
+		idi     string
+		dspType DomainSpecificPartType
+		dsp     []byte
+	}{
+		{
+			name:    "RFC 1277 internet",
+			b:       rfc1277Internet,
+			nt:      F69,
+			idi:     IETF_RFC_1277_TELEX_NUMBER_STR,
+			dspType: DSP_DECIMAL,
+			dsp:     []byte{0, 3, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 6},
+		},
+		{
+			// TELEX+00728722+RFC-1006+03+10.0.0.6+8080, with the odd number
+			// of digits padded to an octet boundary.
+			name: "RFC 1277 internet with port",
+			b: []byte{
+				AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x22,
+				0x03, 0x01, 0x00, 0x00, 0x00, 0x00, 0x06, 0x08, 0x08, 0x0F,
+			},
+			nt:      F69,
+			idi:     IETF_RFC_1277_TELEX_NUMBER_STR,
+			dspType: DSP_DECIMAL,
+			dsp:     []byte{0, 3, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 6, 0, 8, 0, 8, 0},
+		},
+		{
+			name:    "X.121 with leading padding",
+			b:       []byte{AFI_X121_DEC_LEADING_NON_ZERO, 0x00, 0x23, 0x42, 0x19, 0x20, 0x03, 0x00},
+			nt:      X121,
+			idi:     "234219200300",
+			dspType: DSP_DECIMAL,
+			dsp:     []byte{},
+		},
+		{
+			name:    "group X.121",
+			b:       []byte{GROUP_AFI_X121_DEC_LEADING_NON_ZERO, 0x00, 0x23, 0x42, 0x19, 0x20, 0x03, 0x00},
+			nt:      X121,
+			group:   true,
+			idi:     "234219200300",
+			dspType: DSP_DECIMAL,
+			dsp:     []byte{},
+		},
+		{
+			name:    "IANA ICP IPv4",
+			b:       []byte{AFI_IANA_ICP_BIN, 0x00, 0x01, 10, 9, 8, 7},
+			nt:      IANA_ICP,
+			idi:     "0001",
+			dspType: DSP_IP_ADDRESS,
+			dsp:     []byte{10, 9, 8, 7},
+		},
+		{
+			name:    "IANA ICP IPv6",
+			b:       append([]byte{AFI_IANA_ICP_BIN, 0x00, 0x00}, net.IPv6loopback...),
+			nt:      IANA_ICP,
+			idi:     "0000",
+			dspType: DSP_IP_ADDRESS,
+			dsp:     net.IPv6loopback,
+		},
+		{
+			// Too short to be an IPv6 address, so it is left uninterpreted.
+			name:    "IANA ICP IPv6 truncated",
+			b:       []byte{AFI_IANA_ICP_BIN, 0x00, 0x00, 10, 9, 8, 7},
+			nt:      IANA_ICP,
+			idi:     "0000",
+			dspType: DSP_BINARY,
+			dsp:     []byte{10, 9, 8, 7},
+		},
+		{
+			name:    "URL",
+			b:       append([]byte{AFI_URL, 0x00, 0x01}, "itot://localhost:109"...),
+			nt:      URL,
+			idi:     "0001",
+			dspType: DSP_URL,
+			dsp:     []byte("itot://localhost:109"),
+		},
+		{
+			name:    "ISO 6523 ICD",
+			b:       []byte{AFI_ISO_6523_ICD_BIN, 0x87, 0x23, 0x01, 0x02, 0x03, 0x04},
+			nt:      ISO_6523_ICD,
+			idi:     "8723",
+			dspType: DSP_BINARY,
+			dsp:     []byte{0x01, 0x02, 0x03, 0x04},
+		},
+	}
+	for _, c := range cases {
+		addr, err := FromBytes(c.b)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if addr.Network_type != c.nt || addr.Group != c.group || string(addr.Idi) != c.idi {
+			t.Errorf("%s: unexpected network type, group, or IDI: %+v", c.name, addr)
+			continue
+		}
+		if addr.DspType != c.dspType || !bytes.Equal(addr.Dsp, c.dsp) {
+			t.Errorf("%s: expected DSP %v of type %d, but got %v of type %d", c.name, c.dsp, c.dspType, addr.Dsp, addr.DspType)
+		}
+	}
+}
+
+func TestFromBytesErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		b    []byte
+		err  error
+	}{
+		{"empty", []byte{}, ErrEmptyNetworkAddress},
+		{"unrecognized AFI", []byte{0x00, 0x00}, ErrUnrecognizedAFI},
+		{"truncated IDI", []byte{AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72}, ErrTruncatedIDI},
+		{"non-decimal IDI", []byte{AFI_F69_DEC_LEADING_ZERO, 0x00, 0x7A, 0x87, 0x22}, ErrMalformedIDI},
+		{"non-decimal DSP", []byte{AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x22, 0x03, 0xB1}, ErrMalformedDSP},
+		{"digits after padding", []byte{AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x22, 0x0F, 0x01}, ErrMalformedDSP},
+		{"invalid URL", []byte{AFI_URL, 0x00, 0x01, 0xFF, 0xFE}, ErrMalformedDSP},
+		{"ISO/IEC 646 too long", append([]byte{AFI_LOCAL_ISO_IEC_646}, bytes.Repeat([]byte{'a'}, MAX_ISO_IEC_646_LEN_LOCAL+1)...), ErrDSPTooLong},
+		{"ISO/IEC 646 eighth bit", []byte{AFI_LOCAL_ISO_IEC_646, 0x80}, ErrMalformedDSP},
+	}
+	for _, c := range cases {
+		if _, err := FromBytes(c.b); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, but got %v", c.name, c.err, err)
+		}
+	}
+}
+
+func TestSocketAddr(t *testing.T) {
+	cases := []struct {
+		name string
+		b    []byte
+		ip   net.IP
+		port int
+		ok   bool
+	}{
+		{"RFC 1277 internet", rfc1277Internet, net.IPv4(10, 0, 0, 6), 0, true},
+		{
+			// TELEX+00728722+RFC-1006+03+10.0.0.6+8080+1
+			name: "RFC 1277 internet with port and transport set",
+			b: []byte{
+				AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x22,
+				0x03, 0x01, 0x00, 0x00, 0x00, 0x00, 0x06, 0x08, 0x08, 0x00, 0x00, 0x01,
+			},
+			ip:   net.IPv4(10, 0, 0, 6),
+			port: 8080,
+			ok:   true,
+		},
+		{
+			name: "RFC 1277 internet octet out of range",
+			b: []byte{
+				AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x22,
+				0x03, 0x25, 0x60, 0x00, 0x00, 0x00, 0x06,
+			},
+		},
+		{
+			name: "RFC 1277 internet port out of range",
+			b: []byte{
+				AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x22,
+				0x03, 0x01, 0x00, 0x00, 0x00, 0x00, 0x06, 0x70, 0x00, 0x0F,
+			},
+		},
+		{
+			name: "RFC 1277 internet truncated",
+			b:    []byte{AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x22, 0x03, 0x01, 0x00},
+		},
+		{
+			name: "other telex number",
+			b:    []byte{AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x23, 0x03, 0x01, 0x00, 0x00, 0x00, 0x00, 0x06},
+		},
+		{"IANA ICP IPv4", []byte{AFI_IANA_ICP_BIN, 0x00, 0x01, 10, 9, 8, 7}, net.IPv4(10, 9, 8, 7), 0, true},
+		{"IANA ICP IPv6", append([]byte{AFI_IANA_ICP_BIN, 0x00, 0x00}, net.IPv6loopback...), net.IPv6loopback, 0, true},
+		{"URL", append([]byte{AFI_URL, 0x00, 0x01}, "idm://localhost:4632"...), nil, 0, false},
+	}
+	for _, c := range cases {
+		addr, err := FromBytes(c.b)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		ip, port, ok := addr.SocketAddr()
+		if ok != c.ok || port != c.port || !ip.Equal(c.ip) {
+			t.Errorf("%s: expected %s port %d (%t), but got %s port %d (%t)", c.name, c.ip, c.port, c.ok, ip, port, ok)
+		}
+	}
+}
+
+func TestToURL(t *testing.T) {
+	internet := func(prefix byte, port ...byte) []byte {
+		b := []byte{AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x22, prefix, 0x19, 0x21, 0x68, 0x00, 0x00, 0x01}
+		return append(b, port...)
+	}
+	cases := []struct {
+		name string
+		b    []byte
+		url  string
+	}{
+		{"ITOT with the default port", internet(ITU_X519_DSP_PREFIX_ITOT_OVER_IPV4), "itot://192.168.0.1:102"},
+		{"ITOT with a port", internet(ITU_X519_DSP_PREFIX_ITOT_OVER_IPV4, 0x01, 0x02, 0x0F), "itot://192.168.0.1:1020"},
+		{"IDM", internet(ITU_X519_DSP_PREFIX_IDM_OVER_IPV4, 0x04, 0x63, 0x2F), "idm://192.168.0.1:4632"},
+		{"LDAP", internet(ITU_X519_DSP_PREFIX_LDAP, 0x00, 0x38, 0x9F), "ldap://192.168.0.1:389"},
+		{"IDM without a port", internet(ITU_X519_DSP_PREFIX_IDM_OVER_IPV4), ""},
+		{"LDAP without a port", internet(ITU_X519_DSP_PREFIX_LDAP), ""},
+		{"Janet", internet(RFC_1277_WELL_KNOWN_NETWORK_JANET), ""},
+		{"URL", append([]byte{AFI_URL, 0x00, 0x00}, "idms://localhost:4632"...), "idms://localhost:4632"},
+		{"IANA ICP", []byte{AFI_IANA_ICP_BIN, 0x00, 0x01, 10, 9, 8, 7}, ""},
+	}
+	for _, c := range cases {
+		addr, err := FromBytes(c.b)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		url, err := addr.ToURL()
+		if c.url == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error, but got %s", c.name, url)
+			}
+			continue
+		}
+		if err != nil || url != c.url {
+			t.Errorf("%s: expected %s, but got %s (%v)", c.name, c.url, url, err)
+		}
+	}
+}
+
+func TestAFIConversions(t *testing.T) {
+	for ind, group := range individualToGroupAFI {
+		if g, ok := IndividualAFIToGroupAFI(ind); !ok || g != group {
+			t.Errorf("individual AFI %02x: expected group AFI %02x, but got %02x", ind, group, g)
+		}
+		if i, ok := GroupAFIToIndividualAFI(group); !ok || i != ind {
+			t.Errorf("group AFI %02x: expected individual AFI %02x, but got %02x", group, ind, i)
+		}
+		indType, indOk := NetworkTypeFromAFI(ind)
+		groupType, groupOk := NetworkTypeFromAFI(group)
+		if !indOk || !groupOk || indType != groupType {
+			t.Errorf("AFIs %02x and %02x identify different network types", ind, group)
+		}
+	}
+	if _, ok := GroupAFIToIndividualAFI(AFI_F69_DEC_LEADING_ZERO); ok {
+		t.Error("expected an individual AFI not to be a group AFI")
+	}
+	if nt, ok := NetworkTypeFromAFI(AFI_URL); !ok || nt != URL {
+		t.Errorf("expected the URL AFI to identify a URL, but got %d", nt)
+	}
+}
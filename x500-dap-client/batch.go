@@ -0,0 +1,413 @@
+package x500_dap_client
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"github.com/Wildboar-Software/x500-go/x500"
+)
+
+// The kind of update operation performed by a [BatchStep].
+type BatchOperationType int
+
+const (
+	BatchAddEntry    BatchOperationType = iota
+	BatchRemoveEntry BatchOperationType = iota
+	BatchModifyEntry BatchOperationType = iota
+	BatchModifyDN    BatchOperationType = iota
+)
+
+func (t BatchOperationType) String() string {
+	switch t {
+	case BatchAddEntry:
+		return "addEntry"
+	case BatchRemoveEntry:
+		return "removeEntry"
+	case BatchModifyEntry:
+		return "modifyEntry"
+	case BatchModifyDN:
+		return "modifyDN"
+	default:
+		return "unknown"
+	}
+}
+
+// A single update operation within a batch run by [RunBatch].
+type BatchStep struct {
+	Type BatchOperationType
+
+	// The entry to which the operation applies.
+	Object DN
+
+	// The attributes of the new entry. Only used by [BatchAddEntry].
+	Entry []x500.Attribute
+
+	// The changes to make. Only used by [BatchModifyEntry].
+	Changes []x500.EntryModification
+
+	// Only used by [BatchModifyDN].
+	NewRDN       x500.RelativeDistinguishedName
+	DeleteOldRDN bool
+	NewSuperior  DN
+}
+
+// Create a step that adds an entry.
+func AddEntryStep(dn DN, attrs []x500.Attribute) BatchStep {
+	return BatchStep{Type: BatchAddEntry, Object: dn, Entry: attrs}
+}
+
+// Create a step that removes an entry.
+func RemoveEntryStep(dn DN) BatchStep {
+	return BatchStep{Type: BatchRemoveEntry, Object: dn}
+}
+
+// Create a step that modifies an entry.
+func ModifyEntryStep(dn DN, changes ...x500.EntryModification) BatchStep {
+	return BatchStep{Type: BatchModifyEntry, Object: dn, Changes: changes}
+}
+
+// Create a step that renames or moves an entry. If `newSuperior` is nil, the
+// entry is not moved. If it is empty, but not nil, the entry is moved to the
+// root.
+func ModifyDNStep(dn DN, newRDN x500.RelativeDistinguishedName, deleteOldRDN bool, newSuperior DN) BatchStep {
+	return BatchStep{
+		Type:         BatchModifyDN,
+		Object:       dn,
+		NewRDN:       newRDN,
+		DeleteOldRDN: deleteOldRDN,
+		NewSuperior:  newSuperior,
+	}
+}
+
+// Create a step that adds a member to a group, like [IDMProtocolStack.GroupAdd].
+func GroupAddStep(group, member DN, uid *asn1.BitString) (BatchStep, error) {
+	attr := x500.Attribute{Type: x500.Id_at_member}
+	value, err := getMemberAttr(member, uid)
+	if err != nil {
+		return BatchStep{}, err
+	}
+	attr.Values = []asn1.RawValue{value}
+	if uid != nil && uid.BitLength > 0 {
+		attr.Type = x500.Id_at_uniqueMember
+	}
+	mod, err := x500.NewEntryModification(x500.EntryModification_AddValues, attr)
+	if err != nil {
+		return BatchStep{}, err
+	}
+	return ModifyEntryStep(group, mod), nil
+}
+
+// The outcome of a single step of a batch, and of its compensation, if any.
+type BatchStepReport struct {
+	Step BatchStep
+
+	// Whether the step was attempted at all. Steps after a failed step are not.
+	Attempted bool
+
+	// The outcome of the step itself. The step succeeded if Err is nil and the
+	// outcome is a result.
+	Outcome X500OpOutcome
+	Err     error
+
+	// Whether a compensating operation was attempted, and its outcome.
+	Compensated         bool
+	CompensationOutcome X500OpOutcome
+	CompensationErr     error
+
+	// The state recorded before the step, used to compensate for it.
+	priorAttrs []x500.Attribute
+}
+
+func (r *BatchStepReport) succeeded() bool {
+	return r.Err == nil && r.Outcome.OutcomeType == OP_OUTCOME_RESULT
+}
+
+func (r *BatchStepReport) compensationSucceeded() bool {
+	return r.CompensationErr == nil && r.CompensationOutcome.OutcomeType == OP_OUTCOME_RESULT
+}
+
+// The report returned by [RunBatch].
+type BatchReport struct {
+	Steps []BatchStepReport
+
+	// The index of the step that failed, or -1 if all steps succeeded.
+	FailedStep int
+
+	// True if a step failed and every preceding step was compensated for.
+	RolledBack bool
+}
+
+func outcomeError(outcome X500OpOutcome, err error) error {
+	if err != nil {
+		return err
+	}
+	if outcome.OutcomeType != OP_OUTCOME_RESULT {
+		return fmt.Errorf("outcome type %d", outcome.OutcomeType)
+	}
+	return nil
+}
+
+// Read the given attribute types, or all user attributes if `types` is empty,
+// with all of their contexts.
+func readForCompensation(ctx context.Context, client DirectoryAccessClient, dn DN, types []x500.AttributeType) ([]x500.Attribute, error) {
+	object, err := nameFromDN(dn)
+	if err != nil {
+		return nil, err
+	}
+	arg := x500.ReadArgumentData{
+		Object: object,
+		Selection: x500.EntryInformationSelection{
+			ReturnContexts:   true,
+			ContextSelection: asn1.RawValue{FullBytes: []byte{asn1.TagNull, 0}}, // allContexts
+		},
+	}
+	if len(types) > 0 {
+		arg.Selection.SelectSET = types
+	} else {
+		arg.Selection.AllUserAttributes = asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      []byte{asn1.TagNull, 0},
+		}
+	}
+	outcome, result, err := client.Read(ctx, arg)
+	if err = outcomeError(outcome, err); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, errors.New("could not decode read result")
+	}
+	return x500.EntryInformationAttributes(&result.Entry)
+}
+
+// Return the attribute types affected by modifications.
+func modifiedTypes(changes []x500.EntryModification) ([]x500.AttributeType, error) {
+	types := make([]x500.AttributeType, 0, len(changes))
+	for _, mod := range changes {
+		var attrType x500.AttributeType
+		switch mod.Tag {
+		case x500.EntryModification_RemoveAttribute, x500.EntryModification_ResetValue:
+			_, err := asn1.Unmarshal(mod.Bytes, &attrType)
+			if err != nil {
+				return nil, err
+			}
+		case x500.EntryModification_AlterValues:
+			atav := pkix.AttributeTypeAndValue{}
+			_, err := asn1.Unmarshal(mod.Bytes, &atav)
+			if err != nil {
+				return nil, err
+			}
+			attrType = atav.Type
+		default:
+			attr := x500.Attribute{}
+			_, err := asn1.Unmarshal(mod.Bytes, &attr)
+			if err != nil {
+				return nil, err
+			}
+			attrType = attr.Type
+		}
+		types = append(types, attrType)
+	}
+	return types, nil
+}
+
+func rdnTypes(rdn x500.RelativeDistinguishedName) []x500.AttributeType {
+	types := make([]x500.AttributeType, 0, len(rdn))
+	for _, atav := range rdn {
+		types = append(types, atav.Type)
+	}
+	return types
+}
+
+// Returns true if every value of the RDN is among the attributes, compared
+// using the equality matching rules of their types.
+func rdnValuesPresent(rdn x500.RelativeDistinguishedName, attrs []x500.Attribute) bool {
+	eq := x500.DefaultMatchingRuleRegistry().ValueEqual(nil)
+	for _, atav := range rdn {
+		encoded, err := asn1.Marshal(atav.Value)
+		if err != nil {
+			return false
+		}
+		value := asn1.RawValue{}
+		if _, err = asn1.Unmarshal(encoded, &value); err != nil {
+			return false
+		}
+		found := false
+		for _, attr := range attrs {
+			if !attr.Type.Equal(atav.Type) {
+				continue
+			}
+			for i := 0; i < attr.Len() && !found; i++ {
+				found = eq(atav.Type, value, *attr.Get(i))
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Record the state needed to compensate for a step before it is performed.
+func recordPriorState(ctx context.Context, client DirectoryAccessClient, step BatchStep) ([]x500.Attribute, error) {
+	switch step.Type {
+	case BatchRemoveEntry:
+		return readForCompensation(ctx, client, step.Object, nil)
+	case BatchModifyEntry:
+		types, err := modifiedTypes(step.Changes)
+		if err != nil {
+			return nil, err
+		}
+		return readForCompensation(ctx, client, step.Object, types)
+	case BatchModifyDN:
+		return readForCompensation(ctx, client, step.Object, rdnTypes(step.NewRDN))
+	}
+	return nil, nil
+}
+
+func performStep(ctx context.Context, client DirectoryAccessClient, step BatchStep) (X500OpOutcome, error) {
+	switch step.Type {
+	case BatchAddEntry:
+		object, err := nameFromDN(step.Object)
+		if err != nil {
+			return X500OpOutcome{}, err
+		}
+		outcome, _, err := client.AddEntry(ctx, x500.AddEntryArgumentData{Object: object, Entry: step.Entry})
+		return outcome, err
+	case BatchRemoveEntry:
+		object, err := nameFromDN(step.Object)
+		if err != nil {
+			return X500OpOutcome{}, err
+		}
+		outcome, _, err := client.RemoveEntry(ctx, x500.RemoveEntryArgumentData{Object: object})
+		return outcome, err
+	case BatchModifyEntry:
+		object, err := nameFromDN(step.Object)
+		if err != nil {
+			return X500OpOutcome{}, err
+		}
+		outcome, _, err := client.ModifyEntry(ctx, x500.ModifyEntryArgumentData{Object: object, Changes: step.Changes})
+		return outcome, err
+	case BatchModifyDN:
+		arg := x500.ModifyDNArgumentData{
+			Object:       step.Object,
+			NewRDN:       step.NewRDN,
+			DeleteOldRDN: step.DeleteOldRDN,
+			NewSuperior:  step.NewSuperior,
+		}
+		outcome, _, err := client.ModifyDN(ctx, arg)
+		return outcome, err
+	}
+	return X500OpOutcome{}, fmt.Errorf("unrecognized batch operation type %d", step.Type)
+}
+
+// Undo a step that succeeded, using the state recorded before it.
+func compensate(ctx context.Context, client DirectoryAccessClient, report *BatchStepReport) (X500OpOutcome, error) {
+	step := report.Step
+	switch step.Type {
+	case BatchAddEntry:
+		return performStep(ctx, client, RemoveEntryStep(step.Object))
+	case BatchRemoveEntry:
+		return performStep(ctx, client, AddEntryStep(step.Object, report.priorAttrs))
+	case BatchModifyEntry:
+		types, err := modifiedTypes(step.Changes)
+		if err != nil {
+			return X500OpOutcome{}, err
+		}
+		current, err := readForCompensation(ctx, client, step.Object, types)
+		if err != nil {
+			return X500OpOutcome{}, err
+		}
+		changes, err := x500.DiffAttributes(current, report.priorAttrs, nil)
+		if err != nil {
+			return X500OpOutcome{}, err
+		}
+		if len(changes) == 0 {
+			// Nothing to undo, perhaps because the changes had no effect.
+			return X500OpOutcome{OutcomeType: OP_OUTCOME_RESULT}, nil
+		}
+		return performStep(ctx, client, ModifyEntryStep(step.Object, changes...))
+	case BatchModifyDN:
+		if len(step.Object) == 0 {
+			return X500OpOutcome{}, errors.New("cannot rename the root dse")
+		}
+		oldParent := step.Object[:len(step.Object)-1]
+		oldRDN := step.Object[len(step.Object)-1]
+		newParent := oldParent
+		var newSuperior DN
+		if step.NewSuperior != nil {
+			// Moving back may be moving back to the root, so the new
+			// superior must be empty, but not nil.
+			newParent = step.NewSuperior
+			newSuperior = append(DN{}, oldParent...)
+		}
+		newDN := append(append(DN{}, newParent...), step.NewRDN)
+		// If the new RDN's values were already present before the rename,
+		// they must survive its reversal.
+		deleteNewRDN := !rdnValuesPresent(step.NewRDN, report.priorAttrs)
+		return performStep(ctx, client, ModifyDNStep(newDN, oldRDN, deleteNewRDN, newSuperior))
+	}
+	return X500OpOutcome{}, fmt.Errorf("unrecognized batch operation type %d", step.Type)
+}
+
+// Perform a sequence of update operations in order. Before each step, enough
+// of the prior state of the affected entry is read to undo it. If a step fails,
+// no further steps are attempted, and compensating operations are issued for
+// the steps that succeeded, in reverse order:
+//
+//   - An added entry is removed.
+//   - A removed entry is added back with the user attributes it had.
+//   - A modified entry has the attributes it modified restored.
+//   - A renamed or moved entry is renamed or moved back.
+//
+// This is not a transaction: other clients can observe the intermediate
+// states, and operational attributes of removed entries are not restored.
+//
+// The report describes every step. The returned error is nil if every step
+// succeeded, and otherwise describes the failed step and any failed
+// compensations.
+func RunBatch(ctx context.Context, client DirectoryAccessClient, steps []BatchStep) (*BatchReport, error) {
+	report := &BatchReport{
+		Steps:      make([]BatchStepReport, len(steps)),
+		FailedStep: -1,
+	}
+	for i, step := range steps {
+		report.Steps[i].Step = step
+	}
+	for i, step := range steps {
+		stepReport := &report.Steps[i]
+		stepReport.Attempted = true
+		prior, err := recordPriorState(ctx, client, step)
+		if err != nil {
+			stepReport.Err = fmt.Errorf("could not record state prior to %s: %w", step.Type, err)
+			report.FailedStep = i
+			break
+		}
+		stepReport.priorAttrs = prior
+		stepReport.Outcome, stepReport.Err = performStep(ctx, client, step)
+		if !stepReport.succeeded() {
+			report.FailedStep = i
+			break
+		}
+	}
+	if report.FailedStep < 0 {
+		return report, nil
+	}
+	failed := &report.Steps[report.FailedStep]
+	errs := []error{fmt.Errorf("batch step %d (%s) failed: %w", report.FailedStep, failed.Step.Type, outcomeError(failed.Outcome, failed.Err))}
+	report.RolledBack = true
+	for i := report.FailedStep - 1; i >= 0; i-- {
+		stepReport := &report.Steps[i]
+		stepReport.Compensated = true
+		stepReport.CompensationOutcome, stepReport.CompensationErr = compensate(ctx, client, stepReport)
+		if !stepReport.compensationSucceeded() {
+			report.RolledBack = false
+			errs = append(errs, fmt.Errorf("compensation for batch step %d (%s) failed: %w", i, stepReport.Step.Type, outcomeError(stepReport.CompensationOutcome, stepReport.CompensationErr)))
+		}
+	}
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,475 @@
+package x500_dap_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/Wildboar-Software/x500-go/x500"
+)
+
+// An in-memory DSA holding leaf entries, for testing code written against
+// [DirectoryAccessClient]. Operations it does not implement panic.
+type fakeDirectory struct {
+	DirectoryAccessClient
+
+	entries map[string][]x500.Attribute
+
+	// The number of the update operation that fails, counting from one, or
+	// zero if none fail.
+	failUpdate int
+	updates    int
+
+	modifyDNArgs []x500.ModifyDNArgumentData
+	selections   []x500.EntryInformationSelection
+}
+
+func newFakeDirectory() *fakeDirectory {
+	return &fakeDirectory{entries: make(map[string][]x500.Attribute)}
+}
+
+// Entries are keyed by the hexadecimal encoding of their names as sent, so
+// the names used by a test must use a single string type.
+func fakeKey(dn DN) string {
+	encoded, err := asn1.Marshal(dn)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(encoded)
+}
+
+func mustMarshal(t *testing.T, v any, params string) asn1.RawValue {
+	t.Helper()
+	encoded, err := asn1.MarshalWithParams(v, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value asn1.RawValue
+	if _, err = asn1.Unmarshal(encoded, &value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func (d *fakeDirectory) add(dn DN, attrs ...x500.Attribute) {
+	d.entries[fakeKey(dn)] = attrs
+}
+
+var fakeResult = X500OpOutcome{OutcomeType: OP_OUTCOME_RESULT}
+var fakeError = X500OpOutcome{OutcomeType: OP_OUTCOME_ERROR}
+
+func fakeObjectName(name x500.Name) (string, error) {
+	if name.Class != asn1.ClassContextSpecific || name.Tag != 0 {
+		return "", errors.New("name is not an rdnSequence")
+	}
+	return hex.EncodeToString(name.Bytes), nil
+}
+
+func (d *fakeDirectory) update() bool {
+	d.updates++
+	return d.updates != d.failUpdate
+}
+
+func (d *fakeDirectory) Read(ctx context.Context, arg x500.ReadArgumentData) (X500OpOutcome, *x500.ReadResultData, error) {
+	key, err := fakeObjectName(arg.Object)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	d.selections = append(d.selections, arg.Selection)
+	attrs, exists := d.entries[key]
+	if !exists {
+		return fakeError, nil, nil
+	}
+	info, err := fakeEntryInformation(arg.Object, attrs, arg.Selection)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	return fakeResult, &x500.ReadResultData{Entry: info}, nil
+}
+
+// Returns the attributes selected by `selection`. Like a real DSA, contexts
+// are only returned if they are requested.
+func fakeEntryInformation(name x500.Name, attrs []x500.Attribute, selection x500.EntryInformationSelection) (info x500.EntryInformation, err error) {
+	info.Name = name
+	types := append(append([]x500.AttributeType{}, selection.SelectSET...), selection.SelectOperationalAttributesSET...)
+	for _, attr := range attrs {
+		selected := len(types) == 0
+		for _, t := range types {
+			selected = selected || t.Equal(attr.Type)
+		}
+		if !selected {
+			continue
+		}
+		if !selection.ReturnContexts && len(attr.ValuesWithContext) > 0 {
+			values := append([]asn1.RawValue{}, attr.Values...)
+			for _, vwc := range attr.ValuesWithContext {
+				values = append(values, vwc.Value)
+			}
+			attr = x500.Attribute{Type: attr.Type, Values: values}
+		}
+		encoded, err := asn1.Marshal(attr)
+		if err != nil {
+			return info, err
+		}
+		var item asn1.RawValue
+		if _, err := asn1.Unmarshal(encoded, &item); err != nil {
+			return info, err
+		}
+		info.Information = append(info.Information, item)
+	}
+	return info, nil
+}
+
+func (d *fakeDirectory) AddEntry(ctx context.Context, arg x500.AddEntryArgumentData) (X500OpOutcome, *x500.AddEntryResultData, error) {
+	key, err := fakeObjectName(arg.Object)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	if _, exists := d.entries[key]; exists || !d.update() {
+		return fakeError, nil, nil
+	}
+	d.entries[key] = arg.Entry
+	return fakeResult, nil, nil
+}
+
+func (d *fakeDirectory) RemoveEntry(ctx context.Context, arg x500.RemoveEntryArgumentData) (X500OpOutcome, *x500.RemoveEntryResultData, error) {
+	key, err := fakeObjectName(arg.Object)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	if _, exists := d.entries[key]; !exists || !d.update() {
+		return fakeError, nil, nil
+	}
+	delete(d.entries, key)
+	return fakeResult, nil, nil
+}
+
+func fakeRemoveAttribute(attrs []x500.Attribute, attrType x500.AttributeType) []x500.Attribute {
+	ret := make([]x500.Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		if !attr.Type.Equal(attrType) {
+			ret = append(ret, attr)
+		}
+	}
+	return ret
+}
+
+// Add or remove values of an attribute, removing the attribute if it has no
+// values left. Like a real DSA, values are compared using the equality
+// matching rules of their types.
+func fakeChangeValues(attrs []x500.Attribute, change x500.Attribute, remove bool) []x500.Attribute {
+	eq := x500.DefaultMatchingRuleRegistry().ValueEqual(nil)
+	ret := make([]x500.Attribute, 0, len(attrs)+1)
+	found := false
+	for _, attr := range attrs {
+		if !attr.Type.Equal(change.Type) {
+			ret = append(ret, attr)
+			continue
+		}
+		found = true
+		values := make([]asn1.RawValue, 0, len(attr.Values))
+		for _, v := range attr.Values {
+			present := false
+			for _, c := range change.Values {
+				present = present || eq(attr.Type, c, v)
+			}
+			if !present || !remove {
+				values = append(values, v)
+			}
+		}
+		if !remove {
+			for _, c := range change.Values {
+				present := false
+				for _, v := range attr.Values {
+					present = present || eq(attr.Type, c, v)
+				}
+				if !present {
+					values = append(values, c)
+				}
+			}
+		}
+		if len(values) > 0 {
+			ret = append(ret, x500.Attribute{Type: attr.Type, Values: values})
+		}
+	}
+	if !found && !remove {
+		ret = append(ret, change)
+	}
+	return ret
+}
+
+func (d *fakeDirectory) ModifyEntry(ctx context.Context, arg x500.ModifyEntryArgumentData) (X500OpOutcome, *x500.ModifyEntryResultData, error) {
+	key, err := fakeObjectName(arg.Object)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	attrs, exists := d.entries[key]
+	if !exists || !d.update() {
+		return fakeError, nil, nil
+	}
+	for _, mod := range arg.Changes {
+		if mod.Tag == x500.EntryModification_RemoveAttribute {
+			var attrType x500.AttributeType
+			if _, err := asn1.Unmarshal(mod.Bytes, &attrType); err != nil {
+				return X500OpOutcome{}, nil, err
+			}
+			attrs = fakeRemoveAttribute(attrs, attrType)
+			continue
+		}
+		var attr x500.Attribute
+		if _, err := asn1.Unmarshal(mod.Bytes, &attr); err != nil {
+			return X500OpOutcome{}, nil, err
+		}
+		switch mod.Tag {
+		case x500.EntryModification_AddAttribute, x500.EntryModification_AddValues:
+			attrs = fakeChangeValues(attrs, attr, false)
+		case x500.EntryModification_RemoveValues:
+			attrs = fakeChangeValues(attrs, attr, true)
+		case x500.EntryModification_ReplaceValues:
+			attrs = fakeChangeValues(fakeRemoveAttribute(attrs, attr.Type), attr, false)
+		default:
+			return fakeError, nil, nil
+		}
+	}
+	d.entries[key] = attrs
+	return fakeResult, nil, nil
+}
+
+func rdnAttributes(rdn x500.RelativeDistinguishedName) ([]x500.Attribute, error) {
+	attrs := make([]x500.Attribute, 0, len(rdn))
+	for _, atav := range rdn {
+		encoded, err := asn1.Marshal(atav.Value)
+		if err != nil {
+			return nil, err
+		}
+		var value asn1.RawValue
+		if _, err = asn1.Unmarshal(encoded, &value); err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, x500.Attribute{Type: atav.Type, Values: []asn1.RawValue{value}})
+	}
+	return attrs, nil
+}
+
+func (d *fakeDirectory) ModifyDN(ctx context.Context, arg x500.ModifyDNArgumentData) (X500OpOutcome, *x500.ModifyDNResultData, error) {
+	d.modifyDNArgs = append(d.modifyDNArgs, arg)
+	attrs, exists := d.entries[fakeKey(arg.Object)]
+	if !exists || len(arg.Object) == 0 || !d.update() {
+		return fakeError, nil, nil
+	}
+	parent := arg.Object[:len(arg.Object)-1]
+	if arg.NewSuperior != nil {
+		parent = arg.NewSuperior
+	}
+	newDN := append(append(DN{}, parent...), arg.NewRDN)
+	if _, exists := d.entries[fakeKey(newDN)]; exists {
+		return fakeError, nil, nil
+	}
+	oldRDNAttrs, err := rdnAttributes(arg.Object[len(arg.Object)-1])
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	newRDNAttrs, err := rdnAttributes(arg.NewRDN)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	if arg.DeleteOldRDN {
+		for _, attr := range oldRDNAttrs {
+			attrs = fakeChangeValues(attrs, attr, true)
+		}
+	}
+	for _, attr := range newRDNAttrs {
+		attrs = fakeChangeValues(attrs, attr, false)
+	}
+	delete(d.entries, fakeKey(arg.Object))
+	d.entries[fakeKey(newDN)] = attrs
+	return fakeResult, nil, nil
+}
+
+// A canonical description of the directory contents, for comparison.
+func (d *fakeDirectory) snapshot(t *testing.T) []string {
+	ret := make([]string, 0, len(d.entries))
+	for key, attrs := range d.entries {
+		for _, attr := range attrs {
+			values := make([]string, 0, len(attr.Values))
+			for _, v := range attr.Values {
+				values = append(values, string(mustMarshal(t, v, "").FullBytes))
+			}
+			sort.Strings(values)
+			for _, v := range values {
+				ret = append(ret, key+" "+attr.Type.String()+" "+v)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func expectSnapshot(t *testing.T, d *fakeDirectory, expected []string) {
+	t.Helper()
+	after := d.snapshot(t)
+	if len(after) != len(expected) {
+		t.Errorf("expected the directory to be restored to %v, but got %v", expected, after)
+		return
+	}
+	for i := range expected {
+		if expected[i] != after[i] {
+			t.Errorf("expected the directory to be restored to %v, but got %v", expected, after)
+			return
+		}
+	}
+}
+
+func testRDN(attrType x500.AttributeType, value string) x500.RelativeDistinguishedName {
+	utf8 := asn1.RawValue{Tag: asn1.TagUTF8String, Bytes: []byte(value)}
+	return x500.RelativeDistinguishedName{{Type: attrType, Value: utf8}}
+}
+
+func testAttr(t *testing.T, attrType x500.AttributeType, values ...string) x500.Attribute {
+	attr := x500.Attribute{Type: attrType}
+	for _, v := range values {
+		attr.Values = append(attr.Values, mustMarshal(t, v, "utf8"))
+	}
+	return attr
+}
+
+func mustModification(t *testing.T, tag int, arg any) x500.EntryModification {
+	mod, err := x500.NewEntryModification(tag, arg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mod
+}
+
+func TestRunBatchRollback(t *testing.T) {
+	example := DN{testRDN(x500.Id_at_organizationName, "Example")}
+	child := func(rdn x500.RelativeDistinguishedName) DN {
+		return append(append(DN{}, example...), rdn)
+	}
+	alice := child(testRDN(x500.Id_at_commonName, "Alice"))
+	bob := child(testRDN(x500.Id_at_commonName, "Bob"))
+	d := newFakeDirectory()
+	d.add(example, testAttr(t, x500.Id_at_organizationName, "Example"))
+	d.add(alice, testAttr(t, x500.Id_at_commonName, "Alice"), testAttr(t, x500.Id_at_surname, "Smith"))
+	d.add(bob, testAttr(t, x500.Id_at_commonName, "Bob"), testAttr(t, x500.Id_at_description, "Builder"))
+	before := d.snapshot(t)
+
+	steps := []BatchStep{
+		AddEntryStep(child(testRDN(x500.Id_at_commonName, "Carol")), []x500.Attribute{testAttr(t, x500.Id_at_commonName, "Carol")}),
+		RemoveEntryStep(bob),
+		ModifyEntryStep(alice,
+			mustModification(t, x500.EntryModification_ReplaceValues, testAttr(t, x500.Id_at_surname, "Jones")),
+			mustModification(t, x500.EntryModification_AddAttribute, testAttr(t, x500.Id_at_description, "Engineer")),
+		),
+		ModifyDNStep(alice, testRDN(x500.Id_at_commonName, "Alicia"), true, nil),
+		// Fails, because the entry already exists.
+		AddEntryStep(example, nil),
+	}
+	report, err := RunBatch(context.Background(), d, steps)
+	if err == nil {
+		t.Error("expected the batch to fail")
+		return
+	}
+	if report.FailedStep != 4 || !report.RolledBack {
+		t.Errorf("expected step 4 to fail and the batch to be rolled back, but got %+v", report)
+		return
+	}
+	for i, step := range report.Steps[:4] {
+		if !step.Attempted || !step.succeeded() || !step.Compensated || !step.compensationSucceeded() {
+			t.Errorf("step %d was not performed and compensated: %+v", i, step)
+		}
+	}
+	expectSnapshot(t, d, before)
+
+	// The steps after a failed step are not attempted.
+	d.failUpdate = d.updates + 1
+	report, err = RunBatch(context.Background(), d, steps[1:3])
+	if err == nil || report.FailedStep != 0 || report.Steps[1].Attempted || report.Steps[0].Compensated {
+		t.Errorf("unexpected report for a failed first step: %+v (%v)", report, err)
+	}
+}
+
+func TestRunBatchCompensationFailure(t *testing.T) {
+	example := DN{testRDN(x500.Id_at_organizationName, "Example")}
+	carol := append(append(DN{}, example...), testRDN(x500.Id_at_commonName, "Carol"))
+	d := newFakeDirectory()
+	d.add(example, testAttr(t, x500.Id_at_organizationName, "Example"))
+	// The removal that compensates for adding Carol fails.
+	d.failUpdate = 2
+	report, err := RunBatch(context.Background(), d, []BatchStep{
+		AddEntryStep(carol, []x500.Attribute{testAttr(t, x500.Id_at_commonName, "Carol")}),
+		RemoveEntryStep(DN{testRDN(x500.Id_at_organizationName, "Nonexistent")}),
+	})
+	if err == nil || report.FailedStep != 1 || report.RolledBack {
+		t.Errorf("expected step 1 to fail without being rolled back, but got %+v (%v)", report, err)
+		return
+	}
+	if !report.Steps[0].Compensated || report.Steps[0].compensationSucceeded() {
+		t.Errorf("expected the compensation to fail, but got %+v", report.Steps[0])
+		return
+	}
+	if _, exists := d.entries[fakeKey(carol)]; !exists {
+		t.Error("expected Carol to remain")
+	}
+}
+
+func TestRunBatchModifyDNOutOfRoot(t *testing.T) {
+	us := DN{testRDN(x500.Id_at_countryName, "US")}
+	example := DN{testRDN(x500.Id_at_organizationName, "Example")}
+	d := newFakeDirectory()
+	d.add(us, testAttr(t, x500.Id_at_countryName, "US"))
+	d.add(example, testAttr(t, x500.Id_at_organizationName, "Example"))
+	before := d.snapshot(t)
+	report, err := RunBatch(context.Background(), d, []BatchStep{
+		ModifyDNStep(example, testRDN(x500.Id_at_organizationName, "Example"), false, us),
+		RemoveEntryStep(DN{testRDN(x500.Id_at_organizationName, "Nonexistent")}),
+	})
+	if err == nil || !report.RolledBack {
+		t.Errorf("expected the batch to be rolled back, but got %+v (%v)", report, err)
+		return
+	}
+	expectSnapshot(t, d, before)
+	if len(d.modifyDNArgs) != 2 {
+		t.Errorf("expected two modifyDN operations, but got %d", len(d.modifyDNArgs))
+		return
+	}
+	reversal := d.modifyDNArgs[1]
+	if reversal.NewSuperior == nil || len(reversal.NewSuperior) != 0 {
+		t.Errorf("expected the root as the new superior, but got %v", reversal.NewSuperior)
+		return
+	}
+	encoded, err := asn1.Marshal(reversal)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Contains(encoded, []byte{0xA3, 0x02, 0x30, 0x00}) {
+		t.Errorf("expected an empty newSuperior to be encoded, but got %x", encoded)
+	}
+}
+
+func TestRdnValuesPresent(t *testing.T) {
+	rdn := x500.RelativeDistinguishedName{
+		{Type: x500.Id_at_commonName, Value: "ALICE"},
+		{Type: x500.Id_at_surname, Value: "smith"},
+	}
+	attrs := []x500.Attribute{
+		testAttr(t, x500.Id_at_commonName, "Bob", "alice"),
+		testAttr(t, x500.Id_at_surname, "Smith"),
+	}
+	// Equal under caseIgnoreMatch, despite the different string types.
+	if !rdnValuesPresent(rdn, attrs) {
+		t.Error("expected the RDN values to be present")
+	}
+	if rdnValuesPresent(rdn, attrs[:1]) {
+		t.Error("expected the surname to be missing")
+	}
+	other := x500.RelativeDistinguishedName{{Type: x500.Id_at_commonName, Value: "Carol"}}
+	if rdnValuesPresent(other, attrs) {
+		t.Error("expected Carol to be missing")
+	}
+}
@@ -0,0 +1,228 @@
+package x500_dap_client
+
+import (
+	"context"
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/Wildboar-Software/x500-go/x500"
+)
+
+// The features of a DSA, as advertised by the operational attributes of its
+// root DSE.
+type Capabilities struct {
+	// Values of `supportedExtension`: LDAP extended operations.
+	SupportedExtensions []asn1.ObjectIdentifier
+
+	// Values of `supportedControl`: LDAP controls.
+	SupportedControls []asn1.ObjectIdentifier
+
+	// Values of `supportedFeatures`: LDAP features.
+	SupportedFeatures []asn1.ObjectIdentifier
+
+	// Values of `supportedSASLMechanisms`.
+	SupportedSASLMechanisms []string
+
+	// Values of `namingContexts`: the names of the entries at the top of
+	// the naming contexts held by the DSA.
+	NamingContexts []DN
+
+	// Values of `altServer`: URIs of other servers that may be used when this
+	// one is unavailable.
+	AltServers []string
+}
+
+// Returns true if `oid` is among the supported extensions, controls or
+// features.
+func (c *Capabilities) Advertises(oid asn1.ObjectIdentifier) bool {
+	for _, list := range [][]asn1.ObjectIdentifier{c.SupportedExtensions, c.SupportedControls, c.SupportedFeatures} {
+		for _, advertised := range list {
+			if advertised.Equal(oid) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns true if `mechanism` is among the supported SASL mechanisms.
+func (c *Capabilities) SupportsSASLMechanism(mechanism string) bool {
+	for _, m := range c.SupportedSASLMechanisms {
+		if m == mechanism {
+			return true
+		}
+	}
+	return false
+}
+
+// The object identifiers which, if advertised in the root DSE, indicate that a
+// DSA supports an X.500 critical extension, indexed by the CRIT_EXT_BIT_*
+// number of the extension. There is no standard way for a DSA to advertise
+// X.500 critical extensions, so these are the LDAP controls that request the
+// same features. Modify this if your DSA advertises its extensions some other
+// way.
+var CriticalExtensionIndicators = map[int][]asn1.ObjectIdentifier{
+	// ManageDsaIT control (IETF RFC 3296)
+	CRIT_EXT_BIT_MANAGE_DSA_IT: {{2, 16, 840, 1, 113730, 3, 4, 2}},
+	// Subentries control (IETF RFC 3672)
+	CRIT_EXT_BIT_SUBENTRIES: {{1, 3, 6, 1, 4, 1, 4203, 1, 10, 1}},
+	// Simple paged results control (IETF RFC 2696)
+	CRIT_EXT_BIT_PAGED_RESULTS_REQUEST: {{1, 2, 840, 113556, 1, 4, 319}},
+	// Matched values control (IETF RFC 3876)
+	CRIT_EXT_BIT_MATCHED_VALUES_ONLY: {{1, 2, 826, 0, 1, 3344810, 2, 3}},
+}
+
+// Critical extensions that this library marks as critical on its own, rather
+// than because the caller asked for a feature. If the DSA does not advertise
+// them, they are sent as non-critical instead.
+var downgradableCriticalExtensions = map[int]bool{
+	CRIT_EXT_BIT_ATTRIBUTE_SIZE_LIMIT: true,
+}
+
+// Report whether the DSA supports the critical extension numbered `bit`.
+// `known` is false if there is no way to tell from the root DSE; see
+// [CriticalExtensionIndicators].
+func (c *Capabilities) SupportsCriticalExtension(bit int) (supported bool, known bool) {
+	indicators := CriticalExtensionIndicators[bit]
+	if len(indicators) == 0 {
+		return false, false
+	}
+	for _, oid := range indicators {
+		if c.Advertises(oid) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+func decodeCapabilityValues[T any](attr *x500.Attribute, decode func(asn1.RawValue) (T, error)) ([]T, error) {
+	ret := make([]T, 0, attr.Len())
+	for i := 0; i < attr.Len(); i++ {
+		v, err := decode(*attr.Get(i))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %w", attr.Type, err)
+		}
+		ret = append(ret, v)
+	}
+	return ret, nil
+}
+
+func unmarshalValue[T any](v asn1.RawValue) (ret T, err error) {
+	rest, err := asn1.Unmarshal(v.FullBytes, &ret)
+	if err == nil && len(rest) > 0 {
+		err = fmt.Errorf("trailing bytes")
+	}
+	return ret, err
+}
+
+// Build [Capabilities] from the operational attributes of a root DSE.
+// Attributes of other types are ignored.
+func CapabilitiesFromAttributes(attrs []x500.Attribute) (*Capabilities, error) {
+	caps := &Capabilities{}
+	for i := range attrs {
+		attr := &attrs[i]
+		var err error
+		switch {
+		case attr.Type.Equal(x500.Id_lat_supportedExtension):
+			caps.SupportedExtensions, err = decodeCapabilityValues(attr, unmarshalValue[asn1.ObjectIdentifier])
+		case attr.Type.Equal(x500.Id_lat_supportedControl):
+			caps.SupportedControls, err = decodeCapabilityValues(attr, unmarshalValue[asn1.ObjectIdentifier])
+		case attr.Type.Equal(x500.Id_oat_supportedFeatures):
+			caps.SupportedFeatures, err = decodeCapabilityValues(attr, unmarshalValue[asn1.ObjectIdentifier])
+		case attr.Type.Equal(x500.Id_lat_supportedSASLMechanisms):
+			caps.SupportedSASLMechanisms, err = decodeCapabilityValues(attr, x500.DirectoryStringToString)
+		case attr.Type.Equal(x500.Id_lat_namingContexts):
+			caps.NamingContexts, err = decodeCapabilityValues(attr, unmarshalValue[DN])
+		case attr.Type.Equal(x500.Id_lat_altServer):
+			caps.AltServers, err = decodeCapabilityValues(attr, unmarshalValue[string])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return caps, nil
+}
+
+// The root DSE attributes read by [ReadCapabilities].
+var capabilityAttributeTypes = []x500.AttributeType{
+	x500.Id_lat_supportedExtension,
+	x500.Id_lat_supportedControl,
+	x500.Id_oat_supportedFeatures,
+	x500.Id_lat_supportedSASLMechanisms,
+	x500.Id_lat_namingContexts,
+	x500.Id_lat_altServer,
+}
+
+// Read the root DSE of the DSA and return the capabilities it advertises. If
+// the read does not produce a result, its outcome is returned with nil
+// capabilities.
+func ReadCapabilities(ctx context.Context, client DirectoryAccessClient) (X500OpOutcome, *Capabilities, error) {
+	outcome, attrs, err := readOperationalAttributes(ctx, client, DN{}, capabilityAttributeTypes)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_RESULT {
+		return outcome, nil, err
+	}
+	caps, err := CapabilitiesFromAttributes(attrs)
+	return outcome, caps, err
+}
+
+// Read the capabilities of the DSA from its root DSE and store them in the
+// stack, so that subsequent operations only mark as critical the extensions
+// the DSA advertises. See [ReadCapabilities].
+func (stack *IDMProtocolStack) DiscoverCapabilities(ctx context.Context) (X500OpOutcome, *Capabilities, error) {
+	outcome, caps, err := ReadCapabilities(ctx, stack)
+	if caps != nil {
+		stack.SetCapabilities(caps)
+	}
+	return outcome, caps, err
+}
+
+// Return the capabilities of the DSA, as stored by
+// [IDMProtocolStack.DiscoverCapabilities] or [IDMProtocolStack.SetCapabilities],
+// or nil if they are not known.
+func (stack *IDMProtocolStack) Capabilities() *Capabilities {
+	stack.mutex.Lock()
+	defer stack.mutex.Unlock()
+	return stack.capabilities
+}
+
+// Store the capabilities of the DSA, which must not be modified afterwards.
+// If `caps` is nil, critical extensions are no longer negotiated.
+func (stack *IDMProtocolStack) SetCapabilities(caps *Capabilities) {
+	stack.mutex.Lock()
+	defer stack.mutex.Unlock()
+	stack.capabilities = caps
+}
+
+// Remove trailing zero bits, as required by DER for named bit lists.
+func trimBitString(bs *asn1.BitString) {
+	for bs.BitLength > 0 && bs.At(bs.BitLength-1) == 0 {
+		bs.BitLength--
+	}
+	bs.Bytes = bs.Bytes[:(bs.BitLength+7)/8]
+}
+
+// If the capabilities of the DSA are known, clear the critical extension bits
+// that the DSA is known not to support and those this library set on its own
+// that the DSA does not advertise, so that they are sent as non-critical.
+// Whether the DSA actually supports them is left for the DSA to decide, since
+// support can only be inferred from the root DSE. Extensions for which support
+// cannot be determined are left as they are.
+func (stack *IDMProtocolStack) negotiateCriticalExtensions(critex *asn1.BitString) {
+	caps := stack.Capabilities()
+	if caps == nil {
+		return
+	}
+	for bit := 1; bit <= critex.BitLength; bit++ {
+		if critex.At(bit-1) == 0 {
+			continue
+		}
+		supported, known := caps.SupportsCriticalExtension(bit)
+		if supported {
+			continue
+		}
+		if known || downgradableCriticalExtensions[bit] {
+			setBit(critex, bit-1, false)
+		}
+	}
+	trimBitString(critex)
+}
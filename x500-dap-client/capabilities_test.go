@@ -0,0 +1,184 @@
+package x500_dap_client
+
+import (
+	"encoding/asn1"
+	"sync"
+	"testing"
+
+	"github.com/Wildboar-Software/x500-go/x500"
+)
+
+func testRootDSE(t *testing.T) []x500.Attribute {
+	manageDsaIT := CriticalExtensionIndicators[CRIT_EXT_BIT_MANAGE_DSA_IT][0]
+	pagedResults := CriticalExtensionIndicators[CRIT_EXT_BIT_PAGED_RESULTS_REQUEST][0]
+	startTLS := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1466, 20037}
+	allOpAttrs := asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 4203, 1, 5, 1}
+	example := DN{{{Type: x500.Id_at_organizationName, Value: "Example"}}}
+	return []x500.Attribute{
+		{Type: x500.Id_lat_supportedExtension, Values: []asn1.RawValue{mustMarshal(t, startTLS, "")}},
+		{Type: x500.Id_lat_supportedControl, Values: []asn1.RawValue{
+			mustMarshal(t, manageDsaIT, ""),
+			mustMarshal(t, pagedResults, ""),
+		}},
+		{Type: x500.Id_oat_supportedFeatures, Values: []asn1.RawValue{mustMarshal(t, allOpAttrs, "")}},
+		{Type: x500.Id_lat_supportedSASLMechanisms, Values: []asn1.RawValue{
+			mustMarshal(t, "EXTERNAL", "utf8"),
+			mustMarshal(t, "SCRAM-SHA-256", "printable"),
+		}},
+		{Type: x500.Id_lat_namingContexts, Values: []asn1.RawValue{mustMarshal(t, example, "")}},
+		{Type: x500.Id_lat_altServer, Values: []asn1.RawValue{mustMarshal(t, "ldap://backup.example.com", "ia5")}},
+		// Other attributes are ignored.
+		{Type: x500.Id_at_commonName, Values: []asn1.RawValue{mustMarshal(t, 5, "")}},
+	}
+}
+
+func TestCapabilitiesFromAttributes(t *testing.T) {
+	caps, err := CapabilitiesFromAttributes(testRootDSE(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(caps.SupportedExtensions) != 1 || len(caps.SupportedControls) != 2 || len(caps.SupportedFeatures) != 1 {
+		t.Errorf("unexpected extensions, controls, or features: %+v", caps)
+		return
+	}
+	if !caps.Advertises(CriticalExtensionIndicators[CRIT_EXT_BIT_MANAGE_DSA_IT][0]) ||
+		caps.Advertises(CriticalExtensionIndicators[CRIT_EXT_BIT_SUBENTRIES][0]) {
+		t.Errorf("unexpected advertised controls: %v", caps.SupportedControls)
+		return
+	}
+	if !caps.SupportsSASLMechanism("SCRAM-SHA-256") || caps.SupportsSASLMechanism("PLAIN") {
+		t.Errorf("unexpected SASL mechanisms: %v", caps.SupportedSASLMechanisms)
+		return
+	}
+	if len(caps.NamingContexts) != 1 || len(caps.NamingContexts[0]) != 1 ||
+		!caps.NamingContexts[0][0][0].Type.Equal(x500.Id_at_organizationName) {
+		t.Errorf("unexpected naming contexts: %v", caps.NamingContexts)
+		return
+	}
+	if len(caps.AltServers) != 1 || caps.AltServers[0] != "ldap://backup.example.com" {
+		t.Errorf("unexpected alternate servers: %v", caps.AltServers)
+		return
+	}
+
+	invalid := []x500.Attribute{
+		{Type: x500.Id_lat_supportedControl, Values: []asn1.RawValue{mustMarshal(t, "not an oid", "utf8")}},
+	}
+	if _, err := CapabilitiesFromAttributes(invalid); err == nil {
+		t.Error("expected an invalid supportedControl value to be rejected")
+	}
+}
+
+func TestSupportsCriticalExtension(t *testing.T) {
+	caps, err := CapabilitiesFromAttributes(testRootDSE(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	cases := []struct {
+		bit       int
+		supported bool
+		known     bool
+	}{
+		{CRIT_EXT_BIT_MANAGE_DSA_IT, true, true},
+		{CRIT_EXT_BIT_PAGED_RESULTS_REQUEST, true, true},
+		{CRIT_EXT_BIT_SUBENTRIES, false, true},
+		{CRIT_EXT_BIT_RELAXATION, false, false},
+	}
+	for _, c := range cases {
+		supported, known := caps.SupportsCriticalExtension(c.bit)
+		if supported != c.supported || known != c.known {
+			t.Errorf("bit %d: expected %t (known: %t), but got %t (known: %t)", c.bit, c.supported, c.known, supported, known)
+		}
+	}
+}
+
+func TestNegotiateCriticalExtensions(t *testing.T) {
+	caps, err := CapabilitiesFromAttributes(testRootDSE(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	requested := func(bits ...int) asn1.BitString {
+		var critex asn1.BitString
+		for _, bit := range bits {
+			setCritExtBit(&critex, bit)
+		}
+		return critex
+	}
+	cases := []struct {
+		name      string
+		caps      *Capabilities
+		requested asn1.BitString
+		expected  asn1.BitString
+	}{
+		{
+			name:      "unknown capabilities",
+			requested: requested(CRIT_EXT_BIT_SUBENTRIES, CRIT_EXT_BIT_ATTRIBUTE_SIZE_LIMIT),
+			expected:  requested(CRIT_EXT_BIT_SUBENTRIES, CRIT_EXT_BIT_ATTRIBUTE_SIZE_LIMIT),
+		},
+		{
+			name:      "advertised",
+			caps:      caps,
+			requested: requested(CRIT_EXT_BIT_MANAGE_DSA_IT, CRIT_EXT_BIT_PAGED_RESULTS_REQUEST),
+			expected:  requested(CRIT_EXT_BIT_MANAGE_DSA_IT, CRIT_EXT_BIT_PAGED_RESULTS_REQUEST),
+		},
+		{
+			name:      "set by the library and not advertised",
+			caps:      caps,
+			requested: requested(CRIT_EXT_BIT_MANAGE_DSA_IT, CRIT_EXT_BIT_ATTRIBUTE_SIZE_LIMIT),
+			expected:  requested(CRIT_EXT_BIT_MANAGE_DSA_IT),
+		},
+		{
+			// Downgraded rather than refused, so that the DSA decides.
+			name:      "known not to be supported",
+			caps:      caps,
+			requested: requested(CRIT_EXT_BIT_SUBENTRIES, CRIT_EXT_BIT_MANAGE_DSA_IT),
+			expected:  requested(CRIT_EXT_BIT_MANAGE_DSA_IT),
+		},
+		{
+			name:      "support unknown",
+			caps:      caps,
+			requested: requested(CRIT_EXT_BIT_RELAXATION),
+			expected:  requested(CRIT_EXT_BIT_RELAXATION),
+		},
+		{
+			name:      "all downgraded",
+			caps:      &Capabilities{},
+			requested: requested(CRIT_EXT_BIT_SUBENTRIES, CRIT_EXT_BIT_ATTRIBUTE_SIZE_LIMIT),
+			expected:  asn1.BitString{Bytes: []byte{}},
+		},
+	}
+	for _, c := range cases {
+		stack := &IDMProtocolStack{}
+		stack.SetCapabilities(c.caps)
+		critex := c.requested
+		stack.negotiateCriticalExtensions(&critex)
+		if critex.BitLength != c.expected.BitLength || string(critex.Bytes) != string(c.expected.Bytes) {
+			t.Errorf("%s: expected %x (%d bits), but got %x (%d bits)", c.name,
+				c.expected.Bytes, c.expected.BitLength, critex.Bytes, critex.BitLength)
+		}
+	}
+}
+
+func TestCapabilitiesConcurrentAccess(t *testing.T) {
+	stack := &IDMProtocolStack{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			stack.SetCapabilities(&Capabilities{})
+		}()
+		go func() {
+			defer wg.Done()
+			critex := asn1.BitString{}
+			setCritExtBit(&critex, CRIT_EXT_BIT_ATTRIBUTE_SIZE_LIMIT)
+			stack.negotiateCriticalExtensions(&critex)
+		}()
+	}
+	wg.Wait()
+	if stack.Capabilities() == nil {
+		t.Error("expected the capabilities to be stored")
+	}
+}
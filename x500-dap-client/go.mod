@@ -2,9 +2,19 @@ module github.com/Wildboar-Software/x500-go/x500-dap-client
 
 go 1.23.4
 
-require github.com/Wildboar-Software/x500-go/x500 v1.0.5
+require (
+	github.com/Wildboar-Software/x500-go/nsap-address v0.0.0-00010101000000-000000000000
+	github.com/Wildboar-Software/x500-go/x500 v1.0.5
+)
 
 require (
 	github.com/Wildboar-Software/x500-go/teletex v1.0.0 // indirect
 	github.com/sosodev/duration v1.3.1 // indirect
+	golang.org/x/crypto v0.31.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
+
+replace (
+	github.com/Wildboar-Software/x500-go/nsap-address => ../nsap-address
+	github.com/Wildboar-Software/x500-go/x500 => ../x500
 )
@@ -170,6 +170,12 @@ type IDMProtocolStack struct {
 	// then do whatever you want with (usually logging).
 	// If you do not supply this, errors will be logged to the stderr console.
 	ErrorChannel chan error
+
+	// The capabilities advertised by the DSA's root DSE, populated by
+	// [IDMProtocolStack.DiscoverCapabilities]. If this is not nil, critical
+	// extensions that the DSA does not advertise are sent as non-critical.
+	// Guarded by `mutex`.
+	capabilities *Capabilities
 }
 
 // Configuration to create an [IDMProtocolStack].
@@ -1090,9 +1096,12 @@ func (stack *IDMProtocolStack) startTLS(ctx context.Context) (response StartTLSO
 	}
 	switch response.response {
 	case x500.TLSResponse_Success:
+		if _, is_tls := stack.socket.(*tls.Conn); is_tls {
+			return response, errors.New("tls already in use")
+		}
 		netconn, is_tcp := stack.socket.(net.Conn)
 		if !is_tcp {
-			return response, errors.New("tls already in use")
+			return response, errors.New("starttls requires a network connection")
 		}
 		if stack.TlsConfig == nil {
 			return response, errors.New("no tlsconfig defined: not performing x509 authentication of peer")
@@ -1116,8 +1125,8 @@ func (stack *IDMProtocolStack) startTLS(ctx context.Context) (response StartTLSO
 }
 
 func (stack *IDMProtocolStack) Bind(ctx context.Context, arg X500AssociateArgument) (response X500AssociateOutcome, err error) {
-	_, tls_not_in_use := stack.socket.(net.Conn)
-	if tls_not_in_use && stack.StartTLSPolicy != StartTLSNever {
+	_, tls_in_use := stack.socket.(*tls.Conn)
+	if !tls_in_use && stack.StartTLSPolicy != StartTLSNever {
 		_, err = stack.startTLS(ctx)
 		if err != nil && stack.StartTLSPolicy == StartTLSDemand {
 			return X500AssociateOutcome{}, err
@@ -1570,6 +1579,10 @@ func wrapWithTag(v asn1.RawValue, tag int) asn1.RawValue {
 	if v.Class == asn1.ClassContextSpecific && v.Tag == tag {
 		return v
 	}
+	return alwaysWrapWithTag(v, tag)
+}
+
+func alwaysWrapWithTag(v asn1.RawValue, tag int) asn1.RawValue {
 	innerBytes, err := asn1.Marshal(v)
 	if err != nil {
 		panic(err)
@@ -1703,6 +1716,7 @@ func (stack *IDMProtocolStack) Read(ctx context.Context, arg_data x500.ReadArgum
 	}
 	arg_data.CriticalExtensions = *setCommonArgsCritExtBits(&arg_data)
 	setEntryInfoSelectionCritExtBits(&arg_data.CriticalExtensions, &arg_data.Selection)
+	stack.negotiateCriticalExtensions(&arg_data.CriticalExtensions)
 	var arg_bytes []byte
 	if stack.SigningKey != nil && stack.SigningCert != nil {
 		sp, err := createSecurityParameters(
@@ -1796,6 +1810,7 @@ func (stack *IDMProtocolStack) Compare(ctx context.Context, arg_data x500.Compar
 	if assctx.Tag > 0 || len(assctx.FullBytes) > 0 {
 		setCritExtBit(&arg_data.CriticalExtensions, CRIT_EXT_BIT_USE_OF_CONTEXTS)
 	}
+	stack.negotiateCriticalExtensions(&arg_data.CriticalExtensions)
 	var arg_bytes []byte
 	if stack.SigningKey != nil && stack.SigningCert != nil {
 		sp, err := createSecurityParameters(
@@ -1947,6 +1962,7 @@ func (stack *IDMProtocolStack) List(ctx context.Context, arg_data x500.ListArgum
 		}
 	}
 	arg_data.CriticalExtensions = *setCommonArgsCritExtBits(&arg_data)
+	stack.negotiateCriticalExtensions(&arg_data.CriticalExtensions)
 	var arg_bytes []byte
 	if stack.SigningKey != nil && stack.SigningCert != nil {
 		sp, err := createSecurityParameters(
@@ -2042,11 +2058,13 @@ func (stack *IDMProtocolStack) Search(ctx context.Context, arg_data x500.SearchA
 	if arg_data.PagedResults.Tag != 0 {
 		arg_data.PagedResults = wrapWithTag(arg_data.PagedResults, 5)
 	}
-	if arg_data.Filter.Tag != 0 {
-		arg_data.Filter = wrapWithTag(arg_data.Filter, 2)
+	// The alternatives of Filter have context-specific tags of their own, so
+	// these cannot be checked for already having been wrapped.
+	if len(arg_data.Filter.FullBytes) > 0 || len(arg_data.Filter.Bytes) > 0 {
+		arg_data.Filter = alwaysWrapWithTag(arg_data.Filter, 2)
 	}
-	if arg_data.ExtendedFilter.Tag != 0 {
-		arg_data.ExtendedFilter = wrapWithTag(arg_data.ExtendedFilter, 7)
+	if len(arg_data.ExtendedFilter.FullBytes) > 0 || len(arg_data.ExtendedFilter.Bytes) > 0 {
+		arg_data.ExtendedFilter = alwaysWrapWithTag(arg_data.ExtendedFilter, 7)
 	}
 	configureServiceControls(ctx, &arg_data.ServiceControls)
 	if len(arg_data.PagedResults.Bytes) > 0 {
@@ -2089,6 +2107,7 @@ func (stack *IDMProtocolStack) Search(ctx context.Context, arg_data x500.SearchA
 	}
 	// Theoretically, we could check filters for contexts, but hell no.
 	arg_data.CriticalExtensions = *setCommonArgsCritExtBits(&arg_data)
+	stack.negotiateCriticalExtensions(&arg_data.CriticalExtensions)
 	var arg_bytes []byte
 	if stack.SigningKey != nil && stack.SigningCert != nil {
 		sp, err := createSecurityParameters(
@@ -2193,6 +2212,7 @@ func (stack *IDMProtocolStack) AddEntry(ctx context.Context, arg_data x500.AddEn
 		}
 	}
 	// useAliasOnUpdate is to be set by the user.
+	stack.negotiateCriticalExtensions(&arg_data.CriticalExtensions)
 	var arg_bytes []byte
 	if stack.SigningKey != nil && stack.SigningCert != nil {
 		sp, err := createSecurityParameters(
@@ -2256,6 +2276,7 @@ func (stack *IDMProtocolStack) RemoveEntry(ctx context.Context, arg_data x500.Re
 	}
 	configureServiceControls(ctx, &arg_data.ServiceControls)
 	arg_data.CriticalExtensions = *setCommonArgsCritExtBits(&arg_data)
+	stack.negotiateCriticalExtensions(&arg_data.CriticalExtensions)
 	var arg_bytes []byte
 	if stack.SigningKey != nil && stack.SigningCert != nil {
 		sp, err := createSecurityParameters(
@@ -2329,6 +2350,7 @@ func (stack *IDMProtocolStack) ModifyEntry(ctx context.Context, arg_data x500.Mo
 		}
 	}
 	arg_data.CriticalExtensions = *setCommonArgsCritExtBits(&arg_data)
+	stack.negotiateCriticalExtensions(&arg_data.CriticalExtensions)
 	var arg_bytes []byte
 	if stack.SigningKey != nil && stack.SigningCert != nil {
 		sp, err := createSecurityParameters(
@@ -2386,10 +2408,11 @@ func (stack *IDMProtocolStack) ModifyDN(ctx context.Context, arg_data x500.Modif
 		return X500OpOutcome{}, nil, err
 	}
 	configureServiceControls(ctx, &arg_data.ServiceControls)
-	if len(arg_data.NewSuperior) > 0 {
+	if arg_data.NewSuperior != nil {
 		setCritExtBit(&arg_data.CriticalExtensions, CRIT_EXT_BIT_NEW_SUPERIOR)
 	}
 	arg_data.CriticalExtensions = *setCommonArgsCritExtBits(&arg_data)
+	stack.negotiateCriticalExtensions(&arg_data.CriticalExtensions)
 	var arg_bytes []byte
 	if stack.SigningKey != nil && stack.SigningCert != nil {
 		sp, err := createSecurityParameters(
@@ -2981,3 +3004,26 @@ func (stack *IDMProtocolStack) ResetValue(ctx context.Context, dn DN, attr x500.
 func (stack *IDMProtocolStack) ReplaceValues(ctx context.Context, dn DN, attr x500.Attribute) (resp X500OpOutcome, result *x500.ModifyEntryResultData, err error) {
 	return singleModification(stack, ctx, dn, attr, 6)
 }
+
+// Modify the entry named by `dn` so that the attributes `oldAttrs` become
+// `newAttrs`, using the modifications computed by [x500.DiffAttributes]. If
+// there are no differences, no operation is performed and a nil result and an
+// outcome with a zero OutcomeType are returned.
+func (stack *IDMProtocolStack) ModifyAttributes(ctx context.Context, dn DN, oldAttrs []x500.Attribute, newAttrs []x500.Attribute, options *x500.DiffOptions) (resp X500OpOutcome, result *x500.ModifyEntryResultData, err error) {
+	changes, err := x500.DiffAttributes(oldAttrs, newAttrs, options)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	if len(changes) == 0 {
+		return X500OpOutcome{}, nil, nil
+	}
+	object, err := nameFromDN(dn)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	arg_data := x500.ModifyEntryArgumentData{
+		Object:  object,
+		Changes: changes,
+	}
+	return stack.ModifyEntry(ctx, arg_data)
+}
@@ -0,0 +1,171 @@
+package x500_dap_client
+
+import (
+	"context"
+	"encoding/asn1"
+	"errors"
+
+	"github.com/Wildboar-Software/x500-go/x500"
+)
+
+// An entry returned by [SearchInto], unmarshaled into a struct.
+type MappedEntry[T any] struct {
+	Name  DN
+	Value T
+}
+
+func nameFromDN(dn DN) (x500.Name, error) {
+	name_bytes, err := asn1.Marshal(dn)
+	if err != nil {
+		return x500.Name{}, err
+	}
+	return asn1.RawValue{
+		Tag:        0,
+		Class:      asn1.ClassContextSpecific,
+		IsCompound: true,
+		Bytes:      name_bytes,
+	}, nil
+}
+
+func selectionOf[T any]() (eis x500.EntryInformationSelection, err error) {
+	types, err := x500.AttributeTypesOf(new(T))
+	if err != nil {
+		return eis, err
+	}
+	if len(types) > 0 {
+		eis.SelectSET = types
+	}
+	return eis, nil
+}
+
+// Read the attributes named by the `x500` tags of T with all of their
+// contexts, so that they can be compared to values marshaled with contexts.
+func readAttributesOf[T any](ctx context.Context, client DirectoryAccessClient, dn DN) (X500OpOutcome, []x500.Attribute, error) {
+	object, err := nameFromDN(dn)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	selection, err := selectionOf[T]()
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	selection.ReturnContexts = true
+	selection.ContextSelection = asn1.RawValue{FullBytes: []byte{asn1.TagNull, 0}} // allContexts
+	arg := x500.ReadArgumentData{
+		Object:    object,
+		Selection: selection,
+	}
+	outcome, result, err := client.Read(ctx, arg)
+	if err != nil || result == nil {
+		return outcome, nil, err
+	}
+	attrs, err := x500.EntryInformationAttributes(&result.Entry)
+	if err != nil {
+		return outcome, nil, err
+	}
+	return outcome, attrs, nil
+}
+
+// Read the entry named by `dn`, selecting only the attributes named by the
+// `x500` tags of T, and unmarshal them into `out`. Values with contexts are
+// decoded like any other value. `out` is only populated if the outcome is a
+// result. See [x500.Marshal] for the meanings of the tags.
+func ReadInto[T any](ctx context.Context, client DirectoryAccessClient, dn DN, out *T) (X500OpOutcome, error) {
+	outcome, attrs, err := readAttributesOf[T](ctx, client, dn)
+	if err != nil || attrs == nil {
+		return outcome, err
+	}
+	return outcome, x500.Unmarshal(attrs, out)
+}
+
+// Perform a search, selecting only the attributes named by the `x500` tags of
+// T, and unmarshal each returned entry into a T. Any selection in `arg` is
+// overwritten. Uncorrelated and signed results are flattened into a single
+// slice. Entries are only returned if the outcome is a result.
+func SearchInto[T any](ctx context.Context, client DirectoryAccessClient, arg x500.SearchArgumentData) (X500OpOutcome, []MappedEntry[T], error) {
+	selection, err := selectionOf[T]()
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	arg.Selection = selection
+	outcome, _, err := client.Search(ctx, arg)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_RESULT {
+		return outcome, nil, err
+	}
+	entries := make([]MappedEntry[T], 0)
+	iter := x500.NewSearchIter(outcome.Parameter)
+	info, _, err := iter.Next()
+	for info != nil && err == nil {
+		for i := range info.Entries {
+			entry := MappedEntry[T]{}
+			rest, err := asn1.Unmarshal(info.Entries[i].Name.FullBytes, &entry.Name)
+			if err != nil {
+				return outcome, entries, err
+			}
+			if len(rest) > 0 {
+				return outcome, entries, errors.New("trailing bytes")
+			}
+			err = x500.UnmarshalEntryInformation(&info.Entries[i], &entry.Value)
+			if err != nil {
+				return outcome, entries, err
+			}
+			entries = append(entries, entry)
+		}
+		info, _, err = iter.Next()
+	}
+	return outcome, entries, err
+}
+
+// Marshal `val` into attributes and add it as a new entry named by `dn`.
+// Remember that the struct must include the `objectClass` attribute.
+func AddFrom[T any](ctx context.Context, client DirectoryAccessClient, dn DN, val T) (X500OpOutcome, *x500.AddEntryResultData, error) {
+	attrs, err := x500.Marshal(val)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	object, err := nameFromDN(dn)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	arg := x500.AddEntryArgumentData{
+		Object: object,
+		Entry:  attrs,
+	}
+	return client.AddEntry(ctx, arg)
+}
+
+// Read the attributes named by the `x500` tags of T from the entry named by
+// `dn`, compare them to the attributes marshaled from `val` using
+// [x500.DiffAttributes], and issue a single modifyEntry operation that makes
+// the entry match `val`. Attributes not named by T are untouched. `options`
+// may be nil.
+//
+// If the read does not produce a result, its outcome is returned. If there are
+// no differences, no modifyEntry operation is issued, and the outcome of the
+// read is returned with a nil result.
+func UpdateFrom[T any](ctx context.Context, client DirectoryAccessClient, dn DN, val T, options *x500.DiffOptions) (X500OpOutcome, *x500.ModifyEntryResultData, error) {
+	newAttrs, err := x500.Marshal(val)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	outcome, oldAttrs, err := readAttributesOf[T](ctx, client, dn)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_RESULT {
+		return outcome, nil, err
+	}
+	changes, err := x500.DiffAttributes(oldAttrs, newAttrs, options)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	if len(changes) == 0 {
+		return outcome, nil, nil
+	}
+	object, err := nameFromDN(dn)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	arg := x500.ModifyEntryArgumentData{
+		Object:  object,
+		Changes: changes,
+	}
+	return client.ModifyEntry(ctx, arg)
+}
@@ -0,0 +1,222 @@
+package x500_dap_client
+
+import (
+	"bytes"
+	"context"
+	"encoding/asn1"
+	"encoding/hex"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Wildboar-Software/x500-go/x500"
+)
+
+// Returns every entry, regardless of the base object and subset, each in its
+// own searchInfo within an uncorrelatedSearchInfo, as a DSA that chains the
+// search to several other DSAs might.
+func (d *fakeDirectory) Search(ctx context.Context, arg x500.SearchArgumentData) (X500OpOutcome, *x500.SearchResultData_searchInfo, error) {
+	d.selections = append(d.selections, arg.Selection)
+	keys := make([]string, 0, len(d.entries))
+	for key := range d.entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sets := make([]asn1.RawValue, 0, len(keys))
+	for _, key := range keys {
+		name, err := hex.DecodeString(key)
+		if err != nil {
+			return X500OpOutcome{}, nil, err
+		}
+		info, err := fakeEntryInformation(asn1.RawValue{FullBytes: name}, d.entries[key], arg.Selection)
+		if err != nil {
+			return X500OpOutcome{}, nil, err
+		}
+		searchInfo := x500.SearchResultData_searchInfo{Entries: []x500.EntryInformation{info}}
+		encoded, err := asn1.MarshalWithParams(searchInfo, "set")
+		if err != nil {
+			return X500OpOutcome{}, nil, err
+		}
+		sets = append(sets, asn1.RawValue{FullBytes: encoded})
+	}
+	encoded, err := asn1.MarshalWithParams(sets, "set")
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	encoded, err = asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      encoded,
+	})
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	outcome := fakeResult
+	if _, err = asn1.Unmarshal(encoded, &outcome.Parameter); err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	return outcome, nil, nil
+}
+
+type mappedPerson struct {
+	ObjectClass []asn1.ObjectIdentifier `x500:"oid:2.5.4.0"`
+	CommonName  []string                `x500:"oid:2.5.4.3,utf8"`
+	Surname     string                  `x500:"oid:2.5.4.4,utf8,uselang,lang:en"`
+	Title       string                  `x500:"oid:2.5.4.12,utf8,omitempty"`
+}
+
+// Names with Go string values, because SearchInto decodes names into them.
+func mappedTestDN(cn string) DN {
+	return DN{
+		{{Type: x500.Id_at_organizationName, Value: "Example"}},
+		{{Type: x500.Id_at_commonName, Value: cn}},
+	}
+}
+
+func mappedTestAlice() mappedPerson {
+	return mappedPerson{
+		ObjectClass: []asn1.ObjectIdentifier{x500.Id_oc_top, x500.Id_oc_person},
+		CommonName:  []string{"Alice"},
+		Surname:     "Smith",
+	}
+}
+
+func TestReadInto(t *testing.T) {
+	d := newFakeDirectory()
+	alice := mappedTestDN("Alice")
+	in := mappedTestAlice()
+	if _, _, err := AddFrom(context.Background(), d, alice, in); err != nil {
+		t.Error(err)
+		return
+	}
+	attrs := d.entries[fakeKey(alice)]
+	if len(attrs) != 3 || len(attrs[2].ValuesWithContext) != 1 {
+		t.Errorf("expected three attributes, the last with a language context, but got %+v", attrs)
+		return
+	}
+	d.entries[fakeKey(alice)] = append(attrs, testAttr(t, x500.Id_at_description, "Not in the struct"))
+
+	var out mappedPerson
+	outcome, err := ReadInto(context.Background(), d, alice, &out)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_RESULT {
+		t.Errorf("unexpected outcome type %d (%v)", outcome.OutcomeType, err)
+		return
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("expected %+v, but got %+v", in, out)
+		return
+	}
+
+	// The selection is derived from the struct tags, with all contexts.
+	selection := d.selections[len(d.selections)-1]
+	expected := []x500.AttributeType{x500.Id_at_objectClass, x500.Id_at_commonName, x500.Id_at_surname, x500.Id_at_title}
+	if len(selection.SelectSET) != len(expected) {
+		t.Errorf("expected %v to be selected, but got %v", expected, selection.SelectSET)
+		return
+	}
+	for i := range expected {
+		if !selection.SelectSET[i].Equal(expected[i]) {
+			t.Errorf("expected %v to be selected, but got %v", expected, selection.SelectSET)
+			return
+		}
+	}
+	if !selection.ReturnContexts || !bytes.Equal(selection.ContextSelection.FullBytes, []byte{asn1.TagNull, 0}) {
+		t.Errorf("expected all contexts to be requested, but got %+v", selection)
+		return
+	}
+
+	outcome, err = ReadInto(context.Background(), d, mappedTestDN("Bob"), &out)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_ERROR {
+		t.Errorf("expected the outcome of reading a missing entry, but got outcome type %d (%v)", outcome.OutcomeType, err)
+	}
+}
+
+func TestSearchInto(t *testing.T) {
+	d := newFakeDirectory()
+	alice := mappedTestAlice()
+	bob := mappedPerson{
+		ObjectClass: []asn1.ObjectIdentifier{x500.Id_oc_top, x500.Id_oc_person},
+		CommonName:  []string{"Bob", "Robert"},
+		Surname:     "Jones",
+		Title:       "Builder",
+	}
+	for cn, p := range map[string]mappedPerson{"Alice": alice, "Bob": bob} {
+		if _, _, err := AddFrom(context.Background(), d, mappedTestDN(cn), p); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	outcome, entries, err := SearchInto[mappedPerson](context.Background(), d, x500.SearchArgumentData{})
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_RESULT {
+		t.Errorf("unexpected outcome type %d (%v)", outcome.OutcomeType, err)
+		return
+	}
+	// Both searchInfo sets of the uncorrelatedSearchInfo are flattened.
+	if len(entries) != 2 {
+		t.Errorf("expected two entries, but got %d", len(entries))
+		return
+	}
+	for _, entry := range entries {
+		expected := alice
+		if x500.DNEqual(entry.Name, mappedTestDN("Bob")) {
+			expected = bob
+		} else if !x500.DNEqual(entry.Name, mappedTestDN("Alice")) {
+			t.Errorf("unexpected entry %s", entry.Name)
+			continue
+		}
+		if !reflect.DeepEqual(entry.Value, expected) {
+			t.Errorf("expected %+v, but got %+v", expected, entry.Value)
+		}
+	}
+	if len(d.selections[0].SelectSET) != 4 {
+		t.Errorf("expected the selection to be derived from the struct tags, but got %v", d.selections[0].SelectSET)
+	}
+}
+
+func TestUpdateFrom(t *testing.T) {
+	d := newFakeDirectory()
+	alice := mappedTestDN("Alice")
+	in := mappedTestAlice()
+	if _, _, err := AddFrom(context.Background(), d, alice, in); err != nil {
+		t.Error(err)
+		return
+	}
+	updates := d.updates
+
+	// Nothing changed, including the surname, which has a language context.
+	outcome, result, err := UpdateFrom(context.Background(), d, alice, in, nil)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_RESULT || result != nil {
+		t.Errorf("unexpected outcome type %d (%v)", outcome.OutcomeType, err)
+		return
+	}
+	if d.updates != updates {
+		t.Errorf("expected no modifyEntry operation, but got %d", d.updates-updates)
+		return
+	}
+
+	in.CommonName = append(in.CommonName, "Alice Smith")
+	in.Title = "Engineer"
+	outcome, _, err = UpdateFrom(context.Background(), d, alice, in, nil)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_RESULT {
+		t.Errorf("unexpected outcome type %d (%v)", outcome.OutcomeType, err)
+		return
+	}
+	if d.updates != updates+1 {
+		t.Errorf("expected one modifyEntry operation, but got %d", d.updates-updates)
+		return
+	}
+	var out mappedPerson
+	if _, err = ReadInto(context.Background(), d, alice, &out); err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("expected %+v, but got %+v", in, out)
+	}
+
+	outcome, _, err = UpdateFrom(context.Background(), d, mappedTestDN("Bob"), in, nil)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_ERROR || d.updates != updates+1 {
+		t.Errorf("expected the outcome of reading a missing entry, but got outcome type %d (%v)", outcome.OutcomeType, err)
+	}
+}
@@ -0,0 +1,390 @@
+package x500_dap_client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+
+	nsap "github.com/Wildboar-Software/x500-go/nsap-address"
+	"github.com/Wildboar-Software/x500-go/x500"
+)
+
+// A transport protocol over which a directory system agent may be reached.
+type TransportProtocol int
+
+const (
+	// Internet Directly-Mapped (IDM) protocol over TCP, as described in ITU-T
+	// Recommendation X.519. TLS may be started afterwards using StartTLS.
+	TransportIDM TransportProtocol = iota
+	// Internet Directly-Mapped (IDM) protocol over TLS from the first byte.
+	// ITU-T Recommendation X.519 defines no DSP prefix or protocol
+	// information for it, so only URL N-addresses with the "idms" scheme
+	// resolve to it. [DialEndpoints] uses StartTLS on [TransportIDM] instead.
+	TransportIDMS TransportProtocol = iota
+	// ISO Transport over TCP (ITOT), as described in IETF RFC 1006. Endpoints
+	// may be resolved to it, but this library cannot dial them.
+	TransportITOT TransportProtocol = iota
+	// Lightweight Directory Access Protocol (LDAP).
+	TransportLDAP TransportProtocol = iota
+	// Lightweight Directory Access Protocol (LDAP) over TLS.
+	TransportLDAPS TransportProtocol = iota
+)
+
+const DEFAULT_LDAP_PORT = 389
+const DEFAULT_LDAPS_PORT = 636
+
+func (t TransportProtocol) String() string {
+	switch t {
+	case TransportIDM:
+		return "idm"
+	case TransportIDMS:
+		return "idms"
+	case TransportITOT:
+		return "itot"
+	case TransportLDAP:
+		return "ldap"
+	case TransportLDAPS:
+		return "ldaps"
+	default:
+		return "unknown"
+	}
+}
+
+// The transports that [DialEndpoints] will use by default, in order of
+// preference. These are the transports that this library can actually use.
+var DefaultTransports = []TransportProtocol{TransportIDMS, TransportIDM}
+
+// A concrete network endpoint derived from a [x500.PresentationAddress].
+type Endpoint struct {
+	Transport TransportProtocol
+	Host      string
+	Port      int
+
+	// The selectors from the presentation address, which are only meaningful
+	// for OSI transports, such as ITOT.
+	PSelector []byte
+	SSelector []byte
+	TSelector []byte
+
+	// The network address from which this endpoint was derived.
+	NAddress []byte
+}
+
+// Return the endpoint's host and port in a form accepted by [net.Dial].
+func (e Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// Return the endpoint as a URL, such as `idm://dsa.example.com:4632`.
+func (e Endpoint) String() string {
+	return e.Transport.String() + "://" + e.Address()
+}
+
+func transportFromURLScheme(scheme string) (TransportProtocol, int, bool) {
+	switch scheme {
+	case "idm":
+		return TransportIDM, 0, true
+	case "idms":
+		return TransportIDMS, 0, true
+	case "itot":
+		return TransportITOT, nsap.ITOT_OVER_IPV4_DEFAULT_PORT, true
+	case "ldap":
+		return TransportLDAP, DEFAULT_LDAP_PORT, true
+	case "ldaps":
+		return TransportLDAPS, DEFAULT_LDAPS_PORT, true
+	}
+	return TransportIDM, 0, false
+}
+
+func endpointFromURL(u string) (Endpoint, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	transport, port, recognized := transportFromURLScheme(parsed.Scheme)
+	if !recognized {
+		return Endpoint{}, fmt.Errorf("unrecognized url scheme '%s'", parsed.Scheme)
+	}
+	if parsed.Port() != "" {
+		port, err = strconv.Atoi(parsed.Port())
+		if err != nil || port <= 0 || port > 65535 {
+			return Endpoint{}, fmt.Errorf("invalid port in url '%s'", u)
+		}
+	}
+	if port == 0 {
+		return Endpoint{}, fmt.Errorf("no port in url '%s'", u)
+	}
+	if parsed.Hostname() == "" {
+		return Endpoint{}, fmt.Errorf("no host in url '%s'", u)
+	}
+	return Endpoint{
+		Transport: transport,
+		Host:      parsed.Hostname(),
+		Port:      port,
+	}, nil
+}
+
+// Infer the transport of an N-address from the application contexts,
+// abstract syntaxes, and IDM protocols listed in its protocol information.
+// None of these indicate TLS, so IDM protocols yield [TransportIDM].
+func transportFromProfiles(naddr []byte, protocolInfo []x500.ProtocolInformation) (TransportProtocol, bool) {
+	for _, pi := range protocolInfo {
+		if !bytes.Equal(pi.NAddress, naddr) {
+			continue
+		}
+		for _, profile := range pi.Profiles {
+			switch {
+			case profile.Equal(x500.Id_idm_dap),
+				profile.Equal(x500.Id_idm_dsp),
+				profile.Equal(x500.Id_idm_disp),
+				profile.Equal(x500.Id_idm_dop):
+				return TransportIDM, true
+			case profile.Equal(x500.Id_ac_directoryAccessAC),
+				profile.Equal(x500.Id_as_directoryAccessAS):
+				return TransportITOT, true
+			}
+		}
+	}
+	return TransportIDM, false
+}
+
+func resolveNAddress(naddr []byte, protocolInfo []x500.ProtocolInformation) (Endpoint, error) {
+	decoded, err := nsap.FromBytes(naddr)
+	if err != nil {
+		return Endpoint{}, err
+	}
+	var endpoint Endpoint
+	if decoded.Network_type == nsap.URL {
+		endpoint, err = endpointFromURL(string(decoded.Dsp))
+		if err != nil {
+			return Endpoint{}, err
+		}
+		endpoint.NAddress = naddr
+		return endpoint, nil
+	}
+	ip, port, ok := decoded.SocketAddr()
+	if !ok {
+		return Endpoint{}, errors.New("network address does not identify an internet host")
+	}
+	endpoint.Host = ip.String()
+	endpoint.Port = port
+	endpoint.NAddress = naddr
+	if prefix, is_internet := decoded.InternetDSPPrefix(); is_internet {
+		switch prefix {
+		case nsap.ITU_X519_DSP_PREFIX_IDM_OVER_IPV4:
+			endpoint.Transport = TransportIDM
+		case nsap.ITU_X519_DSP_PREFIX_ITOT_OVER_IPV4:
+			endpoint.Transport = TransportITOT
+		case nsap.ITU_X519_DSP_PREFIX_LDAP:
+			endpoint.Transport = TransportLDAP
+		default:
+			return Endpoint{}, fmt.Errorf("unrecognized internet dsp prefix %02x", prefix)
+		}
+	} else {
+		// IP addresses encoded under the IANA ICP AFI say nothing about the
+		// transport, so the protocol information is the only hint we have.
+		transport, known := transportFromProfiles(naddr, protocolInfo)
+		if !known {
+			return Endpoint{}, errors.New("could not determine transport of network address")
+		}
+		endpoint.Transport = transport
+	}
+	if endpoint.Port == 0 {
+		switch endpoint.Transport {
+		case TransportITOT:
+			endpoint.Port = nsap.ITOT_OVER_IPV4_DEFAULT_PORT
+		case TransportLDAP:
+			endpoint.Port = DEFAULT_LDAP_PORT
+		default:
+			return Endpoint{}, fmt.Errorf("%s network address does not encode a port", endpoint.Transport)
+		}
+	}
+	return endpoint, nil
+}
+
+// Resolve a [x500.PresentationAddress] to zero or more concrete endpoints, in
+// the order in which the N-addresses appear. Protocol information, if
+// supplied, is used to determine the transport of N-addresses that do not
+// otherwise indicate one.
+//
+// N-addresses that cannot be resolved are skipped. An error is returned only
+// if no N-address could be resolved, and it describes why each one failed.
+func ResolvePresentationAddress(paddr x500.PresentationAddress, protocolInfo []x500.ProtocolInformation) ([]Endpoint, error) {
+	endpoints := make([]Endpoint, 0, len(paddr.NAddresses))
+	errs := make([]error, 0)
+	for i, naddr := range paddr.NAddresses {
+		endpoint, err := resolveNAddress(naddr, protocolInfo)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("naddress %d: %w", i, err))
+			continue
+		}
+		endpoint.PSelector = paddr.PSelector
+		endpoint.SSelector = paddr.SSelector
+		endpoint.TSelector = paddr.TSelector
+		endpoints = append(endpoints, endpoint)
+	}
+	if len(endpoints) == 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("presentation address has no network addresses")
+		}
+		return nil, errors.Join(errs...)
+	}
+	return endpoints, nil
+}
+
+// Resolve an access point, such as one returned in a referral, in a
+// continuation reference, or in shadowing or knowledge attributes, to zero or
+// more concrete endpoints. See [ResolvePresentationAddress].
+func ResolveAccessPoint(ap x500.AccessPointInterface) ([]Endpoint, error) {
+	return ResolvePresentationAddress(ap.GetAddress(), ap.GetProtocolInformation())
+}
+
+// Configuration for [DialEndpoints] and [DialAccessPoint].
+type DialConfig struct {
+	// The acceptable transports, in order of preference. If empty,
+	// [DefaultTransports] is used.
+	Transports []TransportProtocol
+
+	// The dialer used to establish TCP connections. If nil, a zero-valued
+	// [net.Dialer] is used.
+	Dialer *net.Dialer
+
+	// TLS configuration used for transports that use TLS from the first byte,
+	// and for StartTLS. If ServerName is unset, it is set to the host of the
+	// endpoint.
+	TlsConfig *tls.Config
+
+	// Policy towards StartTLS on [TransportIDM] endpoints, which is the same
+	// as that of [IDMClientConfig]: by default, an endpoint on which TLS
+	// cannot be started is skipped.
+	StartTLSPolicy StartTLSChoice
+}
+
+func (config *DialConfig) tlsConfigFor(endpoint Endpoint) *tls.Config {
+	var tlsConfig *tls.Config
+	if config.TlsConfig != nil {
+		tlsConfig = config.TlsConfig.Clone()
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = endpoint.Host
+	}
+	return tlsConfig
+}
+
+// Perform StartTLS on an IDM connection. If the DSA refuses, and the policy
+// permits it, the connection is returned without TLS.
+func startTLSOnIDM(ctx context.Context, conn net.Conn, endpoint Endpoint, config *DialConfig) (Socket, error) {
+	stack := IDMClient(conn, &IDMClientConfig{
+		TlsConfig:      config.tlsConfigFor(endpoint),
+		StartTLSPolicy: config.StartTLSPolicy,
+	})
+	response, err := stack.startTLS(ctx)
+	if err == nil {
+		return stack.socket, nil
+	}
+	// Errors other than a refusal, such as a failed handshake, leave the
+	// connection unusable.
+	if config.StartTLSPolicy == StartTLSPrefer && response.response != x500.TLSResponse_Success {
+		return conn, nil
+	}
+	return nil, err
+}
+
+// Order the endpoints by the preference of their transports, discarding those
+// whose transports are not acceptable. Endpoints of the same transport retain
+// their relative order.
+func SortEndpoints(endpoints []Endpoint, transports []TransportProtocol) []Endpoint {
+	if len(transports) == 0 {
+		transports = DefaultTransports
+	}
+	sorted := make([]Endpoint, 0, len(endpoints))
+	for _, t := range transports {
+		for _, e := range endpoints {
+			if e.Transport == t {
+				sorted = append(sorted, e)
+			}
+		}
+	}
+	return sorted
+}
+
+// Dial the endpoints in order of transport preference, returning the first
+// socket that was successfully established and the endpoint it connects to.
+// StartTLS is performed on [TransportIDM] endpoints according to the
+// StartTLSPolicy of `config`. [TransportITOT] may not be among its
+// Transports.
+//
+// The returned socket is a [*tls.Conn] if TLS is in use, in which case
+// [IDMProtocolStack.Bind] will not attempt StartTLS.
+func DialEndpoints(ctx context.Context, endpoints []Endpoint, config *DialConfig) (Socket, Endpoint, error) {
+	if config == nil {
+		config = &DialConfig{}
+	}
+	if slices.Contains(config.Transports, TransportITOT) {
+		return nil, Endpoint{}, errors.New("the itot transport is not supported")
+	}
+	dialer := config.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	candidates := SortEndpoints(endpoints, config.Transports)
+	if len(candidates) == 0 {
+		return nil, Endpoint{}, errors.New("no endpoints using an acceptable transport")
+	}
+	errs := make([]error, 0, len(candidates))
+	for _, endpoint := range candidates {
+		conn, err := dialer.DialContext(ctx, "tcp", endpoint.Address())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		if endpoint.Transport == TransportIDM && config.StartTLSPolicy != StartTLSNever {
+			socket, err := startTLSOnIDM(ctx, conn, endpoint, config)
+			if err != nil {
+				conn.Close()
+				errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+				if ctx.Err() != nil {
+					break
+				}
+				continue
+			}
+			return socket, endpoint, nil
+		}
+		if endpoint.Transport != TransportIDMS && endpoint.Transport != TransportLDAPS {
+			return conn, endpoint, nil
+		}
+		tlsConn := tls.Client(conn, config.tlsConfigFor(endpoint))
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			conn.Close()
+			errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		return tlsConn, endpoint, nil
+	}
+	return nil, Endpoint{}, errors.Join(errs...)
+}
+
+// Resolve and dial an access point. See [ResolveAccessPoint] and
+// [DialEndpoints].
+func DialAccessPoint(ctx context.Context, ap x500.AccessPointInterface, config *DialConfig) (Socket, Endpoint, error) {
+	endpoints, err := ResolveAccessPoint(ap)
+	if err != nil {
+		return nil, Endpoint{}, err
+	}
+	return DialEndpoints(ctx, endpoints, config)
+}
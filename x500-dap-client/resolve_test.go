@@ -0,0 +1,177 @@
+package x500_dap_client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/asn1"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	nsap "github.com/Wildboar-Software/x500-go/nsap-address"
+	"github.com/Wildboar-Software/x500-go/x500"
+)
+
+// An IETF RFC 1277 NSAP for 192.168.0.1 using the given DSP prefix and
+// semi-octets encoding the port, if any.
+func rfc1277NAddress(prefix byte, port ...byte) []byte {
+	naddr := []byte{
+		nsap.AFI_F69_DEC_LEADING_ZERO, 0x00, 0x72, 0x87, 0x22,
+		prefix, 0x19, 0x21, 0x68, 0x00, 0x00, 0x01,
+	}
+	return append(naddr, port...)
+}
+
+func urlNAddress(u string) []byte {
+	return append([]byte{nsap.AFI_URL, 0x00, 0x00}, u...)
+}
+
+func TestResolvePresentationAddress(t *testing.T) {
+	icp := []byte{nsap.AFI_IANA_ICP_BIN, 0x00, 0x01, 10, 9, 8, 7}
+	protocolInfo := []x500.ProtocolInformation{
+		{NAddress: icp, Profiles: []asn1.ObjectIdentifier{x500.Id_ac_directoryAccessAC}},
+	}
+	cases := []struct {
+		name     string
+		naddr    []byte
+		expected string
+	}{
+		{"IDM", rfc1277NAddress(nsap.ITU_X519_DSP_PREFIX_IDM_OVER_IPV4, 0x04, 0x63, 0x2F), "idm://192.168.0.1:4632"},
+		{"ITOT", rfc1277NAddress(nsap.ITU_X519_DSP_PREFIX_ITOT_OVER_IPV4), "itot://192.168.0.1:102"},
+		{"LDAP", rfc1277NAddress(nsap.ITU_X519_DSP_PREFIX_LDAP), "ldap://192.168.0.1:389"},
+		{"IDMS URL", urlNAddress("idms://dsa.example.com:44632"), "idms://dsa.example.com:44632"},
+		{"LDAPS URL", urlNAddress("ldaps://dsa.example.com"), "ldaps://dsa.example.com:636"},
+		{"IANA ICP with protocol information", icp, "itot://10.9.8.7:102"},
+	}
+	for _, c := range cases {
+		paddr := x500.PresentationAddress{
+			TSelector:  []byte("T"),
+			NAddresses: [][]byte{c.naddr},
+		}
+		endpoints, err := ResolvePresentationAddress(paddr, protocolInfo)
+		if err != nil {
+			t.Errorf("%s: %s", c.name, err)
+			continue
+		}
+		if len(endpoints) != 1 || endpoints[0].String() != c.expected {
+			t.Errorf("%s: expected %s, but got %v", c.name, c.expected, endpoints)
+			continue
+		}
+		if string(endpoints[0].TSelector) != "T" || string(endpoints[0].NAddress) != string(c.naddr) {
+			t.Errorf("%s: selectors or network address not retained: %+v", c.name, endpoints[0])
+		}
+	}
+}
+
+func TestResolvePresentationAddressErrors(t *testing.T) {
+	for name, naddr := range map[string][]byte{
+		"IDM without a port":                    rfc1277NAddress(nsap.ITU_X519_DSP_PREFIX_IDM_OVER_IPV4),
+		"unrecognized prefix":                   rfc1277NAddress(nsap.RFC_1277_WELL_KNOWN_NETWORK_JANET),
+		"IANA ICP without protocol information": {nsap.AFI_IANA_ICP_BIN, 0x00, 0x01, 10, 9, 8, 7},
+		"unrecognized URL scheme":               urlNAddress("http://dsa.example.com:80"),
+		"URL without a port":                    urlNAddress("idm://dsa.example.com"),
+		"X.121":                                 {nsap.AFI_X121_DEC_LEADING_NON_ZERO, 0x00, 0x23, 0x42, 0x19, 0x20, 0x03, 0x00},
+		"malformed":                             {0x00},
+	} {
+		paddr := x500.PresentationAddress{NAddresses: [][]byte{naddr}}
+		if endpoints, err := ResolvePresentationAddress(paddr, nil); err == nil {
+			t.Errorf("%s: expected an error, but got %v", name, endpoints)
+		}
+	}
+	if _, err := ResolvePresentationAddress(x500.PresentationAddress{}, nil); err == nil {
+		t.Error("expected a presentation address without network addresses to be rejected")
+	}
+
+	// Network addresses that cannot be resolved are skipped.
+	paddr := x500.PresentationAddress{NAddresses: [][]byte{
+		{0x00},
+		rfc1277NAddress(nsap.ITU_X519_DSP_PREFIX_IDM_OVER_IPV4, 0x04, 0x63, 0x2F),
+	}}
+	endpoints, err := ResolvePresentationAddress(paddr, nil)
+	if err != nil || len(endpoints) != 1 || endpoints[0].Transport != TransportIDM {
+		t.Errorf("expected one IDM endpoint, but got %v (%v)", endpoints, err)
+	}
+}
+
+func TestSortEndpoints(t *testing.T) {
+	endpoints := []Endpoint{
+		{Transport: TransportITOT, Host: "a", Port: 102},
+		{Transport: TransportIDM, Host: "b", Port: 4632},
+		{Transport: TransportIDMS, Host: "c", Port: 44632},
+		{Transport: TransportIDM, Host: "d", Port: 4632},
+		{Transport: TransportLDAP, Host: "e", Port: 389},
+	}
+	expect := func(sorted []Endpoint, hosts string) {
+		t.Helper()
+		got := ""
+		for _, e := range sorted {
+			got += e.Host
+		}
+		if got != hosts {
+			t.Errorf("expected hosts %s, but got %s", hosts, got)
+		}
+	}
+	expect(SortEndpoints(endpoints, nil), "cbd")
+	expect(SortEndpoints(endpoints, []TransportProtocol{TransportLDAP, TransportIDM, TransportITOT}), "ebda")
+	expect(SortEndpoints(endpoints, []TransportProtocol{TransportLDAPS}), "")
+	expect(SortEndpoints(nil, nil), "")
+}
+
+// Listens for IDM connections, and refuses every StartTLS request with
+// tLSResponse unavailable.
+func listenRefusingStartTLS(t *testing.T) Endpoint {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+			go func() {
+				request := make([]byte, len(FULL_IDMV1_START_TLS_PDU))
+				if _, err := io.ReadFull(conn, request); err != nil || !bytes.Equal(request, FULL_IDMV1_START_TLS_PDU[:]) {
+					return
+				}
+				conn.Write([]byte{1, 1, 0, 0, 0, 5, 0xAA, 3, asn1.TagEnum, 1, byte(x500.TLSResponse_Unavailable)})
+			}()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return Endpoint{Transport: TransportIDM, Host: host, Port: portNumber}
+}
+
+func TestDialEndpoints(t *testing.T) {
+	endpoint := listenRefusingStartTLS(t)
+	endpoints := []Endpoint{endpoint}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, _, err := DialEndpoints(ctx, endpoints, nil); err == nil {
+		t.Error("expected StartTLS to be demanded by default")
+	}
+	for _, policy := range []StartTLSChoice{StartTLSPrefer, StartTLSNever} {
+		socket, dialed, err := DialEndpoints(ctx, endpoints, &DialConfig{StartTLSPolicy: policy})
+		if err != nil {
+			t.Errorf("policy %d: %v", policy, err)
+			continue
+		}
+		if _, isTLS := socket.(*tls.Conn); isTLS || dialed.String() != endpoint.String() {
+			t.Errorf("policy %d: expected a connection without TLS to %s, but got %s", policy, endpoint, dialed)
+		}
+		socket.Close()
+	}
+
+	config := &DialConfig{Transports: []TransportProtocol{TransportIDM, TransportITOT}, StartTLSPolicy: StartTLSNever}
+	if _, _, err := DialEndpoints(ctx, endpoints, config); err == nil {
+		t.Error("expected the itot transport to be rejected")
+	}
+}
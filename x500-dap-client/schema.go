@@ -0,0 +1,84 @@
+package x500_dap_client
+
+import (
+	"context"
+	"encoding/asn1"
+	"errors"
+
+	"github.com/Wildboar-Software/x500-go/x500"
+)
+
+// Read the operational attributes `types` of the entry named by `dn`,
+// selecting no user attributes.
+func readOperationalAttributes(ctx context.Context, client DirectoryAccessClient, dn DN, types []x500.AttributeType) (X500OpOutcome, []x500.Attribute, error) {
+	object, err := nameFromDN(dn)
+	if err != nil {
+		return X500OpOutcome{}, nil, err
+	}
+	arg := x500.ReadArgumentData{
+		Object: object,
+		Selection: x500.EntryInformationSelection{
+			SelectSET:                      []asn1.ObjectIdentifier{},
+			SelectOperationalAttributesSET: types,
+		},
+	}
+	outcome, result, err := client.Read(ctx, arg)
+	if err != nil || result == nil {
+		return outcome, nil, err
+	}
+	attrs, err := x500.EntryInformationAttributes(&result.Entry)
+	if err != nil {
+		return outcome, nil, err
+	}
+	return outcome, attrs, nil
+}
+
+// Returns the distinguished name of the subschema subentry that governs the
+// entry named by `dn`, as indicated by its `subschemaSubentryList` operational
+// attribute. If the read does not produce a result, its outcome is returned
+// with a nil name.
+func GoverningSubschema(ctx context.Context, client DirectoryAccessClient, dn DN) (X500OpOutcome, DN, error) {
+	outcome, attrs, err := readOperationalAttributes(ctx, client, dn, []x500.AttributeType{x500.Id_oa_subschemaSubentryList})
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_RESULT {
+		return outcome, nil, err
+	}
+	for _, attr := range attrs {
+		if !attr.Type.Equal(x500.Id_oa_subschemaSubentryList) || attr.IsEmpty() {
+			continue
+		}
+		var subschema DN
+		rest, err := asn1.Unmarshal(attr.GetSingleValue().FullBytes, &subschema)
+		if err != nil {
+			return outcome, nil, err
+		}
+		if len(rest) > 0 {
+			return outcome, nil, errors.New("trailing bytes")
+		}
+		return outcome, subschema, nil
+	}
+	return outcome, nil, errors.New("entry has no governing subschema")
+}
+
+// Read the subschema subentry that governs the entry named by `dn` and build
+// a [x500.SchemaRegistry] from its schema operational attributes. Two read
+// operations are performed: one to find the subschema subentry and one to read
+// it. If either read does not produce a result, its outcome is returned with a
+// nil registry.
+func ReadSubschema(ctx context.Context, client DirectoryAccessClient, dn DN) (X500OpOutcome, *x500.SchemaRegistry, error) {
+	outcome, subschema, err := GoverningSubschema(ctx, client, dn)
+	if err != nil || subschema == nil {
+		return outcome, nil, err
+	}
+	outcome, attrs, err := readOperationalAttributes(ctx, client, subschema, x500.SubschemaAttributeTypes)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_RESULT {
+		return outcome, nil, err
+	}
+	registry, err := x500.NewSchemaRegistryFromAttributes(attrs)
+	return outcome, registry, err
+}
+
+// Read the subschema subentry that governs the entry named by `dn` and build
+// a [x500.SchemaRegistry] from it. See [ReadSubschema].
+func (stack *IDMProtocolStack) ReadSubschema(ctx context.Context, dn DN) (X500OpOutcome, *x500.SchemaRegistry, error) {
+	return ReadSubschema(ctx, stack, dn)
+}
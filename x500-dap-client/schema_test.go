@@ -0,0 +1,109 @@
+package x500_dap_client
+
+import (
+	"context"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/Wildboar-Software/x500-go/x500"
+)
+
+// The names of the entries and of the subschema subentry that governs them.
+// The values are Go strings, because GoverningSubschema decodes the name of
+// the subschema subentry into them before it is read.
+var (
+	schemaTestContext    = DN{{{Type: x500.Id_at_organizationName, Value: "Example"}}}
+	schemaTestSubentry   = append(append(DN{}, schemaTestContext...), x500.RelativeDistinguishedName{{Type: x500.Id_at_commonName, Value: "Subschema"}})
+	schemaTestAlice      = append(append(DN{}, schemaTestContext...), x500.RelativeDistinguishedName{{Type: x500.Id_at_commonName, Value: "Alice"}})
+	schemaTestUngoverned = append(append(DN{}, schemaTestContext...), x500.RelativeDistinguishedName{{Type: x500.Id_at_commonName, Value: "Bob"}})
+)
+
+func newSchemaTestDirectory(t *testing.T) *fakeDirectory {
+	cn := x500.AttributeTypeDescription{
+		Identifier: x500.Id_at_commonName,
+		Name:       []x500.UnboundedDirectoryString{x500.NewDirectoryString("cn"), x500.NewDirectoryString("commonName")},
+		Information: x500.AttributeTypeInformation{
+			EqualityMatch: x500.Id_mr_caseIgnoreMatch,
+		},
+	}
+	person := x500.ObjectClassDescription{
+		Identifier: x500.Id_oc_person,
+		Name:       []x500.UnboundedDirectoryString{x500.NewDirectoryString("person")},
+		Information: x500.ObjectClassInformation{
+			Kind:        x500.ObjectClassKind_Structural,
+			Mandatories: []asn1.ObjectIdentifier{x500.Id_at_commonName},
+		},
+	}
+	governing := x500.Attribute{
+		Type:   x500.Id_oa_subschemaSubentryList,
+		Values: []asn1.RawValue{mustMarshal(t, schemaTestSubentry, "")},
+	}
+	d := newFakeDirectory()
+	d.add(schemaTestSubentry,
+		testAttr(t, x500.Id_at_commonName, "Subschema"),
+		x500.Attribute{Type: x500.Id_soa_attributeTypes, Values: []asn1.RawValue{mustMarshal(t, cn, "")}},
+		x500.Attribute{Type: x500.Id_soa_objectClasses, Values: []asn1.RawValue{mustMarshal(t, person, "")}},
+	)
+	d.add(schemaTestAlice, testAttr(t, x500.Id_at_commonName, "Alice"), governing)
+	d.add(schemaTestUngoverned, testAttr(t, x500.Id_at_commonName, "Bob"))
+	return d
+}
+
+func TestGoverningSubschema(t *testing.T) {
+	d := newSchemaTestDirectory(t)
+	outcome, subschema, err := GoverningSubschema(context.Background(), d, schemaTestAlice)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if outcome.OutcomeType != OP_OUTCOME_RESULT || fakeKey(subschema) != fakeKey(schemaTestSubentry) {
+		t.Errorf("expected %s, but got %s (outcome type %d)", schemaTestSubentry, subschema, outcome.OutcomeType)
+		return
+	}
+
+	_, subschema, err = GoverningSubschema(context.Background(), d, schemaTestUngoverned)
+	if err == nil || subschema != nil {
+		t.Errorf("expected an entry without a subschemaSubentryList to be rejected, but got %s", subschema)
+	}
+
+	missing := append(append(DN{}, schemaTestContext...), x500.RelativeDistinguishedName{{Type: x500.Id_at_commonName, Value: "Carol"}})
+	outcome, subschema, err = GoverningSubschema(context.Background(), d, missing)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_ERROR || subschema != nil {
+		t.Errorf("expected the outcome of reading a missing entry, but got %s (%v)", subschema, err)
+	}
+}
+
+func TestReadSubschema(t *testing.T) {
+	d := newSchemaTestDirectory(t)
+	outcome, registry, err := ReadSubschema(context.Background(), d, schemaTestAlice)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if outcome.OutcomeType != OP_OUTCOME_RESULT || registry == nil {
+		t.Errorf("expected a registry, but got outcome type %d", outcome.OutcomeType)
+		return
+	}
+	cn := registry.AttributeType("commonName")
+	if cn == nil || !cn.Information.EqualityMatch.Equal(x500.Id_mr_caseIgnoreMatch) {
+		t.Errorf("failed to look up commonName: %+v", cn)
+		return
+	}
+	person := registry.ObjectClass("person")
+	if person == nil || person.Information.Kind != x500.ObjectClassKind_Structural {
+		t.Errorf("failed to look up person: %+v", person)
+		return
+	}
+	if len(registry.AttributeTypes()) != 1 || len(registry.ObjectClasses()) != 1 {
+		t.Errorf("expected only the schema of the subentry, but got %d attribute types and %d object classes",
+			len(registry.AttributeTypes()), len(registry.ObjectClasses()))
+		return
+	}
+
+	// The subschema subentry that the entry names does not exist.
+	delete(d.entries, fakeKey(schemaTestSubentry))
+	outcome, registry, err = ReadSubschema(context.Background(), d, schemaTestAlice)
+	if err != nil || outcome.OutcomeType != OP_OUTCOME_ERROR || registry != nil {
+		t.Errorf("expected the outcome of reading a missing subentry, but got %v (%v)", registry, err)
+	}
+}
@@ -46,7 +46,7 @@ func (a *Attribute) Get(index int) *asn1.RawValue {
 	}
 	index -= len(a.Values)
 	if index < len(a.ValuesWithContext) {
-		return &a.ValuesWithContext[0].Value
+		return &a.ValuesWithContext[index].Value
 	}
 	return nil
 }
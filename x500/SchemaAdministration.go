@@ -209,7 +209,7 @@ func (x *ObjectClassDescription) GetObsolete() bool {
 //	  ... }
 type ObjectClassInformation struct {
 	SubclassOf  [](asn1.ObjectIdentifier) `asn1:"optional,set,omitempty"`
-	Kind        ObjectClassKind           `asn1:"optional"`
+	Kind        ObjectClassKind           `asn1:"optional,default:1"`
 	Mandatories [](asn1.ObjectIdentifier) `asn1:"optional,explicit,tag:3,set,omitempty"`
 	Optionals   [](asn1.ObjectIdentifier) `asn1:"optional,explicit,tag:4,set,omitempty"`
 }
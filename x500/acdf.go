@@ -0,0 +1,967 @@
+package x500
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// The UserClasses of an ACIItem in a form that is easier to inspect.
+type UserClassesNode struct {
+	AllUsers  bool
+	ThisEntry bool
+	Name      []NameAndOptionalUID
+
+	// The names of groupOfUniqueNames entries whose members are included.
+	UserGroup []NameAndOptionalUID
+
+	// The bases of these subtrees are relative to the root of the DIT.
+	Subtree []*SubtreeSpecificationNode
+}
+
+// The ProtectedItems of an ACIItem in a form that is easier to inspect.
+type ProtectedItemsNode struct {
+	Entry                          bool
+	AllUserAttributeTypes          bool
+	AttributeType                  []AttributeType
+	AllAttributeValues             []AttributeType
+	AllUserAttributeTypesAndValues bool
+
+	// The values of these are asn1.RawValues.
+	AttributeValue []pkix.AttributeTypeAndValue
+	SelfValue      []AttributeType
+	RangeOfValues  *FilterNode
+	MaxValueCount  []MaxValueCount
+
+	// If not nil, the greatest number of immediate subordinates that the
+	// superior of an entry that is added or imported may have.
+	MaxImmSub    *int
+	RestrictedBy []RestrictedValue
+	Contexts     []ContextAssertion
+	Classes      *RefinementNode
+}
+
+// A combination of user classes, protected items and permissions of an
+// ACIItem, as described in ITU-T Recommendation X.501, Section 18.8.2.
+// [DecodeACIItem] produces the tuples of an ACIItem.
+type ACITuple struct {
+	UserClasses UserClassesNode
+
+	// The authentication level of the ACIItem, or nil if it is the `other`
+	// alternative, which no requester is considered to have.
+	AuthenticationLevel *AuthenticationLevel_basicLevels
+	ProtectedItems      ProtectedItemsNode
+	GrantsAndDenials    GrantsAndDenials
+	Precedence          Precedence
+}
+
+// Unmarshals the SET OF `value` into the slice pointed to by `out`.
+func unmarshalSetOf(value asn1.RawValue, out any) error {
+	rest, err := asn1.UnmarshalWithParams(encodingOf(value), out, "set")
+	if err == nil && len(rest) > 0 {
+		err = errors.New("trailing bytes")
+	}
+	return err
+}
+
+func decodeAuthenticationLevel(level AuthenticationLevel) (*AuthenticationLevel_basicLevels, error) {
+	if level.Class != asn1.ClassUniversal {
+		return nil, errors.New("invalid authentication level")
+	}
+	switch level.Tag {
+	case asn1.TagSequence:
+		basic := &AuthenticationLevel_basicLevels{}
+		if err := unmarshalExactly(level, basic); err != nil {
+			return nil, err
+		}
+		return basic, nil
+	case 8: // EXTERNAL
+		return nil, nil
+	}
+	return nil, errors.New("invalid authentication level")
+}
+
+func decodeUserClasses(value asn1.RawValue) (*UserClassesNode, error) {
+	components, err := sequenceComponents(value)
+	if err != nil {
+		return nil, err
+	}
+	u := &UserClassesNode{}
+	for _, component := range components {
+		if component.Class != asn1.ClassContextSpecific {
+			return nil, errors.New("invalid user classes")
+		}
+		inner, err := explicitlyTaggedValue(component)
+		if err != nil {
+			return nil, err
+		}
+		switch component.Tag {
+		case 0:
+			u.AllUsers = true
+		case 1:
+			u.ThisEntry = true
+		case 2:
+			err = unmarshalSetOf(inner, &u.Name)
+		case 3:
+			err = unmarshalSetOf(inner, &u.UserGroup)
+		case 4:
+			var specs []asn1.RawValue
+			if err = unmarshalSetOf(inner, &specs); err != nil {
+				break
+			}
+			for _, spec := range specs {
+				s := &SubtreeSpecificationNode{}
+				if err = s.UnmarshalRawValue(spec); err != nil {
+					break
+				}
+				u.Subtree = append(u.Subtree, s)
+			}
+		}
+		// Unrecognized extensions are ignored.
+		if err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+func decodeProtectedItems(value asn1.RawValue) (*ProtectedItemsNode, error) {
+	components, err := sequenceComponents(value)
+	if err != nil {
+		return nil, err
+	}
+	p := &ProtectedItemsNode{}
+	for _, component := range components {
+		if component.Class != asn1.ClassContextSpecific {
+			return nil, errors.New("invalid protected items")
+		}
+		inner, err := explicitlyTaggedValue(component)
+		if err != nil {
+			return nil, err
+		}
+		switch component.Tag {
+		case 0:
+			p.Entry = true
+		case 1:
+			p.AllUserAttributeTypes = true
+		case 2:
+			err = unmarshalSetOf(inner, &p.AttributeType)
+		case 3:
+			err = unmarshalSetOf(inner, &p.AllAttributeValues)
+		case 4:
+			p.AllUserAttributeTypesAndValues = true
+		case 5:
+			var atavs []struct {
+				Type  asn1.ObjectIdentifier
+				Value asn1.RawValue
+			}
+			if err = unmarshalSetOf(inner, &atavs); err != nil {
+				break
+			}
+			for _, atav := range atavs {
+				p.AttributeValue = append(p.AttributeValue, pkix.AttributeTypeAndValue{Type: atav.Type, Value: atav.Value})
+			}
+		case 6:
+			err = unmarshalSetOf(inner, &p.SelfValue)
+		case 7:
+			p.RangeOfValues, err = DecodeFilter(inner)
+		case 8:
+			err = unmarshalSetOf(inner, &p.MaxValueCount)
+		case 9:
+			p.MaxImmSub = new(int)
+			err = unmarshalExactly(inner, p.MaxImmSub)
+		case 10:
+			err = unmarshalSetOf(inner, &p.RestrictedBy)
+		case 11:
+			err = unmarshalSetOf(inner, &p.Contexts)
+		case 12:
+			p.Classes, err = DecodeRefinement(inner)
+		}
+		// Unrecognized extensions are ignored.
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Decodes an ItemPermission or UserPermission, returning its precedence, if
+// present, and its second component undecoded.
+func decodePermission(value asn1.RawValue) (*Precedence, asn1.RawValue, GrantsAndDenials, error) {
+	var grantsAndDenials GrantsAndDenials
+	components, err := sequenceComponents(value)
+	if err != nil {
+		return nil, asn1.RawValue{}, grantsAndDenials, err
+	}
+	var precedence *Precedence
+	if len(components) > 0 && components[0].Class == asn1.ClassUniversal && components[0].Tag == asn1.TagInteger {
+		precedence = new(Precedence)
+		if err := unmarshalExactly(components[0], precedence); err != nil {
+			return nil, asn1.RawValue{}, grantsAndDenials, err
+		}
+		components = components[1:]
+	}
+	if len(components) < 2 {
+		return nil, asn1.RawValue{}, grantsAndDenials, errors.New("invalid permission")
+	}
+	if err := unmarshalExactly(components[1], &grantsAndDenials); err != nil {
+		return nil, asn1.RawValue{}, grantsAndDenials, err
+	}
+	return precedence, components[0], grantsAndDenials, nil
+}
+
+// An ItemPermission or UserPermission of an [ACIItemNode].
+type ACIPermissionNode struct {
+	// If nil, the precedence of the ACIItem applies.
+	Precedence *Precedence
+
+	// The user classes of an ItemPermission.
+	UserClasses *UserClassesNode
+
+	// The protected items of a UserPermission.
+	ProtectedItems   *ProtectedItemsNode
+	GrantsAndDenials GrantsAndDenials
+}
+
+// An ACIItem in a form that is easier to construct and inspect than its
+// nested CHOICEs. Use [ACIItemNode.Marshal] to produce an ACIItem, and
+// [DecodeACIItemNode] to produce an ACIItemNode from one.
+type ACIItemNode struct {
+	IdentificationTag string
+	Precedence        Precedence
+
+	// The authentication level, or nil if it is the `other` alternative,
+	// which cannot be marshalled.
+	AuthenticationLevel *AuthenticationLevel_basicLevels
+
+	// If true, the item is `userFirst`: `UserClasses` applies to all of the
+	// permissions, which each have protected items. Otherwise, the item is
+	// `itemFirst`: `ProtectedItems` applies to all of the permissions, which
+	// each have user classes.
+	UserFirst      bool
+	UserClasses    *UserClassesNode
+	ProtectedItems *ProtectedItemsNode
+	Permissions    []ACIPermissionNode
+}
+
+// Builds a SEQUENCE whose components have explicit tags, recording the first
+// error.
+type taggedSequence struct {
+	components [][]byte
+	err        error
+}
+
+func (b *taggedSequence) add(tag int, encoded []byte, err error) {
+	if b.err != nil {
+		return
+	}
+	if err != nil {
+		b.err = err
+		return
+	}
+	tagged, err := contextTagged(tag, encoded)
+	b.components = append(b.components, tagged.FullBytes)
+	b.err = err
+}
+
+func (b *taggedSequence) addNull(tag int, present bool) {
+	if present {
+		b.add(tag, asn1.NullBytes, nil)
+	}
+}
+
+// Adds a SET OF the encoded `elements`, unless there are none.
+func (b *taggedSequence) addSet(tag int, elements [][]byte) {
+	if len(elements) == 0 {
+		return
+	}
+	set, err := setOf(elements...)
+	b.add(tag, set.FullBytes, err)
+}
+
+func (b *taggedSequence) marshal() (asn1.RawValue, error) {
+	if b.err != nil {
+		return asn1.RawValue{}, b.err
+	}
+	return sequenceOf(b.components...)
+}
+
+// Adds a SET OF `values`, as encoded by asn1.Marshal, unless there are none.
+func addSetOf[T any](b *taggedSequence, tag int, values []T) {
+	elements := make([][]byte, 0, len(values))
+	for _, v := range values {
+		encoded, err := asn1.Marshal(v)
+		if err != nil {
+			b.add(tag, nil, err)
+			return
+		}
+		elements = append(elements, encoded)
+	}
+	b.addSet(tag, elements)
+}
+
+// Encodes `u` as UserClasses.
+func (u *UserClassesNode) Marshal() (asn1.RawValue, error) {
+	var b taggedSequence
+	b.addNull(0, u.AllUsers)
+	b.addNull(1, u.ThisEntry)
+	addSetOf(&b, 2, u.Name)
+	addSetOf(&b, 3, u.UserGroup)
+	subtrees := make([][]byte, 0, len(u.Subtree))
+	for _, subtree := range u.Subtree {
+		encoded, err := subtree.MarshalRawValue()
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		subtrees = append(subtrees, encoded.FullBytes)
+	}
+	b.addSet(4, subtrees)
+	return b.marshal()
+}
+
+// Encodes `p` as ProtectedItems.
+func (p *ProtectedItemsNode) Marshal() (asn1.RawValue, error) {
+	var b taggedSequence
+	b.addNull(0, p.Entry)
+	b.addNull(1, p.AllUserAttributeTypes)
+	addSetOf(&b, 2, p.AttributeType)
+	addSetOf(&b, 3, p.AllAttributeValues)
+	b.addNull(4, p.AllUserAttributeTypesAndValues)
+	type attributeTypeAndValue struct {
+		Type  asn1.ObjectIdentifier
+		Value asn1.RawValue
+	}
+	atavs := make([]attributeTypeAndValue, 0, len(p.AttributeValue))
+	for _, atav := range p.AttributeValue {
+		value, err := dnRawValue(atav.Value)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		atavs = append(atavs, attributeTypeAndValue{atav.Type, value})
+	}
+	addSetOf(&b, 5, atavs)
+	addSetOf(&b, 6, p.SelfValue)
+	if p.RangeOfValues != nil {
+		filter, err := p.RangeOfValues.Marshal()
+		b.add(7, encodingOf(filter), err)
+	}
+	addSetOf(&b, 8, p.MaxValueCount)
+	if p.MaxImmSub != nil {
+		encoded, err := asn1.Marshal(*p.MaxImmSub)
+		b.add(9, encoded, err)
+	}
+	addSetOf(&b, 10, p.RestrictedBy)
+	addSetOf(&b, 11, p.Contexts)
+	if p.Classes != nil {
+		refinement, err := p.Classes.Marshal()
+		b.add(12, encodingOf(refinement), err)
+	}
+	return b.marshal()
+}
+
+func (n *ACIItemNode) Marshal() (ACIItem, error) {
+	if n.AuthenticationLevel == nil {
+		return ACIItem{}, errors.New("the other authentication level cannot be marshalled")
+	}
+	level, err := marshalRawValue(*n.AuthenticationLevel, "")
+	if err != nil {
+		return ACIItem{}, err
+	}
+	permissions := make([][]byte, 0, len(n.Permissions))
+	for _, permission := range n.Permissions {
+		var components [][]byte
+		if permission.Precedence != nil {
+			precedence, err := asn1.Marshal(*permission.Precedence)
+			if err != nil {
+				return ACIItem{}, err
+			}
+			components = append(components, precedence)
+		}
+		var second asn1.RawValue
+		if n.UserFirst {
+			if permission.ProtectedItems == nil {
+				return ACIItem{}, errors.New("user permission has no protected items")
+			}
+			second, err = permission.ProtectedItems.Marshal()
+		} else {
+			if permission.UserClasses == nil {
+				return ACIItem{}, errors.New("item permission has no user classes")
+			}
+			second, err = permission.UserClasses.Marshal()
+		}
+		if err != nil {
+			return ACIItem{}, err
+		}
+		grantsAndDenials, err := asn1.Marshal(permission.GrantsAndDenials)
+		if err != nil {
+			return ACIItem{}, err
+		}
+		encoded, err := sequenceOf(append(components, second.FullBytes, grantsAndDenials)...)
+		if err != nil {
+			return ACIItem{}, err
+		}
+		permissions = append(permissions, encoded.FullBytes)
+	}
+	set, err := setOf(permissions...)
+	if err != nil {
+		return ACIItem{}, err
+	}
+	var first asn1.RawValue
+	if n.UserFirst {
+		if n.UserClasses == nil {
+			return ACIItem{}, errors.New("userFirst item has no user classes")
+		}
+		first, err = n.UserClasses.Marshal()
+	} else {
+		if n.ProtectedItems == nil {
+			return ACIItem{}, errors.New("itemFirst item has no protected items")
+		}
+		first, err = n.ProtectedItems.Marshal()
+	}
+	if err != nil {
+		return ACIItem{}, err
+	}
+	choice, err := sequenceOf(first.FullBytes, set.FullBytes)
+	if err != nil {
+		return ACIItem{}, err
+	}
+	tag := 0
+	if n.UserFirst {
+		tag = 1
+	}
+	itemOrUserFirst, err := contextTagged(tag, choice.FullBytes)
+	if err != nil {
+		return ACIItem{}, err
+	}
+	tagValue, err := marshalRawValue(NewDirectoryString(n.IdentificationTag), "")
+	if err != nil {
+		return ACIItem{}, err
+	}
+	return ACIItem{
+		IdentificationTag:   tagValue,
+		Precedence:          n.Precedence,
+		AuthenticationLevel: level,
+		ItemOrUserFirst:     itemOrUserFirst,
+	}, nil
+}
+
+// Decodes an ACIItem. The authentication level of the result is nil if it is
+// the `other` alternative.
+func DecodeACIItemNode(item ACIItem) (*ACIItemNode, error) {
+	identificationTag, err := DirectoryStringToString(item.IdentificationTag)
+	if err != nil {
+		return nil, err
+	}
+	level, err := decodeAuthenticationLevel(item.AuthenticationLevel)
+	if err != nil {
+		return nil, err
+	}
+	choice := item.ItemOrUserFirst
+	if choice.Class != asn1.ClassContextSpecific || !choice.IsCompound || choice.Tag > 1 {
+		return nil, errors.New("invalid itemOrUserFirst")
+	}
+	inner, err := explicitlyTaggedValue(choice)
+	if err != nil {
+		return nil, err
+	}
+	components, err := sequenceComponents(inner)
+	if err != nil {
+		return nil, err
+	}
+	if len(components) < 2 {
+		return nil, errors.New("invalid itemOrUserFirst")
+	}
+	var permissions []asn1.RawValue
+	if err := unmarshalSetOf(components[1], &permissions); err != nil {
+		return nil, err
+	}
+	n := &ACIItemNode{
+		IdentificationTag:   identificationTag,
+		Precedence:          item.Precedence,
+		AuthenticationLevel: level,
+		UserFirst:           choice.Tag == 1,
+		Permissions:         make([]ACIPermissionNode, 0, len(permissions)),
+	}
+	if n.UserFirst {
+		n.UserClasses, err = decodeUserClasses(components[0])
+	} else {
+		n.ProtectedItems, err = decodeProtectedItems(components[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, encoded := range permissions {
+		var permission ACIPermissionNode
+		var second asn1.RawValue
+		permission.Precedence, second, permission.GrantsAndDenials, err = decodePermission(encoded)
+		if err != nil {
+			return nil, err
+		}
+		if n.UserFirst {
+			permission.ProtectedItems, err = decodeProtectedItems(second)
+		} else {
+			permission.UserClasses, err = decodeUserClasses(second)
+		}
+		if err != nil {
+			return nil, err
+		}
+		n.Permissions = append(n.Permissions, permission)
+	}
+	return n, nil
+}
+
+// Returns one tuple for each of the permissions of `n`, as described in
+// ITU-T Recommendation X.501, Section 18.8.2.
+func (n *ACIItemNode) Tuples() []ACITuple {
+	tuples := make([]ACITuple, 0, len(n.Permissions))
+	for _, permission := range n.Permissions {
+		tuple := ACITuple{
+			AuthenticationLevel: n.AuthenticationLevel,
+			GrantsAndDenials:    permission.GrantsAndDenials,
+			Precedence:          n.Precedence,
+		}
+		if permission.Precedence != nil {
+			tuple.Precedence = *permission.Precedence
+		}
+		users, items := n.UserClasses, permission.ProtectedItems
+		if !n.UserFirst {
+			users, items = permission.UserClasses, n.ProtectedItems
+		}
+		if users != nil {
+			tuple.UserClasses = *users
+		}
+		if items != nil {
+			tuple.ProtectedItems = *items
+		}
+		tuples = append(tuples, tuple)
+	}
+	return tuples
+}
+
+// Decodes `item` into one tuple for each of its item or user permissions.
+// See [ACIItemNode.Tuples].
+func DecodeACIItem(item ACIItem) ([]ACITuple, error) {
+	n, err := DecodeACIItemNode(item)
+	if err != nil {
+		return nil, err
+	}
+	return n.Tuples(), nil
+}
+
+// A request to exercise a permission on an item of an entry.
+type AccessRequest struct {
+	// The name of the requester, which is empty for an anonymous requester.
+	Requester           NameAndOptionalUID
+	AuthenticationLevel AuthenticationLevel_basicLevels
+
+	// The bit of GrantsAndDenials that grants the permission, such as
+	// [GrantsAndDenials_GrantRead].
+	Permission int
+
+	Entry DistinguishedName
+
+	// The attributes of the entry. When adding values, these are the
+	// attributes as they would be if the values were added.
+	EntryAttributes []Attribute
+
+	// The attribute type of the protected item, or nil if the protected item
+	// is the entry itself.
+	AttributeType AttributeType
+
+	// The attribute value that is the protected item, and its contexts, or
+	// nil if the protected item is the attribute type itself.
+	Value         *asn1.RawValue
+	ValueContexts []Context
+
+	// When adding or importing an entry, the number of immediate subordinates
+	// its superior would have if it were added.
+	Subordinates int
+}
+
+// The specificity of user classes, from least to most specific.
+const (
+	userClassAllUsers = iota + 1
+	userClassSubtree
+	userClassUserGroup
+	userClassName
+)
+
+// The specificity of protected items, from least to most specific.
+const (
+	protectedItemAllUser = iota + 1
+	protectedItemType
+	protectedItemValue
+)
+
+// Returns true if a requester who authenticated at level `l` has the
+// authentication level `required`.
+func (l AuthenticationLevel_basicLevels) satisfies(required *AuthenticationLevel_basicLevels) bool {
+	if required == nil || (required.Signed && !l.Signed) {
+		return false
+	}
+	if l.Level != required.Level {
+		return l.Level > required.Level
+	}
+	return l.LocalQualifier >= required.LocalQualifier
+}
+
+// Decides whether requesters may exercise permissions on the entries of a
+// DSA, as the access control decision function of Basic Access Control in
+// ITU-T Recommendation X.501, Section 18.8. Attribute types are resolved
+// using `Schema`, and values compared using `MatchingRules`; if either is
+// nil, [DefaultSchemaRegistry] or [DefaultMatchingRuleRegistry] is used. A
+// nil *AccessControlDecider may be used.
+type AccessControlDecider struct {
+	Schema        *SchemaRegistry
+	MatchingRules *MatchingRuleRegistry
+
+	// Returns true if `user` is a member of the group `group`. If nil, no
+	// requester is a member of any group.
+	IsGroupMember func(group, user NameAndOptionalUID) (bool, error)
+}
+
+func (d *AccessControlDecider) schema() *SchemaRegistry {
+	if d == nil || d.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return d.Schema
+}
+
+func (d *AccessControlDecider) matchingRules() *MatchingRuleRegistry {
+	if d == nil || d.MatchingRules == nil {
+		return DefaultMatchingRuleRegistry()
+	}
+	return d.MatchingRules
+}
+
+func (d *AccessControlDecider) dnMatcher() *DNMatcher {
+	if d == nil {
+		return nil
+	}
+	return &DNMatcher{Schema: d.Schema, MatchingRules: d.MatchingRules}
+}
+
+func nameAndUIDMatch(m *DNMatcher, name, user NameAndOptionalUID) bool {
+	if !m.DNEqual(name.Dn, user.Dn) {
+		return false
+	}
+	return name.Uid.BitLength == 0 || (name.Uid.BitLength == user.Uid.BitLength && bytes.Equal(name.Uid.Bytes, user.Uid.Bytes))
+}
+
+// Returns the specificity of the most specific of `u` that includes the
+// requester, or zero if none does. An anonymous requester is only included
+// in allUsers. The specificationFilter of a subtree is ignored, since the
+// object classes of the requester's entry are not known.
+func (d *AccessControlDecider) userClassSpecificity(u *UserClassesNode, req *AccessRequest) (int, error) {
+	if len(req.Requester.Dn) > 0 {
+		m := d.dnMatcher()
+		if u.ThisEntry && m.DNEqual(req.Requester.Dn, req.Entry) {
+			return userClassName, nil
+		}
+		for _, name := range u.Name {
+			if nameAndUIDMatch(m, name, req.Requester) {
+				return userClassName, nil
+			}
+		}
+		if d != nil && d.IsGroupMember != nil {
+			for _, group := range u.UserGroup {
+				member, err := d.IsGroupMember(group, req.Requester)
+				if err != nil {
+					return 0, err
+				}
+				if member {
+					return userClassUserGroup, nil
+				}
+			}
+		}
+		for _, subtree := range u.Subtree {
+			unfiltered := *subtree
+			unfiltered.SpecificationFilter = nil
+			if m.SubtreeContains(&unfiltered, nil, req.Requester.Dn, nil) {
+				return userClassSubtree, nil
+			}
+		}
+	}
+	if u.AllUsers {
+		return userClassAllUsers, nil
+	}
+	return 0, nil
+}
+
+// Returns the specificity of the most specific of `u`, regardless of whether
+// it includes the requester.
+func (u *UserClassesNode) specificity() int {
+	switch {
+	case u.ThisEntry || len(u.Name) > 0:
+		return userClassName
+	case len(u.UserGroup) > 0:
+		return userClassUserGroup
+	case len(u.Subtree) > 0:
+		return userClassSubtree
+	case u.AllUsers:
+		return userClassAllUsers
+	}
+	return 0
+}
+
+func objectClassesOf(attrs []Attribute) []asn1.ObjectIdentifier {
+	var objectClasses []asn1.ObjectIdentifier
+	add := func(value asn1.RawValue) {
+		var oc asn1.ObjectIdentifier
+		if unmarshalExactly(value, &oc) == nil {
+			objectClasses = append(objectClasses, oc)
+		}
+	}
+	for _, attr := range attrs {
+		if !attr.Type.Equal(Id_at_objectClass) {
+			continue
+		}
+		for _, v := range attr.Values {
+			add(v)
+		}
+		for _, v := range attr.ValuesWithContext {
+			add(v.Value)
+		}
+	}
+	return objectClasses
+}
+
+// Returns true unless `attrType` is known to be an operational attribute
+// type.
+func (d *AccessControlDecider) isUserAttributeType(attrType AttributeType) bool {
+	at, err := d.schema().ResolveAttributeType(attrType.String())
+	return err != nil || at.Usage == AttributeUsage_UserApplications
+}
+
+func containsAttributeType(types []AttributeType, attrType AttributeType) bool {
+	for _, t := range types {
+		if t.Equal(attrType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if `value` is a distinguished name, or a NameAndOptionalUID,
+// that names the requester.
+func (d *AccessControlDecider) namesRequester(value asn1.RawValue, req *AccessRequest) bool {
+	if len(req.Requester.Dn) == 0 {
+		return false
+	}
+	var name NameAndOptionalUID
+	if unmarshalExactly(value, &name.Dn) != nil && unmarshalExactly(value, &name) != nil {
+		return false
+	}
+	return nameAndUIDMatch(d.dnMatcher(), name, req.Requester)
+}
+
+// Returns the specificity of the most specific of `p` that includes the
+// protected item of `req`, or zero if none does.
+func (d *AccessControlDecider) protectedItemSpecificity(p *ProtectedItemsNode, req *AccessRequest) int {
+	if p.Classes != nil && !p.Classes.Evaluate(objectClassesOf(req.EntryAttributes)) {
+		return 0
+	}
+	if req.AttributeType == nil {
+		if p.Entry {
+			return protectedItemAllUser
+		}
+		return 0
+	}
+	if req.Value == nil {
+		if containsAttributeType(p.AttributeType, req.AttributeType) {
+			return protectedItemType
+		}
+		if (p.AllUserAttributeTypes || p.AllUserAttributeTypesAndValues) && d.isUserAttributeType(req.AttributeType) {
+			return protectedItemAllUser
+		}
+		return 0
+	}
+	for _, assertion := range p.Contexts {
+		if !contextAssertionHolds(assertion, req.ValueContexts, false) {
+			return 0
+		}
+	}
+	equal := d.matchingRules().ValueEqual(d.schema())
+	for _, atav := range p.AttributeValue {
+		if !atav.Type.Equal(req.AttributeType) {
+			continue
+		}
+		value, err := dnRawValue(atav.Value)
+		if err == nil && equal(req.AttributeType, value, *req.Value) {
+			return protectedItemValue
+		}
+	}
+	if containsAttributeType(p.SelfValue, req.AttributeType) && d.namesRequester(*req.Value, req) {
+		return protectedItemValue
+	}
+	if containsAttributeType(p.AllAttributeValues, req.AttributeType) {
+		return protectedItemType
+	}
+	if p.RangeOfValues != nil {
+		// The filter is evaluated against an entry containing only the value.
+		attr := Attribute{Type: req.AttributeType}
+		if len(req.ValueContexts) > 0 {
+			attr.ValuesWithContext = []Attribute_valuesWithContext_Item{{Value: *req.Value, ContextList: req.ValueContexts}}
+		} else {
+			attr.Values = []asn1.RawValue{*req.Value}
+		}
+		evaluator := &FilterEvaluator{Schema: d.schema(), MatchingRules: d.matchingRules()}
+		if evaluator.Evaluate(p.RangeOfValues, []Attribute{attr}) == FilterTrue {
+			return protectedItemType
+		}
+	}
+	if p.AllUserAttributeTypesAndValues && d.isUserAttributeType(req.AttributeType) {
+		return protectedItemAllUser
+	}
+	return 0
+}
+
+// Returns false if maxValueCount, maxImmSub or restrictedBy prevent `p` from
+// granting the permission of `req`.
+func (d *AccessControlDecider) grantRestrictionsHold(p *ProtectedItemsNode, req *AccessRequest) bool {
+	adding := req.Permission == GrantsAndDenials_GrantAdd
+	if req.AttributeType == nil {
+		importing := req.Permission == GrantsAndDenials_GrantImport
+		return p.MaxImmSub == nil || !(adding || importing) || req.Subordinates <= *p.MaxImmSub
+	}
+	if req.Value == nil || !adding {
+		return true
+	}
+	for _, limit := range p.MaxValueCount {
+		if !limit.Type.Equal(req.AttributeType) {
+			continue
+		}
+		count := 0
+		for _, attr := range req.EntryAttributes {
+			if attr.Type.Equal(limit.Type) {
+				count += len(attr.Values) + len(attr.ValuesWithContext)
+			}
+		}
+		if count > limit.MaxCount {
+			return false
+		}
+	}
+	equal := d.matchingRules().ValueEqual(d.schema())
+	for _, restriction := range p.RestrictedBy {
+		if !restriction.Type.Equal(req.AttributeType) {
+			continue
+		}
+		present := false
+		for _, attr := range req.EntryAttributes {
+			if !attr.Type.Equal(restriction.ValuesIn) {
+				continue
+			}
+			for _, v := range attr.Values {
+				present = present || equal(req.AttributeType, v, *req.Value)
+			}
+			for _, v := range attr.ValuesWithContext {
+				present = present || equal(req.AttributeType, v.Value, *req.Value)
+			}
+		}
+		if !present {
+			return false
+		}
+	}
+	return true
+}
+
+// Decides whether `req` is permitted by the ACI items `items`, which are the
+// prescriptiveACI of the subentries that apply to the entry, the subentryACI
+// of its administrative point if the entry is a subentry, and the entryACI of
+// the entry. See [AccessControlDecider.DecideTuples].
+func (d *AccessControlDecider) Decide(items []ACIItem, req *AccessRequest) (bool, error) {
+	var tuples []ACITuple
+	for _, item := range items {
+		itemTuples, err := DecodeACIItem(item)
+		if err != nil {
+			return false, err
+		}
+		tuples = append(tuples, itemTuples...)
+	}
+	return d.DecideTuples(tuples, req)
+}
+
+// Decides whether `req` is permitted by `tuples`, as described in ITU-T
+// Recommendation X.501, Section 18.8.3:
+//
+//  1. Tuples that neither grant nor deny the permission are discarded.
+//  2. Tuples whose user classes do not include the requester are discarded.
+//     A requester who has not authenticated at the authentication level of a
+//     tuple is not granted access by it, but is denied access by it as
+//     though they were in its most specific user class.
+//  3. Tuples whose protected items do not include the item are discarded,
+//     as are grants prevented by maxValueCount, maxImmSub or restrictedBy.
+//  4. Tuples of less than the highest precedence are discarded.
+//  5. Tuples with less specific user classes are discarded: thisEntry and
+//     name are most specific, followed by userGroup, subtree and allUsers.
+//  6. Tuples with less specific protected items are discarded: attributeValue
+//     and selfValue are most specific, followed by attributeType,
+//     allAttributeValues and rangeOfValues, and then allUserAttributeTypes
+//     and allUserAttributeTypesAndValues.
+//
+// Access is permitted if any tuples remain and none of them deny it.
+func (d *AccessControlDecider) DecideTuples(tuples []ACITuple, req *AccessRequest) (bool, error) {
+	if req.Permission < GrantsAndDenials_GrantAdd || req.Permission > GrantsAndDenials_GrantInvoke || req.Permission%2 != 0 {
+		return false, fmt.Errorf("invalid permission %d", req.Permission)
+	}
+	type candidate struct {
+		deny                 bool
+		precedence           Precedence
+		userClassSpecificity int
+		itemSpecificity      int
+	}
+	var candidates []candidate
+	for i := range tuples {
+		t := &tuples[i]
+		grants := t.GrantsAndDenials.At(req.Permission) == 1
+		denies := t.GrantsAndDenials.At(req.Permission+1) == 1
+		if !grants && !denies {
+			continue
+		}
+		userClassSpecificity, err := d.userClassSpecificity(&t.UserClasses, req)
+		if err != nil {
+			return false, err
+		}
+		if !req.AuthenticationLevel.satisfies(t.AuthenticationLevel) {
+			grants = false
+			if denies {
+				userClassSpecificity = t.UserClasses.specificity()
+			}
+		}
+		if userClassSpecificity == 0 {
+			continue
+		}
+		itemSpecificity := d.protectedItemSpecificity(&t.ProtectedItems, req)
+		if itemSpecificity == 0 {
+			continue
+		}
+		if !denies && !(grants && d.grantRestrictionsHold(&t.ProtectedItems, req)) {
+			continue
+		}
+		candidates = append(candidates, candidate{denies, t.Precedence, userClassSpecificity, itemSpecificity})
+	}
+	keepHighest := func(key func(c candidate) int) {
+		highest := 0
+		for _, c := range candidates {
+			highest = max(highest, key(c))
+		}
+		kept := candidates[:0]
+		for _, c := range candidates {
+			if key(c) == highest {
+				kept = append(kept, c)
+			}
+		}
+		candidates = kept
+	}
+	keepHighest(func(c candidate) int { return c.precedence })
+	keepHighest(func(c candidate) int { return c.userClassSpecificity })
+	keepHighest(func(c candidate) int { return c.itemSpecificity })
+	for _, c := range candidates {
+		if c.deny {
+			return false, nil
+		}
+	}
+	return len(candidates) > 0, nil
+}
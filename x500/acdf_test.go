@@ -0,0 +1,376 @@
+package x500
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"testing"
+)
+
+func testGrantsAndDenials(bits ...int) GrantsAndDenials {
+	g := asn1.BitString{Bytes: make([]byte, 4), BitLength: 26}
+	for _, bit := range bits {
+		g.Bytes[bit/8] |= 0x80 >> (bit % 8)
+	}
+	return g
+}
+
+func testSetOf(t *testing.T, components ...[]byte) []byte {
+	t.Helper()
+	set, err := marshalRawValue(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      bytes.Join(components, nil),
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set.FullBytes
+}
+
+func mustContextTagged(t *testing.T, tag int, contents ...[]byte) []byte {
+	t.Helper()
+	tagged, err := contextTagged(tag, contents...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tagged.FullBytes
+}
+
+func mustSequenceOf(t *testing.T, components ...[]byte) []byte {
+	t.Helper()
+	seq, err := sequenceOf(components...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return seq.FullBytes
+}
+
+var simpleLevel = &AuthenticationLevel_basicLevels{Level: AuthenticationLevel_basicLevels_level_Simple}
+
+func TestDecodeACIItem(t *testing.T) {
+	alice := mustParseDN(t, "CN=Alice,O=Example,C=US")
+	commonName := mustMarshalValue(t, Id_at_commonName, "")
+	name := mustMarshalValue(t, NameAndOptionalUID{Dn: alice}, "")
+	grantRead := mustMarshalValue(t, testGrantsAndDenials(GrantsAndDenials_GrantRead), "")
+	denyRemove := mustMarshalValue(t, testGrantsAndDenials(GrantsAndDenials_DenyRemove), "")
+	itemFirst := mustContextTagged(t, 0, mustSequenceOf(t,
+		mustSequenceOf(t,
+			mustContextTagged(t, 0, asn1.NullBytes),
+			mustContextTagged(t, 2, testSetOf(t, commonName.FullBytes)),
+			mustContextTagged(t, 9, mustMarshalValue(t, 3, "").FullBytes),
+		),
+		testSetOf(t,
+			mustSequenceOf(t,
+				mustSequenceOf(t, mustContextTagged(t, 0, asn1.NullBytes)),
+				grantRead.FullBytes,
+			),
+			mustSequenceOf(t,
+				mustMarshalValue(t, 20, "").FullBytes,
+				mustSequenceOf(t, mustContextTagged(t, 2, testSetOf(t, name.FullBytes))),
+				denyRemove.FullBytes,
+			),
+		),
+	))
+	encoded, err := asn1.Marshal(ACIItem{
+		IdentificationTag:   NewDirectoryString("test"),
+		Precedence:          10,
+		AuthenticationLevel: mustMarshalValue(t, *simpleLevel, ""),
+		ItemOrUserFirst:     asn1.RawValue{FullBytes: itemFirst},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var item ACIItem
+	if _, err := asn1.Unmarshal(encoded, &item); err != nil {
+		t.Error(err)
+		return
+	}
+	tuples, err := DecodeACIItem(item)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(tuples) != 2 {
+		t.Errorf("expected 2 tuples, got %d", len(tuples))
+		return
+	}
+	for _, tuple := range tuples {
+		items := tuple.ProtectedItems
+		if !items.Entry || len(items.AttributeType) != 1 || !items.AttributeType[0].Equal(Id_at_commonName) {
+			t.Errorf("unexpected protected items: %+v", items)
+		}
+		if items.MaxImmSub == nil || *items.MaxImmSub != 3 {
+			t.Error("maxImmSub was not decoded")
+		}
+		if tuple.AuthenticationLevel == nil || tuple.AuthenticationLevel.Level != AuthenticationLevel_basicLevels_level_Simple {
+			t.Error("authentication level was not decoded")
+		}
+	}
+	if !tuples[0].UserClasses.AllUsers || tuples[0].Precedence != 10 || tuples[0].GrantsAndDenials.At(GrantsAndDenials_GrantRead) != 1 {
+		t.Errorf("unexpected first tuple: %+v", tuples[0])
+	}
+	second := tuples[1]
+	if second.Precedence != 20 || len(second.UserClasses.Name) != 1 || !DNEqual(second.UserClasses.Name[0].Dn, alice) {
+		t.Errorf("unexpected second tuple: %+v", second)
+	}
+	if second.GrantsAndDenials.At(GrantsAndDenials_DenyRemove) != 1 {
+		t.Error("grants and denials were not decoded")
+	}
+
+	// The same permissions, with the users first.
+	userFirst := mustContextTagged(t, 1, mustSequenceOf(t,
+		mustSequenceOf(t, mustContextTagged(t, 0, asn1.NullBytes)),
+		testSetOf(t, mustSequenceOf(t,
+			mustSequenceOf(t, mustContextTagged(t, 1, asn1.NullBytes)),
+			grantRead.FullBytes,
+		)),
+	))
+	item.ItemOrUserFirst = asn1.RawValue{}
+	if _, err := asn1.Unmarshal(userFirst, &item.ItemOrUserFirst); err != nil {
+		t.Error(err)
+		return
+	}
+	req := &AccessRequest{
+		Requester:           NameAndOptionalUID{Dn: alice},
+		AuthenticationLevel: *simpleLevel,
+		Permission:          GrantsAndDenials_GrantRead,
+		Entry:               alice,
+		AttributeType:       Id_at_commonName,
+	}
+	permitted, err := (*AccessControlDecider)(nil).Decide([]ACIItem{item}, req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !permitted {
+		t.Error("allUsers should have been granted read access to all user attribute types")
+	}
+}
+
+func TestDecideTuples(t *testing.T) {
+	alice := NameAndOptionalUID{Dn: mustParseDN(t, "CN=Alice,OU=People,O=Example,C=US")}
+	bob := NameAndOptionalUID{Dn: mustParseDN(t, "CN=Bob,OU=People,O=Example,C=US")}
+	admins := NameAndOptionalUID{Dn: mustParseDN(t, "CN=Admins,O=Example,C=US")}
+	entry := mustParseDN(t, "CN=Printer,O=Example,C=US")
+	attrs := testEntryAttributes(t)
+	d := &AccessControlDecider{
+		IsGroupMember: func(group, user NameAndOptionalUID) (bool, error) {
+			return DNEqual(group.Dn, admins.Dn) && DNEqual(user.Dn, bob.Dn), nil
+		},
+	}
+	jack := NewDirectoryString("Jack")
+	jackRequest := func(requester NameAndOptionalUID, permission int) *AccessRequest {
+		return &AccessRequest{
+			Requester:           requester,
+			AuthenticationLevel: *simpleLevel,
+			Permission:          permission,
+			Entry:               entry,
+			EntryAttributes:     attrs,
+			AttributeType:       Id_at_commonName,
+			Value:               &jack,
+		}
+	}
+	allValues := ProtectedItemsNode{AllUserAttributeTypesAndValues: true}
+	tuples := []ACITuple{
+		{
+			UserClasses:         UserClassesNode{AllUsers: true},
+			AuthenticationLevel: simpleLevel,
+			ProtectedItems:      allValues,
+			GrantsAndDenials:    testGrantsAndDenials(GrantsAndDenials_GrantRead, GrantsAndDenials_GrantCompare),
+			Precedence:          10,
+		},
+		{
+			UserClasses:         UserClassesNode{Subtree: []*SubtreeSpecificationNode{{Base: mustParseDN(t, "OU=People,O=Example,C=US")}}},
+			AuthenticationLevel: simpleLevel,
+			ProtectedItems:      allValues,
+			GrantsAndDenials:    testGrantsAndDenials(GrantsAndDenials_DenyCompare),
+			Precedence:          10,
+		},
+		{
+			UserClasses:         UserClassesNode{UserGroup: []NameAndOptionalUID{admins}},
+			AuthenticationLevel: simpleLevel,
+			ProtectedItems:      allValues,
+			GrantsAndDenials:    testGrantsAndDenials(GrantsAndDenials_GrantCompare),
+			Precedence:          10,
+		},
+		{
+			UserClasses:         UserClassesNode{AllUsers: true},
+			AuthenticationLevel: simpleLevel,
+			ProtectedItems:      ProtectedItemsNode{AttributeValue: []pkix.AttributeTypeAndValue{{Type: Id_at_commonName, Value: jack}}},
+			GrantsAndDenials:    testGrantsAndDenials(GrantsAndDenials_DenyRead),
+			Precedence:          10,
+		},
+	}
+	for _, test := range []struct {
+		name      string
+		req       *AccessRequest
+		permitted bool
+	}{
+		// The specific value is more specific than all values.
+		{"read", jackRequest(alice, GrantsAndDenials_GrantRead), false},
+		// Membership of a group is more specific than a subtree.
+		{"group compare", jackRequest(bob, GrantsAndDenials_GrantCompare), true},
+		{"subtree compare", jackRequest(alice, GrantsAndDenials_GrantCompare), false},
+		{"anonymous compare", jackRequest(NameAndOptionalUID{}, GrantsAndDenials_GrantCompare), true},
+		{"no tuples", jackRequest(alice, GrantsAndDenials_GrantRemove), false},
+	} {
+		permitted, err := d.DecideTuples(tuples, test.req)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if permitted != test.permitted {
+			t.Errorf("%s: expected %v", test.name, test.permitted)
+		}
+	}
+
+	// A higher precedence outweighs specificity.
+	tuples[0].Precedence = 20
+	if permitted, err := d.DecideTuples(tuples, jackRequest(alice, GrantsAndDenials_GrantRead)); err != nil || !permitted {
+		t.Errorf("the tuple of higher precedence should have granted access: %v", err)
+	}
+
+	_, err := d.DecideTuples(tuples, jackRequest(alice, GrantsAndDenials_DenyRead))
+	if err == nil {
+		t.Error("a denial bit should not be accepted as a permission")
+	}
+	d.IsGroupMember = func(group, user NameAndOptionalUID) (bool, error) {
+		return false, errors.New("directory unavailable")
+	}
+	if _, err := d.DecideTuples(tuples, jackRequest(bob, GrantsAndDenials_GrantCompare)); err == nil {
+		t.Error("the error of the group membership callback should have been returned")
+	}
+}
+
+func TestDecideAuthenticationLevel(t *testing.T) {
+	alice := NameAndOptionalUID{Dn: mustParseDN(t, "CN=Alice,O=Example,C=US")}
+	bob := NameAndOptionalUID{Dn: mustParseDN(t, "CN=Bob,O=Example,C=US")}
+	strong := &AuthenticationLevel_basicLevels{Level: AuthenticationLevel_basicLevels_level_Strong}
+	tuples := []ACITuple{
+		{
+			UserClasses:         UserClassesNode{AllUsers: true},
+			AuthenticationLevel: simpleLevel,
+			ProtectedItems:      ProtectedItemsNode{Entry: true},
+			GrantsAndDenials:    testGrantsAndDenials(GrantsAndDenials_GrantBrowse, GrantsAndDenials_GrantModify),
+		},
+		{
+			UserClasses:         UserClassesNode{Name: []NameAndOptionalUID{bob}},
+			AuthenticationLevel: strong,
+			ProtectedItems:      ProtectedItemsNode{Entry: true},
+			GrantsAndDenials:    testGrantsAndDenials(GrantsAndDenials_DenyBrowse),
+		},
+		{
+			UserClasses:         UserClassesNode{ThisEntry: true},
+			AuthenticationLevel: strong,
+			ProtectedItems:      ProtectedItemsNode{Entry: true},
+			GrantsAndDenials:    testGrantsAndDenials(GrantsAndDenials_GrantRename),
+		},
+	}
+	req := &AccessRequest{
+		Requester:           alice,
+		AuthenticationLevel: *simpleLevel,
+		Permission:          GrantsAndDenials_GrantModify,
+		Entry:               alice.Dn,
+	}
+	for _, test := range []struct {
+		permission int
+		level      *AuthenticationLevel_basicLevels
+		permitted  bool
+	}{
+		{GrantsAndDenials_GrantModify, simpleLevel, true},
+		// Alice could be Bob, as far as the DSA knows.
+		{GrantsAndDenials_GrantBrowse, simpleLevel, false},
+		{GrantsAndDenials_GrantBrowse, strong, true},
+		{GrantsAndDenials_GrantRename, simpleLevel, false},
+		{GrantsAndDenials_GrantRename, strong, true},
+	} {
+		req.Permission = test.permission
+		req.AuthenticationLevel = *test.level
+		permitted, err := (*AccessControlDecider)(nil).DecideTuples(tuples, req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if permitted != test.permitted {
+			t.Errorf("permission %d at level %d: expected %v", test.permission, test.level.Level, test.permitted)
+		}
+	}
+}
+
+func TestDecideGrantRestrictions(t *testing.T) {
+	alice := NameAndOptionalUID{Dn: mustParseDN(t, "CN=Alice,O=Example,C=US")}
+	limit := 2
+	tuples := []ACITuple{
+		{
+			UserClasses:         UserClassesNode{AllUsers: true},
+			AuthenticationLevel: simpleLevel,
+			ProtectedItems: ProtectedItemsNode{
+				Entry:              true,
+				AllAttributeValues: []AttributeType{Id_at_commonName, Id_at_description},
+				MaxImmSub:          &limit,
+				MaxValueCount:      []MaxValueCount{{Type: Id_at_commonName, MaxCount: 2}},
+				RestrictedBy:       []RestrictedValue{{Type: Id_at_description, ValuesIn: Id_at_commonName}},
+			},
+			GrantsAndDenials: testGrantsAndDenials(GrantsAndDenials_GrantAdd),
+		},
+		{
+			UserClasses:         UserClassesNode{AllUsers: true},
+			AuthenticationLevel: simpleLevel,
+			ProtectedItems: ProtectedItemsNode{
+				RangeOfValues: mustParseFilter(t, "(surname=Sm*)"),
+				SelfValue:     []AttributeType{Id_at_member},
+			},
+			GrantsAndDenials: testGrantsAndDenials(GrantsAndDenials_GrantAdd),
+		},
+	}
+	attrs := testEntryAttributes(t) // Which have two common names.
+	value := func(v asn1.RawValue) *asn1.RawValue {
+		return &v
+	}
+	for _, test := range []struct {
+		name            string
+		attrType        AttributeType
+		value           *asn1.RawValue
+		subordinates    int
+		permitted       bool
+		extraCommonName bool
+	}{
+		{"entry", nil, nil, 2, true, false},
+		{"too many subordinates", nil, nil, 3, false, false},
+		{"common name", Id_at_commonName, value(NewDirectoryString("Jack")), 0, true, false},
+		{"too many common names", Id_at_commonName, value(NewDirectoryString("Jill")), 0, false, true},
+		{"description in common names", Id_at_description, value(NewDirectoryString("jack")), 0, true, false},
+		{"description not in common names", Id_at_description, value(NewDirectoryString("Jill")), 0, false, false},
+		{"range of values", Id_at_surname, value(NewDirectoryString("Smythe")), 0, true, false},
+		{"outside range of values", Id_at_surname, value(NewDirectoryString("Jones")), 0, false, false},
+		{"self value", Id_at_member, value(mustMarshalValue(t, alice.Dn, "")), 0, true, false},
+		{"other value", Id_at_member, value(mustMarshalValue(t, mustParseDN(t, "CN=Bob,O=Example,C=US"), "")), 0, false, false},
+	} {
+		entryAttrs := attrs
+		if test.extraCommonName {
+			entryAttrs = append(append([]Attribute{}, attrs...), Attribute{Type: Id_at_commonName, Values: []asn1.RawValue{NewDirectoryString("Jill")}})
+		}
+		req := &AccessRequest{
+			Requester:           alice,
+			AuthenticationLevel: *simpleLevel,
+			Permission:          GrantsAndDenials_GrantAdd,
+			Entry:               mustParseDN(t, "CN=Printer,O=Example,C=US"),
+			EntryAttributes:     entryAttrs,
+			AttributeType:       test.attrType,
+			Value:               test.value,
+			Subordinates:        test.subordinates,
+		}
+		permitted, err := (*AccessControlDecider)(nil).DecideTuples(tuples, req)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if permitted != test.permitted {
+			t.Errorf("%s: expected %v", test.name, test.permitted)
+		}
+	}
+}
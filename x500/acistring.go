@@ -0,0 +1,1297 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Options for parsing and formatting values in the Generic String Encoding
+// Rules (GSER) of IETF RFC 3641, such as ACI items.
+type GSEROptions struct {
+	// Used to resolve attribute type, object class and context type names,
+	// and to parse and format the distinguished names and filters within
+	// values. If nil, [DefaultSchemaRegistry] is used.
+	Schema *SchemaRegistry
+
+	// If not empty, values are formatted with each component on its own
+	// line, indented by `Indent` for each level of nesting.
+	Indent string
+}
+
+func (o *GSEROptions) schema() *SchemaRegistry {
+	if o == nil || o.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return o.Schema
+}
+
+func (o *GSEROptions) indent() string {
+	if o == nil {
+		return ""
+	}
+	return o.Indent
+}
+
+// An error in a value in the Generic String Encoding Rules. The position of
+// the error is given as a byte offset, and as a line and column, counting
+// characters from one.
+type GSERSyntaxError struct {
+	Offset  int
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *GSERSyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+type gserParser struct {
+	s      string
+	pos    int
+	schema *SchemaRegistry
+}
+
+func (p *gserParser) errorAt(offset int, format string, args ...any) error {
+	line, column := 1, 1
+	for _, r := range p.s[:offset] {
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &GSERSyntaxError{
+		Offset:  offset,
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *gserParser) errorf(format string, args ...any) error {
+	return p.errorAt(p.pos, format, args...)
+}
+
+func (p *gserParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *gserParser) skipSpaces() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *gserParser) expect(c byte) error {
+	p.skipSpaces()
+	if p.eof() || p.s[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+// Returns the next identifier, descriptor or numeric object identifier, and
+// its offset.
+func (p *gserParser) identifier() (string, int, error) {
+	p.skipSpaces()
+	start := p.pos
+	for !p.eof() && isAttributeTypeChar(p.s[p.pos]) {
+		p.pos++
+	}
+	if start == p.pos {
+		return "", start, p.errorf("expected an identifier")
+	}
+	return p.s[start:p.pos], start, nil
+}
+
+// Parses `name`, or returns an error positioned at the next token.
+func (p *gserParser) keyword(names ...string) (string, error) {
+	name, start, err := p.identifier()
+	if err != nil {
+		return "", err
+	}
+	for _, n := range names {
+		if strings.EqualFold(name, n) {
+			return n, nil
+		}
+	}
+	return "", p.errorAt(start, "expected %s, but got %q", strings.Join(names, " or "), name)
+}
+
+// Consumes the NULL that may follow the identifier of a NULL component.
+func (p *gserParser) optionalNull() {
+	save := p.pos
+	p.skipSpaces()
+	if strings.HasPrefix(p.s[p.pos:], "NULL") && (p.pos+4 == len(p.s) || !isAttributeTypeChar(p.s[p.pos+4])) {
+		p.pos += 4
+		return
+	}
+	p.pos = save
+}
+
+func (p *gserParser) parseString() (string, int, error) {
+	p.skipSpaces()
+	start := p.pos
+	if p.eof() || p.s[p.pos] != '"' {
+		return "", start, p.errorf("expected a string")
+	}
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", start, p.errorAt(start, "unterminated string")
+		}
+		c := p.s[p.pos]
+		p.pos++
+		if c == '"' {
+			if p.eof() || p.s[p.pos] != '"' {
+				return b.String(), start, nil
+			}
+			p.pos++
+		}
+		b.WriteByte(c)
+	}
+}
+
+func (p *gserParser) parseInteger() (int, error) {
+	p.skipSpaces()
+	start := p.pos
+	if !p.eof() && p.s[p.pos] == '-' {
+		p.pos++
+	}
+	for !p.eof() && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		return 0, p.errorAt(start, "expected an integer")
+	}
+	return n, nil
+}
+
+func (p *gserParser) parseBoolean() (bool, error) {
+	b, err := p.keyword("TRUE", "FALSE")
+	return b == "TRUE", err
+}
+
+// Parses a hexadecimal string, such as '0C02656E'H, containing a single BER
+// encoded value.
+func (p *gserParser) parseEncodedValue() (asn1.RawValue, error) {
+	p.skipSpaces()
+	start := p.pos
+	if p.eof() || p.s[p.pos] != '\'' {
+		return asn1.RawValue{}, p.errorf("expected a hexadecimal string")
+	}
+	end := strings.Index(p.s[p.pos+1:], "'H")
+	if end < 0 {
+		return asn1.RawValue{}, p.errorAt(start, "unterminated hexadecimal string")
+	}
+	encoded, err := hex.DecodeString(p.s[p.pos+1 : p.pos+1+end])
+	if err != nil {
+		return asn1.RawValue{}, p.errorAt(start, "invalid hexadecimal string")
+	}
+	p.pos += end + 3
+	var value asn1.RawValue
+	if err := unmarshalExactly(asn1.RawValue{FullBytes: encoded}, &value); err != nil {
+		return asn1.RawValue{}, p.errorAt(start, "invalid encoding: %v", err)
+	}
+	return value, nil
+}
+
+func (p *gserParser) parseAttributeType() (AttributeType, error) {
+	name, start, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	attrType, err := resolveAttributeType(p.schema, name)
+	if err != nil {
+		return nil, p.errorAt(start, "%v", err)
+	}
+	return attrType, nil
+}
+
+func (p *gserParser) parseObjectClass() (asn1.ObjectIdentifier, error) {
+	name, start, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	oid, err := resolveObjectIdentifier(p.schema, name)
+	if err != nil {
+		return nil, p.errorAt(start, "%v", err)
+	}
+	return oid, nil
+}
+
+func (p *gserParser) parseContextType() (asn1.ObjectIdentifier, error) {
+	name, start, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		oid, err := stringToOID(name)
+		if err != nil || len(oid) < 2 {
+			return nil, p.errorAt(start, "invalid object identifier %q", name)
+		}
+		return oid, nil
+	}
+	if ct := p.schema.ContextType(name); ct != nil {
+		return ct.Identifier, nil
+	}
+	return nil, p.errorAt(start, "unrecognized context type %q", name)
+}
+
+// Parses a distinguished name in a string, in the form of IETF RFC 4514.
+func (p *gserParser) parseDN() (DistinguishedName, error) {
+	s, start, err := p.parseString()
+	if err != nil {
+		return nil, err
+	}
+	dn, err := ParseDNWithOptions(s, &DNOptions{Schema: p.schema})
+	if err != nil {
+		return nil, p.errorAt(start, "%v", err)
+	}
+	return dn, nil
+}
+
+// Parses a filter in the form of IETF RFC 4515, which must be parenthesized.
+func (p *gserParser) parseFilter() (*FilterNode, error) {
+	p.skipSpaces()
+	start := p.pos
+	if p.eof() || p.s[p.pos] != '(' {
+		return nil, p.errorf("expected a filter")
+	}
+	depth := 0
+	for ; !p.eof(); p.pos++ {
+		if p.s[p.pos] == '(' {
+			depth++
+		} else if p.s[p.pos] == ')' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	if p.eof() {
+		return nil, p.errorAt(start, "unterminated filter")
+	}
+	p.pos++
+	f, err := ParseFilterWithOptions(p.s[start:p.pos], &FilterOptions{Schema: p.schema})
+	if err != nil {
+		return nil, p.errorAt(start, "%v", err)
+	}
+	return f, nil
+}
+
+// Parses elements separated by commas and enclosed in braces, calling
+// `element` for each.
+func (p *gserParser) parseList(element func() error) error {
+	if err := p.expect('{'); err != nil {
+		return err
+	}
+	p.skipSpaces()
+	if !p.eof() && p.s[p.pos] == '}' {
+		p.pos++
+		return nil
+	}
+	for {
+		if err := element(); err != nil {
+			return err
+		}
+		p.skipSpaces()
+		if p.eof() || p.s[p.pos] != ',' {
+			return p.expect('}')
+		}
+		p.pos++
+	}
+}
+
+// Parses the components of a SEQUENCE, each of which is its identifier
+// followed by its value, which is parsed by the function in `components`.
+// Identifiers are not case-sensitive. Components may be given in any order,
+// but only once, and those in `required` must be present.
+func (p *gserParser) parseSequence(what string, components map[string]func() error, required ...string) error {
+	seen := make(map[string]bool)
+	err := p.parseList(func() error {
+		name, start, err := p.identifier()
+		if err != nil {
+			return err
+		}
+		for component, parse := range components {
+			if !strings.EqualFold(name, component) {
+				continue
+			}
+			if seen[component] {
+				return p.errorAt(start, "duplicate %s in %s", component, what)
+			}
+			seen[component] = true
+			return parse()
+		}
+		return p.errorAt(start, "unrecognized component %q of %s", name, what)
+	})
+	if err != nil {
+		return err
+	}
+	for _, component := range required {
+		if !seen[component] {
+			return p.errorAt(p.pos-1, "%s has no %s", what, component)
+		}
+	}
+	return nil
+}
+
+var authenticationLevelNames = []string{"none", "simple", "strong"}
+
+func (p *gserParser) parseLevel() (AuthenticationLevel_basicLevels_level, error) {
+	name, err := p.keyword(authenticationLevelNames...)
+	if err != nil {
+		return 0, err
+	}
+	for i, n := range authenticationLevelNames {
+		if n == name {
+			return AuthenticationLevel_basicLevels_level(i), nil
+		}
+	}
+	return 0, nil
+}
+
+// Parses an authentication level, which may be given as just the name of a
+// level, or as basicLevels:{ level simple, localQualifier 1, signed TRUE }.
+func (p *gserParser) parseAuthenticationLevel() (*AuthenticationLevel_basicLevels, error) {
+	p.skipSpaces()
+	save := p.pos
+	level := &AuthenticationLevel_basicLevels{}
+	if _, err := p.keyword("basicLevels"); err != nil {
+		p.pos = save
+		var levelErr error
+		if level.Level, levelErr = p.parseLevel(); levelErr != nil {
+			return nil, p.errorAt(save, "expected basicLevels or %s", strings.Join(authenticationLevelNames, ", "))
+		}
+		return level, nil
+	}
+	if err := p.expect(':'); err != nil {
+		return nil, err
+	}
+	err := p.parseSequence("basicLevels", map[string]func() error{
+		"level": func() (err error) {
+			level.Level, err = p.parseLevel()
+			return err
+		},
+		"localQualifier": func() (err error) {
+			level.LocalQualifier, err = p.parseInteger()
+			return err
+		},
+		"signed": func() (err error) {
+			level.Signed, err = p.parseBoolean()
+			return err
+		},
+	}, "level")
+	return level, err
+}
+
+var grantsAndDenialsNames = []string{
+	"grantAdd", "denyAdd",
+	"grantDiscloseOnError", "denyDiscloseOnError",
+	"grantRead", "denyRead",
+	"grantRemove", "denyRemove",
+	"grantBrowse", "denyBrowse",
+	"grantExport", "denyExport",
+	"grantImport", "denyImport",
+	"grantModify", "denyModify",
+	"grantRename", "denyRename",
+	"grantReturnDN", "denyReturnDN",
+	"grantCompare", "denyCompare",
+	"grantFilterMatch", "denyFilterMatch",
+	"grantInvoke", "denyInvoke",
+}
+
+func (p *gserParser) parseGrantsAndDenials() (GrantsAndDenials, error) {
+	var bits []int
+	err := p.parseList(func() error {
+		name, err := p.keyword(grantsAndDenialsNames...)
+		if err != nil {
+			return err
+		}
+		for bit, n := range grantsAndDenialsNames {
+			if n == name {
+				bits = append(bits, bit)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return GrantsAndDenials{}, err
+	}
+	// Trailing zero bits are omitted, as the Distinguished Encoding Rules
+	// require of named bit lists.
+	length := 0
+	for _, bit := range bits {
+		length = max(length, bit+1)
+	}
+	g := GrantsAndDenials{Bytes: make([]byte, (length+7)/8), BitLength: length}
+	for _, bit := range bits {
+		g.Bytes[bit/8] |= 0x80 >> (bit % 8)
+	}
+	return g, nil
+}
+
+func (p *gserParser) parseNames() ([]NameAndOptionalUID, error) {
+	var names []NameAndOptionalUID
+	err := p.parseList(func() error {
+		s, start, err := p.parseString()
+		if err != nil {
+			return err
+		}
+		value, err := parseNameAndOptionalUID(p.schema, s)
+		if err != nil {
+			return p.errorAt(start, "%v", err)
+		}
+		var name NameAndOptionalUID
+		if err := unmarshalExactly(value, &name); err != nil {
+			return p.errorAt(start, "%v", err)
+		}
+		names = append(names, name)
+		return nil
+	})
+	return names, err
+}
+
+func (p *gserParser) parseAttributeTypes() ([]AttributeType, error) {
+	var types []AttributeType
+	err := p.parseList(func() error {
+		attrType, err := p.parseAttributeType()
+		types = append(types, attrType)
+		return err
+	})
+	return types, err
+}
+
+// Parses a Refinement in the form of IETF RFC 3672, such as
+// and:{ item:person, not:item:applicationProcess }.
+func (p *gserParser) parseRefinement() (*RefinementNode, error) {
+	kind, err := p.keyword("item", "and", "or", "not")
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(':'); err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "item":
+		oc, err := p.parseObjectClass()
+		if err != nil {
+			return nil, err
+		}
+		return &RefinementNode{Kind: RefinementKindItem, ObjectClass: oc}, nil
+	case "not":
+		operand, err := p.parseRefinement()
+		if err != nil {
+			return nil, err
+		}
+		return &RefinementNode{Kind: RefinementKindNot, Refinements: []*RefinementNode{operand}}, nil
+	}
+	r := &RefinementNode{Kind: RefinementKindAnd, Refinements: []*RefinementNode{}}
+	if kind == "or" {
+		r.Kind = RefinementKindOr
+	}
+	err = p.parseList(func() error {
+		operand, err := p.parseRefinement()
+		r.Refinements = append(r.Refinements, operand)
+		return err
+	})
+	return r, err
+}
+
+// Parses a SubtreeSpecification in the form of IETF RFC 3672.
+func (p *gserParser) parseSubtreeSpecification() (*SubtreeSpecificationNode, error) {
+	s := &SubtreeSpecificationNode{}
+	err := p.parseSequence("subtree specification", map[string]func() error{
+		"base": func() (err error) {
+			s.Base, err = p.parseDN()
+			return err
+		},
+		"specificExclusions": func() error {
+			return p.parseList(func() error {
+				chop, err := p.keyword("chopBefore", "chopAfter")
+				if err != nil {
+					return err
+				}
+				if err := p.expect(':'); err != nil {
+					return err
+				}
+				name, err := p.parseDN()
+				s.SpecificExclusions = append(s.SpecificExclusions, SpecificExclusion{ChopAfter: chop == "chopAfter", Name: name})
+				return err
+			})
+		},
+		"minimum": func() (err error) {
+			s.Minimum, err = p.parseInteger()
+			return err
+		},
+		"maximum": func() error {
+			maximum, err := p.parseInteger()
+			s.Maximum = &maximum
+			return err
+		},
+		"specificationFilter": func() (err error) {
+			s.SpecificationFilter, err = p.parseRefinement()
+			return err
+		},
+	})
+	return s, err
+}
+
+func (p *gserParser) parseUserClasses() (*UserClassesNode, error) {
+	u := &UserClassesNode{}
+	err := p.parseSequence("userClasses", map[string]func() error{
+		"allUsers": func() error {
+			u.AllUsers = true
+			p.optionalNull()
+			return nil
+		},
+		"thisEntry": func() error {
+			u.ThisEntry = true
+			p.optionalNull()
+			return nil
+		},
+		"name": func() (err error) {
+			u.Name, err = p.parseNames()
+			return err
+		},
+		"userGroup": func() (err error) {
+			u.UserGroup, err = p.parseNames()
+			return err
+		},
+		"subtree": func() error {
+			return p.parseList(func() error {
+				s, err := p.parseSubtreeSpecification()
+				u.Subtree = append(u.Subtree, s)
+				return err
+			})
+		},
+	})
+	return u, err
+}
+
+func (p *gserParser) parseProtectedItems() (*ProtectedItemsNode, error) {
+	items := &ProtectedItemsNode{}
+	err := p.parseSequence("protectedItems", map[string]func() error{
+		"entry": func() error {
+			items.Entry = true
+			p.optionalNull()
+			return nil
+		},
+		"allUserAttributeTypes": func() error {
+			items.AllUserAttributeTypes = true
+			p.optionalNull()
+			return nil
+		},
+		"attributeType": func() (err error) {
+			items.AttributeType, err = p.parseAttributeTypes()
+			return err
+		},
+		"allAttributeValues": func() (err error) {
+			items.AllAttributeValues, err = p.parseAttributeTypes()
+			return err
+		},
+		"allUserAttributeTypesAndValues": func() error {
+			items.AllUserAttributeTypesAndValues = true
+			p.optionalNull()
+			return nil
+		},
+		"attributeValue": func() error {
+			return p.parseList(func() error {
+				p.skipSpaces()
+				start := p.pos
+				dn, err := p.parseDN()
+				if err != nil {
+					return err
+				}
+				if len(dn) != 1 || len(dn[0]) != 1 {
+					return p.errorAt(start, "expected a single attribute type and value")
+				}
+				items.AttributeValue = append(items.AttributeValue, dn[0][0])
+				return nil
+			})
+		},
+		"selfValue": func() (err error) {
+			items.SelfValue, err = p.parseAttributeTypes()
+			return err
+		},
+		"rangeOfValues": func() (err error) {
+			items.RangeOfValues, err = p.parseFilter()
+			return err
+		},
+		"maxValueCount": func() error {
+			return p.parseList(func() error {
+				var limit MaxValueCount
+				err := p.parseSequence("maxValueCount", map[string]func() error{
+					"type": func() (err error) {
+						limit.Type, err = p.parseAttributeType()
+						return err
+					},
+					"maxCount": func() (err error) {
+						limit.MaxCount, err = p.parseInteger()
+						return err
+					},
+				}, "type", "maxCount")
+				items.MaxValueCount = append(items.MaxValueCount, limit)
+				return err
+			})
+		},
+		"maxImmSub": func() error {
+			maxImmSub, err := p.parseInteger()
+			items.MaxImmSub = &maxImmSub
+			return err
+		},
+		"restrictedBy": func() error {
+			return p.parseList(func() error {
+				var restriction RestrictedValue
+				err := p.parseSequence("restrictedBy", map[string]func() error{
+					"type": func() (err error) {
+						restriction.Type, err = p.parseAttributeType()
+						return err
+					},
+					"valuesIn": func() (err error) {
+						restriction.ValuesIn, err = p.parseAttributeType()
+						return err
+					},
+				}, "type", "valuesIn")
+				items.RestrictedBy = append(items.RestrictedBy, restriction)
+				return err
+			})
+		},
+		"contexts": func() error {
+			return p.parseList(func() error {
+				var assertion ContextAssertion
+				err := p.parseSequence("contexts", map[string]func() error{
+					"contextType": func() (err error) {
+						assertion.ContextType, err = p.parseContextType()
+						return err
+					},
+					"contextValues": func() error {
+						return p.parseList(func() error {
+							value, err := p.parseEncodedValue()
+							assertion.ContextValues = append(assertion.ContextValues, value)
+							return err
+						})
+					},
+				}, "contextType", "contextValues")
+				items.Contexts = append(items.Contexts, assertion)
+				return err
+			})
+		},
+		"classes": func() (err error) {
+			items.Classes, err = p.parseRefinement()
+			return err
+		},
+	})
+	return items, err
+}
+
+func (p *gserParser) parsePermission(userFirst bool) (ACIPermissionNode, error) {
+	var permission ACIPermissionNode
+	components := map[string]func() error{
+		"precedence": func() error {
+			precedence, err := p.parsePrecedence()
+			permission.Precedence = &precedence
+			return err
+		},
+		"grantsAndDenials": func() (err error) {
+			permission.GrantsAndDenials, err = p.parseGrantsAndDenials()
+			return err
+		},
+	}
+	if userFirst {
+		components["protectedItems"] = func() (err error) {
+			permission.ProtectedItems, err = p.parseProtectedItems()
+			return err
+		}
+		return permission, p.parseSequence("userPermission", components, "protectedItems", "grantsAndDenials")
+	}
+	components["userClasses"] = func() (err error) {
+		permission.UserClasses, err = p.parseUserClasses()
+		return err
+	}
+	return permission, p.parseSequence("itemPermission", components, "userClasses", "grantsAndDenials")
+}
+
+func (p *gserParser) parsePrecedence() (Precedence, error) {
+	p.skipSpaces()
+	start := p.pos
+	precedence, err := p.parseInteger()
+	if err == nil && (precedence < 0 || precedence > 255) {
+		return 0, p.errorAt(start, "precedence must be from 0 to 255")
+	}
+	return precedence, err
+}
+
+func (p *gserParser) parseItemOrUserFirst(n *ACIItemNode) error {
+	choice, err := p.keyword("itemFirst", "userFirst")
+	if err != nil {
+		return err
+	}
+	if err := p.expect(':'); err != nil {
+		return err
+	}
+	n.UserFirst = choice == "userFirst"
+	permissions := func() error {
+		return p.parseList(func() error {
+			permission, err := p.parsePermission(n.UserFirst)
+			n.Permissions = append(n.Permissions, permission)
+			return err
+		})
+	}
+	if n.UserFirst {
+		return p.parseSequence("userFirst", map[string]func() error{
+			"userClasses": func() (err error) {
+				n.UserClasses, err = p.parseUserClasses()
+				return err
+			},
+			"userPermissions": permissions,
+		}, "userClasses", "userPermissions")
+	}
+	return p.parseSequence("itemFirst", map[string]func() error{
+		"protectedItems": func() (err error) {
+			n.ProtectedItems, err = p.parseProtectedItems()
+			return err
+		},
+		"itemPermissions": permissions,
+	}, "protectedItems", "itemPermissions")
+}
+
+func (p *gserParser) parseACIItem() (*ACIItemNode, error) {
+	n := &ACIItemNode{}
+	err := p.parseSequence("ACIItem", map[string]func() error{
+		"identificationTag": func() (err error) {
+			n.IdentificationTag, _, err = p.parseString()
+			return err
+		},
+		"precedence": func() (err error) {
+			n.Precedence, err = p.parsePrecedence()
+			return err
+		},
+		"authenticationLevel": func() (err error) {
+			n.AuthenticationLevel, err = p.parseAuthenticationLevel()
+			return err
+		},
+		"itemOrUserFirst": func() error {
+			return p.parseItemOrUserFirst(n)
+		},
+	}, "identificationTag", "precedence", "authenticationLevel", "itemOrUserFirst")
+	return n, err
+}
+
+func (p *gserParser) end() error {
+	p.skipSpaces()
+	if !p.eof() {
+		return p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return nil
+}
+
+// Parse an ACIItem in the Generic String Encoding Rules, resolving names using
+// [DefaultSchemaRegistry]. See [ParseACIItemWithOptions].
+func ParseACIItem(s string) (ACIItem, error) {
+	return ParseACIItemWithOptions(s, nil)
+}
+
+// Parse an ACIItem in the Generic String Encoding Rules of IETF RFC 3641, as
+// used for the ACI Item LDAP syntax by OpenLDAP, Apache Directory Server and
+// Meerkat DSA, such as:
+//
+//	{ identificationTag "public", precedence 10, authenticationLevel none,
+//	  itemOrUserFirst userFirst:{ userClasses { allUsers },
+//	  userPermissions { { protectedItems { entry, allUserAttributeTypesAndValues },
+//	  grantsAndDenials { grantRead, grantBrowse, grantReturnDN } } } } }
+//
+// Components may be given in any order. Distinguished names are strings in
+// the form of IETF RFC 4514, and may be followed by a unique identifier as in
+// the NameAndOptionalUID syntax of IETF RFC 4517. Values of attributeValue
+// are strings of a single attribute type and value, such as "cn=Admin".
+// rangeOfValues is a filter in the form of IETF RFC 4515. Subtree
+// specifications and refinements are in the form of IETF RFC 3672. The
+// values of contexts are hexadecimal strings of their BER encodings, such as
+// '0C02656E'H. An authentication level may be given as just the name of its
+// level. Errors are [*GSERSyntaxError]s.
+func ParseACIItemWithOptions(s string, options *GSEROptions) (ACIItem, error) {
+	p := &gserParser{s: s, schema: options.schema()}
+	n, err := p.parseACIItem()
+	if err != nil {
+		return ACIItem{}, err
+	}
+	if err := p.end(); err != nil {
+		return ACIItem{}, err
+	}
+	return n.Marshal()
+}
+
+// Parse a SubtreeSpecification in the form of IETF RFC 3672, such as
+// { base "ou=People", minimum 1, specificationFilter item:person }, resolving
+// names using [DefaultSchemaRegistry]. See
+// [ParseSubtreeSpecificationWithOptions].
+func ParseSubtreeSpecification(s string) (*SubtreeSpecificationNode, error) {
+	return ParseSubtreeSpecificationWithOptions(s, nil)
+}
+
+// Parse a SubtreeSpecification in the form of IETF RFC 3672. Components may
+// be given in any order. Errors are [*GSERSyntaxError]s.
+func ParseSubtreeSpecificationWithOptions(s string, options *GSEROptions) (*SubtreeSpecificationNode, error) {
+	p := &gserParser{s: s, schema: options.schema()}
+	spec, err := p.parseSubtreeSpecification()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.end(); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// A value in the Generic String Encoding Rules, for formatting: `text`,
+// followed by `elements` in braces if `braced` is true.
+type gserValue struct {
+	text     string
+	braced   bool
+	elements []gserValue
+}
+
+func gserText(format string, args ...any) gserValue {
+	return gserValue{text: fmt.Sprintf(format, args...)}
+}
+
+func gserBraced(text string, elements ...gserValue) gserValue {
+	return gserValue{text: text, braced: true, elements: elements}
+}
+
+func (v *gserValue) write(b *strings.Builder, indent string, depth int) {
+	b.WriteString(v.text)
+	if !v.braced {
+		return
+	}
+	if len(v.elements) == 0 {
+		b.WriteString("{ }")
+		return
+	}
+	if indent == "" {
+		b.WriteString("{ ")
+		for i := range v.elements {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			v.elements[i].write(b, "", 0)
+		}
+		b.WriteString(" }")
+		return
+	}
+	b.WriteString("{\n")
+	for i := range v.elements {
+		b.WriteString(strings.Repeat(indent, depth+1))
+		v.elements[i].write(b, indent, depth+1)
+		if i < len(v.elements)-1 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString(strings.Repeat(indent, depth))
+	b.WriteByte('}')
+}
+
+func quoteGSERString(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+type gserFormatter struct {
+	schema *SchemaRegistry
+}
+
+func (f *gserFormatter) dn(dn DistinguishedName) (string, error) {
+	s, err := FormatDNWithOptions(dn, &DNOptions{Schema: f.schema})
+	return quoteGSERString(s), err
+}
+
+func (f *gserFormatter) attributeTypes(text string, types []AttributeType) gserValue {
+	v := gserBraced(text)
+	for _, t := range types {
+		v.elements = append(v.elements, gserText("%s", attributeTypeName(f.schema, t)))
+	}
+	return v
+}
+
+func (f *gserFormatter) names(text string, names []NameAndOptionalUID) (gserValue, error) {
+	v := gserBraced(text)
+	for _, name := range names {
+		value, err := marshalRawValue(name, "")
+		if err != nil {
+			return v, err
+		}
+		s, err := formatNameAndOptionalUID(f.schema, value)
+		if err != nil {
+			return v, err
+		}
+		v.elements = append(v.elements, gserText("%s", quoteGSERString(s)))
+	}
+	return v, nil
+}
+
+func (f *gserFormatter) refinement(r *RefinementNode) (gserValue, error) {
+	switch r.Kind {
+	case RefinementKindItem:
+		return gserText("item:%s", objectIdentifierName(f.schema, r.ObjectClass)), nil
+	case RefinementKindNot:
+		if len(r.Refinements) != 1 {
+			return gserValue{}, fmt.Errorf("not refinement must have exactly one operand")
+		}
+		operand, err := f.refinement(r.Refinements[0])
+		operand.text = "not:" + operand.text
+		return operand, err
+	case RefinementKindAnd, RefinementKindOr:
+		v := gserBraced("and:")
+		if r.Kind == RefinementKindOr {
+			v.text = "or:"
+		}
+		for _, operand := range r.Refinements {
+			element, err := f.refinement(operand)
+			if err != nil {
+				return v, err
+			}
+			v.elements = append(v.elements, element)
+		}
+		return v, nil
+	}
+	return gserValue{}, fmt.Errorf("unrecognized refinement kind %d", r.Kind)
+}
+
+func (f *gserFormatter) subtreeSpecification(s *SubtreeSpecificationNode) (gserValue, error) {
+	v := gserBraced("")
+	if len(s.Base) > 0 {
+		base, err := f.dn(s.Base)
+		if err != nil {
+			return v, err
+		}
+		v.elements = append(v.elements, gserText("base %s", base))
+	}
+	if len(s.SpecificExclusions) > 0 {
+		exclusions := gserBraced("specificExclusions ")
+		for _, exclusion := range s.SpecificExclusions {
+			name, err := f.dn(exclusion.Name)
+			if err != nil {
+				return v, err
+			}
+			chop := "chopBefore"
+			if exclusion.ChopAfter {
+				chop = "chopAfter"
+			}
+			exclusions.elements = append(exclusions.elements, gserText("%s:%s", chop, name))
+		}
+		v.elements = append(v.elements, exclusions)
+	}
+	if s.Minimum != 0 {
+		v.elements = append(v.elements, gserText("minimum %d", s.Minimum))
+	}
+	if s.Maximum != nil {
+		v.elements = append(v.elements, gserText("maximum %d", *s.Maximum))
+	}
+	if s.SpecificationFilter != nil {
+		filter, err := f.refinement(s.SpecificationFilter)
+		if err != nil {
+			return v, err
+		}
+		filter.text = "specificationFilter " + filter.text
+		v.elements = append(v.elements, filter)
+	}
+	return v, nil
+}
+
+func (f *gserFormatter) userClasses(text string, u *UserClassesNode) (gserValue, error) {
+	v := gserBraced(text)
+	if u.AllUsers {
+		v.elements = append(v.elements, gserText("allUsers"))
+	}
+	if u.ThisEntry {
+		v.elements = append(v.elements, gserText("thisEntry"))
+	}
+	if len(u.Name) > 0 {
+		names, err := f.names("name ", u.Name)
+		if err != nil {
+			return v, err
+		}
+		v.elements = append(v.elements, names)
+	}
+	if len(u.UserGroup) > 0 {
+		groups, err := f.names("userGroup ", u.UserGroup)
+		if err != nil {
+			return v, err
+		}
+		v.elements = append(v.elements, groups)
+	}
+	if len(u.Subtree) > 0 {
+		subtrees := gserBraced("subtree ")
+		for _, s := range u.Subtree {
+			subtree, err := f.subtreeSpecification(s)
+			if err != nil {
+				return v, err
+			}
+			subtrees.elements = append(subtrees.elements, subtree)
+		}
+		v.elements = append(v.elements, subtrees)
+	}
+	return v, nil
+}
+
+func (f *gserFormatter) protectedItems(text string, p *ProtectedItemsNode) (gserValue, error) {
+	v := gserBraced(text)
+	if p.Entry {
+		v.elements = append(v.elements, gserText("entry"))
+	}
+	if p.AllUserAttributeTypes {
+		v.elements = append(v.elements, gserText("allUserAttributeTypes"))
+	}
+	if len(p.AttributeType) > 0 {
+		v.elements = append(v.elements, f.attributeTypes("attributeType ", p.AttributeType))
+	}
+	if len(p.AllAttributeValues) > 0 {
+		v.elements = append(v.elements, f.attributeTypes("allAttributeValues ", p.AllAttributeValues))
+	}
+	if p.AllUserAttributeTypesAndValues {
+		v.elements = append(v.elements, gserText("allUserAttributeTypesAndValues"))
+	}
+	if len(p.AttributeValue) > 0 {
+		values := gserBraced("attributeValue ")
+		for _, atav := range p.AttributeValue {
+			s, err := f.dn(DistinguishedName{{atav}})
+			if err != nil {
+				return v, err
+			}
+			values.elements = append(values.elements, gserText("%s", s))
+		}
+		v.elements = append(v.elements, values)
+	}
+	if len(p.SelfValue) > 0 {
+		v.elements = append(v.elements, f.attributeTypes("selfValue ", p.SelfValue))
+	}
+	if p.RangeOfValues != nil {
+		filter, err := FormatFilterWithOptions(p.RangeOfValues, &FilterOptions{Schema: f.schema})
+		if err != nil {
+			return v, err
+		}
+		v.elements = append(v.elements, gserText("rangeOfValues %s", filter))
+	}
+	if len(p.MaxValueCount) > 0 {
+		limits := gserBraced("maxValueCount ")
+		for _, limit := range p.MaxValueCount {
+			limits.elements = append(limits.elements, gserBraced("",
+				gserText("type %s", attributeTypeName(f.schema, limit.Type)),
+				gserText("maxCount %d", limit.MaxCount)))
+		}
+		v.elements = append(v.elements, limits)
+	}
+	if p.MaxImmSub != nil {
+		v.elements = append(v.elements, gserText("maxImmSub %d", *p.MaxImmSub))
+	}
+	if len(p.RestrictedBy) > 0 {
+		restrictions := gserBraced("restrictedBy ")
+		for _, restriction := range p.RestrictedBy {
+			restrictions.elements = append(restrictions.elements, gserBraced("",
+				gserText("type %s", attributeTypeName(f.schema, restriction.Type)),
+				gserText("valuesIn %s", attributeTypeName(f.schema, restriction.ValuesIn))))
+		}
+		v.elements = append(v.elements, restrictions)
+	}
+	if len(p.Contexts) > 0 {
+		contexts := gserBraced("contexts ")
+		for _, assertion := range p.Contexts {
+			contextType := assertion.ContextType.String()
+			if ct := f.schema.ContextType(contextType); ct != nil && len(ct.Name) > 0 {
+				if name, err := DirectoryStringToString(ct.Name[0]); err == nil {
+					contextType = name
+				}
+			}
+			values := gserBraced("contextValues ")
+			for _, value := range assertion.ContextValues {
+				values.elements = append(values.elements, gserText("'%X'H", encodingOf(value)))
+			}
+			contexts.elements = append(contexts.elements, gserBraced("", gserText("contextType %s", contextType), values))
+		}
+		v.elements = append(v.elements, contexts)
+	}
+	if p.Classes != nil {
+		classes, err := f.refinement(p.Classes)
+		if err != nil {
+			return v, err
+		}
+		classes.text = "classes " + classes.text
+		v.elements = append(v.elements, classes)
+	}
+	return v, nil
+}
+
+func (f *gserFormatter) grantsAndDenials(g GrantsAndDenials) gserValue {
+	v := gserBraced("grantsAndDenials ")
+	for bit, name := range grantsAndDenialsNames {
+		if g.At(bit) == 1 {
+			v.elements = append(v.elements, gserText("%s", name))
+		}
+	}
+	return v
+}
+
+func (f *gserFormatter) authenticationLevel(level *AuthenticationLevel_basicLevels) (gserValue, error) {
+	if level == nil {
+		return gserValue{}, fmt.Errorf("the other authentication level cannot be formatted")
+	}
+	if level.Level < 0 || int(level.Level) >= len(authenticationLevelNames) {
+		return gserValue{}, fmt.Errorf("unrecognized authentication level %d", level.Level)
+	}
+	name := authenticationLevelNames[level.Level]
+	if level.LocalQualifier == 0 && !level.Signed {
+		return gserText("authenticationLevel %s", name), nil
+	}
+	v := gserBraced("authenticationLevel basicLevels:", gserText("level %s", name))
+	if level.LocalQualifier != 0 {
+		v.elements = append(v.elements, gserText("localQualifier %d", level.LocalQualifier))
+	}
+	if level.Signed {
+		v.elements = append(v.elements, gserText("signed TRUE"))
+	}
+	return v, nil
+}
+
+func (f *gserFormatter) aciItem(n *ACIItemNode) (gserValue, error) {
+	level, err := f.authenticationLevel(n.AuthenticationLevel)
+	if err != nil {
+		return gserValue{}, err
+	}
+	v := gserBraced("",
+		gserText("identificationTag %s", quoteGSERString(n.IdentificationTag)),
+		gserText("precedence %d", n.Precedence),
+		level)
+	var first gserValue
+	permissions := gserBraced("itemPermissions ")
+	if n.UserFirst {
+		permissions.text = "userPermissions "
+		if n.UserClasses == nil {
+			return v, fmt.Errorf("userFirst item has no user classes")
+		}
+		first, err = f.userClasses("userClasses ", n.UserClasses)
+	} else {
+		if n.ProtectedItems == nil {
+			return v, fmt.Errorf("itemFirst item has no protected items")
+		}
+		first, err = f.protectedItems("protectedItems ", n.ProtectedItems)
+	}
+	if err != nil {
+		return v, err
+	}
+	for _, permission := range n.Permissions {
+		element := gserBraced("")
+		if permission.Precedence != nil {
+			element.elements = append(element.elements, gserText("precedence %d", *permission.Precedence))
+		}
+		var second gserValue
+		if n.UserFirst {
+			if permission.ProtectedItems == nil {
+				return v, fmt.Errorf("user permission has no protected items")
+			}
+			second, err = f.protectedItems("protectedItems ", permission.ProtectedItems)
+		} else {
+			if permission.UserClasses == nil {
+				return v, fmt.Errorf("item permission has no user classes")
+			}
+			second, err = f.userClasses("userClasses ", permission.UserClasses)
+		}
+		if err != nil {
+			return v, err
+		}
+		element.elements = append(element.elements, second, f.grantsAndDenials(permission.GrantsAndDenials))
+		permissions.elements = append(permissions.elements, element)
+	}
+	choice := "itemOrUserFirst itemFirst:"
+	if n.UserFirst {
+		choice = "itemOrUserFirst userFirst:"
+	}
+	v.elements = append(v.elements, gserBraced(choice, first, permissions))
+	return v, nil
+}
+
+// Format an ACIItem in the Generic String Encoding Rules, using names from
+// [DefaultSchemaRegistry]. See [FormatACIItemWithOptions].
+func FormatACIItem(item ACIItem) (string, error) {
+	return FormatACIItemWithOptions(item, nil)
+}
+
+// Format an ACIItem in the Generic String Encoding Rules, the inverse of
+// [ParseACIItemWithOptions]. Attribute types, object classes and context types
+// are given by their first names in `options.Schema`, or in dotted-decimal
+// form if they are not registered. If `options.Indent` is not empty, the
+// result is spread over multiple lines for review.
+func FormatACIItemWithOptions(item ACIItem, options *GSEROptions) (string, error) {
+	n, err := DecodeACIItemNode(item)
+	if err != nil {
+		return "", err
+	}
+	f := &gserFormatter{schema: options.schema()}
+	v, err := f.aciItem(n)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	v.write(&b, options.indent(), 0)
+	return b.String(), nil
+}
+
+// Format a SubtreeSpecification in the form of IETF RFC 3672, using names
+// from [DefaultSchemaRegistry]. See [FormatSubtreeSpecificationWithOptions].
+func FormatSubtreeSpecification(s *SubtreeSpecificationNode) (string, error) {
+	return FormatSubtreeSpecificationWithOptions(s, nil)
+}
+
+// Format a SubtreeSpecification in the form of IETF RFC 3672, the inverse of
+// [ParseSubtreeSpecificationWithOptions].
+func FormatSubtreeSpecificationWithOptions(s *SubtreeSpecificationNode, options *GSEROptions) (string, error) {
+	f := &gserFormatter{schema: options.schema()}
+	v, err := f.subtreeSpecification(s)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	v.write(&b, options.indent(), 0)
+	return b.String(), nil
+}
+
+func formatACIItem(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var item ACIItem
+	if err := unmarshalExactly(value, &item); err != nil {
+		return "", err
+	}
+	return FormatACIItemWithOptions(item, &GSEROptions{Schema: schema})
+}
+
+func parseACIItem(schema *SchemaRegistry, s string) (asn1.RawValue, error) {
+	item, err := ParseACIItemWithOptions(s, &GSEROptions{Schema: schema})
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	return marshalRawValue(item, "")
+}
+
+func formatSubtreeSpecification(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var spec SubtreeSpecificationNode
+	if err := spec.UnmarshalRawValue(value); err != nil {
+		return "", err
+	}
+	return FormatSubtreeSpecificationWithOptions(&spec, &GSEROptions{Schema: schema})
+}
+
+func parseSubtreeSpecification(schema *SchemaRegistry, s string) (asn1.RawValue, error) {
+	spec, err := ParseSubtreeSpecificationWithOptions(s, &GSEROptions{Schema: schema})
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	return spec.MarshalRawValue()
+}
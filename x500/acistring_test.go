@@ -0,0 +1,213 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseACIItem(t *testing.T) {
+	item, err := ParseACIItem(`{
+		identificationTag "public ""read""",
+		precedence 10,
+		authenticationLevel none,
+		itemOrUserFirst userFirst:{
+			userClasses { allUsers, name { "CN=Alice,O=Example#'0101'B" } },
+			userPermissions {
+				{ protectedItems { entry, allUserAttributeTypesAndValues },
+				  grantsAndDenials { grantRead, grantBrowse, grantReturnDN } },
+				{ precedence 20,
+				  protectedItems { attributeType { userPassword } },
+				  grantsAndDenials { denyRead, denyCompare } }
+			}
+		}
+	}`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	n, err := DecodeACIItemNode(item)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n.IdentificationTag != `public "read"` || n.Precedence != 10 || !n.UserFirst {
+		t.Errorf("unexpected item %+v", n)
+	}
+	if !n.UserClasses.AllUsers || len(n.UserClasses.Name) != 1 || n.UserClasses.Name[0].Uid.BitLength != 4 {
+		t.Errorf("unexpected user classes %+v", n.UserClasses)
+	}
+	if len(n.Permissions) != 2 {
+		t.Errorf("expected 2 permissions, but got %d", len(n.Permissions))
+		return
+	}
+	if !n.Permissions[0].ProtectedItems.Entry || n.Permissions[0].GrantsAndDenials.At(GrantsAndDenials_GrantBrowse) != 1 {
+		t.Errorf("unexpected permission %+v", n.Permissions[0])
+	}
+	if *n.Permissions[1].Precedence != 20 || !n.Permissions[1].ProtectedItems.AttributeType[0].Equal(Id_at_userPassword) {
+		t.Errorf("unexpected permission %+v", n.Permissions[1])
+	}
+	// Trailing zero bits are not encoded.
+	if n.Permissions[1].GrantsAndDenials.BitLength != GrantsAndDenials_DenyCompare+1 {
+		t.Errorf("unexpected bit length %d", n.Permissions[1].GrantsAndDenials.BitLength)
+	}
+
+	formatted, err := FormatACIItem(item)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := `{ identificationTag "public ""read""", precedence 10, authenticationLevel none, ` +
+		`itemOrUserFirst userFirst:{ userClasses { allUsers, name { "CN=Alice,O=Example#'0101'B" } }, ` +
+		`userPermissions { { protectedItems { entry, allUserAttributeTypesAndValues }, grantsAndDenials { grantRead, grantBrowse, grantReturnDN } }, ` +
+		`{ precedence 20, protectedItems { attributeType { userPassword } }, grantsAndDenials { denyRead, denyCompare } } } } }`
+	if formatted != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, formatted)
+	}
+}
+
+func TestFormatACIItemRoundTrip(t *testing.T) {
+	inputs := []string{
+		`{ identificationTag "admins", precedence 255, authenticationLevel basicLevels:{ level strong, localQualifier 3, signed TRUE }, ` +
+			`itemOrUserFirst itemFirst:{ protectedItems { entry, allUserAttributeTypes, attributeType { CN, sn }, allAttributeValues { CN }, ` +
+			`allUserAttributeTypesAndValues, attributeValue { "CN=Jack" }, selfValue { member }, rangeOfValues (sn=Smith), ` +
+			`maxValueCount { { type CN, maxCount 2 } }, maxImmSub 5, restrictedBy { { type CN, valuesIn sn } }, ` +
+			`contexts { { contextType 2.5.31.0, contextValues { '13026465'H } } }, classes and:{ item:person, not:item:top } }, ` +
+			`itemPermissions { { userClasses { thisEntry, userGroup { "CN=Admins,O=Example" }, ` +
+			`subtree { { base "OU=People", specificExclusions { chopBefore:"OU=Former,OU=People" }, minimum 1, maximum 3, specificationFilter item:person } } }, ` +
+			`grantsAndDenials { grantAdd, grantInvoke } } } } }`,
+		`{ identificationTag "empty", precedence 0, authenticationLevel simple, ` +
+			`itemOrUserFirst itemFirst:{ protectedItems { }, itemPermissions { } } }`,
+	}
+	for _, input := range inputs {
+		item, err := ParseACIItem(input)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		formatted, err := FormatACIItem(item)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if formatted != input {
+			t.Errorf("expected\n%s\nbut got\n%s", input, formatted)
+		}
+	}
+}
+
+func TestFormatACIItemIndent(t *testing.T) {
+	item, err := ParseACIItem(`{ identificationTag "x", precedence 1, authenticationLevel none, itemOrUserFirst itemFirst:{ protectedItems { entry }, itemPermissions { { userClasses { allUsers }, grantsAndDenials { grantRead } } } } }`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	formatted, err := FormatACIItemWithOptions(item, &GSEROptions{Indent: "  "})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := `{
+  identificationTag "x",
+  precedence 1,
+  authenticationLevel none,
+  itemOrUserFirst itemFirst:{
+    protectedItems {
+      entry
+    },
+    itemPermissions {
+      {
+        userClasses {
+          allUsers
+        },
+        grantsAndDenials {
+          grantRead
+        }
+      }
+    }
+  }
+}`
+	if formatted != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, formatted)
+	}
+	if _, err := ParseACIItem(formatted); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseACIItemErrors(t *testing.T) {
+	cases := []struct {
+		input   string
+		line    int
+		column  int
+		message string
+	}{
+		{`{ identificationTag "x" }`, 1, 25, "ACIItem has no precedence"},
+		{"{ identificationTag \"x\",\n  precedence 256", 2, 14, "precedence must be from 0 to 255"},
+		{"{\n\tfoo 1 }", 2, 2, `unrecognized component "foo" of ACIItem`},
+		{`{ precedence 1, precedence 2 }`, 1, 17, "duplicate precedence in ACIItem"},
+		{`{ identificationTag "x`, 1, 21, "unterminated string"},
+		{`{ authenticationLevel weak }`, 1, 23, "expected basicLevels or none, simple, strong"},
+		{"{ identificationTag \"x\", precedence 1, authenticationLevel none,\n  itemOrUserFirst itemFirst:{ protectedItems { attributeType { noSuchType } } } }", 2, 64, "noSuchType"},
+		{"{ identificationTag \"x\", precedence 1, authenticationLevel none, itemOrUserFirst itemFirst:{ protectedItems { }, itemPermissions { { userClasses { }, grantsAndDenials { grantEverything } } } } }", 1, 170, "expected grantAdd"},
+		{`{ identificationTag "x", precedence 1, authenticationLevel none, itemOrUserFirst itemFirst:{ protectedItems { }, itemPermissions { } } } x`, 1, 138, "unexpected"},
+	}
+	for _, c := range cases {
+		_, err := ParseACIItem(c.input)
+		var syntaxErr *GSERSyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: expected a syntax error, but got %v", c.input, err)
+			continue
+		}
+		if syntaxErr.Line != c.line || syntaxErr.Column != c.column || !strings.Contains(syntaxErr.Message, c.message) {
+			t.Errorf("%q: expected %q at line %d, column %d, but got %v", c.input, c.message, c.line, c.column, err)
+		}
+	}
+}
+
+func TestParseSubtreeSpecification(t *testing.T) {
+	spec, err := ParseSubtreeSpecification(`{ specificationFilter or:{ item:person, item:organizationalPerson }, base "OU=People" }`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !spec.Contains(mustParseDN(t, "o=Example"), mustParseDN(t, "cn=Alice,ou=People,o=Example"), []asn1.ObjectIdentifier{Id_oc_person}) {
+		t.Error("expected the subtree to contain a person in ou=People")
+	}
+	formatted, err := FormatSubtreeSpecification(spec)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := `{ base "OU=People", specificationFilter or:{ item:person, item:organizationalPerson } }`
+	if formatted != expected {
+		t.Errorf("expected %s, but got %s", expected, formatted)
+	}
+	formatted, err = FormatSubtreeSpecification(&SubtreeSpecificationNode{})
+	if err != nil || formatted != "{ }" {
+		t.Errorf("expected an empty subtree specification, but got %q, %v", formatted, err)
+	}
+}
+
+func TestACIItemSyntaxCodec(t *testing.T) {
+	codec := SyntaxCodecFor("1.3.6.1.4.1.1466.115.121.1.1")
+	if codec == nil {
+		t.Error("expected a codec for the ACI Item syntax")
+		return
+	}
+	input := `{ identificationTag "x", precedence 1, authenticationLevel none, itemOrUserFirst userFirst:{ userClasses { allUsers }, userPermissions { } } }`
+	value, err := codec.Parse(nil, input)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	formatted, err := codec.Format(nil, value)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if formatted != input {
+		t.Errorf("expected %s, but got %s", input, formatted)
+	}
+}
@@ -0,0 +1,382 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A ContextSelection in a form that is easier to construct and evaluate. Use
+// [ContextSelectionNode.Marshal] to produce a ContextSelection, and
+// [DecodeContextSelection] to produce a ContextSelectionNode from one.
+type ContextSelectionNode struct {
+	// If true, this is the allContexts alternative, which selects every value
+	// regardless of its contexts.
+	AllContexts bool
+
+	// The selectedContexts alternative.
+	Selections []TypeAndContextAssertionNode
+}
+
+// A TypeAndContextAssertion of a [ContextSelectionNode].
+type TypeAndContextAssertionNode struct {
+	// The attribute type to which `Assertions` apply. [Id_oa_allAttributeTypes]
+	// applies them to attribute types that have no assertions of their own.
+	Type AttributeType
+
+	// If true, `Assertions` are the preference alternative, in which the
+	// first assertion that any value satisfies selects the values. Otherwise,
+	// they are the all alternative, in which a value must satisfy all of them.
+	Preference bool
+
+	Assertions []ContextAssertion
+}
+
+// Returns a [ContextSelectionNode] that prefers values in the languages
+// `languages`, in order, for all attribute types, such as "fr" and then "en".
+func LanguagePreference(languages ...string) *ContextSelectionNode {
+	assertions := make([]ContextAssertion, 0, len(languages))
+	for _, lang := range languages {
+		assertions = append(assertions, LanguageAssertion(lang))
+	}
+	return &ContextSelectionNode{
+		Selections: []TypeAndContextAssertionNode{{
+			Type:       Id_oa_allAttributeTypes,
+			Preference: true,
+			Assertions: assertions,
+		}},
+	}
+}
+
+func languageValue(lang string) asn1.RawValue {
+	return asn1.RawValue{
+		Class: asn1.ClassUniversal,
+		Tag:   asn1.TagPrintableString,
+		Bytes: []byte(lang),
+	}
+}
+
+// Returns a languageContext, as defined in ITU-T Recommendation X.520,
+// Section 15.1, for the ISO 639-2 language code `lang`.
+func LanguageContext(lang string) Context {
+	return Context{ContextType: Id_avc_language, ContextValues: []asn1.RawValue{languageValue(lang)}}
+}
+
+// Returns an assertion that a languageContext is any of the ISO 639-2
+// language codes `langs`.
+func LanguageAssertion(langs ...string) ContextAssertion {
+	assertion := ContextAssertion{ContextType: Id_avc_language}
+	for _, lang := range langs {
+		assertion.ContextValues = append(assertion.ContextValues, languageValue(lang))
+	}
+	return assertion
+}
+
+// Returns a temporalContext, as defined in ITU-T Recommendation X.520,
+// Section 15.2, for the time specification `spec`.
+func TemporalContext(spec *TimeSpecificationNode) (Context, error) {
+	encoded, err := spec.Marshal()
+	if err != nil {
+		return Context{}, err
+	}
+	value, err := marshalRawValue(encoded, "")
+	return Context{ContextType: Id_avc_temporal, ContextValues: []asn1.RawValue{value}}, err
+}
+
+// Returns a localeContext, as defined in ITU-T Recommendation X.520, Section
+// 15.3, for the locale identified by the string `locale`, such as "en_GB".
+func LocaleContext(locale string) Context {
+	return Context{ContextType: Id_avc_locale, ContextValues: []asn1.RawValue{NewDirectoryString(locale)}}
+}
+
+// Returns a localeContext for the locale identified by the object identifier
+// `locale`.
+func LocaleContextID(locale asn1.ObjectIdentifier) (Context, error) {
+	value, err := marshalRawValue(locale, "")
+	return Context{ContextType: Id_avc_locale, ContextValues: []asn1.RawValue{value}}, err
+}
+
+// Encodes `s` as a ContextSelection.
+func (s *ContextSelectionNode) Marshal() (ContextSelection, error) {
+	if s.AllContexts {
+		return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagNull, FullBytes: asn1.NullBytes}, nil
+	}
+	if len(s.Selections) == 0 {
+		return asn1.RawValue{}, errors.New("a context selection must select at least one attribute type")
+	}
+	selections := make([][]byte, 0, len(s.Selections))
+	for _, selection := range s.Selections {
+		params := "set"
+		if selection.Preference {
+			params = ""
+		}
+		assertions, err := asn1.MarshalWithParams(selection.Assertions, params)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		encoded, err := asn1.Marshal(TypeAndContextAssertion{
+			Type:              selection.Type,
+			ContextAssertions: asn1.RawValue{FullBytes: assertions},
+		})
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		selections = append(selections, encoded)
+	}
+	return setOf(selections...)
+}
+
+// Decode a [ContextSelection] into a [ContextSelectionNode].
+func DecodeContextSelection(selection ContextSelection) (*ContextSelectionNode, error) {
+	if selection.Class == asn1.ClassUniversal && selection.Tag == asn1.TagNull {
+		return &ContextSelectionNode{AllContexts: true}, nil
+	}
+	if selection.Class != asn1.ClassUniversal || selection.Tag != asn1.TagSet {
+		return nil, fmt.Errorf("unrecognized context selection alternative %d", selection.Tag)
+	}
+	var selected []TypeAndContextAssertion
+	rest, err := asn1.UnmarshalWithParams(encodingOf(selection), &selected, "set")
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing bytes after context selection")
+	}
+	node := &ContextSelectionNode{Selections: make([]TypeAndContextAssertionNode, 0, len(selected))}
+	for _, s := range selected {
+		selection := TypeAndContextAssertionNode{Type: s.Type}
+		params := "set"
+		switch s.ContextAssertions.Tag {
+		case asn1.TagSequence:
+			selection.Preference = true
+			params = ""
+		case asn1.TagSet:
+		default:
+			return nil, fmt.Errorf("unrecognized context assertions alternative %d", s.ContextAssertions.Tag)
+		}
+		rest, err := asn1.UnmarshalWithParams(encodingOf(s.ContextAssertions), &selection.Assertions, params)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) > 0 {
+			return nil, errors.New("trailing bytes after context assertions")
+		}
+		node.Selections = append(node.Selections, selection)
+	}
+	return node, nil
+}
+
+// Selects attribute values by their contexts, as described in ITU-T
+// Recommendation X.501, Section 8.9, and ITU-T Recommendation X.511, Section
+// 7.6.
+type ContextSelector struct {
+	// The time against which temporalContexts are evaluated when a
+	// TimeAssertion is `now`. If zero, the current time is used.
+	Now time.Time
+}
+
+func (s *ContextSelector) now() time.Time {
+	if s == nil || s.Now.IsZero() {
+		return time.Now()
+	}
+	return s.Now
+}
+
+// Returns true if the context value `value` of the context type `contextType`
+// matches the asserted value `assertion`. languageContexts match if their
+// language codes are the same, ignoring case. temporalContexts match if their
+// time specification satisfies the asserted TimeAssertion. localeContexts
+// match if their identifiers are the same, ignoring the case of strings.
+// Other contexts match if their encodings are the same.
+func (s *ContextSelector) ContextValueMatches(contextType asn1.ObjectIdentifier, value, assertion asn1.RawValue) (bool, error) {
+	switch {
+	case contextType.Equal(Id_avc_language):
+		var lang, asserted string
+		if err := unmarshalExactly(value, &lang); err != nil {
+			return false, err
+		}
+		if err := unmarshalExactly(assertion, &asserted); err != nil {
+			return false, err
+		}
+		return strings.EqualFold(lang, asserted), nil
+	case contextType.Equal(Id_avc_temporal):
+		var encoded TimeSpecification
+		if err := unmarshalExactly(value, &encoded); err != nil {
+			return false, err
+		}
+		spec, err := DecodeTimeSpecification(encoded)
+		if err != nil {
+			return false, err
+		}
+		if len(assertion.FullBytes) > 0 {
+			if err := unmarshalExactly(assertion, &assertion); err != nil {
+				return false, err
+			}
+		}
+		return spec.MatchesAssertion(assertion, s.now())
+	case contextType.Equal(Id_avc_locale):
+		if value.Tag == asn1.TagOID || assertion.Tag == asn1.TagOID {
+			return bytes.Equal(encodingOf(value), encodingOf(assertion)), nil
+		}
+		locale, err := DirectoryStringToString(value)
+		if err != nil {
+			return false, err
+		}
+		asserted, err := DirectoryStringToString(assertion)
+		if err != nil {
+			return false, err
+		}
+		return strings.EqualFold(locale, asserted), nil
+	}
+	return bytes.Equal(encodingOf(value), encodingOf(assertion)), nil
+}
+
+// How a value relates to a context assertion.
+type contextMatch int
+
+const (
+	contextAbsent   contextMatch = iota // No context of the asserted type.
+	contextMatched                      // A context of the asserted type matches.
+	contextFallback                     // A fallback context of the asserted type does not match.
+	contextMismatch                     // A context of the asserted type does not match.
+)
+
+func (s *ContextSelector) matchContexts(contexts []Context, assertion *ContextAssertion) (contextMatch, error) {
+	match := contextAbsent
+	for _, context := range contexts {
+		if !context.ContextType.Equal(assertion.ContextType) {
+			continue
+		}
+		for _, value := range context.ContextValues {
+			for _, asserted := range assertion.ContextValues {
+				matched, err := s.ContextValueMatches(context.ContextType, value, asserted)
+				if err != nil {
+					return match, err
+				}
+				if matched {
+					return contextMatched, nil
+				}
+			}
+		}
+		if context.Fallback {
+			match = contextFallback
+		} else if match == contextAbsent {
+			match = contextMismatch
+		}
+	}
+	return match, nil
+}
+
+// Returns which values with contexts of `attr` satisfy `assertion`, and
+// whether any does other than for want of a context of the asserted type.
+func (s *ContextSelector) satisfying(attr *Attribute, assertion *ContextAssertion) (holds []bool, satisfied bool, err error) {
+	matches := make([]contextMatch, len(attr.ValuesWithContext))
+	for i := range attr.ValuesWithContext {
+		if matches[i], err = s.matchContexts(attr.ValuesWithContext[i].ContextList, assertion); err != nil {
+			return nil, false, err
+		}
+		satisfied = satisfied || matches[i] == contextMatched
+	}
+	holds = make([]bool, len(matches))
+	fallback := false
+	for i, match := range matches {
+		holds[i] = match == contextMatched || match == contextAbsent || (match == contextFallback && !satisfied)
+		fallback = fallback || (match == contextFallback && !satisfied)
+	}
+	return holds, satisfied || fallback, nil
+}
+
+// Returns the values of `attr` that `assertions` select. Values without
+// contexts are always selected. A value satisfies an assertion if it has a
+// context of the asserted type with a value that matches one of the asserted
+// values according to [ContextSelector.ContextValueMatches], or if it has no
+// context of the asserted type. If no value has a matching context, values
+// with a fallback context of the asserted type satisfy it too.
+//
+// If `preference` is false, values that satisfy every assertion are selected.
+// Otherwise, the first assertion that a value satisfies, other than for want
+// of a context of the asserted type, selects the values that satisfy it. If
+// there is no such assertion, only values with none of the asserted context
+// types are selected.
+func (s *ContextSelector) SelectValues(attr *Attribute, assertions []ContextAssertion, preference bool) (*Attribute, error) {
+	selected := make([]bool, len(attr.ValuesWithContext))
+	for i := range selected {
+		selected[i] = true
+	}
+	for i := range assertions {
+		holds, satisfied, err := s.satisfying(attr, &assertions[i])
+		if err != nil {
+			return nil, err
+		}
+		if preference && !satisfied {
+			for j := range selected {
+				selected[j] = selected[j] && !hasContextType(attr.ValuesWithContext[j].ContextList, assertions[i].ContextType)
+			}
+			continue
+		}
+		if preference {
+			selected = holds
+			break
+		}
+		for j := range selected {
+			selected[j] = selected[j] && holds[j]
+		}
+	}
+	result := &Attribute{Type: attr.Type, Values: attr.Values}
+	for i, value := range attr.ValuesWithContext {
+		if selected[i] {
+			result.ValuesWithContext = append(result.ValuesWithContext, value)
+		}
+	}
+	return result, nil
+}
+
+func hasContextType(contexts []Context, contextType asn1.ObjectIdentifier) bool {
+	for _, context := range contexts {
+		if context.ContextType.Equal(contextType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the values of `attrs` that `selection` selects, omitting attributes
+// with no selected values. The assertions for an attribute type are those of
+// the first [TypeAndContextAssertionNode] of that type, or else the first of
+// [Id_oa_allAttributeTypes]. Attributes of types without assertions, and all
+// attributes if `selection` is nil or `AllContexts` is true, are returned as
+// they are.
+func (s *ContextSelector) Select(attrs []Attribute, selection *ContextSelectionNode) ([]Attribute, error) {
+	if selection == nil || selection.AllContexts {
+		return attrs, nil
+	}
+	result := make([]Attribute, 0, len(attrs))
+	for i := range attrs {
+		var chosen *TypeAndContextAssertionNode
+		for j := range selection.Selections {
+			candidate := &selection.Selections[j]
+			if candidate.Type.Equal(attrs[i].Type) {
+				chosen = candidate
+				break
+			}
+			if chosen == nil && candidate.Type.Equal(Id_oa_allAttributeTypes) {
+				chosen = candidate
+			}
+		}
+		if chosen == nil {
+			result = append(result, attrs[i])
+			continue
+		}
+		selected, err := s.SelectValues(&attrs[i], chosen.Assertions, chosen.Preference)
+		if err != nil {
+			return nil, err
+		}
+		if !selected.IsEmpty() {
+			result = append(result, *selected)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,166 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+	"time"
+)
+
+func testValueWithContexts(t *testing.T, s string, contexts ...Context) Attribute_valuesWithContext_Item {
+	return Attribute_valuesWithContext_Item{Value: mustMarshalValue(t, s, "utf8"), ContextList: contexts}
+}
+
+func selectedStrings(t *testing.T, attr *Attribute) []string {
+	strs := make([]string, 0, attr.Len())
+	for i := 0; i < attr.Len(); i++ {
+		s, err := DirectoryStringToString(*attr.Get(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		strs = append(strs, s)
+	}
+	return strs
+}
+
+func TestLanguagePreference(t *testing.T) {
+	fallback := LanguageContext("la")
+	fallback.Fallback = true
+	attrs := []Attribute{
+		{
+			Type: Id_at_commonName,
+			ValuesWithContext: []Attribute_valuesWithContext_Item{
+				testValueWithContexts(t, "Bonjour", LanguageContext("fr")),
+				testValueWithContexts(t, "Hello", LanguageContext("EN")),
+				testValueWithContexts(t, "Hallo", LanguageContext("de")),
+			},
+		},
+		{
+			Type:   Id_at_surname,
+			Values: []asn1.RawValue{mustMarshalValue(t, "Smith", "utf8")},
+			ValuesWithContext: []Attribute_valuesWithContext_Item{
+				testValueWithContexts(t, "Schmidt", LanguageContext("de")),
+			},
+		},
+		{
+			Type: Id_at_title,
+			ValuesWithContext: []Attribute_valuesWithContext_Item{
+				testValueWithContexts(t, "Chef", LanguageContext("de")),
+				testValueWithContexts(t, "Magister", fallback),
+			},
+		},
+	}
+	selection := LanguagePreference("es", "en", "fr")
+	selected, err := (&ContextSelector{}).Select(attrs, selection)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(selected) != 3 {
+		t.Errorf("expected 3 attributes, but got %d", len(selected))
+		return
+	}
+	expected := [][]string{{"Hello"}, {"Smith"}, {"Magister"}}
+	for i := range expected {
+		got := selectedStrings(t, &selected[i])
+		if len(got) != len(expected[i]) || got[0] != expected[i][0] {
+			t.Errorf("%s: expected %v, but got %v", selected[i].Type, expected[i], got)
+		}
+	}
+
+	all := &ContextSelectionNode{Selections: []TypeAndContextAssertionNode{{
+		Type:       Id_at_commonName,
+		Assertions: []ContextAssertion{LanguageAssertion("de", "fr")},
+	}}}
+	selected, err = (&ContextSelector{}).Select(attrs, all)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got := selectedStrings(t, &selected[0]); len(got) != 2 || len(selected[1].ValuesWithContext) != 1 {
+		t.Errorf("expected French and German common names and all other values, but got %v", got)
+	}
+}
+
+func TestTemporalAndLocaleContexts(t *testing.T) {
+	weekdays, err := TemporalContext(mustParseTimeSpecification(t, "mon-fri 09:00-17:00"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	weekends, err := TemporalContext(mustParseTimeSpecification(t, "sat,sun"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	british, err := LocaleContextID(asn1.ObjectIdentifier{1, 2, 3})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	attr := Attribute{
+		Type: Id_at_telephoneNumber,
+		ValuesWithContext: []Attribute_valuesWithContext_Item{
+			testValueWithContexts(t, "office", weekdays, LocaleContext("en_US")),
+			testValueWithContexts(t, "home", weekends, british),
+		},
+	}
+	selector := &ContextSelector{Now: time.Date(2024, time.March, 9, 12, 0, 0, 0, time.UTC)}
+	now := ContextAssertion{ContextType: Id_avc_temporal, ContextValues: []asn1.RawValue{{FullBytes: asn1.NullBytes}}}
+	selected, err := selector.SelectValues(&attr, []ContextAssertion{now}, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got := selectedStrings(t, selected); len(got) != 1 || got[0] != "home" {
+		t.Errorf("expected the weekend number, but got %v", got)
+	}
+
+	locale := ContextAssertion{ContextType: Id_avc_locale, ContextValues: []asn1.RawValue{NewDirectoryString("EN_us")}}
+	selected, err = selector.SelectValues(&attr, []ContextAssertion{locale}, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got := selectedStrings(t, selected); len(got) != 1 || got[0] != "office" {
+		t.Errorf("expected the American number, but got %v", got)
+	}
+}
+
+func TestContextSelectionMarshal(t *testing.T) {
+	selection := LanguagePreference("fr", "en")
+	selection.Selections = append(selection.Selections, TypeAndContextAssertionNode{
+		Type:       Id_at_commonName,
+		Assertions: []ContextAssertion{LanguageAssertion("de")},
+	})
+	encoded, err := selection.Marshal()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	decoded, err := DecodeContextSelection(mustMarshalValue(t, encoded, ""))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(decoded.Selections) != 2 {
+		t.Errorf("expected 2 selections, but got %+v", decoded)
+		return
+	}
+	for _, s := range decoded.Selections {
+		if s.Type.Equal(Id_oa_allAttributeTypes) != s.Preference {
+			t.Errorf("expected only the language preference to be a preference, but got %+v", s)
+		}
+		if s.Preference && len(s.Assertions) != 2 {
+			t.Errorf("expected 2 preferred languages, but got %+v", s.Assertions)
+		}
+	}
+
+	encoded, err = (&ContextSelectionNode{AllContexts: true}).Marshal()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if decoded, err = DecodeContextSelection(mustMarshalValue(t, encoded, "")); err != nil || !decoded.AllContexts {
+		t.Errorf("expected allContexts, but got %+v (%v)", decoded, err)
+	}
+}
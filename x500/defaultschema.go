@@ -0,0 +1,387 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"slices"
+	"sync"
+)
+
+type builtinAttributeType struct {
+	oid      asn1.ObjectIdentifier
+	names    []string
+	syntax   string
+	equality asn1.ObjectIdentifier
+}
+
+// The attribute types defined in this package. The first name is the one used
+// when formatting distinguished names, which is the short name from IETF RFC
+// 4514 where there is one. The syntax is the name of the ASN.1 type of
+// values, and is only given for types that have a [SyntaxCodec]. The equality
+// matching rule is the one used when comparing values in distinguished names.
+var builtinAttributeTypes = []builtinAttributeType{
+	{Id_at_attributeCertificate, []string{"attributeCertificate"}, "", nil},
+	{Id_at_attributeCertificateRevocationList, []string{"attributeCertificateRevocationList"}, "", nil},
+	{Id_at_aACertificate, []string{"aACertificate"}, "", nil},
+	{Id_at_attributeDescriptorCertificate, []string{"attributeDescriptorCertificate"}, "", nil},
+	{Id_at_attributeAuthorityRevocationList, []string{"attributeAuthorityRevocationList"}, "", nil},
+	{Id_at_privPolicy, []string{"privPolicy"}, "", nil},
+	{Id_at_role, []string{"role"}, "", nil},
+	{Id_at_delegationPath, []string{"delegationPath"}, "", nil},
+	{Id_at_protPrivPolicy, []string{"protPrivPolicy"}, "", nil},
+	{Id_at_xMLPrivilegeInfo, []string{"xMLPrivilegeInfo"}, "", nil},
+	{Id_at_xmlPrivPolicy, []string{"xmlPrivPolicy"}, "", nil},
+	{Id_at_permission, []string{"permission"}, "", nil},
+	{Id_at_eeAttrCertificateRevocationList, []string{"eeAttrCertificateRevocationList"}, "", nil},
+	{Id_at_userPassword, []string{"userPassword"}, "", nil},
+	{Id_at_userCertificate, []string{"userCertificate"}, "", nil},
+	{Id_at_cAcertificate, []string{"cAcertificate"}, "", nil},
+	{Id_at_authorityRevocationList, []string{"authorityRevocationList"}, "", nil},
+	{Id_at_certificateRevocationList, []string{"certificateRevocationList"}, "", nil},
+	{Id_at_crossCertificatePair, []string{"crossCertificatePair"}, "", nil},
+	{Id_at_supportedAlgorithms, []string{"supportedAlgorithms"}, "", nil},
+	{Id_at_deltaRevocationList, []string{"deltaRevocationList"}, "", nil},
+	{Id_at_certificationPracticeStmt, []string{"certificationPracticeStmt"}, "", nil},
+	{Id_at_certificatePolicy, []string{"certificatePolicy"}, "", nil},
+	{Id_at_pkiPath, []string{"pkiPath"}, "", nil},
+	{Id_at_eepkCertificateRevocationList, []string{"eepkCertificateRevocationList"}, "", nil},
+	{Id_at_supportedPublicKeyAlgorithms, []string{"supportedPublicKeyAlgorithms"}, "", nil},
+	{Id_at_family_information, []string{"family-information"}, "", nil},
+	{Id_at_clearance, []string{"clearance"}, "", nil},
+	{Id_at_attributeIntegrityInfo, []string{"attributeIntegrityInfo"}, "", nil},
+	{Id_at_objectClass, []string{"objectClass"}, "OBJECT IDENTIFIER", Id_mr_objectIdentifierMatch},
+	{Id_at_aliasedEntryName, []string{"aliasedEntryName"}, "DistinguishedName", Id_mr_distinguishedNameMatch},
+	{Id_at_pwdAttribute, []string{"pwdAttribute"}, "", nil},
+	{Id_at_userPwd, []string{"userPwd"}, "", nil},
+	{Id_at_knowledgeInformation, []string{"knowledgeInformation"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_commonName, []string{"CN", "commonName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_surname, []string{"sn", "surname"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_serialNumber, []string{"serialNumber"}, "PrintableString", Id_mr_caseIgnoreMatch},
+	{Id_at_countryName, []string{"C", "countryName"}, "CountryName", Id_mr_caseIgnoreMatch},
+	{Id_at_localityName, []string{"L", "localityName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_collectiveLocalityName, []string{"c-l", "collectiveLocalityName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_stateOrProvinceName, []string{"ST", "stateOrProvinceName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_collectiveStateOrProvinceName, []string{"c-st", "collectiveStateOrProvinceName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_streetAddress, []string{"STREET", "streetAddress"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_collectiveStreetAddress, []string{"c-street", "collectiveStreetAddress"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_organizationName, []string{"O", "organizationName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_collectiveOrganizationName, []string{"c-o", "collectiveOrganizationName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_organizationalUnitName, []string{"OU", "organizationalUnitName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_collectiveOrganizationalUnitName, []string{"c-ou", "collectiveOrganizationalUnitName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_title, []string{"title"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_description, []string{"description"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_searchGuide, []string{"searchGuide"}, "Guide", nil},
+	{Id_at_businessCategory, []string{"businessCategory"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_postalAddress, []string{"postalAddress"}, "PostalAddress", Id_mr_caseIgnoreListMatch},
+	{Id_at_collectivePostalAddress, []string{"c-PostalAddress", "collectivePostalAddress"}, "PostalAddress", Id_mr_caseIgnoreListMatch},
+	{Id_at_postalCode, []string{"postalCode"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_collectivePostalCode, []string{"c-PostalCode", "collectivePostalCode"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_postOfficeBox, []string{"postOfficeBox"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_collectivePostOfficeBox, []string{"c-PostOfficeBox", "collectivePostOfficeBox"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_physicalDeliveryOfficeName, []string{"physicalDeliveryOfficeName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_collectivePhysicalDeliveryOfficeName, []string{"c-PhysicalDeliveryOfficeName", "collectivePhysicalDeliveryOfficeName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_telephoneNumber, []string{"telephoneNumber"}, "TelephoneNumber", Id_mr_telephoneNumberMatch},
+	{Id_at_collectiveTelephoneNumber, []string{"c-TelephoneNumber", "collectiveTelephoneNumber"}, "TelephoneNumber", Id_mr_telephoneNumberMatch},
+	{Id_at_telexNumber, []string{"telexNumber"}, "TelexNumber", nil},
+	{Id_at_collectiveTelexNumber, []string{"c-TelexNumber", "collectiveTelexNumber"}, "TelexNumber", nil},
+	{Id_at_facsimileTelephoneNumber, []string{"facsimileTelephoneNumber"}, "FacsimileTelephoneNumber", nil},
+	{Id_at_collectiveFacsimileTelephoneNumber, []string{"c-FacsimileTelephoneNumber", "collectiveFacsimileTelephoneNumber"}, "FacsimileTelephoneNumber", nil},
+	{Id_at_x121Address, []string{"x121Address"}, "NumericString", Id_mr_numericStringMatch},
+	{Id_at_internationalISDNNumber, []string{"internationalISDNNumber"}, "NumericString", Id_mr_numericStringMatch},
+	{Id_at_collectiveInternationalISDNNumber, []string{"c-InternationalISDNNumber", "collectiveInternationalISDNNumber"}, "NumericString", Id_mr_numericStringMatch},
+	{Id_at_registeredAddress, []string{"registeredAddress"}, "PostalAddress", Id_mr_caseIgnoreListMatch},
+	{Id_at_destinationIndicator, []string{"destinationIndicator"}, "PrintableString", Id_mr_caseIgnoreMatch},
+	{Id_at_preferredDeliveryMethod, []string{"preferredDeliveryMethod"}, "PreferredDeliveryMethod", nil},
+	{Id_at_presentationAddress, []string{"presentationAddress"}, "PresentationAddress", nil},
+	{Id_at_supportedApplicationContext, []string{"supportedApplicationContext"}, "OBJECT IDENTIFIER", nil},
+	{Id_at_member, []string{"member"}, "DistinguishedName", Id_mr_distinguishedNameMatch},
+	{Id_at_owner, []string{"owner"}, "DistinguishedName", Id_mr_distinguishedNameMatch},
+	{Id_at_roleOccupant, []string{"roleOccupant"}, "DistinguishedName", Id_mr_distinguishedNameMatch},
+	{Id_at_seeAlso, []string{"seeAlso"}, "DistinguishedName", Id_mr_distinguishedNameMatch},
+	{Id_at_name, []string{"name"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_givenName, []string{"givenName", "gn"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_initials, []string{"initials"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_generationQualifier, []string{"generationQualifier"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_uniqueIdentifier, []string{"uniqueIdentifier"}, "UniqueIdentifier", Id_mr_bitStringMatch},
+	{Id_at_dnQualifier, []string{"dnQualifier"}, "PrintableString", Id_mr_caseIgnoreMatch},
+	{Id_at_enhancedSearchGuide, []string{"enhancedSearchGuide"}, "EnhancedGuide", nil},
+	{Id_at_protocolInformation, []string{"protocolInformation"}, "", nil},
+	{Id_at_distinguishedName, []string{"distinguishedName"}, "DistinguishedName", Id_mr_distinguishedNameMatch},
+	{Id_at_uniqueMember, []string{"uniqueMember"}, "NameAndOptionalUID", Id_mr_uniqueMemberMatch},
+	{Id_at_houseIdentifier, []string{"houseIdentifier"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_dmdName, []string{"dmdName"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_pseudonym, []string{"pseudonym"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_communicationsService, []string{"communicationsService"}, "", nil},
+	{Id_at_communicationsNetwork, []string{"communicationsNetwork"}, "", nil},
+	{Id_at_uuidpair, []string{"uuidpair"}, "UUIDPair", nil},
+	{Id_at_tagOid, []string{"tagOid"}, "OBJECT IDENTIFIER", Id_mr_objectIdentifierMatch},
+	{Id_at_uiiFormat, []string{"uiiFormat"}, "", nil},
+	{Id_at_uiiInUrn, []string{"uiiInUrn"}, "UTF8String", Id_mr_caseExactMatch},
+	{Id_at_contentUrl, []string{"contentUrl"}, "URI", Id_mr_uriMatch},
+	{Id_at_uri, []string{"uri"}, "URI", Id_mr_uriMatch},
+	{Id_at_urn, []string{"urn"}, "URI", Id_mr_uriMatch},
+	{Id_at_url, []string{"url"}, "URI", Id_mr_uriMatch},
+	{Id_at_utmCoordinates, []string{"utmCoordinates"}, "", nil},
+	{Id_at_urnC, []string{"urnC"}, "PrintableString", Id_mr_caseIgnoreMatch},
+	{Id_at_uii, []string{"uii"}, "", nil},
+	{Id_at_epc, []string{"epc"}, "", nil},
+	{Id_at_tagAfi, []string{"tagAfi"}, "", nil},
+	{Id_at_epcFormat, []string{"epcFormat"}, "", nil},
+	{Id_at_epcInUrn, []string{"epcInUrn"}, "UTF8String", Id_mr_caseExactMatch},
+	{Id_at_ldapUrl, []string{"ldapUrl"}, "URI", Id_mr_uriMatch},
+	{Id_at_tagLocation, []string{"tagLocation"}, "", nil},
+	{Id_at_organizationIdentifier, []string{"organizationIdentifier"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_at_countryCode3c, []string{"countryCode3c"}, "PrintableString", Id_mr_caseIgnoreMatch},
+	{Id_at_countryCode3n, []string{"countryCode3n"}, "NumericString", Id_mr_numericStringMatch},
+	{Id_at_dnsName, []string{"dnsName"}, "DomainName", Id_mr_dnsNameMatch},
+	{Id_at_intEmail, []string{"intEmail"}, "IA5String", Id_mr_intEmailMatch},
+	{Id_at_jid, []string{"jid"}, "UTF8String", Id_mr_jidMatch},
+	{Id_at_objectIdentifier, []string{"objectIdentifier"}, "OBJECT IDENTIFIER", Id_mr_objectIdentifierMatch},
+	{Id_coat_uid, []string{"UID", "userid"}, "UnboundedDirectoryString", Id_mr_caseIgnoreMatch},
+	{Id_coat_dc, []string{"DC", "domainComponent"}, "IA5String", Id_lmr_caseIgnoreIA5Match},
+}
+
+type builtinObjectClass struct {
+	oid   asn1.ObjectIdentifier
+	names []string
+	kind  ObjectClassKind
+}
+
+// The object classes defined in this package. Only their names and kinds are
+// given, so that they may be referred to by name, such as in filters.
+var builtinObjectClasses = []builtinObjectClass{
+	{Id_oc_country, []string{"country"}, ObjectClassKind_Structural},
+	{Id_oc_locality, []string{"locality"}, ObjectClassKind_Structural},
+	{Id_oc_organization, []string{"organization"}, ObjectClassKind_Structural},
+	{Id_oc_organizationalUnit, []string{"organizationalUnit"}, ObjectClassKind_Structural},
+	{Id_oc_person, []string{"person"}, ObjectClassKind_Structural},
+	{Id_oc_organizationalPerson, []string{"organizationalPerson"}, ObjectClassKind_Structural},
+	{Id_oc_organizationalRole, []string{"organizationalRole"}, ObjectClassKind_Structural},
+	{Id_oc_groupOfNames, []string{"groupOfNames"}, ObjectClassKind_Structural},
+	{Id_oc_residentialPerson, []string{"residentialPerson"}, ObjectClassKind_Structural},
+	{Id_oc_applicationProcess, []string{"applicationProcess"}, ObjectClassKind_Structural},
+	{Id_oc_applicationEntity, []string{"applicationEntity"}, ObjectClassKind_Structural},
+	{Id_oc_dSA, []string{"dSA"}, ObjectClassKind_Structural},
+	{Id_oc_device, []string{"device"}, ObjectClassKind_Structural},
+	{Id_oc_strongAuthenticationUser, []string{"strongAuthenticationUser"}, ObjectClassKind_Auxiliary},
+	{Id_oc_certificationAuthority, []string{"certificationAuthority"}, ObjectClassKind_Auxiliary},
+	{Id_oc_certificationAuthority_V2, []string{"certificationAuthority-V2"}, ObjectClassKind_Auxiliary},
+	{Id_oc_groupOfUniqueNames, []string{"groupOfUniqueNames"}, ObjectClassKind_Structural},
+	{Id_oc_userSecurityInformation, []string{"userSecurityInformation"}, ObjectClassKind_Auxiliary},
+	{Id_oc_dmd, []string{"dmd"}, ObjectClassKind_Structural},
+	{Id_oc_oidC1obj, []string{"oidC1obj"}, ObjectClassKind_Auxiliary},
+	{Id_oc_oidC2obj, []string{"oidC2obj"}, ObjectClassKind_Auxiliary},
+	{Id_oc_oidCobj, []string{"oidCobj"}, ObjectClassKind_Auxiliary},
+	{Id_oc_isoTagInfo, []string{"isoTagInfo"}, ObjectClassKind_Auxiliary},
+	{Id_oc_isoTagType, []string{"isoTagType"}, ObjectClassKind_Auxiliary},
+	{Id_oc_userPwdClass, []string{"userPwdClass"}, ObjectClassKind_Auxiliary},
+	{Id_oc_urnCobj, []string{"urnCobj"}, ObjectClassKind_Auxiliary},
+	{Id_oc_epcTagInfoObj, []string{"epcTagInfoObj"}, ObjectClassKind_Auxiliary},
+	{Id_oc_epcTagTypeObj, []string{"epcTagTypeObj"}, ObjectClassKind_Auxiliary},
+	{Id_oc_top, []string{"top"}, ObjectClassKind_Abstract},
+	{Id_oc_alias, []string{"alias"}, ObjectClassKind_Structural},
+	{Id_oc_parent, []string{"parent"}, ObjectClassKind_Abstract},
+	{Id_oc_child, []string{"child"}, ObjectClassKind_Auxiliary},
+	{Id_sc_subentry, []string{"subentry"}, ObjectClassKind_Structural},
+	{Id_sc_accessControlSubentry, []string{"accessControlSubentry"}, ObjectClassKind_Auxiliary},
+	{Id_sc_collectiveAttributeSubentry, []string{"collectiveAttributeSubentry"}, ObjectClassKind_Auxiliary},
+	{Id_sc_contextAssertionSubentry, []string{"contextAssertionSubentry"}, ObjectClassKind_Auxiliary},
+	{Id_sc_serviceAdminSubentry, []string{"serviceAdminSubentry"}, ObjectClassKind_Auxiliary},
+	{Id_sc_pwdAdminSubentry, []string{"pwdAdminSubentry"}, ObjectClassKind_Auxiliary},
+	{Id_oc_cRLDistributionPoint, []string{"cRLDistributionPoint"}, ObjectClassKind_Structural},
+	{Id_oc_pkiUser, []string{"pkiUser"}, ObjectClassKind_Auxiliary},
+	{Id_oc_pkiCA, []string{"pkiCA"}, ObjectClassKind_Auxiliary},
+	{Id_oc_deltaCRL, []string{"deltaCRL"}, ObjectClassKind_Auxiliary},
+	{Id_oc_cpCps, []string{"cpCps"}, ObjectClassKind_Auxiliary},
+	{Id_oc_pkiCertPath, []string{"pkiCertPath"}, ObjectClassKind_Auxiliary},
+	{Id_oc_pmiUser, []string{"pmiUser"}, ObjectClassKind_Auxiliary},
+	{Id_oc_pmiAA, []string{"pmiAA"}, ObjectClassKind_Auxiliary},
+	{Id_oc_pmiSOA, []string{"pmiSOA"}, ObjectClassKind_Auxiliary},
+	{Id_oc_attCertCRLDistributionPts, []string{"attCertCRLDistributionPts"}, ObjectClassKind_Auxiliary},
+	{Id_oc_privilegePolicy, []string{"privilegePolicy"}, ObjectClassKind_Auxiliary},
+	{Id_oc_pmiDelegationPath, []string{"pmiDelegationPath"}, ObjectClassKind_Auxiliary},
+	{Id_oc_protectedPrivilegePolicy, []string{"protectedPrivilegePolicy"}, ObjectClassKind_Auxiliary},
+	{Id_oc_integrityInfo, []string{"integrityInfo"}, ObjectClassKind_Auxiliary},
+	{Id_soc_subschema, []string{"subschema"}, ObjectClassKind_Auxiliary},
+}
+
+type builtinMatchingRule struct {
+	oid   asn1.ObjectIdentifier
+	names []string
+}
+
+// The matching rules defined in this package. Only their names are given.
+var builtinMatchingRules = []builtinMatchingRule{
+	{Id_mr_attributeCertificateMatch, []string{"attributeCertificateMatch"}},
+	{Id_mr_attributeCertificateExactMatch, []string{"attributeCertificateExactMatch"}},
+	{Id_mr_holderIssuerMatch, []string{"holderIssuerMatch"}},
+	{Id_mr_authAttIdMatch, []string{"authAttIdMatch"}},
+	{Id_mr_roleSpecCertIdMatch, []string{"roleSpecCertIdMatch"}},
+	{Id_mr_basicAttConstraintsMatch, []string{"basicAttConstraintsMatch"}},
+	{Id_mr_delegatedNameConstraintsMatch, []string{"delegatedNameConstraintsMatch"}},
+	{Id_mr_timeSpecMatch, []string{"timeSpecMatch"}},
+	{Id_mr_attDescriptorMatch, []string{"attDescriptorMatch"}},
+	{Id_mr_acceptableCertPoliciesMatch, []string{"acceptableCertPoliciesMatch"}},
+	{Id_mr_delegationPathMatch, []string{"delegationPathMatch"}},
+	{Id_mr_sOAIdentifierMatch, []string{"sOAIdentifierMatch"}},
+	{Id_mr_extensionPresenceMatch, []string{"extensionPresenceMatch"}},
+	{Id_mr_dualStringMatch, []string{"dualStringMatch"}},
+	{Id_mr_certificateExactMatch, []string{"certificateExactMatch"}},
+	{Id_mr_certificateMatch, []string{"certificateMatch"}},
+	{Id_mr_certificatePairExactMatch, []string{"certificatePairExactMatch"}},
+	{Id_mr_certificatePairMatch, []string{"certificatePairMatch"}},
+	{Id_mr_certificateListExactMatch, []string{"certificateListExactMatch"}},
+	{Id_mr_certificateListMatch, []string{"certificateListMatch"}},
+	{Id_mr_algorithmIdentifierMatch, []string{"algorithmIdentifierMatch"}},
+	{Id_mr_policyMatch, []string{"policyMatch"}},
+	{Id_mr_pkiPathMatch, []string{"pkiPathMatch"}},
+	{Id_mr_enhancedCertificateMatch, []string{"enhancedCertificateMatch"}},
+	{Id_mr_objectIdentifierMatch, []string{"objectIdentifierMatch"}},
+	{Id_mr_distinguishedNameMatch, []string{"distinguishedNameMatch"}},
+	{Id_mr_userPwdMatch, []string{"userPwdMatch"}},
+	{Id_mr_userPwdHistoryMatch, []string{"userPwdHistoryMatch"}},
+	{Id_mr_pwdEncAlgMatch, []string{"pwdEncAlgMatch"}},
+	{Id_mr_caseIgnoreMatch, []string{"caseIgnoreMatch"}},
+	{Id_mr_caseIgnoreOrderingMatch, []string{"caseIgnoreOrderingMatch"}},
+	{Id_mr_caseIgnoreSubstringsMatch, []string{"caseIgnoreSubstringsMatch"}},
+	{Id_mr_caseExactMatch, []string{"caseExactMatch"}},
+	{Id_mr_caseExactOrderingMatch, []string{"caseExactOrderingMatch"}},
+	{Id_mr_caseExactSubstringsMatch, []string{"caseExactSubstringsMatch"}},
+	{Id_mr_numericStringMatch, []string{"numericStringMatch"}},
+	{Id_mr_numericStringOrderingMatch, []string{"numericStringOrderingMatch"}},
+	{Id_mr_numericStringSubstringsMatch, []string{"numericStringSubstringsMatch"}},
+	{Id_mr_caseIgnoreListMatch, []string{"caseIgnoreListMatch"}},
+	{Id_mr_caseIgnoreListSubstringsMatch, []string{"caseIgnoreListSubstringsMatch"}},
+	{Id_mr_booleanMatch, []string{"booleanMatch"}},
+	{Id_mr_integerMatch, []string{"integerMatch"}},
+	{Id_mr_integerOrderingMatch, []string{"integerOrderingMatch"}},
+	{Id_mr_bitStringMatch, []string{"bitStringMatch"}},
+	{Id_mr_octetStringMatch, []string{"octetStringMatch"}},
+	{Id_mr_octetStringOrderingMatch, []string{"octetStringOrderingMatch"}},
+	{Id_mr_octetStringSubstringsMatch, []string{"octetStringSubstringsMatch"}},
+	{Id_mr_telephoneNumberMatch, []string{"telephoneNumberMatch"}},
+	{Id_mr_telephoneNumberSubstringsMatch, []string{"telephoneNumberSubstringsMatch"}},
+	{Id_mr_presentationAddressMatch, []string{"presentationAddressMatch"}},
+	{Id_mr_uniqueMemberMatch, []string{"uniqueMemberMatch"}},
+	{Id_mr_protocolInformationMatch, []string{"protocolInformationMatch"}},
+	{Id_mr_uTCTimeMatch, []string{"uTCTimeMatch"}},
+	{Id_mr_uTCTimeOrderingMatch, []string{"uTCTimeOrderingMatch"}},
+	{Id_mr_generalizedTimeMatch, []string{"generalizedTimeMatch"}},
+	{Id_mr_generalizedTimeOrderingMatch, []string{"generalizedTimeOrderingMatch"}},
+	{Id_mr_integerFirstComponentMatch, []string{"integerFirstComponentMatch"}},
+	{Id_mr_objectIdentifierFirstComponentMatch, []string{"objectIdentifierFirstComponentMatch"}},
+	{Id_mr_directoryStringFirstComponentMatch, []string{"directoryStringFirstComponentMatch"}},
+	{Id_mr_wordMatch, []string{"wordMatch"}},
+	{Id_mr_keywordMatch, []string{"keywordMatch"}},
+	{Id_mr_storedPrefixMatch, []string{"storedPrefixMatch"}},
+	{Id_mr_systemProposedMatch, []string{"systemProposedMatch"}},
+	{Id_mr_generalWordMatch, []string{"generalWordMatch"}},
+	{Id_mr_approximateStringMatch, []string{"approximateStringMatch"}},
+	{Id_mr_ignoreIfAbsentMatch, []string{"ignoreIfAbsentMatch"}},
+	{Id_mr_nullMatch, []string{"nullMatch"}},
+	{Id_mr_zonalMatch, []string{"zonalMatch"}},
+	{Id_mr_facsimileNumberMatch, []string{"facsimileNumberMatch"}},
+	{Id_mr_facsimileNumberSubstringsMatch, []string{"facsimileNumberSubstringsMatch"}},
+	{Id_mr_uuidpairmatch, []string{"uuidpairmatch"}},
+	{Id_mr_uriMatch, []string{"uriMatch"}},
+	{Id_mr_dnsNameMatch, []string{"dnsNameMatch"}},
+	{Id_mr_intEmailMatch, []string{"intEmailMatch"}},
+	{Id_mr_jidMatch, []string{"jidMatch"}},
+	{Id_lmr_caseExactIA5Match, []string{"caseExactIA5Match"}},
+	{Id_lmr_caseIgnoreIA5Match, []string{"caseIgnoreIA5Match"}},
+	{Id_lmr_caseIgnoreIA5SubstringsMatch, []string{"caseIgnoreIA5SubstringsMatch"}},
+}
+
+// The ordering and substrings matching rules of the attribute types defined in
+// this package, indexed by their equality matching rule.
+var relatedMatchingRules = map[string]struct {
+	ordering   asn1.ObjectIdentifier
+	substrings asn1.ObjectIdentifier
+}{
+	Id_mr_caseIgnoreMatch.String():      {Id_mr_caseIgnoreOrderingMatch, Id_mr_caseIgnoreSubstringsMatch},
+	Id_mr_caseExactMatch.String():       {Id_mr_caseExactOrderingMatch, Id_mr_caseExactSubstringsMatch},
+	Id_mr_numericStringMatch.String():   {Id_mr_numericStringOrderingMatch, Id_mr_numericStringSubstringsMatch},
+	Id_mr_telephoneNumberMatch.String(): {nil, Id_mr_telephoneNumberSubstringsMatch},
+	Id_lmr_caseIgnoreIA5Match.String():  {nil, Id_lmr_caseIgnoreIA5SubstringsMatch},
+	Id_mr_caseIgnoreListMatch.String():  {nil, Id_mr_caseIgnoreListSubstringsMatch},
+}
+
+// The collective attribute types defined in this package, which are
+// collective in the default schema.
+var builtinCollectiveAttributeTypes = []asn1.ObjectIdentifier{
+	Id_at_collectiveLocalityName,
+	Id_at_collectiveStateOrProvinceName,
+	Id_at_collectiveStreetAddress,
+	Id_at_collectiveOrganizationName,
+	Id_at_collectiveOrganizationalUnitName,
+	Id_at_collectivePostalAddress,
+	Id_at_collectivePostalCode,
+	Id_at_collectivePostOfficeBox,
+	Id_at_collectivePhysicalDeliveryOfficeName,
+	Id_at_collectiveTelephoneNumber,
+	Id_at_collectiveTelexNumber,
+	Id_at_collectiveFacsimileTelephoneNumber,
+	Id_at_collectiveInternationalISDNNumber,
+}
+
+// Create a new SchemaRegistry containing the names, syntaxes, matching rules
+// and collectiveness of the attribute types defined in this package, the
+// names and kinds of its object classes and the names of its matching rules,
+// with no other schema elements. The registry may be modified, such as to add
+// attribute types that are not defined here.
+func NewDefaultSchemaRegistry() *SchemaRegistry {
+	r := NewSchemaRegistry()
+	for _, at := range builtinAttributeTypes {
+		desc := AttributeTypeDescription{
+			Identifier: at.oid,
+			Name:       make([]UnboundedDirectoryString, 0, len(at.names)),
+		}
+		for _, name := range at.names {
+			desc.Name = append(desc.Name, NewDirectoryString(name))
+		}
+		if at.syntax != "" {
+			desc.Information.AttributeSyntax = NewDirectoryString(at.syntax)
+		}
+		desc.Information.EqualityMatch = at.equality
+		desc.Information.Collective = slices.ContainsFunc(builtinCollectiveAttributeTypes, at.oid.Equal)
+		if related, ok := relatedMatchingRules[at.equality.String()]; ok {
+			desc.Information.OrderingMatch = related.ordering
+			desc.Information.SubstringsMatch = related.substrings
+		}
+		// Names are always valid UTF-8, so this cannot fail.
+		_ = r.AddAttributeType(desc)
+	}
+	for _, oc := range builtinObjectClasses {
+		desc := ObjectClassDescription{
+			Identifier: oc.oid,
+			Name:       make([]UnboundedDirectoryString, 0, len(oc.names)),
+		}
+		for _, name := range oc.names {
+			desc.Name = append(desc.Name, NewDirectoryString(name))
+		}
+		desc.Information.Kind = oc.kind
+		_ = r.AddObjectClass(desc)
+	}
+	for _, mr := range builtinMatchingRules {
+		desc := MatchingRuleDescription{
+			Identifier: mr.oid,
+			Name:       make([]UnboundedDirectoryString, 0, len(mr.names)),
+		}
+		for _, name := range mr.names {
+			desc.Name = append(desc.Name, NewDirectoryString(name))
+		}
+		_ = r.AddMatchingRule(desc)
+	}
+	return r
+}
+
+var defaultSchemaRegistry *SchemaRegistry
+var defaultSchemaRegistryOnce sync.Once
+
+// Returns a shared SchemaRegistry created by [NewDefaultSchemaRegistry]. It is
+// used where no other registry is supplied, and must not be modified.
+func DefaultSchemaRegistry() *SchemaRegistry {
+	defaultSchemaRegistryOnce.Do(func() {
+		defaultSchemaRegistry = NewDefaultSchemaRegistry()
+	})
+	return defaultSchemaRegistry
+}
@@ -0,0 +1,382 @@
+package x500
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"slices"
+)
+
+// The context-specific tags of the alternatives of EntryModification.
+const EntryModification_AddAttribute int = 0
+
+const EntryModification_RemoveAttribute int = 1
+
+const EntryModification_AddValues int = 2
+
+const EntryModification_RemoveValues int = 3
+
+const EntryModification_AlterValues int = 4
+
+const EntryModification_ResetValue int = 5
+
+const EntryModification_ReplaceValues int = 6
+
+// Create an EntryModification of the alternative identified by `tag`. `arg`
+// must be an Attribute, an AttributeType, or an AttributeTypeAndValue as
+// required by the alternative.
+func NewEntryModification(tag int, arg any) (EntryModification, error) {
+	modBytes, err := asn1.Marshal(arg)
+	if err != nil {
+		return EntryModification{}, err
+	}
+	return asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        tag,
+		IsCompound: true,
+		Bytes:      modBytes,
+	}, nil
+}
+
+// Decides whether two values of the attribute type `attrType` are equal. This
+// is where an equality matching rule plugs into [DiffAttributes].
+type ValueEqualFunc func(attrType AttributeType, a, b asn1.RawValue) bool
+
+func encodingOf(v asn1.RawValue) []byte {
+	if len(v.FullBytes) > 0 {
+		return v.FullBytes
+	}
+	encoded, err := asn1.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
+
+// Compare two values by their encodings. This is only correct for attribute
+// types whose values have a single encoding and no equality matching rule
+// that ignores differences between them, such as case, so [DiffAttributes]
+// only uses it if it is given as [DiffOptions.ValueEqual].
+func ValuesEncodedEqual(_ AttributeType, a, b asn1.RawValue) bool {
+	return bytes.Equal(encodingOf(a), encodingOf(b))
+}
+
+// Options for [DiffAttributes].
+type DiffOptions struct {
+	// Used to compare values. If nil, the equality matching rules of
+	// [DefaultMatchingRuleRegistry] are used, as by its ValueEqual method.
+	ValueEqual ValueEqualFunc
+
+	// Use replaceValues where it is shorter than adding and removing values.
+	// Only set this if the DSA supports the replaceValues critical extension.
+	ReplaceValues bool
+
+	// Use alterValues to change a single INTEGER value. Where both could be
+	// used, alterValues is used rather than replaceValues.
+	AlterValues bool
+
+	// Use resetValue when the only values to remove are exactly those that
+	// have a context whose fallback is FALSE.
+	ResetValue bool
+}
+
+// A value of an attribute, with or without contexts.
+type diffValue struct {
+	value    asn1.RawValue
+	contexts []Context
+}
+
+func attributeDiffValues(attr *Attribute) []diffValue {
+	if attr == nil {
+		return nil
+	}
+	ret := make([]diffValue, 0, attr.Len())
+	for _, v := range attr.Values {
+		ret = append(ret, diffValue{value: v})
+	}
+	for _, vwc := range attr.ValuesWithContext {
+		ret = append(ret, diffValue{value: vwc.Value, contexts: vwc.ContextList})
+	}
+	return ret
+}
+
+func contextEncodings(contexts []Context) [][]byte {
+	ret := make([][]byte, 0, len(contexts))
+	for _, c := range contexts {
+		encoded, err := asn1.Marshal(c)
+		if err != nil {
+			continue
+		}
+		ret = append(ret, encoded)
+	}
+	slices.SortFunc(ret, bytes.Compare)
+	return ret
+}
+
+// Context lists are compared as sets of encoded contexts.
+func contextListsEqual(a, b []Context) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return slices.EqualFunc(contextEncodings(a), contextEncodings(b), bytes.Equal)
+}
+
+func containsDiffValue(attrType AttributeType, values []diffValue, v diffValue, eq ValueEqualFunc) bool {
+	for _, other := range values {
+		if eq(attrType, other.value, v.value) && contextListsEqual(other.contexts, v.contexts) {
+			return true
+		}
+	}
+	return false
+}
+
+func attributeFromDiffValues(attrType AttributeType, values []diffValue) Attribute {
+	attr := Attribute{Type: attrType}
+	for _, v := range values {
+		if len(v.contexts) == 0 {
+			attr.Values = append(attr.Values, v.value)
+		} else {
+			attr.ValuesWithContext = append(attr.ValuesWithContext, Attribute_valuesWithContext_Item{
+				Value:       v.value,
+				ContextList: v.contexts,
+			})
+		}
+	}
+	return attr
+}
+
+func hasNonFallbackContext(v diffValue) bool {
+	for _, c := range v.contexts {
+		if !c.Fallback {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if `toRemove` is exactly the set of values that resetValue
+// would remove from `old`.
+func isResetSet(toRemove, old []diffValue) bool {
+	n := 0
+	for _, v := range old {
+		if hasNonFallbackContext(v) {
+			n++
+		}
+	}
+	if n != len(toRemove) {
+		return false
+	}
+	for _, v := range toRemove {
+		if !hasNonFallbackContext(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// If both the old and new values are a single INTEGER without contexts,
+// return the difference between them.
+func integerAddend(oldValues, newValues []diffValue) (*big.Int, bool) {
+	if len(oldValues) != 1 || len(newValues) != 1 {
+		return nil, false
+	}
+	o, n := oldValues[0], newValues[0]
+	if len(o.contexts) > 0 || len(n.contexts) > 0 {
+		return nil, false
+	}
+	if o.value.Class != asn1.ClassUniversal || o.value.Tag != asn1.TagInteger ||
+		n.value.Class != asn1.ClassUniversal || n.value.Tag != asn1.TagInteger {
+		return nil, false
+	}
+	oldInt, newInt := new(big.Int), new(big.Int)
+	if _, err := asn1.Unmarshal(encodingOf(o.value), &oldInt); err != nil {
+		return nil, false
+	}
+	if _, err := asn1.Unmarshal(encodingOf(n.value), &newInt); err != nil {
+		return nil, false
+	}
+	return newInt.Sub(newInt, oldInt), true
+}
+
+func diffAttribute(attrType AttributeType, oldAttr, newAttr *Attribute, options *DiffOptions, eq ValueEqualFunc) ([]EntryModification, error) {
+	oldValues := attributeDiffValues(oldAttr)
+	newValues := attributeDiffValues(newAttr)
+	if len(newValues) == 0 {
+		if len(oldValues) == 0 {
+			return nil, nil
+		}
+		mod, err := NewEntryModification(EntryModification_RemoveAttribute, attrType)
+		return []EntryModification{mod}, err
+	}
+	if len(oldValues) == 0 {
+		mod, err := NewEntryModification(EntryModification_AddAttribute, attributeFromDiffValues(attrType, newValues))
+		return []EntryModification{mod}, err
+	}
+	toAdd := make([]diffValue, 0)
+	for _, v := range newValues {
+		if !containsDiffValue(attrType, oldValues, v, eq) {
+			toAdd = append(toAdd, v)
+		}
+	}
+	toRemove := make([]diffValue, 0)
+	for _, v := range oldValues {
+		if !containsDiffValue(attrType, newValues, v, eq) {
+			toRemove = append(toRemove, v)
+		}
+	}
+	if len(toAdd) == 0 && len(toRemove) == 0 {
+		return nil, nil
+	}
+	mods, err := addAndRemoveValues(attrType, toAdd, toRemove, oldValues, options)
+	if err != nil {
+		return nil, err
+	}
+	if len(toAdd) > 0 && len(toRemove) > 0 {
+		// alterValues is the more specific, so it is tried first.
+		if options.AlterValues {
+			if addend, ok := integerAddend(oldValues, newValues); ok {
+				addendBytes, err := asn1.Marshal(addend)
+				if err != nil {
+					return nil, err
+				}
+				atav := pkix.AttributeTypeAndValue{
+					Type:  attrType,
+					Value: asn1.RawValue{FullBytes: addendBytes},
+				}
+				mod, err := NewEntryModification(EntryModification_AlterValues, atav)
+				return []EntryModification{mod}, err
+			}
+		}
+		if options.ReplaceValues {
+			mod, err := NewEntryModification(EntryModification_ReplaceValues, attributeFromDiffValues(attrType, newValues))
+			if err != nil {
+				return nil, err
+			}
+			shorter, err := encodedShorter([]EntryModification{mod}, mods)
+			if err != nil {
+				return nil, err
+			}
+			if shorter {
+				return []EntryModification{mod}, nil
+			}
+		}
+	}
+	return mods, nil
+}
+
+// The addValues and removeValues (or resetValue) modifications that change
+// the values of an attribute.
+func addAndRemoveValues(attrType AttributeType, toAdd, toRemove, oldValues []diffValue, options *DiffOptions) ([]EntryModification, error) {
+	mods := make([]EntryModification, 0, 2)
+	// Values are added before they are removed so that the attribute never
+	// disappears, even momentarily, when all of its values change.
+	if len(toAdd) > 0 {
+		mod, err := NewEntryModification(EntryModification_AddValues, attributeFromDiffValues(attrType, toAdd))
+		if err != nil {
+			return nil, err
+		}
+		mods = append(mods, mod)
+	}
+	if len(toRemove) > 0 {
+		var mod EntryModification
+		var err error
+		if options.ResetValue && isResetSet(toRemove, oldValues) {
+			mod, err = NewEntryModification(EntryModification_ResetValue, attrType)
+		} else {
+			mod, err = NewEntryModification(EntryModification_RemoveValues, attributeFromDiffValues(attrType, toRemove))
+		}
+		if err != nil {
+			return nil, err
+		}
+		mods = append(mods, mod)
+	}
+	return mods, nil
+}
+
+// Whether the modifications `a` have a shorter encoding than `b`.
+func encodedShorter(a, b []EntryModification) (bool, error) {
+	length := func(mods []EntryModification) (int, error) {
+		n := 0
+		for _, mod := range mods {
+			encoded, err := asn1.Marshal(mod)
+			if err != nil {
+				return 0, err
+			}
+			n += len(encoded)
+		}
+		return n, nil
+	}
+	lenA, err := length(a)
+	if err != nil {
+		return false, err
+	}
+	lenB, err := length(b)
+	if err != nil {
+		return false, err
+	}
+	return lenA < lenB, nil
+}
+
+// Group attributes by type, merging multiple attributes of the same type,
+// and preserving the order in which types first appear.
+func groupAttributes(attrs []Attribute) (order []AttributeType, byType map[string]*Attribute) {
+	byType = make(map[string]*Attribute, len(attrs))
+	order = make([]AttributeType, 0, len(attrs))
+	for _, attr := range attrs {
+		key := attr.Type.String()
+		existing, seen := byType[key]
+		if !seen {
+			merged := Attribute{Type: attr.Type}
+			existing = &merged
+			byType[key] = existing
+			order = append(order, attr.Type)
+		}
+		existing.Values = append(existing.Values, attr.Values...)
+		existing.ValuesWithContext = append(existing.ValuesWithContext, attr.ValuesWithContext...)
+	}
+	return order, byType
+}
+
+// Compute the modifications that turn the attributes `oldAttrs` into `newAttrs`,
+// such as for the changes of a modifyEntry operation. Attribute types only
+// present in `oldAttrs` are removed. Two values are the same if they are equal
+// according to `options.ValueEqual`, which defaults to the equality matching
+// rules of their attribute types, and have the same contexts; changing the
+// contexts of a value removes it and adds it back with the new contexts.
+//
+// For each attribute type, at most two modifications are produced. Where
+// values are both added and removed, replaceValues or alterValues are used
+// instead if permitted by `options`, which may be nil.
+func DiffAttributes(oldAttrs, newAttrs []Attribute, options *DiffOptions) ([]EntryModification, error) {
+	if options == nil {
+		options = &DiffOptions{}
+	}
+	eq := options.ValueEqual
+	if eq == nil {
+		eq = DefaultMatchingRuleRegistry().ValueEqual(nil)
+	}
+	oldOrder, oldByType := groupAttributes(oldAttrs)
+	newOrder, newByType := groupAttributes(newAttrs)
+	mods := make([]EntryModification, 0)
+	for _, attrType := range newOrder {
+		key := attrType.String()
+		attrMods, err := diffAttribute(attrType, oldByType[key], newByType[key], options, eq)
+		if err != nil {
+			return nil, err
+		}
+		mods = append(mods, attrMods...)
+	}
+	for _, attrType := range oldOrder {
+		key := attrType.String()
+		if _, inNew := newByType[key]; inNew {
+			continue
+		}
+		attrMods, err := diffAttribute(attrType, oldByType[key], nil, options, eq)
+		if err != nil {
+			return nil, err
+		}
+		mods = append(mods, attrMods...)
+	}
+	return mods, nil
+}
@@ -0,0 +1,243 @@
+package x500
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"strings"
+	"testing"
+)
+
+func encodeInt(i int) asn1.RawValue {
+	bytes, _ := asn1.Marshal(i)
+	ret := asn1.RawValue{}
+	asn1.Unmarshal(bytes, &ret)
+	return ret
+}
+
+func decodeModification[T any](t *testing.T, mod EntryModification, out *T) {
+	_, err := asn1.Unmarshal(mod.Bytes, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffAttributesAddAndRemove(t *testing.T) {
+	old := []Attribute{
+		{Type: Id_at_surname, Values: []asn1.RawValue{encodeString("Squarepants")}},
+	}
+	new := []Attribute{
+		{Type: Id_at_commonName, Values: []asn1.RawValue{encodeString("Spongebob")}},
+	}
+	mods, err := DiffAttributes(old, new, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 2 {
+		t.Errorf("expected 2 modifications, got %d", len(mods))
+		return
+	}
+	if mods[0].Tag != EntryModification_AddAttribute {
+		t.Errorf("first modification was %d", mods[0].Tag)
+		return
+	}
+	if mods[1].Tag != EntryModification_RemoveAttribute {
+		t.Errorf("second modification was %d", mods[1].Tag)
+		return
+	}
+	var removed asn1.ObjectIdentifier
+	decodeModification(t, mods[1], &removed)
+	if !removed.Equal(Id_at_surname) {
+		t.Errorf("removed %s", removed)
+		return
+	}
+}
+
+func TestDiffAttributesValues(t *testing.T) {
+	old := []Attribute{
+		{Type: Id_at_commonName, Values: []asn1.RawValue{encodeString("Spongebob"), encodeString("Bob")}},
+	}
+	new := []Attribute{
+		{Type: Id_at_commonName, Values: []asn1.RawValue{encodeString("Spongebob"), encodeString("Sponge")}},
+	}
+	mods, err := DiffAttributes(old, new, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 2 || mods[0].Tag != EntryModification_AddValues || mods[1].Tag != EntryModification_RemoveValues {
+		t.Errorf("unexpected modifications: %v", mods)
+		return
+	}
+	added := Attribute{}
+	decodeModification(t, mods[0], &added)
+	if len(added.Values) != 1 || string(added.Values[0].Bytes) != "Sponge" {
+		t.Errorf("wrong values added: %v", added.Values)
+		return
+	}
+	removed := Attribute{}
+	decodeModification(t, mods[1], &removed)
+	if len(removed.Values) != 1 || string(removed.Values[0].Bytes) != "Bob" {
+		t.Errorf("wrong values removed: %v", removed.Values)
+		return
+	}
+
+	mods, err = DiffAttributes(old, new, &DiffOptions{ReplaceValues: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 1 || mods[0].Tag != EntryModification_ReplaceValues {
+		t.Errorf("expected replaceValues: %v", mods)
+		return
+	}
+	replaced := Attribute{}
+	decodeModification(t, mods[0], &replaced)
+	if len(replaced.Values) != 2 {
+		t.Errorf("replaceValues had %d values", len(replaced.Values))
+		return
+	}
+
+	// Replacing many values to change one of them is longer than adding and
+	// removing a value.
+	many := []asn1.RawValue{encodeString("Spongebob Squarepants"), encodeString("Sponge Robert"), encodeString("SpongeBob")}
+	old = []Attribute{{Type: Id_at_commonName, Values: append([]asn1.RawValue{encodeString("Bob")}, many...)}}
+	new = []Attribute{{Type: Id_at_commonName, Values: append([]asn1.RawValue{encodeString("Sponge")}, many...)}}
+	mods, err = DiffAttributes(old, new, &DiffOptions{ReplaceValues: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 2 || mods[0].Tag != EntryModification_AddValues || mods[1].Tag != EntryModification_RemoveValues {
+		t.Errorf("expected addValues and removeValues: %v", mods)
+		return
+	}
+}
+
+func TestDiffAttributesNoChanges(t *testing.T) {
+	old := []Attribute{
+		{Type: Id_at_commonName, Values: []asn1.RawValue{encodeString("Spongebob")}},
+	}
+	new := []Attribute{
+		{Type: Id_at_commonName, Values: []asn1.RawValue{encodeString("SPONGEBOB")}},
+	}
+	caseIgnore := func(_ AttributeType, a, b asn1.RawValue) bool {
+		return strings.EqualFold(string(a.Bytes), string(b.Bytes))
+	}
+	mods, err := DiffAttributes(old, new, &DiffOptions{ValueEqual: caseIgnore})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 0 {
+		t.Errorf("expected no modifications, got %d", len(mods))
+		return
+	}
+
+	// By default, commonName values are compared by caseIgnoreMatch.
+	mods, err = DiffAttributes(old, new, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 0 {
+		t.Errorf("expected no modifications, got %d", len(mods))
+		return
+	}
+	mods, err = DiffAttributes(old, new, &DiffOptions{ValueEqual: ValuesEncodedEqual})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 2 {
+		t.Errorf("expected the values to differ by their encodings, but got %d modifications", len(mods))
+		return
+	}
+}
+
+func TestDiffAttributesAlterValues(t *testing.T) {
+	old := []Attribute{{Type: Id_at_uniqueIdentifier, Values: []asn1.RawValue{encodeInt(5)}}}
+	new := []Attribute{{Type: Id_at_uniqueIdentifier, Values: []asn1.RawValue{encodeInt(3)}}}
+	mods, err := DiffAttributes(old, new, &DiffOptions{AlterValues: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 1 || mods[0].Tag != EntryModification_AlterValues {
+		t.Errorf("expected alterValues: %v", mods)
+		return
+	}
+	atav := pkix.AttributeTypeAndValue{}
+	decodeModification(t, mods[0], &atav)
+	if addend, ok := atav.Value.(int64); !ok || addend != -2 {
+		t.Errorf("addend was %v", atav.Value)
+		return
+	}
+
+	// alterValues is used rather than replaceValues, which is shorter than
+	// adding and removing the values.
+	mods, err = DiffAttributes(old, new, &DiffOptions{AlterValues: true, ReplaceValues: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 1 || mods[0].Tag != EntryModification_AlterValues {
+		t.Errorf("expected alterValues: %v", mods)
+		return
+	}
+	mods, err = DiffAttributes(old, new, &DiffOptions{ReplaceValues: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 1 || mods[0].Tag != EntryModification_ReplaceValues {
+		t.Errorf("expected replaceValues: %v", mods)
+		return
+	}
+}
+
+func TestDiffAttributesContexts(t *testing.T) {
+	old := []Attribute{
+		{
+			Type: Id_at_commonName,
+			ValuesWithContext: []Attribute_valuesWithContext_Item{
+				addLanguageContext(encodeString("Spongebob"), "en"),
+			},
+		},
+	}
+	new := []Attribute{
+		{
+			Type: Id_at_commonName,
+			ValuesWithContext: []Attribute_valuesWithContext_Item{
+				addLanguageContext(encodeString("Spongebob"), "es"),
+			},
+		},
+	}
+	mods, err := DiffAttributes(old, new, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 2 {
+		t.Errorf("expected 2 modifications, got %d", len(mods))
+		return
+	}
+	mods, err = DiffAttributes(old, old, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 0 {
+		t.Errorf("expected no modifications, got %d", len(mods))
+		return
+	}
+	mods, err = DiffAttributes(old, []Attribute{}, &DiffOptions{ResetValue: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 1 || mods[0].Tag != EntryModification_RemoveAttribute {
+		t.Errorf("expected removeAttribute: %v", mods)
+		return
+	}
+}
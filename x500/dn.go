@@ -0,0 +1,419 @@
+package x500
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// The order and punctuation of a distinguished name in string form.
+type DNStyle int
+
+const (
+	// The string representation of IETF RFC 4514: the least significant RDN
+	// first, separated by commas, as in "CN=Steve Kille,O=Isode Limited,C=GB".
+	DNStyleRFC4514 DNStyle = iota
+
+	// The most significant RDN first, separated by commas, which is the order
+	// in which RDNs appear in the DistinguishedName itself and in ITU-T
+	// Recommendation X.501, as in "C=GB,O=Isode Limited,CN=Steve Kille".
+	DNStyleX500
+)
+
+// Options for parsing and formatting distinguished names.
+type DNOptions struct {
+	Style DNStyle
+
+	// Used to resolve attribute type names and determine the string syntaxes
+	// of attribute values. If nil, [DefaultSchemaRegistry] is used.
+	Schema *SchemaRegistry
+}
+
+func (o *DNOptions) schema() *SchemaRegistry {
+	if o == nil || o.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return o.Schema
+}
+
+func (o *DNOptions) style() DNStyle {
+	if o == nil {
+		return DNStyleRFC4514
+	}
+	return o.Style
+}
+
+// Returns the encoding parameter that encoding/asn1 uses for values of
+// `syntax`, which may be the name of an ASN.1 type, as published in X.500
+// subschema, or an LDAP syntax OID.
+func stringSyntaxParams(syntax string) (params string, ok bool) {
+	switch syntax {
+	case "UnboundedDirectoryString",
+		"UTF8String",
+		"DomainName",
+		"URI",
+		"1.3.6.1.4.1.1466.115.121.1.15": // Directory String
+		return "utf8", true
+	case "PrintableString",
+		"CountryName",
+		"TelephoneNumber",
+		"1.3.6.1.4.1.1466.115.121.1.11", // Country String
+		"1.3.6.1.4.1.1466.115.121.1.44", // Printable String
+		"1.3.6.1.4.1.1466.115.121.1.50": // Telephone Number
+		return "printable", true
+	case "IA5String",
+		"1.3.6.1.4.1.1466.115.121.1.26": // IA5 String
+		return "ia5", true
+	case "NumericString",
+		"1.3.6.1.4.1.1466.115.121.1.36": // Numeric String
+		return "numeric", true
+	}
+	if strings.HasPrefix(syntax, "DirectoryString") {
+		return "utf8", true
+	}
+	return "", false
+}
+
+type dnParser struct {
+	s      string
+	pos    int
+	schema *SchemaRegistry
+}
+
+func (p *dnParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *dnParser) skipSpaces() {
+	for !p.eof() && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *dnParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid distinguished name at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func isAttributeTypeChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		c == '-' || c == '.'
+}
+
+func (p *dnParser) parseAttributeType() (AttributeType, error) {
+	start := p.pos
+	for !p.eof() && isAttributeTypeChar(p.s[p.pos]) {
+		p.pos++
+	}
+	descr := p.s[start:p.pos]
+	if len(descr) == 0 {
+		return nil, p.errorf("expected an attribute type")
+	}
+	// The "OID." prefix was permitted by IETF RFC 1779.
+	if len(descr) > 4 && strings.EqualFold(descr[:4], "oid.") {
+		descr = descr[4:]
+	}
+	if descr[0] >= '0' && descr[0] <= '9' {
+		oid, err := stringToOID(descr)
+		if err != nil || len(oid) < 2 {
+			return nil, p.errorf("invalid object identifier %q", descr)
+		}
+		return oid, nil
+	}
+	at := p.schema.AttributeType(descr)
+	if at == nil {
+		return nil, p.errorf("unrecognized attribute type %q", descr)
+	}
+	return at.Identifier, nil
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func (p *dnParser) parseHexValue() (asn1.RawValue, error) {
+	p.pos++ // Skip the '#'.
+	start := p.pos
+	for !p.eof() && isHexDigit(p.s[p.pos]) {
+		p.pos++
+	}
+	encoded, err := hex.DecodeString(p.s[start:p.pos])
+	if err != nil || len(encoded) == 0 {
+		return asn1.RawValue{}, p.errorf("invalid hexadecimal value")
+	}
+	var value asn1.RawValue
+	rest, err := asn1.Unmarshal(encoded, &value)
+	if err != nil {
+		return value, p.errorf("invalid encoding of value: %v", err)
+	}
+	if len(rest) > 0 {
+		return value, p.errorf("trailing bytes after value")
+	}
+	return value, nil
+}
+
+// Parse a string value up to the next unescaped ',' or '+', removing escapes
+// and unescaped trailing spaces.
+func (p *dnParser) parseStringValue() (string, error) {
+	var b strings.Builder
+	// Length of `b` up to the end of the last escaped or non-space character.
+	significant := 0
+	for !p.eof() {
+		c := p.s[p.pos]
+		if c == ',' || c == '+' {
+			break
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			if c != ' ' {
+				significant = b.Len()
+			}
+			continue
+		}
+		p.pos++
+		if p.eof() {
+			return "", p.errorf("unterminated escape")
+		}
+		c = p.s[p.pos]
+		if isHexDigit(c) {
+			if p.pos+1 >= len(p.s) || !isHexDigit(p.s[p.pos+1]) {
+				return "", p.errorf("invalid hexadecimal escape")
+			}
+			decoded, _ := hex.DecodeString(p.s[p.pos : p.pos+2])
+			b.WriteByte(decoded[0])
+			p.pos += 2
+		} else if strings.IndexByte("\"+,;<>\\=# ", c) >= 0 {
+			b.WriteByte(c)
+			p.pos++
+		} else {
+			return "", p.errorf("invalid escaped character %q", c)
+		}
+		significant = b.Len()
+	}
+	return b.String()[:significant], nil
+}
+
+// Encode `s` as a value of `attrType`, using the string syntax of the
+// attribute type from `schema`.
+func encodeStringValue(schema *SchemaRegistry, attrType AttributeType, s string) (asn1.RawValue, error) {
+	resolved, err := schema.ResolveAttributeType(attrType.String())
+	if err != nil {
+		return asn1.RawValue{}, fmt.Errorf("values of %s must be given as #-prefixed hexadecimal: %w", attrType, err)
+	}
+	params, ok := stringSyntaxParams(resolved.Syntax)
+	if !ok {
+		return asn1.RawValue{}, fmt.Errorf("values of %s have no string encoding and must be given as #-prefixed hexadecimal", attrType)
+	}
+	if !utf8.ValidString(s) {
+		return asn1.RawValue{}, fmt.Errorf("invalid utf-8 in value of %s", attrType)
+	}
+	encoded, err := asn1.MarshalWithParams(s, params)
+	if err != nil {
+		return asn1.RawValue{}, fmt.Errorf("invalid value of %s: %w", attrType, err)
+	}
+	var value asn1.RawValue
+	_, err = asn1.Unmarshal(encoded, &value)
+	return value, err
+}
+
+func (p *dnParser) parseAttributeTypeAndValue() (atav pkix.AttributeTypeAndValue, err error) {
+	p.skipSpaces()
+	atav.Type, err = p.parseAttributeType()
+	if err != nil {
+		return atav, err
+	}
+	p.skipSpaces()
+	if p.eof() || p.s[p.pos] != '=' {
+		return atav, p.errorf("expected '='")
+	}
+	p.pos++
+	p.skipSpaces()
+	if !p.eof() && p.s[p.pos] == '#' {
+		atav.Value, err = p.parseHexValue()
+		return atav, err
+	}
+	s, err := p.parseStringValue()
+	if err != nil {
+		return atav, err
+	}
+	atav.Value, err = encodeStringValue(p.schema, atav.Type, s)
+	return atav, err
+}
+
+func (p *dnParser) parseRDN() (RelativeDistinguishedName, error) {
+	rdn := make(RelativeDistinguishedName, 0, 1)
+	for {
+		atav, err := p.parseAttributeTypeAndValue()
+		if err != nil {
+			return nil, err
+		}
+		rdn = append(rdn, atav)
+		p.skipSpaces()
+		if p.eof() || p.s[p.pos] != '+' {
+			return rdn, nil
+		}
+		p.pos++
+	}
+}
+
+// Parse a distinguished name in the string representation of IETF RFC 4514,
+// resolving attribute type names using [DefaultSchemaRegistry]. See
+// [ParseDNWithOptions].
+func ParseDN(s string) (DistinguishedName, error) {
+	return ParseDNWithOptions(s, nil)
+}
+
+// Parse a distinguished name from a string in the style given by `options`,
+// which may be nil. Attribute types may be given by name or in dotted-decimal
+// form. Values may be strings, with the escapes of IETF RFC 4514, or a '#'
+// followed by the hexadecimal BER encoding of the value. String values are
+// encoded using the syntax of the attribute type in `options.Schema`, and
+// attribute types with no string syntax require hexadecimal values. Spaces
+// around separators are ignored. An empty string is the empty DN.
+func ParseDNWithOptions(s string, options *DNOptions) (DistinguishedName, error) {
+	p := dnParser{s: s, schema: options.schema()}
+	dn := make(DistinguishedName, 0)
+	p.skipSpaces()
+	if p.eof() {
+		return dn, nil
+	}
+	for {
+		rdn, err := p.parseRDN()
+		if err != nil {
+			return nil, err
+		}
+		dn = append(dn, rdn)
+		if p.eof() {
+			break
+		}
+		if p.s[p.pos] != ',' {
+			return nil, p.errorf("expected ','")
+		}
+		p.pos++
+	}
+	if options.style() == DNStyleRFC4514 {
+		for i, j := 0, len(dn)-1; i < j; i, j = i+1, j-1 {
+			dn[i], dn[j] = dn[j], dn[i]
+		}
+	}
+	return dn, nil
+}
+
+// Escape a string value as described in IETF RFC 4514, Section 2.4.
+func escapeDNValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 0:
+			b.WriteString("\\00")
+			continue
+		case strings.IndexByte("\"+,;<>\\", c) >= 0,
+			i == 0 && (c == ' ' || c == '#'),
+			i == len(s)-1 && c == ' ':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func isStringTag(tag int) bool {
+	switch tag {
+	case asn1.TagUTF8String,
+		asn1.TagPrintableString,
+		asn1.TagT61String,
+		asn1.TagIA5String,
+		asn1.TagNumericString,
+		asn1.TagBMPString,
+		28: // UniversalString
+		return true
+	}
+	return false
+}
+
+// Returns the string form of `value` if it is a string.
+func dnValueString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case asn1.RawValue:
+		if v.Class != asn1.ClassUniversal || !isStringTag(v.Tag) {
+			return "", false
+		}
+		s, err := DirectoryStringToString(v)
+		return s, err == nil
+	}
+	return "", false
+}
+
+func formatAttributeTypeAndValue(schema *SchemaRegistry, atav pkix.AttributeTypeAndValue) (string, error) {
+	var typeName string
+	if at := schema.AttributeType(atav.Type.String()); at != nil && len(at.Name) > 0 {
+		name, err := DirectoryStringToString(at.Name[0])
+		if err != nil {
+			return "", err
+		}
+		if s, ok := dnValueString(atav.Value); ok {
+			return name + "=" + escapeDNValue(s), nil
+		}
+		typeName = name
+	} else {
+		// Values of attribute types in dotted-decimal form are always given in
+		// hexadecimal, as required by IETF RFC 4514, Section 2.4.
+		typeName = atav.Type.String()
+	}
+	var encoded []byte
+	var err error
+	if raw, ok := atav.Value.(asn1.RawValue); ok {
+		encoded = encodingOf(raw)
+	} else {
+		encoded, err = asn1.Marshal(atav.Value)
+	}
+	if err != nil || len(encoded) == 0 {
+		return "", fmt.Errorf("could not encode value of %s", atav.Type)
+	}
+	return typeName + "=#" + hex.EncodeToString(encoded), nil
+}
+
+// Format a distinguished name in the string representation of IETF RFC 4514,
+// using names from [DefaultSchemaRegistry]. See [FormatDNWithOptions].
+func FormatDN(dn DistinguishedName) (string, error) {
+	return FormatDNWithOptions(dn, nil)
+}
+
+// Format a distinguished name as a string in the style given by `options`,
+// which may be nil. Attribute types are given by their first name in
+// `options.Schema`, or in dotted-decimal form if they are not registered.
+// String values are escaped as described in IETF RFC 4514; other values are
+// given as a '#' followed by the hexadecimal encoding of the value.
+func FormatDNWithOptions(dn DistinguishedName, options *DNOptions) (string, error) {
+	schema := options.schema()
+	rdns := make([]string, len(dn))
+	for i, rdn := range dn {
+		if len(rdn) == 0 {
+			return "", errors.New("empty relative distinguished name")
+		}
+		atavs := make([]string, len(rdn))
+		for j, atav := range rdn {
+			s, err := formatAttributeTypeAndValue(schema, atav)
+			if err != nil {
+				return "", err
+			}
+			atavs[j] = s
+		}
+		rdns[i] = strings.Join(atavs, "+")
+	}
+	if options.style() == DNStyleRFC4514 {
+		for i, j := 0, len(rdns)-1; i < j; i, j = i+1, j-1 {
+			rdns[i], rdns[j] = rdns[j], rdns[i]
+		}
+	}
+	return strings.Join(rdns, ","), nil
+}
@@ -0,0 +1,141 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func TestParseDN(t *testing.T) {
+	dn, err := ParseDN("UID=jsmith,DC=example,DC=net")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(dn) != 3 {
+		t.Errorf("expected 3 RDNs, got %d", len(dn))
+		return
+	}
+	if !dn[0][0].Type.Equal(Id_coat_dc) || !dn[2][0].Type.Equal(Id_coat_uid) {
+		t.Errorf("RDNs were not in the right order: %v", dn)
+		return
+	}
+	dc, ok := dn[0][0].Value.(asn1.RawValue)
+	if !ok || dc.Tag != asn1.TagIA5String || string(dc.Bytes) != "net" {
+		t.Errorf("dc was not encoded as an IA5String: %v", dn[0][0].Value)
+		return
+	}
+	uid, ok := dn[2][0].Value.(asn1.RawValue)
+	if !ok || uid.Tag != asn1.TagUTF8String || string(uid.Bytes) != "jsmith" {
+		t.Errorf("uid was not encoded as a UTF8String: %v", dn[2][0].Value)
+		return
+	}
+}
+
+func TestParseDNEscapes(t *testing.T) {
+	dn, err := ParseDN(`CN=James \"Jim\" Smith\, III , OU=Sales+CN=J.  Smith,1.3.6.1.4.1.1466.0=#04024869,CN=Lu\C4\8Di\C4\87`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(dn) != 4 {
+		t.Errorf("expected 4 RDNs, got %d", len(dn))
+		return
+	}
+	cn, _ := dnValueString(dn[3][0].Value)
+	if cn != `James "Jim" Smith, III` {
+		t.Errorf("unescaped value was %q", cn)
+		return
+	}
+	if len(dn[2]) != 2 {
+		t.Errorf("expected a multi-valued RDN: %v", dn[2])
+		return
+	}
+	hexValue := dn[1][0].Value.(asn1.RawValue)
+	if hexValue.Tag != asn1.TagOctetString || string(hexValue.Bytes) != "Hi" {
+		t.Errorf("hex value was %v", hexValue)
+		return
+	}
+	lucic, _ := dnValueString(dn[0][0].Value)
+	if lucic != "Lučić" {
+		t.Errorf("utf-8 value was %q", lucic)
+		return
+	}
+}
+
+func TestParseDNErrors(t *testing.T) {
+	for _, s := range []string{
+		"CN=Bob,",
+		"notAnAttribute=Bob",
+		"C=Ünited",
+		"CN=Bob\\",
+		"CN",
+		"objectClass=person",
+	} {
+		if _, err := ParseDN(s); err == nil {
+			t.Errorf("parsing %q should have failed", s)
+			return
+		}
+	}
+	dn, err := ParseDN("")
+	if err != nil || len(dn) != 0 {
+		t.Errorf("empty string should be the empty DN: %v %v", dn, err)
+		return
+	}
+}
+
+func TestFormatDN(t *testing.T) {
+	for _, s := range []string{
+		"UID=jsmith,DC=example,DC=net",
+		"OU=Sales+CN=J.  Smith,DC=example,DC=net",
+		`CN=James \"Jim\" Smith\, III,DC=example,DC=net`,
+		`CN=\#1\ ,O=Isode Limited,C=GB`,
+		"1.3.6.1.4.1.1466.0=#04024869,DC=example,DC=com",
+		"objectClass=#0603550406",
+	} {
+		dn, err := ParseDN(s)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		formatted, err := FormatDN(dn)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if formatted != s {
+			t.Errorf("%q was formatted as %q", s, formatted)
+			return
+		}
+	}
+}
+
+func TestDNStyleX500(t *testing.T) {
+	options := &DNOptions{Style: DNStyleX500}
+	dn, err := ParseDNWithOptions("c=GB, o=Isode Limited, cn=Steve Kille", options)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !dn[0][0].Type.Equal(Id_at_countryName) {
+		t.Errorf("first RDN was %v", dn[0])
+		return
+	}
+	formatted, err := FormatDNWithOptions(dn, options)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if formatted != "C=GB,O=Isode Limited,CN=Steve Kille" {
+		t.Errorf("formatted as %q", formatted)
+		return
+	}
+	rfc4514, err := FormatDN(dn)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if rfc4514 != "CN=Steve Kille,O=Isode Limited,C=GB" {
+		t.Errorf("formatted as %q", rfc4514)
+		return
+	}
+}
@@ -0,0 +1,453 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Converts a value into a string that is the same for every value that
+// matches it under an equality matching rule.
+type dnValueNormalizer func(schema *SchemaRegistry, value asn1.RawValue) (string, error)
+
+// Returns the normalizer for the equality matching rule identified by `rule`,
+// or nil if it is not one that this package can apply to values in
+// distinguished names, in which case values are compared by their encodings.
+func dnValueNormalizerFor(rule asn1.ObjectIdentifier) dnValueNormalizer {
+	switch {
+	case rule.Equal(Id_mr_caseIgnoreMatch) || rule.Equal(Id_lmr_caseIgnoreIA5Match):
+		return normalizeCaseIgnore
+	case rule.Equal(Id_mr_caseExactMatch) || rule.Equal(Id_lmr_caseExactIA5Match):
+		return normalizeCaseExact
+	case rule.Equal(Id_mr_caseIgnoreListMatch):
+		return normalizeCaseIgnoreList
+	case rule.Equal(Id_mr_numericStringMatch):
+		return normalizeNumericString
+	case rule.Equal(Id_mr_telephoneNumberMatch):
+		return normalizeTelephoneNumber
+	case rule.Equal(Id_mr_facsimileNumberMatch):
+		return normalizeFacsimileNumber
+	case rule.Equal(Id_mr_objectIdentifierMatch):
+		return normalizeObjectIdentifier
+	case rule.Equal(Id_mr_distinguishedNameMatch):
+		return normalizeDistinguishedName
+	case rule.Equal(Id_mr_uniqueMemberMatch):
+		return normalizeUniqueMember
+	case rule.Equal(Id_mr_dnsNameMatch):
+		return normalizeDNSName
+	case rule.Equal(Id_mr_intEmailMatch) || rule.Equal(Id_mr_jidMatch):
+		return normalizeEmailAddress
+	case rule.Equal(Id_mr_uriMatch):
+		return normalizeURI
+	case rule.Equal(Id_mr_generalizedTimeMatch) || rule.Equal(Id_mr_uTCTimeMatch):
+		return normalizeTime
+	case rule.Equal(Id_mr_uuidpairmatch):
+		return normalizeUUIDPair
+	}
+	return nil
+}
+
+// Remove leading and trailing spaces and collapse internal spaces to one, as
+// in the string preparation of ITU-T Recommendation X.520, Section 7.6.
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func normalizeCaseIgnore(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	s, err := DirectoryStringToString(value)
+	if err != nil {
+		return "", err
+	}
+	return PrepareString(s, true)
+}
+
+func normalizeCaseExact(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	s, err := DirectoryStringToString(value)
+	if err != nil {
+		return "", err
+	}
+	return PrepareString(s, false)
+}
+
+// The strings of a caseIgnoreList are compared in order. They are joined with
+// a character that string preparation would have mapped to a space, so that
+// lists with different boundaries do not match.
+func normalizeCaseIgnoreList(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var list []asn1.RawValue
+	if err := unmarshalExactly(value, &list); err != nil {
+		return "", err
+	}
+	prepared := make([]string, 0, len(list))
+	for _, item := range list {
+		s, err := normalizeCaseIgnore(schema, item)
+		if err != nil {
+			return "", err
+		}
+		prepared = append(prepared, s)
+	}
+	return strings.Join(prepared, "\n"), nil
+}
+
+func normalizeNumericString(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	s, err := DirectoryStringToString(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(s, " ", ""), nil
+}
+
+func normalizeTelephoneNumber(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	s, err := DirectoryStringToString(value)
+	if err != nil {
+		return "", err
+	}
+	s = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.ToLower(s), nil
+}
+
+// Only the telephone number of a facsimileTelephoneNumber is compared.
+func normalizeFacsimileNumber(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var number struct {
+		TelephoneNumber asn1.RawValue
+		Parameters      asn1.RawValue `asn1:"optional"`
+	}
+	if err := unmarshalExactly(value, &number); err != nil {
+		return "", err
+	}
+	return normalizeTelephoneNumber(schema, number.TelephoneNumber)
+}
+
+func normalizeObjectIdentifier(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var oid asn1.ObjectIdentifier
+	if err := unmarshalExactly(value, &oid); err != nil {
+		return "", err
+	}
+	return oid.String(), nil
+}
+
+func normalizeDistinguishedName(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var dn DistinguishedName
+	if err := unmarshalExactly(value, &dn); err != nil {
+		return "", err
+	}
+	return (&DNMatcher{Schema: schema}).CanonicalDN(dn)
+}
+
+func normalizeUniqueMember(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var name NameAndOptionalUID
+	if err := unmarshalExactly(value, &name); err != nil {
+		return "", err
+	}
+	dn, err := (&DNMatcher{Schema: schema}).CanonicalDN(name.Dn)
+	if err != nil {
+		return "", err
+	}
+	if name.Uid.BitLength == 0 {
+		return dn, nil
+	}
+	return fmt.Sprintf("%s#%d:%x", dn, name.Uid.BitLength, name.Uid.Bytes), nil
+}
+
+func normalizeDNSName(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	s, err := DirectoryStringToString(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.ToLower(s), "."), nil
+}
+
+// The domain of an email address or Jabber ID is not case-sensitive, but the
+// local part is.
+func normalizeEmailAddress(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	s, err := DirectoryStringToString(value)
+	if err != nil {
+		return "", err
+	}
+	local, domain, found := strings.Cut(s, "@")
+	if !found {
+		return s, nil
+	}
+	domain, resource, _ := strings.Cut(domain, "/")
+	s = local + "@" + strings.ToLower(domain)
+	if resource != "" {
+		s += "/" + resource
+	}
+	return s, nil
+}
+
+// The scheme and host of a URI are not case-sensitive, but the rest of it
+// is. A value that cannot be parsed is compared exactly.
+func normalizeURI(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	s, err := DirectoryStringToString(value)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return s, nil
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return u.String(), nil
+}
+
+// Times are compared as instants, regardless of the time zone in which they
+// are expressed.
+func normalizeTime(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var t time.Time
+	if err := unmarshalExactly(value, &t); err != nil {
+		return "", err
+	}
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
+
+func normalizeUUIDPair(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var pair UUIDPair
+	if err := unmarshalExactly(value, &pair); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(pair.IssuerUUID) + ":" + hex.EncodeToString(pair.SubjectUUID), nil
+}
+
+func normalizeOctetString(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var b []byte
+	if err := unmarshalExactly(value, &b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Values of types with a single encoding, such as BOOLEAN and INTEGER, are
+// compared by their encodings.
+func normalizeEncoding(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	encoded := encodingOf(value)
+	if len(encoded) == 0 {
+		return "", errors.New("missing value")
+	}
+	return string(encoded), nil
+}
+
+func unmarshalExactly(value asn1.RawValue, out any) error {
+	rest, err := asn1.Unmarshal(encodingOf(value), out)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("trailing bytes")
+	}
+	return nil
+}
+
+// Returns the value of an attribute type and value as an asn1.RawValue. The
+// value may have been decoded into a Go type, such as by asn1.Unmarshal.
+func dnRawValue(value any) (asn1.RawValue, error) {
+	if rv, ok := value.(asn1.RawValue); ok && len(rv.FullBytes) > 0 {
+		return rv, nil
+	}
+	encoded, err := asn1.Marshal(value)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	var rv asn1.RawValue
+	_, err = asn1.Unmarshal(encoded, &rv)
+	return rv, err
+}
+
+// Compares distinguished names using the equality matching rules of the
+// attribute types in `Schema`, as described for `distinguishedNameMatch` in
+// ITU-T Recommendation X.520, Section 8.1.9. If `Schema` is nil,
+// [DefaultSchemaRegistry] is used. A nil *DNMatcher may be used.
+type DNMatcher struct {
+	Schema *SchemaRegistry
+
+	// If set, values are normalized by the Normalize functions of the
+	// equality matching rules in this registry, where they have one, in
+	// preference to those built into this package.
+	MatchingRules *MatchingRuleRegistry
+}
+
+func (m *DNMatcher) schema() *SchemaRegistry {
+	if m == nil || m.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return m.Schema
+}
+
+// Returns a function that normalizes values under the equality matching rule
+// identified by `rule`, or nil if there is none.
+func (m *DNMatcher) normalizerFor(rule asn1.ObjectIdentifier) func(asn1.RawValue) (string, error) {
+	if m != nil && m.MatchingRules != nil {
+		if impl := m.MatchingRules.Get(rule); impl != nil && impl.Normalize != nil {
+			return impl.Normalize
+		}
+	}
+	normalize := dnValueNormalizerFor(rule)
+	if normalize == nil {
+		return nil
+	}
+	schema := m.schema()
+	return func(value asn1.RawValue) (string, error) {
+		return normalize(schema, value)
+	}
+}
+
+// Returns the canonical form of an attribute value: the normalized string if
+// its equality matching rule is known, and `#` followed by the hexadecimal
+// encoding otherwise.
+func (m *DNMatcher) canonicalValue(attrType AttributeType, value any) (string, error) {
+	rv, err := dnRawValue(value)
+	if err != nil {
+		return "", err
+	}
+	var normalize func(asn1.RawValue) (string, error)
+	if at, err := m.schema().ResolveAttributeType(attrType.String()); err == nil && at.EqualityMatch != nil {
+		normalize = m.normalizerFor(at.EqualityMatch)
+	} else if rv.Class == asn1.ClassUniversal && isStringTag(rv.Tag) {
+		normalize = m.normalizerFor(Id_mr_caseExactMatch)
+	}
+	if normalize == nil {
+		return "#" + hex.EncodeToString(encodingOf(rv)), nil
+	}
+	s, err := normalize(rv)
+	if err != nil {
+		return "", fmt.Errorf("invalid value of %s: %w", attrType, err)
+	}
+	return escapeDNValue(s), nil
+}
+
+// Returns the canonical forms of the attribute types and values of `rdn`,
+// sorted, so that they may be compared as sets. If `lenient` is true, values
+// that cannot be normalized are represented by their encodings.
+func (m *DNMatcher) rdnComponents(rdn RelativeDistinguishedName, lenient bool) ([]string, error) {
+	components := make([]string, 0, len(rdn))
+	for _, atav := range rdn {
+		value, err := m.canonicalValue(atav.Type, atav.Value)
+		if err != nil {
+			if !lenient {
+				return nil, err
+			}
+			rv, err := dnRawValue(atav.Value)
+			if err != nil {
+				return nil, err
+			}
+			value = "#!" + hex.EncodeToString(encodingOf(rv))
+		}
+		components = append(components, atav.Type.String()+"="+value)
+	}
+	sort.Strings(components)
+	return components, nil
+}
+
+// Returns a string form of `rdn` that is the same for every RDN that matches
+// it. See [DNMatcher.CanonicalDN].
+func (m *DNMatcher) CanonicalRDN(rdn RelativeDistinguishedName) (string, error) {
+	components, err := m.rdnComponents(rdn, false)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(components, "+"), nil
+}
+
+// Returns a string form of `dn` that is the same for every distinguished name
+// that matches it, so that it may be used as a map key. Attribute types are
+// given as object identifiers, values are normalized according to the
+// equality matching rules of their types, and the attribute types and values
+// of each RDN are sorted. RDNs are in the same order as in `dn`, most
+// significant first. An error is returned if a value is not valid for the
+// equality matching rule of its type.
+func (m *DNMatcher) CanonicalDN(dn DistinguishedName) (string, error) {
+	rdns := make([]string, 0, len(dn))
+	for _, rdn := range dn {
+		s, err := m.CanonicalRDN(rdn)
+		if err != nil {
+			return "", err
+		}
+		rdns = append(rdns, s)
+	}
+	return strings.Join(rdns, ","), nil
+}
+
+// Returns true if `a` and `b` have the same attribute types and matching
+// values, in any order. Values that are not valid for the equality matching
+// rule of their type only match values with the same encoding.
+func (m *DNMatcher) RDNEqual(a, b RelativeDistinguishedName) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	ac, err := m.rdnComponents(a, true)
+	if err != nil {
+		return false
+	}
+	bc, err := m.rdnComponents(b, true)
+	if err != nil {
+		return false
+	}
+	for i := range ac {
+		if ac[i] != bc[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if `a` and `b` have the same number of RDNs and each RDN of
+// `a` matches the corresponding RDN of `b`.
+func (m *DNMatcher) DNEqual(a, b DistinguishedName) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	return m.hasPrefix(b, a)
+}
+
+// Returns true if `ancestor` names a superior of the entry named by
+// `descendant`: that is, if `ancestor` has fewer RDNs than `descendant` and
+// they match the leading RDNs of `descendant`. The empty distinguished name
+// is an ancestor of every other name. A name is not its own ancestor.
+func (m *DNMatcher) DNIsAncestor(ancestor, descendant DistinguishedName) bool {
+	if len(ancestor) >= len(descendant) {
+		return false
+	}
+	return m.hasPrefix(descendant, ancestor)
+}
+
+func (m *DNMatcher) hasPrefix(dn, prefix DistinguishedName) bool {
+	for i := range prefix {
+		if !m.RDNEqual(dn[i], prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare two RDNs using [DefaultSchemaRegistry]. See [DNMatcher.RDNEqual].
+func RDNEqual(a, b RelativeDistinguishedName) bool {
+	return (*DNMatcher)(nil).RDNEqual(a, b)
+}
+
+// Compare two distinguished names using [DefaultSchemaRegistry]. See
+// [DNMatcher.DNEqual].
+func DNEqual(a, b DistinguishedName) bool {
+	return (*DNMatcher)(nil).DNEqual(a, b)
+}
+
+// Returns true if `ancestor` names a superior of `descendant`, using
+// [DefaultSchemaRegistry]. See [DNMatcher.DNIsAncestor].
+func DNIsAncestor(ancestor, descendant DistinguishedName) bool {
+	return (*DNMatcher)(nil).DNIsAncestor(ancestor, descendant)
+}
+
+// Returns the canonical form of `dn` using [DefaultSchemaRegistry]. See
+// [DNMatcher.CanonicalDN].
+func CanonicalDN(dn DistinguishedName) (string, error) {
+	return (*DNMatcher)(nil).CanonicalDN(dn)
+}
@@ -0,0 +1,142 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func mustParseDN(t *testing.T, s string) DistinguishedName {
+	t.Helper()
+	dn, err := ParseDN(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dn
+}
+
+func TestDNEqual(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"CN=Steve  Kille,O=Isode Limited,C=GB", "cn=steve kille , o= ISODE LIMITED,c=gb"},
+		{"OU=Sales+CN=J. Smith,DC=example,DC=net", "CN=j. smith+OU=sales,DC=Example,DC=NET"},
+		{`telephoneNumber=\+1 555-123-4567,DC=net`, `telephoneNumber=\+15551234567,DC=net`},
+		{"x121Address=1234 5678,DC=net", "x121Address=12345678,DC=net"},
+		{"DC=example,DC=net", "DC=example,DC=net"},
+		{"", ""},
+	} {
+		a, b := mustParseDN(t, pair[0]), mustParseDN(t, pair[1])
+		if !DNEqual(a, b) {
+			t.Errorf("%q should match %q", pair[0], pair[1])
+			return
+		}
+	}
+	for _, pair := range [][2]string{
+		{"CN=Steve Kille,O=Isode Limited,C=GB", "CN=Steve Kille,O=Isode,C=GB"},
+		{"OU=Sales+CN=J. Smith,DC=net", "CN=J. Smith,DC=net"},
+		{"CN=Bob,DC=net", "DC=net,CN=Bob"},
+		{"uiiInUrn=Abc,DC=net", "uiiInUrn=abc,DC=net"},
+		{"DC=net", ""},
+	} {
+		a, b := mustParseDN(t, pair[0]), mustParseDN(t, pair[1])
+		if DNEqual(a, b) {
+			t.Errorf("%q should not match %q", pair[0], pair[1])
+			return
+		}
+	}
+}
+
+func TestRDNEqualDecodedValues(t *testing.T) {
+	parsed := mustParseDN(t, "CN=Bob")
+	encoded, err := asn1.Marshal(parsed)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var decoded DistinguishedName
+	if _, err = asn1.Unmarshal(encoded, &decoded); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := decoded[0][0].Value.(string); !ok {
+		t.Errorf("expected the value to be decoded as a string: %T", decoded[0][0].Value)
+		return
+	}
+	if !RDNEqual(decoded[0], mustParseDN(t, "cn=BOB")[0]) {
+		t.Error("decoded RDN should match the parsed RDN")
+		return
+	}
+}
+
+func TestDNIsAncestor(t *testing.T) {
+	base := mustParseDN(t, "DC=Example,DC=Net")
+	entry := mustParseDN(t, "CN=Bob,OU=People,DC=example,DC=net")
+	if !DNIsAncestor(base, entry) {
+		t.Error("base should be an ancestor of entry")
+		return
+	}
+	if !DNIsAncestor(DistinguishedName{}, entry) {
+		t.Error("the root should be an ancestor of entry")
+		return
+	}
+	if DNIsAncestor(entry, base) || DNIsAncestor(entry, entry) {
+		t.Error("an entry should not be an ancestor of its superior or itself")
+		return
+	}
+	if DNIsAncestor(mustParseDN(t, "DC=other,DC=net"), entry) {
+		t.Error("a sibling of the base should not be an ancestor of entry")
+		return
+	}
+}
+
+func TestCanonicalDN(t *testing.T) {
+	keys := map[string]string{}
+	for _, s := range []string{
+		"CN=J.  Smith+OU=Sales,DC=example,DC=net",
+		"ou=sales+cn=j. smith,dc=EXAMPLE,dc=net",
+		"2.5.4.11=#130553414c4553+CN=J. Smith,DC=example,DC=net",
+	} {
+		key, err := CanonicalDN(mustParseDN(t, s))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		keys[key] = s
+	}
+	if len(keys) != 1 {
+		t.Errorf("expected one canonical form, got %v", keys)
+		return
+	}
+	other, err := CanonicalDN(mustParseDN(t, "CN=J. Smith,OU=Sales,DC=example,DC=net"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, ok := keys[other]; ok {
+		t.Error("a different DN had the same canonical form")
+		return
+	}
+}
+
+func TestDNMatcherSchema(t *testing.T) {
+	schema := NewSchemaRegistry()
+	matcher := &DNMatcher{Schema: schema}
+	a := mustParseDN(t, "CN=Bob,DC=net")
+	b := mustParseDN(t, "CN=BOB,DC=net")
+	if matcher.DNEqual(a, b) {
+		t.Error("values should be compared exactly when the schema has no matching rule")
+		return
+	}
+	err := schema.AddAttributeType(AttributeTypeDescription{
+		Identifier: Id_at_commonName,
+		Information: AttributeTypeInformation{
+			EqualityMatch: Id_mr_caseIgnoreMatch,
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !matcher.DNEqual(a, b) {
+		t.Error("values should be compared with caseIgnoreMatch")
+		return
+	}
+}
@@ -0,0 +1,399 @@
+package x500
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// The extensibleObject object class of IETF RFC 4512, Section 4.3, which
+// permits any user attribute type.
+var extensibleObject = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 1466, 101, 120, 111}
+
+// The kind of an [EntrySchemaViolation].
+type EntrySchemaViolationKind int
+
+const (
+	// The entry has no objectClass attribute.
+	EntrySchemaMissingObjectClass EntrySchemaViolationKind = iota + 1
+
+	// An object class of the entry is not in the schema.
+	EntrySchemaUnrecognizedObjectClass
+
+	// The entry has only abstract object classes.
+	EntrySchemaAbstractOnly
+
+	// The entry has auxiliary object classes but no structural object class.
+	EntrySchemaNoStructuralObjectClass
+
+	// The structural object classes of the entry are not all superclasses of
+	// one of them.
+	EntrySchemaConflictingStructuralObjectClasses
+
+	// An auxiliary object class of the entry is not permitted by the DIT
+	// content rule of its structural object class.
+	EntrySchemaAuxiliaryNotPermitted
+
+	// A mandatory attribute type of the object classes or DIT content rule of
+	// the entry is absent.
+	EntrySchemaMissingMandatoryAttribute
+
+	// An attribute type of the entry is neither mandatory nor optional for
+	// its object classes and DIT content rule.
+	EntrySchemaAttributeNotPermitted
+
+	// An attribute type of the entry is precluded by the DIT content rule of
+	// its structural object class.
+	EntrySchemaAttributePrecluded
+
+	// A value of the RDN of the entry is not one of its attribute values.
+	EntrySchemaDistinguishedValueMissing
+
+	// The RDN of the entry does not conform to any name form for its
+	// structural object class.
+	EntrySchemaNameFormViolation
+
+	// No DIT structure rule permits an entry of this kind beneath its
+	// superior.
+	EntrySchemaStructureRuleViolation
+)
+
+var entrySchemaViolationMessages = map[EntrySchemaViolationKind]string{
+	EntrySchemaMissingObjectClass:                 "no object classes",
+	EntrySchemaUnrecognizedObjectClass:            "unrecognized object class",
+	EntrySchemaAbstractOnly:                       "only abstract object classes",
+	EntrySchemaNoStructuralObjectClass:            "no structural object class",
+	EntrySchemaConflictingStructuralObjectClasses: "structural object classes are not in the same chain",
+	EntrySchemaAuxiliaryNotPermitted:              "auxiliary object class not permitted by DIT content rule",
+	EntrySchemaMissingMandatoryAttribute:          "mandatory attribute type missing",
+	EntrySchemaAttributeNotPermitted:              "attribute type not permitted",
+	EntrySchemaAttributePrecluded:                 "attribute type precluded by DIT content rule",
+	EntrySchemaDistinguishedValueMissing:          "distinguished value not present in entry",
+	EntrySchemaNameFormViolation:                  "RDN does not conform to a name form",
+	EntrySchemaStructureRuleViolation:             "superior not permitted by DIT structure rules",
+}
+
+// A reason that a DSA would reject an entry as not conforming to the schema.
+// The problem is the update problem of ITU-T Recommendation X.511 that a DSA
+// would report in an updateError, such as
+// [UpdateProblem_ObjectClassViolation].
+type EntrySchemaViolation struct {
+	Kind    EntrySchemaViolationKind
+	Problem UpdateProblem
+
+	// The object class that the problem concerns, if any.
+	ObjectClass asn1.ObjectIdentifier
+
+	// The attribute type that the problem concerns, if any.
+	AttributeType AttributeType
+}
+
+func (v *EntrySchemaViolation) Error() string {
+	message := entrySchemaViolationMessages[v.Kind]
+	if len(v.ObjectClass) > 0 {
+		message += fmt.Sprintf(" (%s)", v.ObjectClass)
+	}
+	if len(v.AttributeType) > 0 {
+		message += fmt.Sprintf(" (%s)", v.AttributeType)
+	}
+	if v.Problem == UpdateProblem_NamingViolation {
+		return "naming violation: " + message
+	}
+	return "object class violation: " + message
+}
+
+// The outcome of validating an entry against the schema.
+type EntrySchemaCheck struct {
+	// The structural object class of the entry, if it could be determined.
+	StructuralObjectClass *ObjectClassDescription
+
+	// The DIT structure rule that would govern the entry, if the schema has
+	// DIT structure rules and one permits the entry.
+	GoverningStructureRule *DITStructureRuleDescription
+
+	// Everything about the entry that a DSA would reject, in the order in
+	// which it was found.
+	Violations []EntrySchemaViolation
+}
+
+// Validates proposed entries against the object classes, DIT content rules,
+// name forms and DIT structure rules of a schema, as described in ITU-T
+// Recommendation X.501, Section 13, so that entries that a DSA would reject
+// can be caught before they are added.
+type EntryValidator struct {
+	// The schema against which entries are validated. If nil,
+	// [DefaultSchemaRegistry] is used.
+	Schema *SchemaRegistry
+
+	// Used to determine whether the values of the RDN of an entry are among
+	// its attribute values. If nil, [DefaultMatchingRuleRegistry] is used.
+	MatchingRules *MatchingRuleRegistry
+
+	// If true, an entry whose structural object class has no DIT content rule
+	// may not have auxiliary object classes, as ITU-T Recommendation X.501
+	// requires. Otherwise, as is common for LDAP servers, it may have any.
+	StrictContentRules bool
+}
+
+func (v *EntryValidator) schema() *SchemaRegistry {
+	if v == nil || v.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return v.Schema
+}
+
+func (v *EntryValidator) matchingRules() *MatchingRuleRegistry {
+	if v == nil || v.MatchingRules == nil {
+		return DefaultMatchingRuleRegistry()
+	}
+	return v.MatchingRules
+}
+
+// Returns true if the attribute type `attrType` is any of `types` or a
+// subtype of one of them.
+func (v *EntryValidator) isAnyOf(attrType AttributeType, types []AttributeType) bool {
+	return slices.ContainsFunc(types, func(t AttributeType) bool {
+		return attrType.Equal(t) || v.schema().IsSubtypeOf(attrType.String(), t.String())
+	})
+}
+
+// Returns true if `rdn` has all of the mandatory and only the mandatory and
+// optional naming attribute types of `form`.
+func (v *EntryValidator) conformsToNameForm(rdn RelativeDistinguishedName, form *NameFormDescription) bool {
+	permitted := append(slices.Clone(form.Information.NamingMandatories), form.Information.NamingOptionals...)
+	for _, atav := range rdn {
+		if !slices.ContainsFunc(permitted, atav.Type.Equal) {
+			return false
+		}
+	}
+	for _, mandatory := range form.Information.NamingMandatories {
+		if !slices.ContainsFunc(rdn, func(atav pkix.AttributeTypeAndValue) bool { return atav.Type.Equal(mandatory) }) {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *EntryValidator) checkName(check *EntrySchemaCheck, name DistinguishedName, attrs []Attribute, superiorRule *RuleIdentifier) error {
+	if len(name) == 0 {
+		return nil
+	}
+	rdn := RelativeDistinguishedName(name[len(name)-1])
+	equal := v.matchingRules().ValueEqual(v.schema())
+	for _, atav := range rdn {
+		value, err := dnRawValue(atav.Value)
+		if err != nil {
+			return err
+		}
+		present := slices.ContainsFunc(attrs, func(attr Attribute) bool {
+			if !attr.Type.Equal(atav.Type) {
+				return false
+			}
+			for i := 0; i < attr.Len(); i++ {
+				if equal(attr.Type, *attr.Get(i), value) {
+					return true
+				}
+			}
+			return false
+		})
+		if !present {
+			check.Violations = append(check.Violations, EntrySchemaViolation{
+				Kind:          EntrySchemaDistinguishedValueMissing,
+				Problem:       UpdateProblem_NamingViolation,
+				AttributeType: atav.Type,
+			})
+		}
+	}
+	if check.StructuralObjectClass == nil {
+		return nil
+	}
+
+	structural := check.StructuralObjectClass.Identifier
+	var forms []*NameFormDescription
+	conforming := false
+	for _, form := range v.schema().NameForms() {
+		if form.Information.Subordinate.Equal(structural) {
+			forms = append(forms, form)
+			conforming = conforming || v.conformsToNameForm(rdn, form)
+		}
+	}
+	rules := v.schema().DITStructureRules()
+	if len(rules) == 0 {
+		if len(forms) > 0 && !conforming {
+			check.Violations = append(check.Violations, EntrySchemaViolation{
+				Kind:        EntrySchemaNameFormViolation,
+				Problem:     UpdateProblem_NamingViolation,
+				ObjectClass: structural,
+			})
+		}
+		return nil
+	}
+
+	// Structure rules are in effect, so the entry must be governed by one of
+	// them: one whose name form is for its structural object class and is
+	// satisfied by its RDN, and which permits its superior.
+	slices.SortFunc(rules, func(a, b *DITStructureRuleDescription) int { return a.RuleIdentifier - b.RuleIdentifier })
+	conforming = false
+	for _, rule := range rules {
+		i := slices.IndexFunc(forms, func(form *NameFormDescription) bool { return form.Identifier.Equal(rule.NameForm) })
+		if i < 0 || !v.conformsToNameForm(rdn, forms[i]) {
+			continue
+		}
+		conforming = true
+		if superiorRule == nil && len(rule.SuperiorStructureRules) == 0 ||
+			superiorRule != nil && slices.Contains(rule.SuperiorStructureRules, *superiorRule) {
+			check.GoverningStructureRule = rule
+			return nil
+		}
+	}
+	kind := EntrySchemaStructureRuleViolation
+	if !conforming {
+		kind = EntrySchemaNameFormViolation
+	}
+	check.Violations = append(check.Violations, EntrySchemaViolation{
+		Kind:        kind,
+		Problem:     UpdateProblem_NamingViolation,
+		ObjectClass: structural,
+	})
+	return nil
+}
+
+// Validates the entry named `name` with the attributes `attrs`, returning
+// everything about it that a DSA would reject.
+//
+// The object classes of the entry must be in the schema and must include a
+// structural object class of which any other structural object classes are
+// superclasses. The entry must have the mandatory attribute types of its
+// object classes and of the DIT content rule for its structural object
+// class, and only those and their optional attribute types, unless it is of
+// the extensibleObject object class. Operational attribute types are always
+// permitted. Subtypes of attribute types are permitted wherever those types
+// are. Auxiliary object classes must be permitted by the DIT content rule,
+// if there is one.
+//
+// The values of the RDN of the entry must be among its attribute values. If
+// the schema has name forms for its structural object class, its RDN must
+// conform to one of them. If the schema has DIT structure rules, one of them
+// must govern the entry: its name form must be for the structural object
+// class of the entry and be conformed to by its RDN, and its superior rules
+// must include `superiorRule`, the rule that governs the superior of the
+// entry, or be empty if `superiorRule` is nil, such as when the entry is to
+// be an autonomous administrative point.
+//
+// Values of the objectClass attribute that cannot be decoded are ignored. An
+// error is only returned if the RDN of the entry cannot be decoded.
+func (v *EntryValidator) Validate(name DistinguishedName, attrs []Attribute, superiorRule *RuleIdentifier) (*EntrySchemaCheck, error) {
+	schema := v.schema()
+	check := &EntrySchemaCheck{}
+	violation := func(kind EntrySchemaViolationKind, oc asn1.ObjectIdentifier, attrType AttributeType) {
+		check.Violations = append(check.Violations, EntrySchemaViolation{
+			Kind:          kind,
+			Problem:       UpdateProblem_ObjectClassViolation,
+			ObjectClass:   oc,
+			AttributeType: attrType,
+		})
+	}
+
+	classOIDs := objectClassesOf(attrs)
+	found := len(classOIDs) > 0
+	if !found {
+		violation(EntrySchemaMissingObjectClass, nil, nil)
+	}
+	var classes []*ObjectClassDescription
+	extensible := false
+	for _, oid := range classOIDs {
+		if oid.Equal(extensibleObject) {
+			extensible = true
+			continue
+		}
+		oc := schema.ObjectClass(oid.String())
+		if oc == nil && oid.Equal(Id_oc_top) {
+			// Every entry is of the top object class, so a schema need not
+			// define it.
+			continue
+		}
+		if oc == nil {
+			violation(EntrySchemaUnrecognizedObjectClass, oid, nil)
+			continue
+		}
+		classes = append(classes, oc)
+	}
+	if found {
+		var err error
+		check.StructuralObjectClass, err = schema.StructuralObjectClass(classOIDs)
+		switch {
+		case errors.Is(err, errNoStructuralObjectClass):
+			kind := EntrySchemaAbstractOnly
+			if slices.ContainsFunc(classes, func(oc *ObjectClassDescription) bool {
+				return oc.Information.Kind == ObjectClassKind_Auxiliary
+			}) {
+				kind = EntrySchemaNoStructuralObjectClass
+			}
+			violation(kind, nil, nil)
+		case err != nil:
+			violation(EntrySchemaConflictingStructuralObjectClasses, nil, nil)
+		}
+	}
+
+	var mandatory, optional, precluded []AttributeType
+	for _, oc := range classes {
+		m, o, err := schema.ObjectClassAttributes(oc.Identifier.String())
+		if err != nil {
+			// A superclass is not in the schema.
+			violation(EntrySchemaUnrecognizedObjectClass, oc.Identifier, nil)
+			continue
+		}
+		mandatory = append(mandatory, m...)
+		optional = append(optional, o...)
+	}
+	if check.StructuralObjectClass != nil {
+		structural := check.StructuralObjectClass.Identifier.String()
+		rule := schema.DITContentRule(structural)
+		for _, oc := range classes {
+			if oc.Information.Kind != ObjectClassKind_Auxiliary {
+				continue
+			}
+			if rule == nil && v.StrictContentRules || rule != nil && !slices.ContainsFunc(rule.Auxiliaries, oc.Identifier.Equal) {
+				violation(EntrySchemaAuxiliaryNotPermitted, oc.Identifier, nil)
+			}
+		}
+		if rule != nil {
+			mandatory = append(mandatory, rule.Mandatory...)
+			optional = append(optional, rule.Optional...)
+			precluded = rule.Precluded
+		}
+	}
+
+	for _, attrType := range mandatory {
+		if !slices.ContainsFunc(attrs, func(attr Attribute) bool {
+			return !attr.IsEmpty() && v.isAnyOf(attr.Type, []AttributeType{attrType})
+		}) && !slices.ContainsFunc(check.Violations, func(existing EntrySchemaViolation) bool {
+			return existing.Kind == EntrySchemaMissingMandatoryAttribute && existing.AttributeType.Equal(attrType)
+		}) {
+			violation(EntrySchemaMissingMandatoryAttribute, nil, attrType)
+		}
+	}
+	for _, attr := range attrs {
+		if attr.Type.Equal(Id_at_objectClass) {
+			continue
+		}
+		if resolved, err := schema.ResolveAttributeType(attr.Type.String()); err == nil && resolved.Usage != AttributeUsage_UserApplications {
+			continue
+		}
+		switch {
+		case v.isAnyOf(attr.Type, mandatory):
+		case v.isAnyOf(attr.Type, precluded):
+			violation(EntrySchemaAttributePrecluded, nil, attr.Type)
+		case extensible || v.isAnyOf(attr.Type, optional):
+		default:
+			violation(EntrySchemaAttributeNotPermitted, nil, attr.Type)
+		}
+	}
+
+	if err := v.checkName(check, name, attrs, superiorRule); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
@@ -0,0 +1,147 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+var testAuxiliaryA = asn1.ObjectIdentifier{1, 2, 3, 100}
+var testAuxiliaryB = asn1.ObjectIdentifier{1, 2, 3, 101}
+var testPersonNameForm = asn1.ObjectIdentifier{1, 2, 3, 200}
+
+func testEntrySchema(t *testing.T) *SchemaRegistry {
+	r, err := NewSchemaRegistryFromAttributes(testSubschemaAttributes(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, aux := range []asn1.ObjectIdentifier{testAuxiliaryA, testAuxiliaryB} {
+		err := r.AddObjectClass(ObjectClassDescription{
+			Identifier:  aux,
+			Information: ObjectClassInformation{Kind: ObjectClassKind_Auxiliary, Optionals: []asn1.ObjectIdentifier{Id_at_title}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	steps := []error{
+		r.AddNameForm(NameFormDescription{
+			Identifier: testPersonNameForm,
+			Information: NameFormInformation{
+				Subordinate:       Id_oc_person,
+				NamingMandatories: []asn1.ObjectIdentifier{Id_at_commonName},
+			},
+		}),
+		r.AddDITStructureRule(DITStructureRuleDescription{RuleIdentifier: 2, NameForm: testPersonNameForm, SuperiorStructureRules: []RuleIdentifier{1}}),
+		r.AddDITContentRule(DITContentRuleDescription{
+			StructuralObjectClass: Id_oc_person,
+			Auxiliaries:           []asn1.ObjectIdentifier{testAuxiliaryA},
+			Precluded:             []asn1.ObjectIdentifier{Id_at_description},
+		}),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func testEntry(t *testing.T, objectClasses []asn1.ObjectIdentifier, values map[string]string) []Attribute {
+	attrs := []Attribute{{Type: Id_at_objectClass}}
+	for _, oc := range objectClasses {
+		attrs[0].Values = append(attrs[0].Values, mustMarshalValue(t, oc, ""))
+	}
+	for _, attrType := range []AttributeType{Id_at_commonName, Id_at_surname, Id_at_title, Id_at_description} {
+		if value, ok := values[attrType.String()]; ok {
+			attrs = append(attrs, Attribute{Type: attrType, Values: []asn1.RawValue{mustMarshalValue(t, value, "utf8")}})
+		}
+	}
+	return attrs
+}
+
+func TestEntryValidatorValid(t *testing.T) {
+	validator := &EntryValidator{Schema: testEntrySchema(t)}
+	attrs := testEntry(t, []asn1.ObjectIdentifier{Id_oc_top, Id_oc_person, testAuxiliaryA}, map[string]string{
+		Id_at_commonName.String(): "John Smith",
+		Id_at_surname.String():    "Smith",
+		Id_at_title.String():      "Boss",
+	})
+	superior := RuleIdentifier(1)
+	check, err := validator.Validate(mustParseDN(t, "cn=JOHN SMITH,o=Example"), attrs, &superior)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(check.Violations) != 0 {
+		t.Errorf("expected no violations, but got %v", check.Violations)
+		return
+	}
+	if !check.StructuralObjectClass.Identifier.Equal(Id_oc_person) || check.GoverningStructureRule == nil || check.GoverningStructureRule.RuleIdentifier != 2 {
+		t.Errorf("unexpected structural object class or governing structure rule: %+v", check)
+	}
+}
+
+func TestEntryValidatorViolations(t *testing.T) {
+	person := []asn1.ObjectIdentifier{Id_oc_top, Id_oc_person}
+	john := map[string]string{Id_at_commonName.String(): "John Smith", Id_at_surname.String(): "Smith"}
+	rule1 := RuleIdentifier(1)
+	rule3 := RuleIdentifier(3)
+	cases := []struct {
+		name     string
+		classes  []asn1.ObjectIdentifier
+		values   map[string]string
+		superior *RuleIdentifier
+		expected []EntrySchemaViolationKind
+	}{
+		{"cn=John Smith", nil, john, &rule1, []EntrySchemaViolationKind{EntrySchemaMissingObjectClass, EntrySchemaAttributeNotPermitted, EntrySchemaAttributeNotPermitted}},
+		{"cn=John Smith", []asn1.ObjectIdentifier{Id_oc_top}, john, &rule1, []EntrySchemaViolationKind{EntrySchemaAbstractOnly, EntrySchemaAttributeNotPermitted, EntrySchemaAttributeNotPermitted}},
+		{"cn=John Smith", []asn1.ObjectIdentifier{testAuxiliaryA}, john, &rule1, []EntrySchemaViolationKind{EntrySchemaNoStructuralObjectClass, EntrySchemaAttributeNotPermitted, EntrySchemaAttributeNotPermitted}},
+		{"cn=John Smith", []asn1.ObjectIdentifier{Id_oc_person, {1, 9}}, john, &rule1, []EntrySchemaViolationKind{EntrySchemaUnrecognizedObjectClass}},
+		{"cn=John Smith", append(person, testAuxiliaryB), john, &rule1, []EntrySchemaViolationKind{EntrySchemaAuxiliaryNotPermitted}},
+		{
+			"cn=Jack", person,
+			map[string]string{Id_at_commonName.String(): "John Smith", Id_at_title.String(): "Boss", Id_at_description.String(): "A person"},
+			&rule1,
+			[]EntrySchemaViolationKind{EntrySchemaMissingMandatoryAttribute, EntrySchemaAttributeNotPermitted, EntrySchemaAttributePrecluded, EntrySchemaDistinguishedValueMissing},
+		},
+		{"sn=Smith", person, john, &rule1, []EntrySchemaViolationKind{EntrySchemaNameFormViolation}},
+		{"cn=John Smith", person, john, &rule3, []EntrySchemaViolationKind{EntrySchemaStructureRuleViolation}},
+		{"cn=John Smith", person, john, nil, []EntrySchemaViolationKind{EntrySchemaStructureRuleViolation}},
+	}
+	validator := &EntryValidator{Schema: testEntrySchema(t)}
+	for _, c := range cases {
+		check, err := validator.Validate(mustParseDN(t, c.name), testEntry(t, c.classes, c.values), c.superior)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		kinds := make([]EntrySchemaViolationKind, 0, len(check.Violations))
+		for _, v := range check.Violations {
+			kinds = append(kinds, v.Kind)
+		}
+		if len(kinds) != len(c.expected) {
+			t.Errorf("%s %v: expected violations %v, but got %v", c.name, c.classes, c.expected, check.Violations)
+			continue
+		}
+		for i := range kinds {
+			if kinds[i] != c.expected[i] {
+				t.Errorf("%s %v: expected violations %v, but got %v", c.name, c.classes, c.expected, check.Violations)
+				break
+			}
+		}
+	}
+
+	strict := &EntryValidator{Schema: testEntrySchema(t), StrictContentRules: true}
+	attrs := testEntry(t, []asn1.ObjectIdentifier{Id_oc_organizationalPerson, testAuxiliaryA}, john)
+	check, err := strict.Validate(nil, attrs, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(check.Violations) != 1 || check.Violations[0].Kind != EntrySchemaAuxiliaryNotPermitted {
+		t.Errorf("expected the auxiliary object class to be rejected without a content rule, but got %v", check.Violations)
+	}
+	if s := check.Violations[0].Error(); s != "object class violation: auxiliary object class not permitted by DIT content rule (1.2.3.100)" {
+		t.Errorf("unexpected error message %q", s)
+	}
+}
@@ -0,0 +1,562 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// The alternative of a [Filter] or [FilterItem] that a [FilterNode] represents.
+type FilterKind int
+
+const (
+	FilterKindAnd FilterKind = iota
+	FilterKindOr
+	FilterKindNot
+	FilterKindEquality
+	FilterKindSubstrings
+	FilterKindGreaterOrEqual
+	FilterKindLessOrEqual
+	FilterKindPresent
+	FilterKindApproximateMatch
+	FilterKindExtensibleMatch
+	FilterKindContextPresent
+)
+
+// The context-specific tags of the alternatives of FilterItem.
+var filterItemTags = map[FilterKind]int{
+	FilterKindEquality:         0,
+	FilterKindSubstrings:       1,
+	FilterKindGreaterOrEqual:   2,
+	FilterKindLessOrEqual:      3,
+	FilterKindPresent:          4,
+	FilterKindApproximateMatch: 5,
+	FilterKindExtensibleMatch:  6,
+	FilterKindContextPresent:   7,
+}
+
+// The alternative of an element of the `strings` of a substrings filter item.
+type SubstringKind int
+
+const (
+	SubstringInitial SubstringKind = iota
+	SubstringAny
+	SubstringFinal
+
+	// An Attribute that specifies the interpretation of the substrings that
+	// follow it.
+	SubstringControl
+)
+
+// An element of the `strings` of a substrings filter item.
+type FilterSubstring struct {
+	Kind SubstringKind
+
+	// A value of the attribute type, or an encoded Attribute if `Kind` is
+	// [SubstringControl].
+	Value asn1.RawValue
+}
+
+// A search filter in a form that is easier to construct and inspect than the
+// nested CHOICEs of [Filter]. Use [FilterNode.Marshal] to produce a Filter for
+// SearchArgumentData, and [DecodeFilter] to produce a FilterNode from one.
+type FilterNode struct {
+	Kind FilterKind
+
+	// The operands of `and` and `or`, or the single operand of `not`.
+	Filters []*FilterNode
+
+	// The attribute type of a filter item, which is optional for
+	// `extensibleMatch`.
+	Type AttributeType
+
+	// The assertion of `equality`, `greaterOrEqual`, `lessOrEqual`,
+	// `approximateMatch` and `extensibleMatch`.
+	Value asn1.RawValue
+
+	// The strings of `substrings`.
+	Substrings []FilterSubstring
+
+	// The matching rules of `extensibleMatch`.
+	MatchingRules []asn1.ObjectIdentifier
+
+	// The `dnAttributes` of `extensibleMatch`.
+	DNAttributes bool
+
+	// Whether an attribute value assertion asserts `allContexts`.
+	AllContexts bool
+
+	// The `selectedContexts` of an attribute value assertion, or the asserted
+	// contexts of `contextPresent`.
+	Contexts []ContextAssertion
+}
+
+// Returns a constructed context-specific value with the concatenation of
+// `contents` as its content octets.
+func contextTagged(tag int, contents ...[]byte) (asn1.RawValue, error) {
+	v := asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        tag,
+		IsCompound: true,
+		Bytes:      bytes.Join(contents, nil),
+	}
+	encoded, err := asn1.Marshal(v)
+	if err != nil {
+		return v, err
+	}
+	v.FullBytes = encoded
+	return v, nil
+}
+
+func (f *FilterNode) assertedContexts() (AttributeValueAssertion_assertedContexts, error) {
+	if f.AllContexts {
+		null, _ := asn1.Marshal(asn1.NullRawValue)
+		return contextTagged(0, null)
+	}
+	if len(f.Contexts) == 0 {
+		return asn1.RawValue{}, nil
+	}
+	selected, err := asn1.MarshalWithParams(f.Contexts, "set")
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	return contextTagged(1, selected)
+}
+
+func (f *FilterNode) marshalItem() ([]byte, error) {
+	switch f.Kind {
+	case FilterKindEquality, FilterKindGreaterOrEqual, FilterKindLessOrEqual, FilterKindApproximateMatch:
+		contexts, err := f.assertedContexts()
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(AttributeValueAssertion{
+			Type:             f.Type,
+			Assertion:        f.Value,
+			AssertedContexts: contexts,
+		})
+	case FilterKindSubstrings:
+		substrings := FilterItem_substrings{
+			Type:    f.Type,
+			Strings: make([]FilterItem_substrings_strings_Item, 0, len(f.Substrings)),
+		}
+		for _, s := range f.Substrings {
+			if s.Kind == SubstringControl {
+				substrings.Strings = append(substrings.Strings, s.Value)
+				continue
+			}
+			tagged, err := contextTagged(int(s.Kind), encodingOf(s.Value))
+			if err != nil {
+				return nil, err
+			}
+			substrings.Strings = append(substrings.Strings, tagged)
+		}
+		return asn1.Marshal(substrings)
+	case FilterKindPresent:
+		return asn1.Marshal(f.Type)
+	case FilterKindExtensibleMatch:
+		matchValue, err := contextTagged(3, encodingOf(f.Value))
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(MatchingRuleAssertion{
+			MatchingRule: f.MatchingRules,
+			Type:         f.Type,
+			MatchValue:   matchValue,
+			DnAttributes: f.DNAttributes,
+		})
+	case FilterKindContextPresent:
+		return asn1.Marshal(AttributeTypeAssertion{
+			Type:             f.Type,
+			AssertedContexts: f.Contexts,
+		})
+	}
+	return nil, fmt.Errorf("unrecognized filter kind %d", f.Kind)
+}
+
+// Encode the filter as a [Filter], as used in SearchArgumentData. The
+// operands of `and` and `or` are sorted by their encodings, as DER requires
+// for a SET OF.
+func (f *FilterNode) Marshal() (Filter, error) {
+	switch f.Kind {
+	case FilterKindAnd, FilterKindOr:
+		operands := make([][]byte, 0, len(f.Filters))
+		for _, operand := range f.Filters {
+			encoded, err := operand.Marshal()
+			if err != nil {
+				return Filter{}, err
+			}
+			operands = append(operands, encoded.FullBytes)
+		}
+		// The module is explicitly tagged, so the SET OF keeps its own tag.
+		set, err := setOf(operands...)
+		if err != nil {
+			return Filter{}, err
+		}
+		if f.Kind == FilterKindAnd {
+			return contextTagged(1, set.FullBytes)
+		}
+		return contextTagged(2, set.FullBytes)
+	case FilterKindNot:
+		if len(f.Filters) != 1 {
+			return Filter{}, errors.New("not filter must have exactly one operand")
+		}
+		operand, err := f.Filters[0].Marshal()
+		if err != nil {
+			return Filter{}, err
+		}
+		return contextTagged(3, operand.FullBytes)
+	}
+	tag, ok := filterItemTags[f.Kind]
+	if !ok {
+		return Filter{}, fmt.Errorf("unrecognized filter kind %d", f.Kind)
+	}
+	encoded, err := f.marshalItem()
+	if err != nil {
+		return Filter{}, err
+	}
+	item, err := contextTagged(tag, encoded)
+	if err != nil {
+		return Filter{}, err
+	}
+	return contextTagged(0, item.FullBytes)
+}
+
+func decodeAssertedContexts(f *FilterNode, contexts AttributeValueAssertion_assertedContexts) error {
+	if len(contexts.FullBytes) == 0 {
+		return nil
+	}
+	if contexts.Class != asn1.ClassContextSpecific {
+		return errors.New("invalid asserted contexts")
+	}
+	switch contexts.Tag {
+	case 0:
+		f.AllContexts = true
+	case 1:
+		rest, err := asn1.UnmarshalWithParams(contexts.Bytes, &f.Contexts, "set")
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 {
+			return errors.New("trailing bytes after selected contexts")
+		}
+	default:
+		return fmt.Errorf("unrecognized asserted contexts alternative %d", contexts.Tag)
+	}
+	return nil
+}
+
+func decodeFilterItem(item asn1.RawValue) (*FilterNode, error) {
+	if item.Class != asn1.ClassContextSpecific || !item.IsCompound {
+		return nil, errors.New("invalid filter item")
+	}
+	f := &FilterNode{}
+	found := false
+	for kind, tag := range filterItemTags {
+		if tag == item.Tag {
+			f.Kind = kind
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("unrecognized filter item alternative %d", item.Tag)
+	}
+	unmarshal := func(out any) error {
+		rest, err := asn1.Unmarshal(item.Bytes, out)
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 {
+			return errors.New("trailing bytes after filter item")
+		}
+		return nil
+	}
+	switch f.Kind {
+	case FilterKindEquality, FilterKindGreaterOrEqual, FilterKindLessOrEqual, FilterKindApproximateMatch:
+		var ava AttributeValueAssertion
+		if err := unmarshal(&ava); err != nil {
+			return nil, err
+		}
+		f.Type = ava.Type
+		f.Value = ava.Assertion
+		if err := decodeAssertedContexts(f, ava.AssertedContexts); err != nil {
+			return nil, err
+		}
+	case FilterKindSubstrings:
+		var substrings FilterItem_substrings
+		if err := unmarshal(&substrings); err != nil {
+			return nil, err
+		}
+		f.Type = substrings.Type
+		f.Substrings = make([]FilterSubstring, 0, len(substrings.Strings))
+		for _, s := range substrings.Strings {
+			if s.Class == asn1.ClassUniversal {
+				f.Substrings = append(f.Substrings, FilterSubstring{Kind: SubstringControl, Value: s})
+				continue
+			}
+			if s.Class != asn1.ClassContextSpecific || s.Tag > int(SubstringFinal) {
+				return nil, fmt.Errorf("unrecognized substring alternative %d", s.Tag)
+			}
+			value, err := explicitlyTaggedValue(s)
+			if err != nil {
+				return nil, err
+			}
+			f.Substrings = append(f.Substrings, FilterSubstring{Kind: SubstringKind(s.Tag), Value: value})
+		}
+	case FilterKindPresent:
+		if err := unmarshal(&f.Type); err != nil {
+			return nil, err
+		}
+	case FilterKindExtensibleMatch:
+		var mra MatchingRuleAssertion
+		if err := unmarshal(&mra); err != nil {
+			return nil, err
+		}
+		value, err := explicitlyTaggedValue(mra.MatchValue)
+		if err != nil {
+			return nil, err
+		}
+		f.MatchingRules = mra.MatchingRule
+		f.Type = mra.Type
+		f.Value = value
+		f.DNAttributes = mra.DnAttributes
+	case FilterKindContextPresent:
+		var ata AttributeTypeAssertion
+		if err := unmarshal(&ata); err != nil {
+			return nil, err
+		}
+		f.Type = ata.Type
+		f.Contexts = ata.AssertedContexts
+	}
+	return f, nil
+}
+
+// Decode a [Filter], such as one taken from SearchArgumentData, into a
+// [FilterNode]. The filter must not be wrapped in the explicit tag of the
+// field it was taken from.
+func DecodeFilter(filter Filter) (*FilterNode, error) {
+	var outer asn1.RawValue
+	if err := unmarshalExactly(filter, &outer); err != nil {
+		return nil, err
+	}
+	if outer.Class != asn1.ClassContextSpecific || !outer.IsCompound {
+		return nil, errors.New("invalid filter")
+	}
+	operands := make([]*FilterNode, 0)
+	rest := outer.Bytes
+	if outer.Tag == 1 || outer.Tag == 2 {
+		var set asn1.RawValue
+		if err := unmarshalExactly(asn1.RawValue{FullBytes: outer.Bytes}, &set); err != nil {
+			return nil, err
+		}
+		if set.Class != asn1.ClassUniversal || set.Tag != asn1.TagSet {
+			return nil, errors.New("invalid filter operands")
+		}
+		rest = set.Bytes
+	}
+	for len(rest) > 0 {
+		var inner asn1.RawValue
+		var err error
+		rest, err = asn1.Unmarshal(rest, &inner)
+		if err != nil {
+			return nil, err
+		}
+		if outer.Tag == 0 {
+			if len(rest) > 0 {
+				return nil, errors.New("trailing bytes after filter item")
+			}
+			return decodeFilterItem(inner)
+		}
+		operand, err := DecodeFilter(inner)
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+	}
+	switch outer.Tag {
+	case 1:
+		return &FilterNode{Kind: FilterKindAnd, Filters: operands}, nil
+	case 2:
+		return &FilterNode{Kind: FilterKindOr, Filters: operands}, nil
+	case 3:
+		if len(operands) != 1 {
+			return nil, errors.New("not filter must have exactly one operand")
+		}
+		return &FilterNode{Kind: FilterKindNot, Filters: operands}, nil
+	}
+	return nil, fmt.Errorf("unrecognized filter alternative %d", outer.Tag)
+}
+
+// Constructs [FilterNode]s from attribute types and values given as strings,
+// as they would appear in an IETF RFC 4515 filter. Values are encoded using
+// the syntaxes of their attribute types in `Schema`, or [DefaultSchemaRegistry]
+// if it is nil. The first error encountered is retained and returned by
+// [FilterBuilder.Err] and [FilterBuilder.Build], so that calls may be nested:
+//
+//	b := &x500.FilterBuilder{}
+//	filter, err := b.Build(b.And(
+//		b.Substrings("cn", "Jo", nil, ""),
+//		b.Equal("objectClass", "person"),
+//	))
+type FilterBuilder struct {
+	Schema *SchemaRegistry
+	err    error
+}
+
+func (b *FilterBuilder) schema() *SchemaRegistry {
+	if b.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return b.Schema
+}
+
+func (b *FilterBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Returns the first error encountered by the builder.
+func (b *FilterBuilder) Err() error {
+	return b.err
+}
+
+// Returns the first error encountered by the builder, if any, or else the
+// encoding of `f`.
+func (b *FilterBuilder) Build(f *FilterNode) (Filter, error) {
+	if b.err != nil {
+		return Filter{}, b.err
+	}
+	return f.Marshal()
+}
+
+func (b *FilterBuilder) attributeType(attr string) AttributeType {
+	at, err := resolveAttributeType(b.schema(), attr)
+	if err != nil {
+		b.setErr(err)
+	}
+	return at
+}
+
+func (b *FilterBuilder) assertion(kind FilterKind, attr, value string) *FilterNode {
+	f := &FilterNode{Kind: kind, Type: b.attributeType(attr)}
+	if f.Type == nil {
+		return f
+	}
+	v, err := encodeAssertionValue(b.schema(), f.Type, value)
+	if err != nil {
+		b.setErr(err)
+	}
+	f.Value = v
+	return f
+}
+
+// Returns an `and` filter, which is true if all of `filters` are true.
+func (b *FilterBuilder) And(filters ...*FilterNode) *FilterNode {
+	return &FilterNode{Kind: FilterKindAnd, Filters: filters}
+}
+
+// Returns an `or` filter, which is true if any of `filters` is true.
+func (b *FilterBuilder) Or(filters ...*FilterNode) *FilterNode {
+	return &FilterNode{Kind: FilterKindOr, Filters: filters}
+}
+
+// Returns a `not` filter, which is true if `filter` is false.
+func (b *FilterBuilder) Not(filter *FilterNode) *FilterNode {
+	return &FilterNode{Kind: FilterKindNot, Filters: []*FilterNode{filter}}
+}
+
+// Returns an equality filter item, as in "(attr=value)".
+func (b *FilterBuilder) Equal(attr, value string) *FilterNode {
+	return b.assertion(FilterKindEquality, attr, value)
+}
+
+// Returns a greaterOrEqual filter item, as in "(attr>=value)".
+func (b *FilterBuilder) GreaterOrEqual(attr, value string) *FilterNode {
+	return b.assertion(FilterKindGreaterOrEqual, attr, value)
+}
+
+// Returns a lessOrEqual filter item, as in "(attr<=value)".
+func (b *FilterBuilder) LessOrEqual(attr, value string) *FilterNode {
+	return b.assertion(FilterKindLessOrEqual, attr, value)
+}
+
+// Returns an approximateMatch filter item, as in "(attr~=value)".
+func (b *FilterBuilder) ApproximateMatch(attr, value string) *FilterNode {
+	return b.assertion(FilterKindApproximateMatch, attr, value)
+}
+
+// Returns a present filter item, as in "(attr=*)".
+func (b *FilterBuilder) Present(attr string) *FilterNode {
+	return &FilterNode{Kind: FilterKindPresent, Type: b.attributeType(attr)}
+}
+
+// Returns a substrings filter item. Empty `initial` and `final` strings are
+// omitted.
+func (b *FilterBuilder) Substrings(attr, initial string, any []string, final string) *FilterNode {
+	f := &FilterNode{Kind: FilterKindSubstrings, Type: b.attributeType(attr)}
+	if f.Type == nil {
+		return f
+	}
+	add := func(kind SubstringKind, s string) {
+		v, err := encodeSubstringValue(b.schema(), f.Type, s)
+		if err != nil {
+			b.setErr(err)
+		}
+		f.Substrings = append(f.Substrings, FilterSubstring{Kind: kind, Value: v})
+	}
+	if initial != "" {
+		add(SubstringInitial, initial)
+	}
+	for _, s := range any {
+		add(SubstringAny, s)
+	}
+	if final != "" {
+		add(SubstringFinal, final)
+	}
+	return f
+}
+
+// Returns an extensibleMatch filter item. Either `rule` or `attr` may be
+// empty, but not both. If `attr` is empty, `value` is encoded as a
+// UTF8String.
+func (b *FilterBuilder) ExtensibleMatch(rule, attr, value string, dnAttributes bool) *FilterNode {
+	f := &FilterNode{Kind: FilterKindExtensibleMatch, DNAttributes: dnAttributes}
+	if rule == "" && attr == "" {
+		b.setErr(errors.New("extensible match requires a matching rule or attribute type"))
+		return f
+	}
+	if rule != "" {
+		oid, err := resolveMatchingRule(b.schema(), rule)
+		if err != nil {
+			b.setErr(err)
+		}
+		f.MatchingRules = []asn1.ObjectIdentifier{oid}
+	}
+	var err error
+	if attr != "" {
+		f.Type = b.attributeType(attr)
+		if f.Type == nil {
+			return f
+		}
+		f.Value, err = encodeAssertionValue(b.schema(), f.Type, value)
+	} else {
+		f.Value, err = encodeUTF8Value(value)
+	}
+	if err != nil {
+		b.setErr(err)
+	}
+	return f
+}
+
+// Returns a contextPresent filter item, which is true of values of `attr` that
+// have all of the given contexts.
+func (b *FilterBuilder) ContextPresent(attr string, contexts ...ContextAssertion) *FilterNode {
+	return &FilterNode{
+		Kind:     FilterKindContextPresent,
+		Type:     b.attributeType(attr),
+		Contexts: contexts,
+	}
+}
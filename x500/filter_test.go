@@ -0,0 +1,161 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"testing"
+)
+
+func TestFilterBuilder(t *testing.T) {
+	b := &FilterBuilder{}
+	filter, err := b.Build(b.And(
+		b.Substrings("cn", "Jo", []string{"n"}, "th"),
+		b.Equal("objectClass", "person"),
+		b.Not(b.Present("telephoneNumber")),
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if filter.Class != asn1.ClassContextSpecific || filter.Tag != 1 {
+		t.Errorf("expected an and filter, got tag %d", filter.Tag)
+		return
+	}
+	decoded, err := DecodeFilter(filter)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if decoded.Kind != FilterKindAnd || len(decoded.Filters) != 3 {
+		t.Errorf("decoded filter was %v", decoded)
+		return
+	}
+	var substrings, equality *FilterNode
+	for _, f := range decoded.Filters {
+		switch f.Kind {
+		case FilterKindSubstrings:
+			substrings = f
+		case FilterKindEquality:
+			equality = f
+		}
+	}
+	if substrings == nil || !substrings.Type.Equal(Id_at_commonName) || len(substrings.Substrings) != 3 {
+		t.Errorf("substrings were not decoded: %v", substrings)
+		return
+	}
+	initial := substrings.Substrings[0]
+	if initial.Kind != SubstringInitial || initial.Value.Tag != asn1.TagUTF8String || string(initial.Value.Bytes) != "Jo" {
+		t.Errorf("initial substring was %v", initial)
+		return
+	}
+	if substrings.Substrings[2].Kind != SubstringFinal {
+		t.Errorf("final substring was %v", substrings.Substrings[2])
+		return
+	}
+	var oc asn1.ObjectIdentifier
+	if equality == nil || unmarshalExactly(equality.Value, &oc) != nil || !oc.Equal(Id_oc_person) {
+		t.Errorf("objectClass assertion was not an object identifier: %v", equality)
+		return
+	}
+}
+
+func TestFilterBuilderErrors(t *testing.T) {
+	b := &FilterBuilder{}
+	_, err := b.Build(b.Or(
+		b.Equal("notAnAttribute", "x"),
+		b.Equal("cn", "x"),
+	))
+	if err == nil {
+		t.Error("building a filter with an unknown attribute type should fail")
+		return
+	}
+	b = &FilterBuilder{}
+	b.Equal("countryName", "Ü")
+	if b.Err() == nil {
+		t.Error("a country name that is not a PrintableString should fail")
+		return
+	}
+}
+
+func TestFilterRoundTrip(t *testing.T) {
+	contexts := []ContextAssertion{{
+		ContextType:   Id_avc_language,
+		ContextValues: []asn1.RawValue{{Class: asn1.ClassUniversal, Tag: asn1.TagPrintableString, Bytes: []byte("EN")}},
+	}}
+	filters := []*FilterNode{
+		{Kind: FilterKindEquality, Type: Id_at_commonName, Value: asn1.RawValue{Tag: asn1.TagUTF8String, Bytes: []byte("Bob")}, AllContexts: true},
+		{Kind: FilterKindApproximateMatch, Type: Id_at_surname, Value: asn1.RawValue{Tag: asn1.TagUTF8String, Bytes: []byte("Smyth")}, Contexts: contexts},
+		{Kind: FilterKindExtensibleMatch, MatchingRules: []asn1.ObjectIdentifier{Id_mr_caseExactMatch}, Type: Id_at_commonName, Value: asn1.RawValue{Tag: asn1.TagUTF8String, Bytes: []byte("Bob")}, DNAttributes: true},
+		{Kind: FilterKindContextPresent, Type: Id_at_commonName, Contexts: contexts},
+		{Kind: FilterKindOr},
+	}
+	for _, f := range filters {
+		encoded, err := f.Marshal()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		decoded, err := DecodeFilter(encoded)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		reencoded, err := decoded.Marshal()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !bytes.Equal(encoded.FullBytes, reencoded.FullBytes) {
+			t.Errorf("filter %v did not round-trip: %x != %x", f, encoded.FullBytes, reencoded.FullBytes)
+			return
+		}
+		if decoded.AllContexts != f.AllContexts || len(decoded.Contexts) != len(f.Contexts) || decoded.DNAttributes != f.DNAttributes {
+			t.Errorf("filter %v was decoded as %v", f, decoded)
+			return
+		}
+	}
+}
+
+func TestFilterEncoding(t *testing.T) {
+	cnA := []byte{0xa0, 0x0c, 0xa0, 0x0a, 0x30, 0x08, 0x06, 0x03, 0x55, 0x04, 0x03, 0x0c, 0x01, 'a'}
+	snB := []byte{0xa0, 0x0c, 0xa0, 0x0a, 0x30, 0x08, 0x06, 0x03, 0x55, 0x04, 0x04, 0x0c, 0x01, 'b'}
+	operands := append(append([]byte{0x31, 0x1c}, cnA...), snB...)
+	cases := []struct {
+		filter   string
+		expected []byte
+	}{
+		{"(cn=a)", cnA},
+		// The operands of and and or are within an explicitly tagged SET OF.
+		{"(&(cn=a)(sn=b))", append([]byte{0xa1, 0x1e}, operands...)},
+		{"(|(sn=b)(cn=a))", append([]byte{0xa2, 0x1e}, operands...)},
+		{"(!(cn=a))", append([]byte{0xa3, 0x0e}, cnA...)},
+	}
+	for _, c := range cases {
+		node, err := ParseFilter(c.filter)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		encoded, err := node.Marshal()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !bytes.Equal(encoded.FullBytes, c.expected) {
+			t.Errorf("%s: expected %x, but got %x", c.filter, c.expected, encoded.FullBytes)
+			continue
+		}
+		decoded, err := DecodeFilter(encoded)
+		if err != nil {
+			t.Errorf("%s: %s", c.filter, err)
+			continue
+		}
+		if reencoded, err := decoded.Marshal(); err != nil || !bytes.Equal(reencoded.FullBytes, c.expected) {
+			t.Errorf("%s: decoded as %s (%v)", c.filter, decoded, err)
+		}
+	}
+	// Operands that are not within a SET OF are rejected.
+	if _, err := DecodeFilter(Filter{FullBytes: append([]byte{0xa1, 0x0e}, cnA...)}); err == nil {
+		t.Error("expected and without a SET OF to be rejected")
+	}
+}
@@ -0,0 +1,472 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+)
+
+// The result of evaluating a filter, which is one of the three values
+// described in ITU-T Recommendation X.511, Section 7.8.
+type FilterResult int
+
+const (
+	// The filter could not be evaluated, such as because an attribute type
+	// or matching rule is unknown or an assertion value is invalid.
+	FilterUndefined FilterResult = iota
+	FilterFalse
+	FilterTrue
+)
+
+func (r FilterResult) String() string {
+	switch r {
+	case FilterTrue:
+		return "TRUE"
+	case FilterFalse:
+		return "FALSE"
+	}
+	return "UNDEFINED"
+}
+
+// Evaluates filters against the attributes of entries, as a DSA would. The
+// matching rules of attribute types are determined using `Schema`, and are
+// implemented by `MatchingRules`. If either is nil, [DefaultSchemaRegistry]
+// or [DefaultMatchingRuleRegistry] is used.
+type FilterEvaluator struct {
+	Schema        *SchemaRegistry
+	MatchingRules *MatchingRuleRegistry
+}
+
+func (e *FilterEvaluator) schema() *SchemaRegistry {
+	if e == nil || e.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return e.Schema
+}
+
+func (e *FilterEvaluator) matchingRules() *MatchingRuleRegistry {
+	if e == nil || e.MatchingRules == nil {
+		return DefaultMatchingRuleRegistry()
+	}
+	return e.MatchingRules
+}
+
+// A value of an attribute of the entry being evaluated.
+type evalValue struct {
+	attrType AttributeType
+	value    asn1.RawValue
+	contexts []Context
+}
+
+// The values of an entry, with the values of its distinguished name, which are
+// matched by extensibleMatch when dnAttributes is TRUE.
+type evalEntry struct {
+	attrs    []Attribute
+	dnValues []evalValue
+}
+
+// Returns true if `contexts` satisfy `assertion`: that is, if there is a
+// context of the asserted type with one of the asserted values, or, unless
+// `required` is true, if there is no context of the asserted type at all.
+// Context values are compared by their encodings.
+func contextAssertionHolds(assertion ContextAssertion, contexts []Context, required bool) bool {
+	present := false
+	for _, context := range contexts {
+		if !context.ContextType.Equal(assertion.ContextType) {
+			continue
+		}
+		present = true
+		for _, cv := range context.ContextValues {
+			for _, av := range assertion.ContextValues {
+				if bytes.Equal(encodingOf(cv), encodingOf(av)) {
+					return true
+				}
+			}
+		}
+	}
+	return !present && !required
+}
+
+// Returns the values of attributes of `attrType` or its subtypes.
+func (e *FilterEvaluator) valuesOf(entry *evalEntry, attrType AttributeType) (values []evalValue, found bool) {
+	schema := e.schema()
+	for i := range entry.attrs {
+		attr := &entry.attrs[i]
+		if !attr.Type.Equal(attrType) && !schema.IsSubtypeOf(attr.Type.String(), attrType.String()) {
+			continue
+		}
+		found = true
+		for _, v := range attr.Values {
+			values = append(values, evalValue{attrType: attr.Type, value: v})
+		}
+		for _, v := range attr.ValuesWithContext {
+			values = append(values, evalValue{attrType: attr.Type, value: v.Value, contexts: v.ContextList})
+		}
+	}
+	return values, found
+}
+
+// Returns true if the contexts of `v` satisfy the asserted contexts of `f`.
+func (f *FilterNode) contextsHold(v evalValue) bool {
+	if f.AllContexts {
+		return true
+	}
+	for _, assertion := range f.Contexts {
+		if !contextAssertionHolds(assertion, v.contexts, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate a filter item against a single value. The value must be of the
+// attribute type of the item or one of its subtypes, except for
+// extensibleMatch items with no attribute type.
+func (e *FilterEvaluator) matchValue(f *FilterNode, v evalValue) FilterResult {
+	var rule *MatchingRuleImpl
+	if f.Kind != FilterKindExtensibleMatch && f.Kind != FilterKindPresent && f.Kind != FilterKindContextPresent {
+		resolved, err := e.schema().ResolveAttributeType(f.Type.String())
+		if err != nil {
+			return FilterUndefined
+		}
+		var ruleID asn1.ObjectIdentifier
+		switch f.Kind {
+		case FilterKindEquality, FilterKindApproximateMatch:
+			// Approximate matching is implementation-defined, and may be
+			// equality matching.
+			ruleID = resolved.EqualityMatch
+		case FilterKindGreaterOrEqual, FilterKindLessOrEqual:
+			ruleID = resolved.OrderingMatch
+		case FilterKindSubstrings:
+			ruleID = resolved.SubstringsMatch
+		}
+		if ruleID == nil {
+			return FilterUndefined
+		}
+		if rule = e.matchingRules().Get(ruleID); rule == nil {
+			return FilterUndefined
+		}
+	}
+	var matched bool
+	var err error
+	switch f.Kind {
+	case FilterKindPresent:
+		return FilterTrue
+	case FilterKindContextPresent:
+		for _, assertion := range f.Contexts {
+			if !contextAssertionHolds(assertion, v.contexts, true) {
+				return FilterFalse
+			}
+		}
+		return FilterTrue
+	case FilterKindEquality, FilterKindApproximateMatch:
+		if f.Kind == FilterKindApproximateMatch && rule.ApproximateMatch != nil {
+			matched, err = rule.ApproximateMatch(f.Value, v.value)
+			break
+		}
+		if rule.Match == nil {
+			return FilterUndefined
+		}
+		matched, err = rule.Match(f.Value, v.value)
+	case FilterKindGreaterOrEqual, FilterKindLessOrEqual:
+		if rule.Compare == nil {
+			return FilterUndefined
+		}
+		var cmp int
+		cmp, err = rule.Compare(v.value, f.Value)
+		matched = (f.Kind == FilterKindGreaterOrEqual && cmp >= 0) ||
+			(f.Kind == FilterKindLessOrEqual && cmp <= 0)
+	case FilterKindSubstrings:
+		if rule.MatchSubstrings == nil {
+			return FilterUndefined
+		}
+		matched, err = rule.MatchSubstrings(f.Substrings, v.value)
+	case FilterKindExtensibleMatch:
+		if len(f.MatchingRules) == 0 {
+			resolved, err := e.schema().ResolveAttributeType(v.attrType.String())
+			if err != nil || resolved.EqualityMatch == nil {
+				return FilterUndefined
+			}
+			rule = e.matchingRules().Get(resolved.EqualityMatch)
+			if rule == nil || rule.Match == nil {
+				return FilterUndefined
+			}
+			matched, err = rule.Match(f.Value, v.value)
+			break
+		}
+		// The value must match under every one of the matching rules.
+		matched = true
+		for _, oid := range f.MatchingRules {
+			rule = e.matchingRules().Get(oid)
+			if rule == nil || rule.Match == nil {
+				return FilterUndefined
+			}
+			var m bool
+			if m, err = rule.Match(f.Value, v.value); err != nil || !m {
+				matched = false
+				break
+			}
+		}
+	default:
+		return FilterUndefined
+	}
+	if err != nil {
+		return FilterUndefined
+	}
+	if matched {
+		return FilterTrue
+	}
+	return FilterFalse
+}
+
+// Combine the results of evaluating a filter item against each value: TRUE if
+// any value matches, otherwise UNDEFINED if any could not be evaluated, and
+// otherwise FALSE.
+func (r FilterResult) orValue(next FilterResult) FilterResult {
+	if r == FilterTrue || next == FilterTrue {
+		return FilterTrue
+	}
+	if r == FilterUndefined || next == FilterUndefined {
+		return FilterUndefined
+	}
+	return FilterFalse
+}
+
+func (e *FilterEvaluator) evaluateItem(f *FilterNode, entry *evalEntry) FilterResult {
+	if f.Kind == FilterKindExtensibleMatch {
+		var values []evalValue
+		if f.Type != nil {
+			values, _ = e.valuesOf(entry, f.Type)
+		} else {
+			for i := range entry.attrs {
+				attrValues, _ := e.valuesOf(entry, entry.attrs[i].Type)
+				values = append(values, attrValues...)
+			}
+		}
+		if f.DNAttributes {
+			for _, v := range entry.dnValues {
+				if f.Type == nil || v.attrType.Equal(f.Type) || e.schema().IsSubtypeOf(v.attrType.String(), f.Type.String()) {
+					values = append(values, v)
+				}
+			}
+		}
+		result := FilterFalse
+		for _, v := range values {
+			result = result.orValue(e.matchValue(f, v))
+		}
+		// Without an attribute type, values of types to which the matching
+		// rule does not apply are simply ignored.
+		if f.Type == nil && result == FilterUndefined {
+			return FilterFalse
+		}
+		return result
+	}
+	if f.Type == nil {
+		return FilterUndefined
+	}
+	values, found := e.valuesOf(entry, f.Type)
+	if f.Kind == FilterKindPresent {
+		if found {
+			return FilterTrue
+		}
+		return FilterFalse
+	}
+	// The attribute type must be known and its matching rule implemented,
+	// even if the entry has no values to evaluate the item with.
+	if f.Kind != FilterKindContextPresent && !e.canMatch(f) {
+		return FilterUndefined
+	}
+	result := FilterFalse
+	for _, v := range values {
+		if f.Kind != FilterKindContextPresent && !f.contextsHold(v) {
+			continue
+		}
+		result = result.orValue(e.matchValue(f, v))
+	}
+	return result
+}
+
+// Returns true if the attribute type of `f` is known and has an implemented
+// matching rule of the kind `f` requires.
+func (e *FilterEvaluator) canMatch(f *FilterNode) bool {
+	resolved, err := e.schema().ResolveAttributeType(f.Type.String())
+	if err != nil {
+		return false
+	}
+	var rule *MatchingRuleImpl
+	switch f.Kind {
+	case FilterKindEquality, FilterKindApproximateMatch:
+		if resolved.EqualityMatch != nil {
+			rule = e.matchingRules().Get(resolved.EqualityMatch)
+		}
+		return rule != nil && (rule.Match != nil || (f.Kind == FilterKindApproximateMatch && rule.ApproximateMatch != nil))
+	case FilterKindGreaterOrEqual, FilterKindLessOrEqual:
+		if resolved.OrderingMatch != nil {
+			rule = e.matchingRules().Get(resolved.OrderingMatch)
+		}
+		return rule != nil && rule.Compare != nil
+	case FilterKindSubstrings:
+		if resolved.SubstringsMatch != nil {
+			rule = e.matchingRules().Get(resolved.SubstringsMatch)
+		}
+		return rule != nil && rule.MatchSubstrings != nil
+	}
+	return false
+}
+
+func (e *FilterEvaluator) evaluate(f *FilterNode, entry *evalEntry) FilterResult {
+	switch f.Kind {
+	case FilterKindAnd:
+		ret := FilterTrue
+		for _, operand := range f.Filters {
+			switch e.evaluate(operand, entry) {
+			case FilterFalse:
+				return FilterFalse
+			case FilterUndefined:
+				ret = FilterUndefined
+			}
+		}
+		return ret
+	case FilterKindOr:
+		ret := FilterFalse
+		for _, operand := range f.Filters {
+			switch e.evaluate(operand, entry) {
+			case FilterTrue:
+				return FilterTrue
+			case FilterUndefined:
+				ret = FilterUndefined
+			}
+		}
+		return ret
+	case FilterKindNot:
+		if len(f.Filters) != 1 {
+			return FilterUndefined
+		}
+		switch e.evaluate(f.Filters[0], entry) {
+		case FilterTrue:
+			return FilterFalse
+		case FilterFalse:
+			return FilterTrue
+		}
+		return FilterUndefined
+	}
+	return e.evaluateItem(f, entry)
+}
+
+// Evaluate `f` against an entry with the attributes `attrs`. Values with
+// contexts are only considered by attribute value assertions if they satisfy
+// the asserted contexts. A value satisfies a context assertion if it has a
+// context of the asserted type with one of the asserted values, or no context
+// of that type at all. extensibleMatch with dnAttributes set to TRUE does not
+// consider the distinguished name of the entry; use [FilterEvaluator.EvaluateEntry]
+// for that.
+func (e *FilterEvaluator) Evaluate(f *FilterNode, attrs []Attribute) FilterResult {
+	return e.evaluate(f, &evalEntry{attrs: attrs})
+}
+
+func entryInformationDN(entry *EntryInformation) (DistinguishedName, error) {
+	var dn DistinguishedName
+	if err := unmarshalExactly(entry.Name, &dn); err != nil {
+		return nil, errors.New("entry name is not an RDN sequence")
+	}
+	return dn, nil
+}
+
+// Evaluate `f` against `entry`, as in [FilterEvaluator.Evaluate], including
+// the values of the entry's distinguished name for extensibleMatch with
+// dnAttributes set to TRUE.
+func (e *FilterEvaluator) EvaluateEntry(f *FilterNode, entry *EntryInformation) (FilterResult, error) {
+	attrs, err := EntryInformationAttributes(entry)
+	if err != nil {
+		return FilterUndefined, err
+	}
+	dn, err := entryInformationDN(entry)
+	if err != nil {
+		return FilterUndefined, err
+	}
+	ee := &evalEntry{attrs: attrs}
+	for _, rdn := range dn {
+		for _, atav := range rdn {
+			value, err := dnRawValue(atav.Value)
+			if err != nil {
+				return FilterUndefined, err
+			}
+			ee.dnValues = append(ee.dnValues, evalValue{attrType: atav.Type, value: value})
+		}
+	}
+	return e.evaluate(f, ee), nil
+}
+
+// Returns the filter items of `f` that are not negated.
+func positiveFilterItems(f *FilterNode, items []*FilterNode) []*FilterNode {
+	switch f.Kind {
+	case FilterKindAnd, FilterKindOr:
+		for _, operand := range f.Filters {
+			items = positiveFilterItems(operand, items)
+		}
+		return items
+	case FilterKindNot:
+		return items
+	}
+	return append(items, f)
+}
+
+// Returns `attrs` with only the values that match a filter item of `f`, as a
+// DSA does when `matchedValuesOnly` is requested in a search. Attributes that
+// no filter item refers to are returned unchanged, and attributes that a
+// filter item refers to but with no matching values are omitted. Filter items
+// within `not` are ignored.
+func (e *FilterEvaluator) MatchedValues(f *FilterNode, attrs []Attribute) []Attribute {
+	items := positiveFilterItems(f, nil)
+	schema := e.schema()
+	ret := make([]Attribute, 0, len(attrs))
+	for _, attr := range attrs {
+		referenced := false
+		for _, item := range items {
+			if item.Type != nil && (attr.Type.Equal(item.Type) || schema.IsSubtypeOf(attr.Type.String(), item.Type.String())) {
+				referenced = true
+				break
+			}
+		}
+		if !referenced {
+			ret = append(ret, attr)
+			continue
+		}
+		matches := func(v evalValue) bool {
+			for _, item := range items {
+				if item.Type == nil || !(attr.Type.Equal(item.Type) || schema.IsSubtypeOf(attr.Type.String(), item.Type.String())) {
+					continue
+				}
+				if item.Kind != FilterKindContextPresent && item.Kind != FilterKindPresent && !item.contextsHold(v) {
+					continue
+				}
+				if e.matchValue(item, v) == FilterTrue {
+					return true
+				}
+			}
+			return false
+		}
+		matched := Attribute{Type: attr.Type}
+		for _, v := range attr.Values {
+			if matches(evalValue{attrType: attr.Type, value: v}) {
+				matched.Values = append(matched.Values, v)
+			}
+		}
+		for _, v := range attr.ValuesWithContext {
+			if matches(evalValue{attrType: attr.Type, value: v.Value, contexts: v.ContextList}) {
+				matched.ValuesWithContext = append(matched.ValuesWithContext, v)
+			}
+		}
+		if !matched.IsEmpty() {
+			ret = append(ret, matched)
+		}
+	}
+	return ret
+}
+
+// Evaluate `f` against an entry with the attributes `attrs`, using
+// [DefaultSchemaRegistry] and [DefaultMatchingRuleRegistry]. See
+// [FilterEvaluator.Evaluate].
+func EvaluateFilter(f *FilterNode, attrs []Attribute) FilterResult {
+	return (*FilterEvaluator)(nil).Evaluate(f, attrs)
+}
@@ -0,0 +1,230 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func mustParseFilter(t *testing.T, s string) *FilterNode {
+	t.Helper()
+	f, err := ParseFilter(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func mustMarshalValue(t *testing.T, v any, params string) asn1.RawValue {
+	t.Helper()
+	value, err := marshalRawValue(v, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func testEntryAttributes(t *testing.T) []Attribute {
+	return []Attribute{
+		{
+			Type:   Id_at_objectClass,
+			Values: []asn1.RawValue{mustMarshalValue(t, Id_oc_top, ""), mustMarshalValue(t, Id_oc_person, "")},
+		},
+		{
+			Type:   Id_at_commonName,
+			Values: []asn1.RawValue{NewDirectoryString("John  Smith"), NewDirectoryString("Jack")},
+		},
+		{
+			Type:   Id_at_surname,
+			Values: []asn1.RawValue{NewDirectoryString("Smith")},
+		},
+		{
+			Type:   Id_at_x121Address,
+			Values: []asn1.RawValue{mustMarshalValue(t, "12345", "numeric")},
+		},
+		{
+			Type: Id_at_description,
+			ValuesWithContext: []Attribute_valuesWithContext_Item{
+				{
+					Value: NewDirectoryString("Un homme"),
+					ContextList: []Context{{
+						ContextType:   Id_avc_language,
+						ContextValues: []asn1.RawValue{mustMarshalValue(t, "FR", "printable")},
+					}},
+				},
+				{
+					Value: NewDirectoryString("A man"),
+					ContextList: []Context{{
+						ContextType:   Id_avc_language,
+						ContextValues: []asn1.RawValue{mustMarshalValue(t, "EN", "printable")},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func TestEvaluateFilter(t *testing.T) {
+	attrs := testEntryAttributes(t)
+	for s, expected := range map[string]FilterResult{
+		"(cn=john smith)":                           FilterTrue,
+		"(cn=Jo*Sm*)":                               FilterTrue,
+		"(cn=*mith)":                                FilterTrue,
+		"(cn=*jack*)":                               FilterTrue,
+		"(cn=Joe*)":                                 FilterFalse,
+		"(&(objectClass=person)(sn=SMITH))":         FilterTrue,
+		"(&(objectClass=person)(sn=Jones))":         FilterFalse,
+		"(|(sn=Jones)(cn=Jack))":                    FilterTrue,
+		"(!(objectClass=person))":                   FilterFalse,
+		"(x121Address>=1234)":                       FilterTrue,
+		"(x121Address<=1234)":                       FilterFalse,
+		"(telephoneNumber=*)":                       FilterFalse,
+		"(description=*)":                           FilterTrue,
+		"(sn~=smith)":                               FilterTrue,
+		"(sn:caseExactMatch:=Smith)":                FilterTrue,
+		"(sn:caseExactMatch:=smith)":                FilterFalse,
+		"(:caseIgnoreMatch:=jack)":                  FilterTrue,
+		"(2.999.1=anything)":                        FilterUndefined,
+		"(&(2.999.1=anything)(objectClass=person))": FilterUndefined,
+		"(&(2.999.1=anything)(sn=Jones))":           FilterFalse,
+		"(|(2.999.1=anything)(sn=Smith))":           FilterTrue,
+		"(!(2.999.1=anything))":                     FilterUndefined,
+		"(cn>=a)":                                   FilterTrue,
+	} {
+		f := mustParseFilter(t, s)
+		if result := EvaluateFilter(f, attrs); result != expected {
+			t.Errorf("%s evaluated to %s, not %s", s, result, expected)
+		}
+	}
+	noEqualityRule := &FilterNode{
+		Kind:  FilterKindEquality,
+		Type:  Id_at_userPassword,
+		Value: mustMarshalValue(t, []byte("secret"), ""),
+	}
+	attrs = append(attrs, Attribute{Type: Id_at_userPassword, Values: []asn1.RawValue{noEqualityRule.Value}})
+	if r := EvaluateFilter(noEqualityRule, attrs); r != FilterUndefined {
+		t.Errorf("an attribute type without an equality matching rule evaluated to %s", r)
+	}
+}
+
+func TestEvaluateFilterContexts(t *testing.T) {
+	attrs := testEntryAttributes(t)
+	french := []ContextAssertion{{
+		ContextType:   Id_avc_language,
+		ContextValues: []asn1.RawValue{mustMarshalValue(t, "FR", "printable")},
+	}}
+	f := mustParseFilter(t, "(description=a man)")
+	if r := EvaluateFilter(f, attrs); r != FilterTrue {
+		t.Errorf("without asserted contexts, all values should be considered: %s", r)
+		return
+	}
+	f.Contexts = french
+	if r := EvaluateFilter(f, attrs); r != FilterFalse {
+		t.Errorf("the English value should not match a French context assertion: %s", r)
+		return
+	}
+	f.AllContexts = true
+	if r := EvaluateFilter(f, attrs); r != FilterTrue {
+		t.Errorf("allContexts should consider all values: %s", r)
+		return
+	}
+	contextPresent := &FilterNode{Kind: FilterKindContextPresent, Type: Id_at_description, Contexts: french}
+	if r := EvaluateFilter(contextPresent, attrs); r != FilterTrue {
+		t.Errorf("contextPresent evaluated to %s", r)
+		return
+	}
+	contextPresent.Type = Id_at_commonName
+	if r := EvaluateFilter(contextPresent, attrs); r != FilterFalse {
+		t.Errorf("contextPresent for values without contexts evaluated to %s", r)
+		return
+	}
+}
+
+func TestEvaluateFilterSubtypes(t *testing.T) {
+	schema := NewDefaultSchemaRegistry()
+	err := schema.AddAttributeType(AttributeTypeDescription{
+		Identifier: Id_at_name,
+		Name:       []UnboundedDirectoryString{NewDirectoryString("name")},
+		Information: AttributeTypeInformation{
+			EqualityMatch:   Id_mr_caseIgnoreMatch,
+			SubstringsMatch: Id_mr_caseIgnoreSubstringsMatch,
+			AttributeSyntax: NewDirectoryString("UnboundedDirectoryString"),
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = schema.AddAttributeType(AttributeTypeDescription{
+		Identifier:  Id_at_commonName,
+		Name:        []UnboundedDirectoryString{NewDirectoryString("cn")},
+		Information: AttributeTypeInformation{Derivation: Id_at_name},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	e := &FilterEvaluator{Schema: schema, MatchingRules: NewDefaultMatchingRuleRegistry(schema)}
+	f, err := ParseFilterWithOptions("(name=jack)", &FilterOptions{Schema: schema})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if r := e.Evaluate(f, testEntryAttributes(t)); r != FilterTrue {
+		t.Errorf("values of subtypes should be matched: %s", r)
+		return
+	}
+}
+
+func TestEvaluateEntryDNAttributes(t *testing.T) {
+	dn := mustParseDN(t, "CN=Bob,O=Example,C=GB")
+	name, err := asn1.Marshal(dn)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	entry := &EntryInformation{Name: asn1.RawValue{FullBytes: name}}
+	e := &FilterEvaluator{}
+	for s, expected := range map[string]FilterResult{
+		"(o:dn:=example)":                  FilterTrue,
+		"(o:=example)":                     FilterFalse,
+		"(:dn:caseIgnoreMatch:=gb)":        FilterTrue,
+		"(cn:dn:caseExactMatch:=bob)":      FilterFalse,
+		"(objectClass=person)":             FilterFalse,
+		"(!(cn:dn:caseExactMatch:=Alice))": FilterTrue,
+	} {
+		r, err := e.EvaluateEntry(mustParseFilter(t, s), entry)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if r != expected {
+			t.Errorf("%s evaluated to %s, not %s", s, r, expected)
+		}
+	}
+}
+
+func TestMatchedValues(t *testing.T) {
+	attrs := testEntryAttributes(t)
+	e := &FilterEvaluator{}
+	matched := e.MatchedValues(mustParseFilter(t, "(&(cn=Jo*)(sn=Jones)(!(x121Address=12345)))"), attrs)
+	byType := map[string]Attribute{}
+	for _, attr := range matched {
+		byType[attr.Type.String()] = attr
+	}
+	if cn := byType[Id_at_commonName.String()]; len(cn.Values) != 1 || string(cn.Values[0].Bytes) != "John  Smith" {
+		t.Errorf("expected only the matching cn value, got %v", cn.Values)
+		return
+	}
+	if _, ok := byType[Id_at_surname.String()]; ok {
+		t.Error("sn had no matching values and should have been omitted")
+		return
+	}
+	if oc := byType[Id_at_objectClass.String()]; len(oc.Values) != 2 {
+		t.Error("attributes that no filter item refers to should be unchanged")
+		return
+	}
+	if x121 := byType[Id_at_x121Address.String()]; len(x121.Values) != 1 {
+		t.Error("negated filter items should be ignored")
+		return
+	}
+}
@@ -0,0 +1,717 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// Options for parsing and formatting filters.
+type FilterOptions struct {
+	// Used to resolve attribute type, object class and matching rule names
+	// and determine the syntaxes of assertion values. If nil,
+	// [DefaultSchemaRegistry] is used.
+	Schema *SchemaRegistry
+}
+
+func (o *FilterOptions) schema() *SchemaRegistry {
+	if o == nil || o.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return o.Schema
+}
+
+// Resolve an attribute type given by name or in dotted-decimal form.
+func resolveAttributeType(schema *SchemaRegistry, descr string) (AttributeType, error) {
+	if len(descr) > 0 && descr[0] >= '0' && descr[0] <= '9' {
+		oid, err := stringToOID(descr)
+		if err != nil || len(oid) < 2 {
+			return nil, fmt.Errorf("invalid object identifier %q", descr)
+		}
+		return oid, nil
+	}
+	at := schema.AttributeType(descr)
+	if at == nil {
+		return nil, fmt.Errorf("unrecognized attribute type %q", descr)
+	}
+	return at.Identifier, nil
+}
+
+// Resolve a matching rule given by name or in dotted-decimal form.
+func resolveMatchingRule(schema *SchemaRegistry, descr string) (asn1.ObjectIdentifier, error) {
+	if len(descr) > 0 && descr[0] >= '0' && descr[0] <= '9' {
+		oid, err := stringToOID(descr)
+		if err != nil || len(oid) < 2 {
+			return nil, fmt.Errorf("invalid object identifier %q", descr)
+		}
+		return oid, nil
+	}
+	mr := schema.MatchingRule(descr)
+	if mr == nil {
+		return nil, fmt.Errorf("unrecognized matching rule %q", descr)
+	}
+	return mr.Identifier, nil
+}
+
+// Resolve an object identifier given in dotted-decimal form or as the name of
+// an object class or attribute type, which are the values of
+// `objectIdentifierMatch` that most commonly appear in filters.
+func resolveObjectIdentifier(schema *SchemaRegistry, descr string) (asn1.ObjectIdentifier, error) {
+	if len(descr) > 0 && descr[0] >= '0' && descr[0] <= '9' {
+		return resolveAttributeType(schema, descr)
+	}
+	if oc := schema.ObjectClass(descr); oc != nil {
+		return oc.Identifier, nil
+	}
+	if at := schema.AttributeType(descr); at != nil {
+		return at.Identifier, nil
+	}
+	return nil, fmt.Errorf("unrecognized object identifier %q", descr)
+}
+
+func marshalRawValue(v any, params string) (asn1.RawValue, error) {
+	encoded, err := asn1.MarshalWithParams(v, params)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	var value asn1.RawValue
+	_, err = asn1.Unmarshal(encoded, &value)
+	return value, err
+}
+
+func encodeUTF8Value(s string) (asn1.RawValue, error) {
+	if !utf8.ValidString(s) {
+		return asn1.RawValue{}, errors.New("invalid utf-8 in assertion value")
+	}
+	return marshalRawValue(s, "utf8")
+}
+
+// Encode `s` as an assertion about values of `attrType`. String values are
+// encoded using the string syntax of the attribute type, and other values
+// using its [SyntaxCodec], if it has one. Otherwise, the encoding is
+// determined by the equality matching rule of the attribute type, for those
+// rules whose assertions have an obvious string representation. Values of
+// attribute types that are not in `schema` are encoded as UTF8Strings.
+func encodeAssertionValue(schema *SchemaRegistry, attrType AttributeType, s string) (asn1.RawValue, error) {
+	resolved, err := schema.ResolveAttributeType(attrType.String())
+	if err != nil {
+		return encodeUTF8Value(s)
+	}
+	if _, ok := stringSyntaxParams(resolved.Syntax); ok {
+		return encodeStringValue(schema, attrType, s)
+	}
+	if codec := SyntaxCodecFor(resolved.Syntax); codec != nil {
+		return codec.Parse(schema, s)
+	}
+	rule := resolved.EqualityMatch
+	switch {
+	case rule.Equal(Id_mr_objectIdentifierMatch):
+		oid, err := resolveObjectIdentifier(schema, s)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		return marshalRawValue(oid, "")
+	case rule.Equal(Id_mr_distinguishedNameMatch):
+		dn, err := ParseDNWithOptions(s, &DNOptions{Schema: schema})
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		return marshalRawValue(dn, "")
+	case rule.Equal(Id_mr_uniqueMemberMatch):
+		dn, err := ParseDNWithOptions(s, &DNOptions{Schema: schema})
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		return marshalRawValue(NameAndOptionalUID{Dn: dn}, "")
+	case rule.Equal(Id_mr_integerMatch):
+		i, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return asn1.RawValue{}, fmt.Errorf("invalid integer %q", s)
+		}
+		return marshalRawValue(i, "")
+	case rule.Equal(Id_mr_booleanMatch):
+		switch s {
+		case "TRUE":
+			return marshalRawValue(true, "")
+		case "FALSE":
+			return marshalRawValue(false, "")
+		}
+		return asn1.RawValue{}, fmt.Errorf("invalid boolean %q", s)
+	case rule.Equal(Id_mr_bitStringMatch):
+		bits, err := parseBitString(s)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		return marshalRawValue(bits, "")
+	case rule.Equal(Id_mr_octetStringMatch):
+		return marshalRawValue([]byte(s), "")
+	}
+	return asn1.RawValue{}, fmt.Errorf("values of %s have no string encoding", attrType)
+}
+
+// Encode `s` as a component of a substrings assertion about values of
+// `attrType`. The components of assertions about values of structured
+// syntaxes, such as PostalAddress, are strings.
+func encodeSubstringValue(schema *SchemaRegistry, attrType AttributeType, s string) (asn1.RawValue, error) {
+	if resolved, err := schema.ResolveAttributeType(attrType.String()); err == nil {
+		if _, ok := stringSyntaxParams(resolved.Syntax); !ok && SyntaxCodecFor(resolved.Syntax) != nil {
+			return encodeUTF8Value(s)
+		}
+	}
+	return encodeAssertionValue(schema, attrType, s)
+}
+
+// Parse a bit string in the form '0101'B.
+func parseBitString(s string) (asn1.BitString, error) {
+	if len(s) < 3 || s[0] != '\'' || !strings.HasSuffix(s, "'B") {
+		return asn1.BitString{}, fmt.Errorf("invalid bit string %q", s)
+	}
+	digits := s[1 : len(s)-2]
+	bits := asn1.BitString{
+		Bytes:     make([]byte, (len(digits)+7)/8),
+		BitLength: len(digits),
+	}
+	for i, c := range digits {
+		switch c {
+		case '1':
+			bits.Bytes[i/8] |= 0x80 >> (i % 8)
+		case '0':
+		default:
+			return asn1.BitString{}, fmt.Errorf("invalid bit string %q", s)
+		}
+	}
+	return bits, nil
+}
+
+func formatBitString(bits asn1.BitString) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for i := 0; i < bits.BitLength; i++ {
+		b.WriteByte(byte('0' + bits.At(i)))
+	}
+	b.WriteString("'B")
+	return b.String()
+}
+
+// Returns the first name of an attribute type in `schema`, or its
+// dotted-decimal form if it has none.
+func attributeTypeName(schema *SchemaRegistry, attrType AttributeType) string {
+	if at := schema.AttributeType(attrType.String()); at != nil && len(at.Name) > 0 {
+		if name, err := DirectoryStringToString(at.Name[0]); err == nil {
+			return name
+		}
+	}
+	return attrType.String()
+}
+
+func matchingRuleName(schema *SchemaRegistry, rule asn1.ObjectIdentifier) string {
+	if mr := schema.MatchingRule(rule.String()); mr != nil && len(mr.Name) > 0 {
+		if name, err := DirectoryStringToString(mr.Name[0]); err == nil {
+			return name
+		}
+	}
+	return rule.String()
+}
+
+func objectIdentifierName(schema *SchemaRegistry, oid asn1.ObjectIdentifier) string {
+	if oc := schema.ObjectClass(oid.String()); oc != nil && len(oc.Name) > 0 {
+		if name, err := DirectoryStringToString(oc.Name[0]); err == nil {
+			return name
+		}
+	}
+	return attributeTypeName(schema, oid)
+}
+
+// Returns the string form of an assertion about values of `attrType`, which
+// may be nil, without escapes. This is the inverse of [encodeAssertionValue].
+func formatAssertionValue(schema *SchemaRegistry, attrType AttributeType, value asn1.RawValue) (string, error) {
+	if len(value.FullBytes) == 0 && len(value.Bytes) == 0 && value.Tag == 0 {
+		return "", errors.New("missing assertion value")
+	}
+	if attrType != nil && !(value.Class == asn1.ClassUniversal && isStringTag(value.Tag)) {
+		if codec := AttributeSyntaxCodec(schema, attrType); codec != nil {
+			if s, err := codec.Format(schema, value); err == nil {
+				return s, nil
+			}
+		}
+	}
+	if value.Class == asn1.ClassUniversal {
+		if isStringTag(value.Tag) {
+			return DirectoryStringToString(value)
+		}
+		switch value.Tag {
+		case asn1.TagOID:
+			var oid asn1.ObjectIdentifier
+			if err := unmarshalExactly(value, &oid); err != nil {
+				return "", err
+			}
+			return objectIdentifierName(schema, oid), nil
+		case asn1.TagInteger:
+			i := new(big.Int)
+			if err := unmarshalExactly(value, &i); err != nil {
+				return "", err
+			}
+			return i.String(), nil
+		case asn1.TagBoolean:
+			var b bool
+			if err := unmarshalExactly(value, &b); err != nil {
+				return "", err
+			}
+			if b {
+				return "TRUE", nil
+			}
+			return "FALSE", nil
+		case asn1.TagBitString:
+			var bits asn1.BitString
+			if err := unmarshalExactly(value, &bits); err != nil {
+				return "", err
+			}
+			return formatBitString(bits), nil
+		case asn1.TagOctetString:
+			return string(value.Bytes), nil
+		case asn1.TagSequence:
+			resolved, err := schema.ResolveAttributeType(attrType.String())
+			if err != nil {
+				break
+			}
+			rule := resolved.EqualityMatch
+			if rule.Equal(Id_mr_distinguishedNameMatch) {
+				var dn DistinguishedName
+				if err := unmarshalExactly(value, &dn); err != nil {
+					return "", err
+				}
+				return FormatDNWithOptions(dn, &DNOptions{Schema: schema})
+			}
+			var name NameAndOptionalUID
+			if rule.Equal(Id_mr_uniqueMemberMatch) && unmarshalExactly(value, &name) == nil && name.Uid.BitLength == 0 {
+				return FormatDNWithOptions(name.Dn, &DNOptions{Schema: schema})
+			}
+		}
+	}
+	return "", errors.New("assertion value has no string representation")
+}
+
+// Escape an assertion value as described in IETF RFC 4515, Section 3.
+func escapeFilterValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+type filterParser struct {
+	s      string
+	pos    int
+	schema *SchemaRegistry
+}
+
+func (p *filterParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *filterParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid filter at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *filterParser) expect(c byte) error {
+	if p.eof() || p.s[p.pos] != c {
+		return p.errorf("expected %q", c)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *filterParser) parseFilter() (*FilterNode, error) {
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	if p.eof() {
+		return nil, p.errorf("unterminated filter")
+	}
+	var f *FilterNode
+	var err error
+	switch p.s[p.pos] {
+	case '&', '|':
+		f = &FilterNode{Kind: FilterKindAnd}
+		if p.s[p.pos] == '|' {
+			f.Kind = FilterKindOr
+		}
+		p.pos++
+		for !p.eof() && p.s[p.pos] == '(' {
+			operand, err := p.parseFilter()
+			if err != nil {
+				return nil, err
+			}
+			f.Filters = append(f.Filters, operand)
+		}
+	case '!':
+		p.pos++
+		operand, err := p.parseFilter()
+		if err != nil {
+			return nil, err
+		}
+		f = &FilterNode{Kind: FilterKindNot, Filters: []*FilterNode{operand}}
+	default:
+		f, err = p.parseItem()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Returns the raw text of an assertion value, which ends at the next ')'.
+func (p *filterParser) parseRawValue() string {
+	start := p.pos
+	for !p.eof() && p.s[p.pos] != ')' {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// Remove the escapes of IETF RFC 4515 from a value.
+func (p *filterParser) unescape(raw string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '\\':
+			if i+2 >= len(raw) || !isHexDigit(raw[i+1]) || !isHexDigit(raw[i+2]) {
+				return "", p.errorf("invalid escape in %q", raw)
+			}
+			decoded, _ := hex.DecodeString(raw[i+1 : i+3])
+			b.WriteByte(decoded[0])
+			i += 2
+		case c == '(' || c == '*' || c == 0:
+			return "", p.errorf("unescaped %q in value", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), nil
+}
+
+func (p *filterParser) parseItem() (*FilterNode, error) {
+	start := p.pos
+	for !p.eof() && isAttributeTypeChar(p.s[p.pos]) {
+		p.pos++
+	}
+	descr := p.s[start:p.pos]
+	if p.eof() {
+		return nil, p.errorf("unterminated filter")
+	}
+	if p.s[p.pos] == ':' {
+		return p.parseExtensibleMatch(descr)
+	}
+	if p.s[p.pos] == ';' {
+		return nil, p.errorf("attribute options are not supported")
+	}
+	attrType, err := resolveAttributeType(p.schema, descr)
+	if err != nil {
+		return nil, p.errorf("%s", err)
+	}
+	f := &FilterNode{Type: attrType}
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "="):
+		f.Kind = FilterKindEquality
+		p.pos++
+	case strings.HasPrefix(p.s[p.pos:], "~="):
+		f.Kind = FilterKindApproximateMatch
+		p.pos += 2
+	case strings.HasPrefix(p.s[p.pos:], ">="):
+		f.Kind = FilterKindGreaterOrEqual
+		p.pos += 2
+	case strings.HasPrefix(p.s[p.pos:], "<="):
+		f.Kind = FilterKindLessOrEqual
+		p.pos += 2
+	default:
+		return nil, p.errorf("expected a filter type")
+	}
+	valueStart := p.pos
+	raw := p.parseRawValue()
+	if f.Kind == FilterKindEquality && raw == "*" {
+		f.Kind = FilterKindPresent
+		return f, nil
+	}
+	if f.Kind == FilterKindEquality && strings.Contains(raw, "*") {
+		f.Kind = FilterKindSubstrings
+		parts := strings.Split(raw, "*")
+		for i, part := range parts {
+			if part == "" {
+				continue
+			}
+			kind := SubstringAny
+			if i == 0 {
+				kind = SubstringInitial
+			} else if i == len(parts)-1 {
+				kind = SubstringFinal
+			}
+			s, err := p.unescape(part)
+			if err != nil {
+				return nil, err
+			}
+			v, err := encodeSubstringValue(p.schema, attrType, s)
+			if err != nil {
+				p.pos = valueStart
+				return nil, p.errorf("%s", err)
+			}
+			f.Substrings = append(f.Substrings, FilterSubstring{Kind: kind, Value: v})
+		}
+		return f, nil
+	}
+	s, err := p.unescape(raw)
+	if err != nil {
+		return nil, err
+	}
+	f.Value, err = encodeAssertionValue(p.schema, attrType, s)
+	if err != nil {
+		p.pos = valueStart
+		return nil, p.errorf("%s", err)
+	}
+	return f, nil
+}
+
+// Parse the remainder of an extensible match, after the attribute type, if
+// any: [":dn"] [":" matchingrule] ":=" assertionvalue.
+func (p *filterParser) parseExtensibleMatch(descr string) (*FilterNode, error) {
+	f := &FilterNode{Kind: FilterKindExtensibleMatch}
+	if descr != "" {
+		attrType, err := resolveAttributeType(p.schema, descr)
+		if err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		f.Type = attrType
+	}
+	if strings.HasPrefix(p.s[p.pos:], ":dn:") || strings.HasPrefix(p.s[p.pos:], ":DN:") {
+		f.DNAttributes = true
+		p.pos += 3
+	}
+	if !strings.HasPrefix(p.s[p.pos:], ":=") {
+		p.pos++
+		start := p.pos
+		for !p.eof() && isAttributeTypeChar(p.s[p.pos]) {
+			p.pos++
+		}
+		rule, err := resolveMatchingRule(p.schema, p.s[start:p.pos])
+		if err != nil {
+			return nil, p.errorf("%s", err)
+		}
+		f.MatchingRules = []asn1.ObjectIdentifier{rule}
+	}
+	if !strings.HasPrefix(p.s[p.pos:], ":=") {
+		return nil, p.errorf("expected \":=\"")
+	}
+	if f.Type == nil && len(f.MatchingRules) == 0 {
+		return nil, p.errorf("extensible match requires a matching rule or attribute type")
+	}
+	p.pos += 2
+	valueStart := p.pos
+	s, err := p.unescape(p.parseRawValue())
+	if err != nil {
+		return nil, err
+	}
+	if f.Type != nil {
+		f.Value, err = encodeAssertionValue(p.schema, f.Type, s)
+	} else {
+		f.Value, err = encodeUTF8Value(s)
+	}
+	if err != nil {
+		p.pos = valueStart
+		return nil, p.errorf("%s", err)
+	}
+	return f, nil
+}
+
+// Parse a filter in the string representation of IETF RFC 4515, resolving
+// names using [DefaultSchemaRegistry]. See [ParseFilterWithOptions].
+func ParseFilter(s string) (*FilterNode, error) {
+	return ParseFilterWithOptions(s, nil)
+}
+
+// Parse a filter in the string representation of IETF RFC 4515, such as
+// "(&(cn=Jo*)(objectClass=person))". Attribute types and matching rules may be
+// given by name or in dotted-decimal form. Assertion values are encoded using
+// the syntaxes of their attribute types in `options.Schema`; values of
+// attribute types with no string syntax are supported only where their
+// equality matching rule has an obvious string representation, such as
+// object identifiers, distinguished names, integers and booleans. Attribute
+// types that are not in the schema must be given in dotted-decimal form, and
+// their values are encoded as UTF8Strings. The outermost parentheses may be
+// omitted.
+func ParseFilterWithOptions(s string, options *FilterOptions) (*FilterNode, error) {
+	p := filterParser{s: s, schema: options.schema()}
+	if !strings.HasPrefix(s, "(") {
+		p.s = "(" + s + ")"
+	}
+	f, err := p.parseFilter()
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.errorf("trailing characters")
+	}
+	return f, nil
+}
+
+type filterFormatter struct {
+	schema *SchemaRegistry
+
+	// If true, values with no string representation are formatted as a '#'
+	// followed by their hexadecimal encoding, and parts of the filter that
+	// cannot be represented in IETF RFC 4515 are approximated.
+	lenient bool
+}
+
+func (ff *filterFormatter) value(attrType AttributeType, v asn1.RawValue) (string, error) {
+	s, err := formatAssertionValue(ff.schema, attrType, v)
+	if err != nil {
+		if !ff.lenient {
+			return "", err
+		}
+		return "#" + hex.EncodeToString(encodingOf(v)), nil
+	}
+	return escapeFilterValue(s), nil
+}
+
+func (ff *filterFormatter) format(b *strings.Builder, f *FilterNode) error {
+	b.WriteByte('(')
+	switch f.Kind {
+	case FilterKindAnd, FilterKindOr, FilterKindNot:
+		switch f.Kind {
+		case FilterKindAnd:
+			b.WriteByte('&')
+		case FilterKindOr:
+			b.WriteByte('|')
+		default:
+			if len(f.Filters) != 1 && !ff.lenient {
+				return errors.New("not filter must have exactly one operand")
+			}
+			b.WriteByte('!')
+		}
+		for _, operand := range f.Filters {
+			if err := ff.format(b, operand); err != nil {
+				return err
+			}
+		}
+	case FilterKindEquality, FilterKindGreaterOrEqual, FilterKindLessOrEqual, FilterKindApproximateMatch:
+		b.WriteString(attributeTypeName(ff.schema, f.Type))
+		b.WriteString(map[FilterKind]string{
+			FilterKindEquality:         "=",
+			FilterKindGreaterOrEqual:   ">=",
+			FilterKindLessOrEqual:      "<=",
+			FilterKindApproximateMatch: "~=",
+		}[f.Kind])
+		s, err := ff.value(f.Type, f.Value)
+		if err != nil {
+			return err
+		}
+		b.WriteString(s)
+	case FilterKindSubstrings:
+		b.WriteString(attributeTypeName(ff.schema, f.Type))
+		b.WriteByte('=')
+		// Nothing may follow a final substring.
+		wroteFinal := false
+		for i, s := range f.Substrings {
+			if s.Kind == SubstringControl || wroteFinal ||
+				(s.Kind == SubstringInitial && i > 0) {
+				if ff.lenient {
+					continue
+				}
+				return errors.New("substrings cannot be represented as a string")
+			}
+			if s.Kind != SubstringInitial {
+				b.WriteByte('*')
+			}
+			v, err := ff.value(f.Type, s.Value)
+			if err != nil {
+				return err
+			}
+			b.WriteString(v)
+			wroteFinal = s.Kind == SubstringFinal
+		}
+		if !wroteFinal {
+			b.WriteByte('*')
+		}
+	case FilterKindPresent:
+		b.WriteString(attributeTypeName(ff.schema, f.Type))
+		b.WriteString("=*")
+	case FilterKindExtensibleMatch:
+		if f.Type != nil {
+			b.WriteString(attributeTypeName(ff.schema, f.Type))
+		}
+		if f.DNAttributes {
+			b.WriteString(":dn")
+		}
+		if len(f.MatchingRules) > 1 && !ff.lenient {
+			return errors.New("extensible match with more than one matching rule cannot be represented as a string")
+		}
+		for _, rule := range f.MatchingRules {
+			b.WriteByte(':')
+			b.WriteString(matchingRuleName(ff.schema, rule))
+		}
+		b.WriteString(":=")
+		s, err := ff.value(f.Type, f.Value)
+		if err != nil {
+			return err
+		}
+		b.WriteString(s)
+	case FilterKindContextPresent:
+		if !ff.lenient {
+			return errors.New("contextPresent cannot be represented as a string")
+		}
+		b.WriteString(attributeTypeName(ff.schema, f.Type))
+		b.WriteString(":contextPresent:=*")
+	default:
+		return fmt.Errorf("unrecognized filter kind %d", f.Kind)
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// Format a filter in the string representation of IETF RFC 4515, using names
+// from [DefaultSchemaRegistry]. See [FormatFilterWithOptions].
+func FormatFilter(f *FilterNode) (string, error) {
+	return FormatFilterWithOptions(f, nil)
+}
+
+// Format a filter in the string representation of IETF RFC 4515, the inverse
+// of [ParseFilterWithOptions]. Attribute types and matching rules are given by
+// their first names in `options.Schema`, or in dotted-decimal form if they are
+// not registered. An error is returned if the filter uses features that IETF
+// RFC 4515 cannot represent, such as `contextPresent` or assertion values with
+// no string representation. Asserted contexts are omitted.
+func FormatFilterWithOptions(f *FilterNode, options *FilterOptions) (string, error) {
+	var b strings.Builder
+	ff := filterFormatter{schema: options.schema()}
+	if err := ff.format(&b, f); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Returns an approximation of the filter in the string representation of
+// IETF RFC 4515, for logging. Values with no string representation are given
+// as a '#' followed by their hexadecimal encoding. Use [FormatFilter] to
+// produce a string that can be parsed again.
+func (f *FilterNode) String() string {
+	var b strings.Builder
+	ff := filterFormatter{schema: DefaultSchemaRegistry(), lenient: true}
+	if err := ff.format(&b, f); err != nil {
+		return fmt.Sprintf("(invalid filter: %s)", err)
+	}
+	return b.String()
+}
@@ -0,0 +1,148 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter("(&(cn=Jo*)(objectClass=person))")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if f.Kind != FilterKindAnd || len(f.Filters) != 2 {
+		t.Errorf("parsed filter was %v", f)
+		return
+	}
+	cn := f.Filters[0]
+	if cn.Kind != FilterKindSubstrings || len(cn.Substrings) != 1 || cn.Substrings[0].Kind != SubstringInitial {
+		t.Errorf("substrings were parsed as %v", cn)
+		return
+	}
+	b := &FilterBuilder{}
+	expected, err := b.Build(b.And(
+		b.Substrings("cn", "Jo", nil, ""),
+		b.Equal("objectClass", "person"),
+	))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	encoded, err := f.Marshal()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(encoded.FullBytes, expected.FullBytes) {
+		t.Errorf("parsed filter was encoded as %x, not %x", encoded.FullBytes, expected.FullBytes)
+		return
+	}
+}
+
+func TestParseFilterValues(t *testing.T) {
+	f, err := ParseFilter(`(|(cn=a\2a\28b\29)(c=GB)(member=CN=Bob,DC=example,DC=net)(2.5.4.3:caseExactMatch:=X)(:dn:2.5.13.5:=Y)(x121Address>=123))`)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(f.Filters) != 6 {
+		t.Errorf("expected 6 operands, got %d", len(f.Filters))
+		return
+	}
+	if s := string(f.Filters[0].Value.Bytes); s != "a*(b)" {
+		t.Errorf("escapes were not removed: %q", s)
+		return
+	}
+	if f.Filters[1].Value.Tag != asn1.TagPrintableString {
+		t.Errorf("country name was encoded with tag %d", f.Filters[1].Value.Tag)
+		return
+	}
+	var member DistinguishedName
+	if err := unmarshalExactly(f.Filters[2].Value, &member); err != nil || len(member) != 3 {
+		t.Errorf("member was not encoded as a distinguished name: %v", err)
+		return
+	}
+	ext := f.Filters[3]
+	if ext.Kind != FilterKindExtensibleMatch || !ext.MatchingRules[0].Equal(Id_mr_caseExactMatch) || !ext.Type.Equal(Id_at_commonName) {
+		t.Errorf("extensible match was parsed as %v", ext)
+		return
+	}
+	if !f.Filters[4].DNAttributes || f.Filters[4].Type != nil {
+		t.Errorf("extensible match was parsed as %v", f.Filters[4])
+		return
+	}
+	if f.Filters[5].Kind != FilterKindGreaterOrEqual || f.Filters[5].Value.Tag != asn1.TagNumericString {
+		t.Errorf("ordering match was parsed as %v", f.Filters[5])
+		return
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, s := range []string{
+		"(cn=Bob",
+		"(&(cn=Bob)",
+		"(notAnAttribute=Bob)",
+		"(cn=a\\2)",
+		"(cn;lang-en=Bob)",
+		"(:=Bob)",
+		"(cn=Bob))",
+		"(x121Address=abc)",
+		"(objectClass=notAClass)",
+	} {
+		if _, err := ParseFilter(s); err == nil {
+			t.Errorf("parsing %q should have failed", s)
+			return
+		}
+	}
+}
+
+func TestFormatFilter(t *testing.T) {
+	for _, s := range []string{
+		"(&(CN=Jo*)(objectClass=person))",
+		`(CN=a\2a\28b\29\5c)`,
+		"(|(CN=*a*b*c)(sn=Smith*)(!(C=GB)))",
+		"(&(sn~=Smyth)(x121Address>=123)(x121Address<=456)(telephoneNumber=*))",
+		"(CN:dn:caseExactMatch:=Bob)",
+		"(:caseIgnoreMatch:=Bob)",
+		"(member=CN=Bob,DC=example,DC=net)",
+		"(2.999.1=hello)",
+	} {
+		f, err := ParseFilter(s)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		formatted, err := FormatFilter(f)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if formatted != s {
+			t.Errorf("%q was formatted as %q", s, formatted)
+			return
+		}
+	}
+}
+
+func TestFormatFilterErrors(t *testing.T) {
+	contextPresent := &FilterNode{Kind: FilterKindContextPresent, Type: Id_at_commonName}
+	if _, err := FormatFilter(contextPresent); err == nil {
+		t.Error("formatting contextPresent should fail")
+		return
+	}
+	if s := contextPresent.String(); s != "(CN:contextPresent:=*)" {
+		t.Errorf("contextPresent was logged as %q", s)
+		return
+	}
+	binary := &FilterNode{Kind: FilterKindEquality, Type: Id_at_userPassword, Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true}}
+	if _, err := FormatFilter(binary); err == nil {
+		t.Error("formatting a value with no string representation should fail")
+		return
+	}
+	if s := binary.String(); s != "(userPassword=#3000)" {
+		t.Errorf("binary value was logged as %q", s)
+		return
+	}
+}
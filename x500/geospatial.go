@@ -0,0 +1,383 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// # ASN.1 Definition:
+//
+//	WGS84Coordinates ::= SEQUENCE {
+//	  latitude   REAL (-90..90),   -- degrees north of the equator
+//	  longitude  REAL (-180..180), -- degrees east of the prime meridian
+//	  altitude   REAL OPTIONAL,    -- metres above the WGS 84 ellipsoid
+//	  ... }
+//
+// When converted to or from JSON, WGS84Coordinates is a GeoJSON position, as
+// defined in IETF RFC 7946, Section 3.1.1: an array of the longitude,
+// latitude and, if present, altitude.
+type WGS84Coordinates struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  *float64
+}
+
+// # ASN.1 Definition:
+//
+//	WGS84Position ::= WGS84Coordinates
+type WGS84Position = WGS84Coordinates
+
+// # ASN.1 Definition:
+//
+//	WGS84Line ::= SEQUENCE SIZE (2..MAX) OF WGS84Coordinates
+type WGS84Line []WGS84Coordinates
+
+// The first ring of a polygon is its exterior; any others are holes in it.
+// Each ring is closed: its first and last coordinates are the same.
+//
+// # ASN.1 Definition:
+//
+//	WGS84Polygon ::= SEQUENCE SIZE (1..MAX) OF
+//	  SEQUENCE SIZE (4..MAX) OF WGS84Coordinates
+type WGS84Polygon []WGS84Line
+
+// Returns an error if the latitude or longitude of `c` is out of range, or if
+// any of its members is not a finite number.
+func (c WGS84Coordinates) Validate() error {
+	if math.IsNaN(c.Latitude) || c.Latitude < -90 || c.Latitude > 90 {
+		return fmt.Errorf("latitude %g out of range", c.Latitude)
+	}
+	if math.IsNaN(c.Longitude) || c.Longitude < -180 || c.Longitude > 180 {
+		return fmt.Errorf("longitude %g out of range", c.Longitude)
+	}
+	if c.Altitude != nil && (math.IsNaN(*c.Altitude) || math.IsInf(*c.Altitude, 0)) {
+		return errors.New("altitude is not a finite number")
+	}
+	return nil
+}
+
+// Returns true if `c` and `other` are the same point. Altitudes are compared
+// only if both are present.
+func (c WGS84Coordinates) Equal(other WGS84Coordinates) bool {
+	if c.Latitude != other.Latitude || c.Longitude != other.Longitude {
+		return false
+	}
+	return c.Altitude == nil || other.Altitude == nil || *c.Altitude == *other.Altitude
+}
+
+// The mean radius of the Earth in metres, as used by the haversine formula.
+const earthRadius = 6371008.8
+
+// Returns the great-circle distance from `c` to `other` in metres. Altitude
+// is ignored.
+func (c WGS84Coordinates) Distance(other WGS84Coordinates) float64 {
+	lat1 := c.Latitude * math.Pi / 180
+	lat2 := other.Latitude * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (other.Longitude - c.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Returns a negative number if `c` is south of `other`, or at the same
+// latitude and west of it, a positive number if it is north or east, and
+// zero if they are at the same latitude and longitude.
+func (c WGS84Coordinates) Compare(other WGS84Coordinates) int {
+	switch {
+	case c.Latitude < other.Latitude:
+		return -1
+	case c.Latitude > other.Latitude:
+		return 1
+	case c.Longitude < other.Longitude:
+		return -1
+	case c.Longitude > other.Longitude:
+		return 1
+	}
+	return 0
+}
+
+func (c WGS84Coordinates) MarshalRawValue() (asn1.RawValue, error) {
+	if err := c.Validate(); err != nil {
+		return asn1.RawValue{}, err
+	}
+	components := [][]byte{encodeReal(c.Latitude).FullBytes, encodeReal(c.Longitude).FullBytes}
+	if c.Altitude != nil {
+		components = append(components, encodeReal(*c.Altitude).FullBytes)
+	}
+	return sequenceOf(components...)
+}
+
+func (c *WGS84Coordinates) UnmarshalRawValue(value asn1.RawValue) error {
+	components, err := sequenceComponents(value)
+	if err != nil {
+		return err
+	}
+	if len(components) < 2 {
+		return errors.New("wgs84 coordinates must have a latitude and longitude")
+	}
+	coords := WGS84Coordinates{}
+	if coords.Latitude, err = decodeReal(components[0]); err != nil {
+		return err
+	}
+	if coords.Longitude, err = decodeReal(components[1]); err != nil {
+		return err
+	}
+	// Unrecognized extensions are ignored.
+	if len(components) > 2 && components[2].Class == asn1.ClassUniversal && components[2].Tag == tagReal {
+		altitude, err := decodeReal(components[2])
+		if err != nil {
+			return err
+		}
+		coords.Altitude = &altitude
+	}
+	if err := coords.Validate(); err != nil {
+		return err
+	}
+	*c = coords
+	return nil
+}
+
+// Returns an error if `l` has fewer than two points, or any of its points is
+// invalid.
+func (l WGS84Line) Validate() error {
+	if len(l) < 2 {
+		return errors.New("a wgs84 line must have at least two points")
+	}
+	for _, c := range l {
+		if err := c.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l WGS84Line) MarshalRawValue() (asn1.RawValue, error) {
+	if err := l.Validate(); err != nil {
+		return asn1.RawValue{}, err
+	}
+	components := make([][]byte, 0, len(l))
+	for _, c := range l {
+		encoded, err := c.MarshalRawValue()
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		components = append(components, encoded.FullBytes)
+	}
+	return sequenceOf(components...)
+}
+
+func (l *WGS84Line) UnmarshalRawValue(value asn1.RawValue) error {
+	components, err := sequenceComponents(value)
+	if err != nil {
+		return err
+	}
+	line := make(WGS84Line, len(components))
+	for i, component := range components {
+		if err := line[i].UnmarshalRawValue(component); err != nil {
+			return err
+		}
+	}
+	if err := line.Validate(); err != nil {
+		return err
+	}
+	*l = line
+	return nil
+}
+
+// Returns an error if `p` has no rings, or any of its rings has fewer than
+// four points, is not closed, or has an invalid point.
+func (p WGS84Polygon) Validate() error {
+	if len(p) == 0 {
+		return errors.New("a wgs84 polygon must have at least one ring")
+	}
+	for _, ring := range p {
+		if len(ring) < 4 {
+			return errors.New("a wgs84 polygon ring must have at least four points")
+		}
+		if err := ring.Validate(); err != nil {
+			return err
+		}
+		if !ring[0].Equal(ring[len(ring)-1]) {
+			return errors.New("a wgs84 polygon ring must be closed")
+		}
+	}
+	return nil
+}
+
+func (p WGS84Polygon) MarshalRawValue() (asn1.RawValue, error) {
+	if err := p.Validate(); err != nil {
+		return asn1.RawValue{}, err
+	}
+	components := make([][]byte, 0, len(p))
+	for _, ring := range p {
+		encoded, err := ring.MarshalRawValue()
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		components = append(components, encoded.FullBytes)
+	}
+	return sequenceOf(components...)
+}
+
+func (p *WGS84Polygon) UnmarshalRawValue(value asn1.RawValue) error {
+	components, err := sequenceComponents(value)
+	if err != nil {
+		return err
+	}
+	polygon := make(WGS84Polygon, len(components))
+	for i, component := range components {
+		if err := polygon[i].UnmarshalRawValue(component); err != nil {
+			return err
+		}
+	}
+	if err := polygon.Validate(); err != nil {
+		return err
+	}
+	*p = polygon
+	return nil
+}
+
+// Returns true if `c` is inside the polygon `p`, and not in any of its holes.
+// Edges are treated as straight lines in latitude and longitude, which is
+// accurate enough for polygons that are small compared to the Earth.
+func (p WGS84Polygon) Contains(c WGS84Coordinates) bool {
+	if len(p) == 0 || !ringContains(p[0], c) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if ringContains(hole, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func ringContains(ring WGS84Line, c WGS84Coordinates) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Latitude > c.Latitude) != (b.Latitude > c.Latitude) &&
+			c.Longitude < (b.Longitude-a.Longitude)*(c.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// A GeoJSON geometry object, as defined in IETF RFC 7946, Section 3.1.
+// Coordinates is a WGS84Coordinates for a Point, a WGS84Line for a
+// LineString, and a WGS84Polygon for a Polygon.
+type GeoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+// Returns the GeoJSON Point geometry of `c`.
+func (c WGS84Coordinates) GeoJSON() GeoJSONGeometry {
+	return GeoJSONGeometry{Type: "Point", Coordinates: c}
+}
+
+// Returns the GeoJSON LineString geometry of `l`.
+func (l WGS84Line) GeoJSON() GeoJSONGeometry {
+	return GeoJSONGeometry{Type: "LineString", Coordinates: l}
+}
+
+// Returns the GeoJSON Polygon geometry of `p`.
+func (p WGS84Polygon) GeoJSON() GeoJSONGeometry {
+	return GeoJSONGeometry{Type: "Polygon", Coordinates: p}
+}
+
+func (c WGS84Coordinates) MarshalJSON() ([]byte, error) {
+	position := []float64{c.Longitude, c.Latitude}
+	if c.Altitude != nil {
+		position = append(position, *c.Altitude)
+	}
+	return json.Marshal(position)
+}
+
+func (c *WGS84Coordinates) UnmarshalJSON(data []byte) error {
+	var position []float64
+	if err := json.Unmarshal(data, &position); err != nil {
+		return err
+	}
+	if len(position) < 2 {
+		return errors.New("a geojson position must have at least two elements")
+	}
+	coords := WGS84Coordinates{Longitude: position[0], Latitude: position[1]}
+	if len(position) > 2 {
+		coords.Altitude = &position[2]
+	}
+	if err := coords.Validate(); err != nil {
+		return err
+	}
+	*c = coords
+	return nil
+}
+
+// Parses a GeoJSON Point, LineString or Polygon geometry object, and returns
+// a GeoJSONGeometry whose Coordinates are a WGS84Coordinates, WGS84Line or
+// WGS84Polygon respectively.
+func ParseGeoJSONGeometry(data []byte) (geometry GeoJSONGeometry, err error) {
+	var raw struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return geometry, err
+	}
+	geometry.Type = raw.Type
+	switch raw.Type {
+	case "Point":
+		var c WGS84Coordinates
+		err = json.Unmarshal(raw.Coordinates, &c)
+		geometry.Coordinates = c
+	case "LineString":
+		var l WGS84Line
+		if err = json.Unmarshal(raw.Coordinates, &l); err == nil {
+			err = l.Validate()
+		}
+		geometry.Coordinates = l
+	case "Polygon":
+		var p WGS84Polygon
+		if err = json.Unmarshal(raw.Coordinates, &p); err == nil {
+			err = p.Validate()
+		}
+		geometry.Coordinates = p
+	default:
+		return geometry, fmt.Errorf("unsupported geojson geometry type %q", raw.Type)
+	}
+	return geometry, err
+}
+
+// Returns an equality matching rule for values of WGS84Coordinates
+// identified by `oid`. Values match if they are the same point. The rule
+// also supports ordering, by latitude and then longitude, and approximate
+// matching, under which values match if they are no more than `tolerance`
+// metres apart.
+func NewWGS84CoordinatesMatchingRule(oid asn1.ObjectIdentifier, tolerance float64) *MatchingRuleImpl {
+	decode := func(assertion, value asn1.RawValue) (a, v WGS84Coordinates, err error) {
+		if err = a.UnmarshalRawValue(assertion); err != nil {
+			return
+		}
+		err = v.UnmarshalRawValue(value)
+		return
+	}
+	return &MatchingRuleImpl{
+		Identifier: oid,
+		Match: func(assertion, value asn1.RawValue) (bool, error) {
+			a, v, err := decode(assertion, value)
+			return err == nil && a.Equal(v), err
+		},
+		ApproximateMatch: func(assertion, value asn1.RawValue) (bool, error) {
+			a, v, err := decode(assertion, value)
+			return err == nil && a.Distance(v) <= tolerance, err
+		},
+		Compare: func(value, assertion asn1.RawValue) (int, error) {
+			a, v, err := decode(assertion, value)
+			return v.Compare(a), err
+		},
+	}
+}
@@ -0,0 +1,194 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func testPolygon() WGS84Polygon {
+	return WGS84Polygon{
+		{{0, 0, nil}, {0, 10, nil}, {10, 10, nil}, {10, 0, nil}, {0, 0, nil}},
+		{{4, 4, nil}, {4, 6, nil}, {6, 6, nil}, {6, 4, nil}, {4, 4, nil}},
+	}
+}
+
+func TestMarshalGeospatial(t *testing.T) {
+	type Facility struct {
+		Location WGS84Position   `x500:"oid:2.999.1"`
+		Route    WGS84Line       `x500:"oid:2.999.2"`
+		Site     WGS84Polygon    `x500:"oid:2.999.3"`
+		Exits    []WGS84Position `x500:"oid:2.999.4"`
+		Unused   WGS84Line       `x500:"oid:2.999.5"`
+	}
+	altitude := 35.5
+	in := Facility{
+		Location: WGS84Position{Latitude: 51.5007, Longitude: -0.1246, Altitude: &altitude},
+		Route:    WGS84Line{{51.5, -0.12, nil}, {51.51, -0.13, nil}},
+		Site:     testPolygon(),
+		Exits:    []WGS84Position{{1, 2, nil}, {3, 4, nil}},
+	}
+	attrs, err := Marshal(in)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(attrs) != 4 {
+		t.Errorf("marshalling produced %d attributes instead of 4", len(attrs))
+		return
+	}
+	if len(attrs[1].Values) != 1 || len(attrs[3].Values) != 2 {
+		t.Error("a line should be a single value, and a slice of positions multiple values")
+		return
+	}
+	var out Facility
+	err = Unmarshal(attrs, &out)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !out.Location.Equal(in.Location) || out.Location.Altitude == nil || *out.Location.Altitude != altitude {
+		t.Errorf("location was %+v", out.Location)
+		return
+	}
+	if len(out.Route) != 2 || !out.Route[1].Equal(in.Route[1]) {
+		t.Errorf("route was %+v", out.Route)
+		return
+	}
+	if len(out.Site) != 2 || len(out.Site[1]) != 5 || !out.Site[1][2].Equal(in.Site[1][2]) {
+		t.Errorf("site was %+v", out.Site)
+		return
+	}
+	if len(out.Exits) != 2 || !out.Exits[1].Equal(in.Exits[1]) {
+		t.Errorf("exits were %+v", out.Exits)
+		return
+	}
+}
+
+func TestGeospatialValidation(t *testing.T) {
+	for _, v := range []RawValueMarshaler{
+		WGS84Coordinates{Latitude: 91},
+		WGS84Coordinates{Longitude: -180.5},
+		WGS84Coordinates{Latitude: math.NaN()},
+		WGS84Line{{1, 1, nil}},
+		WGS84Polygon{{{0, 0, nil}, {0, 1, nil}, {1, 1, nil}, {1, 0, nil}}},
+		WGS84Polygon{},
+	} {
+		if _, err := v.MarshalRawValue(); err == nil {
+			t.Errorf("%+v should not have been valid", v)
+		}
+	}
+}
+
+func TestGeoJSON(t *testing.T) {
+	altitude := 10.0
+	point := WGS84Coordinates{Latitude: 1.5, Longitude: 2.5, Altitude: &altitude}
+	data, err := json.Marshal(point.GeoJSON())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(data) != `{"type":"Point","coordinates":[2.5,1.5,10]}` {
+		t.Errorf("point was %s", data)
+		return
+	}
+	data, err = json.Marshal(testPolygon().GeoJSON())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	geometry, err := ParseGeoJSONGeometry(data)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	polygon, ok := geometry.Coordinates.(WGS84Polygon)
+	if !ok || geometry.Type != "Polygon" || len(polygon) != 2 || !polygon[0][1].Equal(WGS84Coordinates{0, 10, nil}) {
+		t.Errorf("polygon was parsed as %+v", geometry)
+		return
+	}
+	geometry, err = ParseGeoJSONGeometry([]byte(`{"type":"LineString","coordinates":[[1,2],[3,4]]}`))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if line, ok := geometry.Coordinates.(WGS84Line); !ok || len(line) != 2 || line[1].Latitude != 4 {
+		t.Errorf("line was parsed as %+v", geometry)
+		return
+	}
+	for _, invalid := range []string{
+		`{"type":"Point","coordinates":[1]}`,
+		`{"type":"Point","coordinates":[1,100]}`,
+		`{"type":"LineString","coordinates":[[1,2]]}`,
+		`{"type":"MultiPoint","coordinates":[[1,2]]}`,
+	} {
+		if _, err := ParseGeoJSONGeometry([]byte(invalid)); err == nil {
+			t.Errorf("%s should not have been parsed", invalid)
+		}
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	p := testPolygon()
+	for c, expected := range map[WGS84Coordinates]bool{
+		{1, 1, nil}:   true,
+		{5, 5, nil}:   false,
+		{11, 5, nil}:  false,
+		{9, 2, nil}:   true,
+		{-1, -1, nil}: false,
+	} {
+		if p.Contains(c) != expected {
+			t.Errorf("%+v: expected %v", c, expected)
+		}
+	}
+}
+
+func TestWGS84CoordinatesMatchingRule(t *testing.T) {
+	location := asn1.ObjectIdentifier{2, 999, 1}
+	rule := asn1.ObjectIdentifier{2, 999, 2}
+	schema := NewDefaultSchemaRegistry()
+	err := schema.AddAttributeType(AttributeTypeDescription{
+		Identifier: location,
+		Name:       []UnboundedDirectoryString{NewDirectoryString("location")},
+		Information: AttributeTypeInformation{
+			EqualityMatch: rule,
+			OrderingMatch: rule,
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	rules := NewDefaultMatchingRuleRegistry(schema)
+	rules.Add(NewWGS84CoordinatesMatchingRule(rule, 1000))
+	e := &FilterEvaluator{Schema: schema, MatchingRules: rules}
+
+	encode := func(c WGS84Coordinates) asn1.RawValue {
+		value, err := c.MarshalRawValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	bigBen := WGS84Coordinates{Latitude: 51.5007, Longitude: -0.1246}
+	nearby := WGS84Coordinates{Latitude: 51.5033, Longitude: -0.1196} // About 440m away
+	attrs := []Attribute{{Type: location, Values: []asn1.RawValue{encode(bigBen)}}}
+	for _, tc := range []struct {
+		kind     FilterKind
+		value    WGS84Coordinates
+		expected FilterResult
+	}{
+		{FilterKindEquality, bigBen, FilterTrue},
+		{FilterKindEquality, nearby, FilterFalse},
+		{FilterKindApproximateMatch, nearby, FilterTrue},
+		{FilterKindApproximateMatch, WGS84Coordinates{Latitude: 48.8584, Longitude: 2.2945}, FilterFalse},
+		{FilterKindGreaterOrEqual, WGS84Coordinates{Latitude: 50}, FilterTrue},
+		{FilterKindLessOrEqual, WGS84Coordinates{Latitude: 50}, FilterFalse},
+	} {
+		f := &FilterNode{Kind: tc.kind, Type: location, Value: encode(tc.value)}
+		if r := e.Evaluate(f, attrs); r != tc.expected {
+			t.Errorf("%v %+v evaluated to %s, not %s", tc.kind, tc.value, r, tc.expected)
+		}
+	}
+}
@@ -5,4 +5,6 @@ go 1.23.4
 require (
 	github.com/Wildboar-Software/x500-go/teletex v1.0.0
 	github.com/sosodev/duration v1.3.1
+	golang.org/x/crypto v0.31.0
+	golang.org/x/text v0.21.0
 )
@@ -0,0 +1,885 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Converts between the schema descriptions of ITU-T Recommendation X.501,
+// Section 14.7, and their string forms in IETF RFC 4512, Section 4.1, as
+// published in the subschema subentries of DSAs that also speak LDAP, such as:
+//
+//	( 2.5.4.41 NAME 'name' EQUALITY caseIgnoreMatch SUBSTR caseIgnoreSubstringsMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15{32768} )
+//
+// The X.500 forms identify attribute syntaxes with free text, such as the
+// name of an ASN.1 type, so a SYNTAX is converted to that text in
+// dotted-decimal form without its length bound, and back again if the text is
+// either in dotted-decimal form or names a syntax that has a [SyntaxCodec].
+// Extensions, such as X-ORIGIN, have no X.500 equivalent, so they are
+// ignored.
+type LDAPSchemaCodec struct {
+	// Used to resolve the names of attribute types, object classes, matching
+	// rules and name forms when parsing, and to name them when formatting.
+	// Elements must be registered before other elements can refer to them by
+	// name. If nil, [DefaultSchemaRegistry] is used.
+	Schema *SchemaRegistry
+}
+
+func (c *LDAPSchemaCodec) schema() *SchemaRegistry {
+	if c == nil || c.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return c.Schema
+}
+
+// How the value of a keyword in an RFC 4512 description is written.
+type ldapSchemaArgument int
+
+const (
+	ldapSchemaFlag    ldapSchemaArgument = iota // No value, such as OBSOLETE.
+	ldapSchemaWord                              // One oid, such as EQUALITY.
+	ldapSchemaWords                             // One or more oids or ruleids, such as MUST.
+	ldapSchemaStrings                           // One or more quoted strings, such as NAME.
+	ldapSchemaString                            // One quoted string, such as DESC.
+)
+
+type ldapSchemaToken struct {
+	text   string
+	quoted bool
+	pos    int
+}
+
+// A parsed RFC 4512 description: its identifier, and the values of its
+// keywords, which are nil for flags.
+type ldapSchemaDescription struct {
+	id     string
+	values map[string][]string
+}
+
+func (d *ldapSchemaDescription) has(keyword string) bool {
+	_, ok := d.values[keyword]
+	return ok
+}
+
+func (d *ldapSchemaDescription) first(keyword string) string {
+	if values := d.values[keyword]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+type ldapSchemaParser struct {
+	tokens []ldapSchemaToken
+	end    int
+	i      int
+}
+
+func (p *ldapSchemaParser) errorf(format string, args ...any) error {
+	pos := p.end
+	if p.i < len(p.tokens) {
+		pos = p.tokens[p.i].pos
+	}
+	return fmt.Errorf("invalid schema description at position %d: %s", pos, fmt.Sprintf(format, args...))
+}
+
+// Returns the next token, which is empty at the end of the description.
+func (p *ldapSchemaParser) peek() ldapSchemaToken {
+	if p.i < len(p.tokens) {
+		return p.tokens[p.i]
+	}
+	return ldapSchemaToken{pos: p.end}
+}
+
+func (p *ldapSchemaParser) expect(text string) error {
+	if token := p.peek(); token.quoted || token.text != text {
+		return p.errorf("expected %q", text)
+	}
+	p.i++
+	return nil
+}
+
+func unescapeQDString(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("invalid escape in %q", s)
+		}
+		c, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil || (c != '\'' && c != '\\') {
+			return "", fmt.Errorf("invalid escape in %q", s)
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), nil
+}
+
+func tokenizeLDAPSchema(s string) ([]ldapSchemaToken, error) {
+	var tokens []ldapSchemaToken
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == '$':
+			tokens = append(tokens, ldapSchemaToken{text: s[i : i+1], pos: i})
+			i++
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid schema description at position %d: unterminated string", i)
+			}
+			text, err := unescapeQDString(s[i+1 : i+1+end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid schema description at position %d: %w", i, err)
+			}
+			tokens = append(tokens, ldapSchemaToken{text: text, quoted: true, pos: i})
+			i += end + 2
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\r\n()$'", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, ldapSchemaToken{text: s[start:i], pos: start})
+		}
+	}
+	return tokens, nil
+}
+
+// Parses the values of a keyword that are either one value or a
+// parenthesized list, separated by dollar signs or spaces.
+func (p *ldapSchemaParser) list(quoted bool) ([]string, error) {
+	item := func() (string, error) {
+		token := p.peek()
+		if token.quoted != quoted || token.text == "" || (!quoted && strings.Contains("()$", token.text)) {
+			if quoted {
+				return "", p.errorf("expected a quoted string")
+			}
+			return "", p.errorf("expected an object identifier or descriptor")
+		}
+		p.i++
+		return token.text, nil
+	}
+	if token := p.peek(); token.quoted || token.text != "(" {
+		value, err := item()
+		return []string{value}, err
+	}
+	p.i++
+	var values []string
+	for {
+		value, err := item()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if token := p.peek(); !token.quoted && token.text == "$" {
+			p.i++
+			continue
+		}
+		if token := p.peek(); !token.quoted && token.text == ")" {
+			p.i++
+			return values, nil
+		}
+	}
+}
+
+// Parses an RFC 4512 description whose keywords are among `keywords`, other
+// than extensions, which are ignored.
+func parseLDAPSchemaDescription(s string, keywords map[string]ldapSchemaArgument) (*ldapSchemaDescription, error) {
+	tokens, err := tokenizeLDAPSchema(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &ldapSchemaParser{tokens: tokens, end: len(s)}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	id := p.peek()
+	if id.quoted || id.text == "" || id.text[0] < '0' || id.text[0] > '9' {
+		return nil, p.errorf("expected a numeric identifier")
+	}
+	p.i++
+	d := &ldapSchemaDescription{id: id.text, values: make(map[string][]string)}
+	for {
+		token := p.peek()
+		if token.quoted || token.text == "" {
+			return nil, p.errorf("expected a keyword or \")\"")
+		}
+		if token.text == ")" {
+			p.i++
+			break
+		}
+		keyword := strings.ToUpper(token.text)
+		argument, ok := keywords[keyword]
+		if strings.HasPrefix(keyword, "X-") {
+			argument, ok = ldapSchemaStrings, true
+		}
+		if !ok {
+			return nil, p.errorf("unexpected %q", token.text)
+		}
+		if d.has(keyword) {
+			return nil, p.errorf("duplicate %s", keyword)
+		}
+		p.i++
+		var values []string
+		switch argument {
+		case ldapSchemaWord, ldapSchemaString:
+			next := p.peek()
+			if next.quoted != (argument == ldapSchemaString) || next.text == "" || (!next.quoted && strings.Contains("()$", next.text)) {
+				return nil, p.errorf("expected a value for %s", keyword)
+			}
+			values = []string{next.text}
+			p.i++
+		case ldapSchemaWords, ldapSchemaStrings:
+			if values, err = p.list(argument == ldapSchemaStrings); err != nil {
+				return nil, err
+			}
+		}
+		if !strings.HasPrefix(keyword, "X-") {
+			d.values[keyword] = values
+		}
+	}
+	if p.i < len(p.tokens) {
+		return nil, p.errorf("unexpected %q after description", p.tokens[p.i].text)
+	}
+	return d, nil
+}
+
+// Builds the string form of an RFC 4512 description.
+type ldapSchemaWriter struct {
+	b strings.Builder
+}
+
+func escapeQDString(s string) string {
+	return strings.NewReplacer(`\`, `\5C`, `'`, `\27`).Replace(s)
+}
+
+func newLDAPSchemaWriter(id string) *ldapSchemaWriter {
+	w := &ldapSchemaWriter{}
+	w.b.WriteString("( ")
+	w.b.WriteString(id)
+	return w
+}
+
+func (w *ldapSchemaWriter) flag(keyword string, present bool) {
+	if present {
+		w.b.WriteString(" " + keyword)
+	}
+}
+
+func (w *ldapSchemaWriter) word(keyword, value string) {
+	if value != "" {
+		w.b.WriteString(" " + keyword + " " + value)
+	}
+}
+
+func (w *ldapSchemaWriter) words(keyword string, values []string, separator string) {
+	switch len(values) {
+	case 0:
+	case 1:
+		w.word(keyword, values[0])
+	default:
+		w.b.WriteString(" " + keyword + " ( " + strings.Join(values, separator) + " )")
+	}
+}
+
+func (w *ldapSchemaWriter) qdstring(keyword, value string) {
+	if value != "" {
+		w.b.WriteString(" " + keyword + " '" + escapeQDString(value) + "'")
+	}
+}
+
+func (w *ldapSchemaWriter) names(names []UnboundedDirectoryString) error {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		s, err := DirectoryStringToString(name)
+		if err != nil {
+			return err
+		}
+		quoted = append(quoted, "'"+escapeQDString(s)+"'")
+	}
+	w.words("NAME", quoted, " ")
+	return nil
+}
+
+func (w *ldapSchemaWriter) String() string {
+	return w.b.String() + " )"
+}
+
+// The keywords common to all descriptions but those of LDAP syntaxes.
+var ldapSchemaCommonKeywords = map[string]ldapSchemaArgument{
+	"NAME":     ldapSchemaStrings,
+	"DESC":     ldapSchemaString,
+	"OBSOLETE": ldapSchemaFlag,
+}
+
+func ldapSchemaKeywords(keywords map[string]ldapSchemaArgument) map[string]ldapSchemaArgument {
+	for keyword, argument := range ldapSchemaCommonKeywords {
+		keywords[keyword] = argument
+	}
+	return keywords
+}
+
+var (
+	ldapAttributeTypeKeywords = ldapSchemaKeywords(map[string]ldapSchemaArgument{
+		"SUP":                  ldapSchemaWord,
+		"EQUALITY":             ldapSchemaWord,
+		"ORDERING":             ldapSchemaWord,
+		"SUBSTR":               ldapSchemaWord,
+		"SYNTAX":               ldapSchemaWord,
+		"SINGLE-VALUE":         ldapSchemaFlag,
+		"COLLECTIVE":           ldapSchemaFlag,
+		"NO-USER-MODIFICATION": ldapSchemaFlag,
+		"USAGE":                ldapSchemaWord,
+	})
+	ldapObjectClassKeywords = ldapSchemaKeywords(map[string]ldapSchemaArgument{
+		"SUP":        ldapSchemaWords,
+		"ABSTRACT":   ldapSchemaFlag,
+		"STRUCTURAL": ldapSchemaFlag,
+		"AUXILIARY":  ldapSchemaFlag,
+		"MUST":       ldapSchemaWords,
+		"MAY":        ldapSchemaWords,
+	})
+	ldapMatchingRuleKeywords = ldapSchemaKeywords(map[string]ldapSchemaArgument{
+		"SYNTAX": ldapSchemaWord,
+	})
+	ldapMatchingRuleUseKeywords = ldapSchemaKeywords(map[string]ldapSchemaArgument{
+		"APPLIES": ldapSchemaWords,
+	})
+	ldapNameFormKeywords = ldapSchemaKeywords(map[string]ldapSchemaArgument{
+		"OC":   ldapSchemaWord,
+		"MUST": ldapSchemaWords,
+		"MAY":  ldapSchemaWords,
+	})
+	ldapContentRuleKeywords = ldapSchemaKeywords(map[string]ldapSchemaArgument{
+		"AUX":  ldapSchemaWords,
+		"MUST": ldapSchemaWords,
+		"MAY":  ldapSchemaWords,
+		"NOT":  ldapSchemaWords,
+	})
+	ldapStructureRuleKeywords = ldapSchemaKeywords(map[string]ldapSchemaArgument{
+		"FORM": ldapSchemaWord,
+		"SUP":  ldapSchemaWords,
+	})
+	ldapSyntaxKeywords = map[string]ldapSchemaArgument{
+		"DESC": ldapSchemaString,
+	}
+)
+
+var attributeUsageKeywords = map[string]AttributeUsage{
+	"userApplications":     AttributeUsage_UserApplications,
+	"directoryOperation":   AttributeUsage_DirectoryOperation,
+	"distributedOperation": AttributeUsage_DistributedOperation,
+	"dSAOperation":         AttributeUsage_DSAOperation,
+}
+
+func parseNumericOID(s string) (asn1.ObjectIdentifier, error) {
+	oid, err := stringToOID(s)
+	if err != nil || len(oid) < 2 {
+		return nil, fmt.Errorf("invalid object identifier %q", s)
+	}
+	return oid, nil
+}
+
+func ldapNames(d *ldapSchemaDescription) []UnboundedDirectoryString {
+	var names []UnboundedDirectoryString
+	for _, name := range d.values["NAME"] {
+		names = append(names, NewDirectoryString(name))
+	}
+	return names
+}
+
+func (c *LDAPSchemaCodec) resolveAll(descrs []string, resolve func(*SchemaRegistry, string) (asn1.ObjectIdentifier, error)) ([]asn1.ObjectIdentifier, error) {
+	var oids []asn1.ObjectIdentifier
+	for _, descr := range descrs {
+		oid, err := resolve(c.schema(), descr)
+		if err != nil {
+			return nil, err
+		}
+		oids = append(oids, oid)
+	}
+	return oids, nil
+}
+
+func (c *LDAPSchemaCodec) resolveOne(descr string, resolve func(*SchemaRegistry, string) (asn1.ObjectIdentifier, error)) (asn1.ObjectIdentifier, error) {
+	if descr == "" {
+		return nil, nil
+	}
+	return resolve(c.schema(), descr)
+}
+
+func resolveObjectClass(schema *SchemaRegistry, descr string) (asn1.ObjectIdentifier, error) {
+	if len(descr) > 0 && descr[0] >= '0' && descr[0] <= '9' {
+		return parseNumericOID(descr)
+	}
+	if oc := schema.ObjectClass(descr); oc != nil {
+		return oc.Identifier, nil
+	}
+	return nil, fmt.Errorf("unrecognized object class %q", descr)
+}
+
+func resolveNameForm(schema *SchemaRegistry, descr string) (asn1.ObjectIdentifier, error) {
+	if len(descr) > 0 && descr[0] >= '0' && descr[0] <= '9' {
+		return parseNumericOID(descr)
+	}
+	if nf := schema.NameForm(descr); nf != nil {
+		return nf.Identifier, nil
+	}
+	return nil, fmt.Errorf("unrecognized name form %q", descr)
+}
+
+// Returns the text of an X.500 attribute syntax for the LDAP syntax `noidlen`,
+// without its length bound.
+func ldapSyntaxText(noidlen string) (string, error) {
+	noid, _, _ := strings.Cut(noidlen, "{")
+	if _, err := parseNumericOID(noid); err != nil {
+		return "", err
+	}
+	return noid, nil
+}
+
+// Returns the LDAP syntax for the X.500 attribute syntax `syntax`, or an empty
+// string if there is none.
+func ldapSyntaxOf(syntax string) string {
+	if _, err := parseNumericOID(syntax); err == nil {
+		return syntax
+	}
+	if codec := SyntaxCodecFor(syntax); codec != nil {
+		return codec.LDAPSyntax
+	}
+	return ""
+}
+
+func syntaxText(value UnboundedDirectoryString) (string, error) {
+	if len(value.FullBytes) == 0 && len(value.Bytes) == 0 {
+		return "", nil
+	}
+	value, err := explicitlyTaggedValue(value)
+	if err != nil {
+		return "", err
+	}
+	return DirectoryStringToString(value)
+}
+
+func (c *LDAPSchemaCodec) names(oids []asn1.ObjectIdentifier, name func(*SchemaRegistry, asn1.ObjectIdentifier) string) []string {
+	names := make([]string, 0, len(oids))
+	for _, oid := range oids {
+		names = append(names, name(c.schema(), oid))
+	}
+	return names
+}
+
+func (c *LDAPSchemaCodec) name(oid asn1.ObjectIdentifier, name func(*SchemaRegistry, asn1.ObjectIdentifier) string) string {
+	if len(oid) == 0 {
+		return ""
+	}
+	return name(c.schema(), oid)
+}
+
+func objectClassName(schema *SchemaRegistry, oid asn1.ObjectIdentifier) string {
+	if oc := schema.ObjectClass(oid.String()); oc != nil && len(oc.Name) > 0 {
+		if name, err := DirectoryStringToString(oc.Name[0]); err == nil {
+			return name
+		}
+	}
+	return oid.String()
+}
+
+func nameFormName(schema *SchemaRegistry, oid asn1.ObjectIdentifier) string {
+	if nf := schema.NameForm(oid.String()); nf != nil && len(nf.Name) > 0 {
+		if name, err := DirectoryStringToString(nf.Name[0]); err == nil {
+			return name
+		}
+	}
+	return oid.String()
+}
+
+// Parses an AttributeTypeDescription of IETF RFC 4512, Section 4.1.2.
+func (c *LDAPSchemaCodec) ParseAttributeType(s string) (*AttributeTypeDescription, error) {
+	d, err := parseLDAPSchemaDescription(s, ldapAttributeTypeKeywords)
+	if err != nil {
+		return nil, err
+	}
+	desc := &AttributeTypeDescription{Name: ldapNames(d), Description: d.first("DESC"), Obsolete: d.has("OBSOLETE")}
+	if desc.Identifier, err = parseNumericOID(d.id); err != nil {
+		return nil, err
+	}
+	info := &desc.Information
+	if info.Derivation, err = c.resolveOne(d.first("SUP"), resolveAttributeType); err != nil {
+		return nil, err
+	}
+	for keyword, rule := range map[string]*asn1.ObjectIdentifier{
+		"EQUALITY": &info.EqualityMatch,
+		"ORDERING": &info.OrderingMatch,
+		"SUBSTR":   &info.SubstringsMatch,
+	} {
+		if *rule, err = c.resolveOne(d.first(keyword), resolveMatchingRule); err != nil {
+			return nil, err
+		}
+	}
+	if d.has("SYNTAX") {
+		syntax, err := ldapSyntaxText(d.first("SYNTAX"))
+		if err != nil {
+			return nil, err
+		}
+		if info.AttributeSyntax, err = contextTagged(4, mustMarshalDirectoryString(syntax)); err != nil {
+			return nil, err
+		}
+	}
+	falseValue, err := asn1.Marshal(false)
+	if err != nil {
+		return nil, err
+	}
+	if d.has("SINGLE-VALUE") {
+		if info.Multi_valued, err = contextTagged(5, falseValue); err != nil {
+			return nil, err
+		}
+	}
+	if d.has("NO-USER-MODIFICATION") {
+		if info.UserModifiable, err = contextTagged(7, falseValue); err != nil {
+			return nil, err
+		}
+	}
+	info.Collective = d.has("COLLECTIVE")
+	if d.has("USAGE") {
+		usage, ok := attributeUsageKeywords[d.first("USAGE")]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized attribute usage %q", d.first("USAGE"))
+		}
+		info.Application = usage
+	}
+	return desc, nil
+}
+
+func mustMarshalDirectoryString(s string) []byte {
+	encoded, err := asn1.Marshal(NewDirectoryString(s))
+	if err != nil {
+		// A UTF8String always has an encoding.
+		panic(err)
+	}
+	return encoded
+}
+
+// Returns the AttributeTypeDescription of IETF RFC 4512, Section 4.1.2, for
+// `desc`. The attribute syntax is omitted if it has no LDAP syntax.
+func (c *LDAPSchemaCodec) FormatAttributeType(desc *AttributeTypeDescription) (string, error) {
+	info := &desc.Information
+	w := newLDAPSchemaWriter(desc.Identifier.String())
+	if err := w.names(desc.Name); err != nil {
+		return "", err
+	}
+	w.qdstring("DESC", desc.Description)
+	w.flag("OBSOLETE", desc.Obsolete)
+	w.word("SUP", c.name(info.Derivation, attributeTypeName))
+	w.word("EQUALITY", c.name(info.EqualityMatch, matchingRuleName))
+	w.word("ORDERING", c.name(info.OrderingMatch, matchingRuleName))
+	w.word("SUBSTR", c.name(info.SubstringsMatch, matchingRuleName))
+	syntax, err := syntaxText(info.AttributeSyntax)
+	if err != nil {
+		return "", err
+	}
+	w.word("SYNTAX", ldapSyntaxOf(syntax))
+	multiValued, err := decodeDefaultTrue(info.Multi_valued)
+	if err != nil {
+		return "", err
+	}
+	userModifiable, err := decodeDefaultTrue(info.UserModifiable)
+	if err != nil {
+		return "", err
+	}
+	w.flag("SINGLE-VALUE", !multiValued)
+	w.flag("COLLECTIVE", info.Collective)
+	w.flag("NO-USER-MODIFICATION", !userModifiable)
+	if info.Application != AttributeUsage_UserApplications {
+		for keyword, usage := range attributeUsageKeywords {
+			if usage == info.Application {
+				w.word("USAGE", keyword)
+			}
+		}
+	}
+	return w.String(), nil
+}
+
+// Parses an ObjectClassDescription of IETF RFC 4512, Section 4.1.1.
+func (c *LDAPSchemaCodec) ParseObjectClass(s string) (*ObjectClassDescription, error) {
+	d, err := parseLDAPSchemaDescription(s, ldapObjectClassKeywords)
+	if err != nil {
+		return nil, err
+	}
+	desc := &ObjectClassDescription{Name: ldapNames(d), Description: d.first("DESC"), Obsolete: d.has("OBSOLETE")}
+	if desc.Identifier, err = parseNumericOID(d.id); err != nil {
+		return nil, err
+	}
+	info := &desc.Information
+	info.Kind = ObjectClassKind_Structural
+	kinds := 0
+	for keyword, kind := range map[string]ObjectClassKind{
+		"ABSTRACT":   ObjectClassKind_Abstract,
+		"STRUCTURAL": ObjectClassKind_Structural,
+		"AUXILIARY":  ObjectClassKind_Auxiliary,
+	} {
+		if d.has(keyword) {
+			info.Kind = kind
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return nil, fmt.Errorf("object class %s has more than one kind", d.id)
+	}
+	if info.SubclassOf, err = c.resolveAll(d.values["SUP"], resolveObjectClass); err != nil {
+		return nil, err
+	}
+	if info.Mandatories, err = c.resolveAll(d.values["MUST"], resolveAttributeType); err != nil {
+		return nil, err
+	}
+	if info.Optionals, err = c.resolveAll(d.values["MAY"], resolveAttributeType); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+// Returns the ObjectClassDescription of IETF RFC 4512, Section 4.1.1, for
+// `desc`.
+func (c *LDAPSchemaCodec) FormatObjectClass(desc *ObjectClassDescription) (string, error) {
+	info := &desc.Information
+	w := newLDAPSchemaWriter(desc.Identifier.String())
+	if err := w.names(desc.Name); err != nil {
+		return "", err
+	}
+	w.qdstring("DESC", desc.Description)
+	w.flag("OBSOLETE", desc.Obsolete)
+	w.words("SUP", c.names(info.SubclassOf, objectClassName), " $ ")
+	w.flag("ABSTRACT", info.Kind == ObjectClassKind_Abstract)
+	w.flag("AUXILIARY", info.Kind == ObjectClassKind_Auxiliary)
+	w.words("MUST", c.names(info.Mandatories, attributeTypeName), " $ ")
+	w.words("MAY", c.names(info.Optionals, attributeTypeName), " $ ")
+	return w.String(), nil
+}
+
+// Parses a MatchingRuleDescription of IETF RFC 4512, Section 4.1.3.
+func (c *LDAPSchemaCodec) ParseMatchingRule(s string) (*MatchingRuleDescription, error) {
+	d, err := parseLDAPSchemaDescription(s, ldapMatchingRuleKeywords)
+	if err != nil {
+		return nil, err
+	}
+	desc := &MatchingRuleDescription{Name: ldapNames(d), Description: d.first("DESC"), Obsolete: d.has("OBSOLETE")}
+	if desc.Identifier, err = parseNumericOID(d.id); err != nil {
+		return nil, err
+	}
+	if !d.has("SYNTAX") {
+		return nil, fmt.Errorf("matching rule %s has no syntax", d.id)
+	}
+	syntax, err := ldapSyntaxText(d.first("SYNTAX"))
+	if err != nil {
+		return nil, err
+	}
+	desc.Information, err = contextTagged(0, mustMarshalDirectoryString(syntax))
+	return desc, err
+}
+
+// Returns the MatchingRuleDescription of IETF RFC 4512, Section 4.1.3, for
+// `desc`. The assertion syntax is omitted if it has no LDAP syntax.
+func (c *LDAPSchemaCodec) FormatMatchingRule(desc *MatchingRuleDescription) (string, error) {
+	w := newLDAPSchemaWriter(desc.Identifier.String())
+	if err := w.names(desc.Name); err != nil {
+		return "", err
+	}
+	w.qdstring("DESC", desc.Description)
+	w.flag("OBSOLETE", desc.Obsolete)
+	syntax, err := syntaxText(desc.Information)
+	if err != nil {
+		return "", err
+	}
+	w.word("SYNTAX", ldapSyntaxOf(syntax))
+	return w.String(), nil
+}
+
+// Parses a MatchingRuleUseDescription of IETF RFC 4512, Section 4.1.4.
+func (c *LDAPSchemaCodec) ParseMatchingRuleUse(s string) (*MatchingRuleUseDescription, error) {
+	d, err := parseLDAPSchemaDescription(s, ldapMatchingRuleUseKeywords)
+	if err != nil {
+		return nil, err
+	}
+	desc := &MatchingRuleUseDescription{Name: ldapNames(d), Description: d.first("DESC"), Obsolete: d.has("OBSOLETE")}
+	if desc.Identifier, err = parseNumericOID(d.id); err != nil {
+		return nil, err
+	}
+	if !d.has("APPLIES") {
+		return nil, fmt.Errorf("matching rule use %s applies to no attribute types", d.id)
+	}
+	desc.Information, err = c.resolveAll(d.values["APPLIES"], resolveAttributeType)
+	return desc, err
+}
+
+// Returns the MatchingRuleUseDescription of IETF RFC 4512, Section 4.1.4, for
+// `desc`.
+func (c *LDAPSchemaCodec) FormatMatchingRuleUse(desc *MatchingRuleUseDescription) (string, error) {
+	w := newLDAPSchemaWriter(desc.Identifier.String())
+	if err := w.names(desc.Name); err != nil {
+		return "", err
+	}
+	w.qdstring("DESC", desc.Description)
+	w.flag("OBSOLETE", desc.Obsolete)
+	w.words("APPLIES", c.names(desc.Information, attributeTypeName), " $ ")
+	return w.String(), nil
+}
+
+// Parses a NameFormDescription of IETF RFC 4512, Section 4.1.7.2.
+func (c *LDAPSchemaCodec) ParseNameForm(s string) (*NameFormDescription, error) {
+	d, err := parseLDAPSchemaDescription(s, ldapNameFormKeywords)
+	if err != nil {
+		return nil, err
+	}
+	desc := &NameFormDescription{Name: ldapNames(d), Description: d.first("DESC"), Obsolete: d.has("OBSOLETE")}
+	if desc.Identifier, err = parseNumericOID(d.id); err != nil {
+		return nil, err
+	}
+	if !d.has("OC") || !d.has("MUST") {
+		return nil, fmt.Errorf("name form %s must have an object class and mandatory attribute types", d.id)
+	}
+	info := &desc.Information
+	if info.Subordinate, err = resolveObjectClass(c.schema(), d.first("OC")); err != nil {
+		return nil, err
+	}
+	if info.NamingMandatories, err = c.resolveAll(d.values["MUST"], resolveAttributeType); err != nil {
+		return nil, err
+	}
+	info.NamingOptionals, err = c.resolveAll(d.values["MAY"], resolveAttributeType)
+	return desc, err
+}
+
+// Returns the NameFormDescription of IETF RFC 4512, Section 4.1.7.2, for
+// `desc`.
+func (c *LDAPSchemaCodec) FormatNameForm(desc *NameFormDescription) (string, error) {
+	info := &desc.Information
+	w := newLDAPSchemaWriter(desc.Identifier.String())
+	if err := w.names(desc.Name); err != nil {
+		return "", err
+	}
+	w.qdstring("DESC", desc.Description)
+	w.flag("OBSOLETE", desc.Obsolete)
+	w.word("OC", c.name(info.Subordinate, objectClassName))
+	w.words("MUST", c.names(info.NamingMandatories, attributeTypeName), " $ ")
+	w.words("MAY", c.names(info.NamingOptionals, attributeTypeName), " $ ")
+	return w.String(), nil
+}
+
+// Parses a DITContentRuleDescription of IETF RFC 4512, Section 4.1.6. Its
+// identifier is that of the structural object class to which it applies.
+func (c *LDAPSchemaCodec) ParseDITContentRule(s string) (*DITContentRuleDescription, error) {
+	d, err := parseLDAPSchemaDescription(s, ldapContentRuleKeywords)
+	if err != nil {
+		return nil, err
+	}
+	desc := &DITContentRuleDescription{Name: ldapNames(d), Description: d.first("DESC"), Obsolete: d.has("OBSOLETE")}
+	if desc.StructuralObjectClass, err = parseNumericOID(d.id); err != nil {
+		return nil, err
+	}
+	if desc.Auxiliaries, err = c.resolveAll(d.values["AUX"], resolveObjectClass); err != nil {
+		return nil, err
+	}
+	for keyword, types := range map[string]*[]asn1.ObjectIdentifier{
+		"MUST": &desc.Mandatory,
+		"MAY":  &desc.Optional,
+		"NOT":  &desc.Precluded,
+	} {
+		if *types, err = c.resolveAll(d.values[keyword], resolveAttributeType); err != nil {
+			return nil, err
+		}
+	}
+	return desc, nil
+}
+
+// Returns the DITContentRuleDescription of IETF RFC 4512, Section 4.1.6, for
+// `desc`.
+func (c *LDAPSchemaCodec) FormatDITContentRule(desc *DITContentRuleDescription) (string, error) {
+	w := newLDAPSchemaWriter(desc.StructuralObjectClass.String())
+	if err := w.names(desc.Name); err != nil {
+		return "", err
+	}
+	w.qdstring("DESC", desc.Description)
+	w.flag("OBSOLETE", desc.Obsolete)
+	w.words("AUX", c.names(desc.Auxiliaries, objectClassName), " $ ")
+	w.words("MUST", c.names(desc.Mandatory, attributeTypeName), " $ ")
+	w.words("MAY", c.names(desc.Optional, attributeTypeName), " $ ")
+	w.words("NOT", c.names(desc.Precluded, attributeTypeName), " $ ")
+	return w.String(), nil
+}
+
+// Parses a DITStructureRuleDescription of IETF RFC 4512, Section 4.1.7.1.
+func (c *LDAPSchemaCodec) ParseDITStructureRule(s string) (*DITStructureRuleDescription, error) {
+	d, err := parseLDAPSchemaDescription(s, ldapStructureRuleKeywords)
+	if err != nil {
+		return nil, err
+	}
+	desc := &DITStructureRuleDescription{Name: ldapNames(d), Description: d.first("DESC"), Obsolete: d.has("OBSOLETE")}
+	if desc.RuleIdentifier, err = strconv.Atoi(d.id); err != nil {
+		return nil, fmt.Errorf("invalid rule identifier %q", d.id)
+	}
+	if !d.has("FORM") {
+		return nil, fmt.Errorf("structure rule %s has no name form", d.id)
+	}
+	if desc.NameForm, err = resolveNameForm(c.schema(), d.first("FORM")); err != nil {
+		return nil, err
+	}
+	for _, sup := range d.values["SUP"] {
+		id, err := strconv.Atoi(sup)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule identifier %q", sup)
+		}
+		desc.SuperiorStructureRules = append(desc.SuperiorStructureRules, id)
+	}
+	return desc, nil
+}
+
+// Returns the DITStructureRuleDescription of IETF RFC 4512, Section 4.1.7.1,
+// for `desc`.
+func (c *LDAPSchemaCodec) FormatDITStructureRule(desc *DITStructureRuleDescription) (string, error) {
+	w := newLDAPSchemaWriter(strconv.Itoa(desc.RuleIdentifier))
+	if err := w.names(desc.Name); err != nil {
+		return "", err
+	}
+	w.qdstring("DESC", desc.Description)
+	w.flag("OBSOLETE", desc.Obsolete)
+	w.word("FORM", c.name(desc.NameForm, nameFormName))
+	superiors := make([]string, 0, len(desc.SuperiorStructureRules))
+	for _, id := range desc.SuperiorStructureRules {
+		superiors = append(superiors, strconv.Itoa(id))
+	}
+	w.words("SUP", superiors, " ")
+	return w.String(), nil
+}
+
+// Parses a SyntaxDescription of IETF RFC 4512, Section 4.1.5.
+func (c *LDAPSchemaCodec) ParseLdapSyntax(s string) (*LdapSyntaxDescription, error) {
+	d, err := parseLDAPSchemaDescription(s, ldapSyntaxKeywords)
+	if err != nil {
+		return nil, err
+	}
+	desc := &LdapSyntaxDescription{}
+	if desc.Identifier, err = parseNumericOID(d.id); err != nil {
+		return nil, err
+	}
+	if d.has("DESC") {
+		desc.Description = NewDirectoryString(d.first("DESC"))
+	}
+	return desc, nil
+}
+
+// Returns the SyntaxDescription of IETF RFC 4512, Section 4.1.5, for `desc`.
+func (c *LDAPSchemaCodec) FormatLdapSyntax(desc *LdapSyntaxDescription) (string, error) {
+	w := newLDAPSchemaWriter(desc.Identifier.String())
+	if len(desc.Description.FullBytes) > 0 || len(desc.Description.Bytes) > 0 {
+		description, err := DirectoryStringToString(desc.Description)
+		if err != nil {
+			return "", err
+		}
+		w.qdstring("DESC", description)
+	}
+	return w.String(), nil
+}
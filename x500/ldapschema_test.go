@@ -0,0 +1,224 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func TestLDAPSchemaAttributeType(t *testing.T) {
+	codec := &LDAPSchemaCodec{}
+	desc, err := codec.ParseAttributeType("( 2.5.4.41 NAME 'name' DESC 'RFC4519: common supertype of name attributes' " +
+		"EQUALITY caseIgnoreMatch SUBSTR caseIgnoreSubstringsMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15{32768} X-ORIGIN 'RFC 4519' )")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !desc.Information.EqualityMatch.Equal(testCaseIgnoreMatch) || !desc.Information.SubstringsMatch.Equal(testCaseIgnoreSubstringsMatch) {
+		t.Errorf("unexpected matching rules: %+v", desc.Information)
+		return
+	}
+	formatted, err := codec.FormatAttributeType(desc)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := "( 2.5.4.41 NAME 'name' DESC 'RFC4519: common supertype of name attributes' " +
+		"EQUALITY caseIgnoreMatch SUBSTR caseIgnoreSubstringsMatch SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )"
+	if formatted != expected {
+		t.Errorf("expected %s, but got %s", expected, formatted)
+	}
+
+	desc, err = codec.ParseAttributeType("( 2.5.18.1 NAME 'createTimestamp' EQUALITY generalizedTimeMatch " +
+		"SYNTAX 1.3.6.1.4.1.1466.115.121.1.24 SINGLE-VALUE NO-USER-MODIFICATION USAGE directoryOperation )")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// Round-trip the description through its X.500 encoding.
+	var decoded AttributeTypeDescription
+	if _, err := UnmarshalSchemaDescription(schemaValue(t, *desc).FullBytes, &decoded); err != nil {
+		t.Error(err)
+		return
+	}
+	multiValued, err := decodeDefaultTrue(decoded.Information.Multi_valued)
+	if err != nil || multiValued {
+		t.Errorf("expected a single-valued attribute type, but got %+v", decoded.Information)
+		return
+	}
+	formatted, err = codec.FormatAttributeType(&decoded)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected = "( 2.5.18.1 NAME 'createTimestamp' EQUALITY generalizedTimeMatch " +
+		"SYNTAX 1.3.6.1.4.1.1466.115.121.1.24 SINGLE-VALUE NO-USER-MODIFICATION USAGE directoryOperation )"
+	if formatted != expected {
+		t.Errorf("expected %s, but got %s", expected, formatted)
+	}
+}
+
+func TestLDAPSchemaObjectClass(t *testing.T) {
+	schema, err := NewSchemaRegistryFromAttributes(testSubschemaAttributes(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	codec := &LDAPSchemaCodec{Schema: schema}
+	desc, err := codec.ParseObjectClass("( 2.5.6.6 NAME 'person' SUP top STRUCTURAL MUST ( sn $ cn ) MAY 2.5.4.13 )")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	info := desc.Information
+	if info.Kind != ObjectClassKind_Structural || len(info.SubclassOf) != 1 || !info.SubclassOf[0].Equal(Id_oc_top) ||
+		len(info.Mandatories) != 2 || !info.Mandatories[0].Equal(Id_at_surname) {
+		t.Errorf("unexpected object class: %+v", info)
+		return
+	}
+	formatted, err := codec.FormatObjectClass(desc)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// Structural is the default kind, so it is not written.
+	expected := "( 2.5.6.6 NAME 'person' SUP top MUST ( sn $ cn ) MAY 2.5.4.13 )"
+	if formatted != expected {
+		t.Errorf("expected %s, but got %s", expected, formatted)
+	}
+	if _, err := codec.ParseObjectClass("( 2.5.6.6 NAME 'person' ABSTRACT AUXILIARY )"); err == nil {
+		t.Error("expected an object class of two kinds to be rejected")
+	}
+}
+
+func TestLDAPSchemaOtherDescriptions(t *testing.T) {
+	schema, err := NewSchemaRegistryFromAttributes(testSubschemaAttributes(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	codec := &LDAPSchemaCodec{Schema: schema}
+	roundTrip := func(s string, parse func(string) (any, error), format func(any) (string, error)) {
+		t.Helper()
+		desc, err := parse(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			return
+		}
+		formatted, err := format(desc)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			return
+		}
+		if formatted != s {
+			t.Errorf("expected %s, but got %s", s, formatted)
+		}
+	}
+	roundTrip("( 2.5.13.2 NAME 'caseIgnoreMatch' SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )",
+		func(s string) (any, error) { return codec.ParseMatchingRule(s) },
+		func(d any) (string, error) { return codec.FormatMatchingRule(d.(*MatchingRuleDescription)) })
+	roundTrip("( 2.5.13.2 NAME 'caseIgnoreMatch' APPLIES ( name $ cn $ sn ) )",
+		func(s string) (any, error) { return codec.ParseMatchingRuleUse(s) },
+		func(d any) (string, error) {
+			return codec.FormatMatchingRuleUse(d.(*MatchingRuleUseDescription))
+		})
+	roundTrip("( 1.2.3 NAME 'personNameForm' DESC 'It\\27s a \\5C test' OC person MUST cn MAY sn )",
+		func(s string) (any, error) { return codec.ParseNameForm(s) },
+		func(d any) (string, error) { return codec.FormatNameForm(d.(*NameFormDescription)) })
+	roundTrip("( 2.5.6.6 NAME 'personContentRule' OBSOLETE AUX 2.5.6.11 MAY name NOT ( cn $ sn ) )",
+		func(s string) (any, error) { return codec.ParseDITContentRule(s) },
+		func(d any) (string, error) { return codec.FormatDITContentRule(d.(*DITContentRuleDescription)) })
+	roundTrip("( 2 NAME 'personRule' FORM 1.2.3 SUP ( 1 3 ) )",
+		func(s string) (any, error) { return codec.ParseDITStructureRule(s) },
+		func(d any) (string, error) { return codec.FormatDITStructureRule(d.(*DITStructureRuleDescription)) })
+	roundTrip("( 1.3.6.1.4.1.1466.115.121.1.15 DESC 'Directory String' )",
+		func(s string) (any, error) { return codec.ParseLdapSyntax(s) },
+		func(d any) (string, error) { return codec.FormatLdapSyntax(d.(*LdapSyntaxDescription)) })
+}
+
+func TestLDAPSchemaErrors(t *testing.T) {
+	codec := &LDAPSchemaCodec{}
+	for _, s := range []string{
+		"",
+		"2.5.4.3 NAME 'cn'",
+		"( cn NAME 'cn' )",
+		"( 2.5.4.3 NAME 'cn'",
+		"( 2.5.4.3 NAME 'cn )",
+		"( 2.5.4.3 NAME 'cn' NAME 'commonName' )",
+		"( 2.5.4.3 FROB )",
+		"( 2.5.4.3 SUP noSuchType )",
+		"( 2.5.4.3 USAGE everybody )",
+		"( 2.5.4.3 DESC '\\41' )",
+		"( 2.5.4.3 ) extra",
+	} {
+		if _, err := codec.ParseAttributeType(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestUnmarshalSchemaDescriptionUniversalString(t *testing.T) {
+	universal := asn1.RawValue{Class: asn1.ClassUniversal, Tag: tagUniversalString, Bytes: []byte{0, 0, 0, 'h', 0, 0, 0, 'i'}}
+	encodedUniversal, err := asn1.Marshal(universal)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	id, err := asn1.Marshal(Id_at_name)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	seq, err := sequenceOf(id, encodedUniversal)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var desc LdapSyntaxDescription
+	if _, err := UnmarshalSchemaDescription(seq.FullBytes, &desc); err != nil {
+		t.Error(err)
+		return
+	}
+	if s, err := DirectoryStringToString(desc.Description); err != nil || s != "hi" {
+		t.Errorf("expected \"hi\", but got %q (%v)", s, err)
+	}
+
+	// The description of a DITContentRuleDescription is a Go string.
+	var rule DITContentRuleDescription
+	if _, err := UnmarshalSchemaDescription(seq.FullBytes, &rule); err != nil {
+		t.Error(err)
+		return
+	}
+	if rule.Description != "hi" {
+		t.Errorf("expected \"hi\", but got %q", rule.Description)
+	}
+
+	// The description of a SearchRuleDescription is explicitly tagged.
+	tagged, err := contextTagged(29, encodedUniversal)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	zero, err := asn1.Marshal(0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	dmdID, err := contextTagged(0, id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	seq, err = sequenceOf(zero, dmdID.FullBytes, tagged.FullBytes)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var searchRule SearchRuleDescription
+	if _, err := UnmarshalSchemaDescription(seq.FullBytes, &searchRule); err != nil {
+		t.Error(err)
+		return
+	}
+	if searchRule.Description != "hi" {
+		t.Errorf("expected \"hi\", but got %q", searchRule.Description)
+	}
+}
@@ -1,6 +1,7 @@
 package x500
 
 import (
+	"bytes"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
@@ -8,6 +9,7 @@ import (
 	"fmt"
 	"math/big"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -42,6 +44,13 @@ var (
 	certType             = reflect.TypeFor[x509.Certificate]()
 	crlType              = reflect.TypeFor[x509.RevocationList]()
 	csrType              = reflect.TypeFor[x509.CertificateRequest]()
+	rawValueType         = reflect.TypeFor[asn1.RawValue]()
+	guideType            = reflect.TypeFor[Guide]()
+	enhancedGuideType    = reflect.TypeFor[EnhancedGuide]()
+	subtreeSpecType      = reflect.TypeFor[SubtreeSpecification]()
+	hostPortType         = reflect.TypeFor[HostPort]()
+	cidrType             = reflect.TypeFor[CIDR]()
+	transportAddressType = reflect.TypeFor[TransportAddress]()
 )
 
 type fieldParameters struct {
@@ -52,26 +61,30 @@ type fieldParameters struct {
 	uselang   bool   // If true, take the language context value from the lang tag passed in. Only used when marshalling.
 	lang      string // Only used when marshalling.
 	omitempty bool   // If 0 or false, do not produce an attribute value
+
+	usecontexts bool      // If true, add the contexts passed in. Only used when marshalling.
+	contexts    []Context // Only used when marshalling.
 }
 
 func addLanguageContext(value asn1.RawValue, lang string) Attribute_valuesWithContext_Item {
 	return Attribute_valuesWithContext_Item{
-		Value: value,
-		ContextList: []Context{
-			{
-				ContextType: Id_avc_language,
-				ContextValues: []asn1.RawValue{
-					{
-						Class: asn1.ClassUniversal,
-						Tag:   asn1.TagPrintableString,
-						Bytes: []byte(lang),
-					},
-				},
-			},
-		},
+		Value:       value,
+		ContextList: []Context{LanguageContext(lang)},
 	}
 }
 
+// Returns the contexts that values marshalled with `params` should have.
+func (params *fieldParameters) valueContexts() []Context {
+	var contexts []Context
+	if params.uselang && len(params.lang) == 2 {
+		contexts = append(contexts, LanguageContext(params.lang))
+	}
+	if params.usecontexts {
+		contexts = append(contexts, params.contexts...)
+	}
+	return contexts
+}
+
 func stringToOID(str string) (ret asn1.ObjectIdentifier, err error) {
 	var part string
 	for len(str) > 0 {
@@ -105,6 +118,8 @@ func parseFieldParameters(str string) (ret fieldParameters, err error) {
 			ret.must = true
 		case part == "time":
 			ret.tag = tagTime
+		case part == "utc":
+			ret.tag = asn1.TagUTCTime
 		case part == "printable":
 			ret.tag = asn1.TagPrintableString
 		case part == "ia5":
@@ -143,6 +158,8 @@ func parseFieldParameters(str string) (ret fieldParameters, err error) {
 			ret.tag = tagRelativeOidIri
 		case part == "uselang":
 			ret.uselang = true
+		case part == "usecontexts":
+			ret.usecontexts = true
 		case part == "omitempty":
 			ret.omitempty = true
 		case part == "list":
@@ -152,6 +169,102 @@ func parseFieldParameters(str string) (ret fieldParameters, err error) {
 	return
 }
 
+// Implemented by types that encoding/asn1 cannot marshal, such as those with
+// REAL members, so that Marshal can. The value returned must have FullBytes.
+type RawValueMarshaler interface {
+	MarshalRawValue() (asn1.RawValue, error)
+}
+
+// Implemented by pointers to types that encoding/asn1 cannot unmarshal, such
+// as those with REAL members, so that Unmarshal can.
+type RawValueUnmarshaler interface {
+	UnmarshalRawValue(value asn1.RawValue) error
+}
+
+var (
+	rawValueMarshalerType   = reflect.TypeFor[RawValueMarshaler]()
+	rawValueUnmarshalerType = reflect.TypeFor[RawValueUnmarshaler]()
+)
+
+// Returns a SEQUENCE of the encoded `components`.
+func sequenceOf(components ...[]byte) (asn1.RawValue, error) {
+	return marshalRawValue(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSequence,
+		IsCompound: true,
+		Bytes:      bytes.Join(components, nil),
+	}, "")
+}
+
+// Returns a SET OF the encoded `components`, which are sorted as the
+// Distinguished Encoding Rules require.
+func setOf(components ...[]byte) (asn1.RawValue, error) {
+	sorted := slices.Clone(components)
+	slices.SortFunc(sorted, bytes.Compare)
+	return marshalRawValue(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      bytes.Join(sorted, nil),
+	}, "")
+}
+
+// Returns the components of the SEQUENCE `value`.
+func sequenceComponents(value asn1.RawValue) (components []asn1.RawValue, err error) {
+	rest, err := asn1.Unmarshal(encodingOf(value), &components)
+	if err == nil && len(rest) > 0 {
+		err = errors.New("trailing data")
+	}
+	return components, err
+}
+
+// Returns the tag number of a struct member that is explicitly tagged
+// according to its asn1 struct tag.
+func explicitTagOf(field reflect.StructField) (tag int, explicit bool) {
+	tag = -1
+	str := field.Tag.Get("asn1")
+	var part string
+	for len(str) > 0 {
+		part, str, _ = strings.Cut(str, ",")
+		switch {
+		case part == "explicit":
+			explicit = true
+		case strings.HasPrefix(part, "tag:"):
+			i, err := strconv.Atoi(part[4:])
+			if err == nil {
+				tag = i
+			}
+		}
+	}
+	return tag, explicit && tag >= 0
+}
+
+// encoding/asn1 ignores the explicit tag of an asn1.RawValue member of a
+// struct, so such members are tagged here before the struct is marshalled.
+// Members of the struct hold the untagged value, as they do after
+// unmarshalling with unmarshalWithExplicitRawValues.
+func marshalWithExplicitRawValues(v reflect.Value, params string) ([]byte, error) {
+	t := v.Type()
+	tagged := reflect.New(t).Elem()
+	tagged.Set(v)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type != rawValueType || tagged.Field(i).IsZero() {
+			continue
+		}
+		tag, explicit := explicitTagOf(field)
+		if !explicit {
+			continue
+		}
+		value, err := contextTagged(tag, encodingOf(tagged.Field(i).Interface().(asn1.RawValue)))
+		if err != nil {
+			return nil, err
+		}
+		tagged.Field(i).Set(reflect.ValueOf(value))
+	}
+	return asn1.MarshalWithParams(tagged.Interface(), params)
+}
+
 func marshalValue(v reflect.Value, params fieldParameters) (ret asn1.RawValue, err error) {
 	var bytes []byte
 	tag := 0
@@ -169,8 +282,21 @@ func marshalValue(v reflect.Value, params fieldParameters) (ret asn1.RawValue, e
 	k := t.Kind()
 	if k == reflect.Pointer {
 		if v.IsNil() {
+			if params.must {
+				return ret, errors.New("x500: cannot marshal nil pointer")
+			}
+			return asn1.RawValue{}, nil
+		}
+		if t != bigIntType {
+			return marshalValue(v.Elem(), params)
+		}
+	}
+
+	if t.Implements(rawValueMarshalerType) {
+		if v.IsZero() && (params.omitempty || k == reflect.Slice) {
 			return asn1.RawValue{}, nil
 		}
+		return v.Interface().(RawValueMarshaler).MarshalRawValue()
 	}
 
 	// This switch statement deals with specially-handled types.
@@ -217,7 +343,11 @@ func marshalValue(v reflect.Value, params fieldParameters) (ret asn1.RawValue, e
 			ret.FullBytes, _ = asn1.Marshal(ret)
 			return ret, nil
 		}
-    // TODO: Do you need to handle utc here?
+		if params.tag == asn1.TagUTCTime {
+			bytes, err = asn1.MarshalWithParams(timeValue, "utc")
+			_, _ = asn1.Unmarshal(bytes, &ret)
+			return ret, err
+		}
 		bytes, err = asn1.MarshalWithParams(timeValue, "generalized")
 		_, _ = asn1.Unmarshal(bytes, &ret)
 		return ret, err
@@ -295,6 +425,57 @@ func marshalValue(v reflect.Value, params fieldParameters) (ret asn1.RawValue, e
 		if len(naouid.Dn) == 0 && params.omitempty {
 			return asn1.RawValue{}, err
 		}
+	case rawValueType:
+		rawValue := v.Interface().(asn1.RawValue)
+		if v.IsZero() {
+			return asn1.RawValue{}, nil
+		}
+		if len(rawValue.FullBytes) == 0 {
+			rawValue.FullBytes, err = asn1.Marshal(rawValue)
+		}
+		return rawValue, err
+	case guideType:
+		// Guide is a SET, not a SEQUENCE.
+		bytes, err = marshalWithExplicitRawValues(v, "set")
+		_, _ = asn1.Unmarshal(bytes, &ret)
+		return ret, err
+	case enhancedGuideType, subtreeSpecType:
+		bytes, err = marshalWithExplicitRawValues(v, "")
+		_, _ = asn1.Unmarshal(bytes, &ret)
+		return ret, err
+	case hostPortType:
+		hp := v.Interface().(HostPort)
+		if v.IsZero() && params.omitempty {
+			return asn1.RawValue{}, nil
+		}
+		if isStringTag(params.tag) {
+			return marshalValue(reflect.ValueOf(hp.String()), params)
+		}
+		bytes, err = asn1.Marshal(hp)
+		_, _ = asn1.Unmarshal(bytes, &ret)
+		return ret, err
+	case cidrType:
+		prefix := v.Interface().(CIDR)
+		if !prefix.IsValid() {
+			return asn1.RawValue{}, nil
+		}
+		if isStringTag(params.tag) {
+			return marshalValue(reflect.ValueOf(prefix.String()), params)
+		}
+		bytes, err = asn1.Marshal(encodeCIDR(prefix))
+		_, _ = asn1.Unmarshal(bytes, &ret)
+		return ret, err
+	case transportAddressType:
+		addrPort := v.Interface().(TransportAddress)
+		if !addrPort.IsValid() {
+			return asn1.RawValue{}, nil
+		}
+		if isStringTag(params.tag) {
+			return marshalValue(reflect.ValueOf(addrPort.String()), params)
+		}
+		bytes, err = asn1.Marshal(encodeTransportAddress(addrPort))
+		_, _ = asn1.Unmarshal(bytes, &ret)
+		return ret, err
 	}
 
 	switch v.Kind() {
@@ -305,6 +486,22 @@ func marshalValue(v reflect.Value, params fieldParameters) (ret asn1.RawValue, e
 			return asn1.RawValue{}, nil
 		}
 		bytes, err = asn1.Marshal(v.Interface())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.IsZero() && params.omitempty {
+			return asn1.RawValue{}, nil
+		}
+		// encoding/asn1 does not marshal unsigned integers.
+		bytes, err = asn1.Marshal(new(big.Int).SetUint64(v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		if v.IsZero() && params.omitempty {
+			return asn1.RawValue{}, nil
+		}
+		return encodeReal(v.Float()), nil
+	case reflect.Complex64, reflect.Complex128:
+		if v.IsZero() && params.omitempty {
+			return asn1.RawValue{}, nil
+		}
+		return encodeComplexNumber(v.Complex())
 	case reflect.String:
 		s := v.String()
 		if len(s) == 0 {
@@ -435,8 +632,18 @@ func marshalField(v reflect.Value, params fieldParameters) (attr Attribute, err
 		return marshalField(v.Elem(), params)
 	}
 	t := v.Type()
+	// If the field is a pointer, marshal what it points to.
+	if k == reflect.Pointer && t != bigIntType {
+		if v.IsNil() {
+			if params.must {
+				return attr, fmt.Errorf("x500: required attribute %s is nil", params.oid)
+			}
+			return attr, nil
+		}
+		return marshalField(v.Elem(), params)
+	}
 	attr.Type = params.oid
-	if k == reflect.Slice && !params.list && t.Elem().Kind() != reflect.Uint8 && t != objectIdentifierType && t != dnType && t != rdnType {
+	if k == reflect.Slice && !params.list && t.Elem().Kind() != reflect.Uint8 && t != objectIdentifierType && t != dnType && t != rdnType && !t.Implements(rawValueMarshalerType) {
 		l := v.Len()
 		values := make([]asn1.RawValue, 0, l)
 		for i := 0; i < l; i++ {
@@ -452,10 +659,10 @@ func marshalField(v reflect.Value, params fieldParameters) (attr Attribute, err
 			values = append(values, innerv)
 		}
 		attr.Values = values
-		if params.uselang && len(params.lang) == 2 {
+		if contexts := params.valueContexts(); len(contexts) > 0 {
 			attr.ValuesWithContext = make([]Attribute_valuesWithContext_Item, len(attr.Values))
 			for i, plainValue := range attr.Values {
-				attr.ValuesWithContext[i] = addLanguageContext(plainValue, params.lang)
+				attr.ValuesWithContext[i] = Attribute_valuesWithContext_Item{Value: plainValue, ContextList: contexts}
 			}
 			attr.Values = make([]asn1.RawValue, 0)
 		}
@@ -469,16 +676,19 @@ func marshalField(v reflect.Value, params fieldParameters) (attr Attribute, err
 		// Returning an empty attribute means "nothing to encode"
 		return attr, nil
 	}
-	if params.uselang && len(params.lang) == 2 {
-		attr.ValuesWithContext = []Attribute_valuesWithContext_Item{addLanguageContext(value, params.lang)}
+	if contexts := params.valueContexts(); len(contexts) > 0 {
+		attr.ValuesWithContext = []Attribute_valuesWithContext_Item{{Value: value, ContextList: contexts}}
 	} else {
 		attr.Values = []asn1.RawValue{value}
 	}
 	return attr, nil
 }
 
-// See the documentation for Marshal.
-func MarshalWithParams(val any, lang string) (attrs []Attribute, err error) {
+// See the documentation for Marshal. The values of struct members tagged with
+// "uselang" are given a languageContext of `lang`, and those of struct members
+// tagged with "usecontexts" are given `contexts`, such as those returned by
+// [TemporalContext] and [LocaleContext].
+func MarshalWithParams(val any, lang string, contexts ...Context) (attrs []Attribute, err error) {
 	v := reflect.ValueOf(val)
 	if v.Kind() != reflect.Struct {
 		return attrs, errors.New("cannot marshal non-struct to directory attributes")
@@ -506,6 +716,7 @@ func MarshalWithParams(val any, lang string) (attrs []Attribute, err error) {
 		if fieldParams.lang == "" {
 			fieldParams.lang = lang
 		}
+		fieldParams.contexts = contexts
 		if err != nil {
 			return nil, err
 		}
@@ -533,6 +744,7 @@ func MarshalWithParams(val any, lang string) (attrs []Attribute, err error) {
 //  oid:1.2.3.4: The OID to use for the attribute. Obviously, replace 1.2.3.4 with the right one.
 //  must:        The value MUST be present when unmarshaling. When marshaling, pointers must not be nil.
 //  time:        Encode as an ASN.1 TIME string. (Defined in newer versions of ASN.1. Universal Tag 14.)
+//  utc:         Encode a time.Time as a UTCTime instead of a GeneralizedTime
 //  printable:   Encode as a PrintableString
 //  ia5:         Encode as an IA5String
 //  num:         Encode as a NumericString
@@ -555,6 +767,9 @@ func MarshalWithParams(val any, lang string) (attrs []Attribute, err error) {
 //               params in MarshalWithParams, use its value to create values with
 //               contexts using the languageContext defined in ITU-T Recommendation
 //               X.520 (2019).
+//  usecontexts: Give values the contexts supplied via MarshalWithParams, such
+//               as temporal and locale contexts, in addition to any language
+//               context added by "uselang".
 //  omitempty:   Struct members tagged with this will not produce an attribute
 //               if they are zero-valued.
 //  list:        Slice-typed struct members tagged with this will not use the
@@ -569,50 +784,81 @@ func MarshalWithParams(val any, lang string) (attrs []Attribute, err error) {
 //
 //  string:                   By default, whatever Golang's asn1.Marshal() does. Use tags.
 //  integer types:            INTEGER
+//  unsigned integer types:   INTEGER
 //  bool:                     BOOLEAN
 //  []byte:                   OCTET STRING
 //  other slice types:        Multiple values in the attribute, unless the "list" tag is used.
 //  asn1.Enumerated:          ENUMERATED
 //  time.Duration:            DURATION
 //  asn1.BitString:           BIT STRING
-//  time.Time:                GeneralizedTime; or DATE or TIME-OF-DAY depending on tags
+//  time.Time:                GeneralizedTime; or UTCTime, DATE or TIME-OF-DAY depending on tags
 //  asn1.ObjectIdentifier:    OBJECT IDENTIFIER
 //  *big.Int:                 INTEGER
 //  x509.Certificate:         Certificate
 //  x509.RevocationList:      CertificateList
 //  x509.CertificateRequest:  PKCS#10 CertificationRequest
 //  DistinguishedName:        pkix.RDNSequence
-//  
+//  asn1.RawValue:            The value itself
+//  float types:              REAL
+//  complex types:            ComplexNumber
+//  RawValueMarshaler:        Whatever its MarshalRawValue method returns
+//  Guide:                    Guide, which is a SET
+//  pointer types:            Whatever is pointed to. Nil pointers produce no attribute.
+//  TextualKeyValue:          TextualKeyValue
+//  HostPort:                 HostPort; or "host:port" if tagged with a string type
+//  CIDR:                     Address and mask; or "address/bits" if tagged with a string type
+//  TransportAddress:         Address and port; or "address:port" if tagged with a string type
+//
 // All other types are serialized as expected. As with Golang's asn1 module, if
 // the structs first member is of type asn1.RawContent, that will be used to
 // marshal the value.
+//
+// Golang's asn1 module ignores the explicit tags of asn1.RawValue struct
+// members. The members of Guide, EnhancedGuide and SubtreeSpecification that
+// are of type asn1.RawValue, such as Criteria and Refinement, are tagged when
+// marshalled and untagged when unmarshalled, so they should hold the untagged
+// value. Other structs, such as FacsimileTelephoneNumber, NameAndOptionalUID,
+// pkix.AlgorithmIdentifier and pkix.Extension, are serialized as expected.
+// Slices of asn1.RawValue, such as SubstringAssertion, should use the "list"
+// tag to produce a single value.
 func Marshal(val any) (attrs []Attribute, err error) {
   return MarshalWithParams(val, "")
 }
 
-// Might already be done.
-// TODO: Encoding for pkix.Extension
-// TODO: Encoding for pkix.AlgorithmIdentifier
-// TODO: Encoding for NameAndOptionalUID
-// TODO: Fax
+// Return the attribute types named by the "oid:" parameters of the x500 tags
+// on the members of a struct, in the order in which they appear. This is
+// useful for building an EntryInformationSelection that selects exactly the
+// attributes that Unmarshal would populate. `val` may be a struct or a
+// pointer to one. Members without an x500 tag are ignored.
+func AttributeTypesOf(val any) (types []AttributeType, err error) {
+	t := reflect.TypeOf(val)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("cannot get attribute types of non-struct")
+	}
+	n := t.NumField()
+	types = make([]AttributeType, 0, n)
+	for i := 0; i < n; i++ {
+		tag := t.Field(i).Tag.Get("x500")
+		if len(tag) == 0 {
+			continue
+		}
+		p, err := parseFieldParameters(tag)
+		if err != nil {
+			return nil, err
+		}
+		if len(p.oid) == 0 {
+			return nil, fmt.Errorf("struct member %s has no oid in its x500 tag", t.Field(i).Name)
+		}
+		types = append(types, p.oid)
+	}
+	return types, nil
+}
 
-// TODO: Encoding for uint types
+// The types below are not yet defined by this package.
 // TODO: Schema elements
-// TODO: Guide
-// TODO: Substring Assertion
-// TODO: EnhancedGuide
-// TODO: SubtreeSpecification
-// TODO: WGS84Coordinates
-// TODO: WGS84Position
-// TODO: WGS84Line
-// TODO: WGS84Polygon
-// TODO: ComplexNumber
-// TODO: Currency
-// TODO: TextualKeyValue
-// TODO: CIDR
-// TODO: HostPort
-// TODO: TransportAddress
 // TODO: NameAndString
 // TODO: Crypto
-// TODO: Does this work with just plain asn1.RawValue?
 
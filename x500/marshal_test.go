@@ -730,6 +730,49 @@ func TestLanguageContext(t *testing.T) {
 	}
 }
 
+func TestMarshalContexts(t *testing.T) {
+	type Person struct {
+		CommonName      []string `x500:"oid:2.5.4.3"`
+		TelephoneNumber []string `x500:"oid:2.5.4.20,printable,uselang,usecontexts"`
+	}
+	p := Person{
+		CommonName:      []string{"Jonathan"},
+		TelephoneNumber: []string{"+1 555 555 5555"},
+	}
+	spec, err := ParseTimeSpecification("mon-fri 09:00-17:00")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	temporal, err := TemporalContext(spec)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	attrs, err := MarshalWithParams(p, "en", temporal, LocaleContext("en_US"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(attrs) != 2 || len(attrs[0].ValuesWithContext) != 0 || len(attrs[1].ValuesWithContext) != 1 {
+		t.Error("contexts added to the wrong values")
+		return
+	}
+	contexts := attrs[1].ValuesWithContext[0].ContextList
+	if len(contexts) != 3 {
+		t.Errorf("expected 3 contexts, but got %d", len(contexts))
+		return
+	}
+	for i, contextType := range []asn1.ObjectIdentifier{Id_avc_language, Id_avc_temporal, Id_avc_locale} {
+		if !contexts[i].ContextType.Equal(contextType) {
+			t.Errorf("expected context %d to be %s, but got %s", i, contextType, contexts[i].ContextType)
+		}
+	}
+	if _, err := asn1.Marshal(attrs[1]); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestListValues(t *testing.T) {
 	type PostalThing struct {
 		PostalAddress []string `x500:"oid:2.5.4.43,list"`
@@ -866,3 +909,189 @@ func TestMarshalStrings(t *testing.T) {
 }
 
 // TODO: Pointer-typed fields
+
+func TestAttributeTypesOf(t *testing.T) {
+	type Person struct {
+		CommonName []string `x500:"oid:2.5.4.3"`
+		Surname    string   `x500:"oid:2.5.4.4,must"`
+		Ignored    int
+	}
+	types, err := AttributeTypesOf(&Person{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(types) != 2 {
+		t.Errorf("got %d attribute types", len(types))
+		return
+	}
+	if !types[0].Equal(Id_at_commonName) || !types[1].Equal(Id_at_surname) {
+		t.Errorf("wrong attribute types: %v", types)
+		return
+	}
+	_, err = AttributeTypesOf(5)
+	if err == nil {
+		t.Error("non-struct did not return an error")
+		return
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	type Everything struct {
+		Extension     pkix.Extension           `x500:"oid:1.2.3.1"`
+		Algorithm     pkix.AlgorithmIdentifier `x500:"oid:1.2.3.2"`
+		Name          NameAndOptionalUID       `x500:"oid:1.2.3.3"`
+		Small         uint8                    `x500:"oid:1.2.3.4"`
+		Large         uint64                   `x500:"oid:1.2.3.5"`
+		Guide         Guide                    `x500:"oid:1.2.3.6"`
+		EnhancedGuide EnhancedGuide            `x500:"oid:1.2.3.7"`
+		Substrings    SubstringAssertion       `x500:"oid:1.2.3.8,list"`
+		Subtree       SubtreeSpecification     `x500:"oid:1.2.3.9"`
+		Fax           FacsimileTelephoneNumber `x500:"oid:1.2.3.10"`
+		Raw           asn1.RawValue            `x500:"oid:1.2.3.11"`
+		UTC           time.Time                `x500:"oid:1.2.3.12,utc"`
+	}
+	criteria, err := contextTagged(1, mustMarshalValue(t, asn1.RawValue{
+		Class: asn1.ClassContextSpecific,
+		Tag:   0,
+		Bytes: []byte{0x55, 0x04, 0x03},
+	}, "").FullBytes)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	refinement := mustMarshalValue(t, Id_oc_person, "tag:0")
+	in := Everything{
+		Extension: pkix.Extension{Id: asn1.ObjectIdentifier{2, 5, 29, 19}, Critical: true, Value: []byte{0x30, 0x00}},
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 101, 112}},
+		Name: NameAndOptionalUID{
+			Dn:  getDN(),
+			Uid: asn1.BitString{Bytes: []byte{0x80}, BitLength: 1},
+		},
+		Small:         255,
+		Large:         1 << 63,
+		Guide:         Guide{ObjectClass: Id_oc_person, Criteria: criteria},
+		EnhancedGuide: EnhancedGuide{ObjectClass: Id_oc_person, Criteria: criteria, Subset: EnhancedGuide_subset_WholeSubtree},
+		Substrings: SubstringAssertion{
+			{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: []byte("Jo")},
+			{Class: asn1.ClassContextSpecific, Tag: 2, Bytes: []byte("th")},
+		},
+		Subtree: SubtreeSpecification{Minimum: 1, Maximum: 3, SpecificationFilter: refinement},
+		Fax: FacsimileTelephoneNumber{
+			TelephoneNumber: "+1 555 555 5555",
+			Parameters:      asn1.BitString{Bytes: []byte{0x40}, BitLength: 2},
+		},
+		Raw: NewDirectoryString("raw"),
+		UTC: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	attrs, err := Marshal(in)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(attrs) != 12 {
+		t.Errorf("marshalling produced %d attributes instead of 12", len(attrs))
+		return
+	}
+	if attrs[5].Values[0].Tag != asn1.TagSet {
+		t.Error("a guide should be encoded as a SET")
+		return
+	}
+	if attrs[11].Values[0].Tag != asn1.TagUTCTime {
+		t.Error("the utc tag should produce a UTCTime")
+		return
+	}
+	var out Everything
+	err = Unmarshal(attrs, &out)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(in.Extension, out.Extension) {
+		t.Errorf("extension was %+v", out.Extension)
+		return
+	}
+	if !out.Algorithm.Algorithm.Equal(in.Algorithm.Algorithm) {
+		t.Errorf("algorithm was %+v", out.Algorithm)
+		return
+	}
+	if !DNEqual(out.Name.Dn, in.Name.Dn) || out.Name.Uid.BitLength != 1 {
+		t.Errorf("name was %s", out.Name.String())
+		return
+	}
+	if out.Small != in.Small || out.Large != in.Large {
+		t.Errorf("unsigned integers were %d and %d", out.Small, out.Large)
+		return
+	}
+	if !out.Guide.ObjectClass.Equal(Id_oc_person) || !bytes.Equal(encodingOf(out.Guide.Criteria), encodingOf(criteria)) {
+		t.Errorf("guide was %+v", out.Guide)
+		return
+	}
+	if out.EnhancedGuide.Subset != in.EnhancedGuide.Subset || !bytes.Equal(encodingOf(out.EnhancedGuide.Criteria), encodingOf(criteria)) {
+		t.Errorf("enhanced guide was %+v", out.EnhancedGuide)
+		return
+	}
+	if len(out.Substrings) != 2 || string(out.Substrings[1].Bytes) != "th" {
+		t.Errorf("substrings were %+v", out.Substrings)
+		return
+	}
+	if out.Subtree.Minimum != 1 || out.Subtree.Maximum != 3 || !bytes.Equal(encodingOf(out.Subtree.SpecificationFilter), encodingOf(refinement)) {
+		t.Errorf("subtree specification was %+v", out.Subtree)
+		return
+	}
+	if out.Fax.TelephoneNumber != in.Fax.TelephoneNumber || out.Fax.Parameters.At(1) != 1 {
+		t.Errorf("facsimile telephone number was %+v", out.Fax)
+		return
+	}
+	if !bytes.Equal(out.Raw.FullBytes, encodingOf(in.Raw)) {
+		t.Errorf("raw value was %x", out.Raw.FullBytes)
+		return
+	}
+	if !out.UTC.Equal(in.UTC) {
+		t.Errorf("time was %s", out.UTC)
+		return
+	}
+}
+
+func TestMarshalGuideSyntax(t *testing.T) {
+	guide, err := ParseAttributeValue(nil, Id_at_searchGuide, "person#sn$EQ|cn$SUBSTR")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	type Guided struct {
+		SearchGuide Guide `x500:"oid:2.5.4.14"`
+	}
+	var g Guided
+	err = Unmarshal([]Attribute{{Type: Id_at_searchGuide, Values: []asn1.RawValue{guide}}}, &g)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if g.SearchGuide.Criteria.Class != asn1.ClassContextSpecific || g.SearchGuide.Criteria.Tag != 2 {
+		t.Error("the explicit tag of the criteria should have been removed")
+		return
+	}
+	attrs, err := Marshal(g)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(attrs[0].Values[0].FullBytes, guide.FullBytes) {
+		t.Errorf("guide was re-encoded as %x", attrs[0].Values[0].FullBytes)
+		return
+	}
+}
+
+func TestMarshalUnsignedOutOfRange(t *testing.T) {
+	type Small struct {
+		Value uint8 `x500:"oid:1.2.3.4"`
+	}
+	for _, v := range []int{256, -1} {
+		attrs := []Attribute{{Type: asn1.ObjectIdentifier{1, 2, 3, 4}, Values: []asn1.RawValue{mustMarshalValue(t, v, "")}}}
+		var s Small
+		if err := Unmarshal(attrs, &s); err == nil {
+			t.Errorf("%d should not have been unmarshalled into a uint8", v)
+		}
+	}
+}
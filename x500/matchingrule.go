@@ -0,0 +1,368 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// An implementation of a matching rule. Each function is optional, and is set
+// according to the kinds of matching the rule supports.
+type MatchingRuleImpl struct {
+	Identifier asn1.ObjectIdentifier
+
+	// Returns true if `value` matches `assertion`. This is used for equality
+	// and extensible matching.
+	Match func(assertion, value asn1.RawValue) (bool, error)
+
+	// Returns true if `value` approximately matches `assertion`. This is
+	// used for approximate matching; if it is not set, Match is used
+	// instead.
+	ApproximateMatch func(assertion, value asn1.RawValue) (bool, error)
+
+	// Returns a negative number if `value` is less than `assertion`, zero if
+	// they are equal, and a positive number if `value` is greater. This is
+	// used for ordering matching.
+	Compare func(value, assertion asn1.RawValue) (int, error)
+
+	// Returns true if `value` contains `substrings`, in order. Elements of
+	// kind [SubstringControl] may be ignored. This is used for substrings
+	// matching.
+	MatchSubstrings func(substrings []FilterSubstring, value asn1.RawValue) (bool, error)
+
+	// Returns a string that is the same for every value that matches `value`
+	// under this equality matching rule. This is only set for rules where
+	// such a form exists, and is used to compare distinguished names.
+	Normalize func(value asn1.RawValue) (string, error)
+}
+
+// Implementations of matching rules, indexed by object identifier.
+type MatchingRuleRegistry struct {
+	rules map[string]*MatchingRuleImpl
+}
+
+func NewMatchingRuleRegistry() *MatchingRuleRegistry {
+	return &MatchingRuleRegistry{rules: make(map[string]*MatchingRuleImpl)}
+}
+
+// Add or replace the implementation of `rule.Identifier`.
+func (r *MatchingRuleRegistry) Add(rule *MatchingRuleImpl) {
+	r.rules[rule.Identifier.String()] = rule
+}
+
+// Returns the implementation of the matching rule identified by `oid`, or nil
+// if there is none.
+func (r *MatchingRuleRegistry) Get(oid asn1.ObjectIdentifier) *MatchingRuleImpl {
+	return r.rules[oid.String()]
+}
+
+// Returns a [ValueEqualFunc] for [DiffOptions] that compares values using
+// the equality matching rules of their attribute types in `schema`, or
+// [DefaultSchemaRegistry] if it is nil. Values of attribute types that have
+// no equality matching rule in `r`, and values that are not valid for their
+// rule, are compared by their encodings.
+func (r *MatchingRuleRegistry) ValueEqual(schema *SchemaRegistry) ValueEqualFunc {
+	if schema == nil {
+		schema = DefaultSchemaRegistry()
+	}
+	return func(attrType AttributeType, a, b asn1.RawValue) bool {
+		at, err := schema.ResolveAttributeType(attrType.String())
+		if err != nil || at.EqualityMatch == nil {
+			return ValuesEncodedEqual(attrType, a, b)
+		}
+		rule := r.Get(at.EqualityMatch)
+		if rule == nil || rule.Match == nil {
+			return ValuesEncodedEqual(attrType, a, b)
+		}
+		match, err := rule.Match(a, b)
+		if err != nil {
+			return ValuesEncodedEqual(attrType, a, b)
+		}
+		return match
+	}
+}
+
+// An equality matching rule under which values match if they have the same
+// normalized form.
+func normalizingEqualityRule(oid asn1.ObjectIdentifier, normalize func(asn1.RawValue) (string, error)) *MatchingRuleImpl {
+	return &MatchingRuleImpl{
+		Identifier: oid,
+		Match: func(assertion, value asn1.RawValue) (bool, error) {
+			a, err := normalize(assertion)
+			if err != nil {
+				return false, err
+			}
+			v, err := normalize(value)
+			if err != nil {
+				return false, err
+			}
+			return a == v, nil
+		},
+		Normalize: normalize,
+	}
+}
+
+// An ordering matching rule that compares normalized forms lexically.
+func normalizingOrderingRule(oid asn1.ObjectIdentifier, normalize func(asn1.RawValue) (string, error)) *MatchingRuleImpl {
+	return &MatchingRuleImpl{
+		Identifier: oid,
+		Compare: func(value, assertion asn1.RawValue) (int, error) {
+			v, err := normalize(value)
+			if err != nil {
+				return 0, err
+			}
+			a, err := normalize(assertion)
+			if err != nil {
+				return 0, err
+			}
+			return strings.Compare(v, a), nil
+		},
+	}
+}
+
+// Returns true if `s` contains `substrings`, in order, with the initial
+// substring at the start and the final substring at the end.
+func containsSubstrings(s string, substrings []FilterSubstring, normalize func(asn1.RawValue) (string, error)) (bool, error) {
+	pos := 0
+	for _, sub := range substrings {
+		if sub.Kind == SubstringControl {
+			continue
+		}
+		part, err := normalize(sub.Value)
+		if err != nil {
+			return false, err
+		}
+		switch sub.Kind {
+		case SubstringInitial:
+			if !strings.HasPrefix(s[pos:], part) {
+				return false, nil
+			}
+			pos += len(part)
+		case SubstringAny:
+			i := strings.Index(s[pos:], part)
+			if i < 0 {
+				return false, nil
+			}
+			pos += i + len(part)
+		case SubstringFinal:
+			if len(s)-pos < len(part) || !strings.HasSuffix(s, part) {
+				return false, nil
+			}
+			pos = len(s)
+		}
+	}
+	return true, nil
+}
+
+// A substrings matching rule that searches the normalized form of a value
+// for the normalized forms of the substrings. Substrings may be normalized
+// differently from values, since spaces at their ends can be significant.
+func normalizingSubstringsRule(oid asn1.ObjectIdentifier, normalizeValue, normalizeSubstring func(asn1.RawValue) (string, error)) *MatchingRuleImpl {
+	return &MatchingRuleImpl{
+		Identifier: oid,
+		MatchSubstrings: func(substrings []FilterSubstring, value asn1.RawValue) (bool, error) {
+			v, err := normalizeValue(value)
+			if err != nil {
+				return false, err
+			}
+			return containsSubstrings(v, substrings, normalizeSubstring)
+		},
+	}
+}
+
+// Returns the first component of a SEQUENCE value.
+func firstComponent(value asn1.RawValue) (asn1.RawValue, error) {
+	var seq asn1.RawValue
+	if err := unmarshalExactly(value, &seq); err != nil {
+		return asn1.RawValue{}, err
+	}
+	if seq.Class != asn1.ClassUniversal || seq.Tag != asn1.TagSequence || !seq.IsCompound {
+		return asn1.RawValue{}, errors.New("not a SEQUENCE")
+	}
+	var first asn1.RawValue
+	if _, err := asn1.Unmarshal(seq.Bytes, &first); err != nil {
+		return asn1.RawValue{}, err
+	}
+	return first, nil
+}
+
+// A matching rule under which a SEQUENCE value matches an assertion if its
+// first component has the same normalized form, as for the FirstComponent
+// matching rules of ITU-T Recommendation X.520, Section 8.
+func firstComponentRule(oid asn1.ObjectIdentifier, normalize func(asn1.RawValue) (string, error)) *MatchingRuleImpl {
+	return &MatchingRuleImpl{
+		Identifier: oid,
+		Match: func(assertion, value asn1.RawValue) (bool, error) {
+			first, err := firstComponent(value)
+			if err != nil {
+				return false, err
+			}
+			v, err := normalize(first)
+			if err != nil {
+				return false, err
+			}
+			a, err := normalize(assertion)
+			if err != nil {
+				return false, err
+			}
+			return a == v, nil
+		},
+	}
+}
+
+// A matching rule under which a value matches if any of its words match the
+// asserted word, ignoring case. Words are separated by spaces and
+// punctuation, which is how this package implements wordMatch and
+// keywordMatch.
+func wordRule(oid asn1.ObjectIdentifier) *MatchingRuleImpl {
+	return &MatchingRuleImpl{
+		Identifier: oid,
+		Match: func(assertion, value asn1.RawValue) (bool, error) {
+			a, err := normalizeCaseIgnore(nil, assertion)
+			if err != nil {
+				return false, err
+			}
+			v, err := normalizeCaseIgnore(nil, value)
+			if err != nil {
+				return false, err
+			}
+			words := strings.FieldsFunc(v, func(r rune) bool {
+				return unicode.IsSpace(r) || unicode.IsPunct(r)
+			})
+			return slices.Contains(words, a), nil
+		},
+	}
+}
+
+func compareTimes(value, assertion asn1.RawValue) (int, error) {
+	var v, a time.Time
+	if err := unmarshalExactly(value, &v); err != nil {
+		return 0, err
+	}
+	if err := unmarshalExactly(assertion, &a); err != nil {
+		return 0, err
+	}
+	return v.Compare(a), nil
+}
+
+func compareIntegers(value, assertion asn1.RawValue) (int, error) {
+	v, a := new(big.Int), new(big.Int)
+	if err := unmarshalExactly(value, &v); err != nil {
+		return 0, err
+	}
+	if err := unmarshalExactly(assertion, &a); err != nil {
+		return 0, err
+	}
+	return v.Cmp(a), nil
+}
+
+// Create a new MatchingRuleRegistry containing implementations of the
+// matching rules of ITU-T Recommendation X.520 that this package supports.
+// String matching rules prepare strings as described in Section 7 of that
+// Recommendation; see [PrepareString]. Rules that compare distinguished names
+// use the equality matching rules of attribute types in `schema`, or
+// [DefaultSchemaRegistry] if it is nil.
+func NewDefaultMatchingRuleRegistry(schema *SchemaRegistry) *MatchingRuleRegistry {
+	if schema == nil {
+		schema = DefaultSchemaRegistry()
+	}
+	r := NewMatchingRuleRegistry()
+	withSchema := func(normalize dnValueNormalizer) func(asn1.RawValue) (string, error) {
+		return func(value asn1.RawValue) (string, error) {
+			return normalize(schema, value)
+		}
+	}
+	for _, oid := range []asn1.ObjectIdentifier{
+		Id_mr_caseIgnoreMatch,
+		Id_mr_caseExactMatch,
+		Id_lmr_caseIgnoreIA5Match,
+		Id_lmr_caseExactIA5Match,
+		Id_mr_caseIgnoreListMatch,
+		Id_mr_numericStringMatch,
+		Id_mr_telephoneNumberMatch,
+		Id_mr_facsimileNumberMatch,
+		Id_mr_objectIdentifierMatch,
+		Id_mr_distinguishedNameMatch,
+		Id_mr_uniqueMemberMatch,
+		Id_mr_dnsNameMatch,
+		Id_mr_intEmailMatch,
+		Id_mr_jidMatch,
+		Id_mr_uriMatch,
+		Id_mr_generalizedTimeMatch,
+		Id_mr_uTCTimeMatch,
+		Id_mr_uuidpairmatch,
+	} {
+		r.Add(normalizingEqualityRule(oid, withSchema(dnValueNormalizerFor(oid))))
+	}
+	for _, oid := range []asn1.ObjectIdentifier{Id_mr_booleanMatch, Id_mr_integerMatch, Id_mr_bitStringMatch} {
+		r.Add(normalizingEqualityRule(oid, withSchema(normalizeEncoding)))
+	}
+	r.Add(normalizingEqualityRule(Id_mr_octetStringMatch, withSchema(normalizeOctetString)))
+
+	r.Add(normalizingOrderingRule(Id_mr_caseIgnoreOrderingMatch, withSchema(normalizeCaseIgnore)))
+	r.Add(normalizingOrderingRule(Id_mr_caseExactOrderingMatch, withSchema(normalizeCaseExact)))
+	r.Add(normalizingOrderingRule(Id_mr_numericStringOrderingMatch, withSchema(normalizeNumericString)))
+	r.Add(normalizingOrderingRule(Id_mr_octetStringOrderingMatch, withSchema(normalizeOctetString)))
+	r.Add(&MatchingRuleImpl{Identifier: Id_mr_integerOrderingMatch, Compare: compareIntegers})
+	r.Add(&MatchingRuleImpl{Identifier: Id_mr_generalizedTimeOrderingMatch, Compare: compareTimes})
+	r.Add(&MatchingRuleImpl{Identifier: Id_mr_uTCTimeOrderingMatch, Compare: compareTimes})
+
+	caseIgnoreSubstring := func(value asn1.RawValue) (string, error) {
+		return prepareSubstring(value, true)
+	}
+	caseExactSubstring := func(value asn1.RawValue) (string, error) {
+		return prepareSubstring(value, false)
+	}
+	// The strings of a caseIgnoreList are searched as though they were one.
+	caseIgnoreListConcatenated := func(value asn1.RawValue) (string, error) {
+		s, err := normalizeCaseIgnoreList(schema, value)
+		return strings.ReplaceAll(s, "\n", ""), err
+	}
+	r.Add(normalizingSubstringsRule(Id_mr_caseIgnoreSubstringsMatch, withSchema(normalizeCaseIgnore), caseIgnoreSubstring))
+	r.Add(normalizingSubstringsRule(Id_mr_caseExactSubstringsMatch, withSchema(normalizeCaseExact), caseExactSubstring))
+	r.Add(normalizingSubstringsRule(Id_lmr_caseIgnoreIA5SubstringsMatch, withSchema(normalizeCaseIgnore), caseIgnoreSubstring))
+	r.Add(normalizingSubstringsRule(Id_mr_caseIgnoreListSubstringsMatch, caseIgnoreListConcatenated, caseIgnoreSubstring))
+	r.Add(normalizingSubstringsRule(Id_mr_numericStringSubstringsMatch, withSchema(normalizeNumericString), withSchema(normalizeNumericString)))
+	r.Add(normalizingSubstringsRule(Id_mr_telephoneNumberSubstringsMatch, withSchema(normalizeTelephoneNumber), withSchema(normalizeTelephoneNumber)))
+	r.Add(normalizingSubstringsRule(Id_mr_facsimileNumberSubstringsMatch, withSchema(normalizeFacsimileNumber), withSchema(normalizeTelephoneNumber)))
+	r.Add(normalizingSubstringsRule(Id_mr_octetStringSubstringsMatch, withSchema(normalizeOctetString), withSchema(normalizeOctetString)))
+
+	r.Add(firstComponentRule(Id_mr_directoryStringFirstComponentMatch, withSchema(normalizeCaseIgnore)))
+	r.Add(firstComponentRule(Id_mr_integerFirstComponentMatch, withSchema(normalizeEncoding)))
+	r.Add(firstComponentRule(Id_mr_objectIdentifierFirstComponentMatch, withSchema(normalizeObjectIdentifier)))
+	r.Add(wordRule(Id_mr_wordMatch))
+	r.Add(wordRule(Id_mr_keywordMatch))
+	r.Add(&MatchingRuleImpl{
+		Identifier: Id_mr_storedPrefixMatch,
+		Match: func(assertion, value asn1.RawValue) (bool, error) {
+			a, err := normalizeCaseIgnore(schema, assertion)
+			if err != nil {
+				return false, err
+			}
+			v, err := normalizeCaseIgnore(schema, value)
+			if err != nil {
+				return false, err
+			}
+			return strings.HasPrefix(a, v), nil
+		},
+	})
+	return r
+}
+
+var defaultMatchingRuleRegistry *MatchingRuleRegistry
+var defaultMatchingRuleRegistryOnce sync.Once
+
+// Returns a shared MatchingRuleRegistry created by
+// [NewDefaultMatchingRuleRegistry] with [DefaultSchemaRegistry]. It is used
+// where no other registry is supplied, and must not be modified.
+func DefaultMatchingRuleRegistry() *MatchingRuleRegistry {
+	defaultMatchingRuleRegistryOnce.Do(func() {
+		defaultMatchingRuleRegistry = NewDefaultMatchingRuleRegistry(nil)
+	})
+	return defaultMatchingRuleRegistry
+}
@@ -0,0 +1,197 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func TestDefaultMatchingRules(t *testing.T) {
+	r := DefaultMatchingRuleRegistry()
+	caseIgnore := r.Get(Id_mr_caseIgnoreMatch)
+	if caseIgnore == nil || caseIgnore.Match == nil {
+		t.Error("caseIgnoreMatch was not registered")
+		return
+	}
+	match, err := caseIgnore.Match(NewDirectoryString("  Hello   World "), NewDirectoryString("hello world"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !match {
+		t.Error("caseIgnoreMatch should ignore case and insignificant spaces")
+		return
+	}
+
+	ordering := r.Get(Id_mr_integerOrderingMatch)
+	if ordering == nil || ordering.Compare == nil {
+		t.Error("integerOrderingMatch was not registered")
+		return
+	}
+	cmp, err := ordering.Compare(mustMarshalValue(t, 300, ""), mustMarshalValue(t, -5, ""))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if cmp <= 0 {
+		t.Errorf("300 should be greater than -5, but compared as %d", cmp)
+		return
+	}
+
+	substrings := r.Get(Id_mr_telephoneNumberSubstringsMatch)
+	if substrings == nil || substrings.MatchSubstrings == nil {
+		t.Error("telephoneNumberSubstringsMatch was not registered")
+		return
+	}
+	match, err = substrings.MatchSubstrings([]FilterSubstring{
+		{Kind: SubstringInitial, Value: mustMarshalValue(t, "+1 555", "printable")},
+		{Kind: SubstringFinal, Value: mustMarshalValue(t, "12-34", "printable")},
+	}, mustMarshalValue(t, "+1-555-123-1234", "printable"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !match {
+		t.Error("telephoneNumberSubstringsMatch should ignore spaces and hyphens")
+		return
+	}
+	if r.Get(asn1.ObjectIdentifier{2, 999, 1}) != nil {
+		t.Error("an unknown matching rule should not be found")
+		return
+	}
+}
+
+func TestContainsSubstrings(t *testing.T) {
+	identity := func(value asn1.RawValue) (string, error) {
+		return string(value.Bytes), nil
+	}
+	sub := func(kind SubstringKind, s string) FilterSubstring {
+		return FilterSubstring{Kind: kind, Value: asn1.RawValue{Bytes: []byte(s)}}
+	}
+	for _, tc := range []struct {
+		s          string
+		substrings []FilterSubstring
+		expected   bool
+	}{
+		{"abcabc", []FilterSubstring{sub(SubstringInitial, "abc"), sub(SubstringFinal, "abc")}, true},
+		{"abc", []FilterSubstring{sub(SubstringInitial, "abc"), sub(SubstringFinal, "abc")}, false},
+		{"abcdef", []FilterSubstring{sub(SubstringAny, "cd"), sub(SubstringAny, "b")}, false},
+		{"abcdef", []FilterSubstring{sub(SubstringAny, "b"), sub(SubstringAny, "de")}, true},
+		{"abcdef", []FilterSubstring{sub(SubstringControl, "zzz"), sub(SubstringFinal, "ef")}, true},
+	} {
+		match, err := containsSubstrings(tc.s, tc.substrings, identity)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if match != tc.expected {
+			t.Errorf("substrings %v in %q: expected %v", tc.substrings, tc.s, tc.expected)
+		}
+	}
+}
+
+func TestX520MatchingRules(t *testing.T) {
+	r := DefaultMatchingRuleRegistry()
+	utc := func(s string) asn1.RawValue {
+		return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagGeneralizedTime, Bytes: []byte(s)}
+	}
+	firstComponent := mustMarshalValue(t, struct {
+		Name  string `asn1:"utf8"`
+		Index int
+	}{"Finance  Department", 1}, "")
+	list := func(items ...string) asn1.RawValue {
+		values := make([]asn1.RawValue, 0, len(items))
+		for _, item := range items {
+			values = append(values, NewDirectoryString(item))
+		}
+		return mustMarshalValue(t, values, "")
+	}
+	postalAddress := list("1 Main Street", "Springfield")
+	for _, tc := range []struct {
+		rule      asn1.ObjectIdentifier
+		assertion asn1.RawValue
+		value     asn1.RawValue
+		expected  bool
+	}{
+		{Id_mr_caseIgnoreMatch, NewDirectoryString("STRASSE"), NewDirectoryString("straße"), true},
+		{Id_mr_caseExactMatch, NewDirectoryString("Hello"), NewDirectoryString("hello"), false},
+		{Id_mr_generalizedTimeMatch, utc("20240101120000Z"), utc("20240101140000+0200"), true},
+		{Id_mr_uriMatch, NewDirectoryString("HTTPS://Example.COM/Path"), NewDirectoryString("https://example.com/Path"), true},
+		{Id_mr_uriMatch, NewDirectoryString("https://example.com/path"), NewDirectoryString("https://example.com/Path"), false},
+		{Id_mr_wordMatch, NewDirectoryString("BROWN"), NewDirectoryString("The quick, brown fox"), true},
+		{Id_mr_keywordMatch, NewDirectoryString("qui"), NewDirectoryString("The quick, brown fox"), false},
+		{Id_mr_storedPrefixMatch, NewDirectoryString("+1 555 1234"), NewDirectoryString("+1 555"), true},
+		{Id_mr_directoryStringFirstComponentMatch, NewDirectoryString("finance department"), firstComponent, true},
+		{Id_mr_integerFirstComponentMatch, mustMarshalValue(t, 1, ""), firstComponent, false},
+		{Id_mr_caseIgnoreListMatch, list("1 MAIN STREET", "springfield"), postalAddress, true},
+		{Id_mr_caseIgnoreListMatch, list("1 Main", "Street Springfield"), postalAddress, false},
+		{Id_mr_uuidpairmatch, mustMarshalValue(t, UUIDPair{make([]byte, 16), make([]byte, 16)}, ""), mustMarshalValue(t, UUIDPair{make([]byte, 16), make([]byte, 16)}, ""), true},
+	} {
+		rule := r.Get(tc.rule)
+		if rule == nil || rule.Match == nil {
+			t.Errorf("%s was not registered", tc.rule)
+			continue
+		}
+		match, err := rule.Match(tc.assertion, tc.value)
+		if err != nil {
+			t.Errorf("%s: %v", tc.rule, err)
+			continue
+		}
+		if match != tc.expected {
+			t.Errorf("%s: expected %v for %x and %x", tc.rule, tc.expected, tc.assertion.Bytes, tc.value.Bytes)
+		}
+	}
+
+	cmp, err := r.Get(Id_mr_generalizedTimeOrderingMatch).Compare(utc("20240101120000Z"), utc("20240101130000+0200"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if cmp <= 0 {
+		t.Error("12:00 UTC should be later than 11:00 UTC")
+		return
+	}
+
+	match, err := r.Get(Id_mr_caseIgnoreListSubstringsMatch).MatchSubstrings([]FilterSubstring{
+		{Kind: SubstringAny, Value: NewDirectoryString("streetspring")},
+	}, postalAddress)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !match {
+		t.Error("the strings of a caseIgnoreList should be searched as one")
+		return
+	}
+}
+
+func TestMatchingRuleValueEqual(t *testing.T) {
+	old := []Attribute{{Type: Id_at_commonName, Values: []asn1.RawValue{NewDirectoryString("Spongebob  Squarepants")}}}
+	new := []Attribute{{Type: Id_at_commonName, Values: []asn1.RawValue{NewDirectoryString("SPONGEBOB SQUAREPANTS")}}}
+	mods, err := DiffAttributes(old, new, &DiffOptions{ValueEqual: DefaultMatchingRuleRegistry().ValueEqual(nil)})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mods) != 0 {
+		t.Errorf("expected no modifications, got %d", len(mods))
+		return
+	}
+}
+
+func TestDNMatcherMatchingRules(t *testing.T) {
+	r := NewDefaultMatchingRuleRegistry(nil)
+	r.Add(normalizingEqualityRule(Id_mr_caseIgnoreMatch, func(value asn1.RawValue) (string, error) {
+		return "same", nil
+	}))
+	m := &DNMatcher{MatchingRules: r}
+	a := mustParseDN(t, "CN=Alice,O=Example")
+	b := mustParseDN(t, "CN=Bob,O=Example")
+	if !m.DNEqual(a, b) {
+		t.Error("the normalizer in the registry should have been used")
+		return
+	}
+	if DNEqual(a, b) {
+		t.Error("the default registry should not have been modified")
+		return
+	}
+}
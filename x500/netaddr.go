@@ -0,0 +1,125 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net"
+	"net/netip"
+	"strconv"
+)
+
+// A key and a value, both of which are text, such as those of the labeled
+// parameters of a URI. Marshal encodes it as:
+//
+//	TextualKeyValue ::= SEQUENCE {
+//	    key    UTF8String,
+//	    value  UTF8String }
+type TextualKeyValue struct {
+	Key   string `asn1:"utf8"`
+	Value string `asn1:"utf8"`
+}
+
+// A host name or IP address and a port number. Marshal encodes it as the
+// SEQUENCE below, or, if the struct member is tagged with a string type, such
+// as "ia5", as a string of the form "host:port" or "[IPv6]:port".
+//
+//	HostPort ::= SEQUENCE {
+//	    host   IA5String,
+//	    port   INTEGER (0..65535) }
+type HostPort struct {
+	Host string `asn1:"ia5"`
+	Port int
+}
+
+// An IPv4 or IPv6 address block in Classless Inter-Domain Routing (CIDR)
+// notation. Marshal encodes it as an OCTET STRING of the address followed by
+// the mask, as in the iPAddress of the name constraints defined in IETF RFC
+// 5280, or, if the struct member is tagged with a string type, such as "ia5",
+// as a string such as "192.168.0.0/16".
+type CIDR = netip.Prefix
+
+// An IPv4 or IPv6 address and a TCP or UDP port. Marshal encodes it as an
+// OCTET STRING of the address followed by the port in network byte order, as
+// are TransportAddressIPv4 and TransportAddressIPv6 defined in IETF RFC 3419,
+// or, if the struct member is tagged with a string type, such as "ia5", as a
+// string such as "192.168.0.1:389" or "[2001:db8::1]:389".
+type TransportAddress = netip.AddrPort
+
+// Returns the "host:port" form of a HostPort.
+func (hp HostPort) String() string {
+	return net.JoinHostPort(hp.Host, strconv.Itoa(hp.Port))
+}
+
+// Parses a HostPort from a string such as "dsa.example.com:102" or
+// "[2001:db8::1]:102".
+func ParseHostPort(s string) (hp HostPort, err error) {
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return hp, err
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return hp, fmt.Errorf("invalid port: %s", port)
+	}
+	return HostPort{Host: host, Port: int(p)}, nil
+}
+
+func decodeHostPort(encoded asn1.RawValue) (hp HostPort, err error) {
+	if err = unmarshalExactly(encoded, &hp); err != nil {
+		return hp, err
+	}
+	if hp.Port < 0 || hp.Port > 65535 {
+		return hp, fmt.Errorf("port number out of range: %d", hp.Port)
+	}
+	return hp, nil
+}
+
+// Returns the address of a CIDR followed by its mask.
+func encodeCIDR(prefix CIDR) []byte {
+	addr := prefix.Addr().AsSlice()
+	mask := make([]byte, len(addr))
+	for i := 0; i < prefix.Bits(); i++ {
+		mask[i/8] |= 0x80 >> (i % 8)
+	}
+	return append(addr, mask...)
+}
+
+// Decodes an address followed by a mask, which must be contiguous.
+func decodeCIDR(octets []byte) (prefix CIDR, err error) {
+	if len(octets) != 2*net.IPv4len && len(octets) != 2*net.IPv6len {
+		return prefix, fmt.Errorf("invalid length for an address and mask: %d", len(octets))
+	}
+	half := len(octets) / 2
+	addr, _ := netip.AddrFromSlice(octets[:half])
+	ones := 0
+	ended := false
+	for _, b := range octets[half:] {
+		leading := bits.LeadingZeros8(^b)
+		if (ended && b != 0) || b<<leading != 0 {
+			return prefix, errors.New("non-contiguous mask")
+		}
+		ones += leading
+		ended = leading < 8
+	}
+	return netip.PrefixFrom(addr, ones), nil
+}
+
+// Returns the address of a TransportAddress followed by its port.
+func encodeTransportAddress(addrPort TransportAddress) []byte {
+	addr := addrPort.Addr().AsSlice()
+	return append(addr, byte(addrPort.Port()>>8), byte(addrPort.Port()))
+}
+
+// Decodes an address followed by a port, as the TransportAddressIPv4 and
+// TransportAddressIPv6 of IETF RFC 3419.
+func decodeTransportAddress(octets []byte) (addrPort TransportAddress, err error) {
+	if len(octets) != net.IPv4len+2 && len(octets) != net.IPv6len+2 {
+		return addrPort, fmt.Errorf("invalid length for an address and port: %d", len(octets))
+	}
+	n := len(octets) - 2
+	addr, _ := netip.AddrFromSlice(octets[:n])
+	port := uint16(octets[n])<<8 | uint16(octets[n+1])
+	return netip.AddrPortFrom(addr, port), nil
+}
@@ -0,0 +1,124 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalNetworkAddresses(t *testing.T) {
+	type Service struct {
+		Parameter    TextualKeyValue    `x500:"oid:1.2.3.1"`
+		Server       HostPort           `x500:"oid:1.2.3.2"`
+		ServerText   HostPort           `x500:"oid:1.2.3.3,ia5"`
+		Network      CIDR               `x500:"oid:1.2.3.4"`
+		NetworkText  CIDR               `x500:"oid:1.2.3.5,utf8"`
+		Endpoint     TransportAddress   `x500:"oid:1.2.3.6"`
+		EndpointText TransportAddress   `x500:"oid:1.2.3.7,printable"`
+		Unset        CIDR               `x500:"oid:1.2.3.8"`
+		Endpoints    []TransportAddress `x500:"oid:1.2.3.9"`
+	}
+	in := Service{
+		Parameter:    TextualKeyValue{Key: "scope", Value: "sub"},
+		Server:       HostPort{Host: "dsa.example.com", Port: 102},
+		ServerText:   HostPort{Host: "2001:db8::1", Port: 4632},
+		Network:      netip.MustParsePrefix("192.168.0.0/16"),
+		NetworkText:  netip.MustParsePrefix("2001:db8::/32"),
+		Endpoint:     netip.MustParseAddrPort("192.168.0.1:389"),
+		EndpointText: netip.MustParseAddrPort("192.168.0.1:636"),
+		Endpoints: []TransportAddress{
+			netip.MustParseAddrPort("10.0.0.1:102"),
+			netip.MustParseAddrPort("[::1]:102"),
+		},
+	}
+	attrs, err := Marshal(in)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(attrs) != 8 {
+		t.Errorf("marshalling produced %d attributes instead of 8", len(attrs))
+		return
+	}
+	expected := []struct {
+		tag   int
+		bytes []byte
+	}{
+		{asn1.TagSequence, []byte{0x0C, 0x05, 's', 'c', 'o', 'p', 'e', 0x0C, 0x03, 's', 'u', 'b'}},
+		{asn1.TagSequence, append(append([]byte{0x16, 0x0F}, "dsa.example.com"...), 0x02, 0x01, 0x66)},
+		{asn1.TagIA5String, []byte("[2001:db8::1]:4632")},
+		{asn1.TagOctetString, []byte{192, 168, 0, 0, 255, 255, 0, 0}},
+		{asn1.TagUTF8String, []byte("2001:db8::/32")},
+		{asn1.TagOctetString, []byte{192, 168, 0, 1, 0x01, 0x85}},
+		{asn1.TagPrintableString, []byte("192.168.0.1:636")},
+	}
+	for i, e := range expected {
+		value := attrs[i].Values[0]
+		if value.Tag != e.tag || !bytes.Equal(value.Bytes, e.bytes) {
+			t.Errorf("attribute %d: expected tag %d and %x, but got tag %d and %x", i, e.tag, e.bytes, value.Tag, value.Bytes)
+		}
+	}
+	if len(attrs[7].Values) != 2 || len(attrs[7].Values[1].Bytes) != 18 {
+		t.Errorf("unexpected transport addresses: %v", attrs[7].Values)
+		return
+	}
+	var out Service
+	if err = Unmarshal(attrs, &out); err != nil {
+		t.Error(err)
+		return
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("expected %+v, but got %+v", in, out)
+	}
+}
+
+func TestDecodeNetworkAddresses(t *testing.T) {
+	prefix, err := decodeCIDR([]byte{10, 0, 0, 0, 255, 240, 0, 0})
+	if err != nil || prefix != netip.MustParsePrefix("10.0.0.0/12") {
+		t.Errorf("expected 10.0.0.0/12, but got %s (%v)", prefix, err)
+	}
+	for _, octets := range [][]byte{
+		{10, 0, 0, 0, 255, 0, 255, 0},
+		{10, 0, 0, 0, 0, 255, 0, 0},
+		{10, 0, 0, 0, 255, 0b10110000, 0, 0},
+		{10, 0, 0, 0, 255},
+	} {
+		if _, err := decodeCIDR(octets); err == nil {
+			t.Errorf("expected %x to be rejected", octets)
+		}
+	}
+	if _, err := decodeTransportAddress([]byte{10, 0, 0, 1, 0}); err == nil {
+		t.Error("expected a truncated transport address to be rejected")
+	}
+	if _, err := decodeHostPort(mustMarshalValue(t, HostPort{Host: "a", Port: 65536}, "")); err == nil {
+		t.Error("expected a port number out of range to be rejected")
+	}
+	for s, expected := range map[string]HostPort{
+		"dsa.example.com:102": {Host: "dsa.example.com", Port: 102},
+		"[2001:db8::1]:389":   {Host: "2001:db8::1", Port: 389},
+	} {
+		hp, err := ParseHostPort(s)
+		if err != nil || hp != expected || hp.String() != s {
+			t.Errorf("%s: got %+v (%v)", s, hp, err)
+		}
+	}
+	for _, s := range []string{"dsa.example.com", "dsa.example.com:65536", "dsa.example.com:ldap"} {
+		if _, err := ParseHostPort(s); err == nil {
+			t.Errorf("expected %s to be rejected", s)
+		}
+	}
+
+	// Only an OCTET STRING or a string is accepted as a CIDR.
+	type Service struct {
+		Network CIDR `x500:"oid:1.2.3.4"`
+	}
+	attrs := []Attribute{{Type: asn1.ObjectIdentifier{1, 2, 3, 4}, Values: []asn1.RawValue{
+		mustMarshalValue(t, true, ""),
+	}}}
+	var out Service
+	if err := Unmarshal(attrs, &out); err == nil {
+		t.Error("expected a BOOLEAN to be rejected as a CIDR")
+	}
+}
@@ -0,0 +1,182 @@
+package x500
+
+import (
+	"cmp"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"strings"
+)
+
+// # ASN.1 Definition:
+//
+//	ComplexNumber ::= SEQUENCE {
+//	  real       REAL,
+//	  imaginary  REAL,
+//	  ... }
+//
+// Marshal encodes complex64 and complex128 values as ComplexNumber.
+type ComplexNumber = complex128
+
+func encodeComplexNumber(c complex128) (asn1.RawValue, error) {
+	return sequenceOf(encodeReal(real(c)).FullBytes, encodeReal(imag(c)).FullBytes)
+}
+
+func decodeComplexNumber(value asn1.RawValue) (complex128, error) {
+	components, err := sequenceComponents(value)
+	if err != nil {
+		return 0, err
+	}
+	if len(components) < 2 {
+		return 0, errors.New("a complex number must have a real and imaginary part")
+	}
+	re, err := decodeReal(components[0])
+	if err != nil {
+		return 0, err
+	}
+	im, err := decodeReal(components[1])
+	if err != nil {
+		return 0, err
+	}
+	return complex(re, im), nil
+}
+
+// An amount of money: Amount × 10^-Scale units of the currency identified by
+// Code. For example, 12.34 US dollars is Currency{"USD", 1234, 2}.
+//
+// # ASN.1 Definition:
+//
+//	Currency ::= SEQUENCE {
+//	  code    PrintableString (SIZE (3)), -- ISO 4217 alphabetic code
+//	  amount  INTEGER,
+//	  scale   INTEGER (0..MAX) DEFAULT 2,
+//	  ... }
+type Currency struct {
+	Code   string `asn1:"printable"`
+	Amount int64
+	Scale  int `asn1:"optional,default:2"`
+}
+
+// Returns the value of `c` in its smallest units, and of `other` in the same
+// units, so that they can be compared.
+func (c Currency) commonAmounts(other Currency) (a, b *big.Int) {
+	a = big.NewInt(c.Amount)
+	b = big.NewInt(other.Amount)
+	ten := big.NewInt(10)
+	if c.Scale < other.Scale {
+		a.Mul(a, new(big.Int).Exp(ten, big.NewInt(int64(other.Scale-c.Scale)), nil))
+	} else if other.Scale < c.Scale {
+		b.Mul(b, new(big.Int).Exp(ten, big.NewInt(int64(c.Scale-other.Scale)), nil))
+	}
+	return a, b
+}
+
+// Returns a negative number if `c` is less than `other`, zero if they are
+// equal, and a positive number if `c` is greater. Amounts of different
+// currencies cannot be compared.
+func (c Currency) Compare(other Currency) (int, error) {
+	if !strings.EqualFold(c.Code, other.Code) {
+		return 0, fmt.Errorf("cannot compare %s to %s", c.Code, other.Code)
+	}
+	a, b := c.commonAmounts(other)
+	return a.Cmp(b), nil
+}
+
+// Returns `c` as the currency code followed by the amount in decimal, such
+// as "USD 12.34".
+func (c Currency) String() string {
+	amount := new(big.Int).Abs(big.NewInt(c.Amount)).String()
+	if c.Scale > 0 {
+		if len(amount) <= c.Scale {
+			amount = strings.Repeat("0", c.Scale-len(amount)+1) + amount
+		}
+		amount = amount[:len(amount)-c.Scale] + "." + amount[len(amount)-c.Scale:]
+	}
+	if c.Amount < 0 {
+		amount = "-" + amount
+	}
+	return c.Code + " " + amount
+}
+
+// Parses a Currency in the form produced by [Currency.String], such as
+// "USD 12.34". The scale is the number of digits after the decimal point.
+func ParseCurrency(s string) (c Currency, err error) {
+	code, amount, found := strings.Cut(strings.TrimSpace(s), " ")
+	if !found || len(code) != 3 {
+		return c, fmt.Errorf("invalid currency %q", s)
+	}
+	c.Code = code
+	amount = strings.TrimSpace(amount)
+	whole, fraction, _ := strings.Cut(amount, ".")
+	c.Scale = len(fraction)
+	i, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok || strings.HasPrefix(fraction, "-") || strings.HasPrefix(fraction, "+") {
+		return c, fmt.Errorf("invalid currency amount %q", amount)
+	}
+	if !i.IsInt64() {
+		return c, fmt.Errorf("currency amount %q out of range", amount)
+	}
+	c.Amount = i.Int64()
+	return c, nil
+}
+
+// Returns an equality matching rule for values of ComplexNumber identified by
+// `oid`. The rule also supports ordering, by magnitude and then by phase.
+func NewComplexNumberMatchingRule(oid asn1.ObjectIdentifier) *MatchingRuleImpl {
+	return &MatchingRuleImpl{
+		Identifier: oid,
+		Match: func(assertion, value asn1.RawValue) (bool, error) {
+			a, err := decodeComplexNumber(assertion)
+			if err != nil {
+				return false, err
+			}
+			v, err := decodeComplexNumber(value)
+			return err == nil && a == v, err
+		},
+		Compare: func(value, assertion asn1.RawValue) (int, error) {
+			a, err := decodeComplexNumber(assertion)
+			if err != nil {
+				return 0, err
+			}
+			v, err := decodeComplexNumber(value)
+			if err != nil {
+				return 0, err
+			}
+			if cmplx.IsNaN(a) || cmplx.IsNaN(v) {
+				return 0, errors.New("cannot compare complex numbers that are not numbers")
+			}
+			if c := cmp.Compare(cmplx.Abs(v), cmplx.Abs(a)); c != 0 {
+				return c, nil
+			}
+			return cmp.Compare(math.Mod(cmplx.Phase(v)+2*math.Pi, 2*math.Pi), math.Mod(cmplx.Phase(a)+2*math.Pi, 2*math.Pi)), nil
+		},
+	}
+}
+
+// Returns an equality matching rule for values of Currency identified by
+// `oid`. Amounts are compared regardless of their scale, so 12.3 and 12.30 of
+// the same currency match. The rule also supports ordering of amounts of the
+// same currency.
+func NewCurrencyMatchingRule(oid asn1.ObjectIdentifier) *MatchingRuleImpl {
+	compare := func(value, assertion asn1.RawValue) (int, error) {
+		var a, v Currency
+		if err := unmarshalExactly(assertion, &a); err != nil {
+			return 0, err
+		}
+		if err := unmarshalExactly(value, &v); err != nil {
+			return 0, err
+		}
+		return v.Compare(a)
+	}
+	return &MatchingRuleImpl{
+		Identifier: oid,
+		Match: func(assertion, value asn1.RawValue) (bool, error) {
+			c, err := compare(value, assertion)
+			return err == nil && c == 0, err
+		},
+		Compare: compare,
+	}
+}
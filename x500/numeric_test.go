@@ -0,0 +1,108 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func TestMarshalNumeric(t *testing.T) {
+	type Asset struct {
+		Impedance ComplexNumber `x500:"oid:2.999.1"`
+		Small     complex64     `x500:"oid:2.999.2"`
+		Weight    float64       `x500:"oid:2.999.3"`
+		Price     Currency      `x500:"oid:2.999.4"`
+	}
+	in := Asset{
+		Impedance: complex(50, -12.5),
+		Small:     complex(1, 2),
+		Weight:    72.25,
+		Price:     Currency{Code: "USD", Amount: 1234, Scale: 2},
+	}
+	attrs, err := Marshal(in)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if attrs[2].Values[0].Tag != tagReal {
+		t.Error("a float should be encoded as a REAL")
+		return
+	}
+	var out Asset
+	err = Unmarshal(attrs, &out)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if out != in {
+		t.Errorf("asset was unmarshalled as %+v", out)
+		return
+	}
+}
+
+func TestCurrency(t *testing.T) {
+	for _, s := range []string{"USD 12.34", "JPY 500", "EUR -0.05", "BHD 1.500"} {
+		c, err := ParseCurrency(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if c.String() != s {
+			t.Errorf("%s was formatted as %s", s, c.String())
+		}
+	}
+	for _, s := range []string{"USD", "DOLLARS 1", "USD 1.-5", "USD abc"} {
+		if _, err := ParseCurrency(s); err == nil {
+			t.Errorf("%s should not have been parsed", s)
+		}
+	}
+
+	rule := NewCurrencyMatchingRule(asn1.ObjectIdentifier{2, 999, 1})
+	tenTwenty := mustMarshalValue(t, Currency{"USD", 1020, 2}, "")
+	match, err := rule.Match(mustMarshalValue(t, Currency{"USD", 102, 1}, ""), tenTwenty)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !match {
+		t.Error("amounts should be compared regardless of scale")
+		return
+	}
+	cmp, err := rule.Compare(tenTwenty, mustMarshalValue(t, Currency{"USD", 11, 0}, ""))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if cmp >= 0 {
+		t.Error("10.20 should be less than 11")
+		return
+	}
+	if _, err := rule.Compare(tenTwenty, mustMarshalValue(t, Currency{"EUR", 11, 0}, "")); err == nil {
+		t.Error("amounts of different currencies should not be compared")
+		return
+	}
+}
+
+func TestComplexNumberMatchingRule(t *testing.T) {
+	rule := NewComplexNumberMatchingRule(asn1.ObjectIdentifier{2, 999, 1})
+	encode := func(c complex128) asn1.RawValue {
+		value, err := encodeComplexNumber(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	match, err := rule.Match(encode(complex(3, 4)), encode(complex(3, 4)))
+	if err != nil || !match {
+		t.Errorf("3+4i should match itself: %v", err)
+		return
+	}
+	cmp, err := rule.Compare(encode(complex(0, 6)), encode(complex(3, 4)))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if cmp <= 0 {
+		t.Error("6i should be greater than 3+4i, which has a magnitude of 5")
+		return
+	}
+}
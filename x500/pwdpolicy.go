@@ -0,0 +1,393 @@
+package x500
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// id-PBKDF2 and id-hmacWithSHA1, from IETF RFC 8018.
+var (
+	id_PBKDF2       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	id_hmacWithSHA1 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+)
+
+// The defaults used when a PBKDF2 algorithm identifier has no parameters, and
+// the length of generated salts. The maximums bound the work done to verify a
+// password against a stored UserPwd, which could otherwise name any iteration
+// count or be of any length.
+const (
+	defaultPBKDF2Iterations = 600000
+	defaultPBKDF2KeyLength  = 32
+	maxPBKDF2Iterations     = 10000000
+	maxPBKDF2KeyLength      = 256
+	passwordSaltLength      = 16
+)
+
+// The password policy attributes of ITU-T Recommendation X.520, Section 6.13,
+// as found in a pwdAdminSubentry. Use [Unmarshal] to produce a
+// PwdPolicy from the attributes of a subentry, and [Marshal] to produce
+// them from a PwdPolicy. Durations and ages are in seconds, and zero
+// values mean that the attribute is absent.
+type PwdPolicy struct {
+	MaxAge                  int                        `x500:"oid:2.5.18.32,omitempty"`
+	ExpiryAge               int                        `x500:"oid:2.5.18.33,omitempty"`
+	MinLength               int                        `x500:"oid:2.5.18.34,omitempty"`
+	Vocabulary              PwdVocabulary              `x500:"oid:2.5.18.35,omitempty"`
+	Alphabet                PwdAlphabet                `x500:"oid:2.5.18.36,list,utf8,omitempty"`
+	Dictionaries            []SubtreeSpecificationNode `x500:"oid:2.5.18.37,omitempty"`
+	ExpiryWarning           int                        `x500:"oid:2.5.18.38,omitempty"`
+	Graces                  int                        `x500:"oid:2.5.18.39,omitempty"`
+	FailureDuration         int                        `x500:"oid:2.5.18.40,omitempty"`
+	LockoutDuration         int                        `x500:"oid:2.5.18.41,omitempty"`
+	MaxFailures             int                        `x500:"oid:2.5.18.42,omitempty"`
+	MaxTimeInHistory        int                        `x500:"oid:2.5.18.43,omitempty"`
+	MinTimeInHistory        int                        `x500:"oid:2.5.18.44,omitempty"`
+	HistorySlots            int                        `x500:"oid:2.5.18.45,omitempty"`
+	RecentlyExpiredDuration int                        `x500:"oid:2.5.18.46,omitempty"`
+	EncAlg                  *PwdEncAlg                 `x500:"oid:2.5.18.47,omitempty"`
+}
+
+// A reason for which a password is rejected by [PasswordValidator.Validate].
+type PasswordViolation int
+
+const (
+	PasswordTooShort PasswordViolation = iota + 1
+	PasswordNotInAlphabet
+	PasswordInVocabulary
+	PasswordInHistory
+)
+
+var passwordViolationMessages = map[PasswordViolation]string{
+	PasswordTooShort:      "password is shorter than the minimum length",
+	PasswordNotInAlphabet: "password has characters that are not in the alphabet",
+	PasswordInVocabulary:  "password is in a prohibited vocabulary",
+	PasswordInHistory:     "password has been used recently",
+}
+
+func (v PasswordViolation) Error() string {
+	if msg, ok := passwordViolationMessages[v]; ok {
+		return msg
+	}
+	return fmt.Sprintf("password violation %d", int(v))
+}
+
+// Checks candidate passwords against `Policy`, as a DSA does when a password
+// is changed. A nil `Policy` permits every password.
+type PasswordValidator struct {
+	Policy *PwdPolicy
+
+	// Returns true if `password` is in the vocabulary identified by
+	// `vocabulary`, which is one of the named bits of [PwdVocabulary], such as
+	// [PwdVocabulary_NoDictionaryWords]. The dictionaries may be found using
+	// the Dictionaries of `Policy`. If nil, the vocabulary of passwords is not
+	// checked.
+	InVocabulary func(vocabulary int, password string) (bool, error)
+
+	// The time at which the history of passwords is evaluated. If zero, the
+	// current time is used.
+	Now time.Time
+}
+
+func (v *PasswordValidator) now() time.Time {
+	if v.Now.IsZero() {
+		return time.Now()
+	}
+	return v.Now
+}
+
+// Returns the entries of `history`, which are the values of a userPwdHistory
+// attribute, that the policy requires to be checked: those no older than the
+// maximum time in history, which are among the most recent history slots or
+// no older than the minimum time in history.
+func (v *PasswordValidator) relevantHistory(history []PwdHistory) []PwdHistory {
+	p := v.Policy
+	now := v.now()
+	sorted := append([]PwdHistory(nil), history...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.After(sorted[j].Time)
+	})
+	var relevant []PwdHistory
+	for i, h := range sorted {
+		age := now.Sub(h.Time)
+		if p.MaxTimeInHistory > 0 && age > time.Duration(p.MaxTimeInHistory)*time.Second {
+			continue
+		}
+		inSlots := i < p.HistorySlots
+		young := p.MinTimeInHistory > 0 && age <= time.Duration(p.MinTimeInHistory)*time.Second
+		if inSlots || young {
+			relevant = append(relevant, h)
+		}
+	}
+	return relevant
+}
+
+// Checks `password` against the minimum length, alphabet and vocabulary of
+// the policy, and against the passwords in `history`, which are the values of
+// the userPwdHistory attribute of the entry. The length is measured in
+// characters, and every character must appear in one of the strings of the
+// alphabet, if it has any. The first violation found is returned as a
+// [PasswordViolation].
+func (v *PasswordValidator) Validate(password string, history []PwdHistory) error {
+	p := v.Policy
+	if p == nil {
+		return nil
+	}
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return PasswordTooShort
+	}
+	if len(p.Alphabet) > 0 {
+		alphabet := strings.Join(p.Alphabet, "")
+		for _, c := range password {
+			if !strings.ContainsRune(alphabet, c) {
+				return PasswordNotInAlphabet
+			}
+		}
+	}
+	if v.InVocabulary != nil {
+		for _, vocabulary := range []int{
+			PwdVocabulary_NoDictionaryWords,
+			PwdVocabulary_NoPersonNames,
+			PwdVocabulary_NoGeographicalNames,
+		} {
+			if p.Vocabulary.At(vocabulary) == 0 {
+				continue
+			}
+			in, err := v.InVocabulary(vocabulary, password)
+			if err != nil {
+				return err
+			}
+			if in {
+				return PasswordInVocabulary
+			}
+		}
+	}
+	for _, h := range v.relevantHistory(history) {
+		match, err := VerifyUserPwd(password, h.Password)
+		if err != nil {
+			return err
+		}
+		if match {
+			return PasswordInHistory
+		}
+	}
+	return nil
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	Prf            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// The SHA-2 hash functions supported for salted hashes of passwords.
+var passwordHashFuncs = map[string]func() hash.Hash{
+	Id_sha224.String(): sha256.New224,
+	Id_sha256.String(): sha256.New,
+	Id_sha384.String(): sha512.New384,
+	Id_sha512.String(): sha512.New,
+}
+
+// The pseudorandom functions supported for PBKDF2, by the hash function of
+// their HMACs.
+var pbkdf2HashFuncs = map[string]func() hash.Hash{
+	id_hmacWithSHA1.String():   sha1.New,
+	Id_hmacWithSHA224.String(): sha256.New224,
+	Id_hmacWithSHA256.String(): sha256.New,
+	Id_hmacWithSHA384.String(): sha512.New384,
+	Id_hmacWithSHA512.String(): sha512.New,
+}
+
+// Returns an identifier of the PBKDF2 algorithm of IETF RFC 8018, which has
+// no salt, so that a new salt is generated for each password encrypted with
+// it. `prf` identifies the pseudorandom function, such as
+// [Id_hmacWithSHA256].
+func NewPBKDF2PwdEncAlg(iterations, keyLength int, prf asn1.ObjectIdentifier) (PwdEncAlg, error) {
+	return marshalPBKDF2PwdEncAlg(pbkdf2Params{
+		IterationCount: iterations,
+		KeyLength:      keyLength,
+		Prf:            pkix.AlgorithmIdentifier{Algorithm: prf, Parameters: asn1.NullRawValue},
+	})
+}
+
+func marshalPBKDF2PwdEncAlg(params pbkdf2Params) (PwdEncAlg, error) {
+	if params.Prf.Algorithm.Equal(id_hmacWithSHA1) {
+		// The DEFAULT value is omitted.
+		params.Prf = pkix.AlgorithmIdentifier{}
+	}
+	encoded, err := asn1.Marshal(params)
+	if err != nil {
+		return PwdEncAlg{}, err
+	}
+	return PwdEncAlg{Algorithm: id_PBKDF2, Parameters: asn1.RawValue{FullBytes: encoded}}, nil
+}
+
+// Returns an identifier of a salted SHA-2 hash function, such as [Id_sha256],
+// with no salt, so that a new salt is generated for each password encrypted
+// with it. Like the {SSHA256} scheme of LDAP, the encrypted string is the
+// hash of the password followed by the salt.
+//
+// The salt is recorded as an OCTET STRING in the parameters of the algorithm
+// identifier, which is a private convention of this package: the hash
+// functions of IETF RFC 5754 have absent or NULL parameters, so other
+// implementations will not verify these passwords, and will not salt theirs.
+// Prefer [NewPBKDF2PwdEncAlg], which is the default of [EncryptUserPwd].
+func NewSaltedSHA2PwdEncAlg(hashAlg asn1.ObjectIdentifier) (PwdEncAlg, error) {
+	if _, ok := passwordHashFuncs[hashAlg.String()]; !ok {
+		return PwdEncAlg{}, fmt.Errorf("unsupported hash algorithm %s", hashAlg)
+	}
+	return PwdEncAlg{Algorithm: hashAlg}, nil
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, passwordSaltLength)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+// Returns the encrypted string of `password` under `alg` and the algorithm
+// identifier that verifies it, which has the salt that was used. When
+// verifying, `storedLength` is the length of the stored encrypted string,
+// which is the PBKDF2 key length if the parameters have none.
+func encryptPassword(password string, alg PwdEncAlg, generateSalt bool, storedLength int) ([]byte, PwdEncAlg, error) {
+	if alg.Algorithm.Equal(id_PBKDF2) {
+		params := pbkdf2Params{
+			IterationCount: defaultPBKDF2Iterations,
+			KeyLength:      defaultPBKDF2KeyLength,
+			Prf:            pkix.AlgorithmIdentifier{Algorithm: Id_hmacWithSHA256, Parameters: asn1.NullRawValue},
+		}
+		if !isZeroRawValue(alg.Parameters) {
+			params = pbkdf2Params{}
+			if err := unmarshalExactly(alg.Parameters, &params); err != nil {
+				return nil, alg, fmt.Errorf("invalid pbkdf2 parameters: %w", err)
+			}
+			if len(params.Prf.Algorithm) == 0 {
+				params.Prf.Algorithm = id_hmacWithSHA1
+			}
+		}
+		if params.IterationCount < 1 || params.IterationCount > maxPBKDF2Iterations {
+			return nil, alg, errors.New("invalid pbkdf2 iteration count")
+		}
+		if params.KeyLength == 0 {
+			// The key length is optional, because it is the length of the
+			// encrypted string.
+			params.KeyLength = defaultPBKDF2KeyLength
+			if !generateSalt {
+				params.KeyLength = storedLength
+			}
+		}
+		if params.KeyLength < 1 || params.KeyLength > maxPBKDF2KeyLength {
+			return nil, alg, errors.New("invalid pbkdf2 key length")
+		}
+		h, ok := pbkdf2HashFuncs[params.Prf.Algorithm.String()]
+		if !ok {
+			return nil, alg, fmt.Errorf("unsupported pseudorandom function %s", params.Prf.Algorithm)
+		}
+		if generateSalt && len(params.Salt) == 0 {
+			salt, err := newSalt()
+			if err != nil {
+				return nil, alg, err
+			}
+			params.Salt = salt
+		}
+		used, err := marshalPBKDF2PwdEncAlg(params)
+		if err != nil {
+			return nil, alg, err
+		}
+		return pbkdf2.Key([]byte(password), params.Salt, params.IterationCount, params.KeyLength, h), used, nil
+	}
+	h, ok := passwordHashFuncs[alg.Algorithm.String()]
+	if !ok {
+		return nil, alg, fmt.Errorf("unsupported password encryption algorithm %s", alg.Algorithm)
+	}
+	var salt []byte
+	if !isZeroRawValue(alg.Parameters) && !bytes.Equal(encodingOf(alg.Parameters), asn1.NullBytes) {
+		if err := unmarshalExactly(alg.Parameters, &salt); err != nil {
+			return nil, alg, fmt.Errorf("invalid salt: %w", err)
+		}
+	}
+	if generateSalt && len(salt) == 0 {
+		var err error
+		if salt, err = newSalt(); err != nil {
+			return nil, alg, err
+		}
+		encoded, err := asn1.Marshal(salt)
+		if err != nil {
+			return nil, alg, err
+		}
+		alg.Parameters = asn1.RawValue{FullBytes: encoded}
+	}
+	digest := h()
+	digest.Write([]byte(password))
+	digest.Write(salt)
+	return digest.Sum(nil), alg, nil
+}
+
+// Returns the encrypted alternative of a UserPwd for `password`, using `alg`,
+// such as the EncAlg of a [PwdPolicy]. Salted SHA-2 hash functions and
+// PBKDF2 are supported. If `alg` has no salt, a random salt is generated, and
+// recorded in the algorithm identifier of the result. If `alg` is the zero
+// value, PBKDF2 with HMAC-SHA256, 600,000 iterations and a 32-byte key is
+// used.
+func EncryptUserPwd(password string, alg PwdEncAlg) (UserPwd, error) {
+	if len(alg.Algorithm) == 0 {
+		alg = PwdEncAlg{Algorithm: id_PBKDF2}
+	}
+	encrypted, used, err := encryptPassword(password, alg, true, 0)
+	if err != nil {
+		return UserPwd{}, err
+	}
+	return marshalRawValue(UserPwd_encrypted{AlgorithmIdentifier: used, EncryptedString: encrypted}, "")
+}
+
+// Returns the clear alternative of a UserPwd for `password`.
+func ClearUserPwd(password string) (UserPwd, error) {
+	return marshalRawValue(password, "utf8")
+}
+
+// Returns true if `password` is the password of `stored`, which is a value of
+// the userPwd attribute or of a [PwdHistory]. An error is returned if
+// `stored` is encrypted with an unsupported algorithm.
+func VerifyUserPwd(password string, stored UserPwd) (bool, error) {
+	var choice asn1.RawValue
+	if err := unmarshalExactly(stored, &choice); err != nil {
+		return false, fmt.Errorf("invalid userPwd: %w", err)
+	}
+	if choice.Class == asn1.ClassUniversal && choice.Tag == asn1.TagUTF8String {
+		return subtle.ConstantTimeCompare([]byte(password), choice.Bytes) == 1, nil
+	}
+	var encrypted UserPwd_encrypted
+	if err := unmarshalExactly(stored, &encrypted); err != nil {
+		return false, fmt.Errorf("invalid userPwd: %w", err)
+	}
+	computed, _, err := encryptPassword(password, encrypted.AlgorithmIdentifier, false, len(encrypted.EncryptedString))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(computed, encrypted.EncryptedString) == 1, nil
+}
+
+// Returns the UserPwd to store for `password` under `p`: the encrypted
+// alternative if the policy has an EncAlg, and the clear alternative
+// otherwise.
+func (p *PwdPolicy) UserPwd(password string) (UserPwd, error) {
+	if p == nil || p.EncAlg == nil {
+		return ClearUserPwd(password)
+	}
+	return EncryptUserPwd(password, *p.EncAlg)
+}
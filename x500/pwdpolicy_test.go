@@ -0,0 +1,214 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPwdPolicyUnmarshal(t *testing.T) {
+	alg, err := NewPBKDF2PwdEncAlg(1000, 32, Id_hmacWithSHA256)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	in := PwdPolicy{
+		MinLength:    8,
+		Vocabulary:   withBit(asn1.BitString{}, PwdVocabulary_NoDictionaryWords, true),
+		Alphabet:     PwdAlphabet{"abcdefghijklmnopqrstuvwxyz", "0123456789"},
+		Dictionaries: []SubtreeSpecificationNode{{Base: mustParseDN(t, "OU=Words")}},
+		MaxFailures:  5,
+		HistorySlots: 3,
+		EncAlg:       &alg,
+	}
+	attrs, err := Marshal(in)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(attrs) != 7 {
+		t.Errorf("expected 7 attributes, but got %d", len(attrs))
+		return
+	}
+	var out PwdPolicy
+	if err := Unmarshal(attrs, &out); err != nil {
+		t.Error(err)
+		return
+	}
+	if out.MinLength != 8 || out.MaxFailures != 5 || out.HistorySlots != 3 || out.MaxAge != 0 {
+		t.Errorf("unexpected policy: %+v", out)
+		return
+	}
+	if len(out.Alphabet) != 2 || out.Alphabet[1] != "0123456789" || out.Vocabulary.At(PwdVocabulary_NoDictionaryWords) != 1 {
+		t.Errorf("unexpected alphabet or vocabulary: %+v", out)
+		return
+	}
+	if len(out.Dictionaries) != 1 || out.EncAlg == nil || !out.EncAlg.Algorithm.Equal(id_PBKDF2) {
+		t.Errorf("unexpected dictionaries or encryption algorithm: %+v", out)
+	}
+}
+
+func TestPasswordValidatorValidate(t *testing.T) {
+	policy := &PwdPolicy{
+		MinLength:        6,
+		Vocabulary:       withBit(asn1.BitString{}, PwdVocabulary_NoDictionaryWords, true),
+		Alphabet:         PwdAlphabet{"abcdefghijklmnopqrstuvwxyz", "0123456789"},
+		HistorySlots:     1,
+		MinTimeInHistory: 7 * 24 * 60 * 60,
+	}
+	now := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	v := &PasswordValidator{
+		Policy: policy,
+		InVocabulary: func(vocabulary int, password string) (bool, error) {
+			return vocabulary == PwdVocabulary_NoDictionaryWords && password == "password", nil
+		},
+		Now: now,
+	}
+	pwd := func(s string) UserPwd {
+		value, err := ClearUserPwd(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	history := []PwdHistory{
+		{Time: now.Add(-24 * time.Hour), Password: pwd("recent1")},
+		{Time: now.Add(-48 * time.Hour), Password: pwd("recent2")},
+		{Time: now.Add(-30 * 24 * time.Hour), Password: pwd("ancient1")},
+	}
+	cases := []struct {
+		password  string
+		violation PasswordViolation
+	}{
+		{"abc12", PasswordTooShort},
+		{"Abc123", PasswordNotInAlphabet},
+		{"password", PasswordInVocabulary},
+		{"recent1", PasswordInHistory},
+		// Beyond the history slots, but within the minimum time in history.
+		{"recent2", PasswordInHistory},
+		{"ancient1", 0},
+		{"abc123", 0},
+	}
+	for _, c := range cases {
+		err := v.Validate(c.password, history)
+		if c.violation == 0 {
+			if err != nil {
+				t.Errorf("%s: expected no violation, but got %v", c.password, err)
+			}
+			continue
+		}
+		if !errors.Is(err, c.violation) {
+			t.Errorf("%s: expected %v, but got %v", c.password, c.violation, err)
+		}
+	}
+	if err := (&PasswordValidator{}).Validate("", nil); err != nil {
+		t.Errorf("expected no policy to permit every password, but got %v", err)
+	}
+}
+
+func TestEncryptUserPwd(t *testing.T) {
+	pbkdf2Alg, err := NewPBKDF2PwdEncAlg(1000, 32, Id_hmacWithSHA256)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sha512Alg, err := NewSaltedSHA2PwdEncAlg(Id_sha512)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, alg := range []PwdEncAlg{pbkdf2Alg, sha512Alg} {
+		encrypted, err := EncryptUserPwd("correct horse", alg)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		again, err := EncryptUserPwd("correct horse", alg)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(encrypted.FullBytes) == string(again.FullBytes) {
+			t.Errorf("%s: expected a new salt for each password", alg.Algorithm)
+		}
+		for password, expected := range map[string]bool{"correct horse": true, "battery staple": false} {
+			ok, err := VerifyUserPwd(password, encrypted)
+			if err != nil || ok != expected {
+				t.Errorf("%s: %s: expected %t, but got %t (%v)", alg.Algorithm, password, expected, ok, err)
+			}
+		}
+	}
+
+	clear, err := (*PwdPolicy)(nil).UserPwd("secret")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if ok, err := VerifyUserPwd("secret", clear); err != nil || !ok {
+		t.Errorf("expected the clear password to verify, but got %t (%v)", ok, err)
+	}
+	// PBKDF2 is the default.
+	defaulted, err := EncryptUserPwd("secret", PwdEncAlg{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var encrypted UserPwd_encrypted
+	if err = unmarshalExactly(defaulted, &encrypted); err != nil || !encrypted.AlgorithmIdentifier.Algorithm.Equal(id_PBKDF2) {
+		t.Errorf("expected PBKDF2 to be the default, but got %+v (%v)", encrypted.AlgorithmIdentifier, err)
+		return
+	}
+	if ok, err := VerifyUserPwd("secret", defaulted); err != nil || !ok {
+		t.Errorf("expected the password to verify, but got %t (%v)", ok, err)
+	}
+	if _, err := NewSaltedSHA2PwdEncAlg(Id_sha1); err == nil {
+		t.Error("expected SHA-1 to be rejected")
+	}
+	if _, err := EncryptUserPwd("secret", PwdEncAlg{Algorithm: asn1.ObjectIdentifier{1, 2, 3}}); err == nil {
+		t.Error("expected an unsupported algorithm to be rejected")
+	}
+}
+
+// The test vector of IETF RFC 6070 for PBKDF2 with HMAC-SHA1.
+func TestVerifyUserPwdPBKDF2(t *testing.T) {
+	params, err := asn1.Marshal(pbkdf2Params{Salt: []byte("salt"), IterationCount: 2, KeyLength: 20})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	stored := mustMarshalValue(t, UserPwd_encrypted{
+		AlgorithmIdentifier: PwdEncAlg{Algorithm: id_PBKDF2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedString: []byte{
+			0xea, 0x6c, 0x01, 0x4d, 0xc7, 0x2d, 0x6f, 0x8c, 0xcd, 0x1e,
+			0xd9, 0x2a, 0xce, 0x1d, 0x41, 0xf0, 0xd8, 0xde, 0x89, 0x57,
+		},
+	}, "")
+	if ok, err := VerifyUserPwd("password", stored); err != nil || !ok {
+		t.Errorf("expected the password to verify, but got %t (%v)", ok, err)
+	}
+
+	// The key length is optional, so it is taken from the encrypted string.
+	var encrypted UserPwd_encrypted
+	if err = unmarshalExactly(stored, &encrypted); err != nil {
+		t.Error(err)
+		return
+	}
+	for _, p := range []pbkdf2Params{
+		{Salt: []byte("salt"), IterationCount: 2},
+		{Salt: []byte("salt"), IterationCount: maxPBKDF2Iterations + 1},
+	} {
+		params, err = asn1.Marshal(p)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		encrypted.AlgorithmIdentifier.Parameters = asn1.RawValue{FullBytes: params}
+		ok, err := VerifyUserPwd("password", mustMarshalValue(t, encrypted, ""))
+		if p.IterationCount == 2 && (err != nil || !ok) {
+			t.Errorf("expected the password to verify without a key length, but got %t (%v)", ok, err)
+		} else if p.IterationCount != 2 && err == nil {
+			t.Errorf("expected %d iterations to be rejected", p.IterationCount)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+const tagReal = 9
+
+// Encodes `f` as an ASN.1 REAL using the Distinguished Encoding Rules: finite
+// values use the binary encoding with base 2, an odd mantissa and no scaling
+// factor, as required by ITU-T Recommendation X.690, Section 11.3.1.
+func encodeReal(f float64) asn1.RawValue {
+	ret := asn1.RawValue{Class: asn1.ClassUniversal, Tag: tagReal}
+	switch {
+	case math.IsInf(f, 1):
+		ret.Bytes = []byte{0x40}
+	case math.IsInf(f, -1):
+		ret.Bytes = []byte{0x41}
+	case math.IsNaN(f):
+		ret.Bytes = []byte{0x42}
+	case f == 0 && math.Signbit(f):
+		ret.Bytes = []byte{0x43}
+	case f == 0:
+		ret.Bytes = []byte{}
+	default:
+		first := byte(0x80)
+		if f < 0 {
+			first |= 0x40
+			f = -f
+		}
+		frac, exp := math.Frexp(f)
+		mantissa := uint64(math.Ldexp(frac, 53))
+		exp -= 53
+		shift := bits.TrailingZeros64(mantissa)
+		mantissa >>= shift
+		exp += shift
+
+		// The exponent of a float64 always fits in two octets.
+		expBytes := twosComplement(int64(exp))
+		first |= byte(len(expBytes) - 1)
+		contents := append([]byte{first}, expBytes...)
+		mantissaBytes := big.NewInt(0).SetUint64(mantissa).Bytes()
+		ret.Bytes = append(contents, mantissaBytes...)
+	}
+	ret.FullBytes, _ = asn1.Marshal(ret)
+	return ret
+}
+
+// Returns the minimal two's complement encoding of `i`.
+func twosComplement(i int64) []byte {
+	b := make([]byte, 8)
+	for j := 7; j >= 0; j-- {
+		b[j] = byte(i)
+		i >>= 8
+	}
+	for len(b) > 1 && ((b[0] == 0 && b[1]&0x80 == 0) || (b[0] == 0xFF && b[1]&0x80 != 0)) {
+		b = b[1:]
+	}
+	return b
+}
+
+// Decodes an ASN.1 REAL in any of the encodings permitted by the Basic
+// Encoding Rules: binary, with any base and scaling factor; decimal, in any
+// of the ISO 6093 number representations; and the special real values.
+func decodeReal(value asn1.RawValue) (float64, error) {
+	if value.Class != asn1.ClassUniversal || value.Tag != tagReal || value.IsCompound {
+		return 0, fmt.Errorf("expected a REAL, but got tag %d", value.Tag)
+	}
+	contents := value.Bytes
+	if len(contents) == 0 {
+		return 0, nil
+	}
+	first := contents[0]
+	switch {
+	case first&0x80 != 0:
+		return decodeBinaryReal(contents)
+	case first&0x40 != 0:
+		if len(contents) != 1 {
+			return 0, errors.New("special real value encoded on more than one octet")
+		}
+		switch first {
+		case 0x40:
+			return math.Inf(1), nil
+		case 0x41:
+			return math.Inf(-1), nil
+		case 0x42:
+			return math.NaN(), nil
+		case 0x43:
+			return math.Copysign(0, -1), nil
+		}
+		return 0, fmt.Errorf("unrecognized special real value 0x%02X", first)
+	}
+	if first < 1 || first > 3 {
+		return 0, fmt.Errorf("unrecognized ISO 6093 number representation %d", first)
+	}
+	s := strings.TrimLeft(string(contents[1:]), " ")
+	s = strings.Replace(s, ",", ".", 1)
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed decimal real %q", contents[1:])
+	}
+	return f, nil
+}
+
+func decodeBinaryReal(contents []byte) (float64, error) {
+	first := contents[0]
+	var log2Base int
+	switch (first >> 4) & 0x03 {
+	case 0:
+		log2Base = 1
+	case 1:
+		log2Base = 3
+	case 2:
+		log2Base = 4
+	default:
+		return 0, errors.New("reserved base in binary real")
+	}
+	scale := int((first >> 2) & 0x03)
+	expLen := int(first&0x03) + 1
+	rest := contents[1:]
+	if expLen == 4 {
+		if len(rest) == 0 {
+			return 0, errors.New("truncated binary real")
+		}
+		expLen = int(rest[0])
+		rest = rest[1:]
+	}
+	if expLen == 0 || len(rest) < expLen {
+		return 0, errors.New("truncated binary real exponent")
+	}
+	if expLen > 4 {
+		return 0, errors.New("binary real exponent is too large")
+	}
+	exp := int64(int8(rest[0]))
+	for _, b := range rest[1:expLen] {
+		exp = exp<<8 | int64(b)
+	}
+	mantissa := new(big.Int).SetBytes(rest[expLen:])
+	f := new(big.Float).SetInt(mantissa)
+	f.SetMantExp(f, int(exp)*log2Base+scale)
+	result, _ := f.Float64()
+	if first&0x40 != 0 {
+		result = -result
+	}
+	return result, nil
+}
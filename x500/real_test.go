@@ -0,0 +1,75 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"math"
+	"testing"
+)
+
+func TestRealRoundTrip(t *testing.T) {
+	for _, f := range []float64{
+		0, 1, -1, 0.5, 3.14159, -273.15, 1e300, 5e-324, math.MaxFloat64,
+		math.Inf(1), math.Inf(-1), math.Copysign(0, -1),
+	} {
+		encoded := encodeReal(f)
+		var raw asn1.RawValue
+		if _, err := asn1.Unmarshal(encoded.FullBytes, &raw); err != nil {
+			t.Errorf("%g: %v", f, err)
+			continue
+		}
+		decoded, err := decodeReal(raw)
+		if err != nil {
+			t.Errorf("%g: %v", f, err)
+			continue
+		}
+		if decoded != f || math.Signbit(decoded) != math.Signbit(f) {
+			t.Errorf("%g was decoded as %g", f, decoded)
+		}
+	}
+	decoded, err := decodeReal(encodeReal(math.NaN()))
+	if err != nil || !math.IsNaN(decoded) {
+		t.Errorf("NaN was decoded as %g", decoded)
+		return
+	}
+}
+
+func TestRealDER(t *testing.T) {
+	for f, expected := range map[float64][]byte{
+		1:    {0x80, 0x00, 0x01},
+		0.5:  {0x80, 0xFF, 0x01},
+		-6:   {0xC0, 0x01, 0x03},
+		1024: {0x80, 0x0A, 0x01},
+	} {
+		if encoded := encodeReal(f); string(encoded.Bytes) != string(expected) {
+			t.Errorf("%g was encoded as %x, not %x", f, encoded.Bytes, expected)
+		}
+	}
+}
+
+func TestDecodeRealBER(t *testing.T) {
+	for _, tc := range []struct {
+		contents []byte
+		expected float64
+	}{
+		{[]byte{0x03, '1', '.', '5', 'E', '+', '2'}, 150},
+		{[]byte{0x02, ' ', '-', '2', ',', '5'}, -2.5},
+		{[]byte{0x90, 0x01, 0x03}, 24},      // base 8
+		{[]byte{0xA0, 0xFF, 0x08}, 0.5},     // base 16
+		{[]byte{0x84, 0x00, 0x03}, 6},       // scaling factor of 1
+		{[]byte{0x83, 0x01, 0x02, 0x01}, 4}, // exponent length octet
+		{[]byte{0x81, 0x00, 0x01, 0x01}, 2}, // two-octet exponent
+	} {
+		f, err := decodeReal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: tagReal, Bytes: tc.contents})
+		if err != nil {
+			t.Errorf("%x: %v", tc.contents, err)
+			continue
+		}
+		if f != tc.expected {
+			t.Errorf("%x was decoded as %g, not %g", tc.contents, f, tc.expected)
+		}
+	}
+	if _, err := decodeReal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: tagReal, Bytes: []byte{0xB0, 0x00, 0x01}}); err == nil {
+		t.Error("a reserved base should not be decoded")
+		return
+	}
+}
@@ -0,0 +1,282 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"errors"
+	"slices"
+)
+
+// A level of relaxation or tightening of a search filter.
+type RelaxationLevel struct {
+	// Zero for the basic filter, positive for relaxations and negative for
+	// tightenings.
+	Level int
+
+	// The filter that a DSA would evaluate at this level.
+	Filter *FilterNode
+
+	// The mapping-based matching algorithms in effect at this level, with
+	// their levels. These apply to the filter as a whole, in a way that only
+	// the DSA knows, so they are not reflected in `Filter`.
+	Mappings []Mapping
+}
+
+// The filters that a DSA would try when it applies a [RelaxationPolicy] to a
+// search filter.
+type RelaxationSteps struct {
+	// The filter with the basic matching rule substitutions applied.
+	Basic RelaxationLevel
+
+	// The filters to try, in order, if fewer than `Minimum` entries match.
+	Relaxations []RelaxationLevel
+
+	// The filters to try, in order, if more than `Maximum` entries match.
+	Tightenings []RelaxationLevel
+
+	// The number of entries that should match, at least, which defaults to
+	// one.
+	Minimum int
+
+	// The number of entries that should match, at most, or zero if there is
+	// no maximum.
+	Maximum int
+}
+
+// Returns the relaxation level `level`, or nil if there is no such level.
+func (s *RelaxationSteps) At(level int) *RelaxationLevel {
+	switch {
+	case level == 0:
+		return &s.Basic
+	case level > 0 && level <= len(s.Relaxations):
+		return &s.Relaxations[level-1]
+	case level < 0 && -level <= len(s.Tightenings):
+		return &s.Tightenings[-level-1]
+	}
+	return nil
+}
+
+// Emulates the relaxation that a DSA performs, in which `count` returns the
+// number of entries that a filter matches, such as by evaluating it against
+// entries with [FilterEvaluator.Evaluate]. The basic filter is tried first. If it
+// matches fewer than `Minimum` entries, relaxations are tried until one
+// matches enough, and if it matches more than `Maximum`, tightenings are
+// tried until one matches few enough, without going below `Minimum`. Returns
+// the level that the DSA would settle on and the number of entries it
+// matches.
+func (s *RelaxationSteps) Emulate(count func(filter *FilterNode) (int, error)) (level int, matched int, err error) {
+	if matched, err = count(s.Basic.Filter); err != nil {
+		return 0, 0, err
+	}
+	if matched < s.Minimum {
+		for i := range s.Relaxations {
+			if matched, err = count(s.Relaxations[i].Filter); err != nil {
+				return 0, 0, err
+			}
+			level = i + 1
+			if matched >= s.Minimum {
+				break
+			}
+		}
+		return level, matched, nil
+	}
+	if s.Maximum == 0 || matched <= s.Maximum {
+		return 0, matched, nil
+	}
+	for i := range s.Tightenings {
+		tightened, err := count(s.Tightenings[i].Filter)
+		if err != nil {
+			return 0, 0, err
+		}
+		if tightened < s.Minimum {
+			break
+		}
+		level, matched = -(i + 1), tightened
+		if matched <= s.Maximum {
+			break
+		}
+	}
+	return level, matched, nil
+}
+
+// Applies relaxation policies to search filters, as described in ITU-T
+// Recommendation X.501, Section 16.10.7, and ITU-T Recommendation X.511,
+// Section 13.3.2.3.
+type FilterRelaxer struct {
+	// Used to determine the matching rules that filter items use by
+	// default. If nil, [DefaultSchemaRegistry] is used.
+	Schema *SchemaRegistry
+}
+
+func (r *FilterRelaxer) schema() *SchemaRegistry {
+	if r == nil || r.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return r.Schema
+}
+
+// Returns true if `item` uses the matching rule `rule`, either explicitly as
+// an extensibleMatch, or implicitly as the equality, ordering or substrings
+// matching rule of its attribute type.
+func (r *FilterRelaxer) usesMatchingRule(item *FilterNode, rule asn1.ObjectIdentifier) bool {
+	if item.Kind == FilterKindExtensibleMatch {
+		return slices.ContainsFunc(item.MatchingRules, rule.Equal)
+	}
+	resolved, err := r.schema().ResolveAttributeType(item.Type.String())
+	if err != nil {
+		return false
+	}
+	switch item.Kind {
+	case FilterKindEquality:
+		return resolved.EqualityMatch.Equal(rule)
+	case FilterKindGreaterOrEqual, FilterKindLessOrEqual:
+		return resolved.OrderingMatch.Equal(rule)
+	case FilterKindSubstrings:
+		return resolved.SubstringsMatch.Equal(rule)
+	}
+	return false
+}
+
+// Returns `item` as an extensibleMatch with the matching rule `rule`. The
+// assertion of a substrings filter item becomes a SubstringAssertion.
+func substituteMatchingRule(item *FilterNode, rule asn1.ObjectIdentifier) (*FilterNode, error) {
+	value := item.Value
+	if item.Kind == FilterKindSubstrings {
+		strings := make([][]byte, 0, len(item.Substrings))
+		for _, s := range item.Substrings {
+			if s.Kind == SubstringControl {
+				strings = append(strings, encodingOf(s.Value))
+				continue
+			}
+			tagged, err := contextTagged(int(s.Kind), encodingOf(s.Value))
+			if err != nil {
+				return nil, err
+			}
+			strings = append(strings, tagged.FullBytes)
+		}
+		var err error
+		if value, err = sequenceOf(strings...); err != nil {
+			return nil, err
+		}
+	}
+	return &FilterNode{
+		Kind:          FilterKindExtensibleMatch,
+		Type:          item.Type,
+		Value:         value,
+		MatchingRules: []asn1.ObjectIdentifier{rule},
+		DNAttributes:  item.DNAttributes,
+	}, nil
+}
+
+// Returns `filter` with `substitutions` applied, or nil if every filter item
+// was removed by substitutions without a new matching rule.
+func (r *FilterRelaxer) substitute(filter *FilterNode, substitutions []MRSubstitution) (*FilterNode, error) {
+	switch filter.Kind {
+	case FilterKindAnd, FilterKindOr, FilterKindNot:
+		result := &FilterNode{Kind: filter.Kind, Filters: make([]*FilterNode, 0, len(filter.Filters))}
+		for _, operand := range filter.Filters {
+			substituted, err := r.substitute(operand, substitutions)
+			if err != nil {
+				return nil, err
+			}
+			if substituted != nil {
+				result.Filters = append(result.Filters, substituted)
+			}
+		}
+		if len(result.Filters) == 0 && len(filter.Filters) > 0 {
+			return nil, nil
+		}
+		return result, nil
+	case FilterKindPresent, FilterKindContextPresent:
+		return filter, nil
+	}
+	for _, s := range substitutions {
+		if !s.Attribute.Equal(filter.Type) {
+			continue
+		}
+		if len(s.OldMatchingRule) > 0 && !r.usesMatchingRule(filter, s.OldMatchingRule) {
+			continue
+		}
+		if len(s.NewMatchingRule) == 0 {
+			return nil, nil
+		}
+		return substituteMatchingRule(filter, s.NewMatchingRule)
+	}
+	return filter, nil
+}
+
+// Returns `filter` with `mapping` applied, and `mappings` updated with the
+// mapping-based matching algorithms of `mapping`.
+func (r *FilterRelaxer) apply(filter *FilterNode, mappings []Mapping, mapping *MRMapping) (*FilterNode, []Mapping, error) {
+	substituted, err := r.substitute(filter, mapping.Substitution)
+	if err != nil {
+		return nil, nil, err
+	}
+	if substituted == nil {
+		substituted = &FilterNode{Kind: FilterKindAnd}
+	}
+	mappings = slices.Clone(mappings)
+	for _, m := range mapping.Mapping {
+		i := slices.IndexFunc(mappings, func(existing Mapping) bool {
+			return existing.MappingFunction.Equal(m.MappingFunction)
+		})
+		if i >= 0 {
+			mappings[i] = m
+		} else {
+			mappings = append(mappings, m)
+		}
+	}
+	return substituted, mappings, nil
+}
+
+func (r *FilterRelaxer) levels(basic RelaxationLevel, steps []MRMapping, direction int) ([]RelaxationLevel, error) {
+	levels := make([]RelaxationLevel, 0, len(steps))
+	previous := basic
+	for i := range steps {
+		filter, mappings, err := r.apply(previous.Filter, previous.Mappings, &steps[i])
+		if err != nil {
+			return nil, err
+		}
+		previous = RelaxationLevel{Level: direction * (i + 1), Filter: filter, Mappings: mappings}
+		levels = append(levels, previous)
+	}
+	return levels, nil
+}
+
+// Returns the filters that a DSA would try when it applies `policy` to
+// `filter`. The basic mapping of the policy applies at every level. Each
+// relaxation or tightening applies on top of the basic mapping and those
+// before it, so that an old matching rule of a substitution may be a new
+// matching rule of an earlier one.
+//
+// A substitution applies to the filter items of its attribute type, other
+// than present and contextPresent items. If it has an old matching rule, it
+// only applies to items that use that rule, either as an extensibleMatch or
+// as the equality, ordering or substrings matching rule of the attribute type
+// in `Schema`. A filter item to which a substitution with a new matching rule
+// applies becomes an extensibleMatch with that rule, and one to which a
+// substitution without a new matching rule applies is removed from the
+// filter, along with any `not` that contains it.
+func (r *FilterRelaxer) Steps(policy *RelaxationPolicy, filter *FilterNode) (*RelaxationSteps, error) {
+	if len(policy.Tightenings) > 0 && policy.Maximum <= 0 {
+		return nil, errors.New("a relaxation policy with tightenings must have a maximum")
+	}
+	basicFilter, mappings, err := r.apply(filter, nil, &policy.Basic)
+	if err != nil {
+		return nil, err
+	}
+	steps := &RelaxationSteps{
+		Basic:   RelaxationLevel{Filter: basicFilter, Mappings: mappings},
+		Minimum: policy.Minimum,
+		Maximum: policy.Maximum,
+	}
+	if steps.Minimum == 0 {
+		steps.Minimum = 1
+	}
+	if steps.Relaxations, err = r.levels(steps.Basic, policy.Relaxations, 1); err != nil {
+		return nil, err
+	}
+	if steps.Tightenings, err = r.levels(steps.Basic, policy.Tightenings, -1); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
@@ -0,0 +1,102 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func TestRelaxationStepsEmulate(t *testing.T) {
+	policy := &RelaxationPolicy{
+		Relaxations: []MRMapping{
+			{Substitution: []MRSubstitution{{Attribute: Id_at_commonName, OldMatchingRule: Id_mr_caseIgnoreMatch, NewMatchingRule: Id_mr_wordMatch}}},
+			{Substitution: []MRSubstitution{{Attribute: Id_at_commonName}}},
+		},
+	}
+	steps, err := (&FilterRelaxer{}).Steps(policy, mustParseFilter(t, "(&(cn=John)(sn=Smith))"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if steps.Minimum != 1 || len(steps.Relaxations) != 2 || steps.At(2) != &steps.Relaxations[1] || steps.At(3) != nil {
+		t.Errorf("unexpected steps %+v", steps)
+		return
+	}
+	relaxed := steps.At(1).Filter.Filters[0]
+	if relaxed.Kind != FilterKindExtensibleMatch || !relaxed.MatchingRules[0].Equal(Id_mr_wordMatch) {
+		t.Errorf("expected an extensibleMatch with wordMatch, but got %s", relaxed)
+	}
+	if f := steps.At(2).Filter; len(f.Filters) != 1 || !f.Filters[0].Type.Equal(Id_at_surname) {
+		t.Errorf("expected the cn filter item to be removed, but got %s", f)
+	}
+
+	attrs := testEntryAttributes(t)
+	evaluator := &FilterEvaluator{}
+	level, matched, err := steps.Emulate(func(filter *FilterNode) (int, error) {
+		if evaluator.Evaluate(filter, attrs) == FilterTrue {
+			return 1, nil
+		}
+		return 0, nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if level != 1 || matched != 1 {
+		t.Errorf("expected the first relaxation to match, but got level %d with %d matched", level, matched)
+	}
+}
+
+func TestRelaxationStepsTighten(t *testing.T) {
+	zonal := Mapping{MappingFunction: asn1.ObjectIdentifier{1, 2, 3}, Level: 1}
+	policy := &RelaxationPolicy{
+		Basic: MRMapping{
+			Mapping:      []Mapping{zonal},
+			Substitution: []MRSubstitution{{Attribute: Id_at_surname}},
+		},
+		Tightenings: []MRMapping{
+			{Substitution: []MRSubstitution{{Attribute: Id_at_commonName, OldMatchingRule: Id_mr_caseIgnoreSubstringsMatch, NewMatchingRule: Id_mr_caseExactSubstringsMatch}}},
+			{Mapping: []Mapping{{MappingFunction: zonal.MappingFunction, Level: 2}}},
+		},
+		Maximum: 1,
+		Minimum: 1,
+	}
+	steps, err := (&FilterRelaxer{}).Steps(policy, mustParseFilter(t, "(&(cn=J*)(!(sn=Smith)))"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if basic := steps.Basic.Filter; len(basic.Filters) != 1 || basic.Filters[0].Kind != FilterKindSubstrings {
+		t.Errorf("expected the negated sn filter item to be removed, but got %s", basic)
+	}
+	tightened := steps.At(-1).Filter.Filters[0]
+	if tightened.Kind != FilterKindExtensibleMatch || !tightened.MatchingRules[0].Equal(Id_mr_caseExactSubstringsMatch) {
+		t.Errorf("expected an extensibleMatch with caseExactSubstringsMatch, but got %s", tightened)
+	}
+	if _, err := steps.At(-1).Filter.Marshal(); err != nil {
+		t.Error(err)
+	}
+	if mappings := steps.At(-2).Mappings; len(mappings) != 1 || mappings[0].Level != 2 || steps.Basic.Mappings[0].Level != 1 {
+		t.Errorf("unexpected mappings %+v", mappings)
+	}
+
+	counts := map[int]int{0: 5, -1: 3, -2: 0}
+	level, matched, err := steps.Emulate(func(filter *FilterNode) (int, error) {
+		for level, count := range counts {
+			if steps.At(level).Filter == filter {
+				return count, nil
+			}
+		}
+		return 0, nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if level != -1 || matched != 3 {
+		t.Errorf("expected to stop tightening before no entries match, but got level %d with %d matched", level, matched)
+	}
+
+	if _, err := (&FilterRelaxer{}).Steps(&RelaxationPolicy{Tightenings: policy.Tightenings}, mustParseFilter(t, "(cn=x)")); err == nil {
+		t.Error("expected tightenings without a maximum to be rejected")
+	}
+}
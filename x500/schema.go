@@ -0,0 +1,675 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A schema element that can be looked up by OID or by name.
+type identifiedSchemaElement interface {
+	SchemaElement
+	ObjectIdentifierIdentified
+}
+
+// Elements of a single kind, indexed by OID and by lower-cased name.
+type schemaTable[T any, P interface {
+	*T
+	identifiedSchemaElement
+}] struct {
+	byOID  map[string]P
+	byName map[string]P
+}
+
+func newSchemaTable[T any, P interface {
+	*T
+	identifiedSchemaElement
+}]() schemaTable[T, P] {
+	return schemaTable[T, P]{
+		byOID:  make(map[string]P),
+		byName: make(map[string]P),
+	}
+}
+
+func schemaElementNames(el SchemaElement) ([]string, error) {
+	names := make([]string, 0, len(el.GetName()))
+	for _, n := range el.GetName() {
+		s, err := DirectoryStringToString(n)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, s)
+	}
+	return names, nil
+}
+
+func (t *schemaTable[T, P]) add(el P) error {
+	names, err := schemaElementNames(el)
+	if err != nil {
+		return err
+	}
+	t.byOID[el.GetIdentifier().String()] = el
+	for _, name := range names {
+		t.byName[strings.ToLower(name)] = el
+	}
+	return nil
+}
+
+func (t *schemaTable[T, P]) get(oidOrName string) P {
+	if el, ok := t.byOID[oidOrName]; ok {
+		return el
+	}
+	return t.byName[strings.ToLower(oidOrName)]
+}
+
+func (t *schemaTable[T, P]) all() []P {
+	ret := make([]P, 0, len(t.byOID))
+	for _, el := range t.byOID {
+		ret = append(ret, el)
+	}
+	return ret
+}
+
+// An in-memory registry of the schema elements published in a subschema
+// subentry, such as one read using the schema operational attributes
+// `attributeTypes`, `objectClasses`, and so on.
+//
+// Elements are looked up by the string form of their object identifier, such
+// as "2.5.4.3", or case-insensitively by any of their names, such as "cn".
+// Adding an element whose identifier is already registered replaces it.
+//
+// A SchemaRegistry is not safe for concurrent modification, but may be read
+// concurrently once it has been populated.
+type SchemaRegistry struct {
+	attributeTypes   schemaTable[AttributeTypeDescription, *AttributeTypeDescription]
+	objectClasses    schemaTable[ObjectClassDescription, *ObjectClassDescription]
+	matchingRules    schemaTable[MatchingRuleDescription, *MatchingRuleDescription]
+	matchingRuleUses schemaTable[MatchingRuleUseDescription, *MatchingRuleUseDescription]
+	nameForms        schemaTable[NameFormDescription, *NameFormDescription]
+	contentRules     schemaTable[DITContentRuleDescription, *DITContentRuleDescription]
+	contextTypes     schemaTable[ContextDescription, *ContextDescription]
+	contextUses      schemaTable[DITContextUseDescription, *DITContextUseDescription]
+	friends          schemaTable[FriendsDescription, *FriendsDescription]
+	structureRules   map[RuleIdentifier]*DITStructureRuleDescription
+	ldapSyntaxes     map[string]*LdapSyntaxDescription
+}
+
+// Create a new, empty SchemaRegistry.
+func NewSchemaRegistry() *SchemaRegistry {
+	return &SchemaRegistry{
+		attributeTypes:   newSchemaTable[AttributeTypeDescription](),
+		objectClasses:    newSchemaTable[ObjectClassDescription](),
+		matchingRules:    newSchemaTable[MatchingRuleDescription](),
+		matchingRuleUses: newSchemaTable[MatchingRuleUseDescription](),
+		nameForms:        newSchemaTable[NameFormDescription](),
+		contentRules:     newSchemaTable[DITContentRuleDescription](),
+		contextTypes:     newSchemaTable[ContextDescription](),
+		contextUses:      newSchemaTable[DITContextUseDescription](),
+		friends:          newSchemaTable[FriendsDescription](),
+		structureRules:   make(map[RuleIdentifier]*DITStructureRuleDescription),
+		ldapSyntaxes:     make(map[string]*LdapSyntaxDescription),
+	}
+}
+
+// Register an attribute type.
+func (r *SchemaRegistry) AddAttributeType(desc AttributeTypeDescription) error {
+	return r.attributeTypes.add(&desc)
+}
+
+// Register an object class.
+func (r *SchemaRegistry) AddObjectClass(desc ObjectClassDescription) error {
+	return r.objectClasses.add(&desc)
+}
+
+// Register a matching rule.
+func (r *SchemaRegistry) AddMatchingRule(desc MatchingRuleDescription) error {
+	return r.matchingRules.add(&desc)
+}
+
+// Register the attribute types to which a matching rule applies.
+func (r *SchemaRegistry) AddMatchingRuleUse(desc MatchingRuleUseDescription) error {
+	return r.matchingRuleUses.add(&desc)
+}
+
+// Register a name form.
+func (r *SchemaRegistry) AddNameForm(desc NameFormDescription) error {
+	return r.nameForms.add(&desc)
+}
+
+// Register a DIT content rule. It is identified by its structural object class.
+func (r *SchemaRegistry) AddDITContentRule(desc DITContentRuleDescription) error {
+	return r.contentRules.add(&desc)
+}
+
+// Register a context type.
+func (r *SchemaRegistry) AddContextType(desc ContextDescription) error {
+	return r.contextTypes.add(&desc)
+}
+
+// Register a DIT context use. It is identified by its attribute type.
+func (r *SchemaRegistry) AddDITContextUse(desc DITContextUseDescription) error {
+	return r.contextUses.add(&desc)
+}
+
+// Register the friends of an anchor attribute type.
+func (r *SchemaRegistry) AddFriends(desc FriendsDescription) error {
+	return r.friends.add(&desc)
+}
+
+// Register a DIT structure rule. It is identified by its rule identifier.
+func (r *SchemaRegistry) AddDITStructureRule(desc DITStructureRuleDescription) error {
+	r.structureRules[desc.RuleIdentifier] = &desc
+	return nil
+}
+
+// Register an LDAP syntax.
+func (r *SchemaRegistry) AddLdapSyntax(desc LdapSyntaxDescription) error {
+	r.ldapSyntaxes[desc.Identifier.String()] = &desc
+	return nil
+}
+
+// Look up an attribute type by OID or name. Returns nil if not found.
+func (r *SchemaRegistry) AttributeType(oidOrName string) *AttributeTypeDescription {
+	return r.attributeTypes.get(oidOrName)
+}
+
+// Look up an object class by OID or name. Returns nil if not found.
+func (r *SchemaRegistry) ObjectClass(oidOrName string) *ObjectClassDescription {
+	return r.objectClasses.get(oidOrName)
+}
+
+// Look up a matching rule by OID or name. Returns nil if not found.
+func (r *SchemaRegistry) MatchingRule(oidOrName string) *MatchingRuleDescription {
+	return r.matchingRules.get(oidOrName)
+}
+
+// Look up a matching rule use by the OID or name of its matching rule.
+// Returns nil if not found.
+func (r *SchemaRegistry) MatchingRuleUse(oidOrName string) *MatchingRuleUseDescription {
+	return r.matchingRuleUses.get(oidOrName)
+}
+
+// Look up a name form by OID or name. Returns nil if not found.
+func (r *SchemaRegistry) NameForm(oidOrName string) *NameFormDescription {
+	return r.nameForms.get(oidOrName)
+}
+
+// Look up a DIT content rule by the OID of its structural object class or by
+// the name of the rule. Returns nil if not found.
+func (r *SchemaRegistry) DITContentRule(oidOrName string) *DITContentRuleDescription {
+	return r.contentRules.get(oidOrName)
+}
+
+// Look up a context type by OID or name. Returns nil if not found.
+func (r *SchemaRegistry) ContextType(oidOrName string) *ContextDescription {
+	return r.contextTypes.get(oidOrName)
+}
+
+// Look up a DIT context use by the OID of its attribute type or by its name.
+// Returns nil if not found.
+func (r *SchemaRegistry) DITContextUse(oidOrName string) *DITContextUseDescription {
+	return r.contextUses.get(oidOrName)
+}
+
+// Look up the friends of an anchor attribute type by the OID of the anchor or
+// by name. Returns nil if not found.
+func (r *SchemaRegistry) Friends(oidOrName string) *FriendsDescription {
+	return r.friends.get(oidOrName)
+}
+
+// Look up a DIT structure rule by its rule identifier. Returns nil if not
+// found.
+func (r *SchemaRegistry) DITStructureRule(id RuleIdentifier) *DITStructureRuleDescription {
+	return r.structureRules[id]
+}
+
+// Look up an LDAP syntax by OID. Returns nil if not found.
+func (r *SchemaRegistry) LdapSyntax(oid string) *LdapSyntaxDescription {
+	return r.ldapSyntaxes[oid]
+}
+
+// All registered attribute types, in no particular order.
+func (r *SchemaRegistry) AttributeTypes() []*AttributeTypeDescription {
+	return r.attributeTypes.all()
+}
+
+// All registered object classes, in no particular order.
+func (r *SchemaRegistry) ObjectClasses() []*ObjectClassDescription {
+	return r.objectClasses.all()
+}
+
+// All registered name forms, in no particular order.
+func (r *SchemaRegistry) NameForms() []*NameFormDescription {
+	return r.nameForms.all()
+}
+
+// All registered DIT structure rules, in no particular order.
+func (r *SchemaRegistry) DITStructureRules() []*DITStructureRuleDescription {
+	ret := make([]*DITStructureRuleDescription, 0, len(r.structureRules))
+	for _, rule := range r.structureRules {
+		ret = append(ret, rule)
+	}
+	return ret
+}
+
+// Returns the UTF8String encoding of `v` if it is a UniversalString, or `v`
+// otherwise.
+func universalStringToUTF8(v asn1.RawValue) (asn1.RawValue, error) {
+	if v.Class != asn1.ClassUniversal || v.Tag != tagUniversalString || v.IsCompound {
+		return v, nil
+	}
+	s, err := universalStringFromBytes(v.Bytes)
+	if err != nil {
+		return v, err
+	}
+	return NewDirectoryString(s), nil
+}
+
+// Unmarshals the schema description `b`, such as an [AttributeTypeDescription]
+// or a [SearchRuleDescription], into `desc`, like [asn1.Unmarshal]. Unlike
+// [asn1.Unmarshal], this accepts the UniversalString alternative of the
+// description component, which the generated types represent as a Go string.
+func UnmarshalSchemaDescription(b []byte, desc any) (rest []byte, err error) {
+	var seq asn1.RawValue
+	if rest, err = asn1.Unmarshal(b, &seq); err != nil {
+		return nil, err
+	}
+	if seq.Class != asn1.ClassUniversal || seq.Tag != asn1.TagSequence || !seq.IsCompound {
+		// Let encoding/asn1 report the structural error.
+		_, err = asn1.Unmarshal(b, desc)
+		return rest, err
+	}
+	var components [][]byte
+	transcoded := false
+	for contents := seq.Bytes; len(contents) > 0; {
+		var component asn1.RawValue
+		if contents, err = asn1.Unmarshal(contents, &component); err != nil {
+			return nil, err
+		}
+		switch {
+		case component.Class == asn1.ClassUniversal && component.Tag == tagUniversalString:
+			if component, err = universalStringToUTF8(component); err != nil {
+				return nil, err
+			}
+			transcoded = true
+		case component.Class == asn1.ClassContextSpecific && component.IsCompound:
+			// An explicitly-tagged description, as in SearchRuleDescription.
+			var inner asn1.RawValue
+			innerRest, err := asn1.Unmarshal(component.Bytes, &inner)
+			if err != nil || len(innerRest) > 0 || inner.Class != asn1.ClassUniversal || inner.Tag != tagUniversalString {
+				break
+			}
+			if inner, err = universalStringToUTF8(inner); err != nil {
+				return nil, err
+			}
+			innerBytes, err := asn1.Marshal(inner)
+			if err != nil {
+				return nil, err
+			}
+			if component, err = contextTagged(component.Tag, innerBytes); err != nil {
+				return nil, err
+			}
+			transcoded = true
+		}
+		encoded, err := asn1.Marshal(component)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, encoded)
+	}
+	if !transcoded {
+		_, err = asn1.Unmarshal(b, desc)
+		return rest, err
+	}
+	encoded, err := sequenceOf(components...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = asn1.Unmarshal(encoded.FullBytes, desc)
+	return rest, err
+}
+
+func decodeSchemaValues[T any](attr *Attribute, add func(T) error) error {
+	for i := 0; i < attr.Len(); i++ {
+		var desc T
+		rest, err := UnmarshalSchemaDescription(encodingOf(*attr.Get(i)), &desc)
+		if err != nil {
+			return fmt.Errorf("invalid value of %s: %w", attr.Type, err)
+		}
+		if len(rest) > 0 {
+			return fmt.Errorf("trailing bytes in value of %s", attr.Type)
+		}
+		if err = add(desc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Register the values of the subschema operational attributes among `attrs`,
+// such as those read from a subschema subentry. Attributes of other types are
+// ignored.
+func (r *SchemaRegistry) AddSubschemaAttributes(attrs []Attribute) error {
+	for i := range attrs {
+		attr := &attrs[i]
+		var err error
+		switch {
+		case attr.Type.Equal(Id_soa_attributeTypes):
+			err = decodeSchemaValues(attr, r.AddAttributeType)
+		case attr.Type.Equal(Id_soa_objectClasses):
+			err = decodeSchemaValues(attr, r.AddObjectClass)
+		case attr.Type.Equal(Id_soa_matchingRules):
+			err = decodeSchemaValues(attr, r.AddMatchingRule)
+		case attr.Type.Equal(Id_soa_matchingRuleUse):
+			err = decodeSchemaValues(attr, r.AddMatchingRuleUse)
+		case attr.Type.Equal(Id_soa_nameForms):
+			err = decodeSchemaValues(attr, r.AddNameForm)
+		case attr.Type.Equal(Id_soa_dITStructureRule):
+			err = decodeSchemaValues(attr, r.AddDITStructureRule)
+		case attr.Type.Equal(Id_soa_dITContentRules):
+			err = decodeSchemaValues(attr, r.AddDITContentRule)
+		case attr.Type.Equal(Id_soa_contextTypes):
+			err = decodeSchemaValues(attr, r.AddContextType)
+		case attr.Type.Equal(Id_soa_dITContextUse):
+			err = decodeSchemaValues(attr, r.AddDITContextUse)
+		case attr.Type.Equal(Id_soa_friends):
+			err = decodeSchemaValues(attr, r.AddFriends)
+		case attr.Type.Equal(Id_soa_ldapSyntaxes):
+			err = decodeSchemaValues(attr, r.AddLdapSyntax)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The operational attribute types from which [SchemaRegistry.AddSubschemaAttributes]
+// builds a registry, in the order in which they should be requested.
+var SubschemaAttributeTypes = []AttributeType{
+	Id_soa_attributeTypes,
+	Id_soa_objectClasses,
+	Id_soa_matchingRules,
+	Id_soa_matchingRuleUse,
+	Id_soa_nameForms,
+	Id_soa_dITStructureRule,
+	Id_soa_dITContentRules,
+	Id_soa_contextTypes,
+	Id_soa_dITContextUse,
+	Id_soa_friends,
+	Id_soa_ldapSyntaxes,
+}
+
+// Create a SchemaRegistry from the subschema operational attributes among
+// `attrs`. See [SchemaRegistry.AddSubschemaAttributes].
+func NewSchemaRegistryFromAttributes(attrs []Attribute) (*SchemaRegistry, error) {
+	r := NewSchemaRegistry()
+	if err := r.AddSubschemaAttributes(attrs); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// An attribute type with the characteristics it inherits from its supertypes.
+type ResolvedAttributeType struct {
+	Description *AttributeTypeDescription
+
+	// The attribute type itself, followed by each of its supertypes, ending
+	// with the most general.
+	Supertypes []*AttributeTypeDescription
+
+	EqualityMatch   asn1.ObjectIdentifier
+	OrderingMatch   asn1.ObjectIdentifier
+	SubstringsMatch asn1.ObjectIdentifier
+	Syntax          string
+	SingleValued    bool
+	Collective      bool
+	UserModifiable  bool
+	Usage           AttributeUsage
+}
+
+// Go's encoding/asn1 does not remove the explicit tag from a RawValue field, so
+// this does it, if it is present.
+func explicitlyTaggedValue(v asn1.RawValue) (asn1.RawValue, error) {
+	if v.Class != asn1.ClassContextSpecific || !v.IsCompound {
+		return v, nil
+	}
+	var inner asn1.RawValue
+	rest, err := asn1.Unmarshal(v.Bytes, &inner)
+	if err != nil {
+		return inner, err
+	}
+	if len(rest) > 0 {
+		return inner, errors.New("trailing bytes after explicitly-tagged value")
+	}
+	return inner, nil
+}
+
+func decodeDefaultTrue(v asn1.RawValue) (bool, error) {
+	if len(v.FullBytes) == 0 && v.Tag == 0 {
+		return true, nil
+	}
+	v, err := explicitlyTaggedValue(v)
+	if err != nil {
+		return false, err
+	}
+	var b bool
+	_, err = asn1.Unmarshal(encodingOf(v), &b)
+	return b, err
+}
+
+// Return the attribute type identified by `oidOrName` followed by its chain
+// of supertypes. An error is returned if the attribute type or any of its
+// supertypes is not registered, or if the chain is circular.
+func (r *SchemaRegistry) AttributeTypeChain(oidOrName string) ([]*AttributeTypeDescription, error) {
+	at := r.AttributeType(oidOrName)
+	if at == nil {
+		return nil, fmt.Errorf("unrecognized attribute type %s", oidOrName)
+	}
+	chain := []*AttributeTypeDescription{at}
+	seen := map[string]bool{at.Identifier.String(): true}
+	for len(at.Information.Derivation) > 0 {
+		super := at.Information.Derivation.String()
+		if seen[super] {
+			return nil, fmt.Errorf("circular derivation of attribute type %s", oidOrName)
+		}
+		seen[super] = true
+		at = r.AttributeType(super)
+		if at == nil {
+			return nil, fmt.Errorf("unrecognized supertype %s", super)
+		}
+		chain = append(chain, at)
+	}
+	return chain, nil
+}
+
+// Resolve an attribute type, taking its matching rules and syntax from the
+// nearest supertype that defines them, as described in ITU-T Recommendation
+// X.501 (2019), Section 13.4.7. Single-valuedness, collectiveness,
+// modifiability and usage are those of the attribute type itself.
+func (r *SchemaRegistry) ResolveAttributeType(oidOrName string) (*ResolvedAttributeType, error) {
+	chain, err := r.AttributeTypeChain(oidOrName)
+	if err != nil {
+		return nil, err
+	}
+	at := chain[0]
+	multiValued, err := decodeDefaultTrue(at.Information.Multi_valued)
+	if err != nil {
+		return nil, err
+	}
+	userModifiable, err := decodeDefaultTrue(at.Information.UserModifiable)
+	if err != nil {
+		return nil, err
+	}
+	resolved := &ResolvedAttributeType{
+		Description:    at,
+		Supertypes:     chain,
+		SingleValued:   !multiValued,
+		Collective:     at.Information.Collective,
+		UserModifiable: userModifiable,
+		Usage:          at.Information.Application,
+	}
+	for _, t := range chain {
+		info := &t.Information
+		if len(resolved.EqualityMatch) == 0 {
+			resolved.EqualityMatch = info.EqualityMatch
+		}
+		if len(resolved.OrderingMatch) == 0 {
+			resolved.OrderingMatch = info.OrderingMatch
+		}
+		if len(resolved.SubstringsMatch) == 0 {
+			resolved.SubstringsMatch = info.SubstringsMatch
+		}
+		if resolved.Syntax == "" && (info.AttributeSyntax.Tag > 0 || len(info.AttributeSyntax.FullBytes) > 0) {
+			syntaxValue, err := explicitlyTaggedValue(info.AttributeSyntax)
+			if err != nil {
+				return nil, err
+			}
+			syntax, err := DirectoryStringToString(syntaxValue)
+			if err != nil {
+				return nil, err
+			}
+			resolved.Syntax = syntax
+		}
+	}
+	return resolved, nil
+}
+
+// Returns true if the attribute type `sub` is `super` or is derived from it,
+// directly or indirectly. Unregistered attribute types are only subtypes of
+// themselves.
+func (r *SchemaRegistry) IsSubtypeOf(sub, super string) bool {
+	superType := r.AttributeType(super)
+	if superType == nil {
+		return sub == super
+	}
+	chain, err := r.AttributeTypeChain(sub)
+	if err != nil {
+		return false
+	}
+	for _, t := range chain {
+		if t.Identifier.Equal(superType.Identifier) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return all of the superclasses of the object class identified by
+// `oidOrName`, direct and indirect, nearest first, without duplicates and not
+// including the object class itself. An error is returned if the object class
+// or any of its superclasses is not registered.
+func (r *SchemaRegistry) Superclasses(oidOrName string) ([]*ObjectClassDescription, error) {
+	oc := r.ObjectClass(oidOrName)
+	if oc == nil {
+		return nil, fmt.Errorf("unrecognized object class %s", oidOrName)
+	}
+	ret := make([]*ObjectClassDescription, 0)
+	seen := map[string]bool{oc.Identifier.String(): true}
+	queue := []*ObjectClassDescription{oc}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, superOID := range current.Information.SubclassOf {
+			key := superOID.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			super := r.ObjectClass(key)
+			if super == nil {
+				return nil, fmt.Errorf("unrecognized superclass %s", key)
+			}
+			ret = append(ret, super)
+			queue = append(queue, super)
+		}
+	}
+	return ret, nil
+}
+
+// Returns true if the object class `sub` is `super` or is a subclass of it,
+// directly or indirectly.
+func (r *SchemaRegistry) IsSubclassOf(sub, super string) bool {
+	superClass := r.ObjectClass(super)
+	subClass := r.ObjectClass(sub)
+	if superClass == nil || subClass == nil {
+		return sub == super
+	}
+	if subClass.Identifier.Equal(superClass.Identifier) {
+		return true
+	}
+	supers, err := r.Superclasses(sub)
+	if err != nil {
+		return false
+	}
+	for _, oc := range supers {
+		if oc.Identifier.Equal(superClass.Identifier) {
+			return true
+		}
+	}
+	return false
+}
+
+// Return the mandatory and optional attribute types of the object class
+// identified by `oidOrName`, including those inherited from its superclasses.
+// An attribute type that is mandatory in any of them is not listed as
+// optional.
+func (r *SchemaRegistry) ObjectClassAttributes(oidOrName string) (mandatory []AttributeType, optional []AttributeType, err error) {
+	oc := r.ObjectClass(oidOrName)
+	if oc == nil {
+		return nil, nil, fmt.Errorf("unrecognized object class %s", oidOrName)
+	}
+	supers, err := r.Superclasses(oidOrName)
+	if err != nil {
+		return nil, nil, err
+	}
+	classes := append([]*ObjectClassDescription{oc}, supers...)
+	isMandatory := make(map[string]bool)
+	for _, c := range classes {
+		for _, at := range c.Information.Mandatories {
+			if !isMandatory[at.String()] {
+				isMandatory[at.String()] = true
+				mandatory = append(mandatory, at)
+			}
+		}
+	}
+	isOptional := make(map[string]bool)
+	for _, c := range classes {
+		for _, at := range c.Information.Optionals {
+			if !isMandatory[at.String()] && !isOptional[at.String()] {
+				isOptional[at.String()] = true
+				optional = append(optional, at)
+			}
+		}
+	}
+	return mandatory, optional, nil
+}
+
+var errNoStructuralObjectClass = errors.New("no structural object class")
+
+// Determine the structural object class of an entry having the object classes
+// `objectClasses`: the one structural object class of which all of the other
+// structural object classes are superclasses. See ITU-T Recommendation X.501
+// (2019), Section 13.3.2.
+func (r *SchemaRegistry) StructuralObjectClass(objectClasses []asn1.ObjectIdentifier) (*ObjectClassDescription, error) {
+	var structural *ObjectClassDescription
+	for _, oid := range objectClasses {
+		oc := r.ObjectClass(oid.String())
+		if oc == nil || oc.Information.Kind != ObjectClassKind_Structural {
+			continue
+		}
+		if structural == nil || r.IsSubclassOf(oc.Identifier.String(), structural.Identifier.String()) {
+			structural = oc
+			continue
+		}
+		if !r.IsSubclassOf(structural.Identifier.String(), oc.Identifier.String()) {
+			return nil, fmt.Errorf("structural object classes %s and %s are not in the same chain",
+				structural.Identifier, oc.Identifier)
+		}
+	}
+	if structural == nil {
+		return nil, errNoStructuralObjectClass
+	}
+	return structural, nil
+}
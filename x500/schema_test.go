@@ -0,0 +1,214 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+var testCaseIgnoreMatch = asn1.ObjectIdentifier{2, 5, 13, 2}
+var testCaseIgnoreSubstringsMatch = asn1.ObjectIdentifier{2, 5, 13, 4}
+
+func schemaValue(t *testing.T, desc any) asn1.RawValue {
+	encoded, err := asn1.Marshal(desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asn1.RawValue{FullBytes: encoded}
+}
+
+// Go's encoding/asn1 ignores the tagging of RawValue fields when marshaling,
+// so explicitly-tagged RawValue fields have to be wrapped by hand.
+func explicitlyTagged(t *testing.T, tag int, v any) asn1.RawValue {
+	encoded, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        tag,
+		IsCompound: true,
+		Bytes:      encoded,
+	}
+}
+
+func testSubschemaAttributes(t *testing.T) []Attribute {
+	name := AttributeTypeDescription{
+		Identifier: Id_at_name,
+		Name:       []UnboundedDirectoryString{NewDirectoryString("name")},
+		Information: AttributeTypeInformation{
+			EqualityMatch:   testCaseIgnoreMatch,
+			SubstringsMatch: testCaseIgnoreSubstringsMatch,
+			AttributeSyntax: explicitlyTagged(t, 4, NewDirectoryString("UnboundedDirectoryString")),
+		},
+	}
+	cn := AttributeTypeDescription{
+		Identifier: Id_at_commonName,
+		Name:       []UnboundedDirectoryString{NewDirectoryString("cn"), NewDirectoryString("commonName")},
+		Information: AttributeTypeInformation{
+			Derivation: Id_at_name,
+		},
+	}
+	sn := AttributeTypeDescription{
+		Identifier: Id_at_surname,
+		Name:       []UnboundedDirectoryString{NewDirectoryString("sn")},
+		Information: AttributeTypeInformation{
+			Derivation:   Id_at_name,
+			Multi_valued: explicitlyTagged(t, 5, false),
+		},
+	}
+	top := ObjectClassDescription{
+		Identifier: Id_oc_top,
+		Name:       []UnboundedDirectoryString{NewDirectoryString("top")},
+		Information: ObjectClassInformation{
+			Kind:        ObjectClassKind_Abstract,
+			Mandatories: []asn1.ObjectIdentifier{Id_at_objectClass},
+		},
+	}
+	person := ObjectClassDescription{
+		Identifier: Id_oc_person,
+		Name:       []UnboundedDirectoryString{NewDirectoryString("person")},
+		Information: ObjectClassInformation{
+			SubclassOf:  []asn1.ObjectIdentifier{Id_oc_top},
+			Kind:        ObjectClassKind_Structural,
+			Mandatories: []asn1.ObjectIdentifier{Id_at_commonName, Id_at_surname},
+			Optionals:   []asn1.ObjectIdentifier{Id_at_objectClass, Id_at_description},
+		},
+	}
+	orgPerson := ObjectClassDescription{
+		Identifier: Id_oc_organizationalPerson,
+		Name:       []UnboundedDirectoryString{NewDirectoryString("organizationalPerson")},
+		Information: ObjectClassInformation{
+			SubclassOf: []asn1.ObjectIdentifier{Id_oc_person},
+			Kind:       ObjectClassKind_Structural,
+			Optionals:  []asn1.ObjectIdentifier{Id_at_title},
+		},
+	}
+	rule := DITStructureRuleDescription{
+		RuleIdentifier: 1,
+		NameForm:       asn1.ObjectIdentifier{1, 2, 3},
+	}
+	return []Attribute{
+		{Type: Id_soa_attributeTypes, Values: []asn1.RawValue{
+			schemaValue(t, name),
+			schemaValue(t, cn),
+			schemaValue(t, sn),
+		}},
+		{Type: Id_soa_objectClasses, Values: []asn1.RawValue{
+			schemaValue(t, top),
+			schemaValue(t, person),
+			schemaValue(t, orgPerson),
+		}},
+		{Type: Id_soa_dITStructureRule, Values: []asn1.RawValue{schemaValue(t, rule)}},
+		{Type: Id_at_commonName, Values: []asn1.RawValue{encodeString("ignored")}},
+	}
+}
+
+func TestSchemaRegistryLookup(t *testing.T) {
+	r, err := NewSchemaRegistryFromAttributes(testSubschemaAttributes(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, key := range []string{"2.5.4.3", "cn", "CN", "commonName"} {
+		at := r.AttributeType(key)
+		if at == nil || !at.Identifier.Equal(Id_at_commonName) {
+			t.Errorf("failed to look up %s", key)
+			return
+		}
+	}
+	if r.AttributeType("givenName") != nil {
+		t.Error("found an unregistered attribute type")
+		return
+	}
+	top := r.ObjectClass("TOP")
+	if top == nil || top.Information.Kind != ObjectClassKind_Abstract {
+		t.Error("failed to look up an abstract object class")
+		return
+	}
+	if r.ObjectClass("person").Information.Kind != ObjectClassKind_Structural {
+		t.Error("kind of person was not structural")
+		return
+	}
+	if r.DITStructureRule(1) == nil {
+		t.Error("failed to look up a structure rule")
+		return
+	}
+}
+
+func TestSchemaRegistryResolveAttributeType(t *testing.T) {
+	r, err := NewSchemaRegistryFromAttributes(testSubschemaAttributes(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	cn, err := r.ResolveAttributeType("cn")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !cn.EqualityMatch.Equal(testCaseIgnoreMatch) || !cn.SubstringsMatch.Equal(testCaseIgnoreSubstringsMatch) {
+		t.Errorf("matching rules were not inherited: %v", cn)
+		return
+	}
+	if cn.Syntax != "UnboundedDirectoryString" {
+		t.Errorf("syntax was not inherited: %s", cn.Syntax)
+		return
+	}
+	if len(cn.Supertypes) != 2 || cn.SingleValued || !cn.UserModifiable {
+		t.Errorf("unexpected resolution: %v", cn)
+		return
+	}
+	sn, err := r.ResolveAttributeType("sn")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !sn.SingleValued {
+		t.Error("sn should be single-valued")
+		return
+	}
+	if !r.IsSubtypeOf("sn", "name") || r.IsSubtypeOf("name", "sn") {
+		t.Error("incorrect subtype relationship")
+		return
+	}
+}
+
+func TestSchemaRegistryObjectClasses(t *testing.T) {
+	r, err := NewSchemaRegistryFromAttributes(testSubschemaAttributes(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	supers, err := r.Superclasses("organizationalPerson")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(supers) != 2 || !supers[0].Identifier.Equal(Id_oc_person) || !supers[1].Identifier.Equal(Id_oc_top) {
+		t.Errorf("unexpected superclasses: %v", supers)
+		return
+	}
+	mandatory, optional, err := r.ObjectClassAttributes("organizationalPerson")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(mandatory) != 3 {
+		t.Errorf("expected 3 mandatory attributes, got %v", mandatory)
+		return
+	}
+	// objectClass is mandatory in top, so it must not be listed as optional.
+	if len(optional) != 2 || !optional[0].Equal(Id_at_title) || !optional[1].Equal(Id_at_description) {
+		t.Errorf("unexpected optional attributes: %v", optional)
+		return
+	}
+	structural, err := r.StructuralObjectClass([]asn1.ObjectIdentifier{Id_oc_top, Id_oc_person, Id_oc_organizationalPerson})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !structural.Identifier.Equal(Id_oc_organizationalPerson) {
+		t.Errorf("structural object class was %s", structural.Identifier)
+		return
+	}
+}
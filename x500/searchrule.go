@@ -0,0 +1,492 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// A reason that a search does not comply with a search rule. The problem is
+// one of the search service problems of ITU-T Recommendation X.511, such as
+// [Id_pr_missingSearchAttribute], which a DSA would report in the
+// `searchServiceProblem` notification attribute of a serviceError.
+type SearchRuleViolation struct {
+	// The search rule that the search does not comply with, which is nil if
+	// no search rule applies to the search.
+	Rule *SearchRule
+
+	Problem asn1.ObjectIdentifier
+
+	// The attribute type that the problem concerns, if any.
+	AttributeType AttributeType
+
+	// The context type that the problem concerns, if any.
+	ContextType asn1.ObjectIdentifier
+}
+
+var searchServiceProblemNames = map[string]string{
+	Id_pr_unidentifiedOperation.String():               "no search rule applies to the service type and user class",
+	Id_pr_searchAttributeViolation.String():            "attribute type not permitted in filter",
+	Id_pr_searchAttributeCombinationViolation.String(): "filter does not satisfy the attribute combination",
+	Id_pr_searchValueNotAllowed.String():               "value not permitted in filter",
+	Id_pr_missingSearchAttribute.String():              "attribute type required in filter",
+	Id_pr_searchContextViolation.String():              "context type not permitted in filter",
+	Id_pr_searchContextValueViolation.String():         "context value not permitted in filter",
+	Id_pr_searchSubsetViolation.String():               "subset not permitted",
+}
+
+func (v *SearchRuleViolation) Error() string {
+	message, ok := searchServiceProblemNames[v.Problem.String()]
+	if !ok {
+		message = "search service problem " + v.Problem.String()
+	}
+	if len(v.AttributeType) > 0 {
+		message += fmt.Sprintf(" (%s)", v.AttributeType)
+	}
+	if len(v.ContextType) > 0 {
+		message += fmt.Sprintf(" (context %s)", v.ContextType)
+	}
+	if v.Rule != nil {
+		return fmt.Sprintf("search rule %d of %s: %s", v.Rule.Id, v.Rule.DmdId, message)
+	}
+	return message
+}
+
+// The outcome of checking a search against the search rules in effect.
+type SearchRuleCheck struct {
+	// The search rule that governs the search, or nil if the search does not
+	// comply with any of the search rules that apply to it, in which case a
+	// DSA would reject it.
+	Governing *SearchRule
+
+	// If there is no governing search rule, the reasons that the search does
+	// not comply with each of the search rules that apply to it, in order.
+	Violations []SearchRuleViolation
+
+	// If there is a governing search rule, the search argument that the DSA
+	// would perform: its default and mandatory controls are applied to the
+	// service controls, search control options and hierarchy selections, its
+	// imposed subset replaces the subset, and its entry limit is applied to
+	// the size limit.
+	Effective *SearchArgumentData
+}
+
+// Checks searches against search rules, as described in ITU-T
+// Recommendation X.511, Section 13.3.2, so that searches that a DSA would
+// reject can be caught before they are sent.
+type SearchRuleChecker struct {
+	// Used to determine whether attribute types in a filter are subtypes of
+	// those of the search rule. If nil, [DefaultSchemaRegistry] is used.
+	Schema *SchemaRegistry
+}
+
+func (c *SearchRuleChecker) schema() *SchemaRegistry {
+	if c == nil || c.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return c.Schema
+}
+
+// The AttributeCombination of a search rule in a form that is easier to
+// evaluate than its nested CHOICEs.
+type attributeCombination struct {
+	tag       int
+	attribute AttributeType
+	operands  []*attributeCombination
+}
+
+func decodeAttributeCombination(value asn1.RawValue) (*attributeCombination, error) {
+	if isZeroRawValue(value) {
+		return &attributeCombination{tag: 1}, nil
+	}
+	var outer asn1.RawValue
+	if err := unmarshalExactly(value, &outer); err != nil {
+		return nil, err
+	}
+	if outer.Class != asn1.ClassContextSpecific || !outer.IsCompound {
+		return nil, errors.New("invalid attribute combination")
+	}
+	inner, err := explicitlyTaggedValue(outer)
+	if err != nil {
+		return nil, err
+	}
+	switch outer.Tag {
+	case 0:
+		c := &attributeCombination{tag: 0}
+		if err := unmarshalExactly(inner, &c.attribute); err != nil {
+			return nil, err
+		}
+		return c, nil
+	case 1, 2:
+		var elements []asn1.RawValue
+		if err := unmarshalExactly(inner, &elements); err != nil {
+			return nil, err
+		}
+		c := &attributeCombination{tag: outer.Tag}
+		for _, element := range elements {
+			operand, err := decodeAttributeCombination(element)
+			if err != nil {
+				return nil, err
+			}
+			c.operands = append(c.operands, operand)
+		}
+		return c, nil
+	case 3:
+		operand, err := decodeAttributeCombination(inner)
+		if err != nil {
+			return nil, err
+		}
+		return &attributeCombination{tag: 3, operands: []*attributeCombination{operand}}, nil
+	case 4:
+		// The explicit tag of the attributeCombination component of SearchRule.
+		return decodeAttributeCombination(inner)
+	}
+	return nil, fmt.Errorf("unrecognized attribute combination alternative %d", outer.Tag)
+}
+
+func (a *attributeCombination) satisfiedBy(present func(AttributeType) bool) bool {
+	switch a.tag {
+	case 0:
+		return present(a.attribute)
+	case 1:
+		for _, operand := range a.operands {
+			if !operand.satisfiedBy(present) {
+				return false
+			}
+		}
+		return true
+	case 2:
+		for _, operand := range a.operands {
+			if operand.satisfiedBy(present) {
+				return true
+			}
+		}
+		return false
+	}
+	return !a.operands[0].satisfiedBy(present)
+}
+
+// Returns the attribute types that must be present for `a` to be satisfied:
+// those that are not beneath an `or` or `not`.
+func (a *attributeCombination) required() []AttributeType {
+	switch a.tag {
+	case 0:
+		return []AttributeType{a.attribute}
+	case 1:
+		var types []AttributeType
+		for _, operand := range a.operands {
+			types = append(types, operand.required()...)
+		}
+		return types
+	}
+	return nil
+}
+
+// Returns the filter that a search uses: its extendedFilter if present, and
+// otherwise its filter, which defaults to and:{}.
+func searchFilter(arg *SearchArgumentData) (*FilterNode, error) {
+	filter := arg.Filter
+	if !isZeroRawValue(arg.ExtendedFilter) {
+		filter = arg.ExtendedFilter
+	}
+	if isZeroRawValue(filter) {
+		return &FilterNode{Kind: FilterKindAnd}, nil
+	}
+	return DecodeFilter(filter)
+}
+
+// Calls `f` for each filter item of `filter`.
+func walkFilterItems(filter *FilterNode, f func(item *FilterNode)) {
+	switch filter.Kind {
+	case FilterKindAnd, FilterKindOr, FilterKindNot:
+		for _, operand := range filter.Filters {
+			walkFilterItems(operand, f)
+		}
+	default:
+		f(filter)
+	}
+}
+
+// Decodes an optional SEQUENCE OF component that is represented as an
+// `asn1.RawValue`, returning false if it is absent.
+func decodeOptionalSequenceOf(value asn1.RawValue, out any) (bool, error) {
+	if isZeroRawValue(value) {
+		return false, nil
+	}
+	var outer asn1.RawValue
+	if err := unmarshalExactly(value, &outer); err != nil {
+		return true, err
+	}
+	inner, err := explicitlyTaggedValue(outer)
+	if err != nil {
+		return true, err
+	}
+	return true, unmarshalExactly(inner, out)
+}
+
+func containsEncoding(values []asn1.RawValue, value asn1.RawValue) bool {
+	for _, v := range values {
+		if bytes.Equal(encodingOf(v), encodingOf(value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the values that `item` asserts.
+func filterItemValues(item *FilterNode) []asn1.RawValue {
+	switch item.Kind {
+	case FilterKindSubstrings:
+		values := make([]asn1.RawValue, 0, len(item.Substrings))
+		for _, s := range item.Substrings {
+			if s.Kind != SubstringControl {
+				values = append(values, s.Value)
+			}
+		}
+		return values
+	case FilterKindPresent, FilterKindContextPresent:
+		return nil
+	}
+	return []asn1.RawValue{item.Value}
+}
+
+func (c *SearchRuleChecker) requestAttributeFor(inputs []RequestAttribute, attrType AttributeType) *RequestAttribute {
+	for i := range inputs {
+		if inputs[i].AttributeType.Equal(attrType) {
+			return &inputs[i]
+		}
+	}
+	for i := range inputs {
+		if inputs[i].IncludeSubtypes && c.schema().IsSubtypeOf(attrType.String(), inputs[i].AttributeType.String()) {
+			return &inputs[i]
+		}
+	}
+	return nil
+}
+
+func (c *SearchRuleChecker) checkFilterItem(rule *SearchRule, inputs []RequestAttribute, item *FilterNode) ([]SearchRuleViolation, error) {
+	input := c.requestAttributeFor(inputs, item.Type)
+	if input == nil {
+		return []SearchRuleViolation{{Rule: rule, Problem: Id_pr_searchAttributeViolation, AttributeType: item.Type}}, nil
+	}
+	var violations []SearchRuleViolation
+	var selected []asn1.RawValue
+	restricted, err := decodeOptionalSequenceOf(input.SelectedValues, &selected)
+	if err != nil {
+		return nil, err
+	}
+	if restricted {
+		for _, value := range filterItemValues(item) {
+			if !containsEncoding(selected, value) {
+				violations = append(violations, SearchRuleViolation{Rule: rule, Problem: Id_pr_searchValueNotAllowed, AttributeType: item.Type})
+				break
+			}
+		}
+	}
+	var profiles []ContextProfile
+	restricted, err = decodeOptionalSequenceOf(input.Contexts, &profiles)
+	if err != nil {
+		return nil, err
+	}
+	if !restricted {
+		return violations, nil
+	}
+	for _, assertion := range item.Contexts {
+		var profile *ContextProfile
+		for i := range profiles {
+			if profiles[i].ContextType.Equal(assertion.ContextType) {
+				profile = &profiles[i]
+			}
+		}
+		if profile == nil {
+			violations = append(violations, SearchRuleViolation{Rule: rule, Problem: Id_pr_searchContextViolation, AttributeType: item.Type, ContextType: assertion.ContextType})
+			continue
+		}
+		if len(profile.ContextValue) == 0 {
+			continue
+		}
+		for _, value := range assertion.ContextValues {
+			if !containsEncoding(profile.ContextValue, value) {
+				violations = append(violations, SearchRuleViolation{Rule: rule, Problem: Id_pr_searchContextValueViolation, AttributeType: item.Type, ContextType: assertion.ContextType})
+				break
+			}
+		}
+	}
+	return violations, nil
+}
+
+// Returns the reasons that a search does not comply with `rule`.
+func (c *SearchRuleChecker) violations(rule *SearchRule, arg *SearchArgumentData, filter *FilterNode) ([]SearchRuleViolation, error) {
+	var violations []SearchRuleViolation
+	var inputs []RequestAttribute
+	restricted, err := decodeOptionalSequenceOf(rule.InputAttributeTypes, &inputs)
+	if err != nil {
+		return nil, err
+	}
+	var present []AttributeType
+	var itemErr error
+	walkFilterItems(filter, func(item *FilterNode) {
+		if itemErr != nil {
+			return
+		}
+		if item.Type == nil {
+			// An extensibleMatch without a type matches any attribute.
+			if restricted {
+				violations = append(violations, SearchRuleViolation{Rule: rule, Problem: Id_pr_searchAttributeViolation})
+			}
+			return
+		}
+		present = append(present, item.Type)
+		if restricted {
+			var itemViolations []SearchRuleViolation
+			itemViolations, itemErr = c.checkFilterItem(rule, inputs, item)
+			violations = append(violations, itemViolations...)
+		}
+	})
+	if itemErr != nil {
+		return nil, itemErr
+	}
+
+	combination, err := decodeAttributeCombination(rule.AttributeCombination)
+	if err != nil {
+		return nil, err
+	}
+	isPresent := func(attrType AttributeType) bool {
+		for _, t := range present {
+			if t.Equal(attrType) || c.schema().IsSubtypeOf(t.String(), attrType.String()) {
+				return true
+			}
+		}
+		return false
+	}
+	missing := false
+	for _, attrType := range combination.required() {
+		if !isPresent(attrType) {
+			missing = true
+			violations = append(violations, SearchRuleViolation{Rule: rule, Problem: Id_pr_missingSearchAttribute, AttributeType: attrType})
+		}
+	}
+	if !missing && !combination.satisfiedBy(isPresent) {
+		violations = append(violations, SearchRuleViolation{Rule: rule, Problem: Id_pr_searchAttributeCombinationViolation})
+	}
+
+	// An imposed subset replaces the requested one, so the requested one does
+	// not need to be allowed.
+	if rule.ImposedSubset == 0 && rule.AllowedSubset.BitLength > 0 && rule.AllowedSubset.At(arg.Subset) == 0 {
+		violations = append(violations, SearchRuleViolation{Rule: rule, Problem: Id_pr_searchSubsetViolation})
+	}
+	return violations, nil
+}
+
+// Returns `b` with bit `i` set to `value`, without trailing zero bits.
+func withBit(b asn1.BitString, i int, value bool) asn1.BitString {
+	length := max(b.BitLength, i+1)
+	result := asn1.BitString{Bytes: make([]byte, (length+7)/8), BitLength: length}
+	copy(result.Bytes, b.Bytes)
+	if value {
+		result.Bytes[i/8] |= 0x80 >> (i % 8)
+	} else {
+		result.Bytes[i/8] &^= 0x80 >> (i % 8)
+	}
+	for result.BitLength > 0 && result.At(result.BitLength-1) == 0 {
+		result.BitLength--
+	}
+	result.Bytes = result.Bytes[:(result.BitLength+7)/8]
+	return result
+}
+
+// Returns the requested control options, or the default ones if none were
+// requested, with the mandatory options set as they are in the defaults.
+func effectiveControlOptions(requested, defaults, mandatory asn1.BitString) asn1.BitString {
+	effective := requested
+	if effective.BitLength == 0 {
+		effective = defaults
+	}
+	for i := 0; i < mandatory.BitLength; i++ {
+		if mandatory.At(i) == 1 {
+			effective = withBit(effective, i, defaults.At(i) == 1)
+		}
+	}
+	return effective
+}
+
+func effectiveSearch(rule *SearchRule, arg *SearchArgumentData) *SearchArgumentData {
+	effective := *arg
+	effective.ServiceControls.Options = effectiveControlOptions(
+		arg.ServiceControls.Options,
+		rule.DefaultControls.ServiceControls,
+		rule.MandatoryControls.ServiceControls)
+	effective.SearchControlOptions = effectiveControlOptions(
+		arg.SearchControlOptions,
+		rule.DefaultControls.SearchOptions,
+		rule.MandatoryControls.SearchOptions)
+	effective.HierarchySelections = effectiveControlOptions(
+		arg.HierarchySelections,
+		rule.DefaultControls.HierarchyOptions,
+		rule.MandatoryControls.HierarchyOptions)
+	if rule.ImposedSubset != 0 {
+		effective.Subset = SearchArgumentData_subset(rule.ImposedSubset)
+	}
+	if rule.EntryLimit.Max > 0 {
+		if effective.ServiceControls.SizeLimit == 0 {
+			effective.ServiceControls.SizeLimit = rule.EntryLimit.Default
+		}
+		effective.ServiceControls.SizeLimit = min(effective.ServiceControls.SizeLimit, rule.EntryLimit.Max)
+	}
+	return &effective
+}
+
+// Returns true if `rule` applies to searches with the service type and user
+// class of `controls`. A rule without a service type or user class applies
+// to searches with any.
+func searchRuleApplies(rule *SearchRule, controls *ServiceControls) bool {
+	if len(rule.ServiceType) > 0 && !rule.ServiceType.Equal(controls.ServiceType) {
+		return false
+	}
+	return rule.UserClass == 0 || rule.UserClass == controls.UserClass
+}
+
+// Checks the search `arg` against `rules`, the search rules in effect for
+// the service-specific administrative area in which it would be performed.
+//
+// The search rules that apply to the search are those with its service type
+// and user class, and the first of them that the search complies with
+// governs it. A search complies with a rule if every attribute type in its
+// filter is one of the rule's input attribute types (or a subtype of one
+// that includes subtypes), the values and contexts asserted for them are
+// among those selected, the filter satisfies the rule's attribute
+// combination, and the subset is allowed. A rule without input attribute
+// types permits any filter that satisfies its attribute combination, but
+// one with an empty sequence of them only permits empty filters.
+//
+// An imposed subset of baseObject cannot be distinguished from the absence
+// of an imposed subset in [SearchRule], so it is neither imposed nor exempts
+// the search from the allowed subset.
+func (c *SearchRuleChecker) Check(rules []SearchRule, arg *SearchArgumentData) (*SearchRuleCheck, error) {
+	filter, err := searchFilter(arg)
+	if err != nil {
+		return nil, err
+	}
+	result := &SearchRuleCheck{}
+	applicable := false
+	for i := range rules {
+		rule := &rules[i]
+		if !searchRuleApplies(rule, &arg.ServiceControls) {
+			continue
+		}
+		applicable = true
+		violations, err := c.violations(rule, arg, filter)
+		if err != nil {
+			return nil, fmt.Errorf("search rule %d of %s: %w", rule.Id, rule.DmdId, err)
+		}
+		if len(violations) == 0 {
+			return &SearchRuleCheck{Governing: rule, Effective: effectiveSearch(rule, arg)}, nil
+		}
+		result.Violations = append(result.Violations, violations...)
+	}
+	if !applicable {
+		result.Violations = []SearchRuleViolation{{Problem: Id_pr_unidentifiedOperation}}
+	}
+	return result, nil
+}
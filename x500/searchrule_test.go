@@ -0,0 +1,188 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func mustMarshalFilter(t *testing.T, s string) Filter {
+	t.Helper()
+	filter, err := mustParseFilter(t, s).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filter
+}
+
+func testAttributeCombination(t *testing.T, tag int, types ...AttributeType) AttributeCombination {
+	operands := make([][]byte, 0, len(types))
+	for _, attrType := range types {
+		operands = append(operands, mustContextTagged(t, 0, mustMarshalValue(t, attrType, "").FullBytes))
+	}
+	combination, err := contextTagged(tag, mustSequenceOf(t, operands...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return combination
+}
+
+func TestSearchRuleCheck(t *testing.T) {
+	rules := []SearchRule{
+		{
+			Id:          1,
+			DmdId:       asn1.ObjectIdentifier{1, 2, 3},
+			ServiceType: asn1.ObjectIdentifier{1, 2, 3, 4},
+		},
+		{
+			Id:    2,
+			DmdId: asn1.ObjectIdentifier{1, 2, 3},
+			InputAttributeTypes: mustMarshalValue(t, []RequestAttribute{
+				{AttributeType: Id_at_commonName},
+				{AttributeType: Id_at_surname},
+			}, ""),
+			AttributeCombination: testAttributeCombination(t, 1, Id_at_commonName, Id_at_surname),
+		},
+		{
+			Id:    3,
+			DmdId: asn1.ObjectIdentifier{1, 2, 3},
+			InputAttributeTypes: mustMarshalValue(t, []RequestAttribute{
+				{AttributeType: Id_at_commonName},
+			}, ""),
+			DefaultControls: ControlOptions{
+				SearchOptions: withBit(asn1.BitString{}, SearchControlOptions_SearchAliases, true),
+			},
+			MandatoryControls: ControlOptions{
+				ServiceControls: withBit(asn1.BitString{}, ServiceControlOptions_ChainingProhibited, true),
+			},
+			ImposedSubset: ImposedSubset_OneLevel,
+			EntryLimit:    EntryLimit{Default: 10, Max: 100},
+		},
+	}
+	arg := &SearchArgumentData{
+		Filter:          mustMarshalFilter(t, "(cn=John)"),
+		ServiceControls: ServiceControls{SizeLimit: 500},
+	}
+	arg.ServiceControls.Options = withBit(asn1.BitString{}, ServiceControlOptions_ChainingProhibited, true)
+	result, err := (&SearchRuleChecker{}).Check(rules, arg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if result.Governing == nil || result.Governing.Id != 3 {
+		t.Errorf("expected search rule 3 to govern, but got %+v", result)
+		return
+	}
+	effective := result.Effective
+	if effective.Subset != SearchArgumentData_subset_OneLevel {
+		t.Errorf("expected the imposed subset, but got %d", effective.Subset)
+	}
+	if effective.ServiceControls.SizeLimit != 100 {
+		t.Errorf("expected the size limit to be limited to 100, but got %d", effective.ServiceControls.SizeLimit)
+	}
+	if effective.ServiceControls.Options.At(ServiceControlOptions_ChainingProhibited) != 0 {
+		t.Error("expected the mandatory service control to be unset")
+	}
+	if effective.SearchControlOptions.At(SearchControlOptions_SearchAliases) != 1 {
+		t.Error("expected the default search control options")
+	}
+	if arg.ServiceControls.Options.At(ServiceControlOptions_ChainingProhibited) != 1 || arg.Subset != 0 {
+		t.Error("expected the search argument to be unchanged")
+	}
+
+	arg = &SearchArgumentData{Filter: mustMarshalFilter(t, "(&(cn=John)(sn=Smith))")}
+	result, err = (&SearchRuleChecker{}).Check(rules, arg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if result.Governing == nil || result.Governing.Id != 2 {
+		t.Errorf("expected search rule 2 to govern, but got %+v", result)
+	}
+	if result.Effective.ServiceControls.SizeLimit != 0 {
+		t.Errorf("expected no size limit, but got %d", result.Effective.ServiceControls.SizeLimit)
+	}
+}
+
+func TestSearchRuleViolations(t *testing.T) {
+	john := mustParseFilter(t, "(cn=John)").Value
+	cases := []struct {
+		rule    SearchRule
+		filter  *FilterNode
+		subset  SearchArgumentData_subset
+		problem asn1.ObjectIdentifier
+	}{
+		{
+			SearchRule{InputAttributeTypes: mustMarshalValue(t, []RequestAttribute{{AttributeType: Id_at_commonName}}, "")},
+			mustParseFilter(t, "(sn=Smith)"), 0, Id_pr_searchAttributeViolation,
+		},
+		{
+			SearchRule{InputAttributeTypes: mustMarshalValue(t, []RequestAttribute{}, "")},
+			mustParseFilter(t, "(cn=John)"), 0, Id_pr_searchAttributeViolation,
+		},
+		{
+			SearchRule{InputAttributeTypes: mustMarshalValue(t, []RequestAttribute{{
+				AttributeType:  Id_at_commonName,
+				SelectedValues: asn1.RawValue{FullBytes: mustContextTagged(t, 1, mustMarshalValue(t, []asn1.RawValue{john}, "").FullBytes)},
+			}}, "")},
+			mustParseFilter(t, "(cn=Jack)"), 0, Id_pr_searchValueNotAllowed,
+		},
+		{
+			SearchRule{InputAttributeTypes: mustMarshalValue(t, []RequestAttribute{{
+				AttributeType: Id_at_commonName,
+				Contexts:      asn1.RawValue{FullBytes: mustContextTagged(t, 3, mustMarshalValue(t, []ContextProfile{{ContextType: asn1.ObjectIdentifier{2, 5, 31, 1}}}, "").FullBytes)},
+			}}, "")},
+			&FilterNode{
+				Kind:     FilterKindEquality,
+				Type:     Id_at_commonName,
+				Value:    john,
+				Contexts: []ContextAssertion{{ContextType: Id_avc_language, ContextValues: []asn1.RawValue{mustMarshalValue(t, "en", "printable")}}},
+			}, 0, Id_pr_searchContextViolation,
+		},
+		{
+			SearchRule{AttributeCombination: testAttributeCombination(t, 1, Id_at_commonName, Id_at_surname)},
+			mustParseFilter(t, "(cn=John)"), 0, Id_pr_missingSearchAttribute,
+		},
+		{
+			SearchRule{AttributeCombination: testAttributeCombination(t, 2, Id_at_commonName, Id_at_surname)},
+			mustParseFilter(t, "(title=Boss)"), 0, Id_pr_searchAttributeCombinationViolation,
+		},
+		{
+			SearchRule{AllowedSubset: withBit(asn1.BitString{}, AllowedSubset_BaseObject, true)},
+			mustParseFilter(t, "(cn=John)"), SearchArgumentData_subset_WholeSubtree, Id_pr_searchSubsetViolation,
+		},
+	}
+	for _, c := range cases {
+		filter, err := c.filter.Marshal()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		arg := &SearchArgumentData{Filter: filter, Subset: c.subset}
+		result, err := (&SearchRuleChecker{}).Check([]SearchRule{c.rule}, arg)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if result.Governing != nil || len(result.Violations) != 1 || !result.Violations[0].Problem.Equal(c.problem) {
+			t.Errorf("%s: expected %s, but got %+v", c.filter, c.problem, result)
+		}
+	}
+
+	result, err := (&SearchRuleChecker{}).Check([]SearchRule{{UserClass: 2}}, &SearchArgumentData{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(result.Violations) != 1 || !result.Violations[0].Problem.Equal(Id_pr_unidentifiedOperation) {
+		t.Errorf("expected no search rule to apply, but got %+v", result)
+	}
+
+	violation := SearchRuleViolation{
+		Rule:          &SearchRule{Id: 4, DmdId: asn1.ObjectIdentifier{1, 2, 3}},
+		Problem:       Id_pr_missingSearchAttribute,
+		AttributeType: Id_at_surname,
+	}
+	if s := violation.Error(); s != "search rule 4 of 1.2.3: attribute type required in filter (2.5.4.4)" {
+		t.Errorf("unexpected error message %q", s)
+	}
+}
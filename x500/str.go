@@ -197,6 +197,7 @@ func DirectoryStringToString(ds asn1.RawValue) (s string, err error) {
 		if len(rest) > 0 {
 			return s, errors.New("trailing bytes after directorystring")
 		}
+		return s, nil
 	}
 	if len(ds.Bytes) == 0 {
 		return "", nil
@@ -27,7 +27,11 @@ func TestDirectoryString(t *testing.T) {
 		Bytes: []byte("Hello"),
 	}
 
-	ds := []asn1.RawValue{ds1, ds2, ds3, ds4, ds5}
+	ds6 := asn1.RawValue{}
+	ia5, _ := asn1.MarshalWithParams("Hello", "ia5")
+	asn1.Unmarshal(ia5, &ds6)
+
+	ds := []asn1.RawValue{ds1, ds2, ds3, ds4, ds5, ds6}
 
 	for i, v := range ds {
 		str, err := DirectoryStringToString(v)
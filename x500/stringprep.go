@@ -0,0 +1,128 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Returns true if `r` is mapped to nothing by the string preparation of ITU-T
+// Recommendation X.520, Section 7.2. These are mostly control and formatting
+// characters, variation selectors and the zero width space.
+func mappedToNothing(r rune) bool {
+	switch {
+	case r <= 0x08, r >= 0x0E && r <= 0x1F, r >= 0x7F && r <= 0x84, r >= 0x86 && r <= 0x9F:
+		return true
+	case r == 0xAD, r == 0x034F, r == 0x06DD, r == 0x070F, r == 0x1806:
+		return true
+	case r >= 0x180B && r <= 0x180E:
+		return true
+	case r >= 0x200B && r <= 0x200F, r >= 0x202A && r <= 0x202E:
+		return true
+	case r >= 0x2060 && r <= 0x2063, r >= 0x206A && r <= 0x206F:
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r == 0xFEFF, r >= 0xFFF9 && r <= 0xFFFC:
+		return true
+	case r >= 0x1D173 && r <= 0x1D17A, r == 0xE0001, r >= 0xE0020 && r <= 0xE007F:
+		return true
+	}
+	return false
+}
+
+// Returns true if `r` is mapped to a space by the string preparation of ITU-T
+// Recommendation X.520, Section 7.2.
+func mappedToSpace(r rune) bool {
+	return (r >= 0x09 && r <= 0x0D) || r == 0x85 || unicode.Is(unicode.Zs, r)
+}
+
+// Returns true if `r` is prohibited by the string preparation of ITU-T
+// Recommendation X.520, Section 7.4: unassigned code points, private use
+// characters, non-characters, surrogates, characters that change display
+// properties, and the replacement character.
+func prohibited(r rune) bool {
+	switch {
+	case r == unicode.ReplacementChar, r == 0x0340, r == 0x0341:
+		return true
+	case r >= 0xFDD0 && r <= 0xFDEF, r&0xFFFE == 0xFFFE:
+		return true
+	case unicode.Is(unicode.Co, r), unicode.Is(unicode.Cs, r):
+		return true
+	}
+	return !unicode.In(r, unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.Z, unicode.Cc, unicode.Cf)
+}
+
+// Performs the map, normalize, prohibit and check bidi steps of the string
+// preparation of ITU-T Recommendation X.520, Section 7. If `caseFold` is
+// true, characters are case folded, as they are for caseIgnoreMatch and the
+// other case-insensitive matching rules. Insignificant spaces are not
+// removed.
+func prepareString(s string, caseFold bool) (string, error) {
+	mapped := strings.Map(func(r rune) rune {
+		if mappedToNothing(r) {
+			return -1
+		}
+		if mappedToSpace(r) {
+			return ' '
+		}
+		return r
+	}, s)
+	if caseFold {
+		mapped = cases.Fold().String(mapped)
+	}
+	normalized := norm.NFKC.String(mapped)
+	for _, r := range normalized {
+		if prohibited(r) {
+			return "", fmt.Errorf("prohibited character U+%04X", r)
+		}
+	}
+	// Bidirectional characters are ignored by the check bidi step, so there
+	// is nothing more to do.
+	return normalized, nil
+}
+
+// Prepares `s` for comparison under caseIgnoreMatch, if `caseFold` is true,
+// or caseExactMatch otherwise, according to ITU-T Recommendation X.520,
+// Section 7. Characters are mapped, normalized to Unicode Normalization Form
+// KC, and checked for prohibited characters; leading and trailing spaces are
+// removed and internal sequences of spaces are collapsed to one. Two strings
+// match under these rules if and only if their prepared forms are equal.
+func PrepareString(s string, caseFold bool) (string, error) {
+	prepared, err := prepareString(s, caseFold)
+	if err != nil {
+		return "", err
+	}
+	return collapseSpaces(prepared), nil
+}
+
+// Transcodes `value`, which may be any of the string types, and prepares it
+// as a substring assertion component: internal sequences of spaces are
+// collapsed to one, but spaces at the ends are significant, because they
+// separate the component from the rest of the value.
+func prepareSubstring(value asn1.RawValue, caseFold bool) (string, error) {
+	s, err := DirectoryStringToString(value)
+	if err != nil {
+		return "", err
+	}
+	prepared, err := prepareString(s, caseFold)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	space := false
+	for _, r := range prepared {
+		if r == ' ' {
+			if !space {
+				b.WriteRune(r)
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,52 @@
+package x500
+
+import (
+	"testing"
+)
+
+func TestPrepareString(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		caseFold bool
+		expected string
+	}{
+		{"  Hello\tWorld  ", false, "Hello World"},
+		{"HELLO WORLD", true, "hello world"},
+		{"Straße", true, "strasse"},
+		{"soft\u00adhyphen", false, "softhyphen"},
+		{"zero\u200bwidth", false, "zerowidth"},
+		{"non\u00a0breaking", false, "non breaking"},
+		{"\ufb01", false, "fi"},
+		{"e\u0301", false, "é"},
+		{"", true, ""},
+	} {
+		prepared, err := PrepareString(tc.input, tc.caseFold)
+		if err != nil {
+			t.Errorf("%q: %v", tc.input, err)
+			continue
+		}
+		if prepared != tc.expected {
+			t.Errorf("%q was prepared as %q, not %q", tc.input, prepared, tc.expected)
+		}
+	}
+}
+
+func TestPrepareStringProhibited(t *testing.T) {
+	for _, s := range []string{"private\ue000use", "non\ufffecharacter", "replace\ufffdment", "unassigned\u0378"} {
+		if _, err := PrepareString(s, false); err == nil {
+			t.Errorf("%q should have been prohibited", s)
+		}
+	}
+}
+
+func TestPrepareSubstring(t *testing.T) {
+	prepared, err := prepareSubstring(NewDirectoryString(" John   "), true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if prepared != " john " {
+		t.Errorf("spaces at the ends of a substring should be kept, got %q", prepared)
+		return
+	}
+}
@@ -0,0 +1,360 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"sort"
+)
+
+// A subentry, such as one returned by a list or search with the `subentries`
+// service control. Its administrative point is its immediate superior.
+type NamedSubentry struct {
+	Name       DistinguishedName
+	Attributes []Attribute
+}
+
+// An administrative point and the values of its administrativeRole
+// attribute.
+type AdministrativePoint struct {
+	Name  DistinguishedName
+	Roles []asn1.ObjectIdentifier
+}
+
+// Returns the administrative point named `name` whose attributes are `attrs`,
+// or nil if `attrs` has no administrativeRole attribute.
+func NewAdministrativePoint(name DistinguishedName, attrs []Attribute) (*AdministrativePoint, error) {
+	for i := range attrs {
+		attr := &attrs[i]
+		if !attr.Type.Equal(Id_oa_administrativeRole) {
+			continue
+		}
+		point := &AdministrativePoint{Name: name}
+		for j := 0; j < attr.Len(); j++ {
+			var role asn1.ObjectIdentifier
+			if err := unmarshalExactly(*attr.Get(j), &role); err != nil {
+				return nil, err
+			}
+			point.Roles = append(point.Roles, role)
+		}
+		return point, nil
+	}
+	return nil, nil
+}
+
+func (p *AdministrativePoint) hasRole(role asn1.ObjectIdentifier) bool {
+	for _, r := range p.Roles {
+		if r.Equal(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// The subentries that apply to an entry, and the collective attributes that
+// they give it, as computed by [SubentryResolver.Resolve].
+type SubentryResolution struct {
+	// The collective attributes of the entry, which are not excluded by its
+	// collectiveExclusions attribute.
+	CollectiveAttributes []Attribute
+
+	// The subentries of each aspect of administration that apply to the
+	// entry, which are listed by the operational attribute named in the
+	// comment.
+	AccessControl       []*NamedSubentry // accessControlSubentryList
+	CollectiveAttribute []*NamedSubentry // collectiveAttributeSubentryList
+	Subschema           []*NamedSubentry // subschemaSubentryList
+	ContextDefault      []*NamedSubentry // contextDefaultSubentryList
+	ServiceAdmin        []*NamedSubentry // serviceAdminSubentryList
+	PwdAdmin            []*NamedSubentry // pwdAdminSubentryList
+}
+
+// An aspect of administration, as described in ITU-T Recommendation X.501,
+// Section 10.
+type subentryAspect struct {
+	specificRole asn1.ObjectIdentifier
+	innerRole    asn1.ObjectIdentifier // nil if the aspect has no inner areas
+	objectClass  asn1.ObjectIdentifier // of the subentries
+	listType     AttributeType
+	subentries   func(*SubentryResolution) *[]*NamedSubentry
+}
+
+var subentryAspects = []subentryAspect{
+	{
+		Id_ar_accessControlSpecificArea, Id_ar_accessControlInnerArea,
+		Id_sc_accessControlSubentry, Id_oa_accessControlSubentryList,
+		func(r *SubentryResolution) *[]*NamedSubentry { return &r.AccessControl },
+	},
+	{
+		Id_ar_collectiveAttributeSpecificArea, Id_ar_collectiveAttributeInnerArea,
+		Id_sc_collectiveAttributeSubentry, Id_oa_collectiveAttributeSubentryList,
+		func(r *SubentryResolution) *[]*NamedSubentry { return &r.CollectiveAttribute },
+	},
+	{
+		Id_ar_subschemaAdminSpecificArea, nil,
+		Id_soc_subschema, Id_oa_subschemaSubentryList,
+		func(r *SubentryResolution) *[]*NamedSubentry { return &r.Subschema },
+	},
+	{
+		Id_ar_contextDefaultSpecificArea, nil,
+		Id_sc_contextAssertionSubentry, Id_oa_contextDefaultSubentryList,
+		func(r *SubentryResolution) *[]*NamedSubentry { return &r.ContextDefault },
+	},
+	{
+		Id_ar_serviceSpecificArea, nil,
+		Id_sc_serviceAdminSubentry, Id_oa_serviceAdminSubentryList,
+		func(r *SubentryResolution) *[]*NamedSubentry { return &r.ServiceAdmin },
+	},
+	{
+		Id_ar_pwdAdminSpecificArea, nil,
+		Id_sc_pwdAdminSubentry, Id_oa_pwdAdminSubentryList,
+		func(r *SubentryResolution) *[]*NamedSubentry { return &r.PwdAdmin },
+	},
+}
+
+// Returns the operational attributes that list the subentries in `r`, such as
+// accessControlSubentryList, followed by its collective attributes: that is,
+// the attributes that a DSA would add to the entry when it is read. Lists of
+// no subentries are omitted.
+func (r *SubentryResolution) Attributes() ([]Attribute, error) {
+	var attrs []Attribute
+	for _, aspect := range subentryAspects {
+		subentries := *aspect.subentries(r)
+		if len(subentries) == 0 {
+			continue
+		}
+		attr := Attribute{Type: aspect.listType}
+		for _, subentry := range subentries {
+			encoded, err := asn1.Marshal(subentry.Name)
+			if err != nil {
+				return nil, err
+			}
+			attr.Values = append(attr.Values, asn1.RawValue{FullBytes: encoded})
+		}
+		attrs = append(attrs, attr)
+	}
+	return append(attrs, r.CollectiveAttributes...), nil
+}
+
+// Determines which subentries apply to an entry and which collective
+// attributes they give it, as described in ITU-T Recommendation X.501,
+// Sections 10 to 12, without a DSA. Attribute types and names are resolved
+// and compared using `Schema` and `MatchingRules`; if either is nil,
+// [DefaultSchemaRegistry] or [DefaultMatchingRuleRegistry] is used. A nil
+// *SubentryResolver may be used.
+type SubentryResolver struct {
+	Schema        *SchemaRegistry
+	MatchingRules *MatchingRuleRegistry
+}
+
+func (r *SubentryResolver) schema() *SchemaRegistry {
+	if r == nil || r.Schema == nil {
+		return DefaultSchemaRegistry()
+	}
+	return r.Schema
+}
+
+func (r *SubentryResolver) dnMatcher() *DNMatcher {
+	if r == nil {
+		return nil
+	}
+	return &DNMatcher{Schema: r.Schema, MatchingRules: r.MatchingRules}
+}
+
+// Returns the administrative points among `points`, which are those at or
+// above an entry, nearest first, whose areas of `aspect` include the entry:
+// the nearest specific administrative point, which may be autonomous, and the
+// inner administrative points between it and the entry. If there is no
+// specific administrative point, no administrative points apply.
+func (r *SubentryResolver) areasOf(aspect *subentryAspect, points []*AdministrativePoint) []*AdministrativePoint {
+	var areas []*AdministrativePoint
+	for _, point := range points {
+		switch {
+		case point.hasRole(aspect.specificRole) || point.hasRole(Id_ar_autonomousArea):
+			return append(areas, point)
+		case aspect.innerRole != nil && point.hasRole(aspect.innerRole):
+			areas = append(areas, point)
+		}
+	}
+	return nil
+}
+
+// Returns true if the entry named `entry`, whose objectClass attribute has
+// the values `objectClasses`, is within any of the subtreeSpecification
+// values of `subentry`, whose administrative point is named `adminPoint`. A
+// subentry with no subtreeSpecification applies to its whole administrative
+// area.
+func (r *SubentryResolver) inScope(subentry *NamedSubentry, adminPoint, entry DistinguishedName, objectClasses []asn1.ObjectIdentifier) (bool, error) {
+	m := r.dnMatcher()
+	for i := range subentry.Attributes {
+		attr := &subentry.Attributes[i]
+		if !attr.Type.Equal(Id_oa_subtreeSpecification) {
+			continue
+		}
+		for j := 0; j < attr.Len(); j++ {
+			spec := &SubtreeSpecificationNode{}
+			if err := spec.UnmarshalRawValue(*attr.Get(j)); err != nil {
+				return false, err
+			}
+			if m.SubtreeContains(spec, adminPoint, entry, objectClasses) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return m.SubtreeContains(&SubtreeSpecificationNode{}, adminPoint, entry, objectClasses), nil
+}
+
+// Returns the values of the collectiveExclusions attribute among `attrs`.
+func collectiveExclusionsOf(attrs []Attribute) ([]asn1.ObjectIdentifier, error) {
+	var exclusions []asn1.ObjectIdentifier
+	for i := range attrs {
+		attr := &attrs[i]
+		if !attr.Type.Equal(Id_oa_collectiveExclusions) {
+			continue
+		}
+		for j := 0; j < attr.Len(); j++ {
+			var exclusion asn1.ObjectIdentifier
+			if err := unmarshalExactly(*attr.Get(j), &exclusion); err != nil {
+				return nil, err
+			}
+			exclusions = append(exclusions, exclusion)
+		}
+	}
+	return exclusions, nil
+}
+
+// Adds the values of `attr` that `attrs` does not already have to the
+// attribute of the same type in `attrs`, if there is one, or adds `attr`.
+func mergeAttribute(attrs []Attribute, attr *Attribute) []Attribute {
+	for i := range attrs {
+		existing := &attrs[i]
+		if !existing.Type.Equal(attr.Type) {
+			continue
+		}
+		has := func(v asn1.RawValue) bool {
+			for j := 0; j < existing.Len(); j++ {
+				if bytes.Equal(encodingOf(*existing.Get(j)), encodingOf(v)) {
+					return true
+				}
+			}
+			return false
+		}
+		for _, v := range attr.Values {
+			if !has(v) {
+				existing.Values = append(existing.Values, v)
+			}
+		}
+		for _, v := range attr.ValuesWithContext {
+			if !has(v.Value) {
+				existing.ValuesWithContext = append(existing.ValuesWithContext, v)
+			}
+		}
+		return attrs
+	}
+	return append(attrs, Attribute{
+		Type:              attr.Type,
+		Values:            append([]asn1.RawValue(nil), attr.Values...),
+		ValuesWithContext: append([]Attribute_valuesWithContext_Item(nil), attr.ValuesWithContext...),
+	})
+}
+
+// Returns the collective attributes of `subentries` that are not excluded by
+// `exclusions`. An attribute is collective if `Schema` says that it or one of
+// its supertypes is. Values of the same attribute type from several
+// subentries are combined, omitting identical values.
+func (r *SubentryResolver) collectiveAttributes(subentries []*NamedSubentry, exclusions []asn1.ObjectIdentifier) []Attribute {
+	schema := r.schema()
+	var attrs []Attribute
+	for _, exclusion := range exclusions {
+		if exclusion.Equal(Id_oa_excludeAllCollectiveAttributes) {
+			return nil
+		}
+	}
+	for _, subentry := range subentries {
+	attributes:
+		for i := range subentry.Attributes {
+			attr := &subentry.Attributes[i]
+			at, err := schema.ResolveAttributeType(attr.Type.String())
+			if err != nil || !at.Collective {
+				continue
+			}
+			for _, exclusion := range exclusions {
+				if schema.IsSubtypeOf(attr.Type.String(), exclusion.String()) {
+					continue attributes
+				}
+			}
+			attrs = mergeAttribute(attrs, attr)
+		}
+	}
+	return attrs
+}
+
+// Determines which of `subentries` apply to the entry named `entry`, whose
+// attributes are `attrs`, and the collective attributes that they give it.
+// `points` must include every administrative point at or above the entry
+// whose subentries may apply to it, including the entry itself if it is one;
+// subentries whose administrative points are not among `points` are ignored.
+//
+// For each aspect of administration, the subentries that apply are those of
+// the nearest specific administrative point, which may be autonomous, and of
+// any inner administrative points below it, whose object class is that of the
+// aspect, such as accessControlSubentry, and whose subtree specification
+// includes the entry. Subentries do not apply to other subentries.
+func (r *SubentryResolver) Resolve(entry DistinguishedName, attrs []Attribute, points []AdministrativePoint, subentries []NamedSubentry) (*SubentryResolution, error) {
+	resolution := &SubentryResolution{}
+	objectClasses := objectClassesOf(attrs)
+	for _, oc := range objectClasses {
+		if oc.Equal(Id_sc_subentry) {
+			return resolution, nil
+		}
+	}
+	m := r.dnMatcher()
+	var superiors []*AdministrativePoint
+	for i := range points {
+		if m.DNEqual(points[i].Name, entry) || m.DNIsAncestor(points[i].Name, entry) {
+			superiors = append(superiors, &points[i])
+		}
+	}
+	sort.SliceStable(superiors, func(i, j int) bool {
+		return len(superiors[i].Name) > len(superiors[j].Name)
+	})
+	for _, aspect := range subentryAspects {
+		areas := r.areasOf(&aspect, superiors)
+		applicable := aspect.subentries(resolution)
+		for i := range subentries {
+			subentry := &subentries[i]
+			if len(subentry.Name) == 0 || !hasObjectClass(subentry.Attributes, aspect.objectClass) {
+				continue
+			}
+			adminPoint := subentry.Name[:len(subentry.Name)-1]
+			for _, area := range areas {
+				if !m.DNEqual(area.Name, adminPoint) {
+					continue
+				}
+				ok, err := r.inScope(subentry, adminPoint, entry, objectClasses)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					*applicable = append(*applicable, subentry)
+				}
+				break
+			}
+		}
+	}
+	exclusions, err := collectiveExclusionsOf(attrs)
+	if err != nil {
+		return nil, err
+	}
+	resolution.CollectiveAttributes = r.collectiveAttributes(resolution.CollectiveAttribute, exclusions)
+	return resolution, nil
+}
+
+func hasObjectClass(attrs []Attribute, objectClass asn1.ObjectIdentifier) bool {
+	for _, oc := range objectClassesOf(attrs) {
+		if oc.Equal(objectClass) {
+			return true
+		}
+	}
+	return false
+}
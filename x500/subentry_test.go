@@ -0,0 +1,176 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func testObjectClasses(t *testing.T, classes ...asn1.ObjectIdentifier) Attribute {
+	attr := Attribute{Type: Id_at_objectClass}
+	for _, oc := range classes {
+		attr.Values = append(attr.Values, mustMarshalValue(t, oc, ""))
+	}
+	return attr
+}
+
+func testSubentries(t *testing.T) []NamedSubentry {
+	devices, err := (SubtreeSpecificationNode{Base: mustParseDN(t, "OU=Devices")}).MarshalRawValue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []NamedSubentry{
+		{
+			Name: mustParseDN(t, "CN=Locality,O=Example,C=US"),
+			Attributes: []Attribute{
+				testObjectClasses(t, Id_oc_top, Id_sc_subentry, Id_sc_collectiveAttributeSubentry),
+				{Type: Id_at_collectiveLocalityName, Values: []asn1.RawValue{mustMarshalValue(t, "Springfield", "utf8")}},
+				{Type: Id_at_collectiveOrganizationName, Values: []asn1.RawValue{mustMarshalValue(t, "Example", "utf8")}},
+			},
+		},
+		{
+			Name: mustParseDN(t, "CN=People,OU=People,O=Example,C=US"),
+			Attributes: []Attribute{
+				testObjectClasses(t, Id_oc_top, Id_sc_subentry, Id_sc_collectiveAttributeSubentry),
+				{Type: Id_at_collectiveLocalityName, Values: []asn1.RawValue{
+					mustMarshalValue(t, "Springfield", "utf8"),
+					mustMarshalValue(t, "Shelbyville", "utf8"),
+				}},
+				// Not collective, so it is not given to entries.
+				{Type: Id_at_description, Values: []asn1.RawValue{mustMarshalValue(t, "People", "utf8")}},
+			},
+		},
+		{
+			Name: mustParseDN(t, "CN=Devices,O=Example,C=US"),
+			Attributes: []Attribute{
+				testObjectClasses(t, Id_oc_top, Id_sc_subentry, Id_sc_accessControlSubentry),
+				{Type: Id_oa_subtreeSpecification, Values: []asn1.RawValue{devices}},
+			},
+		},
+		{
+			// The people are not in an inner area for access control.
+			Name: mustParseDN(t, "CN=People ACI,OU=People,O=Example,C=US"),
+			Attributes: []Attribute{
+				testObjectClasses(t, Id_oc_top, Id_sc_subentry, Id_sc_accessControlSubentry),
+			},
+		},
+		{
+			Name: mustParseDN(t, "CN=Schema,O=Example,C=US"),
+			Attributes: []Attribute{
+				testObjectClasses(t, Id_oc_top, Id_sc_subentry, Id_soc_subschema),
+			},
+		},
+	}
+}
+
+func testAdministrativePoints(t *testing.T) []AdministrativePoint {
+	return []AdministrativePoint{
+		{Name: mustParseDN(t, "O=Example,C=US"), Roles: []asn1.ObjectIdentifier{Id_ar_autonomousArea}},
+		{Name: mustParseDN(t, "OU=People,O=Example,C=US"), Roles: []asn1.ObjectIdentifier{Id_ar_collectiveAttributeInnerArea}},
+		{Name: mustParseDN(t, "O=Other,C=US"), Roles: []asn1.ObjectIdentifier{Id_ar_autonomousArea}},
+	}
+}
+
+func TestSubentryResolverResolve(t *testing.T) {
+	alice := mustParseDN(t, "CN=Alice,OU=People,O=Example,C=US")
+	attrs := []Attribute{
+		testObjectClasses(t, Id_oc_top, Id_oc_person),
+		{Type: Id_oa_collectiveExclusions, Values: []asn1.RawValue{mustMarshalValue(t, Id_at_collectiveOrganizationName, "")}},
+	}
+	resolution, err := (&SubentryResolver{}).Resolve(alice, attrs, testAdministrativePoints(t), testSubentries(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(resolution.CollectiveAttribute) != 2 || len(resolution.AccessControl) != 0 || len(resolution.Subschema) != 1 {
+		t.Errorf("unexpected subentries: %+v", resolution)
+		return
+	}
+	if len(resolution.CollectiveAttributes) != 1 {
+		t.Errorf("expected only c-l, but got %+v", resolution.CollectiveAttributes)
+		return
+	}
+	if got := selectedStrings(t, &resolution.CollectiveAttributes[0]); len(got) != 2 || got[0] != "Springfield" || got[1] != "Shelbyville" {
+		t.Errorf("expected two localities, but got %v", got)
+		return
+	}
+	operational, err := resolution.Attributes()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(operational) != 3 || !operational[0].Type.Equal(Id_oa_collectiveAttributeSubentryList) || !operational[1].Type.Equal(Id_oa_subschemaSubentryList) {
+		t.Errorf("unexpected operational attributes: %+v", operational)
+		return
+	}
+	var schemaName DistinguishedName
+	if err := unmarshalExactly(operational[1].Values[0], &schemaName); err != nil || !DNEqual(schemaName, mustParseDN(t, "CN=Schema,O=Example,C=US")) {
+		t.Errorf("unexpected subschemaSubentryList: %v (%v)", schemaName, err)
+		return
+	}
+
+	// A device is outside the inner area, but within the access control
+	// subentry's subtree.
+	device := mustParseDN(t, "CN=Printer,OU=Devices,O=Example,C=US")
+	resolution, err = (&SubentryResolver{}).Resolve(device, nil, testAdministrativePoints(t), testSubentries(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(resolution.AccessControl) != 1 || len(resolution.CollectiveAttribute) != 1 || len(resolution.CollectiveAttributes) != 2 {
+		t.Errorf("unexpected resolution for a device: %+v", resolution)
+		return
+	}
+}
+
+func TestSubentryResolverExclusions(t *testing.T) {
+	alice := mustParseDN(t, "CN=Alice,OU=People,O=Example,C=US")
+	attrs := []Attribute{
+		{Type: Id_oa_collectiveExclusions, Values: []asn1.RawValue{mustMarshalValue(t, Id_oa_excludeAllCollectiveAttributes, "")}},
+	}
+	resolution, err := (&SubentryResolver{}).Resolve(alice, attrs, testAdministrativePoints(t), testSubentries(t))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(resolution.CollectiveAttribute) != 2 || len(resolution.CollectiveAttributes) != 0 {
+		t.Errorf("expected all collective attributes to be excluded, but got %+v", resolution.CollectiveAttributes)
+		return
+	}
+
+	// Subentries do not apply to subentries.
+	subentries := testSubentries(t)
+	resolution, err = (&SubentryResolver{}).Resolve(subentries[0].Name, subentries[0].Attributes, testAdministrativePoints(t), subentries)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(resolution.CollectiveAttribute) != 0 || len(resolution.Subschema) != 0 {
+		t.Errorf("expected no subentries to apply to a subentry, but got %+v", resolution)
+		return
+	}
+
+	// Without a specific administrative point, inner areas do not apply.
+	resolution, err = (&SubentryResolver{}).Resolve(alice, nil, testAdministrativePoints(t)[1:], subentries)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(resolution.CollectiveAttribute) != 0 {
+		t.Errorf("expected no collective attribute subentries, but got %+v", resolution.CollectiveAttribute)
+	}
+}
+
+func TestNewAdministrativePoint(t *testing.T) {
+	name := mustParseDN(t, "O=Example,C=US")
+	point, err := NewAdministrativePoint(name, []Attribute{{
+		Type:   Id_oa_administrativeRole,
+		Values: []asn1.RawValue{mustMarshalValue(t, Id_ar_autonomousArea, "")},
+	}})
+	if err != nil || point == nil || !point.hasRole(Id_ar_autonomousArea) {
+		t.Errorf("expected an autonomous administrative point, but got %+v (%v)", point, err)
+		return
+	}
+	if point, err = NewAdministrativePoint(name, nil); err != nil || point != nil {
+		t.Errorf("expected no administrative point, but got %+v (%v)", point, err)
+	}
+}
@@ -0,0 +1,423 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// The alternative of a [Refinement] that a [RefinementNode] represents.
+type RefinementKind int
+
+const (
+	RefinementKindItem RefinementKind = iota
+	RefinementKindAnd
+	RefinementKindOr
+	RefinementKindNot
+)
+
+// A Refinement in a form that is easier to construct and inspect than its
+// nested CHOICEs. Use [RefinementNode.Marshal] to produce a Refinement, and
+// [DecodeRefinement] to produce a RefinementNode from one.
+type RefinementNode struct {
+	Kind RefinementKind
+
+	// The object class of an `item`.
+	ObjectClass asn1.ObjectIdentifier
+
+	// The operands of `and` and `or`, or the single operand of `not`.
+	Refinements []*RefinementNode
+}
+
+func (r *RefinementNode) Marshal() (Refinement, error) {
+	switch r.Kind {
+	case RefinementKindItem:
+		oid, err := asn1.Marshal(r.ObjectClass)
+		if err != nil {
+			return Refinement{}, err
+		}
+		return contextTagged(0, oid)
+	case RefinementKindAnd, RefinementKindOr:
+		operands := make([][]byte, 0, len(r.Refinements))
+		for _, operand := range r.Refinements {
+			encoded, err := operand.Marshal()
+			if err != nil {
+				return Refinement{}, err
+			}
+			operands = append(operands, encoded.FullBytes)
+		}
+		sort.Slice(operands, func(i, j int) bool {
+			return bytes.Compare(operands[i], operands[j]) < 0
+		})
+		set, err := marshalRawValue(asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      bytes.Join(operands, nil),
+		}, "")
+		if err != nil {
+			return Refinement{}, err
+		}
+		if r.Kind == RefinementKindAnd {
+			return contextTagged(1, set.FullBytes)
+		}
+		return contextTagged(2, set.FullBytes)
+	case RefinementKindNot:
+		if len(r.Refinements) != 1 {
+			return Refinement{}, errors.New("not refinement must have exactly one operand")
+		}
+		operand, err := r.Refinements[0].Marshal()
+		if err != nil {
+			return Refinement{}, err
+		}
+		return contextTagged(3, operand.FullBytes)
+	}
+	return Refinement{}, fmt.Errorf("unrecognized refinement kind %d", r.Kind)
+}
+
+func DecodeRefinement(refinement Refinement) (*RefinementNode, error) {
+	var outer asn1.RawValue
+	if err := unmarshalExactly(refinement, &outer); err != nil {
+		return nil, err
+	}
+	if outer.Class != asn1.ClassContextSpecific || !outer.IsCompound {
+		return nil, errors.New("invalid refinement")
+	}
+	inner, err := explicitlyTaggedValue(outer)
+	if err != nil {
+		return nil, err
+	}
+	switch outer.Tag {
+	case 0:
+		r := &RefinementNode{Kind: RefinementKindItem}
+		if err := unmarshalExactly(inner, &r.ObjectClass); err != nil {
+			return nil, err
+		}
+		return r, nil
+	case 1, 2:
+		var elements []asn1.RawValue
+		rest, err := asn1.UnmarshalWithParams(encodingOf(inner), &elements, "set")
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) > 0 {
+			return nil, errors.New("trailing bytes after refinements")
+		}
+		r := &RefinementNode{Kind: RefinementKindAnd, Refinements: make([]*RefinementNode, 0, len(elements))}
+		if outer.Tag == 2 {
+			r.Kind = RefinementKindOr
+		}
+		for _, element := range elements {
+			operand, err := DecodeRefinement(element)
+			if err != nil {
+				return nil, err
+			}
+			r.Refinements = append(r.Refinements, operand)
+		}
+		return r, nil
+	case 3:
+		operand, err := DecodeRefinement(inner)
+		if err != nil {
+			return nil, err
+		}
+		return &RefinementNode{Kind: RefinementKindNot, Refinements: []*RefinementNode{operand}}, nil
+	}
+	return nil, fmt.Errorf("unrecognized refinement alternative %d", outer.Tag)
+}
+
+// Returns true if an entry whose objectClass attribute has the values
+// `objectClasses` satisfies `r`, as described in ITU-T Recommendation X.501,
+// Section 12.3.5. The `and` of no refinements is true, and the `or` of no
+// refinements is false.
+func (r *RefinementNode) Evaluate(objectClasses []asn1.ObjectIdentifier) bool {
+	switch r.Kind {
+	case RefinementKindItem:
+		for _, oc := range objectClasses {
+			if oc.Equal(r.ObjectClass) {
+				return true
+			}
+		}
+		return false
+	case RefinementKindAnd:
+		for _, operand := range r.Refinements {
+			if !operand.Evaluate(objectClasses) {
+				return false
+			}
+		}
+		return true
+	case RefinementKindOr:
+		for _, operand := range r.Refinements {
+			if operand.Evaluate(objectClasses) {
+				return true
+			}
+		}
+		return false
+	case RefinementKindNot:
+		return len(r.Refinements) == 1 && !r.Refinements[0].Evaluate(objectClasses)
+	}
+	return false
+}
+
+// An element of the `specificExclusions` of a [SubtreeSpecification].
+type SpecificExclusion struct {
+	// If true, this is `chopAfter`: the subordinates of the named entry are
+	// excluded, but the entry itself is not. Otherwise, this is `chopBefore`:
+	// the named entry and all of its subordinates are excluded.
+	ChopAfter bool
+
+	// The name of the entry, relative to the base of the subtree.
+	Name LocalName
+}
+
+// A [SubtreeSpecification] in a form that is easier to construct and
+// inspect. Use [DecodeSubtreeSpecification] to produce one from a
+// SubtreeSpecification. A SubtreeSpecificationNode may be used with Marshal
+// and Unmarshal, which encode it as a SubtreeSpecification.
+type SubtreeSpecificationNode struct {
+	// The name of the base of the subtree, relative to the administrative
+	// point.
+	Base LocalName
+
+	SpecificExclusions []SpecificExclusion
+
+	// The number of RDNs by which the names of entries in the subtree must
+	// be longer than the name of the base, at least.
+	Minimum int
+
+	// The number of RDNs by which the names of entries in the subtree may be
+	// longer than the name of the base, at most. If nil, there is no limit.
+	Maximum *int
+
+	// If not nil, entries must also satisfy this refinement.
+	SpecificationFilter *RefinementNode
+}
+
+// Decodes a SubtreeSpecification. The specificationFilter may be encoded
+// either with or without its explicit tag, so specifications unmarshalled by
+// encoding/asn1 and by Unmarshal may both be decoded.
+func DecodeSubtreeSpecification(spec SubtreeSpecification) (*SubtreeSpecificationNode, error) {
+	s := &SubtreeSpecificationNode{
+		Base:               spec.Base,
+		SpecificExclusions: make([]SpecificExclusion, 0, len(spec.SpecificExclusions)),
+		Minimum:            spec.Minimum,
+	}
+	for _, exclusion := range spec.SpecificExclusions {
+		if exclusion.Class != asn1.ClassContextSpecific || exclusion.Tag > 1 {
+			return nil, errors.New("invalid specific exclusion")
+		}
+		name, err := explicitlyTaggedValue(exclusion)
+		if err != nil {
+			return nil, err
+		}
+		e := SpecificExclusion{ChopAfter: exclusion.Tag == 1}
+		if err := unmarshalExactly(name, &e.Name); err != nil {
+			return nil, err
+		}
+		s.SpecificExclusions = append(s.SpecificExclusions, e)
+	}
+	// encoding/asn1 cannot tell an absent maximum from a maximum of zero, so
+	// a maximum of zero is taken to be absent.
+	if spec.Maximum != 0 {
+		maximum := spec.Maximum
+		s.Maximum = &maximum
+	}
+	if !isZeroRawValue(spec.SpecificationFilter) {
+		refinement := spec.SpecificationFilter
+		// The alternatives of Refinement have tags 0 through 3, so a tag of 4
+		// can only be the explicit tag of specificationFilter.
+		if refinement.Class == asn1.ClassContextSpecific && refinement.Tag == 4 {
+			var err error
+			if refinement, err = explicitlyTaggedValue(refinement); err != nil {
+				return nil, err
+			}
+		}
+		filter, err := DecodeRefinement(refinement)
+		if err != nil {
+			return nil, err
+		}
+		s.SpecificationFilter = filter
+	}
+	return s, nil
+}
+
+func isZeroRawValue(v asn1.RawValue) bool {
+	return len(v.FullBytes) == 0 && len(v.Bytes) == 0 && v.Class == 0 && v.Tag == 0
+}
+
+// Produces a SubtreeSpecification from `s`. Its specificationFilter does not
+// have its explicit tag, as Marshal expects.
+func (s *SubtreeSpecificationNode) Marshal() (spec SubtreeSpecification, err error) {
+	spec.Base = s.Base
+	spec.Minimum = s.Minimum
+	if s.Maximum != nil {
+		spec.Maximum = *s.Maximum
+	}
+	for _, exclusion := range s.SpecificExclusions {
+		name, err := asn1.Marshal(exclusion.Name)
+		if err != nil {
+			return spec, err
+		}
+		tag := 0
+		if exclusion.ChopAfter {
+			tag = 1
+		}
+		encoded, err := contextTagged(tag, name)
+		if err != nil {
+			return spec, err
+		}
+		spec.SpecificExclusions = append(spec.SpecificExclusions, encoded)
+	}
+	if s.SpecificationFilter != nil {
+		if spec.SpecificationFilter, err = s.SpecificationFilter.Marshal(); err != nil {
+			return spec, err
+		}
+	}
+	return spec, nil
+}
+
+// encoding/asn1 cannot encode a maximum of zero, so the SubtreeSpecification
+// is encoded by hand.
+func (s SubtreeSpecificationNode) MarshalRawValue() (asn1.RawValue, error) {
+	components := make([][]byte, 0, 5)
+	explicit := func(tag int, v any) error {
+		encoded, err := asn1.Marshal(v)
+		if err != nil {
+			return err
+		}
+		tagged, err := contextTagged(tag, encoded)
+		if err != nil {
+			return err
+		}
+		components = append(components, tagged.FullBytes)
+		return nil
+	}
+	if len(s.Base) > 0 {
+		if err := explicit(0, s.Base); err != nil {
+			return asn1.RawValue{}, err
+		}
+	}
+	spec, err := s.Marshal()
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	if len(spec.SpecificExclusions) > 0 {
+		exclusions := make([][]byte, 0, len(spec.SpecificExclusions))
+		for _, exclusion := range spec.SpecificExclusions {
+			exclusions = append(exclusions, exclusion.FullBytes)
+		}
+		sort.Slice(exclusions, func(i, j int) bool {
+			return bytes.Compare(exclusions[i], exclusions[j]) < 0
+		})
+		set, err := marshalRawValue(asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      bytes.Join(exclusions, nil),
+		}, "")
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		tagged, err := contextTagged(1, set.FullBytes)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		components = append(components, tagged.FullBytes)
+	}
+	if s.Minimum != 0 {
+		if err := explicit(2, s.Minimum); err != nil {
+			return asn1.RawValue{}, err
+		}
+	}
+	if s.Maximum != nil {
+		if err := explicit(3, *s.Maximum); err != nil {
+			return asn1.RawValue{}, err
+		}
+	}
+	if s.SpecificationFilter != nil {
+		tagged, err := contextTagged(4, spec.SpecificationFilter.FullBytes)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		components = append(components, tagged.FullBytes)
+	}
+	return sequenceOf(components...)
+}
+
+func (s *SubtreeSpecificationNode) UnmarshalRawValue(value asn1.RawValue) error {
+	components, err := sequenceComponents(value)
+	if err != nil {
+		return err
+	}
+	var spec SubtreeSpecification
+	var maximum *int
+	for _, component := range components {
+		if component.Class != asn1.ClassContextSpecific {
+			return errors.New("invalid subtree specification")
+		}
+		inner, err := explicitlyTaggedValue(component)
+		if err != nil {
+			return err
+		}
+		switch component.Tag {
+		case 0:
+			err = unmarshalExactly(inner, &spec.Base)
+		case 1:
+			_, err = asn1.UnmarshalWithParams(encodingOf(inner), &spec.SpecificExclusions, "set")
+		case 2:
+			err = unmarshalExactly(inner, &spec.Minimum)
+		case 3:
+			maximum = new(int)
+			err = unmarshalExactly(inner, maximum)
+		case 4:
+			spec.SpecificationFilter = inner
+		}
+		// Unrecognized extensions are ignored.
+		if err != nil {
+			return err
+		}
+	}
+	decoded, err := DecodeSubtreeSpecification(spec)
+	if err != nil {
+		return err
+	}
+	decoded.Maximum = maximum
+	*s = *decoded
+	return nil
+}
+
+// Returns true if the entry named `entry`, whose objectClass attribute has
+// the values `objectClasses`, is within the subtree `s` of the
+// administrative area whose administrative point is named `adminPoint`, as
+// described in ITU-T Recommendation X.501, Section 12.3. Names are compared
+// using [DefaultSchemaRegistry]; see [DNMatcher.SubtreeContains].
+func (s *SubtreeSpecificationNode) Contains(adminPoint, entry DistinguishedName, objectClasses []asn1.ObjectIdentifier) bool {
+	return (*DNMatcher)(nil).SubtreeContains(s, adminPoint, entry, objectClasses)
+}
+
+// Returns true if the entry named `entry`, whose objectClass attribute has
+// the values `objectClasses`, is within the subtree `s` of the
+// administrative area whose administrative point is named `adminPoint`.
+func (m *DNMatcher) SubtreeContains(s *SubtreeSpecificationNode, adminPoint, entry DistinguishedName, objectClasses []asn1.ObjectIdentifier) bool {
+	base := make(DistinguishedName, 0, len(adminPoint)+len(s.Base))
+	base = append(append(base, adminPoint...), s.Base...)
+	if len(entry) < len(base) || !m.hasPrefix(entry, base) {
+		return false
+	}
+	distance := len(entry) - len(base)
+	if distance < s.Minimum || (s.Maximum != nil && distance > *s.Maximum) {
+		return false
+	}
+	for _, exclusion := range s.SpecificExclusions {
+		excluded := append(append(make(DistinguishedName, 0, len(base)+len(exclusion.Name)), base...), exclusion.Name...)
+		if exclusion.ChopAfter {
+			if m.DNIsAncestor(excluded, entry) {
+				return false
+			}
+		} else if len(entry) >= len(excluded) && m.hasPrefix(entry, excluded) {
+			return false
+		}
+	}
+	return s.SpecificationFilter == nil || s.SpecificationFilter.Evaluate(objectClasses)
+}
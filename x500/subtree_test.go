@@ -0,0 +1,154 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func testSubtree(t *testing.T) *SubtreeSpecificationNode {
+	maximum := 2
+	return &SubtreeSpecificationNode{
+		Base: mustParseDN(t, "OU=People"),
+		SpecificExclusions: []SpecificExclusion{
+			{Name: mustParseDN(t, "OU=Former")},
+			{ChopAfter: true, Name: mustParseDN(t, "CN=Bob")},
+		},
+		Minimum: 1,
+		Maximum: &maximum,
+		SpecificationFilter: &RefinementNode{
+			Kind: RefinementKindAnd,
+			Refinements: []*RefinementNode{
+				{Kind: RefinementKindItem, ObjectClass: Id_oc_person},
+				{Kind: RefinementKindNot, Refinements: []*RefinementNode{
+					{Kind: RefinementKindItem, ObjectClass: Id_oc_applicationProcess},
+				}},
+			},
+		},
+	}
+}
+
+func TestSubtreeContains(t *testing.T) {
+	s := testSubtree(t)
+	adminPoint := mustParseDN(t, "O=Example,C=US")
+	person := []asn1.ObjectIdentifier{Id_oc_top, Id_oc_person}
+	for dn, expected := range map[string]bool{
+		"OU=People,O=Example,C=US":                    false, // Below the minimum
+		"CN=Alice,OU=People,O=Example,C=US":           true,
+		"CN=Phone,CN=Alice,OU=People,O=Example,C=US":  true,
+		"CN=C,CN=B,CN=A,OU=People,O=Example,C=US":     false, // Beyond the maximum
+		"CN=Alice,OU=Groups,O=Example,C=US":           false, // Outside of the base
+		"OU=Former,OU=People,O=Example,C=US":          false, // Chopped before
+		"CN=Carol,OU=Former,OU=People,O=Example,C=US": false,
+		"CN=Bob,OU=People,O=Example,C=US":             true, // Chopped after
+		"CN=Phone,CN=Bob,OU=People,O=Example,C=US":    false,
+		"CN=Alice,OU=People,O=Other,C=US":             false,
+	} {
+		entry := mustParseDN(t, dn)
+		if s.Contains(adminPoint, entry, person) != expected {
+			t.Errorf("%s: expected %v", dn, expected)
+		}
+	}
+	alice := mustParseDN(t, "CN=Alice,OU=People,O=Example,C=US")
+	if s.Contains(adminPoint, alice, []asn1.ObjectIdentifier{Id_oc_top, Id_oc_person, Id_oc_applicationProcess}) {
+		t.Error("the refinement should have excluded the entry")
+		return
+	}
+	if s.Contains(adminPoint, alice, []asn1.ObjectIdentifier{Id_oc_top}) {
+		t.Error("the refinement should require the person object class")
+		return
+	}
+	whole := &SubtreeSpecificationNode{}
+	if !whole.Contains(adminPoint, adminPoint, nil) {
+		t.Error("an empty subtree specification should contain the administrative point")
+		return
+	}
+}
+
+func TestSubtreeSpecificationRoundTrip(t *testing.T) {
+	type Subentry struct {
+		SubtreeSpecification []SubtreeSpecificationNode `x500:"oid:2.5.18.6"`
+	}
+	zero := 0
+	in := Subentry{SubtreeSpecification: []SubtreeSpecificationNode{
+		*testSubtree(t),
+		{Maximum: &zero},
+	}}
+	attrs, err := Marshal(in)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(attrs) != 1 || len(attrs[0].Values) != 2 {
+		t.Error("each subtree specification should be a value")
+		return
+	}
+	var out Subentry
+	if err := Unmarshal(attrs, &out); err != nil {
+		t.Error(err)
+		return
+	}
+	s := out.SubtreeSpecification[0]
+	if !DNEqual(s.Base, in.SubtreeSpecification[0].Base) || s.Minimum != 1 || s.Maximum == nil || *s.Maximum != 2 {
+		t.Errorf("subtree specification was %+v", s)
+		return
+	}
+	if len(s.SpecificExclusions) != 2 || s.SpecificExclusions[0].ChopAfter || !s.SpecificExclusions[1].ChopAfter {
+		t.Errorf("specific exclusions were %+v", s.SpecificExclusions)
+		return
+	}
+	if s.SpecificationFilter == nil || s.SpecificationFilter.Kind != RefinementKindAnd || len(s.SpecificationFilter.Refinements) != 2 {
+		t.Errorf("specification filter was %+v", s.SpecificationFilter)
+		return
+	}
+	if m := out.SubtreeSpecification[1].Maximum; m == nil || *m != 0 {
+		t.Error("a maximum of zero should have been preserved")
+		return
+	}
+
+	// The same value, unmarshalled by encoding/asn1.
+	var spec SubtreeSpecification
+	if err := unmarshalExactly(attrs[0].Values[0], &spec); err != nil {
+		t.Error(err)
+		return
+	}
+	decoded, err := DecodeSubtreeSpecification(spec)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if decoded.SpecificationFilter == nil || len(decoded.SpecificationFilter.Refinements) != 2 || len(decoded.SpecificExclusions) != 2 {
+		t.Errorf("subtree specification was decoded as %+v", decoded)
+		return
+	}
+}
+
+func TestRefinementEvaluate(t *testing.T) {
+	empty := &RefinementNode{Kind: RefinementKindAnd}
+	if !empty.Evaluate(nil) {
+		t.Error("the and of no refinements should be true")
+		return
+	}
+	empty.Kind = RefinementKindOr
+	if empty.Evaluate(nil) {
+		t.Error("the or of no refinements should be false")
+		return
+	}
+	r := &RefinementNode{Kind: RefinementKindOr, Refinements: []*RefinementNode{
+		{Kind: RefinementKindItem, ObjectClass: Id_oc_person},
+		{Kind: RefinementKindItem, ObjectClass: Id_oc_device},
+	}}
+	encoded, err := r.Marshal()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	decoded, err := DecodeRefinement(encoded)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !decoded.Evaluate([]asn1.ObjectIdentifier{Id_oc_device}) || decoded.Evaluate([]asn1.ObjectIdentifier{Id_oc_top}) {
+		t.Error("the decoded refinement was evaluated incorrectly")
+		return
+	}
+}
@@ -0,0 +1,1127 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Converts values of an attribute syntax to and from strings. The string
+// representations are those of IETF RFC 4517 where the syntax has one, so
+// that values can be shown and edited as text.
+type SyntaxCodec struct {
+	// The name of the ASN.1 type of values, as published in X.500 subschema.
+	Name string
+
+	// The object identifier of the equivalent LDAP syntax, if there is one.
+	LDAPSyntax string
+
+	// Returns the string representation of `value`, or an error if `value`
+	// is not a valid value of the syntax.
+	Format func(schema *SchemaRegistry, value asn1.RawValue) (string, error)
+
+	// Returns the value represented by `s`.
+	Parse func(schema *SchemaRegistry, s string) (asn1.RawValue, error)
+}
+
+// Returns an error if `value` is not a valid value of the syntax. Values are
+// validated by decoding them, which formatting does anyway.
+func (c *SyntaxCodec) Validate(schema *SchemaRegistry, value asn1.RawValue) error {
+	_, err := c.Format(schema, value)
+	return err
+}
+
+// The bounds of X.520 upper bounds used to validate values.
+const (
+	ubTelephoneNumber = 32
+	ubTelexNumber     = 14
+	ubCountryCode     = 4
+	ubAnswerback      = 8
+)
+
+func checkLength(what, s string, min, max int) error {
+	n := utf8.RuneCountInString(s)
+	if n < min || (max > 0 && n > max) {
+		if max > 0 {
+			return fmt.Errorf("%s must be %d to %d characters long", what, min, max)
+		}
+		return fmt.Errorf("%s must be at least %d characters long", what, min)
+	}
+	return nil
+}
+
+// Returns true if `syntax` is a CHOICE of string types, such as
+// UnboundedDirectoryString, any of whose alternatives is valid.
+func isDirectoryStringSyntax(syntax string) bool {
+	return syntax == "UnboundedDirectoryString" ||
+		syntax == "1.3.6.1.4.1.1466.115.121.1.15" ||
+		strings.HasPrefix(syntax, "DirectoryString")
+}
+
+var stringTagParams = map[int]string{
+	asn1.TagUTF8String:      "utf8",
+	asn1.TagPrintableString: "printable",
+	asn1.TagIA5String:       "ia5",
+	asn1.TagNumericString:   "numeric",
+}
+
+// A codec for a string syntax, whose values are encoded with `params`, and
+// whose lengths are between `min` and `max`, or unbounded if `max` is zero.
+func stringSyntaxCodec(name, ldapSyntax, params string, min, max int) *SyntaxCodec {
+	return &SyntaxCodec{
+		Name:       name,
+		LDAPSyntax: ldapSyntax,
+		Format: func(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+			if value.Class != asn1.ClassUniversal || !isStringTag(value.Tag) {
+				return "", errors.New("not a string")
+			}
+			s, err := DirectoryStringToString(value)
+			if err != nil {
+				return "", err
+			}
+			if !isDirectoryStringSyntax(name) {
+				if stringTagParams[value.Tag] != params {
+					return "", fmt.Errorf("values of %s must be encoded with the %s tag", name, params)
+				}
+				// encoding/asn1 checks the character set of the string.
+				if _, err := asn1.MarshalWithParams(s, params); err != nil {
+					return "", err
+				}
+			} else if !utf8.ValidString(s) {
+				return "", errors.New("invalid utf-8")
+			}
+			if err := checkLength(name, s, min, max); err != nil {
+				return "", err
+			}
+			return s, nil
+		},
+		Parse: func(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+			if !utf8.ValidString(s) {
+				return asn1.RawValue{}, errors.New("invalid utf-8")
+			}
+			if err := checkLength(name, s, min, max); err != nil {
+				return asn1.RawValue{}, err
+			}
+			return marshalRawValue(s, params)
+		},
+	}
+}
+
+// Escape `$` and `\` in a line of a postal address, as described in IETF RFC
+// 4517, Section 3.3.28.
+func escapePostalLine(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\5C")
+	return strings.ReplaceAll(s, "$", "\\24")
+}
+
+func unescapePostalLine(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		escape := strings.ToUpper(s[i+1 : min(i+3, len(s))])
+		switch escape {
+		case "5C":
+			b.WriteByte('\\')
+		case "24":
+			b.WriteByte('$')
+		default:
+			return "", fmt.Errorf("invalid escape sequence in postal address line %q", s)
+		}
+		i += 2
+	}
+	return b.String(), nil
+}
+
+func formatPostalAddress(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var lines []asn1.RawValue
+	if err := unmarshalExactly(value, &lines); err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		return "", errors.New("a postal address must have at least one line")
+	}
+	formatted := make([]string, 0, len(lines))
+	for _, line := range lines {
+		s, err := DirectoryStringToString(line)
+		if err != nil {
+			return "", err
+		}
+		formatted = append(formatted, escapePostalLine(s))
+	}
+	return strings.Join(formatted, "$"), nil
+}
+
+func parsePostalAddress(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+	lines := make([]asn1.RawValue, 0)
+	for _, line := range strings.Split(s, "$") {
+		unescaped, err := unescapePostalLine(line)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		value, err := encodeUTF8Value(unescaped)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		lines = append(lines, value)
+	}
+	return marshalRawValue(lines, "")
+}
+
+// The names of the G3 facsimile parameters of IETF RFC 4517, Section 3.3.11,
+// indexed by bit number.
+var faxParameterNames = map[int]string{
+	G3FacsimileNonBasicParameters_Two_dimensional:  "twoDimensional",
+	G3FacsimileNonBasicParameters_Fine_resolution:  "fineResolution",
+	G3FacsimileNonBasicParameters_Unlimited_length: "unlimitedLength",
+	G3FacsimileNonBasicParameters_B4_length:        "b4Length",
+	G3FacsimileNonBasicParameters_A3_width:         "a3Width",
+	G3FacsimileNonBasicParameters_B4_width:         "b4Width",
+	G3FacsimileNonBasicParameters_Uncompressed:     "uncompressed",
+}
+
+func formatFacsimileNumber(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var number FacsimileTelephoneNumber
+	if err := unmarshalExactly(value, &number); err != nil {
+		return "", err
+	}
+	if err := checkLength("TelephoneNumber", number.TelephoneNumber, 1, ubTelephoneNumber); err != nil {
+		return "", err
+	}
+	parts := []string{number.TelephoneNumber}
+	for bit := 0; bit < number.Parameters.BitLength; bit++ {
+		if number.Parameters.At(bit) == 0 {
+			continue
+		}
+		name, ok := faxParameterNames[bit]
+		if !ok {
+			return "", fmt.Errorf("facsimile parameter %d has no string representation", bit)
+		}
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, "$"), nil
+}
+
+func parseFacsimileNumber(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+	parts := strings.Split(s, "$")
+	number := FacsimileTelephoneNumber{TelephoneNumber: parts[0]}
+	if err := checkLength("TelephoneNumber", number.TelephoneNumber, 1, ubTelephoneNumber); err != nil {
+		return asn1.RawValue{}, err
+	}
+	bits := make([]int, 0, len(parts)-1)
+	for _, name := range parts[1:] {
+		found := false
+		for bit, n := range faxParameterNames {
+			if strings.EqualFold(n, name) {
+				bits = append(bits, bit)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return asn1.RawValue{}, fmt.Errorf("unrecognized facsimile parameter %q", name)
+		}
+	}
+	if len(bits) > 0 {
+		last := slices.Max(bits)
+		number.Parameters = asn1.BitString{Bytes: make([]byte, last/8+1), BitLength: last + 1}
+		for _, bit := range bits {
+			number.Parameters.Bytes[bit/8] |= 0x80 >> (bit % 8)
+		}
+	}
+	return marshalRawValue(number, "")
+}
+
+func formatTelexNumber(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var number TelexNumber
+	if err := unmarshalExactly(value, &number); err != nil {
+		return "", err
+	}
+	if err := checkTelexNumber(number); err != nil {
+		return "", err
+	}
+	return number.TelexNumber + "$" + number.CountryCode + "$" + number.Answerback, nil
+}
+
+func checkTelexNumber(number TelexNumber) error {
+	if err := checkLength("telexNumber", number.TelexNumber, 1, ubTelexNumber); err != nil {
+		return err
+	}
+	if err := checkLength("countryCode", number.CountryCode, 1, ubCountryCode); err != nil {
+		return err
+	}
+	return checkLength("answerback", number.Answerback, 1, ubAnswerback)
+}
+
+func parseTelexNumber(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+	parts := strings.Split(s, "$")
+	if len(parts) != 3 {
+		return asn1.RawValue{}, fmt.Errorf("invalid telex number %q", s)
+	}
+	number := TelexNumber{TelexNumber: parts[0], CountryCode: parts[1], Answerback: parts[2]}
+	if err := checkTelexNumber(number); err != nil {
+		return asn1.RawValue{}, err
+	}
+	return marshalRawValue(number, "")
+}
+
+// The match types of IETF RFC 4517, Section 3.3.14, indexed by the tag of
+// the CriteriaItem alternative.
+var criteriaMatchTypes = []string{"EQ", "SUBSTR", "GE", "LE", "APPROX"}
+
+// The precedence of the context in which criteria are formatted.
+const (
+	criteriaLevelCriteria = iota
+	criteriaLevelAndTerm
+	criteriaLevelTerm
+)
+
+func formatCriteria(schema *SchemaRegistry, c Criteria, level int) (string, error) {
+	if c.Class != asn1.ClassContextSpecific || !c.IsCompound {
+		return "", errors.New("invalid criteria")
+	}
+	switch c.Tag {
+	case 0:
+		item, err := explicitlyTaggedValue(c)
+		if err != nil {
+			return "", err
+		}
+		if item.Class != asn1.ClassContextSpecific || item.Tag >= len(criteriaMatchTypes) {
+			return "", errors.New("unrecognized criteria item")
+		}
+		inner, err := explicitlyTaggedValue(item)
+		if err != nil {
+			return "", err
+		}
+		var attrType asn1.ObjectIdentifier
+		if err := unmarshalExactly(inner, &attrType); err != nil {
+			return "", err
+		}
+		return attributeTypeName(schema, attrType) + "$" + criteriaMatchTypes[item.Tag], nil
+	case 1, 2:
+		set, err := explicitlyTaggedValue(c)
+		if err != nil {
+			return "", err
+		}
+		var elements []asn1.RawValue
+		if _, err := asn1.UnmarshalWithParams(set.FullBytes, &elements, "set"); err != nil {
+			return "", err
+		}
+		if len(elements) == 0 {
+			if c.Tag == 1 {
+				return "?true", nil
+			}
+			return "?false", nil
+		}
+		separator, elementLevel, parenthesize := "&", criteriaLevelTerm, level == criteriaLevelTerm
+		if c.Tag == 2 {
+			separator, elementLevel, parenthesize = "|", criteriaLevelAndTerm, level != criteriaLevelCriteria
+		}
+		if len(elements) == 1 {
+			return formatCriteria(schema, elements[0], level)
+		}
+		formatted := make([]string, 0, len(elements))
+		for _, element := range elements {
+			s, err := formatCriteria(schema, element, elementLevel)
+			if err != nil {
+				return "", err
+			}
+			formatted = append(formatted, s)
+		}
+		s := strings.Join(formatted, separator)
+		if parenthesize {
+			return "(" + s + ")", nil
+		}
+		return s, nil
+	case 3:
+		inner, err := explicitlyTaggedValue(c)
+		if err != nil {
+			return "", err
+		}
+		s, err := formatCriteria(schema, inner, criteriaLevelTerm)
+		if err != nil {
+			return "", err
+		}
+		return "!" + s, nil
+	}
+	return "", fmt.Errorf("unrecognized criteria alternative [%d]", c.Tag)
+}
+
+type criteriaParser struct {
+	schema *SchemaRegistry
+	s      string
+	pos    int
+}
+
+func (p *criteriaParser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *criteriaParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid criteria at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// Encodes the and or or alternative of Criteria containing `elements`.
+func criteriaSet(tag int, elements []Criteria) (Criteria, error) {
+	encodings := make([][]byte, 0, len(elements))
+	for _, element := range elements {
+		encodings = append(encodings, element.FullBytes)
+	}
+	sort.Slice(encodings, func(i, j int) bool {
+		return bytes.Compare(encodings[i], encodings[j]) < 0
+	})
+	set, err := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      bytes.Join(encodings, nil),
+	})
+	if err != nil {
+		return Criteria{}, err
+	}
+	return contextTagged(tag, set)
+}
+
+func (p *criteriaParser) parseCriteria() (Criteria, error) {
+	terms := make([]Criteria, 0, 1)
+	for {
+		term, err := p.parseAndTerm()
+		if err != nil {
+			return Criteria{}, err
+		}
+		terms = append(terms, term)
+		p.skipSpaces()
+		if p.pos >= len(p.s) || p.s[p.pos] != '|' {
+			break
+		}
+		p.pos++
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return criteriaSet(2, terms)
+}
+
+func (p *criteriaParser) parseAndTerm() (Criteria, error) {
+	terms := make([]Criteria, 0, 1)
+	for {
+		term, err := p.parseTerm()
+		if err != nil {
+			return Criteria{}, err
+		}
+		terms = append(terms, term)
+		p.skipSpaces()
+		if p.pos >= len(p.s) || p.s[p.pos] != '&' {
+			break
+		}
+		p.pos++
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return criteriaSet(1, terms)
+}
+
+func (p *criteriaParser) parseTerm() (Criteria, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.s) {
+		return Criteria{}, p.errorf("unexpected end of criteria")
+	}
+	switch {
+	case p.s[p.pos] == '!':
+		p.pos++
+		inner, err := p.parseTerm()
+		if err != nil {
+			return Criteria{}, err
+		}
+		return contextTagged(3, inner.FullBytes)
+	case p.s[p.pos] == '(':
+		p.pos++
+		inner, err := p.parseCriteria()
+		if err != nil {
+			return Criteria{}, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+			return Criteria{}, p.errorf("expected ')'")
+		}
+		p.pos++
+		return inner, nil
+	case strings.HasPrefix(p.s[p.pos:], "?true"):
+		p.pos += len("?true")
+		return criteriaSet(1, nil)
+	case strings.HasPrefix(p.s[p.pos:], "?false"):
+		p.pos += len("?false")
+		return criteriaSet(2, nil)
+	}
+	end := strings.IndexByte(p.s[p.pos:], '$')
+	if end < 0 {
+		return Criteria{}, p.errorf("expected an attribute type followed by '$'")
+	}
+	attrType, err := resolveAttributeType(p.schema, strings.TrimSpace(p.s[p.pos:p.pos+end]))
+	if err != nil {
+		return Criteria{}, p.errorf("%v", err)
+	}
+	p.pos += end + 1
+	start := p.pos
+	for p.pos < len(p.s) && p.s[p.pos] >= 'A' && p.s[p.pos] <= 'Z' {
+		p.pos++
+	}
+	matchType := p.s[start:p.pos]
+	for tag, name := range criteriaMatchTypes {
+		if name != matchType {
+			continue
+		}
+		oid, err := asn1.Marshal(attrType)
+		if err != nil {
+			return Criteria{}, err
+		}
+		item, err := contextTagged(tag, oid)
+		if err != nil {
+			return Criteria{}, err
+		}
+		return contextTagged(0, item.FullBytes)
+	}
+	return Criteria{}, p.errorf("unrecognized match type %q", matchType)
+}
+
+func parseCriteria(schema *SchemaRegistry, s string) (Criteria, error) {
+	p := &criteriaParser{schema: schema, s: s}
+	c, err := p.parseCriteria()
+	if err != nil {
+		return Criteria{}, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.s) {
+		return Criteria{}, p.errorf("unexpected %q", p.s[p.pos:])
+	}
+	return c, nil
+}
+
+func formatGuide(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var guide Guide
+	if _, err := asn1.UnmarshalWithParams(encodingOf(value), &guide, "set"); err != nil {
+		return "", err
+	}
+	// encoding/asn1 does not remove the explicit tag of a RawValue.
+	inner, err := explicitlyTaggedValue(guide.Criteria)
+	if err != nil {
+		return "", err
+	}
+	criteria, err := formatCriteria(schema, inner, criteriaLevelCriteria)
+	if err != nil {
+		return "", err
+	}
+	if len(guide.ObjectClass) == 0 {
+		return criteria, nil
+	}
+	return objectIdentifierName(schema, guide.ObjectClass) + "#" + criteria, nil
+}
+
+func parseGuide(schema *SchemaRegistry, s string) (asn1.RawValue, error) {
+	var guide Guide
+	if oc, criteria, found := strings.Cut(s, "#"); found {
+		oid, err := resolveObjectIdentifier(schema, strings.TrimSpace(oc))
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		guide.ObjectClass = oid
+		s = criteria
+	}
+	criteria, err := parseCriteria(schema, s)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	// encoding/asn1 ignores the explicit tag of a RawValue, so the guide is
+	// encoded by hand.
+	var components [][]byte
+	if guide.ObjectClass != nil {
+		oid, err := asn1.Marshal(guide.ObjectClass)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		oc, err := contextTagged(0, oid)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		components = append(components, oc.FullBytes)
+	}
+	tagged, err := contextTagged(1, criteria.FullBytes)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	components = append(components, tagged.FullBytes)
+	return marshalRawValue(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSet,
+		IsCompound: true,
+		Bytes:      bytes.Join(components, nil),
+	}, "")
+}
+
+var enhancedGuideSubsets = []string{"baseObject", "oneLevel", "wholeSubtree"}
+
+func formatEnhancedGuide(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var guide struct {
+		ObjectClass asn1.ObjectIdentifier `asn1:"explicit,tag:0"`
+		Criteria    asn1.RawValue         `asn1:"tag:1"`
+		Subset      int                   `asn1:"optional,explicit,tag:2,default:1"`
+	}
+	if err := unmarshalExactly(value, &guide); err != nil {
+		return "", err
+	}
+	criteria, err := explicitlyTaggedValue(guide.Criteria)
+	if err != nil {
+		return "", err
+	}
+	formatted, err := formatCriteria(schema, criteria, criteriaLevelCriteria)
+	if err != nil {
+		return "", err
+	}
+	if guide.Subset < 0 || guide.Subset >= len(enhancedGuideSubsets) {
+		return "", fmt.Errorf("invalid subset %d", guide.Subset)
+	}
+	return fmt.Sprintf("%s # %s # %s", objectIdentifierName(schema, guide.ObjectClass),
+		formatted, enhancedGuideSubsets[guide.Subset]), nil
+}
+
+func parseEnhancedGuide(schema *SchemaRegistry, s string) (asn1.RawValue, error) {
+	parts := strings.Split(s, "#")
+	if len(parts) != 3 {
+		return asn1.RawValue{}, fmt.Errorf("invalid enhanced guide %q", s)
+	}
+	oid, err := resolveObjectIdentifier(schema, strings.TrimSpace(parts[0]))
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	criteria, err := parseCriteria(schema, parts[1])
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	subset := -1
+	for i, name := range enhancedGuideSubsets {
+		if strings.EqualFold(name, strings.TrimSpace(parts[2])) {
+			subset = i
+		}
+	}
+	if subset < 0 {
+		return asn1.RawValue{}, fmt.Errorf("invalid subset %q", parts[2])
+	}
+	oidEncoding, err := asn1.Marshal(oid)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	oc, err := contextTagged(0, oidEncoding)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	tagged, err := contextTagged(1, criteria.FullBytes)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	components := [][]byte{oc.FullBytes, tagged.FullBytes}
+	if subset != EnhancedGuide_subset_OneLevel {
+		i, err := asn1.Marshal(subset)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		tagged, err := contextTagged(2, i)
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		components = append(components, tagged.FullBytes)
+	}
+	return marshalRawValue(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSequence,
+		IsCompound: true,
+		Bytes:      bytes.Join(components, nil),
+	}, "")
+}
+
+// Formats a selector of a presentation address as described in IETF RFC
+// 1278: as a quoted string if it is printable, and in hexadecimal otherwise.
+func formatSelector(selector []byte) string {
+	if selector == nil {
+		return ""
+	}
+	printable := len(selector) > 0
+	for _, c := range selector {
+		if c < 0x20 || c > 0x7E || c == '"' || c == '/' {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		return `"` + string(selector) + `"`
+	}
+	return "'" + strings.ToUpper(hex.EncodeToString(selector)) + "'H"
+}
+
+func parseSelector(s string) ([]byte, error) {
+	switch {
+	case s == "":
+		return nil, nil
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return []byte(s[1 : len(s)-1]), nil
+	case len(s) >= 3 && s[0] == '\'' && strings.HasSuffix(s, "'H"):
+		return hex.DecodeString(s[1 : len(s)-2])
+	}
+	return nil, fmt.Errorf("invalid selector %q", s)
+}
+
+// Formats a presentation address in the string representation of IETF RFC
+// 1278. Network addresses are given in hexadecimal, prefixed with "NS+".
+func formatPresentationAddress(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var addr PresentationAddress
+	if err := unmarshalExactly(value, &addr); err != nil {
+		return "", err
+	}
+	if len(addr.NAddresses) == 0 {
+		return "", errors.New("a presentation address must have at least one network address")
+	}
+	var b strings.Builder
+	switch {
+	case addr.PSelector != nil:
+		b.WriteString(formatSelector(addr.PSelector) + "/")
+		fallthrough
+	case addr.SSelector != nil:
+		b.WriteString(formatSelector(addr.SSelector) + "/")
+		fallthrough
+	case addr.TSelector != nil:
+		b.WriteString(formatSelector(addr.TSelector) + "/")
+	}
+	for i, nAddress := range addr.NAddresses {
+		if i > 0 {
+			b.WriteByte('_')
+		}
+		b.WriteString("NS+" + strings.ToUpper(hex.EncodeToString(nAddress)))
+	}
+	return b.String(), nil
+}
+
+func parsePresentationAddress(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) > 4 {
+		return asn1.RawValue{}, fmt.Errorf("invalid presentation address %q", s)
+	}
+	var addr PresentationAddress
+	selectors := []*[]byte{&addr.TSelector, &addr.SSelector, &addr.PSelector}
+	for i := len(parts) - 2; i >= 0; i-- {
+		selector, err := parseSelector(parts[i])
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		*selectors[len(parts)-2-i] = selector
+	}
+	for _, nAddress := range strings.Split(parts[len(parts)-1], "_") {
+		if !strings.HasPrefix(nAddress, "NS+") {
+			return asn1.RawValue{}, fmt.Errorf("unsupported network address %q", nAddress)
+		}
+		decoded, err := hex.DecodeString(nAddress[len("NS+"):])
+		if err != nil || len(decoded) == 0 {
+			return asn1.RawValue{}, fmt.Errorf("invalid network address %q", nAddress)
+		}
+		addr.NAddresses = append(addr.NAddresses, decoded)
+	}
+	return marshalRawValue(addr, "")
+}
+
+func formatNameAndOptionalUID(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var name NameAndOptionalUID
+	if err := unmarshalExactly(value, &name); err != nil {
+		return "", err
+	}
+	dn, err := FormatDNWithOptions(name.Dn, &DNOptions{Schema: schema})
+	if err != nil {
+		return "", err
+	}
+	if name.Uid.BitLength == 0 {
+		return dn, nil
+	}
+	return dn + "#" + formatBitString(name.Uid), nil
+}
+
+// The unique identifier is separated from the distinguished name by the last
+// `#` that is followed by a bit string, since `#` may also appear in the
+// distinguished name.
+func parseNameAndOptionalUID(schema *SchemaRegistry, s string) (asn1.RawValue, error) {
+	var name NameAndOptionalUID
+	if i := strings.LastIndex(s, "#'"); i >= 0 && strings.HasSuffix(s, "'B") {
+		uid, err := parseBitString(s[i+1:])
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		name.Uid = uid
+		s = s[:i]
+	}
+	dn, err := ParseDNWithOptions(s, &DNOptions{Schema: schema})
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	name.Dn = dn
+	return marshalRawValue(name, "")
+}
+
+// The delivery methods of IETF RFC 4517, Section 3.3.5, indexed by their
+// values in PreferredDeliveryMethod.
+var deliveryMethods = []string{
+	"any", "mhs", "physical", "telex", "teletex",
+	"g3fax", "g4fax", "ia5", "videotex", "telephone",
+}
+
+func formatDeliveryMethod(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var methods PreferredDeliveryMethod
+	if err := unmarshalExactly(value, &methods); err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(methods))
+	for _, method := range methods {
+		if method < 0 || method >= len(deliveryMethods) {
+			return "", fmt.Errorf("unrecognized delivery method %d", method)
+		}
+		names = append(names, deliveryMethods[method])
+	}
+	return strings.Join(names, " $ "), nil
+}
+
+func parseDeliveryMethod(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+	methods := make(PreferredDeliveryMethod, 0)
+	for _, name := range strings.Split(s, "$") {
+		name = strings.TrimSpace(name)
+		found := false
+		for method, n := range deliveryMethods {
+			if strings.EqualFold(n, name) {
+				methods = append(methods, method)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return asn1.RawValue{}, fmt.Errorf("unrecognized delivery method %q", name)
+		}
+	}
+	return marshalRawValue(methods, "")
+}
+
+// Formats a UUID in the form of IETF RFC 9562, Section 4.
+func formatUUID(uuid UUID) (string, error) {
+	if len(uuid) != 16 {
+		return "", errors.New("a UUID must be 16 bytes long")
+	}
+	h := hex.EncodeToString(uuid)
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+func parseUUID(s string) (UUID, error) {
+	uuid, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+	if err != nil || len(uuid) != 16 {
+		return nil, fmt.Errorf("invalid UUID %q", s)
+	}
+	return uuid, nil
+}
+
+// UUIDPair has no LDAP syntax, so its UUIDs are separated by `$`, as are
+// the components of other LDAP syntaxes.
+func formatUUIDPair(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var pair UUIDPair
+	if err := unmarshalExactly(value, &pair); err != nil {
+		return "", err
+	}
+	issuer, err := formatUUID(pair.IssuerUUID)
+	if err != nil {
+		return "", err
+	}
+	subject, err := formatUUID(pair.SubjectUUID)
+	if err != nil {
+		return "", err
+	}
+	return issuer + "$" + subject, nil
+}
+
+func parseUUIDPair(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+	issuer, subject, found := strings.Cut(s, "$")
+	if !found {
+		return asn1.RawValue{}, fmt.Errorf("invalid UUID pair %q", s)
+	}
+	var pair UUIDPair
+	var err error
+	if pair.IssuerUUID, err = parseUUID(issuer); err != nil {
+		return asn1.RawValue{}, err
+	}
+	if pair.SubjectUUID, err = parseUUID(subject); err != nil {
+		return asn1.RawValue{}, err
+	}
+	return marshalRawValue(pair, "")
+}
+
+func formatDistinguishedName(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var dn DistinguishedName
+	if err := unmarshalExactly(value, &dn); err != nil {
+		return "", err
+	}
+	return FormatDNWithOptions(dn, &DNOptions{Schema: schema})
+}
+
+func parseDistinguishedName(schema *SchemaRegistry, s string) (asn1.RawValue, error) {
+	dn, err := ParseDNWithOptions(s, &DNOptions{Schema: schema})
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	return marshalRawValue(dn, "")
+}
+
+func formatObjectIdentifier(schema *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var oid asn1.ObjectIdentifier
+	if err := unmarshalExactly(value, &oid); err != nil {
+		return "", err
+	}
+	return objectIdentifierName(schema, oid), nil
+}
+
+func parseObjectIdentifier(schema *SchemaRegistry, s string) (asn1.RawValue, error) {
+	oid, err := resolveObjectIdentifier(schema, s)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	return marshalRawValue(oid, "")
+}
+
+func formatBoolean(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var b bool
+	if err := unmarshalExactly(value, &b); err != nil {
+		return "", err
+	}
+	if b {
+		return "TRUE", nil
+	}
+	return "FALSE", nil
+}
+
+func parseBoolean(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+	switch s {
+	case "TRUE":
+		return marshalRawValue(true, "")
+	case "FALSE":
+		return marshalRawValue(false, "")
+	}
+	return asn1.RawValue{}, fmt.Errorf("invalid boolean %q", s)
+}
+
+func formatInteger(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	i := new(big.Int)
+	if err := unmarshalExactly(value, &i); err != nil {
+		return "", err
+	}
+	return i.String(), nil
+}
+
+func parseInteger(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return asn1.RawValue{}, fmt.Errorf("invalid integer %q", s)
+	}
+	return marshalRawValue(i, "")
+}
+
+func formatBitStringValue(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+	var bits asn1.BitString
+	if err := unmarshalExactly(value, &bits); err != nil {
+		return "", err
+	}
+	return formatBitString(bits), nil
+}
+
+func parseBitStringValue(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+	bits, err := parseBitString(s)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	return marshalRawValue(bits, "")
+}
+
+// A codec for GeneralizedTime or UTCTime, whose LDAP string representations
+// are the same as their contents.
+func timeSyntaxCodec(name, ldapSyntax string, tag int) *SyntaxCodec {
+	return &SyntaxCodec{
+		Name:       name,
+		LDAPSyntax: ldapSyntax,
+		Format: func(_ *SchemaRegistry, value asn1.RawValue) (string, error) {
+			if value.Class != asn1.ClassUniversal || value.Tag != tag {
+				return "", fmt.Errorf("not a %s", name)
+			}
+			var t time.Time
+			if err := unmarshalExactly(value, &t); err != nil {
+				return "", err
+			}
+			return string(value.Bytes), nil
+		},
+		Parse: func(_ *SchemaRegistry, s string) (asn1.RawValue, error) {
+			value := asn1.RawValue{Class: asn1.ClassUniversal, Tag: tag, Bytes: []byte(s)}
+			encoded, err := asn1.Marshal(value)
+			if err != nil {
+				return asn1.RawValue{}, err
+			}
+			value.FullBytes = encoded
+			var t time.Time
+			if err := unmarshalExactly(value, &t); err != nil {
+				return asn1.RawValue{}, fmt.Errorf("invalid %s %q: %w", name, s, err)
+			}
+			return value, nil
+		},
+	}
+}
+
+// Returns the codec for values of `syntax`, which may be the name of an
+// ASN.1 type, as published in X.500 subschema, or an LDAP syntax OID. Returns
+// nil if this package has no codec for the syntax.
+func SyntaxCodecFor(syntax string) *SyntaxCodec {
+	switch syntax {
+	case "UnboundedDirectoryString", "1.3.6.1.4.1.1466.115.121.1.15":
+		return stringSyntaxCodec("UnboundedDirectoryString", "1.3.6.1.4.1.1466.115.121.1.15", "utf8", 1, 0)
+	case "UTF8String", "URI", "DomainName":
+		return stringSyntaxCodec(syntax, "", "utf8", 0, 0)
+	case "PrintableString", "1.3.6.1.4.1.1466.115.121.1.44":
+		return stringSyntaxCodec("PrintableString", "1.3.6.1.4.1.1466.115.121.1.44", "printable", 0, 0)
+	case "CountryName", "1.3.6.1.4.1.1466.115.121.1.11":
+		return stringSyntaxCodec("CountryName", "1.3.6.1.4.1.1466.115.121.1.11", "printable", 2, 2)
+	case "TelephoneNumber", "1.3.6.1.4.1.1466.115.121.1.50":
+		return stringSyntaxCodec("TelephoneNumber", "1.3.6.1.4.1.1466.115.121.1.50", "printable", 1, ubTelephoneNumber)
+	case "IA5String", "1.3.6.1.4.1.1466.115.121.1.26":
+		return stringSyntaxCodec("IA5String", "1.3.6.1.4.1.1466.115.121.1.26", "ia5", 0, 0)
+	case "NumericString", "1.3.6.1.4.1.1466.115.121.1.36":
+		return stringSyntaxCodec("NumericString", "1.3.6.1.4.1.1466.115.121.1.36", "numeric", 0, 0)
+	case "PostalAddress", "1.3.6.1.4.1.1466.115.121.1.41":
+		return &SyntaxCodec{"PostalAddress", "1.3.6.1.4.1.1466.115.121.1.41", formatPostalAddress, parsePostalAddress}
+	case "FacsimileTelephoneNumber", "1.3.6.1.4.1.1466.115.121.1.22":
+		return &SyntaxCodec{"FacsimileTelephoneNumber", "1.3.6.1.4.1.1466.115.121.1.22", formatFacsimileNumber, parseFacsimileNumber}
+	case "TelexNumber", "1.3.6.1.4.1.1466.115.121.1.52":
+		return &SyntaxCodec{"TelexNumber", "1.3.6.1.4.1.1466.115.121.1.52", formatTelexNumber, parseTelexNumber}
+	case "Guide", "1.3.6.1.4.1.1466.115.121.1.25":
+		return &SyntaxCodec{"Guide", "1.3.6.1.4.1.1466.115.121.1.25", formatGuide, parseGuide}
+	case "EnhancedGuide", "1.3.6.1.4.1.1466.115.121.1.21":
+		return &SyntaxCodec{"EnhancedGuide", "1.3.6.1.4.1.1466.115.121.1.21", formatEnhancedGuide, parseEnhancedGuide}
+	case "PresentationAddress", "1.3.6.1.4.1.1466.115.121.1.43":
+		return &SyntaxCodec{"PresentationAddress", "1.3.6.1.4.1.1466.115.121.1.43", formatPresentationAddress, parsePresentationAddress}
+	case "NameAndOptionalUID", "1.3.6.1.4.1.1466.115.121.1.34":
+		return &SyntaxCodec{"NameAndOptionalUID", "1.3.6.1.4.1.1466.115.121.1.34", formatNameAndOptionalUID, parseNameAndOptionalUID}
+	case "PreferredDeliveryMethod", "1.3.6.1.4.1.1466.115.121.1.14":
+		return &SyntaxCodec{"PreferredDeliveryMethod", "1.3.6.1.4.1.1466.115.121.1.14", formatDeliveryMethod, parseDeliveryMethod}
+	case "UUIDPair":
+		return &SyntaxCodec{"UUIDPair", "", formatUUIDPair, parseUUIDPair}
+	case "DistinguishedName", "1.3.6.1.4.1.1466.115.121.1.12":
+		return &SyntaxCodec{"DistinguishedName", "1.3.6.1.4.1.1466.115.121.1.12", formatDistinguishedName, parseDistinguishedName}
+	case "OBJECT IDENTIFIER", "1.3.6.1.4.1.1466.115.121.1.38":
+		return &SyntaxCodec{"OBJECT IDENTIFIER", "1.3.6.1.4.1.1466.115.121.1.38", formatObjectIdentifier, parseObjectIdentifier}
+	case "BOOLEAN", "1.3.6.1.4.1.1466.115.121.1.7":
+		return &SyntaxCodec{"BOOLEAN", "1.3.6.1.4.1.1466.115.121.1.7", formatBoolean, parseBoolean}
+	case "INTEGER", "1.3.6.1.4.1.1466.115.121.1.27":
+		return &SyntaxCodec{"INTEGER", "1.3.6.1.4.1.1466.115.121.1.27", formatInteger, parseInteger}
+	case "BIT STRING", "UniqueIdentifier", "1.3.6.1.4.1.1466.115.121.1.6":
+		return &SyntaxCodec{"BIT STRING", "1.3.6.1.4.1.1466.115.121.1.6", formatBitStringValue, parseBitStringValue}
+	case "GeneralizedTime", "1.3.6.1.4.1.1466.115.121.1.24":
+		return timeSyntaxCodec("GeneralizedTime", "1.3.6.1.4.1.1466.115.121.1.24", asn1.TagGeneralizedTime)
+	case "UTCTime", "1.3.6.1.4.1.1466.115.121.1.53":
+		return timeSyntaxCodec("UTCTime", "1.3.6.1.4.1.1466.115.121.1.53", asn1.TagUTCTime)
+	case "ACIItem", "1.3.6.1.4.1.1466.115.121.1.1":
+		return &SyntaxCodec{"ACIItem", "1.3.6.1.4.1.1466.115.121.1.1", formatACIItem, parseACIItem}
+	case "SubtreeSpecification", "1.3.6.1.4.1.1466.115.121.1.45":
+		return &SyntaxCodec{"SubtreeSpecification", "1.3.6.1.4.1.1466.115.121.1.45", formatSubtreeSpecification, parseSubtreeSpecification}
+	}
+	if strings.HasPrefix(syntax, "DirectoryString") {
+		return stringSyntaxCodec(syntax, "1.3.6.1.4.1.1466.115.121.1.15", "utf8", 1, 0)
+	}
+	return nil
+}
+
+// Returns the codec for values of `attrType`, according to its syntax in
+// `schema`, or [DefaultSchemaRegistry] if it is nil. Returns nil if the
+// attribute type is not in the schema or this package has no codec for its
+// syntax.
+func AttributeSyntaxCodec(schema *SchemaRegistry, attrType AttributeType) *SyntaxCodec {
+	if schema == nil {
+		schema = DefaultSchemaRegistry()
+	}
+	resolved, err := schema.ResolveAttributeType(attrType.String())
+	if err != nil || resolved.Syntax == "" {
+		return nil
+	}
+	return SyntaxCodecFor(resolved.Syntax)
+}
+
+// Returns an error if `value` is not a valid value of `attrType`. Values of
+// attribute types that have no codec only need to be a single valid BER
+// encoding. See [AttributeSyntaxCodec].
+func ValidateAttributeValue(schema *SchemaRegistry, attrType AttributeType, value asn1.RawValue) error {
+	if schema == nil {
+		schema = DefaultSchemaRegistry()
+	}
+	codec := AttributeSyntaxCodec(schema, attrType)
+	if codec == nil {
+		var element asn1.RawValue
+		return unmarshalExactly(value, &element)
+	}
+	if err := codec.Validate(schema, value); err != nil {
+		return fmt.Errorf("invalid value of %s: %w", attrType, err)
+	}
+	return nil
+}
+
+// Returns the string representation of `value`, a value of `attrType`.
+// Values of attribute types that have no codec are represented by `#`
+// followed by the hexadecimal encoding, as they are in distinguished names.
+// See [AttributeSyntaxCodec].
+func FormatAttributeValue(schema *SchemaRegistry, attrType AttributeType, value asn1.RawValue) (string, error) {
+	if schema == nil {
+		schema = DefaultSchemaRegistry()
+	}
+	codec := AttributeSyntaxCodec(schema, attrType)
+	if codec == nil {
+		return "#" + hex.EncodeToString(encodingOf(value)), nil
+	}
+	s, err := codec.Format(schema, value)
+	if err != nil {
+		return "", fmt.Errorf("invalid value of %s: %w", attrType, err)
+	}
+	return s, nil
+}
+
+// Returns the value of `attrType` represented by `s`. This is the inverse of
+// [FormatAttributeValue].
+func ParseAttributeValue(schema *SchemaRegistry, attrType AttributeType, s string) (asn1.RawValue, error) {
+	if schema == nil {
+		schema = DefaultSchemaRegistry()
+	}
+	codec := AttributeSyntaxCodec(schema, attrType)
+	if codec == nil {
+		if !strings.HasPrefix(s, "#") {
+			return asn1.RawValue{}, fmt.Errorf("values of %s have no string encoding and must be given as #-prefixed hexadecimal", attrType)
+		}
+		encoded, err := hex.DecodeString(s[1:])
+		if err != nil {
+			return asn1.RawValue{}, err
+		}
+		var value asn1.RawValue
+		if err := unmarshalExactly(asn1.RawValue{FullBytes: encoded}, &value); err != nil {
+			return asn1.RawValue{}, err
+		}
+		return value, nil
+	}
+	value, err := codec.Parse(schema, s)
+	if err != nil {
+		return asn1.RawValue{}, fmt.Errorf("invalid value of %s: %w", attrType, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,150 @@
+package x500
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"testing"
+)
+
+func TestAttributeValueStringRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		attrType AttributeType
+		s        string
+	}{
+		{Id_at_commonName, "Jonathan Wilbur"},
+		{Id_at_countryName, "US"},
+		{Id_at_telephoneNumber, "+1 555 555 5555"},
+		{Id_at_x121Address, "12345"},
+		{Id_at_postalAddress, "1 Main Street$Springfield, \\24 \\5C IL"},
+		{Id_at_facsimileTelephoneNumber, "+1 555 555 5555$twoDimensional$uncompressed"},
+		{Id_at_facsimileTelephoneNumber, "+1 555 555 5555"},
+		{Id_at_telexNumber, "12345$023$ABCDE"},
+		{Id_at_searchGuide, "person#sn$EQ|!(CN$SUBSTR&?true)"},
+		{Id_at_searchGuide, "CN$EQ&(sn$GE|sn$LE)"},
+		{Id_at_enhancedSearchGuide, "person # CN$APPROX # wholeSubtree"},
+		{Id_at_enhancedSearchGuide, "person # ?false # oneLevel"},
+		{Id_at_presentationAddress, `'0101'H/"sel"/NS+490000_NS+49000011`},
+		{Id_at_presentationAddress, "NS+49000011"},
+		{Id_at_uniqueMember, "CN=Bob,O=Example#'0101'B"},
+		{Id_at_uniqueMember, "CN=Bob,O=Example"},
+		{Id_at_member, "CN=Bob,O=Example"},
+		{Id_at_preferredDeliveryMethod, "telephone $ mhs"},
+		{Id_at_uuidpair, "f81d4fae-7dec-11d0-a765-00a0c91e6bf6$00000000-0000-0000-0000-000000000000"},
+		{Id_at_objectClass, "person"},
+		{Id_at_uniqueIdentifier, "'0110'B"},
+		{Id_at_userPassword, "#0403010203"},
+	} {
+		value, err := ParseAttributeValue(nil, tc.attrType, tc.s)
+		if err != nil {
+			t.Errorf("%s: %v", tc.s, err)
+			continue
+		}
+		if err := ValidateAttributeValue(nil, tc.attrType, value); err != nil {
+			t.Errorf("%s: %v", tc.s, err)
+			continue
+		}
+		s, err := FormatAttributeValue(nil, tc.attrType, value)
+		if err != nil {
+			t.Errorf("%s: %v", tc.s, err)
+			continue
+		}
+		if s != tc.s {
+			t.Errorf("%s was formatted as %s", tc.s, s)
+			continue
+		}
+		reparsed, err := ParseAttributeValue(nil, tc.attrType, s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+			continue
+		}
+		if !bytes.Equal(encodingOf(value), encodingOf(reparsed)) {
+			t.Errorf("%s did not round-trip", tc.s)
+		}
+	}
+}
+
+func TestParseAttributeValueInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		attrType AttributeType
+		s        string
+	}{
+		{Id_at_countryName, "USA"},
+		{Id_at_telephoneNumber, "+1 555 555 5555 555 555 555 555 555"},
+		{Id_at_x121Address, "12a45"},
+		{Id_at_facsimileTelephoneNumber, "+1 555$colour"},
+		{Id_at_telexNumber, "12345$023"},
+		{Id_at_searchGuide, "cn$EQUALS"},
+		{Id_at_searchGuide, "(cn$EQ"},
+		{Id_at_enhancedSearchGuide, "person # cn$EQ # everywhere"},
+		{Id_at_presentationAddress, "'0101'H"},
+		{Id_at_preferredDeliveryMethod, "pigeon"},
+		{Id_at_uuidpair, "f81d4fae-7dec-11d0-a765-00a0c91e6bf6"},
+		{Id_at_postalAddress, "bad \\escape"},
+		{Id_at_userPassword, "secret"},
+	} {
+		if _, err := ParseAttributeValue(nil, tc.attrType, tc.s); err == nil {
+			t.Errorf("%s should not have been parsed as a value of %s", tc.s, tc.attrType)
+		}
+	}
+}
+
+func TestValidateAttributeValue(t *testing.T) {
+	utf8Country := mustMarshalValue(t, "US", "utf8")
+	if err := ValidateAttributeValue(nil, Id_at_countryName, utf8Country); err == nil {
+		t.Error("a country name must be a PrintableString")
+		return
+	}
+	emptyAddress := mustMarshalValue(t, []asn1.RawValue{}, "")
+	if err := ValidateAttributeValue(nil, Id_at_postalAddress, emptyAddress); err == nil {
+		t.Error("a postal address must have at least one line")
+		return
+	}
+	shortUUID := mustMarshalValue(t, UUIDPair{IssuerUUID: []byte{1}, SubjectUUID: make([]byte, 16)}, "")
+	if err := ValidateAttributeValue(nil, Id_at_uuidpair, shortUUID); err == nil {
+		t.Error("a UUID must be 16 bytes")
+		return
+	}
+	if err := ValidateAttributeValue(nil, Id_at_commonName, NewDirectoryString("Bob")); err != nil {
+		t.Error(err)
+		return
+	}
+}
+
+func TestSyntaxCodecFor(t *testing.T) {
+	byName := SyntaxCodecFor("PostalAddress")
+	byOID := SyntaxCodecFor("1.3.6.1.4.1.1466.115.121.1.41")
+	if byName == nil || byOID == nil || byName.Name != byOID.Name {
+		t.Error("codecs should be found by name and by LDAP syntax")
+		return
+	}
+	if SyntaxCodecFor("NoSuchSyntax") != nil {
+		t.Error("an unknown syntax should have no codec")
+		return
+	}
+}
+
+func TestFilterWithStructuredSyntax(t *testing.T) {
+	f, err := ParseFilter("(&(postalAddress=1 Main Street$Springfield)(postalAddress=*main*))")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if f.Filters[1].Substrings[0].Value.Tag != asn1.TagUTF8String {
+		t.Error("substrings of a postal address should be strings")
+		return
+	}
+	s, err := FormatFilter(f)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if s != "(&(postalAddress=1 Main Street$Springfield)(postalAddress=*main*))" {
+		t.Errorf("filter was formatted as %s", s)
+		return
+	}
+	attrs := []Attribute{{Type: Id_at_postalAddress, Values: []asn1.RawValue{f.Filters[0].Value}}}
+	if r := EvaluateFilter(f, attrs); r != FilterTrue {
+		t.Errorf("filter evaluated to %s", r)
+		return
+	}
+}
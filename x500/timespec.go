@@ -0,0 +1,796 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A TimeSpecification in a form that is easier to construct and evaluate than
+// its nested CHOICEs. Use [TimeSpecificationNode.Marshal] to produce a
+// TimeSpecification, and [DecodeTimeSpecification] to produce a
+// TimeSpecificationNode from one.
+type TimeSpecificationNode struct {
+	// The start and end of an absolute time specification. Either may be zero,
+	// in which case the time specification has no start or no end. Both are
+	// inclusive.
+	StartTime time.Time
+	EndTime   time.Time
+
+	// The periods of a periodic time specification, which is absolute if
+	// there are none.
+	Periods []PeriodNode
+
+	// If true, the time specification is all times except those specified.
+	NotThisTime bool
+
+	// The offset from UTC, in hours, of the time zone in which the periods
+	// are expressed.
+	TimeZone TimeZone
+}
+
+// A Period of a periodic time specification. Only one of `Days`,
+// `DaysOfWeek` and `DayOf` may be used, as may only one of `AllWeeks`,
+// `Weeks` and `WeeksOfMonth`, and one of `AllMonths` and `Months`. Absent
+// components include all times, days, weeks, months or years.
+type PeriodNode struct {
+	// The times of the day, inclusive. An `EndDayTime` of zero is the
+	// default, 23:59:59. A band that ends before it starts spans midnight.
+	TimesOfDay []DayTimeBand
+
+	// The intDay alternative: days of the week, from 1 for Sunday to 7 for
+	// Saturday, if weeks are given; otherwise, days of the month if months
+	// are given; otherwise, days of the year.
+	Days []int
+
+	// The bitDay alternative.
+	DaysOfWeek []time.Weekday
+
+	// The dayOf alternative, such as the second Tuesday of the month.
+	DayOf *XDayOfNode
+
+	// The allWeeks alternative.
+	AllWeeks bool
+
+	// The intWeek alternative: weeks of the month if months are given, and
+	// otherwise weeks of the year. Week 1 is days 1 to 7, and so on.
+	Weeks []int
+
+	// The bitWeek alternative: weeks of the month from 1 to 5.
+	WeeksOfMonth []int
+
+	// The allMonths alternative.
+	AllMonths bool
+
+	// The months, encoded as the bitMonth alternative.
+	Months []time.Month
+
+	Years []int
+}
+
+// The XDayOf of a [PeriodNode]: the `Ordinal` occurrence in the month, from 1
+// to 5, of any of `Days`.
+type XDayOfNode struct {
+	Ordinal int
+	Days    []time.Weekday
+}
+
+func weekdayBits(days []time.Weekday) asn1.BitString {
+	var bits asn1.BitString
+	for _, day := range days {
+		bits = withBit(bits, int(day), true)
+	}
+	return bits
+}
+
+func bitWeekdays(bits asn1.BitString) []time.Weekday {
+	var days []time.Weekday
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if bits.At(int(day)) == 1 {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+func (d *XDayOfNode) marshal() ([]byte, error) {
+	if d.Ordinal < 1 || d.Ordinal > 5 || len(d.Days) == 0 {
+		return nil, errors.New("invalid day of the month")
+	}
+	var namedDay []byte
+	var err error
+	if len(d.Days) == 1 {
+		namedDay, err = asn1.Marshal(asn1.Enumerated(d.Days[0] + 1))
+	} else {
+		namedDay, err = asn1.Marshal(weekdayBits(d.Days))
+	}
+	if err != nil {
+		return nil, err
+	}
+	tagged, err := contextTagged(d.Ordinal, namedDay)
+	return tagged.FullBytes, err
+}
+
+// Encodes `p` as a Period.
+func (p *PeriodNode) Marshal() (asn1.RawValue, error) {
+	var b taggedSequence
+	bands := make([]DayTimeBand, 0, len(p.TimesOfDay))
+	for _, band := range p.TimesOfDay {
+		if band.EndDayTime == (DayTime{Hour: 23, Minute: 59, Second: 59}) {
+			band.EndDayTime = DayTime{}
+		}
+		bands = append(bands, band)
+	}
+	addSetOf(&b, 0, bands)
+	switch {
+	case len(p.Days) > 0:
+		addSetOf(&b, 1, p.Days)
+	case len(p.DaysOfWeek) > 0:
+		bits, err := asn1.Marshal(weekdayBits(p.DaysOfWeek))
+		b.add(1, bits, err)
+	case p.DayOf != nil:
+		dayOf, err := p.DayOf.marshal()
+		b.add(1, dayOf, err)
+	}
+	switch {
+	case p.AllWeeks:
+		b.addNull(2, true)
+	case len(p.Weeks) > 0:
+		addSetOf(&b, 2, p.Weeks)
+	case len(p.WeeksOfMonth) > 0:
+		var bits asn1.BitString
+		for _, week := range p.WeeksOfMonth {
+			bits = withBit(bits, week-1, true)
+		}
+		encoded, err := asn1.Marshal(bits)
+		b.add(2, encoded, err)
+	}
+	switch {
+	case p.AllMonths:
+		b.addNull(3, true)
+	case len(p.Months) > 0:
+		var bits asn1.BitString
+		for _, month := range p.Months {
+			bits = withBit(bits, int(month-time.January), true)
+		}
+		encoded, err := asn1.Marshal(bits)
+		b.add(3, encoded, err)
+	}
+	addSetOf(&b, 4, p.Years)
+	return b.marshal()
+}
+
+// Encodes `s` as a TimeSpecification. The start and end times are encoded in
+// UTC.
+func (s *TimeSpecificationNode) Marshal() (spec TimeSpecification, err error) {
+	if len(s.Periods) > 0 {
+		periods := make([][]byte, 0, len(s.Periods))
+		for i := range s.Periods {
+			period, err := s.Periods[i].Marshal()
+			if err != nil {
+				return spec, err
+			}
+			periods = append(periods, period.FullBytes)
+		}
+		spec.Time, err = setOf(periods...)
+	} else {
+		var b taggedSequence
+		if !s.StartTime.IsZero() {
+			start, err := asn1.MarshalWithParams(s.StartTime.UTC(), "generalized")
+			b.add(0, start, err)
+		}
+		if !s.EndTime.IsZero() {
+			end, err := asn1.MarshalWithParams(s.EndTime.UTC(), "generalized")
+			b.add(1, end, err)
+		}
+		spec.Time, err = b.marshal()
+	}
+	spec.NotThisTime = s.NotThisTime
+	spec.TimeZone = s.TimeZone
+	return spec, err
+}
+
+func decodeIntegerSet(value asn1.RawValue) ([]int, error) {
+	var ints []int
+	rest, err := asn1.UnmarshalWithParams(encodingOf(value), &ints, "set")
+	if err == nil && len(rest) > 0 {
+		err = errors.New("trailing bytes after set of integers")
+	}
+	return ints, err
+}
+
+func decodeXDayOf(value asn1.RawValue) (*XDayOfNode, error) {
+	if value.Tag < 1 || value.Tag > 5 {
+		return nil, fmt.Errorf("unrecognized day of the month alternative %d", value.Tag)
+	}
+	namedDay, err := explicitlyTaggedValue(value)
+	if err != nil {
+		return nil, err
+	}
+	d := &XDayOfNode{Ordinal: value.Tag}
+	if namedDay.Tag == asn1.TagEnum {
+		var day asn1.Enumerated
+		if err := unmarshalExactly(namedDay, &day); err != nil {
+			return nil, err
+		}
+		if day < 1 || day > 7 {
+			return nil, fmt.Errorf("invalid named day %d", day)
+		}
+		d.Days = []time.Weekday{time.Weekday(day - 1)}
+		return d, nil
+	}
+	var bits asn1.BitString
+	if err := unmarshalExactly(namedDay, &bits); err != nil {
+		return nil, err
+	}
+	d.Days = bitWeekdays(bits)
+	return d, nil
+}
+
+func decodePeriod(period *Period) (*PeriodNode, error) {
+	p := &PeriodNode{TimesOfDay: period.TimesOfDay, Years: period.Years}
+	if !isZeroRawValue(period.Days) {
+		days, err := explicitlyTaggedValue(period.Days)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case days.Class == asn1.ClassContextSpecific:
+			p.DayOf, err = decodeXDayOf(days)
+		case days.Tag == asn1.TagSet:
+			p.Days, err = decodeIntegerSet(days)
+		default:
+			var bits asn1.BitString
+			err = unmarshalExactly(days, &bits)
+			p.DaysOfWeek = bitWeekdays(bits)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !isZeroRawValue(period.Weeks) {
+		weeks, err := explicitlyTaggedValue(period.Weeks)
+		if err != nil {
+			return nil, err
+		}
+		switch weeks.Tag {
+		case asn1.TagNull:
+			p.AllWeeks = true
+		case asn1.TagSet:
+			p.Weeks, err = decodeIntegerSet(weeks)
+		default:
+			var bits asn1.BitString
+			err = unmarshalExactly(weeks, &bits)
+			for week := 0; week < bits.BitLength; week++ {
+				if bits.At(week) == 1 {
+					p.WeeksOfMonth = append(p.WeeksOfMonth, week+1)
+				}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !isZeroRawValue(period.Months) {
+		months, err := explicitlyTaggedValue(period.Months)
+		if err != nil {
+			return nil, err
+		}
+		switch months.Tag {
+		case asn1.TagNull:
+			p.AllMonths = true
+		case asn1.TagSet:
+			ints, err := decodeIntegerSet(months)
+			if err != nil {
+				return nil, err
+			}
+			for _, month := range ints {
+				p.Months = append(p.Months, time.Month(month))
+			}
+		default:
+			var bits asn1.BitString
+			if err := unmarshalExactly(months, &bits); err != nil {
+				return nil, err
+			}
+			for month := 0; month < bits.BitLength; month++ {
+				if bits.At(month) == 1 {
+					p.Months = append(p.Months, time.January+time.Month(month))
+				}
+			}
+		}
+	}
+	return p, nil
+}
+
+// Decode a [TimeSpecification] into a [TimeSpecificationNode].
+func DecodeTimeSpecification(spec TimeSpecification) (*TimeSpecificationNode, error) {
+	s := &TimeSpecificationNode{NotThisTime: spec.NotThisTime, TimeZone: spec.TimeZone}
+	if s.TimeZone < -12 || s.TimeZone > 12 {
+		return nil, fmt.Errorf("invalid time zone %d", s.TimeZone)
+	}
+	switch spec.Time.Tag {
+	case asn1.TagSequence:
+		var absolute TimeSpecification_time_absolute
+		if err := unmarshalExactly(spec.Time, &absolute); err != nil {
+			return nil, err
+		}
+		s.StartTime = absolute.StartTime
+		s.EndTime = absolute.EndTime
+	case asn1.TagSet:
+		var periods []Period
+		rest, err := asn1.UnmarshalWithParams(encodingOf(spec.Time), &periods, "set")
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) > 0 {
+			return nil, errors.New("trailing bytes after periods")
+		}
+		if len(periods) == 0 {
+			return nil, errors.New("a periodic time specification must have at least one period")
+		}
+		for i := range periods {
+			period, err := decodePeriod(&periods[i])
+			if err != nil {
+				return nil, err
+			}
+			s.Periods = append(s.Periods, *period)
+		}
+	default:
+		return nil, fmt.Errorf("unrecognized time specification alternative %d", spec.Time.Tag)
+	}
+	return s, nil
+}
+
+func secondOfDay(d DayTime) int {
+	return d.Hour*3600 + d.Minute*60 + d.Second
+}
+
+func (p *PeriodNode) includesTimeOfDay(t time.Time) bool {
+	if len(p.TimesOfDay) == 0 {
+		return true
+	}
+	second := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	for _, band := range p.TimesOfDay {
+		start, end := secondOfDay(band.StartDayTime), secondOfDay(band.EndDayTime)
+		if band.EndDayTime == (DayTime{}) {
+			end = 86399
+		}
+		if start <= end && start <= second && second <= end {
+			return true
+		}
+		if start > end && (second >= start || second <= end) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PeriodNode) includesDate(t time.Time) bool {
+	weekOfMonth := (t.Day()-1)/7 + 1
+	weeksGiven := p.AllWeeks || len(p.Weeks) > 0 || len(p.WeeksOfMonth) > 0
+	monthsGiven := p.AllMonths || len(p.Months) > 0
+	switch {
+	case len(p.Days) > 0:
+		day := t.YearDay()
+		if weeksGiven {
+			day = int(t.Weekday()) + 1
+		} else if monthsGiven {
+			day = t.Day()
+		}
+		if !slices.Contains(p.Days, day) {
+			return false
+		}
+	case len(p.DaysOfWeek) > 0:
+		if !slices.Contains(p.DaysOfWeek, t.Weekday()) {
+			return false
+		}
+	case p.DayOf != nil:
+		if weekOfMonth != p.DayOf.Ordinal || !slices.Contains(p.DayOf.Days, t.Weekday()) {
+			return false
+		}
+	}
+	switch {
+	case len(p.Weeks) > 0:
+		week := (t.YearDay()-1)/7 + 1
+		if monthsGiven {
+			week = weekOfMonth
+		}
+		if !slices.Contains(p.Weeks, week) {
+			return false
+		}
+	case len(p.WeeksOfMonth) > 0:
+		if !slices.Contains(p.WeeksOfMonth, weekOfMonth) {
+			return false
+		}
+	}
+	if len(p.Months) > 0 && !slices.Contains(p.Months, t.Month()) {
+		return false
+	}
+	return len(p.Years) == 0 || slices.Contains(p.Years, t.Year())
+}
+
+func (s *TimeSpecificationNode) location() *time.Location {
+	if s.TimeZone == 0 {
+		return time.UTC
+	}
+	return time.FixedZone(fmt.Sprintf("UTC%+d", s.TimeZone), int(s.TimeZone)*3600)
+}
+
+func (s *TimeSpecificationNode) specifies(t time.Time) bool {
+	if len(s.Periods) == 0 {
+		return (s.StartTime.IsZero() || !t.Before(s.StartTime)) && (s.EndTime.IsZero() || !t.After(s.EndTime))
+	}
+	t = t.In(s.location())
+	for i := range s.Periods {
+		if s.Periods[i].includesDate(t) && s.Periods[i].includesTimeOfDay(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if `t` is within the time specification, taking
+// `NotThisTime` into account.
+func (s *TimeSpecificationNode) Contains(t time.Time) bool {
+	return s.specifies(t) != s.NotThisTime
+}
+
+// How far NextTransition looks ahead for a periodic time specification
+// without years, which is long enough to include a 29th of February.
+const transitionHorizon = 8
+
+// Returns the first instant after `t` at which [TimeSpecificationNode.Contains]
+// changes, at a resolution of one second, or false if there is none. Periodic
+// time specifications without years are searched up to eight years ahead.
+func (s *TimeSpecificationNode) NextTransition(t time.Time) (time.Time, bool) {
+	if len(s.Periods) == 0 {
+		if !s.StartTime.IsZero() && t.Before(s.StartTime) {
+			return s.StartTime, true
+		}
+		if !s.EndTime.IsZero() && !t.After(s.EndTime) {
+			return s.EndTime.Truncate(time.Second).Add(time.Second), true
+		}
+		return time.Time{}, false
+	}
+	loc := s.location()
+	local := t.In(loc)
+	horizon := local.Year() + transitionHorizon
+	for i := range s.Periods {
+		for _, year := range s.Periods[i].Years {
+			horizon = max(horizon, year+1)
+		}
+	}
+	current := s.Contains(t)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	for ; day.Year() < horizon; day = day.AddDate(0, 0, 1) {
+		candidates := []time.Time{day}
+		for i := range s.Periods {
+			for _, band := range s.Periods[i].TimesOfDay {
+				end := secondOfDay(band.EndDayTime) + 1
+				if band.EndDayTime == (DayTime{}) {
+					end = 86400
+				}
+				candidates = append(candidates,
+					day.Add(time.Duration(secondOfDay(band.StartDayTime))*time.Second),
+					day.Add(time.Duration(end)*time.Second))
+			}
+		}
+		slices.SortFunc(candidates, func(a, b time.Time) int { return a.Compare(b) })
+		for _, candidate := range candidates {
+			if candidate.After(t) && s.Contains(candidate) != current {
+				return candidate, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// Returns true if the time specification satisfies the TimeAssertion
+// `assertion`, as temporalContext does in ITU-T Recommendation X.520,
+// Section 15.2: `now` is satisfied if it contains `now`, `at` if it contains
+// the given time, and `between` if it contains any time in the interval, or
+// every time if `entirely` is TRUE. An interval without an end is searched
+// only as far as [TimeSpecificationNode.NextTransition] looks ahead.
+func (s *TimeSpecificationNode) MatchesAssertion(assertion TimeAssertion, now time.Time) (bool, error) {
+	switch {
+	case assertion.Class == asn1.ClassUniversal && assertion.Tag == asn1.TagNull:
+		return s.Contains(now), nil
+	case assertion.Class == asn1.ClassUniversal && assertion.Tag == asn1.TagGeneralizedTime:
+		var at time.Time
+		if err := unmarshalExactly(assertion, &at); err != nil {
+			return false, err
+		}
+		return s.Contains(at), nil
+	case assertion.Class == asn1.ClassUniversal && assertion.Tag == asn1.TagSequence:
+		var between TimeAssertion_between
+		if err := unmarshalExactly(assertion, &between); err != nil {
+			return false, err
+		}
+		contained := s.Contains(between.StartTime)
+		next, changes := s.NextTransition(between.StartTime)
+		withinInterval := changes && (between.EndTime.IsZero() || !next.After(between.EndTime))
+		if between.Entirely {
+			return contained && !withinInterval, nil
+		}
+		return contained || withinInterval, nil
+	}
+	return false, fmt.Errorf("unrecognized time assertion alternative %d", assertion.Tag)
+}
+
+var weekdayNames = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+var monthNames = []string{
+	"january", "february", "march", "april", "may", "june",
+	"july", "august", "september", "october", "november", "december",
+}
+
+var ordinalNames = []string{"1st", "2nd", "3rd", "4th", "5th"}
+
+type timeSpecParser struct {
+	tokens    []string
+	positions []int
+	i         int
+}
+
+func (p *timeSpecParser) errorf(format string, args ...any) error {
+	position := 0
+	if p.i < len(p.positions) {
+		position = p.positions[p.i]
+	} else if len(p.positions) > 0 {
+		position = p.positions[len(p.positions)-1] + len(p.tokens[len(p.tokens)-1])
+	}
+	return fmt.Errorf("invalid time specification at position %d: %s", position, fmt.Sprintf(format, args...))
+}
+
+func (p *timeSpecParser) peek() string {
+	if p.i < len(p.tokens) {
+		return strings.ToLower(p.tokens[p.i])
+	}
+	return ""
+}
+
+// Parses a comma-separated list of items, each of which may be a range
+// `a-b`, calling `parse` for each end. Ranges of names may wrap around, such
+// as fri-mon.
+func parseRanges(s string, parse func(string) (int, bool), wrap int) ([]int, bool) {
+	var values []int
+	for _, item := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(item, "-")
+		start, ok := parse(first)
+		if !ok {
+			return nil, false
+		}
+		end := start
+		if isRange {
+			if end, ok = parse(last); !ok {
+				return nil, false
+			}
+		}
+		if end < start && wrap == 0 {
+			return nil, false
+		}
+		for v := start; ; v++ {
+			if wrap > 0 {
+				v %= wrap
+			}
+			values = append(values, v)
+			if v == end {
+				break
+			}
+		}
+	}
+	return values, true
+}
+
+func parseName(names []string) func(string) (int, bool) {
+	return func(s string) (int, bool) {
+		if len(s) < 3 {
+			return 0, false
+		}
+		i := slices.IndexFunc(names, func(name string) bool { return strings.HasPrefix(name, s) })
+		return i, i >= 0
+	}
+}
+
+func parseNumber(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n >= 0
+}
+
+func parseDayTime(s string) (DayTime, bool) {
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return DayTime{Hour: t.Hour(), Minute: t.Minute(), Second: t.Second()}, true
+		}
+	}
+	return DayTime{}, false
+}
+
+func weekdays(values []int) []time.Weekday {
+	days := make([]time.Weekday, 0, len(values))
+	for _, v := range values {
+		days = append(days, time.Weekday(v))
+	}
+	return days
+}
+
+func (p *timeSpecParser) parsePeriod() (PeriodNode, error) {
+	var period PeriodNode
+	daysGiven := false
+	for p.peek() != "" && p.peek() != ";" && !strings.HasPrefix(p.peek(), "utc") {
+		token := p.peek()
+		switch {
+		case slices.Contains(ordinalNames, token):
+			if daysGiven {
+				return period, p.errorf("days given more than once")
+			}
+			p.i++
+			days, ok := parseRanges(p.peek(), parseName(weekdayNames), 7)
+			if !ok {
+				return period, p.errorf("expected days of the week")
+			}
+			period.DayOf = &XDayOfNode{Ordinal: slices.Index(ordinalNames, token) + 1, Days: weekdays(days)}
+			daysGiven = true
+		case token == "day":
+			if daysGiven {
+				return period, p.errorf("days given more than once")
+			}
+			p.i++
+			days, ok := parseRanges(p.peek(), parseNumber, 0)
+			if !ok {
+				return period, p.errorf("expected day numbers")
+			}
+			period.Days = days
+			daysGiven = true
+		case strings.HasPrefix(token, "week"):
+			weeks, ok := parseRanges(token[4:], parseNumber, 0)
+			if !ok || slices.ContainsFunc(weeks, func(w int) bool { return w < 1 || w > 5 }) {
+				return period, p.errorf("expected weeks of the month from 1 to 5")
+			}
+			period.WeeksOfMonth = append(period.WeeksOfMonth, weeks...)
+		case strings.Contains(token, ":"):
+			for _, item := range strings.Split(token, ",") {
+				first, last, _ := strings.Cut(item, "-")
+				start, ok1 := parseDayTime(first)
+				end, ok2 := parseDayTime(last)
+				if !ok1 || !ok2 {
+					return period, p.errorf("expected times of the day, such as 09:00-17:00")
+				}
+				period.TimesOfDay = append(period.TimesOfDay, DayTimeBand{StartDayTime: start, EndDayTime: end})
+			}
+		case token[0] >= '0' && token[0] <= '9':
+			years, ok := parseRanges(token, parseNumber, 0)
+			if !ok || slices.ContainsFunc(years, func(y int) bool { return y < 1000 }) {
+				return period, p.errorf("expected years")
+			}
+			period.Years = append(period.Years, years...)
+		default:
+			if days, ok := parseRanges(token, parseName(weekdayNames), 7); ok {
+				if daysGiven {
+					return period, p.errorf("days given more than once")
+				}
+				period.DaysOfWeek = weekdays(days)
+				daysGiven = true
+			} else if months, ok := parseRanges(token, parseName(monthNames), 12); ok {
+				for _, month := range months {
+					period.Months = append(period.Months, time.January+time.Month(month))
+				}
+			} else {
+				return period, p.errorf("unrecognized %q", p.tokens[p.i])
+			}
+		}
+		p.i++
+	}
+	return period, nil
+}
+
+func (p *timeSpecParser) parseTime() (time.Time, error) {
+	p.i++
+	t, err := time.Parse(time.RFC3339, strings.ToUpper(p.peek()))
+	if err != nil {
+		return t, p.errorf("expected a time such as 2024-01-01T00:00:00Z")
+	}
+	p.i++
+	return t, nil
+}
+
+// Parses a time specification in a compact form: optionally `not`, then
+// either an absolute time specification or one or more periods separated by
+// semicolons, then optionally a time zone such as `UTC+2`.
+//
+// An absolute time specification is `from` followed by a start time and
+// `until` followed by an end time, either of which may be omitted, in the
+// form of IETF RFC 3339, such as:
+//
+//	from 2024-01-01T00:00:00Z until 2024-12-31T23:59:59Z
+//
+// A period is any combination of:
+//
+//   - days of the week, such as `mon-fri` or `sat,sun`;
+//   - an ordinal and days of the week, such as `2nd tue`;
+//   - `day` and day numbers, such as `day 1,15`, as described for
+//     [PeriodNode.Days];
+//   - weeks of the month, such as `week1,3` or `week2-4`;
+//   - months, such as `jan-mar,dec`;
+//   - years, such as `2024,2026-2028`;
+//   - times of the day, such as `09:00-12:00,13:00-17:30:30`.
+//
+// Lists are separated by commas without spaces. Names are not case-sensitive
+// and may be abbreviated to three or more letters. For example:
+//
+//	mon-fri 09:00-17:00; sat 10:00-12:00 UTC+1
+//	not 2nd tue 02:00-04:00
+func ParseTimeSpecification(s string) (*TimeSpecificationNode, error) {
+	p := &timeSpecParser{}
+	for i := 0; i < len(s); {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		start := i
+		if i < len(s) && s[i] == ';' {
+			i++
+		} else {
+			for i < len(s) && s[i] != ' ' && s[i] != '\t' && s[i] != ';' {
+				i++
+			}
+		}
+		if i > start {
+			p.tokens = append(p.tokens, s[start:i])
+			p.positions = append(p.positions, start)
+		}
+	}
+	spec := &TimeSpecificationNode{}
+	if p.peek() == "not" {
+		spec.NotThisTime = true
+		p.i++
+	}
+	var err error
+	switch p.peek() {
+	case "from", "until":
+		if p.peek() == "from" {
+			if spec.StartTime, err = p.parseTime(); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek() == "until" {
+			if spec.EndTime, err = p.parseTime(); err != nil {
+				return nil, err
+			}
+		}
+		if !spec.EndTime.IsZero() && spec.EndTime.Before(spec.StartTime) {
+			return nil, p.errorf("the end time is before the start time")
+		}
+	default:
+		for {
+			period, err := p.parsePeriod()
+			if err != nil {
+				return nil, err
+			}
+			if period.TimesOfDay == nil && period.Days == nil && period.DaysOfWeek == nil &&
+				period.DayOf == nil && period.WeeksOfMonth == nil && period.Months == nil && period.Years == nil {
+				return nil, p.errorf("expected a period")
+			}
+			spec.Periods = append(spec.Periods, period)
+			if p.peek() != ";" {
+				break
+			}
+			p.i++
+		}
+	}
+	if zone := p.peek(); strings.HasPrefix(zone, "utc") {
+		offset, err := strconv.Atoi(zone[3:])
+		if err != nil || offset < -12 || offset > 12 || (zone[3] != '+' && zone[3] != '-') {
+			return nil, p.errorf("expected a time zone from UTC-12 to UTC+12")
+		}
+		spec.TimeZone = offset
+		p.i++
+	}
+	if p.i < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.tokens[p.i])
+	}
+	return spec, nil
+}
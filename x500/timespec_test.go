@@ -0,0 +1,194 @@
+package x500
+
+import (
+	"encoding/asn1"
+	"testing"
+	"time"
+)
+
+func mustParseTimeSpecification(t *testing.T, s string) *TimeSpecificationNode {
+	t.Helper()
+	spec, err := ParseTimeSpecification(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestTimeSpecificationContains(t *testing.T) {
+	cases := []struct {
+		spec     string
+		time     string
+		contains bool
+	}{
+		{"mon-fri 09:00-17:00", "2024-03-05T10:30:00Z", true},
+		{"mon-fri 09:00-17:00", "2024-03-05T17:00:00Z", true},
+		{"mon-fri 09:00-17:00", "2024-03-05T17:00:01Z", false},
+		{"mon-fri 09:00-17:00", "2024-03-09T10:30:00Z", false},
+		{"mon-fri 09:00-17:00 UTC+2", "2024-03-05T07:30:00Z", true},
+		{"fri-mon", "2024-03-04T12:00:00Z", true},
+		{"fri-mon", "2024-03-05T12:00:00Z", false},
+		{"22:00-06:00", "2024-03-05T23:00:00Z", true},
+		{"22:00-06:00", "2024-03-05T05:59:59Z", true},
+		{"22:00-06:00", "2024-03-05T12:00:00Z", false},
+		{"2nd tue", "2024-03-12T12:00:00Z", true},
+		{"2nd tue", "2024-03-05T12:00:00Z", false},
+		{"not 2nd tue 02:00-04:00", "2024-03-12T03:00:00Z", false},
+		{"not 2nd tue 02:00-04:00", "2024-03-12T05:00:00Z", true},
+		{"day 1,15 jan-mar", "2024-02-15T00:00:00Z", true},
+		{"day 1,15 jan-mar", "2024-04-15T00:00:00Z", false},
+		{"day 60", "2024-02-29T12:00:00Z", true},
+		{"day 2 week1", "2024-03-04T12:00:00Z", true},
+		{"day 2 week1", "2024-03-11T12:00:00Z", false},
+		{"sat; sun 10:00-12:00", "2024-03-10T11:00:00Z", true},
+		{"sat; sun 10:00-12:00", "2024-03-10T13:00:00Z", false},
+		{"December 2024,2026", "2026-12-25T00:00:00Z", true},
+		{"December 2024,2026", "2025-12-25T00:00:00Z", false},
+		{"from 2024-01-01T00:00:00Z until 2024-12-31T23:59:59Z", "2024-06-01T00:00:00Z", true},
+		{"from 2024-01-01T00:00:00Z", "2023-06-01T00:00:00Z", false},
+		{"not until 2024-01-01T00:00:00Z", "2024-06-01T00:00:00Z", true},
+	}
+	for _, c := range cases {
+		at, err := time.Parse(time.RFC3339, c.time)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if contains := mustParseTimeSpecification(t, c.spec).Contains(at); contains != c.contains {
+			t.Errorf("%q contains %s: expected %t, but got %t", c.spec, c.time, c.contains, contains)
+		}
+	}
+}
+
+func TestTimeSpecificationNextTransition(t *testing.T) {
+	cases := []struct {
+		spec string
+		time string
+		next string
+	}{
+		{"mon-fri 09:00-17:00", "2024-03-05T10:30:00Z", "2024-03-05T17:00:01Z"},
+		{"mon-fri 09:00-17:00", "2024-03-08T18:00:00Z", "2024-03-11T09:00:00Z"},
+		{"sat,sun", "2024-03-06T12:00:00Z", "2024-03-09T00:00:00Z"},
+		{"day 29 feb", "2025-01-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"from 2024-01-01T00:00:00Z until 2024-12-31T23:59:59Z", "2023-06-01T00:00:00Z", "2024-01-01T00:00:00Z"},
+		{"from 2024-01-01T00:00:00Z until 2024-12-31T23:59:59Z", "2024-06-01T00:00:00Z", "2025-01-01T00:00:00Z"},
+		{"2024", "2025-06-01T00:00:00Z", ""},
+	}
+	for _, c := range cases {
+		at, err := time.Parse(time.RFC3339, c.time)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		next, ok := mustParseTimeSpecification(t, c.spec).NextTransition(at)
+		if c.next == "" {
+			if ok {
+				t.Errorf("%q after %s: expected no transition, but got %s", c.spec, c.time, next)
+			}
+			continue
+		}
+		if !ok || next.UTC().Format(time.RFC3339) != c.next {
+			t.Errorf("%q after %s: expected %s, but got %s", c.spec, c.time, c.next, next)
+		}
+	}
+}
+
+func TestTimeSpecificationMarshal(t *testing.T) {
+	for _, s := range []string{
+		"mon-fri 09:00-17:00,18:00-23:59:59; 2nd tue,thu 02:00-04:00 UTC-5",
+		"not day 1,15 week2-3 jan,jul 2024-2025",
+		"3rd sat",
+		"from 2024-01-01T00:00:00Z until 2024-12-31T23:59:59Z",
+	} {
+		spec := mustParseTimeSpecification(t, s)
+		encoded, err := spec.Marshal()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		der, err := asn1.Marshal(encoded)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var decoded TimeSpecification
+		if _, err := asn1.Unmarshal(der, &decoded); err != nil {
+			t.Error(err)
+			continue
+		}
+		node, err := DecodeTimeSpecification(decoded)
+		if err != nil {
+			t.Errorf("%q: %s", s, err)
+			continue
+		}
+		if node.NotThisTime != spec.NotThisTime || node.TimeZone != spec.TimeZone || len(node.Periods) != len(spec.Periods) {
+			t.Errorf("%q: expected %+v, but got %+v", s, spec, node)
+			continue
+		}
+		if !node.StartTime.Equal(spec.StartTime) || !node.EndTime.Equal(spec.EndTime) {
+			t.Errorf("%q: expected %s to %s, but got %s to %s", s, spec.StartTime, spec.EndTime, node.StartTime, node.EndTime)
+		}
+		// The periods are a SET OF, so compare their behaviour instead of
+		// their order.
+		at := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 24*400; i++ {
+			at = at.Add(time.Hour)
+			if node.Contains(at) != spec.Contains(at) {
+				t.Errorf("%q: decoded time specification differs at %s", s, at)
+				break
+			}
+		}
+	}
+}
+
+func TestTimeSpecificationMatchesAssertion(t *testing.T) {
+	spec := mustParseTimeSpecification(t, "mon-fri 09:00-17:00")
+	now := time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)
+	at := mustMarshalValue(t, time.Date(2024, time.March, 9, 10, 0, 0, 0, time.UTC), "generalized")
+	between := func(start, end time.Time, entirely bool) TimeAssertion {
+		return mustMarshalValue(t, TimeAssertion_between{StartTime: start, EndTime: end, Entirely: entirely}, "")
+	}
+	cases := []struct {
+		assertion TimeAssertion
+		matches   bool
+	}{
+		{asn1.RawValue{FullBytes: asn1.NullBytes}, true},
+		{at, false},
+		{between(now, now.Add(2*time.Hour), true), true},
+		{between(now, now.Add(8*time.Hour), true), false},
+		{between(now.Add(8*time.Hour), now.Add(20*time.Hour), false), false},
+		{between(now.Add(8*time.Hour), now.Add(24*time.Hour), false), true},
+	}
+	for i, c := range cases {
+		if err := unmarshalExactly(c.assertion, &c.assertion); err != nil {
+			t.Error(err)
+			continue
+		}
+		matches, err := spec.MatchesAssertion(c.assertion, now)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if matches != c.matches {
+			t.Errorf("case %d: expected %t, but got %t", i, c.matches, matches)
+		}
+	}
+}
+
+func TestParseTimeSpecificationErrors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"mon tue",
+		"mon 9-17",
+		"week6",
+		"mon UTC+13",
+		"from yesterday",
+		"from 2024-01-01T00:00:00Z until 2023-01-01T00:00:00Z",
+		"mon; ",
+		"mon UTC+1 tue",
+	} {
+		if _, err := ParseTimeSpecification(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
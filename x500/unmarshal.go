@@ -5,6 +5,8 @@ import (
 	"encoding/asn1"
 	"errors"
 	"fmt"
+	"math/big"
+	"net/netip"
 	"reflect"
 	"strings"
 	"unicode"
@@ -127,11 +129,72 @@ func unmarshalDateTimeAsString(octets []byte) (string, error) {
 	return string(octets), nil
 }
 
+// The counterpart of marshalWithExplicitRawValues: encoding/asn1 leaves the
+// explicit tag on an asn1.RawValue member of a struct, so it is removed here.
+func unmarshalWithExplicitRawValues(v reflect.Value, encoded asn1.RawValue, params string) error {
+	rest, err := asn1.UnmarshalWithParams(encodingOf(encoded), v.Addr().Interface(), params)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("trailing data")
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type != rawValueType || v.Field(i).IsZero() {
+			continue
+		}
+		if _, explicit := explicitTagOf(field); !explicit {
+			continue
+		}
+		inner, err := explicitlyTaggedValue(v.Field(i).Interface().(asn1.RawValue))
+		if err != nil {
+			return err
+		}
+		v.Field(i).Set(reflect.ValueOf(inner))
+	}
+	return nil
+}
+
+// Decodes `encoded` as an unsigned integer that fits in `bits` bits.
+func unmarshalUnsigned(encoded asn1.RawValue, bits int) (uint64, error) {
+	var i *big.Int
+	rest, err := asn1.Unmarshal(encodingOf(encoded), &i)
+	if err != nil {
+		return 0, err
+	}
+	if len(rest) > 0 {
+		return 0, errors.New("trailing data")
+	}
+	if i.Sign() < 0 || i.BitLen() > bits {
+		return 0, fmt.Errorf("integer %s out of range for %d-bit unsigned integer", i, bits)
+	}
+	return i.Uint64(), nil
+}
+
 func unmarshalValue(v reflect.Value, encoded asn1.RawValue, params fieldParameters) (err error) {
-	if params.tag != 0 && params.tag != encoded.Tag {
+	// As when marshalling, a string type applies to the elements of a list,
+	// rather than to the list itself.
+	isList := params.list && v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8
+	if params.tag != 0 && params.tag != encoded.Tag && !(isList && params.tag != asn1.TagSet) {
 		return fmt.Errorf("unexpected tag: expected %d but got %d", params.tag, encoded.Tag)
 	}
 
+	// If the value is a pointer, unmarshal into what it points to.
+	if v.Kind() == reflect.Pointer && v.Type() != bigIntType {
+		ptr := reflect.New(v.Type().Elem())
+		if err = unmarshalValue(ptr.Elem(), encoded, params); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	if reflect.PointerTo(v.Type()).Implements(rawValueUnmarshalerType) {
+		return v.Addr().Interface().(RawValueUnmarshaler).UnmarshalRawValue(encoded)
+	}
+
 	var s string
 	// This switch statement deals with specially-handled types.
 	switch v.Type() {
@@ -192,6 +255,70 @@ func unmarshalValue(v reflect.Value, encoded asn1.RawValue, params fieldParamete
 		}
 		v.Set(reflect.ValueOf(*csr))
 		return nil
+	case rawValueType:
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(encodingOf(encoded), &raw)
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 {
+			return errors.New("trailing data")
+		}
+		v.Set(reflect.ValueOf(raw))
+		return nil
+	case guideType:
+		return unmarshalWithExplicitRawValues(v, encoded, "set")
+	case enhancedGuideType, subtreeSpecType:
+		return unmarshalWithExplicitRawValues(v, encoded, "")
+	case hostPortType:
+		var hp HostPort
+		if encoded.Class == asn1.ClassUniversal && isStringTag(encoded.Tag) {
+			if err = unmarshalValue(reflect.ValueOf(&s).Elem(), encoded, params); err != nil {
+				return err
+			}
+			hp, err = ParseHostPort(s)
+		} else {
+			hp, err = decodeHostPort(encoded)
+		}
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(hp))
+		return nil
+	case cidrType:
+		var prefix CIDR
+		if encoded.Class == asn1.ClassUniversal && isStringTag(encoded.Tag) {
+			if err = unmarshalValue(reflect.ValueOf(&s).Elem(), encoded, params); err != nil {
+				return err
+			}
+			prefix, err = netip.ParsePrefix(s)
+		} else if encoded.Class == asn1.ClassUniversal && encoded.Tag == asn1.TagOctetString {
+			prefix, err = decodeCIDR(encoded.Bytes)
+		} else {
+			err = fmt.Errorf("unexpected tag for a cidr: %d", encoded.Tag)
+		}
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(prefix))
+		return nil
+	case transportAddressType:
+		var addrPort TransportAddress
+		if encoded.Class == asn1.ClassUniversal && isStringTag(encoded.Tag) {
+			if err = unmarshalValue(reflect.ValueOf(&s).Elem(), encoded, params); err != nil {
+				return err
+			}
+			addrPort, err = netip.ParseAddrPort(s)
+		} else if encoded.Class == asn1.ClassUniversal && encoded.Tag == asn1.TagOctetString {
+			addrPort, err = decodeTransportAddress(encoded.Bytes)
+		} else {
+			err = fmt.Errorf("unexpected tag for a transport address: %d", encoded.Tag)
+		}
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(addrPort))
+		return nil
 	}
 
 	k := v.Kind()
@@ -206,6 +333,28 @@ func unmarshalValue(v reflect.Value, encoded asn1.RawValue, params fieldParamete
 		if len(rest) > 0 {
 			return errors.New("trailing data")
 		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := unmarshalUnsigned(encoded, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var raw asn1.RawValue
+		if _, err := asn1.Unmarshal(encodingOf(encoded), &raw); err != nil {
+			return err
+		}
+		f, err := decodeReal(raw)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Complex64, reflect.Complex128:
+		c, err := decodeComplexNumber(encoded)
+		if err != nil {
+			return err
+		}
+		v.SetComplex(c)
 	case reflect.String:
 		switch encoded.Tag {
 		case tagTime:
@@ -322,7 +471,16 @@ func unmarshalField(v reflect.Value, attrs map[string]Attribute, params fieldPar
 	}
 	k := v.Kind()
 	t := v.Type()
-	if k == reflect.Slice && !params.list && t.Elem().Kind() != reflect.Uint8 && t != objectIdentifierType && t != dnType && t != rdnType {
+	// If the field is a pointer, unmarshal into what it points to.
+	if k == reflect.Pointer && t != bigIntType {
+		ptr := reflect.New(t.Elem())
+		if err = unmarshalField(ptr.Elem(), attrs, params); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if k == reflect.Slice && !params.list && t.Elem().Kind() != reflect.Uint8 && t != objectIdentifierType && t != dnType && t != rdnType && !reflect.PointerTo(t).Implements(rawValueUnmarshalerType) {
 		l := attr.Len()
 		values := reflect.MakeSlice(v.Type(), l, l)
 		for i := 0; i < l; i++ {
@@ -395,3 +553,47 @@ func Unmarshal(attrs []Attribute, val any) error {
   return UnmarshalWithParams(attrs, val, "")
 }
 
+// Convert the information of an EntryInformation into attributes. Attribute
+// types returned without values, as happens when only types are requested,
+// produce attributes with no values.
+func EntryInformationAttributes(info *EntryInformation) (attrs []Attribute, err error) {
+	attrs = make([]Attribute, 0, len(info.Information))
+	for _, item := range info.Information {
+		if item.Class == asn1.ClassUniversal && item.Tag == asn1.TagOID {
+			attr := Attribute{}
+			rest, err := asn1.Unmarshal(item.FullBytes, &attr.Type)
+			if err != nil {
+				return nil, err
+			}
+			if len(rest) > 0 {
+				return nil, errors.New("trailing data")
+			}
+			attrs = append(attrs, attr)
+			continue
+		}
+		if item.Class != asn1.ClassUniversal || item.Tag != asn1.TagSequence {
+			return nil, fmt.Errorf("unrecognized entry information syntax (class=%d, tag=%d)", item.Class, item.Tag)
+		}
+		attr := Attribute{}
+		rest, err := asn1.Unmarshal(item.FullBytes, &attr)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) > 0 {
+			return nil, errors.New("trailing data")
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+// Unmarshal the attributes of an EntryInformation, such as one returned by a
+// read or search operation, into a struct. Values with contexts are decoded
+// just like values without them. See the documentation for Marshal.
+func UnmarshalEntryInformation(info *EntryInformation, val any) error {
+	attrs, err := EntryInformationAttributes(info)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(attrs, val)
+}
@@ -100,4 +100,118 @@ func TestListUnmarshaling(t *testing.T) {
 	}
 }
 
-// TODO: Pointer-typed fields
+func TestUnmarshalEntryInformation(t *testing.T) {
+	type Person struct {
+		CommonName []string `x500:"oid:2.5.4.3"`
+		Surname    string   `x500:"oid:2.5.4.4"`
+	}
+	cnAttr := Attribute{
+		Type: Id_at_commonName,
+		ValuesWithContext: []Attribute_valuesWithContext_Item{
+			addLanguageContext(encodeString("Spongebob"), "en"),
+			addLanguageContext(encodeString("Bob Esponja"), "es"),
+		},
+	}
+	cnBytes, err := asn1.Marshal(cnAttr)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	snBytes, err := asn1.Marshal(Id_at_surname)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	info := EntryInformation{
+		Information: []EntryInformation_information_Item{
+			{FullBytes: cnBytes},
+			{FullBytes: snBytes},
+		},
+	}
+	_, err = asn1.Unmarshal(cnBytes, &info.Information[0])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_, err = asn1.Unmarshal(snBytes, &info.Information[1])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	p := Person{}
+	err = UnmarshalEntryInformation(&info, &p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(p.CommonName) != 2 || p.CommonName[0] != "Spongebob" || p.CommonName[1] != "Bob Esponja" {
+		t.Errorf("commonName was %v", p.CommonName)
+		return
+	}
+	if p.Surname != "" {
+		t.Errorf("surname was %s", p.Surname)
+		return
+	}
+}
+
+func TestPointerFields(t *testing.T) {
+	type Person struct {
+		CommonName  *string   `x500:"oid:2.5.4.3"`
+		Surname     *[]string `x500:"oid:2.5.4.4"`
+		Title       *string   `x500:"oid:2.5.4.12"`
+		Description []*string `x500:"oid:2.5.4.13"`
+		Number      *uint16   `x500:"oid:2.5.4.999"`
+	}
+	cn := "Spongebob"
+	description := "Lives in a pineapple"
+	number := uint16(42)
+	p := Person{
+		CommonName:  &cn,
+		Surname:     &[]string{"Squarepants"},
+		Description: []*string{&description},
+		Number:      &number,
+	}
+	attrs, err := Marshal(p)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(attrs) != 4 {
+		t.Errorf("a nil pointer should produce no attribute, but got %d attributes", len(attrs))
+		return
+	}
+	var out Person
+	err = Unmarshal(attrs, &out)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if out.CommonName == nil || *out.CommonName != cn {
+		t.Errorf("commonName was %v", out.CommonName)
+		return
+	}
+	if out.Surname == nil || len(*out.Surname) != 1 || (*out.Surname)[0] != "Squarepants" {
+		t.Errorf("surname was %v", out.Surname)
+		return
+	}
+	if out.Title != nil {
+		t.Error("title should have been left nil")
+		return
+	}
+	if len(out.Description) != 1 || *out.Description[0] != description {
+		t.Errorf("description was %v", out.Description)
+		return
+	}
+	if out.Number == nil || *out.Number != number {
+		t.Errorf("number was %v", out.Number)
+		return
+	}
+
+	type Required struct {
+		CommonName *string `x500:"oid:2.5.4.3,must"`
+	}
+	if _, err := Marshal(Required{}); err == nil {
+		t.Error("a nil pointer for a required attribute should not be marshalled")
+		return
+	}
+}